@@ -11,11 +11,18 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/backup"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/hostlock"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/server"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/server/metrics"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/service"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
 )
 
 var (
@@ -45,6 +52,10 @@ func main() {
 	cliapp.UsageText = "clickhouse-backup <command> [-t, --tables=<db>.<table>] <backup_name>"
 	cliapp.Description = "Run as 'root' or 'clickhouse' user"
 	cliapp.Version = version
+	cliapp.Metadata = map[string]interface{}{
+		"gitCommit": gitCommit,
+		"buildDate": buildDate,
+	}
 	// @todo add GCS and Azure support when resolve https://github.com/googleapis/google-cloud-go/issues/8169 and https://github.com/Azure/azure-sdk-for-go/issues/21047
 	if strings.HasSuffix(version, "fips") {
 		_ = os.Setenv("AWS_USE_FIPS_ENDPOINT", "true")
@@ -111,11 +122,23 @@ func main() {
 		{
 			Name:        "create",
 			Usage:       "Create new backup",
-			UsageText:   "clickhouse-backup create [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from-remote=<backup-name>] [-s, --schema] [--rbac] [--configs] [--skip-check-parts-columns] [--resume] <backup_name>",
+			UsageText:   "clickhouse-backup create [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [--partitions=<partition_names>] [--diff-from-remote=<backup-name>] [-s, --schema] [--rbac] [--configs] [--skip-check-parts-columns] [--strict] [--resume] [--tag=<key>=<value>] [--metadata=<key>=<value>] [--include-detached] <backup_name>",
 			Description: "Create new backup",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.CreateBackup(c.Args().First(), c.String("diff-from-remote"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("skip-check-parts-columns"), c.Bool("resume"), version, c.Int("command-id"))
+				userTags, err := metadata.ParseUserTags(c.StringSlice("tag"))
+				if err != nil {
+					return err
+				}
+				customMetadata, err := metadata.ParseCustomMetadata(c.StringSlice("metadata"))
+				if err != nil {
+					return err
+				}
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithStrictTablePattern(c.Bool("strict")), backup.WithExcludeTables(c.StringSlice("exclude")), backup.WithUserTags(userTags), backup.WithCustomMetadata(customMetadata), backup.WithIncludeDetached(c.Bool("include-detached")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.CreateBackup(c.Args().First(), c.String("diff-from-remote"), tablePattern, c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("skip-check-parts-columns"), c.Bool("resume"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -123,6 +146,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Create backup only matched with table name patterns, separated by comma, allow ? and * as wildcard",
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Create backup only for selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip tables matched with patterns from backup, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringFlag{
 					Name:   "diff-from-remote",
 					Hidden: false,
@@ -136,6 +169,7 @@ func main() {
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -169,6 +203,26 @@ func main() {
 					Hidden: false,
 					Usage:  "Skip check system.parts_columns to allow backup inconsistent column types for data parts",
 				},
+				cli.BoolFlag{
+					Name:   "strict",
+					Hidden: false,
+					Usage:  "Fail before freeze when any table name pattern from --tables or --database doesn't match tables, prevents empty backups due typo in pattern",
+				},
+				cli.StringSliceFlag{
+					Name:   "tag",
+					Hidden: false,
+					Usage:  "Store `key=value` user tag in backup metadata, could be repeated or separated by comma, like --tag env=prod --tag reason=pre-upgrade",
+				},
+				cli.StringSliceFlag{
+					Name:   "metadata",
+					Hidden: false,
+					Usage:  "Store free-form `key=value` pair in custom_metadata of metadata.json, could be repeated, like --metadata app_version=1.2.3 --metadata ticket=OPS-123",
+				},
+				cli.BoolFlag{
+					Name:   "include-detached",
+					Hidden: false,
+					Usage:  "Hardlink 'detached' folders of backed up tables into backup, parts will not attach during restore, not work when 'use_embedded_backup_restore: true'",
+				},
 				cli.BoolFlag{
 					Name:   "resume, resumable",
 					Hidden: false,
@@ -179,11 +233,23 @@ func main() {
 		{
 			Name:        "create_remote",
 			Usage:       "Create and upload new backup",
-			UsageText:   "clickhouse-backup create_remote [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from=<local_backup_name>] [--diff-from-remote=<local_backup_name>] [--schema] [--rbac] [--configs] [--resumable] [--skip-check-parts-columns] <backup_name>",
+			UsageText:   "clickhouse-backup create_remote [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [--partitions=<partition_names>] [--diff-from=<local_backup_name>] [--diff-from-remote=<local_backup_name>] [--schema] [--rbac] [--configs] [--resumable] [--skip-check-parts-columns] [--strict] [--tag=<key>=<value>] [--metadata=<key>=<value>] [--include-detached] <backup_name>",
 			Description: "Create and upload",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.CreateToRemote(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), c.Bool("skip-check-parts-columns"), version, c.Int("command-id"))
+				userTags, err := metadata.ParseUserTags(c.StringSlice("tag"))
+				if err != nil {
+					return err
+				}
+				customMetadata, err := metadata.ParseCustomMetadata(c.StringSlice("metadata"))
+				if err != nil {
+					return err
+				}
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithStrictTablePattern(c.Bool("strict")), backup.WithExcludeTables(c.StringSlice("exclude")), backup.WithUserTags(userTags), backup.WithCustomMetadata(customMetadata), backup.WithIncludeDetached(c.Bool("include-detached")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.CreateToRemote(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), tablePattern, c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), c.Bool("skip-check-parts-columns"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -191,6 +257,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Create and upload backup only matched with table name patterns, separated by comma, allow ? and * as wildcard",
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Create and upload backup only for selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip tables matched with patterns from backup, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
@@ -199,6 +275,7 @@ func main() {
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -247,6 +324,26 @@ func main() {
 					Hidden: false,
 					Usage:  "Skip check system.parts_columns to allow backup inconsistent column types for data parts",
 				},
+				cli.BoolFlag{
+					Name:   "strict",
+					Hidden: false,
+					Usage:  "Fail before freeze when any table name pattern from --tables or --database doesn't match tables, prevents empty backups due typo in pattern",
+				},
+				cli.StringSliceFlag{
+					Name:   "tag",
+					Hidden: false,
+					Usage:  "Store `key=value` user tag in backup metadata, could be repeated or separated by comma, like --tag env=prod --tag reason=pre-upgrade",
+				},
+				cli.StringSliceFlag{
+					Name:   "metadata",
+					Hidden: false,
+					Usage:  "Store free-form `key=value` pair in custom_metadata of metadata.json, could be repeated, like --metadata app_version=1.2.3 --metadata ticket=OPS-123",
+				},
+				cli.BoolFlag{
+					Name:   "include-detached",
+					Hidden: false,
+					Usage:  "Hardlink 'detached' folders of backed up tables into backup, parts will not attach during restore, not work when 'use_embedded_backup_restore: true'",
+				},
 				cli.BoolFlag{
 					Name:   "delete, delete-source, delete-local",
 					Hidden: false,
@@ -257,10 +354,14 @@ func main() {
 		{
 			Name:      "upload",
 			Usage:     "Upload backup to remote storage",
-			UsageText: "clickhouse-backup upload [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [-s, --schema] [--diff-from=<local_backup_name>] [--diff-from-remote=<remote_backup_name>] [--resumable] <backup_name>",
+			UsageText: "clickhouse-backup upload [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [--partitions=<partition_names>] [-s, --schema] [--diff-from=<local_backup_name>] [--diff-from-remote=<remote_backup_name>] [--resumable] <backup_name>",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.Upload(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("resume"), version, c.Int("command-id"))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithExcludeTables(c.StringSlice("exclude")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.Upload(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), tablePattern, c.StringSlice("partitions"), c.Bool("s"), c.Bool("resume"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -278,6 +379,16 @@ func main() {
 					Usage:  "Upload data only for matched table name patterns, separated by comma, allow ? and * as wildcard",
 					Hidden: false,
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Upload data only for selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip upload for tables matched with patterns, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
@@ -286,6 +397,7 @@ func main() {
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -309,21 +421,35 @@ func main() {
 		{
 			Name:      "list",
 			Usage:     "List of backups",
-			UsageText: "clickhouse-backup list [all|local|remote] [latest|previous]",
+			UsageText: "clickhouse-backup list [--tag=<key>=<value>] [all|local|remote] [latest|previous|databases]",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				err := b.List(c.Args().Get(0), c.Args().Get(1))
+				userTagsFilter, err := metadata.ParseUserTags(c.StringSlice("tag"))
+				if err != nil {
+					return err
+				}
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithUserTagsFilter(userTagsFilter))
+				err = b.List(c.Args().Get(0), c.Args().Get(1))
 				return err
 			},
-			Flags: cliapp.Flags,
+			Flags: append(cliapp.Flags,
+				cli.StringSliceFlag{
+					Name:   "tag",
+					Hidden: false,
+					Usage:  "Show only backups which contain all `key=value` user tags, could be repeated or separated by comma",
+				},
+			),
 		},
 		{
 			Name:      "download",
 			Usage:     "Download backup from remote storage",
-			UsageText: "clickhouse-backup download [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [-s, --schema] [--resumable] <backup_name>",
+			UsageText: "clickhouse-backup download [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [--partitions=<partition_names>] [-s, --schema] [--resumable] [--insecure] <backup_name>",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.Download(c.Args().First(), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("resume"), version, c.Int("command-id"))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithExcludeTables(c.StringSlice("exclude")), backup.WithInsecureMetadata(c.Bool("insecure")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.Download(c.Args().First(), tablePattern, c.StringSlice("partitions"), c.Bool("s"), c.Bool("resume"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -331,6 +457,16 @@ func main() {
 					Usage:  "Download objects which matched with table name patterns, separated by comma, allow ? and * as wildcard",
 					Hidden: false,
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Download objects only for selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip download for tables matched with patterns, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
@@ -339,6 +475,7 @@ func main() {
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -352,15 +489,24 @@ func main() {
 					Hidden: false,
 					Usage:  "Save intermediate download state and resume download if backup exists on local storage, ignored with 'remote_storage: custom' or 'use_embedded_backup_restore: true'",
 				},
+				cli.BoolFlag{
+					Name:   "insecure",
+					Hidden: false,
+					Usage:  "Skip metadata.json signature check when 'general->metadata_signing_key' is set, allow restore unsigned or tampered backup",
+				},
 			),
 		},
 		{
 			Name:      "restore",
 			Usage:     "Create schema and restore data from backup",
-			UsageText: "clickhouse-backup restore  [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [-s, --schema] [-d, --data] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--resume] <backup_name>",
+			UsageText: "clickhouse-backup restore  [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [-s, --schema] [-d, --data] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--resume] [--replicated-ddl-wait] [--restore-schema-on-cluster=<cluster>] [--replicated-to-merge-tree] [--insecure] [--rebuild-projections] [--ignore-missing] [--strict] [--include-detached] [--attach-only] [--dry-run] <backup_name>",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.Restore(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("data"), c.Bool("drop"), c.Bool("ignore-dependencies"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), version, c.Int("command-id"))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithExcludeTables(c.StringSlice("exclude")), backup.WithRestoreSchemaOnCluster(c.String("restore-schema-on-cluster")), backup.WithReplicatedToMergeTree(c.Bool("replicated-to-merge-tree")), backup.WithInsecureMetadata(c.Bool("insecure")), backup.WithRebuildProjections(c.Bool("rebuild-projections")), backup.WithIgnoreMissingTables(c.Bool("ignore-missing")), backup.WithStrictTablePattern(c.Bool("strict")), backup.WithAttachOnly(c.Bool("attach-only")), backup.WithIncludeDetached(c.Bool("include-detached")), backup.WithRestoreDryRun(c.Bool("dry-run")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.Restore(c.Args().First(), tablePattern, c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("data"), c.Bool("drop"), c.Bool("ignore-dependencies"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), c.Bool("replicated-ddl-wait"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -368,6 +514,16 @@ func main() {
 					Usage:  "Restore only database and objects which matched with table name patterns, separated by comma, allow ? and * as wildcard",
 					Hidden: false,
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Restore only selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip restore for tables matched with patterns, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringSliceFlag{
 					Name:   "restore-database-mapping, m",
 					Usage:  "Define the rule to restore data. For the database not defined in this struct, the program will not deal with it.",
@@ -375,17 +531,19 @@ func main() {
 				},
 				cli.StringSliceFlag{
 					Name:   "restore-table-mapping, tm",
-					Usage:  "Define the rule to restore data. For the table not defined in this struct, the program will not deal with it.",
+					Usage:  "Define the rule to restore data, table:new_table for tables in all databases or db.table:db.new_table for one table, mapped tables are created alongside source tables. For the table not defined in this struct, the program will not deal with it.",
 					Hidden: false,
 				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
 					Usage: "Restore backup only for selected partition names, separated by comma\n" +
+						"Existing tables keep schema and other partitions, only selected partitions are dropped and attached from backup, use --rm to recreate tables\n" +
 						"If PARTITION BY clause returns numeric not hashed values for `partition_id` field in system.parts table, then use --partitions=partition_id1,partition_id2 format\n" +
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -434,15 +592,72 @@ func main() {
 					Hidden: false,
 					Usage:  "Will resume download for object disk data",
 				},
+				cli.BoolFlag{
+					Name:   "replicated-ddl-wait",
+					Hidden: false,
+					Usage:  "Wait until all replicas of ENGINE=Replicated databases apply restored DDL before restore data, timeout is 'clickhouse->timeout'",
+				},
+				cli.StringFlag{
+					Name:   "restore-schema-on-cluster",
+					Hidden: false,
+					Usage:  "Execute schema related SQL queries with ON CLUSTER clause for selected cluster, system.macros values could be used, overrides 'general->restore_schema_on_cluster'",
+				},
+				cli.BoolFlag{
+					Name:   "replicated-to-merge-tree",
+					Hidden: false,
+					Usage:  "Restore Replicated*MergeTree tables as *MergeTree without keeper path and replica name, and ENGINE=Replicated databases as Atomic, useful to restore replicated cluster backup into standalone server",
+				},
+				cli.BoolFlag{
+					Name:   "rebuild-projections",
+					Hidden: false,
+					Usage:  "Run MATERIALIZE PROJECTION for all table projections after restore data, useful when projections data in backup is absent or broken",
+				},
+				cli.BoolFlag{
+					Name:   "ignore-missing",
+					Hidden: false,
+					Usage:  "Log warning instead of fail when table pattern from --tables or --database doesn't match any table in backup, by default restore fails only when no table matched, or with --strict when any pattern doesn't match",
+				},
+				cli.BoolFlag{
+					Name:   "strict",
+					Hidden: false,
+					Usage:  "Fail before restore when any table name pattern from --tables or --database doesn't match tables in backup, by default only restore without any matched table fails",
+				},
+				cli.BoolFlag{
+					Name:   "include-detached",
+					Hidden: false,
+					Usage:  "Put parts from 'detached' folders stored by 'create --include-detached' back into 'detached' folders of restored tables, existing detached parts are kept",
+				},
+				cli.BoolFlag{
+					Name:   "attach-only",
+					Hidden: false,
+					Usage:  "Restore data into already existing tables only, implies --data, don't create databases and tables, fail before attach any data when table is absent, backup columns are absent or have another type, or engine, PARTITION BY or ORDER BY differ on server",
+				},
+				cli.BoolFlag{
+					Name:   "dry-run",
+					Hidden: false,
+					Usage:  "Print CREATE and ATTACH statements and data parts for each table which restore would apply, nothing will be executed",
+				},
+				cli.BoolFlag{
+					Name:   "insecure",
+					Hidden: false,
+					Usage:  "Skip metadata.json signature check when 'general->metadata_signing_key' is set, allow restore unsigned or tampered backup",
+				},
 			),
 		},
 		{
 			Name:      "restore_remote",
 			Usage:     "Download and restore",
-			UsageText: "clickhouse-backup restore_remote [--schema] [--data] [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--skip-rbac] [--skip-configs] [--resumable] <backup_name>",
+			UsageText: "clickhouse-backup restore_remote [--schema] [--data] [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] [--exclude=<db>.<table>[,<...>]] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--skip-rbac] [--skip-configs] [--resumable] [--replicated-ddl-wait] [--restore-schema-on-cluster=<cluster>] [--replicated-to-merge-tree] [--insecure] [--rebuild-projections] [--ignore-missing] [--strict] [--include-detached] [--attach-only] [--object-disk=<disk_name>] <backup_name>",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.RestoreFromRemote(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("d"), c.Bool("rm"), c.Bool("i"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), version, c.Int("command-id"))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithExcludeTables(c.StringSlice("exclude")), backup.WithRestoreSchemaOnCluster(c.String("restore-schema-on-cluster")), backup.WithReplicatedToMergeTree(c.Bool("replicated-to-merge-tree")), backup.WithInsecureMetadata(c.Bool("insecure")), backup.WithRebuildProjections(c.Bool("rebuild-projections")), backup.WithIgnoreMissingTables(c.Bool("ignore-missing")), backup.WithStrictTablePattern(c.Bool("strict")), backup.WithAttachOnly(c.Bool("attach-only")), backup.WithIncludeDetached(c.Bool("include-detached")), withTransferObserver(c))
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				if objectDisk := c.String("object-disk"); objectDisk != "" {
+					return b.RestoreRemoteToObjectDisk(c.Args().First(), tablePattern, c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), objectDisk, c.Bool("rm"), c.Bool("i"), version, c.Int("command-id"))
+				}
+				return b.RestoreFromRemote(c.Args().First(), tablePattern, c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("d"), c.Bool("rm"), c.Bool("i"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), c.Bool("replicated-ddl-wait"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -450,6 +665,16 @@ func main() {
 					Usage:  "Download and restore objects which matched with table name patterns, separated by comma, allow ? and * as wildcard",
 					Hidden: false,
 				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Download and restore only selected databases, separated by comma, could be combined with --tables",
+				},
+				cli.StringSliceFlag{
+					Name:   "exclude, exclude-tables",
+					Hidden: false,
+					Usage:  "Skip download and restore for tables matched with patterns, separated by comma, pattern without database applies to all databases, allow ? and * as wildcard, extends clickhouse->skip_tables",
+				},
 				cli.StringSliceFlag{
 					Name:   "restore-database-mapping, m",
 					Usage:  "Define the rule to restore data. For the database not defined in this struct, the program will not deal with it.",
@@ -457,17 +682,19 @@ func main() {
 				},
 				cli.StringSliceFlag{
 					Name:   "restore-table-mapping, tm",
-					Usage:  "Define the rule to restore data. For the database not defined in this struct, the program will not deal with it.",
+					Usage:  "Define the rule to restore data, table:new_table for tables in all databases or db.table:db.new_table for one table, mapped tables are created alongside source tables. For the table not defined in this struct, the program will not deal with it.",
 					Hidden: false,
 				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
 					Usage: "Download and restore backup only for selected partition names, separated by comma\n" +
+						"Existing tables keep schema and other partitions, only selected partitions are dropped and attached from backup, use --rm to recreate tables\n" +
 						"If PARTITION BY clause returns numeric not hashed values for `partition_id` field in system.parts table, then use --partitions=partition_id1,partition_id2 format\n" +
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -516,14 +743,93 @@ func main() {
 					Hidden: false,
 					Usage:  "Save intermediate download state and resume download if backup exists on remote storage, ignored with 'remote_storage: custom' or 'use_embedded_backup_restore: true'",
 				},
+				cli.BoolFlag{
+					Name:   "replicated-ddl-wait",
+					Hidden: false,
+					Usage:  "Wait until all replicas of ENGINE=Replicated databases apply restored DDL before restore data, timeout is 'clickhouse->timeout'",
+				},
+				cli.StringFlag{
+					Name:   "restore-schema-on-cluster",
+					Hidden: false,
+					Usage:  "Execute schema related SQL queries with ON CLUSTER clause for selected cluster, system.macros values could be used, overrides 'general->restore_schema_on_cluster'",
+				},
+				cli.BoolFlag{
+					Name:   "replicated-to-merge-tree",
+					Hidden: false,
+					Usage:  "Restore Replicated*MergeTree tables as *MergeTree without keeper path and replica name, and ENGINE=Replicated databases as Atomic, useful to restore replicated cluster backup into standalone server",
+				},
+				cli.BoolFlag{
+					Name:   "rebuild-projections",
+					Hidden: false,
+					Usage:  "Run MATERIALIZE PROJECTION for all table projections after restore data, useful when projections data in backup is absent or broken",
+				},
+				cli.BoolFlag{
+					Name:   "ignore-missing",
+					Hidden: false,
+					Usage:  "Log warning instead of fail when table pattern from --tables or --database doesn't match any table in backup, by default restore fails only when no table matched, or with --strict when any pattern doesn't match",
+				},
+				cli.BoolFlag{
+					Name:   "strict",
+					Hidden: false,
+					Usage:  "Fail before restore when any table name pattern from --tables or --database doesn't match tables in backup, by default only restore without any matched table fails",
+				},
+				cli.BoolFlag{
+					Name:   "include-detached",
+					Hidden: false,
+					Usage:  "Put parts from 'detached' folders stored by 'create --include-detached' back into 'detached' folders of restored tables, existing detached parts are kept",
+				},
+				cli.BoolFlag{
+					Name:   "attach-only",
+					Hidden: false,
+					Usage:  "Restore data into already existing tables only, implies --data, don't create databases and tables, fail before attach any data when table is absent, backup columns are absent or have another type, or engine, PARTITION BY or ORDER BY differ on server",
+				},
+				cli.BoolFlag{
+					Name:   "insecure",
+					Hidden: false,
+					Usage:  "Skip metadata.json signature check when 'general->metadata_signing_key' is set, allow restore unsigned or tampered backup",
+				},
+				cli.StringFlag{
+					Name:   "object-disk",
+					Hidden: false,
+					Usage:  "Restore schema and server-side copy data parts from remote storage directly into selected S3 disk without local download, requires 'remote_storage: s3' and backup uploaded with 'compression_format: none', --restore-database-mapping, --restore-table-mapping and --partitions are supported",
+				},
+			),
+		},
+		{
+			Name:      "diff-schema",
+			Usage:     "Compare tables and columns from local backup with current clickhouse-server schema",
+			UsageText: "clickhouse-backup diff-schema [-t, --tables=<db>.<table>] [--database=<db1>,<db2>] <backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Args().First() == "" {
+					log.Err(fmt.Errorf("backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				tablePattern, err := backup.ApplyDatabaseFilter(c.String("t"), c.StringSlice("database"))
+				if err != nil {
+					return err
+				}
+				return b.DiffSchema(c.Args().First(), tablePattern, c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "table, tables, t",
+					Hidden: false,
+					Usage:  "Compare only tables which matched with table name patterns, separated by comma, allow ? and * as wildcard",
+				},
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Compare only tables from selected databases, separated by comma, could be combined with --tables",
+				},
 			),
 		},
 		{
 			Name:      "delete",
 			Usage:     "Delete specific backup",
-			UsageText: "clickhouse-backup delete <local|remote> <backup_name>",
+			UsageText: "clickhouse-backup delete <local|remote> [--database=<db1>,<db2>] [--force] <backup_name>",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithDeletePinned(c.Bool("force")))
 				if c.Args().Get(1) == "" {
 					log.Err(fmt.Errorf("backup name must be defined")).Send()
 					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
@@ -532,9 +838,113 @@ func main() {
 					log.Err(fmt.Errorf("Unknown command '%s'\n", c.Args().Get(0))).Send()
 					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
 				}
+				if len(c.StringSlice("database")) > 0 {
+					return b.DeleteDatabases(c.Args().Get(0), c.Args().Get(1), c.StringSlice("database"), c.Int("command-id"))
+				}
 				return b.Delete(c.Args().Get(0), c.Args().Get(1), c.Int("command-id"))
 			},
-			Flags: cliapp.Flags,
+			Flags: append(cliapp.Flags,
+				cli.StringSliceFlag{
+					Name:   "database, databases",
+					Hidden: false,
+					Usage:  "Delete only selected databases from local backup, separated by comma, other databases stay in backup",
+				},
+				cli.BoolFlag{
+					Name:   "force",
+					Hidden: false,
+					Usage:  "Delete backup even if it pinned",
+				},
+			),
+		},
+		{
+			Name:      "pin",
+			Usage:     "Protect backup from retention and delete without --force",
+			UsageText: "clickhouse-backup pin [--location=<local|remote>] <backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Args().Get(0) == "" {
+					log.Err(fmt.Errorf("backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				return b.Pin(c.String("location"), c.Args().Get(0), true, c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "location",
+					Hidden: false,
+					Usage:  "Pin only local or only remote backup, by default pin backup on each storage where it exists",
+				},
+			),
+		},
+		{
+			Name:      "unpin",
+			Usage:     "Remove protection from retention and delete, added by pin",
+			UsageText: "clickhouse-backup unpin [--location=<local|remote>] <backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Args().Get(0) == "" {
+					log.Err(fmt.Errorf("backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				return b.Pin(c.String("location"), c.Args().Get(0), false, c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "location",
+					Hidden: false,
+					Usage:  "Unpin only local or only remote backup, by default unpin backup on each storage where it exists",
+				},
+			),
+		},
+		{
+			Name:      "rename",
+			Usage:     "Rename local and remote backup",
+			UsageText: "clickhouse-backup rename [--location=<local|remote>] <backup_name> <new_backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Args().Get(0) == "" || c.Args().Get(1) == "" {
+					log.Err(fmt.Errorf("backup name and new backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				return b.Rename(c.String("location"), c.Args().Get(0), c.Args().Get(1), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "location",
+					Hidden: false,
+					Usage:  "Rename only local or only remote backup, by default rename backup on each storage where it exists",
+				},
+			),
+		},
+		{
+			Name:      "copy",
+			Usage:     "Copy remote backup to another remote storage without unpacking",
+			UsageText: "clickhouse-backup copy --from=<main|replica_name|replica_config_path> --to=<main|replica_name|replica_config_path> [--insecure] <backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c), backup.WithInsecureMetadata(c.Bool("insecure")))
+				if c.Args().Get(0) == "" {
+					log.Err(fmt.Errorf("backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				return b.Copy(c.Args().Get(0), c.String("from"), c.String("to"), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "from",
+					Hidden: false,
+					Usage:  "Source remote storage, `main` for general->remote_storage, name of general->replica_configs file without extension or path to replica config file",
+				},
+				cli.StringFlag{
+					Name:   "to",
+					Hidden: false,
+					Usage:  "Destination remote storage, the same values as for --from, backup shall not exist on destination",
+				},
+				cli.BoolFlag{
+					Name:   "insecure",
+					Hidden: false,
+					Usage:  "Skip metadata.json signature check of source backup when 'general->metadata_signing_key' is set in source config",
+				},
+			),
 		},
 		{
 			Name:  "default-config",
@@ -553,13 +963,29 @@ func main() {
 			Flags: cliapp.Flags,
 		},
 		{
-			Name:  "clean",
-			Usage: "Remove data in 'shadow' folder from all 'path' folders available from 'system.disks'",
+			Name:      "clean",
+			Usage:     "Remove data in 'shadow' folder from all 'path' folders available from 'system.disks'",
+			UsageText: "clickhouse-backup clean [--broken] [--dry-run]",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Bool("broken") {
+					_, err := b.CleanLocalBroken(c.Bool("dry-run"), c.Int("command-id"))
+					return err
+				}
 				return b.Clean(context.Background())
 			},
-			Flags: cliapp.Flags,
+			Flags: append(cliapp.Flags,
+				cli.BoolFlag{
+					Name:   "broken",
+					Hidden: false,
+					Usage:  "Instead of 'shadow' cleaning, remove local backups without metadata.json or with missing table metadata and data parts, like after interrupted create or download",
+				},
+				cli.BoolFlag{
+					Name:   "dry-run",
+					Hidden: false,
+					Usage:  "Only log broken local backups which would be removed with --broken",
+				},
+			),
 		},
 		{
 			Name:  "clean_remote_broken",
@@ -570,6 +996,43 @@ func main() {
 			},
 			Flags: cliapp.Flags,
 		},
+		{
+			Name:      "benchmark-storage",
+			Usage:     "Measure write, read and delete throughput and latency of configured remote storage, print suggested concurrency and part size",
+			UsageText: "clickhouse-backup benchmark-storage [--size=1GiB] [--object-size=64MiB] [--concurrency=8]",
+			Action: func(c *cli.Context) error {
+				size, err := utils.ParseBytes(c.String("size"))
+				if err != nil {
+					return err
+				}
+				objectSize, err := utils.ParseBytes(c.String("object-size"))
+				if err != nil {
+					return err
+				}
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.BenchmarkStorage(size, objectSize, c.Int("concurrency"), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "size",
+					Value:  "1GiB",
+					Hidden: false,
+					Usage:  "Total data size which will be written, read and deleted, split between all measured concurrency levels",
+				},
+				cli.StringFlag{
+					Name:   "object-size",
+					Value:  "64MiB",
+					Hidden: false,
+					Usage:  "Size of each written object",
+				},
+				cli.IntFlag{
+					Name:   "concurrency",
+					Value:  8,
+					Hidden: false,
+					Usage:  "Maximum concurrency, measured levels are 1, 2, 4 ... up to this value",
+				},
+			),
+		},
 
 		{
 			Name:        "watch",
@@ -577,7 +1040,7 @@ func main() {
 			UsageText:   "clickhouse-backup watch [--watch-interval=1h] [--full-interval=24h] [--watch-backup-name-template=shard{shard}-{type}-{time:20060102150405}] [-t, --tables=<db>.<table>] [--partitions=<partitions_names>] [--schema] [--rbac] [--configs] [--skip-check-parts-columns]",
 			Description: "Execute create_remote + delete local, create full backup every `--full-interval`, create and upload incremental backup every `--watch-interval` use previous backup as base with `--diff-from-remote` option, use `backups_to_keep_remote` config option for properly deletion remote backups, will delete old backups which not have references from other backups",
 			Action: func(c *cli.Context) error {
-				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				b := backup.NewBackuper(config.GetConfigFromCli(c), withTransferObserver(c))
 				return b.Watch(c.String("watch-interval"), c.String("full-interval"), c.String("watch-backup-name-template"), c.String("tables"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("rbac"), c.Bool("configs"), c.Bool("skip-check-parts-columns"), version, c.Int("command-id"), nil, c)
 			},
 			Flags: append(cliapp.Flags,
@@ -609,6 +1072,7 @@ func main() {
 						"If PARTITION BY clause returns hashed string values, then use --partitions=('non_numeric_field_value_for_part1'),('non_numeric_field_value_for_part2') format\n" +
 						"If PARTITION BY clause returns tuple with multiple fields, then use --partitions=(numeric_value1,'string_value1','date_or_datetime_value'),(...) format\n" +
 						"If you need different partitions for different tables, then use --partitions=db.table1:part1,part2 --partitions=db.table?:*\n" +
+						"Use {time:layout} or {time-24h:layout} to select partitions relative to current UTC time in Go layout format, like --partitions={time-24h:20060102} for yesterday partition\n" +
 						"Values depends on field types in your table, use single quotes for String and Date/DateTime related types\n" +
 						"Look at the system.parts partition and partition_id fields for details https://clickhouse.com/docs/en/operations/system-tables/parts/",
 				},
@@ -638,7 +1102,17 @@ func main() {
 			Name:  "server",
 			Usage: "Run API server",
 			Action: func(c *cli.Context) error {
-				return server.Run(c, cliapp, config.GetConfigPath(c), version)
+				configPath := config.GetConfigPath(c)
+				if service.IsService() {
+					s, err := service.NewService(configPath)
+					if err != nil {
+						return err
+					}
+					return s.RunService(func(stop <-chan struct{}) error {
+						return server.Run(c, cliapp, configPath, version, stop)
+					})
+				}
+				return server.Run(c, cliapp, configPath, version, nil)
 			},
 			Flags: append(cliapp.Flags,
 				cli.BoolFlag{
@@ -663,8 +1137,106 @@ func main() {
 				},
 			),
 		},
+		{
+			Name:        "service",
+			Usage:       "Manage API server as systemd unit or Windows service",
+			UsageText:   "clickhouse-backup service <install|uninstall|start|stop>",
+			Description: "Register `clickhouse-backup server` with current executable and --config path in OS service manager, need root or Administrator privileges",
+			Action: func(c *cli.Context) error {
+				action := c.Args().Get(0)
+				if action != "install" && action != "uninstall" && action != "start" && action != "stop" {
+					log.Err(fmt.Errorf("unknown service action '%s'", action)).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				s, err := service.NewService(config.GetConfigPath(c))
+				if err != nil {
+					return err
+				}
+				return s.Run(action)
+			},
+			Flags: cliapp.Flags,
+		},
+	}
+	for i := range cliapp.Commands {
+		cliapp.Commands[i].Action = pushMetricsAfterAction(cliapp.Commands[i].Name, hostLockAction(cliapp.Commands[i].Name, cliapp.Commands[i].Action))
 	}
 	if err := cliapp.Run(os.Args); err != nil {
 		log.Fatal().Err(err).Send()
 	}
 }
+
+// hostLockAction - wrap commands which change backups to hold `general->lock_file`, API server holds the same lock while any command is in progress
+func hostLockAction(command string, action interface{}) interface{} {
+	commandAction, ok := action.(func(*cli.Context) error)
+	if !ok {
+		return action
+	}
+	switch command {
+	case "create", "create_remote", "upload", "download", "restore", "restore_remote", "delete", "rename", "copy", "clean", "clean_remote_broken", "watch":
+	default:
+		return action
+	}
+	return func(c *cli.Context) error {
+		// commands from API are executed inside server process which already holds lock
+		if c.Int("command-id") != status.NotFromAPI {
+			return commandAction(c)
+		}
+		cfg := config.GetConfigFromCli(c)
+		lockFile, err := backup.NewBackuper(cfg).GetLockFile(context.Background())
+		if err != nil {
+			return err
+		}
+		if lockFile == "none" {
+			return commandAction(c)
+		}
+		lock := hostlock.New(lockFile, fmt.Sprintf("pid=%d command=%s", os.Getpid(), command))
+		// watch acquires lock only during each create_remote + delete local iteration
+		if command == "watch" {
+			status.Current.AddSharedLock(lock)
+			return commandAction(c)
+		}
+		if err = lock.Acquire(); err != nil {
+			return err
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				log.Warn().Msgf("can't release lock_file: %v", err)
+			}
+		}()
+		return commandAction(c)
+	}
+}
+
+// withTransferObserver - API server pass own transfer metrics observer for commands executed via cli.App, CLI run doesn't track transfers
+func withTransferObserver(c *cli.Context) backup.BackuperOpt {
+	observer, _ := c.App.Metadata[server.TransferObserverMetadataKey].(storage.TransferObserver)
+	return backup.WithTransferObserver(observer)
+}
+
+// pushMetricsAfterAction - wrap measured commands to push metrics into `api.pushgateway_url` after CLI run, API server has own /metrics
+func pushMetricsAfterAction(command string, action interface{}) interface{} {
+	commandAction, ok := action.(func(*cli.Context) error)
+	if !ok {
+		return action
+	}
+	switch command {
+	case "create", "create_remote", "upload", "download", "restore", "restore_remote", "delete":
+	default:
+		return action
+	}
+	return func(c *cli.Context) error {
+		startTime := time.Now()
+		err := commandAction(c)
+		if c.Int("command-id") != status.NotFromAPI || c.Bool("dry-run") {
+			return err
+		}
+		cfg := config.GetConfigFromCli(c)
+		if cfg.API.PushgatewayURL == "" {
+			return err
+		}
+		if pushErr := metrics.PushCommandMetrics(cfg.API.PushgatewayURL, cfg.API.PushgatewayJob, command, startTime, err); pushErr != nil {
+			log.Warn().Msgf("metrics.PushCommandMetrics return error: %v", pushErr)
+		}
+		return err
+	}
+}
@@ -17,6 +17,7 @@ cmds=(
   clean_remote_broken
   watch
   server
+  service
 )
 for cmd in  ${cmds[@]}; do
   echo "### CLI command - ${cmd}"
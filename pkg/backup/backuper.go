@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
@@ -39,7 +40,24 @@ type Backuper struct {
 	EmbeddedBackupDataPath string
 	isEmbedded             bool
 	resume                 bool
+	deletePinned           bool
+	strictTablePattern     bool
+	replicatedToMergeTree  bool
+	insecureMetadata       bool
+	rebuildProjections     bool
+	restoreDryRun          bool
+	ignoreMissingTables    bool
+	attachOnly             bool
+	includeDetached        bool
+	userTags               map[string]string
+	userTagsFilter         map[string]string
+	customMetadata         map[string]string
+	replicatedDatabases    common.EmptyMap
 	resumableState         *resumable.State
+	transferObserver       storage.TransferObserver
+	replicaName            string // name of `general->replica_configs` target, empty for main remote storage
+	// checkedBackupMetadata - metadata.json of downloaded or restored backup, signature is verified when `general->metadata_signing_key` is set, tables metadata of this backup are checked against its TablesChecksums
+	checkedBackupMetadata *metadata.BackupMetadata
 }
 
 func NewBackuper(cfg *config.Config, opts ...BackuperOpt) *Backuper {
@@ -70,6 +88,215 @@ func WithBackupSharder(s backupSharder) BackuperOpt {
 	}
 }
 
+// WithDeletePinned - allow delete pinned backups, `--force` for `delete` command
+func WithDeletePinned(deletePinned bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.deletePinned = deletePinned
+	}
+}
+
+// WithStrictTablePattern - fail `create` or `restore` when any table pattern matches zero tables, `--strict` for `create`, `create_remote`, `restore` and `restore_remote` commands
+func WithStrictTablePattern(strictTablePattern bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.strictTablePattern = strictTablePattern
+	}
+}
+
+// WithReplicatedToMergeTree - restore Replicated*MergeTree tables as *MergeTree and Replicated databases as Atomic, `--replicated-to-merge-tree` for `restore` and `restore_remote` commands
+func WithReplicatedToMergeTree(replicatedToMergeTree bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.replicatedToMergeTree = replicatedToMergeTree
+	}
+}
+
+// WithRebuildProjections - materialize all table projections after restore data, `--rebuild-projections` for `restore` and `restore_remote` commands
+func WithRebuildProjections(rebuildProjections bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.rebuildProjections = rebuildProjections
+	}
+}
+
+// WithRestoreDryRun - print DDL statements and data parts which restore would apply without executing anything, `--dry-run` for `restore` command
+func WithRestoreDryRun(restoreDryRun bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.restoreDryRun = restoreDryRun
+	}
+}
+
+// WithIgnoreMissingTables - log warning instead of fail when table pattern doesn't match any table in backup, `--ignore-missing` for `restore` and `restore_remote` commands
+func WithIgnoreMissingTables(ignoreMissingTables bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.ignoreMissingTables = ignoreMissingTables
+	}
+}
+
+// WithAttachOnly - restore data into already existing tables without schema changes, table columns are checked before attach, `--attach-only` for `restore` and `restore_remote` commands
+func WithAttachOnly(attachOnly bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.attachOnly = attachOnly
+	}
+}
+
+// WithIncludeDetached - backup and restore `detached` folders of tables, `--include-detached` for `create`, `create_remote`, `restore` and `restore_remote` commands
+func WithIncludeDetached(includeDetached bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.includeDetached = includeDetached
+	}
+}
+
+// WithUserTags - `key=value` pairs stored in backup metadata, `--tag` for `create` and `create_remote` commands
+func WithUserTags(userTags map[string]string) BackuperOpt {
+	return func(b *Backuper) {
+		b.userTags = userTags
+	}
+}
+
+// WithCustomMetadata - free-form `key=value` pairs stored in metadata.json, `--metadata` for `create` and `create_remote` commands
+func WithCustomMetadata(customMetadata map[string]string) BackuperOpt {
+	return func(b *Backuper) {
+		b.customMetadata = customMetadata
+	}
+}
+
+// WithUserTagsFilter - show only backups which contain all `key=value` pairs, `--tag` for `list` command
+func WithUserTagsFilter(userTagsFilter map[string]string) BackuperOpt {
+	return func(b *Backuper) {
+		b.userTagsFilter = userTagsFilter
+	}
+}
+
+// WithInsecureMetadata - allow unsigned or tampered metadata.json when `general->metadata_signing_key` is set, `--insecure` for `download`, `restore` and `restore_remote` commands
+func WithInsecureMetadata(insecureMetadata bool) BackuperOpt {
+	return func(b *Backuper) {
+		b.insecureMetadata = insecureMetadata
+	}
+}
+
+// WithExcludeTables - add `--exclude` patterns to `clickhouse->skip_tables` only for current operation, shared config is not modified
+func WithExcludeTables(excludePatterns []string) BackuperOpt {
+	return func(b *Backuper) {
+		skipTables := ParseExcludeTablePatterns(excludePatterns)
+		if len(skipTables) == 0 {
+			return
+		}
+		cfg := *b.cfg
+		cfg.ClickHouse.SkipTables = append(append(make([]string, 0, len(b.cfg.ClickHouse.SkipTables)+len(skipTables)), b.cfg.ClickHouse.SkipTables...), skipTables...)
+		b.cfg = &cfg
+		b.ch.Config = &cfg.ClickHouse
+	}
+}
+
+// WithRestoreSchemaOnCluster - `--restore-schema-on-cluster` overrides `general->restore_schema_on_cluster` only for current operation
+func WithRestoreSchemaOnCluster(cluster string) BackuperOpt {
+	return func(b *Backuper) {
+		if cluster == "" {
+			return
+		}
+		cfg := *b.cfg
+		cfg.General.RestoreSchemaOnCluster = cluster
+		b.cfg = &cfg
+		b.ch.Config = &cfg.ClickHouse
+	}
+}
+
+// WithTransferObserver - receive bytes uploaded to and downloaded from remote storage, used by API server for transfer metrics
+func WithTransferObserver(observer storage.TransferObserver) BackuperOpt {
+	return func(b *Backuper) {
+		b.transferObserver = observer
+	}
+}
+
+// checkBackupMetadataSignature - when `general->metadata_signing_key` is set, refuse backups with unsigned or tampered metadata.json
+func (b *Backuper) checkBackupMetadataSignature(backupMetadata *metadata.BackupMetadata) error {
+	return b.checkBackupMetadataSignatureWithKey(backupMetadata, b.cfg.General.MetadataSigningKey)
+}
+
+// checkBackupMetadataSignatureWithKey - the same as checkBackupMetadataSignature, for metadata.json from remote storage of another config, like `copy --from`
+func (b *Backuper) checkBackupMetadataSignatureWithKey(backupMetadata *metadata.BackupMetadata, signingKey string) error {
+	if signingKey == "" {
+		return nil
+	}
+	if err := backupMetadata.VerifySignature(signingKey); err != nil {
+		if b.insecureMetadata {
+			log.Warn().Msgf("%v, ignored cause --insecure", err)
+			return nil
+		}
+		return fmt.Errorf("%v, use --insecure to skip signature check", err)
+	}
+	return nil
+}
+
+// checkTableMetadataChecksum - refuse table metadata which doesn't match with checksum from metadata.json, when `general->metadata_signing_key` is set, checksum is required and metadata.json signature is verified before
+func (b *Backuper) checkTableMetadataChecksum(backupName string, tableMetadata *metadata.TableMetadata) error {
+	if b.checkedBackupMetadata == nil || b.checkedBackupMetadata.BackupName != backupName || b.isEmbedded {
+		return nil
+	}
+	// local backups without signing key and remote backups uploaded by previous versions don't contain tables checksums
+	if b.cfg.General.MetadataSigningKey == "" && len(b.checkedBackupMetadata.TablesChecksums) == 0 {
+		return nil
+	}
+	if err := b.checkedBackupMetadata.VerifyTableMetadata(tableMetadata); err != nil {
+		if b.insecureMetadata {
+			log.Warn().Msgf("%v, ignored cause --insecure", err)
+			return nil
+		}
+		return fmt.Errorf("%v, use --insecure to skip signature check", err)
+	}
+	return nil
+}
+
+// checkBackupMetadataSignatureBeforeChange - metadata.json shall not be signed again after pin, rename, or other change, when current signature is invalid
+func (b *Backuper) checkBackupMetadataSignatureBeforeChange(backupMetadata *metadata.BackupMetadata) error {
+	if b.cfg.General.MetadataSigningKey == "" {
+		return nil
+	}
+	if err := backupMetadata.VerifySignature(b.cfg.General.MetadataSigningKey); err != nil {
+		return fmt.Errorf("%v, can't sign changed metadata", err)
+	}
+	return nil
+}
+
+// setTablesChecksumsLocal - read tables metadata from local backup and store their checksums in metadata.json before Sign, tables which are not present locally, like after partial download, are skipped
+func (b *Backuper) setTablesChecksumsLocal(backupMetadata *metadata.BackupMetadata, backupPath string) error {
+	backupMetadata.TablesChecksums = nil
+	if b.cfg.General.MetadataSigningKey == "" || strings.Contains(backupMetadata.Tags, "embedded") {
+		return nil
+	}
+	tables := make([]metadata.TableMetadata, 0, len(backupMetadata.Tables))
+	for _, table := range backupMetadata.Tables {
+		tableMetadata := metadata.TableMetadata{}
+		tableMetadataFile := path.Join(backupPath, "metadata", common.TablePathEncode(table.Database), common.TablePathEncode(table.Table)+".json")
+		if _, err := tableMetadata.Load(tableMetadataFile); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		tables = append(tables, tableMetadata)
+	}
+	return backupMetadata.SetTablesChecksums(tables)
+}
+
+// GetLockFile - `general->lock_file`, empty value means `clickhouse-backup.lock` in backup directory of default disk, `none` means lock is disabled
+func (b *Backuper) GetLockFile(ctx context.Context) (string, error) {
+	if b.cfg.General.LockFile != "" {
+		return b.cfg.General.LockFile, nil
+	}
+	if err := b.ch.Connect(); err != nil {
+		return "", fmt.Errorf("can't connect to clickhouse to get default lock_file: %v", err)
+	}
+	defer b.ch.Close()
+	disks, err := b.ch.GetDisks(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	defaultDataPath, err := b.ch.GetDefaultPath(disks)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(defaultDataPath, "backup", "clickhouse-backup.lock"), nil
+}
+
 func (b *Backuper) initDisksPathsAndBackupDestination(ctx context.Context, disks []clickhouse.Disk, backupName string) error {
 	var err error
 	if disks == nil {
@@ -101,6 +328,7 @@ func (b *Backuper) initDisksPathsAndBackupDestination(ctx context.Context, disks
 		if err != nil {
 			return err
 		}
+		b.dst.SetTransferObserver(b.transferObserver)
 		if err := b.dst.Connect(ctx); err != nil {
 			return fmt.Errorf("can't connect to %s: %v", b.dst.Kind(), err)
 		}
@@ -110,12 +338,16 @@ func (b *Backuper) initDisksPathsAndBackupDestination(ctx context.Context, disks
 
 // CalculateMaxSize https://github.com/Altinity/clickhouse-backup/issues/404
 func (b *Backuper) CalculateMaxSize(ctx context.Context) error {
+	// archives split by files size, so configured max_file_size is a hard limit for each uploaded object
+	if b.cfg.General.MaxFileSize > 0 && !b.cfg.General.UploadByPart {
+		return nil
+	}
 	maxFileSize, err := b.ch.CalculateMaxFileSize(ctx, b.cfg)
 	if err != nil {
 		return err
 	}
 	if b.cfg.General.MaxFileSize > 0 && b.cfg.General.MaxFileSize < maxFileSize {
-		log.Warn().Msgf("MAX_FILE_SIZE=%d is less than actual %d, please remove general->max_file_size section from your config", b.cfg.General.MaxFileSize, maxFileSize)
+		log.Warn().Msgf("MAX_FILE_SIZE=%d is less than actual %d, one data part can't be split into different archives with upload_by_part: true, use upload_by_part: false to keep each uploaded object under max_file_size", b.cfg.General.MaxFileSize, maxFileSize)
 	}
 	if b.cfg.General.MaxFileSize <= 0 || b.cfg.General.MaxFileSize < maxFileSize {
 		b.cfg.General.MaxFileSize = maxFileSize
@@ -138,6 +370,8 @@ func (b *Backuper) populateBackupShardField(ctx context.Context, tables []clickh
 		tables[i].BackupType = clickhouse.ShardBackupFull
 		if tables[i].Skip {
 			tables[i].BackupType = clickhouse.ShardBackupNone
+		} else if tables[i].SkipData {
+			tables[i].BackupType = clickhouse.ShardBackupSchema
 		}
 	}
 	if !doesShard(b.cfg.General.ShardedOperationMode) {
@@ -195,6 +429,17 @@ func (b *Backuper) isDiskTypeEncryptedObject(disk clickhouse.Disk, disks []click
 	return underlyingIdx >= 0
 }
 
+// minEmbeddedBackupVersion - BACKUP / RESTORE SQL statements with Disk() and S3() destinations are production ready since 22.7
+const minEmbeddedBackupVersion = 22007000
+
+// checkEmbeddedBackupVersion - fail early with clear error instead of SQL syntax error from old clickhouse-server when `use_embedded_backup_restore: true`
+func (b *Backuper) checkEmbeddedBackupVersion(version int) error {
+	if b.cfg.ClickHouse.UseEmbeddedBackupRestore && version < minEmbeddedBackupVersion {
+		return fmt.Errorf("`use_embedded_backup_restore: true` requires clickhouse-server 22.7+, current version %d", version)
+	}
+	return nil
+}
+
 // getEmbeddedRestoreSettings - different with getEmbeddedBackupSettings, cause https://github.com/ClickHouse/ClickHouse/issues/69053
 func (b *Backuper) getEmbeddedRestoreSettings(version int) []string {
 	settings := []string{}
@@ -292,6 +537,8 @@ func (b *Backuper) applyMacrosToObjectDiskPath(ctx context.Context) error {
 		b.cfg.FTP.ObjectDiskPath, err = b.ch.ApplyMacros(ctx, b.cfg.FTP.ObjectDiskPath)
 	} else if b.cfg.General.RemoteStorage == "sftp" {
 		b.cfg.SFTP.ObjectDiskPath, err = b.ch.ApplyMacros(ctx, b.cfg.SFTP.ObjectDiskPath)
+	} else if b.cfg.General.RemoteStorage == "local" {
+		b.cfg.Local.ObjectDiskPath, err = b.ch.ApplyMacros(ctx, b.cfg.Local.ObjectDiskPath)
 	} else if b.cfg.General.RemoteStorage == "cos" {
 		b.cfg.COS.ObjectDiskPath, err = b.ch.ApplyMacros(ctx, b.cfg.COS.ObjectDiskPath)
 	}
@@ -370,6 +617,8 @@ func (b *Backuper) getObjectDiskPath() (string, error) {
 		return b.cfg.FTP.ObjectDiskPath, nil
 	} else if b.cfg.General.RemoteStorage == "sftp" {
 		return b.cfg.SFTP.ObjectDiskPath, nil
+	} else if b.cfg.General.RemoteStorage == "local" {
+		return b.cfg.Local.ObjectDiskPath, nil
 	} else {
 		return "", fmt.Errorf("cleanBackupObjectDisks: requesst object disks path but have unsupported remote_storage: %s", b.cfg.General.RemoteStorage)
 	}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 )
 
 type testVersioner struct {
@@ -183,3 +184,64 @@ func TestPopulateBackupShardField(t *testing.T) {
 		)
 	}
 }
+
+func TestPopulateBackupShardFieldSkipData(t *testing.T) {
+	cfg := config.DefaultConfig()
+	b := NewBackuper(cfg)
+	tables := []clickhouse.Table{
+		{Database: "a", Name: "data", Engine: "MergeTree"},
+		{Database: "a", Name: "queue", Engine: "Kafka", SkipData: true},
+		{Database: "a", Name: "skipped", Engine: "Memory", Skip: true},
+	}
+	if err := b.populateBackupShardField(context.Background(), tables); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []clickhouse.ShardBackupType{clickhouse.ShardBackupFull, clickhouse.ShardBackupSchema, clickhouse.ShardBackupNone}
+	for i, table := range tables {
+		if table.BackupType != expected[i] {
+			t.Fatalf("expected %s for %s.%s, got %s", expected[i], table.Database, table.Name, table.BackupType)
+		}
+	}
+	kafkaTable := metadata.TableMetadata{Database: "a", Table: "queue", Query: "CREATE TABLE a.queue (`id` UInt64) ENGINE = Kafka"}
+	if b.shouldSkipByTableEngine(kafkaTable) {
+		t.Fatalf("skip_table_engines_schema_only shall keep schema for %s", kafkaTable.Query)
+	}
+	b.cfg.ClickHouse.SkipTableEngines = []string{"Kafka"}
+	if !b.shouldSkipByTableEngine(kafkaTable) {
+		t.Fatalf("skip_table_engines shall skip %s", kafkaTable.Query)
+	}
+}
+
+func TestBackuperOptsDoNotModifySharedConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.General.RestoreSchemaOnCluster = "default"
+	b := NewBackuper(cfg, WithExcludeTables([]string{"logs.*,tmp_*"}), WithRestoreSchemaOnCluster("{cluster}"))
+	if b.cfg.General.RestoreSchemaOnCluster != "{cluster}" || cfg.General.RestoreSchemaOnCluster != "default" {
+		t.Fatalf("unexpected restore_schema_on_cluster backuper=%s shared=%s", b.cfg.General.RestoreSchemaOnCluster, cfg.General.RestoreSchemaOnCluster)
+	}
+	expectedSkipTables := append(append([]string{}, cfg.ClickHouse.SkipTables...), "logs.*", "*.tmp_*")
+	if !reflect.DeepEqual(b.cfg.ClickHouse.SkipTables, expectedSkipTables) || b.ch.Config != &b.cfg.ClickHouse {
+		t.Fatalf("unexpected skip_tables %v", b.cfg.ClickHouse.SkipTables)
+	}
+	if len(cfg.ClickHouse.SkipTables) == len(b.cfg.ClickHouse.SkipTables) {
+		t.Fatalf("shared skip_tables shall not be modified, got %v", cfg.ClickHouse.SkipTables)
+	}
+	if b = NewBackuper(cfg, WithRestoreSchemaOnCluster("")); b.cfg != cfg {
+		t.Fatalf("empty --restore-schema-on-cluster shall keep shared config")
+	}
+}
+
+func TestCheckEmbeddedBackupVersion(t *testing.T) {
+	cfg := config.DefaultConfig()
+	b := NewBackuper(cfg)
+	if err := b.checkEmbeddedBackupVersion(21008000); err != nil {
+		t.Fatalf("unexpected error without use_embedded_backup_restore: %v", err)
+	}
+	b.cfg.ClickHouse.UseEmbeddedBackupRestore = true
+	if err := b.checkEmbeddedBackupVersion(22003000); err == nil {
+		t.Fatalf("expected error for clickhouse-server 22.3")
+	}
+	if err := b.checkEmbeddedBackupVersion(22007000); err != nil {
+		t.Fatalf("unexpected error for clickhouse-server 22.7: %v", err)
+	}
+}
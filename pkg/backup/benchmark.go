@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// benchmarkStep - measured throughput and per object latency of one operation with one concurrency level
+type benchmarkStep struct {
+	Operation   string
+	Concurrency int
+	Bytes       uint64
+	Duration    time.Duration
+	Latencies   []time.Duration
+}
+
+func (s benchmarkStep) throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Duration.Seconds()
+}
+
+func (s benchmarkStep) percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	latencies := make([]time.Duration, len(s.Latencies))
+	copy(latencies, s.Latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	idx := int(p*float64(len(latencies))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(latencies) {
+		idx = len(latencies) - 1
+	}
+	return latencies[idx]
+}
+
+// BenchmarkStorage - write, read and delete `size` bytes split into objects of `objectSize` on configured remote storage with concurrency 1, 2, 4 ... maxConcurrency, print measured results and suggested config values
+func (b *Backuper) BenchmarkStorage(size, objectSize uint64, maxConcurrency int, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+		return fmt.Errorf("benchmark-storage is not supported for remote_storage: %s", b.cfg.General.RemoteStorage)
+	}
+	if size == 0 || objectSize == 0 || maxConcurrency < 1 {
+		return fmt.Errorf("--size, --object-size and --concurrency shall be more than zero")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+
+	bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, "")
+	if err != nil {
+		return err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return fmt.Errorf("can't connect to remote storage: %v", err)
+	}
+	defer func() {
+		if err := bd.Close(ctx); err != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", err)
+		}
+	}()
+
+	levels := getBenchmarkConcurrencyLevels(maxConcurrency)
+	prefix := fmt.Sprintf("benchmark-storage-%s", time.Now().UTC().Format("20060102150405"))
+	results := make([]benchmarkStep, 0, len(levels)*3)
+	for _, concurrency := range levels {
+		objects := int((size/uint64(len(levels)) + objectSize - 1) / objectSize)
+		if objects < concurrency {
+			objects = concurrency
+		}
+		keys := make([]string, objects)
+		for i := range keys {
+			keys[i] = path.Join(prefix, fmt.Sprintf("concurrency_%d", concurrency), fmt.Sprintf("object_%d", i))
+		}
+		log.Info().Msgf("benchmark-storage: write, read and delete %d objects of %s with concurrency=%d", objects, utils.FormatBytes(objectSize), concurrency)
+
+		writeStep, err := runBenchmarkStep(ctx, "write", concurrency, keys, func(ctx context.Context, key string, i int) (uint64, error) {
+			data := io.LimitReader(rand.New(rand.NewSource(int64(i))), int64(objectSize))
+			return objectSize, bd.PutFile(ctx, key, io.NopCloser(data))
+		})
+		if err != nil {
+			b.cleanBenchmarkObjects(bd, keys)
+			return err
+		}
+		readStep, err := runBenchmarkStep(ctx, "read", concurrency, keys, func(ctx context.Context, key string, i int) (uint64, error) {
+			reader, err := bd.GetFileReader(ctx, key)
+			if err != nil {
+				return 0, err
+			}
+			n, err := io.Copy(io.Discard, reader)
+			if closeErr := reader.Close(); err == nil {
+				err = closeErr
+			}
+			return uint64(n), err
+		})
+		if err != nil {
+			b.cleanBenchmarkObjects(bd, keys)
+			return err
+		}
+		deleteStep, err := runBenchmarkStep(ctx, "delete", concurrency, keys, func(ctx context.Context, key string, i int) (uint64, error) {
+			return 0, bd.DeleteFile(ctx, key)
+		})
+		if err != nil {
+			b.cleanBenchmarkObjects(bd, keys)
+			return err
+		}
+		results = append(results, writeStep, readStep, deleteStep)
+	}
+	return printBenchmarkResults(os.Stdout, b.cfg.General.RemoteStorage, results)
+}
+
+func getBenchmarkConcurrencyLevels(maxConcurrency int) []int {
+	levels := make([]int, 0)
+	for concurrency := 1; concurrency < maxConcurrency; concurrency *= 2 {
+		levels = append(levels, concurrency)
+	}
+	return append(levels, maxConcurrency)
+}
+
+func runBenchmarkStep(ctx context.Context, operation string, concurrency int, keys []string, fn func(ctx context.Context, key string, i int) (uint64, error)) (benchmarkStep, error) {
+	step := benchmarkStep{Operation: operation, Concurrency: concurrency, Latencies: make([]time.Duration, len(keys))}
+	var bytesMutex sync.Mutex
+	start := time.Now()
+	stepGroup, stepCtx := errgroup.WithContext(ctx)
+	stepGroup.SetLimit(concurrency)
+	for i, key := range keys {
+		stepGroup.Go(func() error {
+			objectStart := time.Now()
+			n, err := fn(stepCtx, key, i)
+			if err != nil {
+				return fmt.Errorf("benchmark-storage %s %s error: %v", operation, key, err)
+			}
+			step.Latencies[i] = time.Since(objectStart)
+			bytesMutex.Lock()
+			step.Bytes += n
+			bytesMutex.Unlock()
+			return nil
+		})
+	}
+	err := stepGroup.Wait()
+	step.Duration = time.Since(start)
+	return step, err
+}
+
+// cleanBenchmarkObjects - best effort cleanup after failed step, use separate context cause main context could be canceled
+func (b *Backuper) cleanBenchmarkObjects(bd *storage.BackupDestination, keys []string) {
+	for _, key := range keys {
+		if err := bd.DeleteFile(context.Background(), key); err != nil {
+			log.Debug().Msgf("benchmark-storage can't delete %s: %v", key, err)
+		}
+	}
+}
+
+// getBenchmarkSuggestions - smallest concurrency which achieves 90% of the best throughput, part size which keeps per request overhead, measured as delete p50 latency, under 5% of part upload time
+func getBenchmarkSuggestions(results []benchmarkStep) (uploadConcurrency, downloadConcurrency int, partSize uint64) {
+	bestConcurrency := func(operation string) (int, float64) {
+		maxThroughput := 0.0
+		for _, step := range results {
+			if step.Operation == operation && step.throughput() > maxThroughput {
+				maxThroughput = step.throughput()
+			}
+		}
+		for _, step := range results {
+			if step.Operation == operation && step.throughput() >= 0.9*maxThroughput {
+				return step.Concurrency, step.throughput()
+			}
+		}
+		return 1, 0
+	}
+	uploadConcurrency, uploadThroughput := bestConcurrency("write")
+	downloadConcurrency, _ = bestConcurrency("read")
+
+	requestLatency := time.Duration(0)
+	for _, step := range results {
+		if step.Operation == "delete" && step.Concurrency == uploadConcurrency {
+			requestLatency = step.percentile(0.5)
+		}
+	}
+	perStreamThroughput := uploadThroughput / float64(uploadConcurrency)
+	targetSize := uint64(perStreamThroughput * requestLatency.Seconds() * 20)
+	const minPartSize, maxPartSize = 5 * 1024 * 1024, 5 * 1024 * 1024 * 1024
+	partSize = minPartSize
+	for partSize < targetSize && partSize < maxPartSize {
+		partSize *= 2
+	}
+	return uploadConcurrency, downloadConcurrency, partSize
+}
+
+func printBenchmarkResults(out io.Writer, remoteStorage string, results []benchmarkStep) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', tabwriter.DiscardEmptyColumns)
+	if _, err := fmt.Fprintln(w, "operation\tconcurrency\tobjects\tbytes\tthroughput\tp50\tp95\tmax\t"); err != nil {
+		return err
+	}
+	for _, step := range results {
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s/s\t%s\t%s\t%s\t\n", step.Operation, step.Concurrency, len(step.Latencies), utils.FormatBytes(step.Bytes), utils.FormatBytes(uint64(step.throughput())), utils.HumanizeDuration(step.percentile(0.5)), utils.HumanizeDuration(step.percentile(0.95)), utils.HumanizeDuration(step.percentile(1))); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	uploadConcurrency, downloadConcurrency, partSize := getBenchmarkSuggestions(results)
+	suggestion := fmt.Sprintf("\nsuggested config:\ngeneral:\n  upload_concurrency: %d\n  download_concurrency: %d\n", uploadConcurrency, downloadConcurrency)
+	switch remoteStorage {
+	case "s3":
+		suggestion += fmt.Sprintf("s3:\n  part_size: %d\n", partSize)
+	case "gcs":
+		suggestion += fmt.Sprintf("gcs:\n  chunk_size: %d\n", partSize)
+	case "azblob":
+		suggestion += fmt.Sprintf("azblob:\n  buffer_size: %d\n", partSize)
+	}
+	_, err := fmt.Fprint(out, suggestion)
+	return err
+}
@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetBenchmarkConcurrencyLevels(t *testing.T) {
+	if levels := getBenchmarkConcurrencyLevels(8); !reflect.DeepEqual(levels, []int{1, 2, 4, 8}) {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+	if levels := getBenchmarkConcurrencyLevels(6); !reflect.DeepEqual(levels, []int{1, 2, 4, 6}) {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+	if levels := getBenchmarkConcurrencyLevels(1); !reflect.DeepEqual(levels, []int{1}) {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+}
+
+func TestGetBenchmarkSuggestions(t *testing.T) {
+	step := func(operation string, concurrency int, bytes uint64, duration time.Duration, latency time.Duration) benchmarkStep {
+		return benchmarkStep{Operation: operation, Concurrency: concurrency, Bytes: bytes, Duration: duration, Latencies: []time.Duration{latency, latency, latency}}
+	}
+	const mib = 1024 * 1024
+	results := []benchmarkStep{
+		step("write", 1, 100*mib, 10*time.Second, time.Second),
+		step("read", 1, 100*mib, 5*time.Second, time.Second),
+		step("delete", 1, 0, time.Second, 50*time.Millisecond),
+		step("write", 2, 100*mib, 5*time.Second, time.Second),
+		step("read", 2, 100*mib, 5*time.Second, time.Second),
+		step("delete", 2, 0, time.Second, 100*time.Millisecond),
+		step("write", 4, 100*mib, 4800*time.Millisecond, time.Second),
+		step("read", 4, 100*mib, 5*time.Second, time.Second),
+		step("delete", 4, 0, time.Second, 200*time.Millisecond),
+	}
+	uploadConcurrency, downloadConcurrency, partSize := getBenchmarkSuggestions(results)
+	// 20MiB/s at concurrency=2 is within 90% of best, 10MiB/s per stream * 100ms * 20 = 20MiB, part size doubles from 5MiB minimum
+	if uploadConcurrency != 2 || downloadConcurrency != 1 || partSize != 20*mib {
+		t.Fatalf("unexpected suggestions upload_concurrency=%d download_concurrency=%d part_size=%d", uploadConcurrency, downloadConcurrency, partSize)
+	}
+	if p := results[0].percentile(0.95); p != time.Second {
+		t.Fatalf("unexpected percentile %s", p)
+	}
+}
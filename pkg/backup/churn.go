@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/rs/zerolog/log"
+)
+
+// calculateBackupChurn - compare parts of the new backup with diff base, `--diff-from-remote` backup when defined, otherwise the latest previous local backup, return nil when base backup not exists
+func (b *Backuper) calculateBackupChurn(ctx context.Context, backupName, diffFromRemote string, tablesDiffFromRemote map[metadata.TableTitle]metadata.TableMetadata, tablePattern string, tables []clickhouse.Table, disks []clickhouse.Disk, tablesParts map[metadata.TableTitle]map[string][]metadata.Part) *metadata.ChurnMetadata {
+	previousTablesParts := make(map[metadata.TableTitle]map[string][]metadata.Part)
+	if diffFromRemote != "" {
+		// already filtered by tablePattern
+		for tableTitle, previousTable := range tablesDiffFromRemote {
+			previousTablesParts[tableTitle] = previousTable.Parts
+		}
+		churn := &metadata.ChurnMetadata{PreviousBackup: diffFromRemote}
+		calculateTablesChurn(churn, tablesParts, previousTablesParts, tables)
+		return churn
+	}
+	backupList, _, err := b.GetLocalBackups(ctx, disks)
+	if err != nil {
+		log.Warn().Msgf("can't calculate churn for %s, GetLocalBackups error: %v", backupName, err)
+		return nil
+	}
+	var previousBackup *LocalBackup
+	for i := range backupList {
+		backup := backupList[i]
+		if backup.BackupName == backupName || backup.Broken != "" || strings.Contains(backup.Tags, "embedded") {
+			continue
+		}
+		if previousBackup == nil || backup.CreationDate.After(previousBackup.CreationDate) {
+			previousBackup = &backup
+		}
+	}
+	if previousBackup == nil {
+		return nil
+	}
+	churn := &metadata.ChurnMetadata{PreviousBackup: previousBackup.BackupName}
+	previousMetadataPath := path.Join(b.DefaultDataPath, "backup", previousBackup.BackupName, "metadata")
+	for _, tableTitle := range parseTablePatternForDownload(previousBackup.Tables, tablePattern) {
+		previousTable := metadata.TableMetadata{}
+		metadataFile := path.Join(previousMetadataPath, common.TablePathEncode(tableTitle.Database), fmt.Sprintf("%s.json", common.TablePathEncode(tableTitle.Table)))
+		if _, err = previousTable.Load(metadataFile); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			log.Warn().Msgf("can't calculate churn for %s, can't load %s: %v", backupName, metadataFile, err)
+			return nil
+		}
+		previousTablesParts[tableTitle] = previousTable.Parts
+	}
+	calculateTablesChurn(churn, tablesParts, previousTablesParts, tables)
+	return churn
+}
+
+// calculateTablesChurn - all parts of tables which exist in base backup but not exist in clickhouse anymore are removed, tables which still exist but without data in current backup are ignored
+func calculateTablesChurn(churn *metadata.ChurnMetadata, currentTablesParts, previousTablesParts map[metadata.TableTitle]map[string][]metadata.Part, tables []clickhouse.Table) {
+	existsTables := make(map[metadata.TableTitle]struct{}, len(tables))
+	for _, table := range tables {
+		existsTables[metadata.TableTitle{Database: table.Database, Table: table.Name}] = struct{}{}
+	}
+	for tableTitle, currentParts := range currentTablesParts {
+		calculateTablePartsChurn(churn, currentParts, previousTablesParts[tableTitle])
+	}
+	for tableTitle, previousParts := range previousTablesParts {
+		if _, exists := currentTablesParts[tableTitle]; exists {
+			continue
+		}
+		if _, exists := existsTables[tableTitle]; !exists {
+			calculateTablePartsChurn(churn, nil, previousParts)
+		}
+	}
+}
+
+// calculateTablePartsChurn - parts which exist only in current backup are new, only in previous are removed (merged or dropped), parts with the same block range and level but different mutation version are changed
+func calculateTablePartsChurn(churn *metadata.ChurnMetadata, currentParts, previousParts map[string][]metadata.Part) {
+	previousByName := make(map[string]metadata.Part)
+	previousByBase := make(map[string]string)
+	for _, parts := range previousParts {
+		for _, part := range parts {
+			previousByName[part.Name] = part
+			previousByBase[partNameWithoutMutation(part.Name)] = part.Name
+		}
+	}
+	for _, parts := range currentParts {
+		for _, part := range parts {
+			if _, exists := previousByName[part.Name]; exists {
+				delete(previousByName, part.Name)
+				continue
+			}
+			if previousName, isMutated := previousByBase[partNameWithoutMutation(part.Name)]; isMutated {
+				if _, notProcessed := previousByName[previousName]; notProcessed {
+					delete(previousByName, previousName)
+					churn.ChangedParts += 1
+					churn.ChangedBytes += uint64(part.Size)
+					continue
+				}
+			}
+			churn.NewParts += 1
+			churn.NewBytes += uint64(part.Size)
+		}
+	}
+	for _, part := range previousByName {
+		churn.RemovedParts += 1
+		churn.RemovedBytes += uint64(part.Size)
+	}
+}
+
+// partNameWithoutMutation - `partition_min_max_level_mutation` -> `partition_min_max_level`
+func partNameWithoutMutation(partName string) string {
+	if fields := strings.Split(partName, "_"); len(fields) == 5 {
+		return strings.Join(fields[:4], "_")
+	}
+	return partName
+}
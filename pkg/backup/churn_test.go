@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateTablePartsChurn(t *testing.T) {
+	previousParts := map[string][]metadata.Part{
+		"default": {
+			{Name: "all_1_1_0", Size: 100},
+			{Name: "all_2_2_0", Size: 200},
+			{Name: "all_3_3_0", Size: 300},
+			{Name: "all_4_4_0_5", Size: 400},
+		},
+	}
+	currentParts := map[string][]metadata.Part{
+		"default": {
+			{Name: "all_1_1_0", Size: 100},
+			{Name: "all_4_4_0_6", Size: 410},
+		},
+		"hdd": {
+			{Name: "all_2_3_1", Size: 490},
+			{Name: "all_7_7_0", Size: 70},
+		},
+	}
+	churn := &metadata.ChurnMetadata{}
+	calculateTablePartsChurn(churn, currentParts, previousParts)
+	assert.Equal(t, metadata.ChurnMetadata{
+		NewParts:     2,
+		NewBytes:     560,
+		ChangedParts: 1,
+		ChangedBytes: 410,
+		RemovedParts: 2,
+		RemovedBytes: 500,
+	}, *churn)
+
+	churn = &metadata.ChurnMetadata{}
+	calculateTablePartsChurn(churn, currentParts, nil)
+	assert.Equal(t, uint64(4), churn.NewParts)
+	assert.Equal(t, uint64(0), churn.RemovedParts)
+}
+
+func TestCalculateTablesChurn(t *testing.T) {
+	previousTablesParts := map[metadata.TableTitle]map[string][]metadata.Part{
+		{Database: "default", Table: "t1"}:      {"default": {{Name: "all_1_1_0", Size: 100}}},
+		{Database: "default", Table: "dropped"}: {"default": {{Name: "all_1_1_0", Size: 300}, {Name: "all_2_2_0", Size: 400}}},
+		{Database: "default", Table: "no_data"}: {"default": {{Name: "all_1_1_0", Size: 500}}},
+	}
+	currentTablesParts := map[metadata.TableTitle]map[string][]metadata.Part{
+		{Database: "default", Table: "t1"}:  {"default": {{Name: "all_1_1_0", Size: 100}, {Name: "all_2_2_0", Size: 20}}},
+		{Database: "default", Table: "new"}: {"default": {{Name: "all_1_1_0", Size: 30}}},
+	}
+	tables := []clickhouse.Table{
+		{Database: "default", Name: "t1"},
+		{Database: "default", Name: "new"},
+		{Database: "default", Name: "no_data"},
+	}
+	churn := &metadata.ChurnMetadata{}
+	calculateTablesChurn(churn, currentTablesParts, previousTablesParts, tables)
+	// parts of dropped table are removed, table which still exists but without data in current backup is ignored
+	assert.Equal(t, metadata.ChurnMetadata{
+		NewParts:     2,
+		NewBytes:     50,
+		RemovedParts: 2,
+		RemovedBytes: 700,
+	}, *churn)
+}
@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// mainRemoteName - `copy --from main`, remote storage from `general->remote_storage` of current config
+const mainRemoteName = "main"
+
+// Copy - transfer backup between remote storages without unpacking, server-side copy is used when both remote storages have the same type and support it, otherwise each file is streamed through clickhouse-backup
+func (b *Backuper) Copy(backupName, from, to string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("select backup for copy")
+	}
+	if from == "" || to == "" {
+		return fmt.Errorf("`--from` and `--to` shall be defined")
+	}
+	if from == to {
+		return fmt.Errorf("`--from` and `--to` shall be different")
+	}
+	// both configs are resolved before NewBackupDestination, which applies macros to main config
+	srcCfg, err := b.getRemoteConfig(from)
+	if err != nil {
+		return err
+	}
+	dstCfg, err := b.getRemoteConfig(to)
+	if err != nil {
+		return err
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+
+	srcBd, err := b.connectRemoteForCopy(ctx, from, srcCfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := srcBd.Close(ctx); err != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", err)
+		}
+	}()
+	dstBd, err := b.connectRemoteForCopy(ctx, to, dstCfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := dstBd.Close(ctx); err != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", err)
+		}
+	}()
+
+	srcBackups, err := srcBd.BackupList(ctx, true, backupName)
+	if err != nil {
+		return err
+	}
+	var backupToCopy *storage.Backup
+	for i := range srcBackups {
+		if srcBackups[i].BackupName == backupName {
+			backupToCopy = &srcBackups[i]
+		}
+	}
+	if backupToCopy == nil {
+		return fmt.Errorf("'%s' is not found on %s", backupName, from)
+	}
+	if backupToCopy.Broken != "" {
+		return fmt.Errorf("'%s' is broken on %s: %s", backupName, from, backupToCopy.Broken)
+	}
+	if strings.Contains(backupToCopy.Tags, "embedded") {
+		return fmt.Errorf("copy is not supported for embedded backups")
+	}
+	if err = b.checkBackupMetadataSignatureWithKey(&backupToCopy.BackupMetadata, srcCfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
+	if b.hasObjectDisksRemote(*backupToCopy) {
+		return fmt.Errorf("copy is not supported for backups with object disks, data is stored in object_disk_path of source remote storage")
+	}
+	dstBackups, err := dstBd.BackupList(ctx, false, "")
+	if err != nil {
+		return err
+	}
+	requiredBackupExists := backupToCopy.RequiredBackup == ""
+	for _, dstBackup := range dstBackups {
+		if dstBackup.BackupName == backupName {
+			return fmt.Errorf("'%s' already exists on %s", backupName, to)
+		}
+		if dstBackup.BackupName == backupToCopy.RequiredBackup && dstBackup.Broken == "" {
+			requiredBackupExists = true
+		}
+	}
+	if !requiredBackupExists {
+		return fmt.Errorf("'%s' requires '%s' which is not found on %s, copy it first", backupName, backupToCopy.RequiredBackup, to)
+	}
+
+	copier := newRemoteObjectCopier(srcBd, dstBd, srcCfg, dstCfg)
+	copyGroup, copyCtx := errgroup.WithContext(ctx)
+	copyGroup.SetLimit(max(int(dstCfg.General.UploadConcurrency), 1))
+	walkErr := srcBd.Walk(ctx, backupName+"/", true, func(ctx context.Context, f storage.RemoteFile) error {
+		// azblob returns virtual directories, the same as in RemoveBackupRemote
+		if f.Name() == "metadata.json" || (srcBd.Kind() == "azblob" && f.Size() == 0 && f.LastModified().IsZero()) {
+			return nil
+		}
+		key := path.Join(backupName, f.Name())
+		size := f.Size()
+		copyGroup.Go(func() error {
+			retry := storage.NewRetrier(&dstCfg.General)
+			return retry.RunCtx(copyCtx, func(ctx context.Context) error {
+				return copier.copy(ctx, size, key, key)
+			})
+		})
+		return nil
+	})
+	if wgWaitErr := copyGroup.Wait(); wgWaitErr != nil {
+		b.removePartialCopy(ctx, dstBd, dstCfg, backupName, to)
+		return fmt.Errorf("can't copy '%s' from %s to %s: %v", backupName, from, to, wgWaitErr)
+	}
+	if walkErr != nil {
+		b.removePartialCopy(ctx, dstBd, dstCfg, backupName, to)
+		return fmt.Errorf("can't walk '%s' on %s: %v", backupName, from, walkErr)
+	}
+	// metadata.json is written last, so backup is not visible on destination until all files are copied
+	dstBackupMetadata := backupToCopy.BackupMetadata
+	dstBackupMetadata.Replicas = nil
+	if err = dstBackupMetadata.Sign(dstCfg.General.MetadataSigningKey); err != nil {
+		b.removePartialCopy(ctx, dstBd, dstCfg, backupName, to)
+		return err
+	}
+	if err = dstBd.PutBackupMetadata(ctx, dstBackupMetadata); err != nil {
+		b.removePartialCopy(ctx, dstBd, dstCfg, backupName, to)
+		return err
+	}
+	log.Info().Str("operation", "copy").
+		Str("backup", backupName).
+		Str("from", from).
+		Str("to", to).
+		Bool("server_side_copy", copier.useServerSideCopy.Load()).
+		Str("size", utils.FormatBytes(uint64(atomic.LoadInt64(&copier.copiedSize)))).
+		Str("duration", utils.HumanizeDuration(time.Since(start))).
+		Msg("done")
+	return nil
+}
+
+// removePartialCopy - remove files which already copied to destination when copy failed, backup without metadata.json is shown as broken otherwise
+func (b *Backuper) removePartialCopy(ctx context.Context, dstBd *storage.BackupDestination, dstCfg *config.Config, backupName, to string) {
+	// copy could fail cause context canceled, cleanup shall run anyway
+	cleanCtx := context.WithoutCancel(ctx)
+	partialBackup := storage.Backup{BackupMetadata: metadata.BackupMetadata{BackupName: backupName}}
+	if err := dstBd.RemoveBackupRemote(cleanCtx, partialBackup, dstCfg); err != nil {
+		log.Warn().Msgf("can't remove partially copied '%s' from %s: %v", backupName, to, err)
+	}
+}
+
+// CheckCopyRemoteName - only `main` and names of `general->replica_configs` are allowed, paths to config files are accepted only from CLI
+func CheckCopyRemoteName(cfg *config.Config, name string) error {
+	if name == mainRemoteName {
+		return nil
+	}
+	for _, replicaConfig := range cfg.General.ReplicaConfigs {
+		if getReplicaName(replicaConfig) == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("remote storage '%s' is not found, use `%s` or name of general->replica_configs file without extension", name, mainRemoteName)
+}
+
+// getRemoteConfig - `main` is remote storage of current config, other names are `general->replica_configs` file names without extension, or path to replica config file
+func (b *Backuper) getRemoteConfig(name string) (*config.Config, error) {
+	if name == mainRemoteName {
+		return b.cfg, nil
+	}
+	for _, replicaConfig := range b.cfg.General.ReplicaConfigs {
+		if getReplicaName(replicaConfig) == name {
+			return b.cfg.LoadReplicaConfig(replicaConfig)
+		}
+	}
+	if _, err := os.Stat(name); err == nil {
+		return b.cfg.LoadReplicaConfig(name)
+	}
+	return nil, fmt.Errorf("remote storage '%s' is not found, use `%s`, name of general->replica_configs file without extension or path to replica config file", name, mainRemoteName)
+}
+
+func (b *Backuper) connectRemoteForCopy(ctx context.Context, name string, cfg *config.Config) (*storage.BackupDestination, error) {
+	if cfg.General.RemoteStorage == "none" || cfg.General.RemoteStorage == "custom" {
+		return nil, fmt.Errorf("copy is not supported for %s with remote_storage: %s", name, cfg.General.RemoteStorage)
+	}
+	bd, err := storage.NewBackupDestination(ctx, cfg, b.ch, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("can't connect to %s: %v", name, err)
+	}
+	return bd, nil
+}
+
+// remoteObjectCopier - copy backup files between remote storages or inside the same remote storage, server-side copy is used while it succeeds, otherwise each file is streamed through clickhouse-backup
+type remoteObjectCopier struct {
+	srcBd             *storage.BackupDestination
+	dstBd             *storage.BackupDestination
+	dstCopyStorage    storage.BackupCopyStorage
+	srcBucket         string
+	srcPath           string
+	useServerSideCopy atomic.Bool
+	copiedSize        int64
+}
+
+// newRemoteObjectCopier - shall be called after NewBackupDestination for both configs, cause it applies macros to `path`
+func newRemoteObjectCopier(srcBd, dstBd *storage.BackupDestination, srcCfg, dstCfg *config.Config) *remoteObjectCopier {
+	c := &remoteObjectCopier{srcBd: srcBd, dstBd: dstBd}
+	srcBucket, srcPath, isServerSideCopy := getBackupCopySource(srcCfg)
+	dstCopyStorage, isCopyStorage := dstBd.RemoteStorage.(storage.BackupCopyStorage)
+	c.srcBucket, c.srcPath, c.dstCopyStorage = srcBucket, srcPath, dstCopyStorage
+	c.useServerSideCopy.Store(isServerSideCopy && isCopyStorage && srcCfg.General.RemoteStorage == dstCfg.General.RemoteStorage)
+	return c
+}
+
+// copy - srcKey and dstKey are relative to `path` of remote storage, safe for concurrent use
+func (c *remoteObjectCopier) copy(ctx context.Context, size int64, srcKey, dstKey string) error {
+	if c.useServerSideCopy.Load() {
+		copied, err := c.dstCopyStorage.CopyBackupObject(ctx, size, c.srcBucket, path.Join(c.srcPath, srcKey), dstKey)
+		if err == nil {
+			atomic.AddInt64(&c.copiedSize, copied)
+			return nil
+		}
+		// credentials of destination could be without read access to source bucket
+		if c.useServerSideCopy.CompareAndSwap(true, false) {
+			log.Warn().Msgf("server-side copy %s return error: %v, switch to streaming", srcKey, err)
+		}
+	}
+	r, err := c.srcBd.GetFileReader(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("copy %s -> %s", srcKey, dstKey)
+	if err = c.dstBd.PutFile(ctx, dstKey, r); err != nil {
+		return err
+	}
+	atomic.AddInt64(&c.copiedSize, size)
+	return nil
+}
+
+// getBackupCopySource - bucket and path of source remote storage for storage.BackupCopyStorage, false when server-side copy is not supported
+func getBackupCopySource(cfg *config.Config) (string, string, bool) {
+	switch cfg.General.RemoteStorage {
+	case "s3":
+		return cfg.S3.Bucket, cfg.S3.Path, true
+	case "gcs":
+		return cfg.GCS.Bucket, cfg.GCS.Path, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+func TestGetRemoteConfig(t *testing.T) {
+	replicaConfig := path.Join(t.TempDir(), "archive.yml")
+	if err := os.WriteFile(replicaConfig, []byte("general:\n  remote_storage: local\nlocal:\n  path: /var/lib/clickhouse-backup/archive/\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.General.BackupsToKeepRemote = 7
+	cfg.General.ReplicaConfigs = []string{replicaConfig}
+	b := NewBackuper(cfg)
+
+	mainCfg, err := b.getRemoteConfig("main")
+	if err != nil || mainCfg != cfg {
+		t.Fatalf("`main` shall return current config, got err=%v", err)
+	}
+	for _, name := range []string{"archive", replicaConfig} {
+		archiveCfg, err := b.getRemoteConfig(name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if archiveCfg.General.RemoteStorage != "local" || archiveCfg.Local.Path != "/var/lib/clickhouse-backup/archive" {
+			t.Fatalf("%s: replica settings are not applied, remote_storage=%s local->path=%s", name, archiveCfg.General.RemoteStorage, archiveCfg.Local.Path)
+		}
+		if archiveCfg.General.BackupsToKeepRemote != 7 {
+			t.Fatalf("%s: main settings shall be inherited, got backups_to_keep_remote=%d", name, archiveCfg.General.BackupsToKeepRemote)
+		}
+		if len(archiveCfg.General.ReplicaConfigs) != 0 || cfg.General.RemoteStorage == "local" {
+			t.Fatalf("%s: replica config shall not have replicas and shall not change main config", name)
+		}
+	}
+	if _, err = b.getRemoteConfig("unknown"); err == nil {
+		t.Fatal("unknown remote storage shall return error")
+	}
+}
+
+func TestCheckCopyRemoteName(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.General.ReplicaConfigs = []string{"/etc/clickhouse-backup/archive.yml"}
+	for _, name := range []string{"main", "archive"} {
+		if err := CheckCopyRemoteName(cfg, name); err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"/etc/clickhouse-backup/archive.yml", "/etc/passwd", "unknown"} {
+		if err := CheckCopyRemoteName(cfg, name); err == nil {
+			t.Fatalf("%s: shall return error", name)
+		}
+	}
+}
+
+// fakeCopyStorage - in-memory objects relative to `path`, CopyBackupObject receives source key with `path` prefix like S3 and GCS
+type fakeCopyStorage struct {
+	storage.RemoteStorage
+	sync.Mutex
+	objects          map[string]string
+	pathPrefix       string
+	copyErr          error
+	serverSideCopies int
+	streamedCopies   int
+}
+
+func (s *fakeCopyStorage) CopyBackupObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.copyErr != nil {
+		return 0, s.copyErr
+	}
+	body, exists := s.objects[strings.TrimPrefix(srcKey, s.pathPrefix+"/")]
+	if !exists {
+		return 0, fmt.Errorf("%s/%s not found", srcBucket, srcKey)
+	}
+	s.objects[dstKey] = body
+	s.serverSideCopies++
+	return int64(len(body)), nil
+}
+
+func (s *fakeCopyStorage) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.Lock()
+	defer s.Unlock()
+	body, exists := s.objects[key]
+	if !exists {
+		return nil, fmt.Errorf("%s not found", key)
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func (s *fakeCopyStorage) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.objects[key] = string(body)
+	s.streamedCopies++
+	return nil
+}
+
+func TestRemoteObjectCopierRename(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.General.RemoteStorage = "s3"
+	cfg.S3.Bucket = "bucket"
+	cfg.S3.Path = "prefix"
+	fakeStorage := &fakeCopyStorage{pathPrefix: "prefix", objects: map[string]string{
+		"backup1/shadow/default/t1/default_all_1_1_0.tar": "data1",
+		"backup1/metadata/default/t1.json":                "table",
+	}}
+	bd := &storage.BackupDestination{RemoteStorage: fakeStorage}
+
+	// rename inside the same remote storage shall use server-side copy
+	copier := newRemoteObjectCopier(bd, bd, cfg, cfg)
+	for _, key := range []string{"shadow/default/t1/default_all_1_1_0.tar", "metadata/default/t1.json"} {
+		if err := copier.copy(context.Background(), 5, path.Join("backup1", key), path.Join("backup2", key)); err != nil {
+			t.Fatalf("unexpected copy error: %v", err)
+		}
+	}
+	if fakeStorage.serverSideCopies != 2 || fakeStorage.streamedCopies != 0 {
+		t.Fatalf("expected 2 server-side copies, got server-side=%d streamed=%d", fakeStorage.serverSideCopies, fakeStorage.streamedCopies)
+	}
+	if fakeStorage.objects["backup2/shadow/default/t1/default_all_1_1_0.tar"] != "data1" || fakeStorage.objects["backup2/metadata/default/t1.json"] != "table" {
+		t.Fatalf("unexpected objects after server-side copy: %v", fakeStorage.objects)
+	}
+	if copier.copiedSize != 10 || !copier.useServerSideCopy.Load() {
+		t.Fatalf("unexpected copied size %d or server-side copy disabled", copier.copiedSize)
+	}
+
+	// failed server-side copy switch to streaming
+	fakeStorage.copyErr = fmt.Errorf("access denied")
+	copier = newRemoteObjectCopier(bd, bd, cfg, cfg)
+	if err := copier.copy(context.Background(), 5, "backup1/shadow/default/t1/default_all_1_1_0.tar", "backup3/shadow/default/t1/default_all_1_1_0.tar"); err != nil {
+		t.Fatalf("unexpected copy error: %v", err)
+	}
+	if copier.useServerSideCopy.Load() || fakeStorage.streamedCopies != 1 || fakeStorage.objects["backup3/shadow/default/t1/default_all_1_1_0.tar"] != "data1" {
+		t.Fatalf("server-side copy error shall switch to streaming, streamed=%d", fakeStorage.streamedCopies)
+	}
+
+	// storages without server-side copy always stream
+	cfg.General.RemoteStorage = "sftp"
+	copier = newRemoteObjectCopier(bd, bd, cfg, cfg)
+	if copier.useServerSideCopy.Load() {
+		t.Fatal("server-side copy shall be disabled for sftp")
+	}
+}
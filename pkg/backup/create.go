@@ -6,10 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
-	"github.com/eapache/go-resiliency/retrier"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -24,6 +24,7 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/hooks"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/keeper"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/partition"
@@ -55,9 +56,43 @@ func NewBackupName() string {
 	return time.Now().UTC().Format(TimeFormatForBackup)
 }
 
+var backupNameTemplateMacroRE = regexp.MustCompile(`{[^}]*}`)
+
+// NewBackupNameFromTemplate - return backup name from general->backup_name_template, {macro} values apply from system.macros, {date}, {time} and {time:layout} apply from current UTC time
+// return default backup name when template is empty
+func NewBackupNameFromTemplate(ctx context.Context, ch *clickhouse.ClickHouse, template string) (string, error) {
+	if template == "" {
+		return NewBackupName(), nil
+	}
+	backupName := applyBackupNameTimeTemplate(template, time.Now().UTC())
+	if backupNameTemplateMacroRE.MatchString(backupName) {
+		if !ch.IsOpen {
+			if err := ch.Connect(); err != nil {
+				return "", fmt.Errorf("can't connect to clickhouse: %v", err)
+			}
+			defer ch.Close()
+		}
+		var err error
+		if backupName, err = ch.ApplyMacros(ctx, backupName); err != nil {
+			return "", fmt.Errorf("can't apply macros to backup_name_template: %v", err)
+		}
+		if unknownMacros := backupNameTemplateMacroRE.FindAllString(backupName, -1); len(unknownMacros) > 0 {
+			return "", fmt.Errorf("backup_name_template %s contains %s which not present in system.macros", template, strings.Join(unknownMacros, ","))
+		}
+	}
+	return utils.CleanBackupNameRE.ReplaceAllString(backupName, ""), nil
+}
+
+func applyBackupNameTimeTemplate(template string, now time.Time) string {
+	for _, group := range watchBackupTemplateTimeRE.FindAllStringSubmatch(template, -1) {
+		template = strings.ReplaceAll(template, group[0], now.Format(group[1]))
+	}
+	return strings.NewReplacer("{date}", now.Format("2006-01-02"), "{time}", now.Format(TimeFormatForBackup)).Replace(template)
+}
+
 // CreateBackup - create new backup of all tables matched by tablePattern
 // If backupName is empty string will use default backup name
-func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string, partitions []string, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, skipCheckPartsColumns, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string, partitions []string, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, skipCheckPartsColumns, resume bool, backupVersion string, commandId int) (err error) {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -67,9 +102,20 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 
 	startBackup := time.Now()
 	if backupName == "" {
-		backupName = NewBackupName()
+		if backupName, err = NewBackupNameFromTemplate(ctx, b.ch, b.cfg.General.BackupNameTemplate); err != nil {
+			return err
+		}
 	}
 	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	defer func() {
+		b.notify("create", backupName, startBackup, err)
+	}()
+	if err = hooks.Run(ctx, &b.cfg.Hooks, hooks.BeforeCreate, "create", backupName, nil); err != nil {
+		return err
+	}
+	if partitions, err = partition.ApplyPartitionsTimeTemplate(partitions, time.Now()); err != nil {
+		return err
+	}
 	if err := b.ch.Connect(); err != nil {
 		return fmt.Errorf("can't connect to clickhouse: %v", err)
 	}
@@ -91,6 +137,17 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 	if err != nil {
 		return fmt.Errorf("can't get tables from clickhouse: %v", err)
 	}
+	// validate before any FREEZE
+	if b.strictTablePattern && !rbacOnly && !configsOnly {
+		if err = CheckTablePatternMatches(tables, tablePattern); err != nil {
+			return err
+		}
+	}
+
+	// BACKUP SQL for embedded backup doesn't support schema-only tables together with data tables
+	if b.cfg.General.MaxTableDataSizeBytes > 0 && !schemaOnly && !rbacOnly && !configsOnly && !b.cfg.ClickHouse.UseEmbeddedBackupRestore {
+		skipDataForLargeTables(tables, tablePattern, b.cfg.General.MaxTableDataSizeBytes)
+	}
 
 	if b.CalculateNonSkipTables(tables) == 0 && !b.cfg.General.AllowEmptyBackups {
 		return fmt.Errorf("no tables for backup")
@@ -109,6 +166,15 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 	if err != nil {
 		return err
 	}
+	if err = b.checkEmbeddedBackupVersion(version); err != nil {
+		return err
+	}
+	// validate replicas health before any FREEZE
+	if b.cfg.ClickHouse.CheckReplicasBeforeBackup && !schemaOnly && !rbacOnly && !configsOnly {
+		if err = b.checkReplicasHealth(ctx, tables); err != nil {
+			return err
+		}
+	}
 	b.DefaultDataPath, err = b.ch.GetDefaultPath(disks)
 	if err != nil {
 		return err
@@ -120,17 +186,61 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 		diskMap[disk.Name] = disk.Path
 		diskTypes[disk.Name] = disk.Type
 	}
-	partitionsIdMap, partitionsNameList := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, tables, nil, partitions)
+	partitionsIdMap, partitionsNameList, err := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, tables, nil, partitions)
+	if err != nil {
+		return err
+	}
 	doBackupData := !schemaOnly && !rbacOnly && !configsOnly
+	if doBackupData {
+		if err = b.checkMaxPartsCount(ctx, tables, partitionsIdMap); err != nil {
+			return err
+		}
+		// FREEZE creates hardlinks, only embedded backup to local disk copies data
+		requiredSpace := map[string]uint64{}
+		if b.cfg.ClickHouse.UseEmbeddedBackupRestore && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+			for _, table := range tables {
+				if !table.Skip && !table.SkipData {
+					requiredSpace[b.cfg.ClickHouse.EmbeddedBackupDisk] += table.TotalBytes
+				}
+			}
+		}
+		if err = b.checkDisksFreeSpace("create", disks, requiredSpace); err != nil {
+			return err
+		}
+	}
 	backupRBACSize, backupConfigSize, rbacAndConfigsErr := b.createRBACAndConfigsIfNecessary(ctx, backupName, createRBAC, rbacOnly, createConfigs, configsOnly, disks, diskMap)
 	if rbacAndConfigsErr != nil {
 		return rbacAndConfigsErr
 	}
+	if b.cfg.ClickHouse.BackupDictionaryFiles && !rbacOnly && !configsOnly {
+		backupPath := path.Join(b.DefaultDataPath, "backup", backupName)
+		if b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+			backupPath = path.Join(diskMap[b.cfg.ClickHouse.EmbeddedBackupDisk], backupName)
+		}
+		dictionaryFilesSize, dictionaryFilesErr := b.createBackupDictionaryFiles(ctx, backupPath, tables, disks)
+		if dictionaryFilesErr != nil {
+			return dictionaryFilesErr
+		}
+		log.Info().Str("size", utils.FormatBytes(dictionaryFilesSize)).Msg("done createBackupDictionaryFiles")
+		backupConfigSize += dictionaryFilesSize
+	}
+	// embedded backup doesn't use local data paths
+	if b.includeDetached && doBackupData && !b.cfg.ClickHouse.UseEmbeddedBackupRestore {
+		detachedSize, detachedErr := b.createBackupDetachedParts(ctx, path.Join(b.DefaultDataPath, "backup", backupName), tables, disks)
+		if detachedErr != nil {
+			return detachedErr
+		}
+		log.Info().Str("size", utils.FormatBytes(detachedSize)).Msg("done createBackupDetachedParts")
+		backupConfigSize += detachedSize
+	}
 	if b.cfg.ClickHouse.UseEmbeddedBackupRestore {
 		err = b.createBackupEmbedded(ctx, backupName, diffFromRemote, doBackupData, schemaOnly, backupVersion, tablePattern, partitionsNameList, partitionsIdMap, tables, allDatabases, allFunctions, disks, diskMap, diskTypes, backupRBACSize, backupConfigSize, startBackup, version)
 	} else {
 		err = b.createBackupLocal(ctx, backupName, diffFromRemote, doBackupData, schemaOnly, rbacOnly, configsOnly, backupVersion, partitions, partitionsIdMap, tables, tablePattern, disks, diskMap, diskTypes, allDatabases, allFunctions, backupRBACSize, backupConfigSize, startBackup, version)
 	}
+	if err == nil {
+		err = b.runPostCreateChecks(ctx, backupName)
+	}
 	if err != nil {
 		log.Error().Msgf("backup failed error: %v", err)
 		// delete local backup if can't create
@@ -162,6 +272,25 @@ func (b *Backuper) CalculateNonSkipTables(tables []clickhouse.Table) int {
 	return i
 }
 
+// checkMaxPartsCount - protect from freeze millions of tiny parts, when inserts misbehave and merges can't keep up
+func (b *Backuper) checkMaxPartsCount(ctx context.Context, tables []clickhouse.Table, partitionsIdMap map[metadata.TableTitle]common.EmptyMap) error {
+	if b.cfg.General.MaxPartsCount == 0 {
+		return nil
+	}
+	partsCount, err := b.ch.GetActivePartsCount(ctx, tables, partitionsIdMap)
+	if err != nil {
+		return fmt.Errorf("can't get active parts count: %v", err)
+	}
+	if partsCount <= b.cfg.General.MaxPartsCount {
+		return nil
+	}
+	if b.cfg.General.MaxPartsCountAction == "warn" {
+		log.Warn().Msgf("matched tables contain %d active parts, more than max_parts_count=%d", partsCount, b.cfg.General.MaxPartsCount)
+		return nil
+	}
+	return fmt.Errorf("matched tables contain %d active parts, more than max_parts_count=%d, check system.parts or increase max_parts_count", partsCount, b.cfg.General.MaxPartsCount)
+}
+
 func (b *Backuper) createRBACAndConfigsIfNecessary(ctx context.Context, backupName string, createRBAC bool, rbacOnly bool, createConfigs bool, configsOnly bool, disks []clickhouse.Disk, diskMap map[string]string) (uint64, uint64, error) {
 	backupRBACSize, backupConfigSize := uint64(0), uint64(0)
 	backupPath := path.Join(b.DefaultDataPath, "backup")
@@ -184,6 +313,14 @@ func (b *Backuper) createRBACAndConfigsIfNecessary(ctx context.Context, backupNa
 		} else {
 			log.Info().Str("size", utils.FormatBytes(backupConfigSize)).Msg("done createBackupConfigs")
 		}
+		if b.cfg.ClickHouse.KeeperSnapshotPath != "" {
+			keeperSnapshotSize, createKeeperSnapshotErr := b.createBackupKeeperSnapshot(ctx, backupPath)
+			if createKeeperSnapshotErr != nil {
+				return backupRBACSize, backupConfigSize, fmt.Errorf("error during do Keeper snapshot backup: %v", createKeeperSnapshotErr)
+			}
+			log.Info().Str("size", utils.FormatBytes(keeperSnapshotSize)).Msg("done createBackupKeeperSnapshot")
+			backupConfigSize += keeperSnapshotSize
+		}
 	}
 	if backupRBACSize > 0 || backupConfigSize > 0 {
 		if chownErr := filesystemhelper.Chown(backupPath, b.ch, disks, true); chownErr != nil {
@@ -244,6 +381,7 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 		if err != nil {
 			return err
 		}
+		b.dst.SetTransferObserver(b.transferObserver)
 		if err = b.dst.Connect(ctx); err != nil {
 			return fmt.Errorf("can't connect to %s: %v", b.dst.Kind(), err)
 		}
@@ -274,8 +412,15 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 
 	var backupDataSize, backupObjectDiskSize, backupMetadataSize uint64
 	var metaMutex sync.Mutex
+	databaseSizes := make(map[string]uint64)
+	tablesParts := make(map[metadata.TableTitle]map[string][]metadata.Part)
 	createBackupWorkingGroup, createCtx := errgroup.WithContext(ctx)
-	createBackupWorkingGroup.SetLimit(max(b.cfg.ClickHouse.MaxConnections, 1))
+	freezeConcurrency := b.cfg.ClickHouse.MaxConnections
+	if b.cfg.ClickHouse.FreezeConcurrency > 0 {
+		freezeConcurrency = b.cfg.ClickHouse.FreezeConcurrency
+	}
+	createBackupWorkingGroup.SetLimit(max(freezeConcurrency, 1))
+	log.Debug().Msgf("prepare table concurrent semaphore with concurrency=%d len(tables)=%d", max(freezeConcurrency, 1), len(tables))
 
 	var tableMetas []metadata.TableTitle
 	for tableIdx, tableItem := range tables {
@@ -289,11 +434,21 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 			logger := log.With().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Logger()
 			var realSize, objectDiskSize map[string]int64
 			var disksToPartsMap map[string][]metadata.Part
+			var snapshot *metadata.SnapshotMetadata
 			if doBackupData && table.BackupType == clickhouse.ShardBackupFull {
 				logger.Debug().Msg("create data")
 				shadowBackupUUID := strings.ReplaceAll(uuid.New().String(), "-", "")
+				if isMergeTreeFamily(table.Engine) {
+					maxModificationTime, maxModificationTimeErr := b.ch.GetMaxModificationTime(createCtx, &table)
+					if maxModificationTimeErr != nil {
+						logger.Error().Msgf("b.ch.GetMaxModificationTime error: %v", maxModificationTimeErr)
+						return maxModificationTimeErr
+					}
+					// FreezeTime and MergesStopped are filled by AddTableToLocalBackup during FREEZE
+					snapshot = &metadata.SnapshotMetadata{MaxModificationTime: maxModificationTime}
+				}
 				var addTableToBackupErr error
-				disksToPartsMap, realSize, objectDiskSize, addTableToBackupErr = b.AddTableToLocalBackup(createCtx, backupName, tablesDiffFromRemote, shadowBackupUUID, disks, &table, partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Name}], version)
+				disksToPartsMap, realSize, objectDiskSize, addTableToBackupErr = b.AddTableToLocalBackup(createCtx, backupName, tablesDiffFromRemote, shadowBackupUUID, disks, &table, partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Name}], snapshot, version)
 				if addTableToBackupErr != nil {
 					logger.Error().Msgf("b.AddTableToLocalBackup error: %v", addTableToBackupErr)
 					return addTableToBackupErr
@@ -320,14 +475,16 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 			logger.Debug().Msg("create metadata")
 			if schemaOnly || doBackupData {
 				metadataSize, createTableMetadataErr := b.createTableMetadata(path.Join(backupPath, "metadata"), metadata.TableMetadata{
-					Table:        table.Name,
-					Database:     table.Database,
-					Query:        table.CreateTableQuery,
-					TotalBytes:   table.TotalBytes,
-					Size:         realSize,
-					Parts:        disksToPartsMap,
-					Mutations:    inProgressMutations,
-					MetadataOnly: schemaOnly || table.BackupType == clickhouse.ShardBackupSchema,
+					Table:          table.Name,
+					Database:       table.Database,
+					Query:          table.CreateTableQuery,
+					TotalBytes:     table.TotalBytes,
+					Size:           realSize,
+					Parts:          disksToPartsMap,
+					Mutations:      inProgressMutations,
+					Snapshot:       snapshot,
+					MetadataOnly:   schemaOnly || table.BackupType == clickhouse.ShardBackupSchema,
+					SkipDataReason: table.SkipDataReason,
 				}, disks)
 				if createTableMetadataErr != nil {
 					logger.Error().Msgf("b.createTableMetadata error: %v", createTableMetadataErr)
@@ -339,6 +496,12 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 					Database: table.Database,
 					Table:    table.Name,
 				})
+				for _, size := range realSize {
+					databaseSizes[table.Database] += uint64(size)
+				}
+				if disksToPartsMap != nil {
+					tablesParts[metadata.TableTitle{Database: table.Database, Table: table.Name}] = disksToPartsMap
+				}
 				metaMutex.Unlock()
 			}
 			logger.Info().Str("progress", fmt.Sprintf("%d/%d", idx+1, len(tables))).Msg("done")
@@ -349,8 +512,12 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 		return fmt.Errorf("one of createBackupLocal go-routine return error: %v", wgWaitErr)
 	}
 
+	var churn *metadata.ChurnMetadata
+	if doBackupData {
+		churn = b.calculateBackupChurn(ctx, backupName, diffFromRemote, tablesDiffFromRemote, tablePattern, tables, disks, tablesParts)
+	}
 	backupMetaFile := path.Join(b.DefaultDataPath, "backup", backupName, "metadata.json")
-	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, diffFromRemote, backupVersion, "regular", diskMap, diskTypes, disks, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize, tableMetas, allDatabases, allFunctions); err != nil {
+	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, diffFromRemote, backupVersion, "regular", diskMap, diskTypes, disks, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize, databaseSizes, tableMetas, tables, allDatabases, allFunctions, churn); err != nil {
 		return fmt.Errorf("createBackupMetadata return error: %v", err)
 	}
 	log.Info().Str("version", backupVersion).Str("operation", "createBackupLocal").Str("duration", utils.HumanizeDuration(time.Since(startBackup))).Msg("done")
@@ -431,6 +598,7 @@ func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBac
 			if b.dst, err = storage.NewBackupDestination(ctx, b.cfg, b.ch, backupName); err != nil {
 				return err
 			}
+			b.dst.SetTransferObserver(b.transferObserver)
 			if err = b.dst.Connect(ctx); err != nil {
 				return fmt.Errorf("createBackupEmbedded: can't connect to %s: %v", b.dst.Kind(), err)
 			}
@@ -481,7 +649,7 @@ func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBac
 		}
 	}
 	backupMetaFile := path.Join(backupPath, "metadata.json")
-	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, baseBackup, backupVersion, "embedded", diskMap, diskTypes, disks, backupDataSize[0].Size, 0, backupMetadataSize, backupRBACSize, backupConfigSize, tablesTitle, allDatabases, allFunctions); err != nil {
+	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, baseBackup, backupVersion, "embedded", diskMap, diskTypes, disks, backupDataSize[0].Size, 0, backupMetadataSize, backupRBACSize, backupConfigSize, nil, tablesTitle, tables, allDatabases, allFunctions, nil); err != nil {
 		return err
 	}
 
@@ -650,6 +818,36 @@ func (b *Backuper) createBackupConfigs(ctx context.Context, backupPath string) (
 	}
 }
 
+// createBackupKeeperSnapshot - trigger snapshot via `csnp` when keeper_snapshot_address defined and copy latest snapshot from co-located ClickHouse Keeper
+func (b *Backuper) createBackupKeeperSnapshot(ctx context.Context, backupPath string) (uint64, error) {
+	snapshotPath := b.cfg.ClickHouse.KeeperSnapshotPath
+	snapshotFile := ""
+	if b.cfg.ClickHouse.KeeperSnapshotAddress != "" {
+		logIdx, err := keeper.TriggerSnapshot(ctx, b.cfg.ClickHouse.KeeperSnapshotAddress)
+		if err != nil {
+			return 0, err
+		}
+		if snapshotFile, err = keeper.WaitSnapshot(ctx, snapshotPath, logIdx, b.cfg.ClickHouse.KeeperSnapshotTimeoutDuration); err != nil {
+			return 0, err
+		}
+	} else {
+		latestSnapshotFile, snapshotIdx, err := keeper.LatestSnapshot(snapshotPath)
+		if err != nil {
+			return 0, err
+		}
+		if latestSnapshotFile == "" {
+			return 0, fmt.Errorf("no snapshot_*.bin files in %s", snapshotPath)
+		}
+		// latest snapshot could be still in progress
+		if snapshotFile, err = keeper.WaitSnapshot(ctx, snapshotPath, snapshotIdx, b.cfg.ClickHouse.KeeperSnapshotTimeoutDuration); err != nil {
+			return 0, err
+		}
+	}
+	keeperBackupPath := path.Join(backupPath, "keeper")
+	log.Debug().Msgf("copy %s -> %s", snapshotFile, keeperBackupPath)
+	return keeper.CopySnapshot(snapshotFile, keeperBackupPath)
+}
+
 func (b *Backuper) createBackupRBAC(ctx context.Context, backupPath string, disks []clickhouse.Disk) (uint64, error) {
 	select {
 	case <-ctx.Done():
@@ -741,7 +939,7 @@ func (b *Backuper) createBackupRBACReplicated(ctx context.Context, rbacBackup st
 	return rbacDataSize, nil
 }
 
-func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string, tablesDiffFromRemote map[metadata.TableTitle]metadata.TableMetadata, shadowBackupUUID string, diskList []clickhouse.Disk, table *clickhouse.Table, partitionsIdsMap common.EmptyMap, version int) (map[string][]metadata.Part, map[string]int64, map[string]int64, error) {
+func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string, tablesDiffFromRemote map[metadata.TableTitle]metadata.TableMetadata, shadowBackupUUID string, diskList []clickhouse.Disk, table *clickhouse.Table, partitionsIdsMap common.EmptyMap, snapshot *metadata.SnapshotMetadata, version int) (map[string][]metadata.Part, map[string]int64, map[string]int64, error) {
 	logger := log.With().Fields(map[string]interface{}{
 		"backup":    backupName,
 		"operation": "create",
@@ -762,11 +960,28 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 			return nil, nil, nil, err
 		}
 	}
-	// backup data
-	if err := b.ch.FreezeTable(ctx, table, shadowBackupUUID); err != nil {
+	if b.cfg.ClickHouse.CleanShadowAfterFreeze {
+		defer b.cleanShadowAfterFreeze(context.WithoutCancel(ctx), table, shadowBackupUUID, diskList, version)
+	}
+	// backup data, when --partitions is defined, FREEZE only requested partitions instead of hard-link all table parts
+	var freezePartitionIds []string
+	if len(partitionsIdsMap) > 0 {
+		tablePartitionIds, err := b.ch.GetActivePartitionIds(ctx, table)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		freezePartitionIds = filterPartitionIds(tablePartitionIds, partitionsIdsMap)
+	}
+	if len(partitionsIdsMap) > 0 && len(freezePartitionIds) == 0 {
+		logger.Debug().Msg("requested partitions not found, skip freeze")
+		if snapshot != nil {
+			snapshot.FreezeTime = time.Now().UTC()
+		}
+	} else if err := b.freezeTable(ctx, table, shadowBackupUUID, freezePartitionIds, snapshot); err != nil {
 		return nil, nil, nil, err
+	} else {
+		log.Debug().Str("database", table.Database).Str("table", table.Name).Int("partitions", len(freezePartitionIds)).Msg("frozen")
 	}
-	log.Debug().Str("database", table.Database).Str("table", table.Name).Msg("frozen")
 	realSize := map[string]int64{}
 	objectDiskSize := map[string]int64{}
 	disksToPartsMap := map[string][]metadata.Part{}
@@ -822,7 +1037,7 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 		}
 	}
 	// Unfreeze to unlock data on S3 disks, https://github.com/Altinity/clickhouse-backup/issues/423
-	if version > 21004000 {
+	if version > 21004000 && !b.cfg.ClickHouse.CleanShadowAfterFreeze {
 		if err := b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` UNFREEZE WITH NAME '%s'", table.Database, table.Name, shadowBackupUUID)); err != nil {
 			if (strings.Contains(err.Error(), "code: 60") || strings.Contains(err.Error(), "code: 81") || strings.Contains(err.Error(), "code: 218")) && b.cfg.ClickHouse.IgnoreNotExistsErrorDuringFreeze {
 				logger.Warn().Msgf("can't unfreeze table: %v", err)
@@ -836,6 +1051,143 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 	return disksToPartsMap, realSize, objectDiskSize, nil
 }
 
+// skipDataForLargeTables - `general->max_table_data_size`, protect against accidental backup of huge tables, tables from tablePattern without wildcards are explicitly included
+func skipDataForLargeTables(tables []clickhouse.Table, tablePattern string, maxTableDataSize uint64) {
+	explicitTables := common.EmptyMap{}
+	for _, pattern := range strings.Split(tablePattern, ",") {
+		pattern = strings.Trim(pattern, " \t\r\n")
+		if pattern != "" && !strings.ContainsAny(pattern, "*?[") {
+			explicitTables[pattern] = struct{}{}
+		}
+	}
+	for i := range tables {
+		if tables[i].Skip || tables[i].SkipData || tables[i].TotalBytes <= maxTableDataSize {
+			continue
+		}
+		tableName := fmt.Sprintf("%s.%s", tables[i].Database, tables[i].Name)
+		if _, isExplicit := explicitTables[tableName]; isExplicit {
+			log.Info().Msgf("%s total_bytes=%s greater than max_table_data_size=%s, but explicitly included in --tables", tableName, utils.FormatBytes(tables[i].TotalBytes), utils.FormatBytes(maxTableDataSize))
+			continue
+		}
+		tables[i].SkipData = true
+		// BackupType is already populated by GetTables, createBackupLocal freeze only ShardBackupFull tables
+		tables[i].BackupType = clickhouse.ShardBackupSchema
+		tables[i].SkipDataReason = fmt.Sprintf("total_bytes=%d greater than max_table_data_size=%d", tables[i].TotalBytes, maxTableDataSize)
+		log.Warn().Msgf("%s total_bytes=%s greater than max_table_data_size=%s, only schema will backup, use --tables=%s to backup data", tableName, utils.FormatBytes(tables[i].TotalBytes), utils.FormatBytes(maxTableDataSize), tableName)
+	}
+}
+
+// checkReplicasHealth - `clickhouse->check_replicas_before_backup`, backup of read-only or lagged replica silently contains incomplete data
+func (b *Backuper) checkReplicasHealth(ctx context.Context, tables []clickhouse.Table) error {
+	replicas, err := b.ch.GetReplicasHealth(ctx)
+	if err != nil {
+		return err
+	}
+	unhealthyReplicas := getUnhealthyReplicas(replicas, tables, b.cfg.ClickHouse.CheckReplicasMaxAbsoluteDelay, b.cfg.ClickHouse.CheckReplicasMaxQueueErrors)
+	if len(unhealthyReplicas) > 0 {
+		return fmt.Errorf("replicas health check failed, use `check_replicas_before_backup: false` to skip it: %s", strings.Join(unhealthyReplicas, "; "))
+	}
+	log.Info().Int("replicas", len(replicas)).Msg("replicas health check passed")
+	return nil
+}
+
+// getUnhealthyReplicas - only tables with data in backup are checked
+func getUnhealthyReplicas(replicas []clickhouse.ReplicaHealth, tables []clickhouse.Table, maxAbsoluteDelay, maxQueueErrors uint64) []string {
+	backupTables := make(map[metadata.TableTitle]struct{}, len(tables))
+	for _, table := range tables {
+		if !table.Skip && !table.SkipData {
+			backupTables[metadata.TableTitle{Database: table.Database, Table: table.Name}] = struct{}{}
+		}
+	}
+	unhealthyReplicas := make([]string, 0)
+	for _, r := range replicas {
+		if _, exists := backupTables[metadata.TableTitle{Database: r.Database, Table: r.Table}]; !exists {
+			continue
+		}
+		var problems []string
+		if r.IsReadonly > 0 {
+			problems = append(problems, "is_readonly=1")
+		}
+		if r.IsSessionExpired > 0 {
+			problems = append(problems, "is_session_expired=1")
+		}
+		if r.AbsoluteDelay > maxAbsoluteDelay {
+			problems = append(problems, fmt.Sprintf("absolute_delay=%d > %d", r.AbsoluteDelay, maxAbsoluteDelay))
+		}
+		if r.QueueErrors > maxQueueErrors {
+			problems = append(problems, fmt.Sprintf("queue_errors=%d > %d, queue_size=%d", r.QueueErrors, maxQueueErrors, r.QueueSize))
+		}
+		if len(problems) > 0 {
+			unhealthyReplicas = append(unhealthyReplicas, fmt.Sprintf("`%s`.`%s` %s", r.Database, r.Table, strings.Join(problems, ", ")))
+		}
+	}
+	return unhealthyReplicas
+}
+
+func isMergeTreeFamily(engine string) bool {
+	return strings.HasSuffix(engine, "MergeTree")
+}
+
+// freezeTable - FREEZE table, when snapshot is not nil, fill freeze time and execute SYSTEM STOP MERGES only during FREEZE, `clickhouse->stop_merges_during_freeze`
+func (b *Backuper) freezeTable(ctx context.Context, table *clickhouse.Table, shadowBackupUUID string, freezePartitionIds []string, snapshot *metadata.SnapshotMetadata) error {
+	if snapshot != nil && b.cfg.ClickHouse.StopMergesDuringFreeze {
+		if snapshot.MergesStopped = b.stopMergesDuringFreeze(ctx, table); snapshot.MergesStopped {
+			defer b.startMergesAfterFreeze(context.WithoutCancel(ctx), table)
+		}
+	}
+	if snapshot != nil {
+		snapshot.FreezeTime = time.Now().UTC()
+	}
+	return b.ch.FreezeTable(ctx, table, shadowBackupUUID, freezePartitionIds)
+}
+
+// stopMergesDuringFreeze - SYSTEM STOP MERGES for table before its FREEZE, returns false when merges were not stopped and startMergesAfterFreeze is not required
+func (b *Backuper) stopMergesDuringFreeze(ctx context.Context, table *clickhouse.Table) bool {
+	if err := b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM STOP MERGES `%s`.`%s`", table.Database, table.Name)); err != nil {
+		log.Warn().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Msgf("can't stop merges: %v", err)
+		return false
+	}
+	return true
+}
+
+// startMergesAfterFreeze - SYSTEM START MERGES right after FREEZE of table, executed after success and failure
+func (b *Backuper) startMergesAfterFreeze(ctx context.Context, table *clickhouse.Table) {
+	if err := b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM START MERGES `%s`.`%s`", table.Database, table.Name)); err != nil {
+		log.Error().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Msgf("can't start merges, execute SYSTEM START MERGES manually: %v", err)
+	}
+}
+
+// cleanShadowAfterFreeze - UNFREEZE and remove shadow/<freeze_name> on each disk, executed after success and failure, `clickhouse->clean_shadow_after_freeze`
+func (b *Backuper) cleanShadowAfterFreeze(ctx context.Context, table *clickhouse.Table, shadowBackupUUID string, diskList []clickhouse.Disk, version int) {
+	var unfreezeErr error
+	if version > 21004000 {
+		if unfreezeErr = b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` UNFREEZE WITH NAME '%s'", table.Database, table.Name, shadowBackupUUID)); unfreezeErr != nil {
+			log.Warn().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Msgf("can't unfreeze table: %v", unfreezeErr)
+		}
+	}
+	for _, disk := range diskList {
+		// removing shadow files on object disk without UNFREEZE will leave orphan objects in remote storage
+		if unfreezeErr != nil && (b.isDiskTypeObject(disk.Type) || b.isDiskTypeEncryptedObject(disk, diskList)) {
+			continue
+		}
+		shadowPath := path.Join(disk.Path, "shadow", shadowBackupUUID)
+		if err := os.RemoveAll(shadowPath); err != nil {
+			log.Warn().Msgf("can't remove %s: %v", shadowPath, err)
+		}
+	}
+}
+
+// filterPartitionIds - partition ids which match with --partitions, the same matching as filesystemhelper.IsPartInPartition
+func filterPartitionIds(partitionIds []string, partitionsIdsMap common.EmptyMap) []string {
+	filtered := make([]string, 0, len(partitionIds))
+	for _, partitionId := range partitionIds {
+		if filesystemhelper.IsPartInPartition(partitionId, partitionsIdsMap) {
+			filtered = append(filtered, partitionId)
+		}
+	}
+	return filtered
+}
+
 func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string, tableDiffFromRemote metadata.TableMetadata, backupShadowPath string, disk clickhouse.Disk) (int64, error) {
 	var size int64
 	var err error
@@ -845,7 +1197,7 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	uploadObjectDiskPartsWorkingGroup, uploadCtx := errgroup.WithContext(ctx)
-	uploadObjectDiskPartsWorkingGroup.SetLimit(int(b.cfg.General.ObjectDiskServerSideCopyConcurrency))
+	uploadObjectDiskPartsWorkingGroup.SetLimit(max(int(b.cfg.General.ObjectDiskServerSideCopyConcurrency), 1))
 	srcDiskConnection, exists := object_disk.DisksConnections.Load(disk.Name)
 	if !exists {
 		return 0, fmt.Errorf("uploadObjectDiskParts: %s not present in object_disk.DisksConnections", disk.Name)
@@ -894,31 +1246,25 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 					isAlreadyProcesses := false
 					isAlreadyProcesses, objSize = b.resumableState.IsAlreadyProcessed(path.Join(srcBucket, srcKey))
 					if isAlreadyProcesses {
+						realSize += objSize
 						continue
 					}
 				}
 				dstKey := path.Join(backupName, disk.Name, storageObject.ObjectRelativePath)
 				if !b.cfg.General.AllowObjectDiskStreaming {
-					retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
-					copyObjectErr = retry.RunCtx(uploadCtx, func(ctx context.Context) error {
-						if objSize, err = b.dst.CopyObject(ctx, storageObject.ObjectSize, srcBucket, srcKey, dstKey); err != nil {
-							return err
-						}
-						return nil
-					})
-					if copyObjectErr != nil {
+					if objSize, copyObjectErr = b.dst.CopyObject(uploadCtx, storageObject.ObjectSize, srcBucket, srcKey, dstKey); copyObjectErr != nil {
 						return fmt.Errorf("b.dst.CopyObject in %s error: %v", backupShadowPath, copyObjectErr)
 					}
 				} else {
 					if !isCopyFailed.Load() {
-						objSize, copyObjectErr = b.dst.CopyObject(ctx, storageObject.ObjectSize, srcBucket, srcKey, dstKey)
+						objSize, copyObjectErr = b.dst.CopyObject(uploadCtx, storageObject.ObjectSize, srcBucket, srcKey, dstKey)
 						if copyObjectErr != nil {
 							log.Warn().Msgf("b.dst.CopyObject in %s error: %v, will try upload via streaming (possible high network traffic)", backupShadowPath, copyObjectErr)
 							isCopyFailed.Store(true)
 						}
 					}
 					if isCopyFailed.Load() {
-						retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+						retry := storage.NewRetrier(&b.cfg.General)
 						copyObjectErr = retry.RunCtx(uploadCtx, func(ctx context.Context) error {
 							return object_disk.CopyObjectStreaming(uploadCtx, srcDiskConnection.GetRemoteStorage(), b.dst, srcKey, path.Join(objectDiskPath, dstKey))
 						})
@@ -927,9 +1273,9 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 						}
 					}
 					objSize = storageObject.ObjectSize
-					if b.resume {
-						b.resumableState.AppendToState(path.Join(srcBucket, srcKey), objSize)
-					}
+				}
+				if b.resume {
+					b.resumableState.AppendToState(path.Join(srcBucket, srcKey), objSize)
 				}
 				realSize += objSize
 			}
@@ -943,7 +1289,8 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 		return nil
 	})
 	if walkErr != nil {
-		return 0, err
+		_ = uploadObjectDiskPartsWorkingGroup.Wait()
+		return 0, walkErr
 	}
 
 	if wgWaitErr := uploadObjectDiskPartsWorkingGroup.Wait(); wgWaitErr != nil {
@@ -952,7 +1299,20 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 	return size, nil
 }
 
-func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, backupName, requiredBackup, version, tags string, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize uint64, tableMetas []metadata.TableTitle, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function) error {
+// getBackupTableEngines - sorted distinct engines of tables which will be present in backup
+func getBackupTableEngines(tables []clickhouse.Table) []string {
+	engines := make([]string, 0)
+	for _, table := range tables {
+		if table.Skip || table.Engine == "" {
+			continue
+		}
+		engines = common.AddStringToSliceIfNotExists(engines, table.Engine)
+	}
+	sort.Strings(engines)
+	return engines
+}
+
+func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, backupName, requiredBackup, version, tags string, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize uint64, databaseSizes map[string]uint64, tableMetas []metadata.TableTitle, tables []clickhouse.Table, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, churn *metadata.ChurnMetadata) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -971,9 +1331,20 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 			MetadataSize:            backupMetadataSize,
 			RBACSize:                backupRBACSize,
 			ConfigSize:              backupConfigSize,
+			DatabaseSizes:           databaseSizes,
 			Tables:                  tableMetas,
 			Databases:               []metadata.DatabasesMeta{},
 			Functions:               []metadata.FunctionsMeta{},
+			Churn:                   churn,
+			UserTags:                b.userTags,
+			CustomMetadata:          b.customMetadata,
+		}
+		backupMetadata.TableEngines = getBackupTableEngines(tables)
+		// settings and engines are used only for compatibility warnings during restore, so backup shall not fail
+		if changedSettings, err := b.ch.GetChangedSettings(ctx); err != nil {
+			log.Warn().Msgf("%v", err)
+		} else {
+			backupMetadata.ClickHouseSettings = changedSettings
 		}
 		for _, database := range allDatabases {
 			backupMetadata.Databases = append(backupMetadata.Databases, metadata.DatabasesMeta(database))
@@ -981,13 +1352,20 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 		for _, function := range allFunctions {
 			backupMetadata.Functions = append(backupMetadata.Functions, metadata.FunctionsMeta(function))
 		}
+		if err := b.setTablesChecksumsLocal(&backupMetadata, path.Dir(backupMetaFile)); err != nil {
+			return err
+		}
+		if err := backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+			return err
+		}
 		content, err := json.MarshalIndent(&backupMetadata, "", "\t")
 		if err != nil {
 			return fmt.Errorf("can't marshal backup metafile json: %v", err)
 		}
-		if err := os.WriteFile(backupMetaFile, content, 0640); err != nil {
+		if err := writeFileAtomically(backupMetaFile, content, 0640); err != nil {
 			return err
 		}
+		invalidateLocalBackupsScanCache()
 		if err := filesystemhelper.Chown(backupMetaFile, b.ch, disks, false); err != nil {
 			log.Warn().Msgf("can't chown %s: %v", backupMetaFile, err)
 		}
@@ -996,6 +1374,19 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 	}
 }
 
+// writeFileAtomically - metadata.json shall appear only after all tables processed and fully written, so interrupted create will not look like complete backup
+func writeFileAtomically(fileName string, content []byte, perm os.FileMode) error {
+	tmpFileName := fileName + ".tmp"
+	if err := os.WriteFile(tmpFileName, content, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFileName, fileName); err != nil {
+		_ = os.Remove(tmpFileName)
+		return err
+	}
+	return nil
+}
+
 func (b *Backuper) createTableMetadata(metadataPath string, table metadata.TableMetadata, disks []clickhouse.Disk) (uint64, error) {
 	if err := filesystemhelper.Mkdir(metadataPath, b.ch, disks); err != nil {
 		return 0, err
@@ -1018,3 +1409,38 @@ func (b *Backuper) createTableMetadata(metadataPath string, table metadata.Table
 	log.Debug().Msgf("%s created", metadataFile)
 	return uint64(len(metadataBody)), nil
 }
+
+// runPostCreateChecks - execute `general->post_create_checks` queries, backup declared as failed when any check doesn't return 1
+func (b *Backuper) runPostCreateChecks(ctx context.Context, backupName string) error {
+	for _, checkQuery := range b.cfg.General.PostCreateChecks {
+		if strings.TrimSpace(checkQuery) == "" {
+			continue
+		}
+		value, isEmpty, err := b.ch.SelectSingleValue(ctx, checkQuery)
+		if err != nil {
+			return fmt.Errorf("post_create_checks `%s` return error: %v", checkQuery, err)
+		}
+		passed, err := isPostCreateCheckPassed(value, isEmpty)
+		if err != nil {
+			return fmt.Errorf("post_create_checks `%s`: %v", checkQuery, err)
+		}
+		if !passed {
+			return fmt.Errorf("post_create_checks `%s` failed for %s, result: `%s`", checkQuery, backupName, value)
+		}
+		log.Info().Str("backup", backupName).Str("check", checkQuery).Msg("post_create_checks passed")
+	}
+	return nil
+}
+
+func isPostCreateCheckPassed(value string, isEmpty bool) (bool, error) {
+	if isEmpty {
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected result `%s`, expect 1 or 0", value)
+}
@@ -13,7 +13,9 @@ func (b *Backuper) CreateToRemote(backupName string, deleteSource bool, diffFrom
 	ctx, cancel = context.WithCancel(ctx)
 	defer cancel()
 	if backupName == "" {
-		backupName = NewBackupName()
+		if backupName, err = NewBackupNameFromTemplate(ctx, b.ch, b.cfg.General.BackupNameTemplate); err != nil {
+			return err
+		}
 	}
 	if err := b.CreateBackup(backupName, diffFromRemote, tablePattern, partitions, schemaOnly, backupRBAC, rbacOnly, backupConfigs, configsOnly, skipCheckPartsColumns, resume, version, commandId); err != nil {
 		return err
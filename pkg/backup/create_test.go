@@ -0,0 +1,171 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPostCreateCheckPassed(t *testing.T) {
+	testCases := []struct {
+		value          string
+		isEmpty        bool
+		expectedPassed bool
+		expectedErr    bool
+	}{
+		{value: "1", expectedPassed: true},
+		{value: "true", expectedPassed: true},
+		{value: "0", expectedPassed: false},
+		{value: "false", expectedPassed: false},
+		{isEmpty: true, expectedPassed: false},
+		{value: "42", expectedErr: true},
+	}
+	for _, tc := range testCases {
+		passed, err := isPostCreateCheckPassed(tc.value, tc.isEmpty)
+		if tc.expectedErr {
+			assert.Error(t, err, "value=%s", tc.value)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedPassed, passed, "value=%s isEmpty=%v", tc.value, tc.isEmpty)
+	}
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "metadata.json")
+	assert.NoError(t, os.WriteFile(fileName, []byte("old"), 0640))
+	assert.NoError(t, writeFileAtomically(fileName, []byte("new"), 0640))
+	content, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+	_, err = os.Stat(fileName + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, writeFileAtomically(path.Join(t.TempDir(), "not-exists", "metadata.json"), []byte("new"), 0640))
+}
+
+func TestApplyBackupNameTimeTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 5, 7, 9, 11, 0, time.UTC)
+	assert.Equal(t, "{cluster}-{shard}-{replica}-2024-03-05", applyBackupNameTimeTemplate("{cluster}-{shard}-{replica}-{date}", now))
+	assert.Equal(t, "shard{shard}-2024-03-05T07-09-11", applyBackupNameTimeTemplate("shard{shard}-{time}", now))
+	assert.Equal(t, "backup-20240305070911-2024", applyBackupNameTimeTemplate("backup-{time:20060102150405}-{time:2006}", now))
+}
+
+func TestNewBackupNameFromTemplateWithoutMacros(t *testing.T) {
+	backupName, err := NewBackupNameFromTemplate(context.Background(), nil, "")
+	assert.NoError(t, err)
+	_, err = time.Parse(TimeFormatForBackup, backupName)
+	assert.NoError(t, err)
+	backupName, err = NewBackupNameFromTemplate(context.Background(), nil, "daily {date}")
+	assert.NoError(t, err)
+	assert.Equal(t, "daily"+time.Now().UTC().Format("2006-01-02"), backupName)
+}
+
+func TestFilterPartitionIds(t *testing.T) {
+	partitionIds := []string{"202401", "202402", "202501", "all"}
+	assert.Equal(t, []string{"202402"}, filterPartitionIds(partitionIds, common.EmptyMap{"202402": struct{}{}, "202403": struct{}{}}))
+	assert.Equal(t, []string{"202401", "202402"}, filterPartitionIds(partitionIds, common.EmptyMap{"2024*": struct{}{}}))
+	assert.Equal(t, []string{}, filterPartitionIds(partitionIds, common.EmptyMap{"2023*": struct{}{}}))
+}
+
+func TestCleanShadowAfterFreeze(t *testing.T) {
+	diskPath := t.TempDir()
+	shadowPath := path.Join(diskPath, "shadow", "freeze_name", "data", "db", "table", "all_1_1_0")
+	assert.NoError(t, os.MkdirAll(shadowPath, 0755))
+	otherShadowPath := path.Join(diskPath, "shadow", "other_freeze_name")
+	assert.NoError(t, os.MkdirAll(otherShadowPath, 0755))
+	b := &Backuper{}
+	// version before 21.4 doesn't support UNFREEZE, so only directory is removed
+	b.cleanShadowAfterFreeze(context.Background(), &clickhouse.Table{Database: "db", Name: "table"}, "freeze_name", []clickhouse.Disk{{Name: "default", Path: diskPath, Type: "local"}}, 21001000)
+	_, err := os.Stat(path.Join(diskPath, "shadow", "freeze_name"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(otherShadowPath)
+	assert.NoError(t, err)
+}
+
+func TestGetUnhealthyReplicas(t *testing.T) {
+	tables := []clickhouse.Table{
+		{Database: "db", Name: "ok"},
+		{Database: "db", Name: "readonly"},
+		{Database: "db", Name: "lagged"},
+		{Database: "db", Name: "errors"},
+		{Database: "db", Name: "skipped", Skip: true},
+	}
+	replicas := []clickhouse.ReplicaHealth{
+		{Database: "db", Table: "ok", AbsoluteDelay: 10, QueueErrors: 1},
+		{Database: "db", Table: "readonly", IsReadonly: 1},
+		{Database: "db", Table: "lagged", AbsoluteDelay: 301},
+		{Database: "db", Table: "errors", QueueErrors: 2, QueueSize: 5},
+		{Database: "db", Table: "skipped", IsReadonly: 1},
+		{Database: "other", Table: "not_in_backup", IsSessionExpired: 1},
+	}
+	assert.Equal(t, []string{
+		"`db`.`readonly` is_readonly=1",
+		"`db`.`lagged` absolute_delay=301 > 300",
+		"`db`.`errors` queue_errors=2 > 1, queue_size=5",
+	}, getUnhealthyReplicas(replicas, tables, 300, 1))
+	assert.Empty(t, getUnhealthyReplicas(replicas[:1], tables, 300, 1))
+}
+
+func TestBackupAndRestoreDetachedParts(t *testing.T) {
+	diskPath := t.TempDir()
+	disks := []clickhouse.Disk{{Name: "default", Path: diskPath, Type: "local"}}
+	srcDataPath := path.Join(diskPath, "store", "abc", "src")
+	assert.NoError(t, os.MkdirAll(path.Join(srcDataPath, "detached", "broken_all_1_1_0"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(srcDataPath, "detached", "broken_all_1_1_0", "data.bin"), []byte("broken"), 0644))
+	b := &Backuper{ch: &clickhouse.ClickHouse{Config: &config.ClickHouseConfig{}}}
+	backupPath := path.Join(diskPath, "backup", "test_backup")
+	tables := []clickhouse.Table{
+		{Database: "db", Name: "src", DataPaths: []string{srcDataPath}},
+		{Database: "db", Name: "skipped", DataPaths: []string{srcDataPath}, Skip: true},
+	}
+	size, err := b.createBackupDetachedParts(context.Background(), backupPath, tables, disks)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), size)
+	_, err = os.Stat(path.Join(backupPath, detachedPartsDir, "db", "skipped"))
+	assert.True(t, os.IsNotExist(err))
+	// detached parts are hardlinked, not copied
+	srcInfo, err := os.Stat(path.Join(srcDataPath, "detached", "broken_all_1_1_0", "data.bin"))
+	assert.NoError(t, err)
+	backupInfo, err := os.Stat(path.Join(backupPath, detachedPartsDir, "db", "src", "default", "broken_all_1_1_0", "data.bin"))
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(srcInfo, backupInfo))
+
+	dstDataPath := path.Join(diskPath, "store", "def", "dst")
+	assert.NoError(t, os.MkdirAll(path.Join(dstDataPath, "detached", "ignored_all_2_2_0"), 0755))
+	dstTable := clickhouse.Table{Database: "db", Name: "dst", DataPaths: []string{dstDataPath}}
+	assert.NoError(t, b.restoreDetachedParts(backupPath, metadata.TableMetadata{Database: "db", Table: "src"}, dstTable, disks))
+	content, err := os.ReadFile(path.Join(dstDataPath, "detached", "broken_all_1_1_0", "data.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, "broken", string(content))
+	_, err = os.Stat(path.Join(dstDataPath, "detached", "ignored_all_2_2_0"))
+	assert.NoError(t, err)
+	// backup without detached parts for table
+	assert.NoError(t, b.restoreDetachedParts(backupPath, metadata.TableMetadata{Database: "db", Table: "other"}, dstTable, disks))
+}
+
+func TestSkipDataForLargeTables(t *testing.T) {
+	tables := []clickhouse.Table{
+		{Database: "db", Name: "small", TotalBytes: 100, BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "large", TotalBytes: 1001, BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "explicit", TotalBytes: 1001, BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "skipped", TotalBytes: 1001, Skip: true, BackupType: clickhouse.ShardBackupNone},
+	}
+	skipDataForLargeTables(tables, "db.*, db.explicit", 1000)
+	assert.False(t, tables[0].SkipData)
+	assert.Equal(t, clickhouse.ShardBackupType(clickhouse.ShardBackupFull), tables[0].BackupType)
+	assert.True(t, tables[1].SkipData)
+	assert.Equal(t, clickhouse.ShardBackupType(clickhouse.ShardBackupSchema), tables[1].BackupType)
+	assert.Equal(t, "total_bytes=1001 greater than max_table_data_size=1000", tables[1].SkipDataReason)
+	assert.False(t, tables[2].SkipData)
+	assert.Equal(t, clickhouse.ShardBackupType(clickhouse.ShardBackupFull), tables[2].BackupType)
+	assert.False(t, tables[3].SkipData)
+	assert.Equal(t, clickhouse.ShardBackupType(clickhouse.ShardBackupNone), tables[3].BackupType)
+}
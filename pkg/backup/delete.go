@@ -11,7 +11,11 @@ import (
 	"time"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/custom"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/events"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/storage/object_disk"
@@ -80,24 +84,164 @@ func (b *Backuper) Delete(backupType, backupName string, commandId int) error {
 	}
 }
 
-func (b *Backuper) RemoveOldBackupsLocal(ctx context.Context, keepLastBackup bool, disks []clickhouse.Disk) error {
-	keep := b.cfg.General.BackupsToKeepLocal
-	if keep == 0 {
+// DeleteDatabases - remove only selected databases from local backup, remote backups could be referenced by incremental backups, so they are not supported
+func (b *Backuper) DeleteDatabases(backupType, backupName string, databases []string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	switch backupType {
+	case "local":
+		return b.RemoveDatabasesFromBackupLocal(ctx, backupName, databases)
+	case "remote":
+		return fmt.Errorf("--database supported only for local backups, remote backup could be required by other incremental backups")
+	default:
+		return fmt.Errorf("unknown backup type")
+	}
+}
+
+// RemoveDatabasesFromBackupLocal - remove metadata and data for selected databases and rewrite metadata.json
+func (b *Backuper) RemoveDatabasesFromBackupLocal(ctx context.Context, backupName string, databases []string) error {
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	tablePattern, err := ApplyDatabaseFilter("", databases)
+	if err != nil {
+		return err
+	}
+	if tablePattern == "" {
+		return fmt.Errorf("database list is empty")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	backupList, disks, err := b.GetLocalBackups(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backupList {
+		if backup.BackupName != backupName {
+			continue
+		}
+		if backup.Broken != "" {
+			return fmt.Errorf("'%s' is broken: %s", backupName, backup.Broken)
+		}
+		if strings.Contains(backup.Tags, "embedded") || b.hasObjectDisksLocal(backupList, backupName, disks) {
+			return fmt.Errorf("--database not supported for embedded backups and backups with object disks, delete whole backup instead")
+		}
+		if err = b.checkBackupMetadataSignatureBeforeChange(&backup.BackupMetadata); err != nil {
+			return err
+		}
+		backupPath := path.Join(b.DefaultDataPath, "backup", backupName)
+		removedDatabases := map[string]struct{}{}
+		for _, t := range parseTablePatternForDownload(backup.Tables, tablePattern) {
+			removedDatabases[t.Database] = struct{}{}
+			tm := metadata.TableMetadata{}
+			metadataSize, loadErr := tm.Load(path.Join(backupPath, "metadata", common.TablePathEncode(t.Database), fmt.Sprintf("%s.json", common.TablePathEncode(t.Table))))
+			if loadErr != nil {
+				return loadErr
+			}
+			backup.MetadataSize -= min(backup.MetadataSize, metadataSize)
+			for _, size := range tm.Size {
+				backup.DataSize -= min(backup.DataSize, uint64(size))
+			}
+		}
+		for _, database := range backup.Databases {
+			for _, pattern := range strings.Split(tablePattern, ",") {
+				if matched, _ := filepath.Match(strings.TrimSuffix(pattern, ".*"), database.Name); matched {
+					removedDatabases[database.Name] = struct{}{}
+				}
+			}
+		}
+		if len(removedDatabases) == 0 {
+			return fmt.Errorf("databases %s not found in '%s'", strings.Join(databases, ","), backupName)
+		}
+		for database := range removedDatabases {
+			dirsToRemove := []string{path.Join(backupPath, "metadata", common.TablePathEncode(database))}
+			for _, disk := range disks {
+				if !disk.IsBackup {
+					dirsToRemove = append(dirsToRemove, path.Join(disk.Path, "backup", backupName, "shadow", common.TablePathEncode(database)))
+				}
+			}
+			for _, dir := range dirsToRemove {
+				log.Info().Msgf("remove '%s'", dir)
+				if err = os.RemoveAll(dir); err != nil {
+					return err
+				}
+			}
+			delete(backup.DatabaseSizes, database)
+		}
+		tables := make([]metadata.TableTitle, 0, len(backup.Tables))
+		for _, t := range backup.Tables {
+			if _, removed := removedDatabases[t.Database]; !removed {
+				tables = append(tables, t)
+			}
+		}
+		backup.Tables = tables
+		databasesMeta := make([]metadata.DatabasesMeta, 0, len(backup.Databases))
+		for _, database := range backup.Databases {
+			if _, removed := removedDatabases[database.Name]; !removed {
+				databasesMeta = append(databasesMeta, database)
+			}
+		}
+		backup.Databases = databasesMeta
+		if err = b.setTablesChecksumsLocal(&backup.BackupMetadata, backupPath); err != nil {
+			return err
+		}
+		if err = backup.BackupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+			return err
+		}
+		if err = backup.BackupMetadata.Save(path.Join(backupPath, "metadata.json")); err != nil {
+			return err
+		}
+		invalidateLocalBackupsScanCache()
+		log.Info().Str("operation", "delete").
+			Str("location", "local").
+			Str("backup", backupName).
+			Str("databases", strings.Join(databases, ",")).
+			Str("duration", utils.HumanizeDuration(time.Since(start))).
+			Msg("done")
 		return nil
 	}
+	return fmt.Errorf("'%s' is not found on local storage", backupName)
+}
+
+// localRetentionPolicy - `backups_to_keep_local` combined with `backups_to_keep_days_local` and `backups_to_keep_gfs_local`
+// negative `backups_to_keep_local` means delete local backup after upload, calendar rules are ignored in this case
+func (b *Backuper) localRetentionPolicy(keepLastBackup bool) config.RetentionPolicy {
+	keep := b.cfg.General.BackupsToKeepLocal
 	// fix https://github.com/Altinity/clickhouse-backup/issues/698
 	if keep < 0 {
 		keep = 0
 		if keepLastBackup {
 			keep = 1
 		}
+		return config.RetentionPolicy{KeepLast: keep}
+	}
+	return b.cfg.General.RetentionLocal.WithKeepLast(keep)
+}
+
+// remoteRetentionPolicy - `backups_to_keep_remote` combined with `backups_to_keep_days_remote` and `backups_to_keep_gfs_remote`
+func (b *Backuper) remoteRetentionPolicy() config.RetentionPolicy {
+	keep := b.cfg.General.BackupsToKeepRemote
+	if keep < 0 {
+		keep = 0
 	}
+	return b.cfg.General.RetentionRemote.WithKeepLast(keep)
+}
 
+func (b *Backuper) RemoveOldBackupsLocal(ctx context.Context, keepLastBackup bool, disks []clickhouse.Disk) error {
+	if b.cfg.General.BackupsToKeepLocal == 0 && !b.cfg.General.RetentionLocal.IsCalendarBased() {
+		return nil
+	}
 	backupList, disks, err := b.GetLocalBackups(ctx, disks)
 	if err != nil {
 		return err
 	}
-	backupsToDelete := GetBackupsToDeleteLocal(backupList, keep)
+	backupsToDelete := GetBackupsToDeleteLocalByPolicy(backupList, b.localRetentionPolicy(keepLastBackup), time.Now(), b.cfg.General.RetentionTimeLocation)
 	for _, backup := range backupsToDelete {
 		if deleteErr := b.RemoveBackupLocal(ctx, backup.BackupName, disks); deleteErr != nil {
 			return deleteErr
@@ -128,6 +272,9 @@ func (b *Backuper) RemoveBackupLocal(ctx context.Context, backupName string, dis
 
 	for _, backup := range backupList {
 		if backup.BackupName == backupName {
+			if backup.Pinned && !b.deletePinned {
+				return fmt.Errorf("'%s' is pinned on local storage, unpin it or use --force", backupName)
+			}
 			b.isEmbedded = strings.Contains(backup.Tags, "embedded")
 			if hasObjectDisks || (b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk == "") {
 				bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, backupName)
@@ -164,6 +311,7 @@ func (b *Backuper) RemoveBackupLocal(ctx context.Context, backupName string, dis
 				Str("backup", backupName).
 				Str("duration", utils.HumanizeDuration(time.Since(start))).
 				Msg("done")
+			events.Current.Publish(events.Deleted, backupName, "local", nil)
 			return nil
 		}
 	}
@@ -270,7 +418,11 @@ func (b *Backuper) RemoveBackupRemote(ctx context.Context, backupName string) er
 		return err
 	}
 	if b.cfg.General.RemoteStorage == "custom" {
-		return custom.DeleteRemote(ctx, b.cfg, backupName)
+		if err := custom.DeleteRemote(ctx, b.cfg, backupName); err != nil {
+			return err
+		}
+		events.Current.Publish(events.Deleted, backupName, "remote", nil)
+		return nil
 	}
 	if err := b.ch.Connect(); err != nil {
 		return fmt.Errorf("can't connect to clickhouse: %v", err)
@@ -299,6 +451,12 @@ func (b *Backuper) RemoveBackupRemote(ctx context.Context, backupName string) er
 	}
 	for _, backup := range backupList {
 		if backup.BackupName == backupName {
+			if backup.Pinned && !b.deletePinned {
+				return fmt.Errorf("'%s' is pinned on remote storage, unpin it or use --force", backupName)
+			}
+			if err = bd.CheckBackupObjectLock(ctx, backupName); err != nil {
+				return err
+			}
 			err = b.cleanEmbeddedAndObjectDiskRemoteIfSameLocalNotPresent(ctx, backup)
 			if err != nil {
 				return err
@@ -314,6 +472,7 @@ func (b *Backuper) RemoveBackupRemote(ctx context.Context, backupName string) er
 				"operation": "delete",
 				"duration":  utils.HumanizeDuration(time.Since(start)),
 			}).Msg("done")
+			events.Current.Publish(events.Deleted, backupName, "remote", nil)
 			return nil
 		}
 	}
@@ -439,6 +598,204 @@ func (b *Backuper) CleanRemoteBroken(commandId int) error {
 	return nil
 }
 
+// cleanBrokenConflictCommands - commands which write or read local backup directory, clean --broken refuses to run in parallel with them
+var cleanBrokenConflictCommands = map[string]bool{
+	"create":         true,
+	"create_remote":  true,
+	"download":       true,
+	"restore":        true,
+	"restore_remote": true,
+	"watch":          true,
+}
+
+// cleanBrokenMinAge - broken local backup modified later than this could still be written by another process
+const cleanBrokenMinAge = time.Hour
+
+// CleanLocalBroken - remove local backups without readable metadata.json or with missing table metadata and data parts, usually left by interrupted create or download, return names of removed backups, or backups which would be removed when dryRun
+func (b *Backuper) CleanLocalBroken(dryRun bool, commandId int) ([]string, error) {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	localBackups, disks, err := b.GetLocalBackups(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// metadata.json is written at the end of create and download, so backup which is still in progress looks broken
+	for _, row := range status.Current.GetStatus(false, "", 0) {
+		if row.Status != status.InProgressStatus {
+			continue
+		}
+		if commandName := strings.Fields(row.Command); len(commandName) > 0 && cleanBrokenConflictCommands[commandName[0]] {
+			return nil, fmt.Errorf("can't clean broken local backups, `%s` is in progress", row.Command)
+		}
+	}
+	brokenBackups := make([]string, 0)
+	for _, backup := range localBackups {
+		reason := backup.Broken
+		if reason == "" {
+			reason = findLocalBackupMissingFiles(backup.BackupMetadata, disks)
+		}
+		if reason == "" {
+			continue
+		}
+		// create or download could run in another clickhouse-backup process
+		if modifiedAt := localBackupLastModified(backup.BackupName, disks); time.Since(modifiedAt) < cleanBrokenMinAge {
+			log.Warn().Str("backup", backup.BackupName).Msgf("skip clean broken, backup was modified at %s less than %s ago: %s", modifiedAt.Format(time.RFC3339), cleanBrokenMinAge, reason)
+			continue
+		}
+		if backup.Pinned && !b.deletePinned {
+			log.Warn().Str("backup", backup.BackupName).Msgf("skip clean broken, backup is pinned: %s", reason)
+			continue
+		}
+		log.Info().Str("backup", backup.BackupName).Bool("dry_run", dryRun).Msgf("broken local backup: %s", reason)
+		brokenBackups = append(brokenBackups, backup.BackupName)
+	}
+	if dryRun {
+		return brokenBackups, nil
+	}
+	for i, backupName := range brokenBackups {
+		if err = b.RemoveBackupLocal(ctx, backupName, disks); err != nil {
+			return brokenBackups[:i], err
+		}
+	}
+	log.Info().Str("operation", "clean").Str("location", "local").Msgf("deleted %d broken backups: %s", len(brokenBackups), strings.Join(brokenBackups, ","))
+	return brokenBackups, nil
+}
+
+// localBackupLastModified - return latest modification time of backup directory and its subdirectories on all disks
+func localBackupLastModified(backupName string, disks []clickhouse.Disk) time.Time {
+	lastModified := time.Time{}
+	for _, disk := range disks {
+		if disk.IsBackup {
+			continue
+		}
+		_ = filepath.WalkDir(path.Join(disk.Path, "backup", backupName), func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if info, infoErr := d.Info(); infoErr == nil && info.ModTime().After(lastModified) {
+				lastModified = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return lastModified
+}
+
+// findLocalBackupMissingFiles - return description of the first missing table metadata file or data part, parts with `required` flag are stored in required backup, embedded backups have different layout and are not checked
+func findLocalBackupMissingFiles(backupMetadata metadata.BackupMetadata, disks []clickhouse.Disk) string {
+	if strings.Contains(backupMetadata.Tags, "embedded") {
+		return ""
+	}
+	backupPaths := make([]string, 0, len(disks))
+	for _, disk := range disks {
+		if !disk.IsBackup {
+			backupPaths = append(backupPaths, path.Join(disk.Path, "backup", backupMetadata.BackupName))
+		}
+	}
+	fileExists := func(relativePath string) bool {
+		for _, backupPath := range backupPaths {
+			if _, err := os.Stat(path.Join(backupPath, relativePath)); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	for _, table := range backupMetadata.Tables {
+		dbAndTablePath := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+		tableMetadataPath := path.Join("metadata", dbAndTablePath+".json")
+		if !fileExists(tableMetadataPath) {
+			return fmt.Sprintf("%s not found", tableMetadataPath)
+		}
+		tableMetadata := metadata.TableMetadata{}
+		for _, backupPath := range backupPaths {
+			if _, err := tableMetadata.Load(path.Join(backupPath, tableMetadataPath)); err == nil {
+				break
+			}
+		}
+		for disk, parts := range tableMetadata.Parts {
+			for _, part := range parts {
+				if part.Required {
+					continue
+				}
+				partPath := path.Join("shadow", dbAndTablePath, disk, part.Name)
+				if !fileExists(partPath) {
+					return fmt.Sprintf("%s not found", partPath)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// CleanRetention - apply backups_to_keep_local or backups_to_keep_remote immediately, return names of deleted backups, or backups which would be deleted when dryRun
+func (b *Backuper) CleanRetention(location string, dryRun bool, commandId int) ([]string, error) {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	backupsToDelete := make([]string, 0)
+	switch location {
+	case "local":
+		if b.cfg.General.BackupsToKeepLocal == 0 && !b.cfg.General.RetentionLocal.IsCalendarBased() {
+			return backupsToDelete, nil
+		}
+		localBackups, _, err := b.GetLocalBackups(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		// negative `backups_to_keep_local` means delete local backups after upload, on demand cleaning keeps last backup the same as after create
+		for _, backup := range GetBackupsToDeleteLocalByPolicy(localBackups, b.localRetentionPolicy(true), time.Now(), b.cfg.General.RetentionTimeLocation) {
+			backupsToDelete = append(backupsToDelete, backup.BackupName)
+		}
+		if dryRun {
+			return backupsToDelete, nil
+		}
+		for i, backupName := range backupsToDelete {
+			if err = b.RemoveBackupLocal(ctx, backupName, nil); err != nil {
+				return backupsToDelete[:i], err
+			}
+		}
+	case "remote":
+		if !b.remoteRetentionPolicy().IsEnabled() {
+			return backupsToDelete, nil
+		}
+		remoteBackups, err := b.GetRemoteBackups(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, backup := range storage.GetBackupsToDeleteRemoteByPolicy(remoteBackups, b.remoteRetentionPolicy(), time.Now(), b.cfg.General.RetentionTimeLocation) {
+			backupsToDelete = append(backupsToDelete, backup.BackupName)
+		}
+		if dryRun {
+			return backupsToDelete, nil
+		}
+		deletedBackups := make([]string, 0, len(backupsToDelete))
+		for _, backupName := range backupsToDelete {
+			if err = b.RemoveBackupRemote(ctx, backupName); err != nil {
+				if errors.Is(err, storage.ErrObjectLocked) {
+					log.Warn().Msgf("skip retention: %v", err)
+					continue
+				}
+				return deletedBackups, err
+			}
+			deletedBackups = append(deletedBackups, backupName)
+		}
+		backupsToDelete = deletedBackups
+	default:
+		return nil, fmt.Errorf("location must be 'local' or 'remote'")
+	}
+	log.Info().Str("operation", "clean").Str("location", location).Msgf("retention deleted %d backups: %s", len(backupsToDelete), strings.Join(backupsToDelete, ","))
+	return backupsToDelete, nil
+}
+
 func (b *Backuper) cleanPartialRequiredBackup(ctx context.Context, disks []clickhouse.Disk, currentBackupName string) error {
 	if localBackups, _, err := b.GetLocalBackups(ctx, disks); err == nil {
 		for _, localBackup := range localBackups {
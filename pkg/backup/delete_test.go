@@ -4,6 +4,11 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCleanDir(t *testing.T) {
@@ -35,3 +40,46 @@ func TestCleanDir(t *testing.T) {
 		},
 	)
 }
+
+func TestFindLocalBackupMissingFiles(t *testing.T) {
+	disks := []clickhouse.Disk{{Name: "default", Path: t.TempDir()}, {Name: "hot", Path: t.TempDir()}}
+	backupPath := path.Join(disks[0].Path, "backup", "partial")
+	tableMetadata := metadata.TableMetadata{
+		Database: "db",
+		Table:    "t",
+		Parts: map[string][]metadata.Part{
+			"default": {{Name: "all_1_1_0"}, {Name: "all_2_2_0", Required: true}},
+			"hot":     {{Name: "all_3_3_0"}},
+		},
+	}
+	_, err := tableMetadata.Save(path.Join(backupPath, "metadata", "db", "t.json"), false)
+	assert.NoError(t, err)
+	backupMetadata := metadata.BackupMetadata{BackupName: "partial", Tables: []metadata.TableTitle{{Database: "db", Table: "t"}}}
+
+	assert.NoError(t, os.MkdirAll(path.Join(backupPath, "shadow", "db", "t", "default", "all_1_1_0"), 0755))
+	assert.Equal(t, "shadow/db/t/hot/all_3_3_0 not found", findLocalBackupMissingFiles(backupMetadata, disks))
+
+	assert.NoError(t, os.MkdirAll(path.Join(disks[1].Path, "backup", "partial", "shadow", "db", "t", "hot", "all_3_3_0"), 0755))
+	assert.Equal(t, "", findLocalBackupMissingFiles(backupMetadata, disks))
+
+	backupMetadata.Tables = append(backupMetadata.Tables, metadata.TableTitle{Database: "db", Table: "absent"})
+	assert.Equal(t, "metadata/db/absent.json not found", findLocalBackupMissingFiles(backupMetadata, disks))
+	backupMetadata.Tags = "embedded"
+	assert.Equal(t, "", findLocalBackupMissingFiles(backupMetadata, disks))
+}
+
+func TestLocalBackupLastModified(t *testing.T) {
+	disks := []clickhouse.Disk{{Name: "default", Path: t.TempDir()}, {Name: "hot", Path: t.TempDir()}}
+	assert.True(t, localBackupLastModified("absent", disks).IsZero())
+
+	oldTime := time.Now().Add(-2 * cleanBrokenMinAge)
+	partPath := path.Join(disks[1].Path, "backup", "partial", "shadow", "db", "t", "hot", "all_1_1_0")
+	assert.NoError(t, os.MkdirAll(partPath, 0755))
+	for p := partPath; p != disks[1].Path; p = path.Dir(p) {
+		assert.NoError(t, os.Chtimes(p, oldTime, oldTime))
+	}
+	assert.True(t, time.Since(localBackupLastModified("partial", disks)) > cleanBrokenMinAge)
+
+	assert.NoError(t, os.WriteFile(path.Join(partPath, "data.bin"), []byte("data"), 0644))
+	assert.True(t, time.Since(localBackupLastModified("partial", disks)) < cleanBrokenMinAge)
+}
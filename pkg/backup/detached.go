@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	recursiveCopy "github.com/otiai10/copy"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+// detachedPartsDir - `detached` folders of tables are stored as `detached/<db>/<table>/<disk>/<part>` inside backup directory, independent of `shadow`, so these parts are never attached during restore
+const detachedPartsDir = "detached"
+
+// getObjectDiskNames - detached parts on object disks contain only references to remote objects, they could be removed by ClickHouse any time, so they are skipped
+func (b *Backuper) getObjectDiskNames(disks []clickhouse.Disk) common.EmptyMap {
+	objectDisks := common.EmptyMap{}
+	for _, disk := range disks {
+		if b.isDiskTypeObject(disk.Type) || b.isDiskTypeEncryptedObject(disk, disks) {
+			objectDisks[disk.Name] = struct{}{}
+		}
+	}
+	return objectDisks
+}
+
+// createBackupDetachedParts - `--include-detached`, hardlink `detached` folder content for each table with data in backup
+func (b *Backuper) createBackupDetachedParts(ctx context.Context, backupPath string, tables []clickhouse.Table, disks []clickhouse.Disk) (uint64, error) {
+	detachedSize := uint64(0)
+	objectDisks := b.getObjectDiskNames(disks)
+	for _, table := range tables {
+		if table.Skip || table.SkipData {
+			continue
+		}
+		dbAndTableDir := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Name))
+		for diskName, dataPath := range clickhouse.GetDisksByPaths(disks, table.DataPaths) {
+			select {
+			case <-ctx.Done():
+				return detachedSize, ctx.Err()
+			default:
+			}
+			if _, isObjectDisk := objectDisks[diskName]; isObjectDisk {
+				log.Warn().Msgf("skip detached parts for `%s`.`%s` on object disk %s", table.Database, table.Name, diskName)
+				continue
+			}
+			srcDetachedDir := path.Join(dataPath, "detached")
+			detachedParts, err := os.ReadDir(srcDetachedDir)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return detachedSize, err
+			}
+			if len(detachedParts) == 0 {
+				continue
+			}
+			dstDetachedDir := path.Join(backupPath, detachedPartsDir, dbAndTableDir, diskName)
+			log.Debug().Msgf("link %s -> %s", srcDetachedDir, dstDetachedDir)
+			linkedSize, err := linkDetachedParts(srcDetachedDir, dstDetachedDir)
+			detachedSize += linkedSize
+			if err != nil {
+				return detachedSize, fmt.Errorf("can't link detached parts for `%s`.`%s`: %v", table.Database, table.Name, err)
+			}
+			log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Str("disk", diskName).Int("parts", len(detachedParts)).Msg("detached parts linked")
+		}
+	}
+	if detachedSize > 0 {
+		if err := filesystemhelper.Chown(path.Join(backupPath, detachedPartsDir), b.ch, disks, true); err != nil {
+			return detachedSize, err
+		}
+	}
+	return detachedSize, nil
+}
+
+// restoreDetachedParts - `--include-detached`, put parts back into `detached` folder of destination table, already existing detached parts are kept
+func (b *Backuper) restoreDetachedParts(backupPath string, backupTable metadata.TableMetadata, dstTable clickhouse.Table, disks []clickhouse.Disk) error {
+	dbAndTableDir := path.Join(common.TablePathEncode(backupTable.Database), common.TablePathEncode(backupTable.Table))
+	backupDetachedDir := path.Join(backupPath, detachedPartsDir, dbAndTableDir)
+	backupDisks, err := os.ReadDir(backupDetachedDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	dstDataPaths := clickhouse.GetDisksByPaths(disks, dstTable.DataPaths)
+	for _, backupDisk := range backupDisks {
+		dstDataPath, exists := dstDataPaths[backupDisk.Name()]
+		if !exists {
+			log.Warn().Msgf("disk %s not found for `%s`.`%s`, detached parts skipped", backupDisk.Name(), dstTable.Database, dstTable.Name)
+			continue
+		}
+		dstDetachedDir := path.Join(dstDataPath, "detached")
+		if err = filesystemhelper.MkdirAll(dstDetachedDir, b.ch, disks); err != nil {
+			return err
+		}
+		detachedParts, err := os.ReadDir(path.Join(backupDetachedDir, backupDisk.Name()))
+		if err != nil {
+			return err
+		}
+		for _, part := range detachedParts {
+			dstPartPath := path.Join(dstDetachedDir, part.Name())
+			if _, err = os.Stat(dstPartPath); err == nil {
+				log.Warn().Msgf("%s already exists, skip restore detached part", dstPartPath)
+				continue
+			}
+			if _, err = linkDetachedParts(path.Join(backupDetachedDir, backupDisk.Name(), part.Name()), dstPartPath); err != nil {
+				return fmt.Errorf("can't restore detached part %s: %v", dstPartPath, err)
+			}
+			if err = filesystemhelper.Chown(dstPartPath, b.ch, disks, true); err != nil {
+				return err
+			}
+		}
+		log.Info().Str("table", fmt.Sprintf("%s.%s", dstTable.Database, dstTable.Name)).Str("disk", backupDisk.Name()).Int("parts", len(detachedParts)).Msg("detached parts restored")
+	}
+	return nil
+}
+
+// linkDetachedParts - hardlink files like FREEZE does for regular parts, copy only when `detached` folder is not on the same filesystem with backup, return size of linked files
+func linkDetachedParts(srcDir, dstDir string) (uint64, error) {
+	size := uint64(0)
+	err := filepath.Walk(srcDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		dstFilePath := path.Join(dstDir, strings.TrimPrefix(filePath, srcDir))
+		if info.IsDir() {
+			return os.MkdirAll(dstFilePath, 0750)
+		}
+		if !info.Mode().IsRegular() {
+			log.Debug().Msgf("'%s' is not a regular file, skipping", filePath)
+			return nil
+		}
+		size += uint64(info.Size())
+		if linkErr := os.Link(filePath, dstFilePath); linkErr != nil {
+			if !errors.Is(linkErr, syscall.EXDEV) {
+				return linkErr
+			}
+			log.Debug().Msgf("can't link %s -> %s across filesystems, copy", filePath, dstFilePath)
+			return recursiveCopy.Copy(filePath, dstFilePath)
+		}
+		return nil
+	})
+	return size, err
+}
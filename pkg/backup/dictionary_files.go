@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	recursiveCopy "github.com/otiai10/copy"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+// dictionaryFilesDir - local files referenced by `SOURCE(FILE(PATH ...))` are stored with full original path inside this backup directory
+const dictionaryFilesDir = "dictionaries"
+
+var dictionaryFileSourceRE = regexp.MustCompile(`(?i)SOURCE\s*\(\s*FILE\s*\(\s*PATH\s+'((?:[^'\\]|\\.)+)'`)
+
+// extractDictionaryFilePath - absolute path of local file source for CREATE DICTIONARY query, relative path is resolved from user_files_path, empty when dictionary has another source
+// DDL in table metadata is not signed, so path outside user_files_path is an error, clickhouse-server also doesn't allow such dictionary sources
+func extractDictionaryFilePath(query, userFilesPath string) (string, error) {
+	if !strings.HasPrefix(query, "CREATE DICTIONARY") && !strings.HasPrefix(query, "ATTACH DICTIONARY") {
+		return "", nil
+	}
+	matches := dictionaryFileSourceRE.FindStringSubmatch(query)
+	if len(matches) < 2 {
+		return "", nil
+	}
+	filePath := strings.ReplaceAll(matches[1], `\'`, `'`)
+	if !path.IsAbs(filePath) {
+		filePath = path.Join(userFilesPath, filePath)
+	}
+	filePath = path.Clean(filePath)
+	userFilesPath = path.Clean(userFilesPath)
+	if !strings.HasPrefix(filePath, userFilesPath+"/") {
+		return "", fmt.Errorf("dictionary source file %s is outside of %s", filePath, userFilesPath)
+	}
+	return filePath, nil
+}
+
+// hasDictionaryFiles - download `dictionaries` only when at least one downloaded dictionary has local file source
+func hasDictionaryFiles(tables []*metadata.TableMetadata, userFilesPath string) bool {
+	for _, table := range tables {
+		if table == nil {
+			continue
+		}
+		if filePath, _ := extractDictionaryFilePath(table.Query, userFilesPath); filePath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getUserFilesPath - `user_files_path` has `<path>/user_files/` as default value, clickhouse-backup can't read server config for each version, so default is used
+func (b *Backuper) getUserFilesPath() string {
+	return path.Join(b.DefaultDataPath, "user_files")
+}
+
+// createBackupDictionaryFiles - `clickhouse->backup_dictionary_files`, copy local source files for backed up dictionaries
+func (b *Backuper) createBackupDictionaryFiles(ctx context.Context, backupPath string, tables []clickhouse.Table, disks []clickhouse.Disk) (uint64, error) {
+	dictionaryFilesSize := uint64(0)
+	userFilesPath := b.getUserFilesPath()
+	for _, table := range tables {
+		if table.Skip {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return dictionaryFilesSize, ctx.Err()
+		default:
+		}
+		filePath, err := extractDictionaryFilePath(table.CreateTableQuery, userFilesPath)
+		if err != nil {
+			log.Warn().Msgf("can't backup source file for dictionary `%s`.`%s`: %v", table.Database, table.Name, err)
+			continue
+		}
+		if filePath == "" {
+			continue
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			log.Warn().Msgf("can't backup source file %s for dictionary `%s`.`%s`: %v", filePath, table.Database, table.Name, err)
+			continue
+		}
+		if info.IsDir() {
+			log.Warn().Msgf("can't backup source %s for dictionary `%s`.`%s`: is a dir", filePath, table.Database, table.Name)
+			continue
+		}
+		dst := path.Join(backupPath, dictionaryFilesDir, filePath)
+		log.Debug().Msgf("copy %s -> %s", filePath, dst)
+		if err = recursiveCopy.Copy(filePath, dst); err != nil {
+			return dictionaryFilesSize, fmt.Errorf("can't copy source file %s for dictionary `%s`.`%s`: %v", filePath, table.Database, table.Name, err)
+		}
+		dictionaryFilesSize += uint64(info.Size())
+	}
+	if dictionaryFilesSize > 0 {
+		if err := filesystemhelper.Chown(path.Join(backupPath, dictionaryFilesDir), b.ch, disks, true); err != nil {
+			return dictionaryFilesSize, err
+		}
+	}
+	return dictionaryFilesSize, nil
+}
+
+// restoreDictionaryFiles - copy local source files back before CREATE DICTIONARY, only for restored dictionaries, existing files are overwritten
+func (b *Backuper) restoreDictionaryFiles(backupPath string, tablesForRestore ListOfTables, disks []clickhouse.Disk) error {
+	userFilesPath := b.getUserFilesPath()
+	restoredFiles := 0
+	for _, table := range tablesForRestore {
+		filePath, err := extractDictionaryFilePath(table.Query, userFilesPath)
+		if err != nil {
+			log.Warn().Msgf("can't restore source file for dictionary `%s`.`%s`: %v", table.Database, table.Table, err)
+			continue
+		}
+		if filePath == "" {
+			continue
+		}
+		src := path.Join(backupPath, dictionaryFilesDir, filePath)
+		if _, err = os.Stat(src); err != nil {
+			if os.IsNotExist(err) {
+				log.Debug().Msgf("%s not found in backup, source file for dictionary `%s`.`%s` is not restored", src, table.Database, table.Table)
+				continue
+			}
+			return err
+		}
+		log.Debug().Msgf("copy %s -> %s", src, filePath)
+		if err = recursiveCopy.Copy(src, filePath); err != nil {
+			return fmt.Errorf("can't restore source file %s for dictionary `%s`.`%s`: %v", filePath, table.Database, table.Table, err)
+		}
+		if err = filesystemhelper.Chown(filePath, b.ch, disks, false); err != nil {
+			return err
+		}
+		restoredFiles++
+	}
+	if restoredFiles > 0 {
+		log.Info().Msgf("%d dictionary source files successfully restored", restoredFiles)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	schemaDiffAdded   = "added"
+	schemaDiffRemoved = "removed"
+	schemaDiffChanged = "changed"
+)
+
+// schemaDiff - one difference between backup and clickhouse-server schema, empty Column means whole table
+type schemaDiff struct {
+	Database string
+	Table    string
+	Column   string
+	Change   string
+	Backup   string
+	Server   string
+}
+
+type schemaColumn struct {
+	Name       string
+	Definition string
+}
+
+// DiffSchema - compare tables and columns from local backup with current clickhouse-server schema, `added` means present only on server, `removed` means present only in backup
+func (b *Backuper) DiffSchema(backupName, tablePattern string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("backup name must be defined")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return err
+	}
+	if b.DefaultDataPath, err = b.ch.GetDefaultPath(disks); err != nil {
+		return ErrUnknownClickhouseDataPath
+	}
+	if b.EmbeddedBackupDataPath, err = b.ch.GetEmbeddedBackupPath(disks); err != nil {
+		return err
+	}
+	backupMetadata, err := b.ReadBackupMetadataLocal(ctx, backupName)
+	if err != nil {
+		return err
+	}
+	b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
+	metadataPath := path.Join(b.DefaultDataPath, "backup", backupName, "metadata")
+	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		metadataPath = path.Join(b.EmbeddedBackupDataPath, backupName, "metadata")
+	}
+	backupTables, _, err := b.getTableListByPatternLocal(ctx, metadataPath, tablePattern, false, nil)
+	if err != nil {
+		return err
+	}
+	serverTables, err := b.GetTables(ctx, tablePattern)
+	if err != nil {
+		return err
+	}
+	diffs := diffTablesSchema(backupTables, serverTables)
+	log.Info().Str("backup", backupName).Int("differences", len(diffs)).Msg("diff-schema")
+	return printSchemaDiff(os.Stdout, diffs)
+}
+
+// diffTablesSchema - columns are compared by definition text from CREATE queries, both queries are formatted by clickhouse-server
+func diffTablesSchema(backupTables ListOfTables, serverTables []clickhouse.Table) []schemaDiff {
+	diffs := make([]schemaDiff, 0)
+	serverQueries := make(map[metadata.TableTitle]string, len(serverTables))
+	for _, t := range serverTables {
+		if t.Skip {
+			continue
+		}
+		serverQueries[metadata.TableTitle{Database: t.Database, Table: t.Name}] = t.CreateTableQuery
+	}
+	for _, t := range backupTables {
+		title := metadata.TableTitle{Database: t.Database, Table: t.Table}
+		serverQuery, exists := serverQueries[title]
+		if !exists {
+			diffs = append(diffs, schemaDiff{Database: t.Database, Table: t.Table, Change: schemaDiffRemoved})
+			continue
+		}
+		delete(serverQueries, title)
+		diffs = append(diffs, diffColumns(t.Database, t.Table, extractColumnsFromCreateQuery(t.Query), extractColumnsFromCreateQuery(serverQuery))...)
+	}
+	for title := range serverQueries {
+		diffs = append(diffs, schemaDiff{Database: title.Database, Table: title.Table, Change: schemaDiffAdded})
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Database != diffs[j].Database {
+			return diffs[i].Database < diffs[j].Database
+		}
+		return diffs[i].Table < diffs[j].Table
+	})
+	return diffs
+}
+
+func diffColumns(database, table string, backupColumns, serverColumns []schemaColumn) []schemaDiff {
+	diffs := make([]schemaDiff, 0)
+	serverDefinitions := make(map[string]string, len(serverColumns))
+	for _, c := range serverColumns {
+		serverDefinitions[c.Name] = c.Definition
+	}
+	backupDefinitions := make(map[string]string, len(backupColumns))
+	for _, c := range backupColumns {
+		backupDefinitions[c.Name] = c.Definition
+		serverDefinition, exists := serverDefinitions[c.Name]
+		if !exists {
+			diffs = append(diffs, schemaDiff{Database: database, Table: table, Column: c.Name, Change: schemaDiffRemoved, Backup: c.Definition})
+		} else if serverDefinition != c.Definition {
+			diffs = append(diffs, schemaDiff{Database: database, Table: table, Column: c.Name, Change: schemaDiffChanged, Backup: c.Definition, Server: serverDefinition})
+		}
+	}
+	for _, c := range serverColumns {
+		if _, exists := backupDefinitions[c.Name]; !exists {
+			diffs = append(diffs, schemaDiff{Database: database, Table: table, Column: c.Name, Change: schemaDiffAdded, Server: c.Definition})
+		}
+	}
+	return diffs
+}
+
+func printSchemaDiff(out io.Writer, diffs []schemaDiff) error {
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintln(out, "no differences")
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.DiscardEmptyColumns)
+	for _, d := range diffs {
+		object := "table"
+		if d.Column != "" {
+			object = fmt.Sprintf("column `%s`", d.Column)
+		}
+		details := ""
+		switch {
+		case d.Backup != "" && d.Server != "":
+			details = fmt.Sprintf("backup: %s, server: %s", d.Backup, d.Server)
+		case d.Backup != "":
+			details = fmt.Sprintf("backup: %s", d.Backup)
+		case d.Server != "":
+			details = fmt.Sprintf("server: %s", d.Server)
+		}
+		if _, err := fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\n", d.Database, d.Table, d.Change, object, details); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// extractColumnsFromCreateQuery - parse column list of CREATE TABLE or CREATE VIEW query, INDEX, PROJECTION and CONSTRAINT elements are ignored
+func extractColumnsFromCreateQuery(query string) []schemaColumn {
+	columns := make([]schemaColumn, 0)
+	start := strings.Index(query, "(")
+	if start < 0 {
+		return columns
+	}
+	// column list shall be before AS SELECT and ENGINE clauses
+	prefix := strings.ToUpper(query[:start])
+	if strings.Contains(prefix, " AS ") || strings.Contains(prefix, " ENGINE") {
+		return columns
+	}
+	for _, element := range splitTopLevelElements(query[start+1:]) {
+		element = strings.TrimSpace(element)
+		upperElement := strings.ToUpper(element)
+		if element == "" || strings.HasPrefix(upperElement, "INDEX ") || strings.HasPrefix(upperElement, "PROJECTION ") || strings.HasPrefix(upperElement, "CONSTRAINT ") || strings.HasPrefix(upperElement, "PRIMARY KEY") {
+			continue
+		}
+		var name, definition string
+		if strings.HasPrefix(element, "`") {
+			end := strings.Index(element[1:], "`")
+			if end < 0 {
+				continue
+			}
+			name, definition = element[1:end+1], element[end+2:]
+		} else if idx := strings.IndexAny(element, " \t\n"); idx > 0 {
+			name, definition = element[:idx], element[idx:]
+		} else {
+			name = element
+		}
+		columns = append(columns, schemaColumn{Name: name, Definition: strings.Join(strings.Fields(definition), " ")})
+	}
+	return columns
+}
+
+// splitTopLevelElements - split comma separated list until closing bracket, commas inside nested brackets and quoted strings are skipped
+func splitTopLevelElements(s string) []string {
+	elements := make([]string, 0)
+	depth := 0
+	var quote byte
+	begin := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return append(elements, s[begin:i])
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				elements = append(elements, s[begin:i])
+				begin = i + 1
+			}
+		}
+	}
+	return append(elements, s[begin:])
+}
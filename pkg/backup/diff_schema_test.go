@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractColumnsFromCreateQuery(t *testing.T) {
+	query := "CREATE TABLE db.t UUID 'f5a4c6a2-9b0a-4a4e-8a4e-2c0a2f0a0a0a' (`id` UInt64, `name` String DEFAULT 'a,b', `tags` Map(String, Array(UInt8)) CODEC(ZSTD(1)), INDEX idx_name name TYPE bloom_filter GRANULARITY 1, PROJECTION by_name (SELECT * ORDER BY name)) ENGINE = MergeTree ORDER BY id"
+	assert.Equal(t, []schemaColumn{
+		{Name: "id", Definition: "UInt64"},
+		{Name: "name", Definition: "String DEFAULT 'a,b'"},
+		{Name: "tags", Definition: "Map(String, Array(UInt8)) CODEC(ZSTD(1))"},
+	}, extractColumnsFromCreateQuery(query))
+	assert.Equal(t, []schemaColumn{{Name: "id", Definition: "UInt64"}}, extractColumnsFromCreateQuery("CREATE MATERIALIZED VIEW db.mv TO db.t (`id` UInt64) AS SELECT id FROM db.src"))
+	assert.Empty(t, extractColumnsFromCreateQuery("CREATE DICTIONARY db.d AS SELECT (1)"))
+	assert.Empty(t, extractColumnsFromCreateQuery(""))
+}
+
+func TestDiffTablesSchema(t *testing.T) {
+	backupTables := ListOfTables{
+		{Database: "db", Table: "events", Query: "CREATE TABLE db.events (`id` UInt64, `value` UInt32, `old` String) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Table: "dropped", Query: "CREATE TABLE db.dropped (`id` UInt64) ENGINE = MergeTree ORDER BY id"},
+	}
+	serverTables := []clickhouse.Table{
+		{Database: "db", Name: "events", CreateTableQuery: "CREATE TABLE db.events (`id` UInt64, `value` UInt64, `new` String) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Name: "created", CreateTableQuery: "CREATE TABLE db.created (`id` UInt64) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Name: "skipped", Skip: true},
+	}
+	diffs := diffTablesSchema(backupTables, serverTables)
+	assert.Equal(t, []schemaDiff{
+		{Database: "db", Table: "created", Change: schemaDiffAdded},
+		{Database: "db", Table: "dropped", Change: schemaDiffRemoved},
+		{Database: "db", Table: "events", Column: "value", Change: schemaDiffChanged, Backup: "UInt32", Server: "UInt64"},
+		{Database: "db", Table: "events", Column: "old", Change: schemaDiffRemoved, Backup: "String"},
+		{Database: "db", Table: "events", Column: "new", Change: schemaDiffAdded, Server: "String"},
+	}, diffs)
+
+	out := &strings.Builder{}
+	assert.NoError(t, printSchemaDiff(out, diffs))
+	assert.Contains(t, out.String(), "db.events   changed  column `value`  backup: UInt32, server: UInt64")
+	out.Reset()
+	assert.NoError(t, printSchemaDiff(out, nil))
+	assert.Equal(t, "no differences\n", out.String())
+}
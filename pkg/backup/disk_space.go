@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// checkDisksFreeSpace - fail fast when local disk doesn't have free space for requiredSpace[disk.Name] plus general->free_space_reserve
+// object disks are skipped, data for them stored in object storage
+func (b *Backuper) checkDisksFreeSpace(command string, disks []clickhouse.Disk, requiredSpace map[string]uint64) error {
+	reserve := b.cfg.General.FreeSpaceReserveBytes
+	for _, disk := range disks {
+		if disk.Type != "local" {
+			continue
+		}
+		required := requiredSpace[disk.Name] + reserve
+		if required == 0 {
+			continue
+		}
+		if disk.FreeSpace < required {
+			return fmt.Errorf("%s: not enough free space on disk `%s` (%s), available %s, required %s plus free_space_reserve %s", command, disk.Name, disk.Path, utils.FormatBytes(disk.FreeSpace), utils.FormatBytes(requiredSpace[disk.Name]), utils.FormatBytes(reserve))
+		}
+		log.Debug().Str("command", command).Str("disk", disk.Name).Msgf("free space %s, required %s plus free_space_reserve %s", utils.FormatBytes(disk.FreeSpace), utils.FormatBytes(requiredSpace[disk.Name]), utils.FormatBytes(reserve))
+	}
+	return nil
+}
+
+// calculateDownloadRequiredSpace - size of parts which will download into each disk, rebalanced parts counted on new disk
+// when part size is not present in metadata, table size on disk is used, archives are extracted on the fly into the same disk, so no additional space is required for them
+func calculateDownloadRequiredSpace(tables []*metadata.TableMetadata) map[string]uint64 {
+	requiredSpace := make(map[string]uint64)
+	for _, t := range tables {
+		if t == nil || t.MetadataOnly {
+			continue
+		}
+		for disk, parts := range t.Parts {
+			unknownPartSize := false
+			for _, p := range parts {
+				if p.Size <= 0 {
+					unknownPartSize = true
+					continue
+				}
+				targetDisk := disk
+				if p.RebalancedDisk != "" {
+					targetDisk = p.RebalancedDisk
+				}
+				requiredSpace[targetDisk] += uint64(p.Size)
+			}
+			if unknownPartSize && t.Size[disk] > 0 {
+				requiredSpace[disk] += uint64(t.Size[disk])
+			}
+		}
+	}
+	return requiredSpace
+}
+
+// checkRestoreFreeSpace - ATTACH hardlinks parts from backup, so only general->free_space_reserve is required for merges after ATTACH,
+// except parts which will be copied into ClickHouse data disks, look calculateRestoreRequiredSpace
+func (b *Backuper) checkRestoreFreeSpace(ctx context.Context, tablesForRestore ListOfTables) error {
+	// disks added by `clickhouse->disk_mapping` are not present in system.disks and don't have free_space
+	disks, err := b.ch.GetDisks(ctx, false)
+	if err != nil {
+		return err
+	}
+	return b.checkDisksFreeSpace("restore", disks, calculateRestoreRequiredSpace(tablesForRestore, b.cfg.ClickHouse.DiskMapping))
+}
+
+// calculateRestoreRequiredSpace - size of parts which will be copied during restore into each disk
+// backup of disk from `clickhouse->disk_mapping` is placed outside ClickHouse disk path, and could be on other filesystem, so parts can't be hardlinked and will be copied,
+// `clickhouse->storage_policy_mapping` parts are rebalanced into disks of new storage policy during download and hardlinked
+func calculateRestoreRequiredSpace(tables ListOfTables, diskMapping map[string]string) map[string]uint64 {
+	requiredSpace := make(map[string]uint64)
+	if len(diskMapping) == 0 {
+		return requiredSpace
+	}
+	for _, t := range tables {
+		if t.MetadataOnly {
+			continue
+		}
+		for disk, parts := range t.Parts {
+			unknownPartSize := false
+			for _, p := range parts {
+				targetDisk := disk
+				if p.RebalancedDisk != "" {
+					targetDisk = p.RebalancedDisk
+				}
+				if _, isMapped := diskMapping[targetDisk]; !isMapped {
+					continue
+				}
+				if p.Size <= 0 {
+					unknownPartSize = true
+					continue
+				}
+				requiredSpace[targetDisk] += uint64(p.Size)
+			}
+			if unknownPartSize && t.Size[disk] > 0 {
+				requiredSpace[disk] += uint64(t.Size[disk])
+			}
+		}
+	}
+	return requiredSpace
+}
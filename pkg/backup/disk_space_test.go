@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateDownloadRequiredSpace(t *testing.T) {
+	tables := []*metadata.TableMetadata{
+		nil,
+		{
+			Parts: map[string][]metadata.Part{
+				"default": {{Name: "all_1_1_0", Size: 100}, {Name: "all_2_2_0", Size: 50, RebalancedDisk: "hot"}},
+				"cold":    {{Name: "all_3_3_0"}},
+			},
+			Size: map[string]int64{"default": 150, "cold": 1000},
+		},
+		{MetadataOnly: true, Parts: map[string][]metadata.Part{"default": {{Name: "all_1_1_0", Size: 10000}}}},
+	}
+	assert.Equal(t, map[string]uint64{"default": 100, "hot": 50, "cold": 1000}, calculateDownloadRequiredSpace(tables))
+}
+
+func TestCheckDisksFreeSpace(t *testing.T) {
+	b := &Backuper{cfg: &config.Config{General: config.GeneralConfig{FreeSpaceReserveBytes: 100}}}
+	disks := []clickhouse.Disk{
+		{Name: "default", Path: "/var/lib/clickhouse", Type: "local", FreeSpace: 1000},
+		{Name: "s3", Path: "/var/lib/clickhouse/disks/s3", Type: "s3", FreeSpace: 0},
+	}
+	assert.NoError(t, b.checkDisksFreeSpace("create", disks, nil))
+	assert.NoError(t, b.checkDisksFreeSpace("download", disks, map[string]uint64{"default": 900, "s3": 1 << 30}))
+	assert.ErrorContains(t, b.checkDisksFreeSpace("download", disks, map[string]uint64{"default": 901}), "not enough free space on disk `default`")
+	b.cfg.General.FreeSpaceReserveBytes = 2000
+	assert.ErrorContains(t, b.checkDisksFreeSpace("restore", disks, nil), "restore:")
+}
+
+func TestCalculateRestoreRequiredSpace(t *testing.T) {
+	tables := ListOfTables{
+		{
+			Parts: map[string][]metadata.Part{
+				"default": {{Name: "all_1_1_0", Size: 100}, {Name: "all_2_2_0", Size: 50, RebalancedDisk: "hot"}},
+				"cold":    {{Name: "all_3_3_0"}},
+			},
+			Size: map[string]int64{"default": 150, "cold": 1000},
+		},
+		{MetadataOnly: true, Parts: map[string][]metadata.Part{"hot": {{Name: "all_1_1_0", Size: 10000}}}},
+	}
+	// without disk_mapping all parts are hardlinked
+	assert.Equal(t, map[string]uint64{}, calculateRestoreRequiredSpace(tables, nil))
+	assert.Equal(t, map[string]uint64{"hot": 50, "cold": 1000}, calculateRestoreRequiredSpace(tables, map[string]string{"hot": "/mnt/hot", "cold": "/mnt/cold"}))
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/partition"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
-	"github.com/eapache/go-resiliency/retrier"
 	"github.com/rs/zerolog"
 	"io"
 	"io/fs"
@@ -40,13 +39,17 @@ var (
 	ErrBackupIsAlreadyExists = errors.New("backup is already exists")
 )
 
-func (b *Backuper) Download(backupName string, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) Download(backupName string, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) (err error) {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
 	}
 	defer cancel()
+	startDownload := time.Now()
 	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	defer func() {
+		b.notify("download", backupName, startDownload, err)
+	}()
 	if err := b.ch.Connect(); err != nil {
 		return fmt.Errorf("can't connect to clickhouse: %v", err)
 	}
@@ -88,11 +91,11 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 			}
 		}
 	}
-	startDownload := time.Now()
 	if b.cfg.General.RemoteStorage == "custom" {
 		return custom.Download(ctx, b.cfg, backupName, tablePattern, partitions, schemaOnly)
 	}
-	if err := b.initDisksPathsAndBackupDestination(ctx, disks, ""); err != nil {
+	remoteBackup, err := b.findRemoteBackupWithFailover(ctx, disks, backupName)
+	if err != nil {
 		return err
 	}
 	defer func() {
@@ -100,28 +103,39 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 			log.Warn().Msgf("can't close BackupDestination error: %v", err)
 		}
 	}()
-
-	remoteBackups, err := b.dst.BackupList(ctx, true, backupName)
-	if err != nil {
-		return err
-	}
-	found := false
-	var remoteBackup storage.Backup
-	for _, r := range remoteBackups {
-		if backupName == r.BackupName {
-			remoteBackup = r
-			found = true
-			break
-		}
-	}
-	if !found {
-		return fmt.Errorf("'%s' is not found on remote storage", backupName)
-	}
 	if len(remoteBackup.Tables) == 0 && !b.cfg.General.AllowEmptyBackups {
 		return fmt.Errorf("'%s' is empty backup", backupName)
 	}
 	tablesForDownload := parseTablePatternForDownload(remoteBackup.Tables, tablePattern)
 
+	var requiredChain []string
+	if !schemaOnly && remoteBackup.RequiredBackup != "" {
+		requiredChain, err = resolveRequiredBackupsChain(remoteBackup.BackupName, remoteBackup.RequiredBackup, func(requiredBackupName string) (string, error) {
+			requiredBackups, err := b.dst.BackupList(ctx, true, requiredBackupName)
+			if err != nil {
+				return "", err
+			}
+			for _, requiredBackup := range requiredBackups {
+				if requiredBackup.BackupName == requiredBackupName {
+					if requiredBackup.Broken != "" {
+						return "", fmt.Errorf("%s is broken: %s", requiredBackupName, requiredBackup.Broken)
+					}
+					return requiredBackup.RequiredBackup, nil
+				}
+			}
+			return "", fmt.Errorf("%s not found on remote storage", requiredBackupName)
+		})
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("%s requires %s", backupName, strings.Join(requiredChain, " -> "))
+	}
+	// objects in archive tier are restored together for backup and all required backups, instead of wait restore of each object separately during download
+	if !schemaOnly {
+		if err = b.dst.RestoreArchivedBackups(ctx, append([]string{backupName}, requiredChain...)...); err != nil {
+			return fmt.Errorf("can't restore archived objects of %s: %v", backupName, err)
+		}
+	}
 	if !schemaOnly && !b.cfg.General.DownloadByPart && remoteBackup.RequiredBackup != "" {
 		err := b.Download(remoteBackup.RequiredBackup, tablePattern, partitions, schemaOnly, b.resume, backupVersion, commandId)
 		if err != nil && !errors.Is(err, ErrBackupIsAlreadyExists) {
@@ -131,6 +145,10 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 
 	dataSize := uint64(0)
 	metadataSize := uint64(0)
+	if err = b.checkBackupMetadataSignature(&remoteBackup.BackupMetadata); err != nil {
+		return err
+	}
+	b.checkedBackupMetadata = &remoteBackup.BackupMetadata
 	b.isEmbedded = strings.Contains(remoteBackup.Tags, "embedded")
 	localBackupDir := path.Join(b.DefaultDataPath, "backup", backupName)
 	if b.isEmbedded {
@@ -182,6 +200,12 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 		if reBalanceErr := b.reBalanceTablesMetadataIfDiskNotExists(tableMetadataAfterDownload, disks, remoteBackup); reBalanceErr != nil {
 			return reBalanceErr
 		}
+		// resumed download already wrote part of data, required space can't be calculated precisely
+		if !b.resume {
+			if err = b.checkDisksFreeSpace("download", disks, calculateDownloadRequiredSpace(tableMetadataAfterDownload)); err != nil {
+				return err
+			}
+		}
 		log.Debug().Str("backupName", backupName).Msgf("prepare table DATA concurrent semaphore with concurrency=%d len(tableMetadataAfterDownload)=%d", b.cfg.General.DownloadConcurrency, len(tableMetadataAfterDownload))
 		dataGroup, dataCtx := errgroup.WithContext(ctx)
 		dataGroup.SetLimit(int(b.cfg.General.DownloadConcurrency))
@@ -224,6 +248,28 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 		return fmt.Errorf("download CONFIGS error: %v", err)
 	}
 
+	detachedSize, err := b.downloadDetachedPartsData(ctx, remoteBackup)
+	if err != nil {
+		return fmt.Errorf("download DETACHED error: %v", err)
+	}
+	configSize += detachedSize
+
+	if hasDictionaryFiles(tableMetadataAfterDownload, b.getUserFilesPath()) {
+		dictionaryFilesSize, err := b.downloadDictionaryFilesData(ctx, remoteBackup)
+		if err != nil {
+			return fmt.Errorf("download DICTIONARIES error: %v", err)
+		}
+		configSize += dictionaryFilesSize
+	}
+
+	if b.cfg.ClickHouse.KeeperSnapshotPath != "" {
+		keeperSnapshotSize, keeperErr := b.downloadKeeperSnapshotData(ctx, remoteBackup)
+		if keeperErr != nil {
+			return fmt.Errorf("download KEEPER snapshot error: %v", keeperErr)
+		}
+		configSize += keeperSnapshotSize
+	}
+
 	backupMetadata := remoteBackup.BackupMetadata
 	backupMetadata.Tables = tablesForDownload
 
@@ -239,6 +285,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 
 	backupMetadata.CompressedSize = 0
 	backupMetadata.DataFormat = ""
+	backupMetadata.Checksums = nil
 	backupMetadata.DataSize = dataSize
 	backupMetadata.MetadataSize = metadataSize
 	backupMetadata.ConfigSize = configSize
@@ -248,9 +295,16 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
 		backupMetafileLocalPath = path.Join(b.EmbeddedBackupDataPath, backupName, "metadata.json")
 	}
+	if err := b.setTablesChecksumsLocal(&backupMetadata, path.Dir(backupMetafileLocalPath)); err != nil {
+		return err
+	}
+	if err := backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
 	if err := backupMetadata.Save(backupMetafileLocalPath); err != nil {
 		return err
 	}
+	invalidateLocalBackupsScanCache()
 	for _, disk := range disks {
 		if disk.IsBackup {
 			if err = filesystemhelper.Chown(path.Join(disk.Path, backupName), b.ch, disks, true); err != nil {
@@ -288,7 +342,7 @@ func (b *Backuper) reBalanceTablesMetadataIfDiskNotExists(tableMetadataAfterDown
 		if !ok {
 			return "", nil, fmt.Errorf("disk: %s not found in disk_types section %#v in %s/metadata.json", disk, remoteBackup.DiskTypes, remoteBackup.BackupName)
 		}
-		storagePolicy := b.ch.ExtractStoragePolicy(t.Query)
+		storagePolicy := b.extractMappedStoragePolicy(t.Query)
 		if len(disksByStoragePolicyAndType) == 0 {
 			disksByStoragePolicyAndType = b.splitDisksByTypeAndStoragePolicy(disks)
 		}
@@ -413,7 +467,9 @@ func (b *Backuper) downloadTableMetadata(ctx context.Context, backupName string,
 				if err = json.Unmarshal(tmBody, &tableMetadata); err != nil {
 					return nil, 0, err
 				}
-				partitionsIdMap, _ = partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{tableMetadata}, partitions)
+				if partitionsIdMap, _, err = partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{tableMetadata}, partitions); err != nil {
+					return nil, 0, err
+				}
 				filterPartsAndFilesByPartitionsFilter(tableMetadata, partitionsIdMap[metadata.TableTitle{Database: tableMetadata.Database, Table: tableMetadata.Table}])
 				tableMetadata.LocalFile = localMetadataFile
 			}
@@ -422,23 +478,7 @@ func (b *Backuper) downloadTableMetadata(ctx context.Context, backupName string,
 				continue
 			}
 		}
-		var tmBody []byte
-		retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
-		err := retry.RunCtx(ctx, func(ctx context.Context) error {
-			tmReader, err := b.dst.GetFileReader(ctx, remoteMetadataFile)
-			if err != nil {
-				return err
-			}
-			tmBody, err = io.ReadAll(tmReader)
-			if err != nil {
-				return err
-			}
-			err = tmReader.Close()
-			if err != nil {
-				return err
-			}
-			return nil
-		})
+		tmBody, err := b.dst.ReadFile(ctx, remoteMetadataFile)
 		// sql file could be not present in incremental backup
 		if err != nil && strings.HasSuffix(localMetadataFile, ".sql") {
 			log.Warn().Str("localMetadataFile", localMetadataFile).Err(err).Send()
@@ -465,10 +505,15 @@ func (b *Backuper) downloadTableMetadata(ctx context.Context, backupName string,
 			if err = json.Unmarshal(tmBody, &tableMetadata); err != nil {
 				return nil, 0, err
 			}
+			if err = b.checkTableMetadataChecksum(backupName, &tableMetadata); err != nil {
+				return nil, 0, err
+			}
 			if b.shouldSkipByTableEngine(tableMetadata) || b.shouldSkipByTableName(fmt.Sprintf("%s.%s", tableMetadata.Database, tableMetadata.Table)) {
 				return nil, 0, nil
 			}
-			partitionsIdMap, _ = partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{tableMetadata}, partitions)
+			if partitionsIdMap, _, err = partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{tableMetadata}, partitions); err != nil {
+				return nil, 0, err
+			}
 			filterPartsAndFilesByPartitionsFilter(tableMetadata, partitionsIdMap[metadata.TableTitle{Database: tableMetadata.Database, Table: tableMetadata.Table}])
 			// save metadata
 			jsonSize := uint64(0)
@@ -544,6 +589,18 @@ func (b *Backuper) downloadConfigData(ctx context.Context, remoteBackup storage.
 	return b.downloadBackupRelatedDir(ctx, remoteBackup, "configs")
 }
 
+func (b *Backuper) downloadDictionaryFilesData(ctx context.Context, remoteBackup storage.Backup) (uint64, error) {
+	return b.downloadBackupRelatedDir(ctx, remoteBackup, dictionaryFilesDir)
+}
+
+func (b *Backuper) downloadDetachedPartsData(ctx context.Context, remoteBackup storage.Backup) (uint64, error) {
+	return b.downloadBackupRelatedDir(ctx, remoteBackup, detachedPartsDir)
+}
+
+func (b *Backuper) downloadKeeperSnapshotData(ctx context.Context, remoteBackup storage.Backup) (uint64, error) {
+	return b.downloadBackupRelatedDir(ctx, remoteBackup, "keeper")
+}
+
 func (b *Backuper) downloadBackupRelatedDir(ctx context.Context, remoteBackup storage.Backup, prefix string) (uint64, error) {
 	localDir := path.Join(b.DefaultDataPath, "backup", remoteBackup.BackupName, prefix)
 
@@ -558,7 +615,7 @@ func (b *Backuper) downloadBackupRelatedDir(ctx context.Context, remoteBackup st
 		}
 	}
 	if remoteBackup.DataFormat == DirectoryFormat {
-		if err := b.dst.DownloadPath(ctx, remoteSource, localDir, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
+		if err := b.dst.DownloadPath(ctx, remoteSource, localDir, checksumsForPath(remoteBackup.Checksums, prefix), &b.cfg.General, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
 			//SFTP can't walk on non exists paths and return error
 			if !strings.Contains(err.Error(), "not exist") {
 				return 0, err
@@ -590,9 +647,9 @@ func (b *Backuper) downloadBackupRelatedDir(ctx context.Context, remoteBackup st
 		log.Debug().Msgf("%s not exists on remote storage, skip download", remoteSource)
 		return 0, nil
 	}
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
-		return b.dst.DownloadCompressedStream(ctx, remoteSource, localDir, b.cfg.General.DownloadMaxBytesPerSecond)
+		return b.dst.DownloadCompressedStream(ctx, remoteSource, localDir, remoteBackup.Checksums[prefix], b.cfg.General.DownloadMaxBytesPerSecond)
 	})
 	if err != nil {
 		return 0, err
@@ -608,7 +665,7 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	dataGroup, dataCtx := errgroup.WithContext(ctx)
-	dataGroup.SetLimit(int(b.cfg.General.DownloadConcurrency))
+	dataGroup.SetLimit(b.cfg.GetDownloadObjectsConcurrency())
 
 	if remoteBackup.DataFormat != DirectoryFormat {
 		capacity := 0
@@ -617,7 +674,7 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 			capacity += len(table.Files[disk])
 			downloadOffset[disk] = 0
 		}
-		log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Files[...])=%d", table.Database, table.Table, b.cfg.General.DownloadConcurrency, capacity)
+		log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Files[...])=%d", table.Database, table.Table, b.cfg.GetDownloadObjectsConcurrency(), capacity)
 		for common.SumMapValuesInt(downloadOffset) < capacity {
 			for disk := range table.Files {
 				if downloadOffset[disk] >= len(table.Files[disk]) {
@@ -637,9 +694,9 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 					if b.resume && b.resumableState.IsAlreadyProcessedBool(tableRemoteFile) {
 						return nil
 					}
-					retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+					retry := storage.NewRetrier(&b.cfg.General)
 					err := retry.RunCtx(dataCtx, func(dataCtx context.Context) error {
-						return b.dst.DownloadCompressedStream(dataCtx, tableRemoteFile, tableLocalDir, b.cfg.General.DownloadMaxBytesPerSecond)
+						return b.dst.DownloadCompressedStream(dataCtx, tableRemoteFile, tableLocalDir, table.Checksums[archiveFile], b.cfg.General.DownloadMaxBytesPerSecond)
 					})
 					if err != nil {
 						return err
@@ -657,7 +714,7 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 		for disk := range table.Parts {
 			capacity += len(table.Parts[disk])
 		}
-		log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Parts[...])=%d", table.Database, table.Table, b.cfg.General.DownloadConcurrency, capacity)
+		log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Parts[...])=%d", table.Database, table.Table, b.cfg.GetDownloadObjectsConcurrency(), capacity)
 
 		for disk, parts := range table.Parts {
 			tableRemotePath := path.Join(remoteBackup.BackupName, "shadow", dbAndTableDir, disk)
@@ -683,7 +740,7 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 					if b.resume && b.resumableState.IsAlreadyProcessedBool(partRemotePath) {
 						return nil
 					}
-					if err := b.dst.DownloadPath(dataCtx, partRemotePath, partLocalPath, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
+					if err := b.dst.DownloadPath(dataCtx, partRemotePath, partLocalPath, checksumsForPath(table.Checksums, path.Join(disk, part.Name)), &b.cfg.General, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
 						return err
 					}
 					if b.resume {
@@ -719,7 +776,7 @@ func (b *Backuper) downloadDiffParts(ctx context.Context, remoteBackup metadata.
 	defer cancel()
 	downloadedDiffParts := uint32(0)
 	downloadDiffGroup, downloadDiffCtx := errgroup.WithContext(ctx)
-	downloadDiffGroup.SetLimit(int(b.cfg.General.DownloadConcurrency))
+	downloadDiffGroup.SetLimit(b.cfg.GetDownloadObjectsConcurrency())
 	diffRemoteFilesCache := map[string]*sync.Mutex{}
 	diffRemoteFilesLock := &sync.Mutex{}
 
@@ -772,7 +829,7 @@ func (b *Backuper) downloadDiffParts(ctx context.Context, remoteBackup metadata.
 					}
 
 					for tableRemoteFile, tableLocalDir := range tableRemoteFiles {
-						err = b.downloadDiffRemoteFile(downloadDiffCtx, diffRemoteFilesLock, diffRemoteFilesCache, tableRemoteFile, tableLocalDir)
+						err = b.downloadDiffRemoteFile(downloadDiffCtx, diffRemoteFilesLock, diffRemoteFilesCache, table, tableRemoteFile, tableLocalDir)
 						if err != nil {
 							return err
 						}
@@ -822,7 +879,7 @@ func (b *Backuper) downloadDiffParts(ctx context.Context, remoteBackup metadata.
 	return nil
 }
 
-func (b *Backuper) downloadDiffRemoteFile(ctx context.Context, diffRemoteFilesLock *sync.Mutex, diffRemoteFilesCache map[string]*sync.Mutex, tableRemoteFile string, tableLocalDir string) error {
+func (b *Backuper) downloadDiffRemoteFile(ctx context.Context, diffRemoteFilesLock *sync.Mutex, diffRemoteFilesCache map[string]*sync.Mutex, table metadata.TableMetadata, tableRemoteFile string, tableLocalDir string) error {
 	if b.resume && b.resumableState.IsAlreadyProcessedBool(tableRemoteFile) {
 		return nil
 	}
@@ -840,10 +897,15 @@ func (b *Backuper) downloadDiffRemoteFile(ctx context.Context, diffRemoteFilesLo
 		diffRemoteFilesCache[tableRemoteFile] = namedLock
 		namedLock.Lock()
 		diffRemoteFilesLock.Unlock()
+		requiredChecksums, checksumPath, err := b.getRequiredTableChecksums(ctx, table, tableRemoteFile)
+		if err != nil {
+			namedLock.Unlock()
+			return err
+		}
 		if path.Ext(tableRemoteFile) != "" {
-			retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+			retry := storage.NewRetrier(&b.cfg.General)
 			err := retry.RunCtx(ctx, func(ctx context.Context) error {
-				return b.dst.DownloadCompressedStream(ctx, tableRemoteFile, tableLocalDir, b.cfg.General.DownloadMaxBytesPerSecond)
+				return b.dst.DownloadCompressedStream(ctx, tableRemoteFile, tableLocalDir, requiredChecksums[checksumPath], b.cfg.General.DownloadMaxBytesPerSecond)
 			})
 			if err != nil {
 				log.Warn().Msgf("DownloadCompressedStream %s -> %s return error: %v", tableRemoteFile, tableLocalDir, err)
@@ -851,7 +913,7 @@ func (b *Backuper) downloadDiffRemoteFile(ctx context.Context, diffRemoteFilesLo
 			}
 		} else {
 			// remoteFile could be a directory
-			if err := b.dst.DownloadPath(ctx, tableRemoteFile, tableLocalDir, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
+			if err := b.dst.DownloadPath(ctx, tableRemoteFile, tableLocalDir, checksumsForPath(requiredChecksums, checksumPath), &b.cfg.General, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
 				log.Warn().Msgf("DownloadPath %s -> %s return error: %v", tableRemoteFile, tableLocalDir, err)
 				return err
 			}
@@ -879,6 +941,28 @@ func (b *Backuper) checkNewPath(newPath string, part metadata.Part) error {
 	return nil
 }
 
+// getRequiredTableChecksums - return checksums from table metadata of required backup which contains tableRemoteFile, and tableRemoteFile path relative to table data in required backup
+func (b *Backuper) getRequiredTableChecksums(ctx context.Context, table metadata.TableMetadata, tableRemoteFile string) (map[string]string, string, error) {
+	requiredBackupName := strings.SplitN(tableRemoteFile, "/", 2)[0]
+	requiredTable, err := b.downloadTableMetadataIfNotExists(ctx, requiredBackupName, metadata.TableTitle{Database: table.Database, Table: table.Table})
+	if err != nil {
+		return nil, "", err
+	}
+	tableRemotePath := path.Join(requiredBackupName, "shadow", common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+	return requiredTable.Checksums, strings.TrimPrefix(tableRemoteFile, tableRemotePath+"/"), nil
+}
+
+// checksumsForPath - checksums of files inside remotePath with file names relative to remotePath, like DownloadPath expects
+func checksumsForPath(checksums map[string]string, remotePath string) map[string]string {
+	pathChecksums := make(map[string]string)
+	for fileName, checksum := range checksums {
+		if relativeName, isInside := strings.CutPrefix(fileName, remotePath+"/"); isInside {
+			pathChecksums[relativeName] = checksum
+		}
+	}
+	return pathChecksums
+}
+
 func (b *Backuper) findDiffBackupFilesRemote(ctx context.Context, backup metadata.BackupMetadata, table metadata.TableMetadata, disk string, part metadata.Part) (map[string]string, error) {
 	var requiredTable *metadata.TableMetadata
 	log.Debug().Fields(map[string]interface{}{"database": table.Database, "table": table.Table, "part": part.Name, "logger": "findDiffBackupFilesRemote"}).Msg("start")
@@ -1019,6 +1103,25 @@ func (b *Backuper) findDiffFileExist(ctx context.Context, requiredBackup *metada
 	return tableRemotePath, tableLocalDir, nil
 }
 
+// resolveRequiredBackupsChain - walk `required_backup` links up to the full backup before download any data, return chain from the nearest required backup to the full backup
+func resolveRequiredBackupsChain(backupName, requiredBackupName string, getRequiredBackup func(requiredBackupName string) (string, error)) ([]string, error) {
+	chain := make([]string, 0)
+	visited := map[string]struct{}{backupName: {}}
+	for requiredBackupName != "" {
+		if _, exists := visited[requiredBackupName]; exists {
+			return chain, fmt.Errorf("cyclic required_backup chain %s -> %s -> %s", backupName, strings.Join(chain, " -> "), requiredBackupName)
+		}
+		visited[requiredBackupName] = struct{}{}
+		chain = append(chain, requiredBackupName)
+		nextRequiredBackupName, err := getRequiredBackup(requiredBackupName)
+		if err != nil {
+			return chain, fmt.Errorf("%s can't be downloaded, required backup chain %s -> %s is incomplete: %v", backupName, backupName, strings.Join(chain, " -> "), err)
+		}
+		requiredBackupName = nextRequiredBackupName
+	}
+	return chain, nil
+}
+
 func (b *Backuper) ReadBackupMetadataRemote(ctx context.Context, backupName string) (*metadata.BackupMetadata, error) {
 	backupList, err := b.dst.BackupList(ctx, true, backupName)
 	if err != nil {
@@ -1080,7 +1183,7 @@ func (b *Backuper) downloadSingleBackupFile(ctx context.Context, remoteFile stri
 			return size, nil
 		}
 	}
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
 
 	err := retry.RunCtx(ctx, func(ctx context.Context) error {
 		remoteReader, err := b.dst.GetFileReader(ctx, remoteFile)
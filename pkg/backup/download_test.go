@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
@@ -284,3 +285,43 @@ func TestReBalanceTablesMetadataIfDiskNotExists_CheckErrors(t *testing.T) {
 	assert.Equal(t, "250B free space, not found in system.disks with `local` type", err.Error())
 
 }
+
+func TestResolveRequiredBackupsChain(t *testing.T) {
+	remoteBackups := map[string]string{
+		"increment2": "increment1",
+		"increment1": "full",
+		"full":       "",
+		"cycle1":     "cycle2",
+		"cycle2":     "cycle1",
+		"orphan":     "deleted",
+	}
+	getRequiredBackup := func(backupName string) (string, error) {
+		requiredBackup, exists := remoteBackups[backupName]
+		if !exists {
+			return "", fmt.Errorf("%s not found on remote storage", backupName)
+		}
+		return requiredBackup, nil
+	}
+	chain, err := resolveRequiredBackupsChain("increment3", "increment2", getRequiredBackup)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"increment2", "increment1", "full"}, chain)
+
+	_, err = resolveRequiredBackupsChain("cycle0", "cycle1", getRequiredBackup)
+	assert.ErrorContains(t, err, "cyclic required_backup chain")
+
+	_, err = resolveRequiredBackupsChain("increment", "orphan", getRequiredBackup)
+	assert.ErrorContains(t, err, "deleted not found on remote storage")
+}
+
+func TestChecksumsForPath(t *testing.T) {
+	checksums := map[string]string{
+		"default_all_1_1_0.tar":       "archive",
+		"default/all_1_1_0/data.bin":  "data1",
+		"default/all_1_1_0/data.mrk":  "mark1",
+		"default/all_1_1_01/data.bin": "other part with the same prefix",
+		"access/users.list":           "users",
+	}
+	assert.Equal(t, map[string]string{"data.bin": "data1", "data.mrk": "mark1"}, checksumsForPath(checksums, "default/all_1_1_0"))
+	assert.Equal(t, map[string]string{"users.list": "users"}, checksumsForPath(checksums, "access"))
+	assert.Empty(t, checksumsForPath(nil, "configs"))
+}
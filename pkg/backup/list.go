@@ -2,7 +2,6 @@ package backup
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/custom"
@@ -19,6 +18,7 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // List - list backups to stdout from command line
@@ -58,6 +58,9 @@ func printBackupsRemote(w io.Writer, backupList []storage.Backup, format string)
 			if backup.Tags != "" {
 				description += ", " + backup.Tags
 			}
+			if backup.Pinned {
+				description += ", pinned"
+			}
 			required := ""
 			if backup.RequiredBackup != "" {
 				required = "+" + backup.RequiredBackup
@@ -70,12 +73,32 @@ func printBackupsRemote(w io.Writer, backupList []storage.Backup, format string)
 				log.Error().Msgf("fmt.Fprintf write %d bytes return error: %v", bytes, err)
 			}
 		}
+	case "databases", "db":
+		for _, backup := range backupList {
+			if backup.Broken != "" {
+				continue
+			}
+			printBackupDatabases(w, backup.BackupMetadata, "remote")
+		}
 	default:
 		return fmt.Errorf("'%s' undefined", format)
 	}
 	return nil
 }
 
+// printBackupDatabases - one row for each database in backup, size is unknown for backups created before database_sizes was added
+func printBackupDatabases(w io.Writer, backup metadata.BackupMetadata, location string) {
+	for _, database := range backup.Databases {
+		size := "???"
+		if backup.DatabaseSizes != nil {
+			size = utils.FormatBytes(backup.DatabaseSizes[database.Name])
+		}
+		if bytes, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", backup.BackupName, database.Name, size, location); err != nil {
+			log.Error().Msgf("fmt.Fprintf write %d bytes return error: %v", bytes, err)
+		}
+	}
+}
+
 func printBackupsLocal(ctx context.Context, w io.Writer, backupList []LocalBackup, format string) error {
 	switch format {
 	case "latest", "last", "l":
@@ -102,6 +125,12 @@ func printBackupsLocal(ctx context.Context, w io.Writer, backupList []LocalBacku
 					}
 					description += backup.Tags
 				}
+				if backup.Pinned {
+					if description != "" {
+						description += ", "
+					}
+					description += "pinned"
+				}
 				creationDate := backup.CreationDate.Format("02/01/2006 15:04:05")
 				required := ""
 				if backup.RequiredBackup != "" {
@@ -116,6 +145,13 @@ func printBackupsLocal(ctx context.Context, w io.Writer, backupList []LocalBacku
 				}
 			}
 		}
+	case "databases", "db":
+		for _, backup := range backupList {
+			if backup.Broken != "" {
+				continue
+			}
+			printBackupDatabases(w, backup.BackupMetadata, "local")
+		}
 	default:
 		return fmt.Errorf("'%s' undefined", format)
 	}
@@ -140,7 +176,35 @@ func (b *Backuper) PrintLocalBackups(ctx context.Context, format string) error {
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	return printBackupsLocal(ctx, w, backupList, format)
+	return printBackupsLocal(ctx, w, filterLocalBackupsByUserTags(backupList, b.userTagsFilter), format)
+}
+
+// filterLocalBackupsByUserTags - keep only backups which contain all `key=value` pairs from `list --tag`
+func filterLocalBackupsByUserTags(backupList []LocalBackup, filter map[string]string) []LocalBackup {
+	if len(filter) == 0 {
+		return backupList
+	}
+	filtered := make([]LocalBackup, 0, len(backupList))
+	for _, backup := range backupList {
+		if backup.MatchAllUserTags(filter) {
+			filtered = append(filtered, backup)
+		}
+	}
+	return filtered
+}
+
+// filterRemoteBackupsByUserTags - the same as filterLocalBackupsByUserTags for remote backups
+func filterRemoteBackupsByUserTags(backupList []storage.Backup, filter map[string]string) []storage.Backup {
+	if len(filter) == 0 {
+		return backupList
+	}
+	filtered := make([]storage.Backup, 0, len(backupList))
+	for _, backup := range backupList {
+		if backup.MatchAllUserTags(filter) {
+			filtered = append(filtered, backup)
+		}
+	}
+	return filtered
 }
 
 // GetLocalBackups - return slice of all backups stored locally
@@ -178,7 +242,7 @@ func (b *Backuper) GetLocalBackups(ctx context.Context, disks []clickhouse.Disk)
 			allBackupPaths = append(allBackupPaths, path.Join(disk.Path, "backup"))
 		}
 	}
-	addBrokenBackupIfNotExists := func(result []LocalBackup, name string, info os.FileInfo, broken string) []LocalBackup {
+	addBrokenBackupIfNotExists := func(result []LocalBackup, name string, modTime time.Time, broken string) []LocalBackup {
 		backupAlreadyExists := false
 		for _, backup := range result {
 			if backup.BackupName == name {
@@ -191,7 +255,7 @@ func (b *Backuper) GetLocalBackups(ctx context.Context, disks []clickhouse.Disk)
 			result = append(result, LocalBackup{
 				BackupMetadata: metadata.BackupMetadata{
 					BackupName:   name,
-					CreationDate: info.ModTime(),
+					CreationDate: modTime,
 				},
 				Broken: broken,
 			})
@@ -204,42 +268,22 @@ func (b *Backuper) GetLocalBackups(ctx context.Context, disks []clickhouse.Disk)
 		case <-ctx.Done():
 			return nil, nil, ctx.Err()
 		default:
-			d, openErr := os.Open(backupPath)
-			if openErr != nil {
+			entries, scanErr := scanLocalBackupPathCached(backupPath)
+			if scanErr != nil {
 				if i < l-1 {
 					continue
 				}
-				if os.IsNotExist(openErr) {
+				if os.IsNotExist(scanErr) {
 					return result, disks, nil
 				}
-				return nil, nil, openErr
+				return nil, nil, scanErr
 			}
-			names, err := d.Readdirnames(-1)
-			if err != nil {
-				return nil, nil, err
-			}
-			for _, name := range names {
-				info, err := os.Stat(path.Join(backupPath, name))
-				if err != nil {
-					continue
-				}
-				if !info.IsDir() {
-					continue
-				}
-				backupMetafilePath := path.Join(backupPath, name, "metadata.json")
-				backupMetadataBody, err := os.ReadFile(backupMetafilePath)
-				if err != nil {
-					if !os.IsNotExist(err) {
-						log.Warn().Msgf("list can't read %s error: %s", backupMetafilePath, err)
-					}
-					result = addBrokenBackupIfNotExists(result, name, info, "broken metadata.json not found")
-					continue
-				}
-				var backupMetadata metadata.BackupMetadata
-				if parseErr := json.Unmarshal(backupMetadataBody, &backupMetadata); parseErr != nil {
-					result = addBrokenBackupIfNotExists(result, name, info, fmt.Sprintf("parse metadata.json error: %v", parseErr))
+			for _, entry := range entries {
+				if entry.broken != "" {
+					result = addBrokenBackupIfNotExists(result, entry.name, entry.modTime, entry.broken)
 					continue
 				}
+				backupMetadata := entry.metadata
 				brokenBackupIsAlreadyExists := false
 				for i, backup := range result {
 					if backup.BackupName == backupMetadata.BackupName {
@@ -254,10 +298,6 @@ func (b *Backuper) GetLocalBackups(ctx context.Context, disks []clickhouse.Disk)
 						BackupMetadata: backupMetadata,
 					})
 				}
-
-			}
-			if closeErr := d.Close(); closeErr != nil {
-				log.Error().Msgf("can't close %s error: %v", backupPath, closeErr)
 			}
 		}
 	}
@@ -284,7 +324,7 @@ func (b *Backuper) PrintAllBackups(ctx context.Context, format string) error {
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if err = printBackupsLocal(ctx, w, localBackups, format); err != nil {
+	if err = printBackupsLocal(ctx, w, filterLocalBackupsByUserTags(localBackups, b.userTagsFilter), format); err != nil {
 		log.Warn().Msgf("printBackupsLocal return error: %v", err)
 	}
 
@@ -293,7 +333,7 @@ func (b *Backuper) PrintAllBackups(ctx context.Context, format string) error {
 		if err != nil {
 			return err
 		}
-		if err = printBackupsRemote(w, remoteBackups, format); err != nil {
+		if err = printBackupsRemote(w, filterRemoteBackupsByUserTags(remoteBackups, b.userTagsFilter), format); err != nil {
 			log.Warn().Msgf("printBackupsRemote return error: %v", err)
 		}
 	}
@@ -318,7 +358,7 @@ func (b *Backuper) PrintRemoteBackups(ctx context.Context, format string) error
 	if err != nil {
 		return err
 	}
-	err = printBackupsRemote(w, backupList, format)
+	err = printBackupsRemote(w, filterRemoteBackupsByUserTags(backupList, b.userTagsFilter), format)
 	return err
 }
 
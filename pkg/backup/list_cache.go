@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/rs/zerolog/log"
+)
+
+// localBackupsScanInterval - how long scan result of backup path is reused while modification time of backup path not changed, in-place metadata.json changes made by other processes become visible after this interval
+const localBackupsScanInterval = 10 * time.Second
+
+// errParseLocalMetadata - metadata.json exists but content is not valid, backup shall be listed as broken
+var errParseLocalMetadata = errors.New("parse metadata.json error")
+
+// localMetadataCacheEntry - parsed metadata.json, valid while file size and modification time not changed
+type localMetadataCacheEntry struct {
+	modTime  time.Time
+	size     int64
+	metadata metadata.BackupMetadata
+	parseErr error
+}
+
+// localMetadataCache - avoid re-reading and parsing huge metadata.json for each local backup on every `list`, `/backup/list` and metrics update
+var localMetadataCache = struct {
+	sync.Mutex
+	entries map[string]localMetadataCacheEntry
+}{entries: make(map[string]localMetadataCacheEntry)}
+
+// localBackupsScanEntry - sub-directory of backup path, broken is not empty when metadata.json can't be read or parsed
+type localBackupsScanEntry struct {
+	name     string
+	modTime  time.Time
+	metadata metadata.BackupMetadata
+	broken   string
+}
+
+// localBackupsScanCacheEntry - scan result of backup path, valid while modification time of backup path not changed and scannedAt not older than localBackupsScanInterval
+type localBackupsScanCacheEntry struct {
+	modTime   time.Time
+	scannedAt time.Time
+	entries   []localBackupsScanEntry
+}
+
+// localBackupsScanCache - avoid walking backup path with many retained backups on every `list`, `/backup/list` and metrics update
+var localBackupsScanCache = struct {
+	sync.Mutex
+	scans map[string]localBackupsScanCacheEntry
+}{scans: make(map[string]localBackupsScanCacheEntry)}
+
+// invalidateLocalBackupsScanCache - shall be called after metadata.json changed in-place, backup directory creation and deletion is detected by modification time of backup path
+func invalidateLocalBackupsScanCache() {
+	localBackupsScanCache.Lock()
+	clear(localBackupsScanCache.scans)
+	localBackupsScanCache.Unlock()
+}
+
+// scanLocalBackupPathCached - return backup directories inside backupPath with parsed metadata.json, metadata is deep copied and safe to change
+func scanLocalBackupPathCached(backupPath string) ([]localBackupsScanEntry, error) {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	localBackupsScanCache.Lock()
+	scan, exists := localBackupsScanCache.scans[backupPath]
+	localBackupsScanCache.Unlock()
+	if !exists || !scan.modTime.Equal(info.ModTime()) || time.Since(scan.scannedAt) > localBackupsScanInterval {
+		scannedAt := time.Now()
+		entries, err := scanLocalBackupPath(backupPath)
+		if err != nil {
+			return nil, err
+		}
+		scan = localBackupsScanCacheEntry{modTime: info.ModTime(), scannedAt: scannedAt, entries: entries}
+		localBackupsScanCache.Lock()
+		localBackupsScanCache.scans[backupPath] = scan
+		localBackupsScanCache.Unlock()
+	}
+	entries := make([]localBackupsScanEntry, len(scan.entries))
+	for i, entry := range scan.entries {
+		entries[i] = entry
+		entries[i].metadata = copyBackupMetadata(entry.metadata)
+	}
+	return entries, nil
+}
+
+// scanLocalBackupPath - walk backupPath, metadata.json is parsed only when changed, see readLocalBackupMetadataCached
+func scanLocalBackupPath(backupPath string) ([]localBackupsScanEntry, error) {
+	d, err := os.Open(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	if closeErr := d.Close(); closeErr != nil {
+		log.Error().Msgf("can't close %s error: %v", backupPath, closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]localBackupsScanEntry, 0, len(names))
+	for _, name := range names {
+		info, err := os.Stat(path.Join(backupPath, name))
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			continue
+		}
+		entry := localBackupsScanEntry{name: name, modTime: info.ModTime()}
+		backupMetafilePath := path.Join(backupPath, name, "metadata.json")
+		entry.metadata, err = readLocalBackupMetadataCached(backupMetafilePath)
+		if errors.Is(err, errParseLocalMetadata) {
+			entry.broken = err.Error()
+		} else if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Warn().Msgf("list %v", err)
+			}
+			entry.broken = "broken metadata.json not found"
+		}
+		entries = append(entries, entry)
+	}
+	pruneLocalMetadataCache(backupPath, names)
+	return entries, nil
+}
+
+// readLocalBackupMetadataCached - return parsed metadata.json, error wraps errParseLocalMetadata when file content is not valid, returned metadata shares maps with cache and shall not be changed
+func readLocalBackupMetadataCached(backupMetafilePath string) (metadata.BackupMetadata, error) {
+	var backupMetadata metadata.BackupMetadata
+	info, err := os.Stat(backupMetafilePath)
+	if err != nil {
+		return backupMetadata, fmt.Errorf("can't read %s: %w", backupMetafilePath, err)
+	}
+	localMetadataCache.Lock()
+	entry, exists := localMetadataCache.entries[backupMetafilePath]
+	localMetadataCache.Unlock()
+	if !exists || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		backupMetadataBody, err := os.ReadFile(backupMetafilePath)
+		if err != nil {
+			return backupMetadata, fmt.Errorf("can't read %s: %w", backupMetafilePath, err)
+		}
+		entry = localMetadataCacheEntry{modTime: info.ModTime(), size: info.Size()}
+		entry.parseErr = json.Unmarshal(backupMetadataBody, &entry.metadata)
+		localMetadataCache.Lock()
+		localMetadataCache.entries[backupMetafilePath] = entry
+		localMetadataCache.Unlock()
+	}
+	if entry.parseErr != nil {
+		return backupMetadata, fmt.Errorf("%w: %v", errParseLocalMetadata, entry.parseErr)
+	}
+	return entry.metadata, nil
+}
+
+// copyBackupMetadata - callers change maps and slices of listed backups, like `delete(backup.DatabaseSizes, database)`, which shall not affect cached metadata
+func copyBackupMetadata(backupMetadata metadata.BackupMetadata) metadata.BackupMetadata {
+	backupMetadata.Disks = maps.Clone(backupMetadata.Disks)
+	backupMetadata.DiskTypes = maps.Clone(backupMetadata.DiskTypes)
+	backupMetadata.ClickHouseSettings = maps.Clone(backupMetadata.ClickHouseSettings)
+	backupMetadata.TableEngines = slices.Clone(backupMetadata.TableEngines)
+	backupMetadata.Databases = slices.Clone(backupMetadata.Databases)
+	backupMetadata.DatabaseSizes = maps.Clone(backupMetadata.DatabaseSizes)
+	backupMetadata.Tables = slices.Clone(backupMetadata.Tables)
+	backupMetadata.Functions = slices.Clone(backupMetadata.Functions)
+	if backupMetadata.Churn != nil {
+		churn := *backupMetadata.Churn
+		backupMetadata.Churn = &churn
+	}
+	backupMetadata.UserTags = maps.Clone(backupMetadata.UserTags)
+	backupMetadata.CustomMetadata = maps.Clone(backupMetadata.CustomMetadata)
+	backupMetadata.Replicas = maps.Clone(backupMetadata.Replicas)
+	backupMetadata.TablesChecksums = maps.Clone(backupMetadata.TablesChecksums)
+	backupMetadata.Checksums = maps.Clone(backupMetadata.Checksums)
+	return backupMetadata
+}
+
+// pruneLocalMetadataCache - remove cache entries for deleted backups inside backupPath
+func pruneLocalMetadataCache(backupPath string, existsNames []string) {
+	exists := make(map[string]struct{}, len(existsNames))
+	for _, name := range existsNames {
+		exists[name] = struct{}{}
+	}
+	localMetadataCache.Lock()
+	defer localMetadataCache.Unlock()
+	for backupMetafilePath := range localMetadataCache.entries {
+		backupDir := path.Dir(backupMetafilePath)
+		if path.Dir(backupDir) != path.Clean(backupPath) {
+			continue
+		}
+		if _, ok := exists[path.Base(backupDir)]; !ok {
+			delete(localMetadataCache.entries, backupMetafilePath)
+		}
+	}
+}
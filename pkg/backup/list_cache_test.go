@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLocalBackupMetadataCached(t *testing.T) {
+	r := require.New(t)
+	backupPath := t.TempDir()
+	r.NoError(os.MkdirAll(path.Join(backupPath, "backup1"), 0750))
+	metafilePath := path.Join(backupPath, "backup1", "metadata.json")
+	r.NoError(os.WriteFile(metafilePath, []byte(`{"backup_name":"backup1","data_size":1}`), 0640))
+
+	backupMetadata, err := readLocalBackupMetadataCached(metafilePath)
+	r.NoError(err)
+	r.Equal(uint64(1), backupMetadata.DataSize)
+
+	// the same size and modification time, cached value expected
+	info, err := os.Stat(metafilePath)
+	r.NoError(err)
+	r.NoError(os.WriteFile(metafilePath, []byte(`{"backup_name":"backup1","data_size":2}`), 0640))
+	r.NoError(os.Chtimes(metafilePath, info.ModTime(), info.ModTime()))
+	backupMetadata, err = readLocalBackupMetadataCached(metafilePath)
+	r.NoError(err)
+	r.Equal(uint64(1), backupMetadata.DataSize)
+
+	// changed file, re-read expected
+	r.NoError(os.Chtimes(metafilePath, time.Now(), info.ModTime().Add(time.Second)))
+	backupMetadata, err = readLocalBackupMetadataCached(metafilePath)
+	r.NoError(err)
+	r.Equal(uint64(2), backupMetadata.DataSize)
+
+	r.NoError(os.WriteFile(metafilePath, []byte(`{`), 0640))
+	_, err = readLocalBackupMetadataCached(metafilePath)
+	r.ErrorIs(err, errParseLocalMetadata)
+
+	_, err = readLocalBackupMetadataCached(path.Join(backupPath, "backup2", "metadata.json"))
+	r.ErrorIs(err, os.ErrNotExist)
+	r.NotErrorIs(err, errParseLocalMetadata)
+
+	pruneLocalMetadataCache(backupPath, []string{"backup1"})
+	localMetadataCache.Lock()
+	r.Contains(localMetadataCache.entries, metafilePath)
+	localMetadataCache.Unlock()
+	pruneLocalMetadataCache(backupPath, []string{})
+	localMetadataCache.Lock()
+	r.NotContains(localMetadataCache.entries, metafilePath)
+	localMetadataCache.Unlock()
+}
+
+func TestScanLocalBackupPathCached(t *testing.T) {
+	r := require.New(t)
+	backupPath := t.TempDir()
+	r.NoError(os.MkdirAll(path.Join(backupPath, "backup1"), 0750))
+	r.NoError(os.MkdirAll(path.Join(backupPath, "broken"), 0750))
+	metafilePath := path.Join(backupPath, "backup1", "metadata.json")
+	r.NoError(os.WriteFile(metafilePath, []byte(`{"backup_name":"backup1","database_sizes":{"db1":1,"db2":2}}`), 0640))
+	r.NoError(os.WriteFile(path.Join(backupPath, "broken", "metadata.json"), []byte(`{`), 0640))
+
+	entries, err := scanLocalBackupPathCached(backupPath)
+	r.NoError(err)
+	r.Len(entries, 2)
+	byName := map[string]localBackupsScanEntry{}
+	for _, entry := range entries {
+		byName[entry.name] = entry
+	}
+	r.Empty(byName["backup1"].broken)
+	r.Contains(byName["broken"].broken, errParseLocalMetadata.Error())
+
+	// returned metadata is a copy, changes shall not affect cache
+	delete(byName["backup1"].metadata.DatabaseSizes, "db1")
+	entries, err = scanLocalBackupPathCached(backupPath)
+	r.NoError(err)
+	for _, entry := range entries {
+		if entry.name == "backup1" {
+			r.Equal(map[string]uint64{"db1": 1, "db2": 2}, entry.metadata.DatabaseSizes)
+		}
+	}
+
+	// in-place metadata.json change is not visible until invalidation, backup path not walked again
+	r.NoError(os.WriteFile(metafilePath, []byte(`{"backup_name":"backup1","data_size":3}`), 0640))
+	entries, err = scanLocalBackupPathCached(backupPath)
+	r.NoError(err)
+	for _, entry := range entries {
+		if entry.name == "backup1" {
+			r.Equal(uint64(0), entry.metadata.DataSize)
+		}
+	}
+	invalidateLocalBackupsScanCache()
+	entries, err = scanLocalBackupPathCached(backupPath)
+	r.NoError(err)
+	for _, entry := range entries {
+		if entry.name == "backup1" {
+			r.Equal(uint64(3), entry.metadata.DataSize)
+		}
+	}
+
+	// new backup directory changes modification time of backup path
+	info, err := os.Stat(backupPath)
+	r.NoError(err)
+	r.NoError(os.MkdirAll(path.Join(backupPath, "backup2"), 0750))
+	r.NoError(os.Chtimes(backupPath, time.Now(), info.ModTime().Add(time.Second)))
+	entries, err = scanLocalBackupPathCached(backupPath)
+	r.NoError(err)
+	r.Len(entries, 3)
+
+	_, err = scanLocalBackupPathCached(path.Join(backupPath, "not_exists"))
+	r.True(os.IsNotExist(err))
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/events"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/hooks"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// notify - send notification about finished command into configured notifiers, notification errors don't change command result
+func (b *Backuper) notify(command, backupName string, startTime time.Time, commandErr error) {
+	b.publishEvent(command, backupName, commandErr)
+	b.runFinishHooks(command, backupName, commandErr)
+	if !notify.IsEnabled(&b.cfg.Notifications, commandErr) {
+		return
+	}
+	var size uint64
+	if backupName != "" && b.DefaultDataPath != "" {
+		if backupMetadata, err := b.ReadBackupMetadataLocal(context.Background(), backupName); err == nil {
+			size = backupMetadata.GetFullSize()
+		}
+	}
+	event := notify.NewEvent(command, backupName, size, time.Since(startTime), commandErr)
+	if err := notify.Send(context.Background(), &b.cfg.Notifications, event); err != nil {
+		log.Warn().Str("command", command).Str("backup", backupName).Msgf("notify.Send return error: %v", err)
+	}
+}
+
+// publishEvent - lifecycle events for `GET /backup/events`, failed create, upload and download don't change backup state, so they are not published
+func (b *Backuper) publishEvent(command, backupName string, commandErr error) {
+	switch command {
+	case "create":
+		if commandErr == nil {
+			events.Current.Publish(events.Created, backupName, "local", nil)
+		}
+	case "upload":
+		if commandErr == nil {
+			events.Current.Publish(events.Uploaded, backupName, "remote", nil)
+		}
+	case "download":
+		if commandErr == nil {
+			events.Current.Publish(events.Downloaded, backupName, "local", nil)
+		}
+	case "restore":
+		events.Current.Publish(events.RestoreFinished, backupName, "local", commandErr)
+	}
+}
+
+// runFinishHooks - run hooks->after_create, hooks->after_upload_success and hooks->on_failure, hook errors don't change command result
+func (b *Backuper) runFinishHooks(command, backupName string, commandErr error) {
+	finishHooks := make([]string, 0, 2)
+	if command == "create" {
+		finishHooks = append(finishHooks, hooks.AfterCreate)
+	}
+	if command == "upload" && commandErr == nil {
+		finishHooks = append(finishHooks, hooks.AfterUploadSuccess)
+	}
+	if commandErr != nil {
+		finishHooks = append(finishHooks, hooks.OnFailure)
+	}
+	for _, hook := range finishHooks {
+		if err := hooks.Run(context.Background(), &b.cfg.Hooks, hook, command, backupName, commandErr); err != nil {
+			log.Warn().Str("command", command).Str("backup", backupName).Msgf("hooks.Run return error: %v", err)
+		}
+	}
+}
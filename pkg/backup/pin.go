@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Pin - set or reset `pinned` flag in local or remote metadata.json, pinned backups are skipped by retention and `delete` requires `--force`
+// empty location means pin backup on each storage where it exists
+func (b *Backuper) Pin(location, backupName string, pinned bool, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	switch location {
+	case "local":
+		return b.PinBackupLocal(ctx, backupName, pinned)
+	case "remote":
+		return b.PinBackupRemote(ctx, backupName, pinned)
+	case "":
+		localErr := b.PinBackupLocal(ctx, backupName, pinned)
+		if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+			return localErr
+		}
+		remoteErr := b.PinBackupRemote(ctx, backupName, pinned)
+		if localErr != nil && remoteErr != nil {
+			return fmt.Errorf("local: %v, remote: %v", localErr, remoteErr)
+		}
+		if localErr != nil {
+			log.Warn().Msgf("skip local pin: %v", localErr)
+		}
+		if remoteErr != nil {
+			log.Warn().Msgf("skip remote pin: %v", remoteErr)
+		}
+		return nil
+	default:
+		return fmt.Errorf("location must be 'local' or 'remote'")
+	}
+}
+
+func (b *Backuper) PinBackupLocal(ctx context.Context, backupName string, pinned bool) error {
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if err := b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return err
+	}
+	for _, disk := range disks {
+		backupMetaFile := path.Join(disk.Path, "backup", backupName, "metadata.json")
+		if disk.IsBackup || disk.Name == b.cfg.ClickHouse.EmbeddedBackupDisk {
+			backupMetaFile = path.Join(disk.Path, backupName, "metadata.json")
+		}
+		body, readErr := os.ReadFile(backupMetaFile)
+		if os.IsNotExist(readErr) {
+			continue
+		}
+		if readErr != nil {
+			return readErr
+		}
+		backupMetadata := metadata.BackupMetadata{}
+		if err = json.Unmarshal(body, &backupMetadata); err != nil {
+			return fmt.Errorf("'%s' is broken, can't parse %s: %v", backupName, backupMetaFile, err)
+		}
+		if err = b.checkBackupMetadataSignatureBeforeChange(&backupMetadata); err != nil {
+			return err
+		}
+		backupMetadata.Pinned = pinned
+		if err = backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+			return err
+		}
+		if err = backupMetadata.Save(backupMetaFile); err != nil {
+			return err
+		}
+		invalidateLocalBackupsScanCache()
+		log.Info().Str("operation", "pin").
+			Str("location", "local").
+			Str("backup", backupName).
+			Bool("pinned", pinned).
+			Str("duration", utils.HumanizeDuration(time.Since(start))).
+			Msg("done")
+		return nil
+	}
+	return fmt.Errorf("'%s' is not found on local storage", backupName)
+}
+
+func (b *Backuper) PinBackupRemote(ctx context.Context, backupName string, pinned bool) error {
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+		return fmt.Errorf("pin is not supported for remote_storage: %s", b.cfg.General.RemoteStorage)
+	}
+	if err := b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+
+	bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, "")
+	if err != nil {
+		return err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return fmt.Errorf("can't connect to remote storage: %v", err)
+	}
+	defer func() {
+		if err := bd.Close(ctx); err != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", err)
+		}
+	}()
+	r, err := bd.GetFileReader(ctx, path.Join(backupName, "metadata.json"))
+	if err != nil {
+		return fmt.Errorf("'%s' is not found on remote storage: %v", backupName, err)
+	}
+	body, err := io.ReadAll(r)
+	if closeErr := r.Close(); closeErr != nil {
+		log.Warn().Msgf("can't close %s/metadata.json reader: %v", backupName, closeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("can't read %s/metadata.json: %v", backupName, err)
+	}
+	backupMetadata := metadata.BackupMetadata{}
+	if err = json.Unmarshal(body, &backupMetadata); err != nil {
+		return fmt.Errorf("'%s' is broken, can't parse metadata.json: %v", backupName, err)
+	}
+	if err = b.checkBackupMetadataSignatureBeforeChange(&backupMetadata); err != nil {
+		return err
+	}
+	backupMetadata.Pinned = pinned
+	if err = backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
+	if err = bd.PutBackupMetadata(ctx, backupMetadata); err != nil {
+		return err
+	}
+	log.Info().Str("operation", "pin").
+		Str("location", "remote").
+		Str("backup", backupName).
+		Bool("pinned", pinned).
+		Str("duration", utils.HumanizeDuration(time.Since(start))).
+		Msg("done")
+	return nil
+}
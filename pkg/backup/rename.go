@@ -0,0 +1,279 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// Rename - rename local or remote backup, empty location means rename backup on each storage where it exists
+func (b *Backuper) Rename(location, backupName, newBackupName string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	if newBackupName == "" || newBackupName != utils.CleanBackupNameRE.ReplaceAllString(newBackupName, "") {
+		return fmt.Errorf("invalid new backup name '%s'", newBackupName)
+	}
+	switch location {
+	case "local":
+		return b.RenameBackupLocal(ctx, backupName, newBackupName)
+	case "remote":
+		return b.RenameBackupRemote(ctx, backupName, newBackupName)
+	case "":
+		localErr := b.RenameBackupLocal(ctx, backupName, newBackupName)
+		if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+			return localErr
+		}
+		remoteErr := b.RenameBackupRemote(ctx, backupName, newBackupName)
+		if localErr != nil && remoteErr != nil {
+			return fmt.Errorf("local: %v, remote: %v", localErr, remoteErr)
+		}
+		if localErr != nil {
+			log.Warn().Msgf("skip local rename: %v", localErr)
+		}
+		if remoteErr != nil {
+			log.Warn().Msgf("skip remote rename: %v", remoteErr)
+		}
+		return nil
+	default:
+		return fmt.Errorf("location must be 'local' or 'remote'")
+	}
+}
+
+// RenameBackupLocal - rename backup directories on all disks, update metadata.json and `required_backup` of dependent local backups
+func (b *Backuper) RenameBackupLocal(ctx context.Context, backupName, newBackupName string) error {
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if err := b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	backupList, disks, err := b.GetLocalBackups(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var backupToRename *LocalBackup
+	for i := range backupList {
+		if backupList[i].BackupName == newBackupName {
+			return fmt.Errorf("'%s' already exists on local storage", newBackupName)
+		}
+		if backupList[i].BackupName == backupName {
+			backupToRename = &backupList[i]
+		}
+	}
+	if backupToRename == nil {
+		return fmt.Errorf("'%s' is not found on local storage", backupName)
+	}
+	if backupToRename.Broken != "" {
+		return fmt.Errorf("'%s' is broken: %s", backupName, backupToRename.Broken)
+	}
+	if strings.Contains(backupToRename.Tags, "embedded") {
+		return fmt.Errorf("rename is not supported for embedded backups")
+	}
+	if b.hasObjectDisksLocal(backupList, backupName, disks) {
+		return fmt.Errorf("rename is not supported for backups with object disks, data keys in object storage contain backup name")
+	}
+	// metadata.json of renamed and dependent backups will be signed again, so check signatures before any change
+	if err = b.checkBackupMetadataSignatureBeforeChange(&backupToRename.BackupMetadata); err != nil {
+		return err
+	}
+	for i := range backupList {
+		if backupList[i].RequiredBackup != backupName || backupList[i].Broken != "" {
+			continue
+		}
+		if err = b.checkBackupMetadataSignatureBeforeChange(&backupList[i].BackupMetadata); err != nil {
+			return err
+		}
+	}
+	backupMetaFile := ""
+	for _, disk := range disks {
+		if disk.IsBackup {
+			continue
+		}
+		backupPath := path.Join(disk.Path, "backup", backupName)
+		if _, statErr := os.Stat(backupPath); os.IsNotExist(statErr) {
+			continue
+		}
+		newBackupPath := path.Join(disk.Path, "backup", newBackupName)
+		log.Info().Msgf("rename '%s' -> '%s'", backupPath, newBackupPath)
+		if err = os.Rename(backupPath, newBackupPath); err != nil {
+			return err
+		}
+		if _, statErr := os.Stat(path.Join(newBackupPath, "metadata.json")); statErr == nil {
+			backupMetaFile = path.Join(newBackupPath, "metadata.json")
+		}
+	}
+	if backupMetaFile == "" {
+		return fmt.Errorf("metadata.json for '%s' not found after rename", newBackupName)
+	}
+	backupToRename.BackupName = newBackupName
+	if err = backupToRename.BackupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
+	if err = backupToRename.BackupMetadata.Save(backupMetaFile); err != nil {
+		return err
+	}
+	invalidateLocalBackupsScanCache()
+	for _, backup := range backupList {
+		if backup.RequiredBackup != backupName || backup.Broken != "" {
+			continue
+		}
+		for _, disk := range disks {
+			dependentMetaFile := path.Join(disk.Path, "backup", backup.BackupName, "metadata.json")
+			if _, statErr := os.Stat(dependentMetaFile); statErr != nil {
+				continue
+			}
+			backup.RequiredBackup = newBackupName
+			if err = backup.BackupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+				return err
+			}
+			if err = backup.BackupMetadata.Save(dependentMetaFile); err != nil {
+				return err
+			}
+			invalidateLocalBackupsScanCache()
+			log.Info().Msgf("'%s' required_backup changed to '%s'", backup.BackupName, newBackupName)
+		}
+	}
+	log.Info().Str("operation", "rename").
+		Str("location", "local").
+		Str("backup", backupName).
+		Str("new_backup", newBackupName).
+		Str("duration", utils.HumanizeDuration(time.Since(start))).
+		Msg("done")
+	return nil
+}
+
+// RenameBackupRemote - most of remote storages don't support rename, so copy all backup files with new prefix, server-side when supported, write metadata.json last, and delete old backup
+func (b *Backuper) RenameBackupRemote(ctx context.Context, backupName, newBackupName string) error {
+	start := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+		return fmt.Errorf("rename is not supported for remote_storage: %s", b.cfg.General.RemoteStorage)
+	}
+	if err := b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+
+	bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, "")
+	if err != nil {
+		return err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return fmt.Errorf("can't connect to remote storage: %v", err)
+	}
+	defer func() {
+		if err := bd.Close(ctx); err != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", err)
+		}
+	}()
+	backupList, err := bd.BackupList(ctx, true, "")
+	if err != nil {
+		return err
+	}
+	var backupToRename *storage.Backup
+	for i := range backupList {
+		if backupList[i].BackupName == newBackupName {
+			return fmt.Errorf("'%s' already exists on remote storage", newBackupName)
+		}
+		if backupList[i].BackupName == backupName {
+			backupToRename = &backupList[i]
+		}
+	}
+	if backupToRename == nil {
+		return fmt.Errorf("'%s' is not found on remote storage", backupName)
+	}
+	if backupToRename.Broken != "" {
+		return fmt.Errorf("'%s' is broken: %s", backupName, backupToRename.Broken)
+	}
+	if strings.Contains(backupToRename.Tags, "embedded") {
+		return fmt.Errorf("rename is not supported for embedded backups")
+	}
+	if b.hasObjectDisksRemote(*backupToRename) {
+		return fmt.Errorf("rename is not supported for backups with object disks, data keys in object storage contain backup name")
+	}
+	// metadata.json of renamed and dependent backups will be signed again, so check signatures before any change
+	if err = b.checkBackupMetadataSignatureBeforeChange(&backupToRename.BackupMetadata); err != nil {
+		return err
+	}
+	for i := range backupList {
+		if backupList[i].RequiredBackup != backupName || backupList[i].Broken != "" {
+			continue
+		}
+		if err = b.checkBackupMetadataSignatureBeforeChange(&backupList[i].BackupMetadata); err != nil {
+			return err
+		}
+	}
+
+	// the same remote storage, so server-side copy is used where supported
+	copier := newRemoteObjectCopier(bd, bd, b.cfg, b.cfg)
+	copyGroup, copyCtx := errgroup.WithContext(ctx)
+	copyGroup.SetLimit(max(int(b.cfg.General.UploadConcurrency), 1))
+	walkErr := bd.Walk(ctx, backupName+"/", true, func(ctx context.Context, f storage.RemoteFile) error {
+		// azblob returns virtual directories, the same as in RemoveBackupRemote
+		if f.Name() == "metadata.json" || (bd.Kind() == "azblob" && f.Size() == 0 && f.LastModified().IsZero()) {
+			return nil
+		}
+		srcKey := path.Join(backupName, f.Name())
+		dstKey := path.Join(newBackupName, f.Name())
+		size := f.Size()
+		copyGroup.Go(func() error {
+			retry := storage.NewRetrier(&b.cfg.General)
+			return retry.RunCtx(copyCtx, func(ctx context.Context) error {
+				return copier.copy(ctx, size, srcKey, dstKey)
+			})
+		})
+		return nil
+	})
+	if wgWaitErr := copyGroup.Wait(); wgWaitErr != nil {
+		return fmt.Errorf("can't copy '%s' to '%s': %v", backupName, newBackupName, wgWaitErr)
+	}
+	if walkErr != nil {
+		return fmt.Errorf("can't walk '%s': %v", backupName, walkErr)
+	}
+	newBackupMetadata := backupToRename.BackupMetadata
+	newBackupMetadata.BackupName = newBackupName
+	if err = newBackupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
+	if err = bd.PutBackupMetadata(ctx, newBackupMetadata); err != nil {
+		return err
+	}
+	for _, backup := range backupList {
+		if backup.RequiredBackup != backupName || backup.Broken != "" {
+			continue
+		}
+		backup.RequiredBackup = newBackupName
+		if err = backup.BackupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+			return err
+		}
+		if err = bd.PutBackupMetadata(ctx, backup.BackupMetadata); err != nil {
+			return err
+		}
+		log.Info().Msgf("'%s' required_backup changed to '%s'", backup.BackupName, newBackupName)
+	}
+	if err = bd.RemoveBackupRemote(ctx, *backupToRename, b.cfg); err != nil {
+		return fmt.Errorf("'%s' copied to '%s', but can't delete old backup: %v", backupName, newBackupName, err)
+	}
+	log.Info().Str("operation", "rename").
+		Str("location", "remote").
+		Str("backup", backupName).
+		Str("new_backup", newBackupName).
+		Bool("server_side_copy", copier.useServerSideCopy.Load()).
+		Str("duration", utils.HumanizeDuration(time.Since(start))).
+		Msg("done")
+	return nil
+}
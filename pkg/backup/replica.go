@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// ReplicaUploadSuccess - value in metadata.json `replicas` for successfully uploaded replica, otherwise error message is stored
+const ReplicaUploadSuccess = "success"
+
+// getReplicaName - name of replica config file without extension, used as key for metadata.json `replicas` and as metrics label
+func getReplicaName(replicaConfig string) string {
+	return strings.TrimSuffix(filepath.Base(replicaConfig), filepath.Ext(replicaConfig))
+}
+
+// uploadReplicas - `general->replica_configs`, upload the same local backup to each replica after successful upload to main remote storage, failed replica doesn't stop upload to other replicas
+func (b *Backuper) uploadReplicas(backupName, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) (map[string]string, error) {
+	replicaStatuses := make(map[string]string, len(b.cfg.General.ReplicaConfigs))
+	replicaStatusesMutex := sync.Mutex{}
+	replicaGroup := errgroup.Group{}
+	if b.cfg.General.ReplicaUploadMode == "parallel" {
+		replicaGroup.SetLimit(len(b.cfg.General.ReplicaConfigs))
+	} else {
+		replicaGroup.SetLimit(1)
+	}
+	for _, replicaConfig := range b.cfg.General.ReplicaConfigs {
+		replicaName := getReplicaName(replicaConfig)
+		replicaGroup.Go(func() error {
+			start := time.Now()
+			replicaStatus := ReplicaUploadSuccess
+			if err := b.uploadReplica(replicaConfig, replicaName, backupName, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, backupVersion, commandId); err != nil {
+				log.Error().Str("replica", replicaName).Str("backup", backupName).Msgf("upload to replica return error: %v", err)
+				replicaStatus = err.Error()
+			} else {
+				log.Info().Fields(map[string]interface{}{
+					"backup":    backupName,
+					"operation": "upload_replica",
+					"replica":   replicaName,
+					"duration":  utils.HumanizeDuration(time.Since(start)),
+				}).Msg("done")
+			}
+			replicaStatusesMutex.Lock()
+			replicaStatuses[replicaName] = replicaStatus
+			replicaStatusesMutex.Unlock()
+			return nil
+		})
+	}
+	_ = replicaGroup.Wait()
+	failedReplicas := make([]string, 0)
+	for replicaName, replicaStatus := range replicaStatuses {
+		if replicaStatus != ReplicaUploadSuccess {
+			failedReplicas = append(failedReplicas, replicaName)
+		}
+	}
+	if len(failedReplicas) > 0 {
+		sort.Strings(failedReplicas)
+		return replicaStatuses, fmt.Errorf("upload to replicas %s failed, see metadata.json `replicas` for details", strings.Join(failedReplicas, ", "))
+	}
+	return replicaStatuses, nil
+}
+
+// uploadReplica - replica has own Backuper with own remote storage, retention and resumable state, local backup is never deleted by replica upload
+func (b *Backuper) uploadReplica(replicaConfig, replicaName, backupName, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) error {
+	replicaCfg, err := b.cfg.LoadReplicaConfig(replicaConfig)
+	if err != nil {
+		return err
+	}
+	replica := NewBackuper(replicaCfg)
+	replica.replicaName = replicaName
+	return replica.Upload(backupName, false, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, backupVersion, commandId)
+}
+
+// findRemoteBackupWithFailover - when main remote storage is unreachable or doesn't contain the backup, `general->replica_configs` are tried in the same order,
+// config of the first replica which contains the backup replaces main config, so required backups and object disks data are downloaded from the same replica
+func (b *Backuper) findRemoteBackupWithFailover(ctx context.Context, disks []clickhouse.Disk, backupName string) (storage.Backup, error) {
+	remoteBackup, err := b.findRemoteBackup(ctx, disks, backupName)
+	if err == nil || len(b.cfg.General.ReplicaConfigs) == 0 {
+		return remoteBackup, err
+	}
+	log.Warn().Str("backup", backupName).Msgf("main remote storage: %v, try replicas", err)
+	mainCfg := b.cfg
+	for _, replicaConfig := range mainCfg.General.ReplicaConfigs {
+		if ctx.Err() != nil {
+			b.cfg = mainCfg
+			return storage.Backup{}, ctx.Err()
+		}
+		replicaName := getReplicaName(replicaConfig)
+		replicaCfg, replicaErr := mainCfg.LoadReplicaConfig(replicaConfig)
+		if replicaErr == nil {
+			b.cfg = replicaCfg
+			if remoteBackup, replicaErr = b.findRemoteBackup(ctx, disks, backupName); replicaErr == nil {
+				log.Info().Str("backup", backupName).Str("replica", replicaName).Msg("download from replica")
+				return remoteBackup, nil
+			}
+		}
+		log.Warn().Str("backup", backupName).Str("replica", replicaName).Msgf("replica: %v", replicaErr)
+		err = fmt.Errorf("%v, replica %s: %v", err, replicaName, replicaErr)
+	}
+	b.cfg = mainCfg
+	return storage.Backup{}, err
+}
+
+// findRemoteBackup - connect to remote storage from b.cfg and find backup, b.dst is closed when backup is not found
+func (b *Backuper) findRemoteBackup(ctx context.Context, disks []clickhouse.Disk, backupName string) (storage.Backup, error) {
+	if err := b.initDisksPathsAndBackupDestination(ctx, disks, ""); err != nil {
+		return storage.Backup{}, err
+	}
+	remoteBackups, err := b.dst.BackupList(ctx, true, backupName)
+	if err == nil {
+		for _, r := range remoteBackups {
+			if backupName == r.BackupName {
+				return r, nil
+			}
+		}
+		err = fmt.Errorf("'%s' is not found on remote storage", backupName)
+	}
+	if closeErr := b.dst.Close(ctx); closeErr != nil {
+		log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+	}
+	return storage.Backup{}, err
+}
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+func TestGetReplicaName(t *testing.T) {
+	if name := getReplicaName("/etc/clickhouse-backup/replica-eu-west-1.yml"); name != "replica-eu-west-1" {
+		t.Fatalf("expected replica-eu-west-1, got %s", name)
+	}
+	if name := getReplicaName("cross-region"); name != "cross-region" {
+		t.Fatalf("expected cross-region, got %s", name)
+	}
+}
+
+func TestUploadReplicasContinueAfterFailure(t *testing.T) {
+	for _, mode := range []string{"sequential", "parallel"} {
+		cfg := config.DefaultConfig()
+		cfg.General.ReplicaUploadMode = mode
+		cfg.General.ReplicaConfigs = []string{"/nonexistent/in-region.yml", "/nonexistent/cross-region.yml"}
+		b := NewBackuper(cfg)
+		replicaStatuses, err := b.uploadReplicas("backup1", "", "", "", nil, false, false, "test", status.NotFromAPI)
+		if err == nil || !strings.Contains(err.Error(), "cross-region, in-region") {
+			t.Fatalf("mode=%s, expected error for both replicas, got %v", mode, err)
+		}
+		if len(replicaStatuses) != 2 {
+			t.Fatalf("mode=%s, expected status for each replica, got %v", mode, replicaStatuses)
+		}
+		for replicaName, replicaStatus := range replicaStatuses {
+			if !strings.Contains(replicaStatus, "can't open replica config file") {
+				t.Fatalf("mode=%s, unexpected status for %s: %s", mode, replicaName, replicaStatus)
+			}
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
-	"github.com/eapache/go-resiliency/retrier"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -18,7 +17,9 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -32,7 +33,9 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/events"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/hooks"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/keeper"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
@@ -44,7 +47,7 @@ import (
 var CreateDatabaseRE = regexp.MustCompile(`(?m)^CREATE DATABASE (\s*)(\S+)(\s*)`)
 
 // Restore - restore tables matched by tablePattern from backupName
-func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume, replicatedDDLWait bool, backupVersion string, commandId int) (err error) {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -53,6 +56,13 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 	defer cancel()
 	startRestore := time.Now()
 	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	// --dry-run doesn't change anything, so events, hooks and notifications are skipped
+	if !b.restoreDryRun {
+		events.Current.Publish(events.RestoreStarted, backupName, "local", nil)
+		defer func() {
+			b.notify("restore", backupName, startRestore, err)
+		}()
+	}
 	if err := b.prepareRestoreMapping(databaseMapping, "database"); err != nil {
 		return err
 	}
@@ -60,6 +70,13 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		return err
 	}
 
+	if b.attachOnly {
+		if schemaOnly || dropExists || rbacOnly || configsOnly {
+			return fmt.Errorf("--attach-only can't be used together with --schema, --rm, --rbac-only or --configs-only")
+		}
+		dataOnly = true
+	}
+
 	doRestoreData := (!schemaOnly && !rbacOnly && !configsOnly) || dataOnly
 
 	if err := b.ch.Connect(); err != nil {
@@ -71,6 +88,11 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		_ = b.PrintLocalBackups(ctx, "all")
 		return fmt.Errorf("select backup for restore")
 	}
+	if !b.restoreDryRun {
+		if err = hooks.Run(ctx, &b.cfg.Hooks, hooks.BeforeRestore, "restore", backupName, nil); err != nil {
+			return err
+		}
+	}
 	disks, err := b.ch.GetDisks(ctx, true)
 	if err != nil {
 		return err
@@ -79,6 +101,9 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 	if err != nil {
 		return err
 	}
+	if err = b.checkEmbeddedBackupVersion(version); err != nil {
+		return err
+	}
 	b.DefaultDataPath, err = b.ch.GetDefaultPath(disks)
 	if err != nil {
 		log.Warn().Msgf("%v", err)
@@ -114,9 +139,24 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 	if err := json.Unmarshal(backupMetadataBody, &backupMetadata); err != nil {
 		return err
 	}
+	if err := b.checkBackupMetadataSignature(&backupMetadata); err != nil {
+		return err
+	}
+	b.checkedBackupMetadata = &backupMetadata
 	b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
+	if b.isEmbedded && b.replicatedToMergeTree {
+		return fmt.Errorf("--replicated-to-merge-tree is not supported for embedded backup %s", backupName)
+	}
+	if !rbacOnly && !configsOnly {
+		b.checkRestoreCompatibility(ctx, backupMetadata, version)
+	}
+	if b.restoreDryRun {
+		restoreSchema := schemaOnly || dropExists || (schemaOnly == dataOnly && !rbacOnly && !configsOnly)
+		return b.restoreDryRunPlan(ctx, os.Stdout, backupName, backupMetadata, tablePattern, partitions, restoreSchema, doRestoreData, dropExists)
+	}
 
-	if schemaOnly || doRestoreData {
+	// --attach-only doesn't create anything, databases shall exist together with tables
+	if (schemaOnly || doRestoreData) && !b.attachOnly {
 		for _, database := range backupMetadata.Databases {
 			targetDB := database.Name
 			if !IsInformationSchema(targetDB) {
@@ -174,6 +214,7 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		if b.dst, err = storage.NewBackupDestination(ctx, b.cfg, b.ch, backupName); err != nil {
 			return err
 		}
+		b.dst.SetTransferObserver(b.transferObserver)
 		if err = b.dst.Connect(ctx); err != nil {
 			return fmt.Errorf("BackupDestination for embedded or object disk: can't connect to %s: %v", b.dst.Kind(), err)
 		}
@@ -213,11 +254,55 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		if err != nil {
 			return err
 		}
+		if len(tablesForRestore) == 0 && b.ignoreMissingTables {
+			log.Warn().Msgf("nothing to restore from %s by %s", backupName, tablePattern)
+			return nil
+		}
+	}
+	if doRestoreData {
+		if err = b.checkRestoreFreeSpace(ctx, tablesForRestore); err != nil {
+			return err
+		}
+	}
+	// check structure before drop partitions and attach any data, to avoid partially restored tables
+	if b.attachOnly && len(tablesForRestore) > 0 {
+		if err = b.checkAttachOnlyTables(ctx, tablesForRestore); err != nil {
+			return err
+		}
+	}
+	// do not create UDF when use --data, --rbac-only, --configs-only flags, https://github.com/Altinity/clickhouse-backup/issues/697
+	// UDF shall be created before schema, cause DEFAULT, MATERIALIZED expressions and views could use it
+	if schemaOnly || (schemaOnly == dataOnly && !rbacOnly && !configsOnly) {
+		for _, function := range backupMetadata.Functions {
+			if err = b.ch.CreateUserDefinedFunction(function.Name, function.CreateQuery, b.cfg.General.RestoreSchemaOnCluster); err != nil {
+				return fmt.Errorf("can't restore function %s: %v", function.Name, err)
+			}
+		}
+		if len(backupMetadata.Functions) > 0 {
+			log.Info().Msgf("%d user defined functions successfully restored", len(backupMetadata.Functions))
+		}
+	}
+	// when only selected partitions restored, existing tables keep schema and other partitions, only not existing tables will create
+	schemaTablesForRestore := tablesForRestore
+	var existsTablesForRestore ListOfTables
+	if len(partitions) > 0 && !schemaOnly && !dataOnly && !dropExists && !rbacOnly && !configsOnly && !b.isEmbedded {
+		if existsTablesForRestore, schemaTablesForRestore, err = b.splitExistsTablesForRestore(ctx, tablesForRestore); err != nil {
+			return err
+		}
+		if len(existsTablesForRestore) > 0 {
+			log.Info().Msgf("%d tables already exist, skip schema recreation and replace only %v partitions", len(existsTablesForRestore), partitions)
+		}
 	}
 	if schemaOnly || dropExists || (schemaOnly == dataOnly && !rbacOnly && !configsOnly) {
-		if err = b.RestoreSchema(ctx, backupName, backupMetadata, disks, tablesForRestore, ignoreDependencies, version); err != nil {
+		// dictionaries with local file source shall be created after file restored
+		if err = b.restoreDictionaryFiles(path.Dir(metadataPath), schemaTablesForRestore, disks); err != nil {
 			return err
 		}
+		if len(schemaTablesForRestore) > 0 || len(existsTablesForRestore) == 0 {
+			if err = b.RestoreSchema(ctx, backupName, backupMetadata, disks, schemaTablesForRestore, ignoreDependencies, replicatedDDLWait, version); err != nil {
+				return err
+			}
+		}
 	}
 	// https://github.com/Altinity/clickhouse-backup/issues/756
 	if dataOnly && !schemaOnly && !rbacOnly && !configsOnly && len(partitions) > 0 {
@@ -225,20 +310,16 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 			return err
 		}
 
+	} else if len(existsTablesForRestore) > 0 {
+		if err = b.dropExistPartitions(ctx, existsTablesForRestore, partitionsNames, partitions, version); err != nil {
+			return err
+		}
 	}
 	if dataOnly || (schemaOnly == dataOnly && !rbacOnly && !configsOnly) {
 		if err := b.RestoreData(ctx, backupName, backupMetadata, dataOnly, metadataPath, tablePattern, partitions, disks, version); err != nil {
 			return err
 		}
 	}
-	// do not create UDF when use --data, --rbac-only, --configs-only flags, https://github.com/Altinity/clickhouse-backup/issues/697
-	if schemaOnly || (schemaOnly == dataOnly && !rbacOnly && !configsOnly) {
-		for _, function := range backupMetadata.Functions {
-			if err = b.ch.CreateUserDefinedFunction(function.Name, function.CreateQuery, b.cfg.General.RestoreSchemaOnCluster); err != nil {
-				return err
-			}
-		}
-	}
 
 	//clean partially downloaded requiredBackup
 	if backupMetadata.RequiredBackup != "" {
@@ -276,19 +357,17 @@ func (b *Backuper) getTablesForRestoreLocal(ctx context.Context, backupName stri
 	if err != nil {
 		return nil, nil, err
 	}
-	// if restore-database-mapping is specified, create database in mapping rules instead of in backup files.
-	if len(b.cfg.General.RestoreDatabaseMapping) > 0 {
-		err = changeTableQueryToAdjustDatabaseMapping(&tablesForRestore, b.cfg.General.RestoreDatabaseMapping)
-		if err != nil {
-			return nil, nil, err
+	// patterns are checked before mapping, cause they contain source database and table names
+	if notMatchedPatterns := getNotMatchedTablePatterns(tablesForRestore, tablePattern); len(notMatchedPatterns) > 0 {
+		if b.strictTablePattern && !b.ignoreMissingTables {
+			return nil, nil, fmt.Errorf("table pattern %s doesn't match any table in %s, also check skip_tables and skip_table_engines setting, use --ignore-missing to skip them", strings.Join(notMatchedPatterns, ","), backupName)
 		}
-		partitionsNames, err = changePartitionsToAdjustDatabaseMapping(partitionsNames, b.cfg.General.RestoreDatabaseMapping)
-		if err != nil {
-			return nil, nil, err
+		if b.ignoreMissingTables {
+			log.Warn().Msgf("table pattern %s doesn't match any table in %s, skipped due --ignore-missing", strings.Join(notMatchedPatterns, ","), backupName)
 		}
 	}
-
 	// if restore-table-mapping is specified, create table in mapping rules instead of in backup files.
+	// table mapping shall be applied before database mapping, cause `db.table` rules contain source database
 	// https://github.com/Altinity/clickhouse-backup/issues/937
 	if len(b.cfg.General.RestoreTableMapping) > 0 {
 		err = changeTableQueryToAdjustTableMapping(&tablesForRestore, b.cfg.General.RestoreTableMapping)
@@ -301,7 +380,19 @@ func (b *Backuper) getTablesForRestoreLocal(ctx context.Context, backupName stri
 		}
 	}
 
-	if len(tablesForRestore) == 0 {
+	// if restore-database-mapping is specified, create database in mapping rules instead of in backup files.
+	if len(b.cfg.General.RestoreDatabaseMapping) > 0 {
+		err = changeTableQueryToAdjustDatabaseMapping(&tablesForRestore, b.cfg.General.RestoreDatabaseMapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		partitionsNames, err = changePartitionsToAdjustDatabaseMapping(partitionsNames, b.cfg.General.RestoreDatabaseMapping)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(tablesForRestore) == 0 && !b.ignoreMissingTables {
 		return nil, nil, fmt.Errorf("not found schemas by %s in %s, also check skip_tables and skip_table_engines setting", tablePattern, backupName)
 	}
 	return tablesForRestore, partitionsNames, nil
@@ -366,10 +457,7 @@ func (b *Backuper) executeShellCommandWithTimeout(ctx context.Context, cmd strin
 }
 
 func (b *Backuper) restoreEmptyDatabase(ctx context.Context, targetDB, tablePattern string, database metadata.DatabasesMeta, dropTable, schemaOnly, ignoreDependencies bool, version int) error {
-	isMapped := false
-	if targetDB, isMapped = b.cfg.General.RestoreDatabaseMapping[database.Name]; !isMapped {
-		targetDB = database.Name
-	}
+	targetDB, databaseQuery := b.prepareDatabaseQueryForRestore(database)
 	// https://github.com/Altinity/clickhouse-backup/issues/583
 	// https://github.com/Altinity/clickhouse-backup/issues/663
 	if ShallSkipDatabase(b.cfg, targetDB, tablePattern) {
@@ -396,13 +484,26 @@ func (b *Backuper) restoreEmptyDatabase(ctx context.Context, targetDB, tablePatt
 		}
 
 	}
-	substitution := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS ${1}`%s`${3}", targetDB)
-	if err := b.ch.CreateDatabaseFromQuery(ctx, CreateDatabaseRE.ReplaceAllString(database.Query, substitution), b.cfg.General.RestoreSchemaOnCluster); err != nil {
+	if err := b.ch.CreateDatabaseFromQuery(ctx, databaseQuery, b.cfg.General.RestoreSchemaOnCluster); err != nil {
 		return err
 	}
 	return nil
 }
 
+// prepareDatabaseQueryForRestore - return target database name according to restore_database_mapping and CREATE DATABASE IF NOT EXISTS query for it
+func (b *Backuper) prepareDatabaseQueryForRestore(database metadata.DatabasesMeta) (string, string) {
+	targetDB, isMapped := b.cfg.General.RestoreDatabaseMapping[database.Name]
+	if !isMapped {
+		targetDB = database.Name
+	}
+	substitution := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS ${1}`%s`${3}", targetDB)
+	databaseQuery := CreateDatabaseRE.ReplaceAllString(database.Query, substitution)
+	if b.replicatedToMergeTree {
+		databaseQuery = replicatedDatabaseEngineRE.ReplaceAllString(databaseQuery, "ENGINE = Atomic")
+	}
+	return targetDB, databaseQuery
+}
+
 func (b *Backuper) prepareRestoreMapping(objectMapping []string, objectType string) error {
 	if objectType != "database" && objectType != "table" {
 		return fmt.Errorf("objectType must be one of `database` or `table`")
@@ -410,17 +511,46 @@ func (b *Backuper) prepareRestoreMapping(objectMapping []string, objectType stri
 	for i := 0; i < len(objectMapping); i++ {
 		splitByCommas := strings.Split(objectMapping[i], ",")
 		for _, m := range splitByCommas {
+			if m = strings.Trim(m, " \t\r\n"); m == "" {
+				continue
+			}
 			splitByColon := strings.Split(m, ":")
-			if len(splitByColon) != 2 {
+			if len(splitByColon) != 2 || strings.TrimSpace(splitByColon[0]) == "" || strings.TrimSpace(splitByColon[1]) == "" {
 				objectTypeTitleCase := cases.Title(language.Und).String(objectType)
 				return fmt.Errorf("restore-%s-mapping %s should only have src%s:destination%s format for each map rule", objectType, m, objectTypeTitleCase, objectTypeTitleCase)
 			}
+			src, dst := strings.TrimSpace(splitByColon[0]), strings.TrimSpace(splitByColon[1])
 			if objectType == "database" {
-				b.cfg.General.RestoreDatabaseMapping[splitByColon[0]] = splitByColon[1]
+				b.cfg.General.RestoreDatabaseMapping[src] = dst
 			} else {
-				b.cfg.General.RestoreTableMapping[splitByColon[0]] = splitByColon[1]
+				// `db.table:db.table_restored`, target table is created in the same database, use --restore-database-mapping to change database
+				if dstDb, dstTable, isQualified := strings.Cut(dst, "."); isQualified {
+					if srcDb, _, isSrcQualified := strings.Cut(src, "."); !isSrcQualified || srcDb != dstDb {
+						return fmt.Errorf("restore-table-mapping %s shall use the same database in source and destination, use --restore-database-mapping to change database", m)
+					}
+					dst = dstTable
+				}
+				b.cfg.General.RestoreTableMapping[src] = dst
+			}
+		}
+	}
+	if objectType == "database" {
+		return checkRestoreDatabaseMappingTargets(b.cfg.General.RestoreDatabaseMapping)
+	}
+	return nil
+}
+
+// checkRestoreDatabaseMappingTargets - two source databases mapped into one target database could silently overwrite tables with the same name
+func checkRestoreDatabaseMappingTargets(databaseMapping map[string]string) error {
+	sources := make(map[string]string, len(databaseMapping))
+	for src, dst := range databaseMapping {
+		if existsSrc, exists := sources[dst]; exists {
+			if existsSrc > src {
+				existsSrc, src = src, existsSrc
 			}
+			return fmt.Errorf("restore-database-mapping %s:%s and %s:%s have the same target database", existsSrc, dst, src, dst)
 		}
+		sources[dst] = src
 	}
 	return nil
 }
@@ -809,6 +939,10 @@ func (b *Backuper) restoreRBACReplicated(backupName string, backupPrefixDir stri
 
 // restoreConfigs - copy backup_name/configs folder to /etc/clickhouse-server/
 func (b *Backuper) restoreConfigs(backupName string, disks []clickhouse.Disk) error {
+	keeperBackupDir := path.Join(b.DefaultDataPath, "backup", backupName, "keeper")
+	if _, err := os.Stat(keeperBackupDir); err == nil {
+		log.Warn().Msgf("%s contains Keeper snapshot which is not restored, stop clickhouse-keeper and copy snapshot file into keeper_server/snapshot_storage_path manually", keeperBackupDir)
+	}
 	if err := b.restoreBackupRelatedDir(backupName, "configs", b.ch.Config.ConfigDir, disks, nil); err != nil && os.IsNotExist(err) {
 		return nil
 	} else {
@@ -862,22 +996,57 @@ func (b *Backuper) restoreBackupRelatedDir(backupName, backupPrefixDir, destinat
 	return nil
 }
 
+// splitExistsTablesForRestore - return tables which already exist in clickhouse and tables which shall be created
+func (b *Backuper) splitExistsTablesForRestore(ctx context.Context, tablesForRestore ListOfTables) (ListOfTables, ListOfTables, error) {
+	var existsTables []struct {
+		Database string `ch:"database"`
+		Name     string `ch:"name"`
+	}
+	if err := b.ch.SelectContext(ctx, &existsTables, "SELECT database, name FROM system.tables WHERE is_temporary=0"); err != nil {
+		return nil, nil, fmt.Errorf("can't get exists tables: %v", err)
+	}
+	existsTablesMap := make(map[metadata.TableTitle]struct{}, len(existsTables))
+	for _, t := range existsTables {
+		existsTablesMap[metadata.TableTitle{Database: t.Database, Table: t.Name}] = struct{}{}
+	}
+	exists, notExists := b.splitTablesByExistence(tablesForRestore, existsTablesMap)
+	return exists, notExists, nil
+}
+
+// splitTablesByExistence - tablesForRestore contains source names, so compare destination names after --restore-database-mapping and --restore-table-mapping
+func (b *Backuper) splitTablesByExistence(tablesForRestore ListOfTables, existsTablesMap map[metadata.TableTitle]struct{}) (ListOfTables, ListOfTables) {
+	exists := make(ListOfTables, 0)
+	notExists := make(ListOfTables, 0)
+	for _, table := range tablesForRestore {
+		if _, isExists := existsTablesMap[b.getDstTableTitle(table)]; isExists {
+			exists = append(exists, table)
+		} else {
+			notExists = append(notExists, table)
+		}
+	}
+	return exists, notExists
+}
+
 // execute ALTER TABLE db.table DROP PARTITION for corner case when we try to restore backup with the same structure, https://github.com/Altinity/clickhouse-backup/issues/756
 func (b *Backuper) dropExistPartitions(ctx context.Context, tablesForRestore ListOfTables, partitionsIdMap map[metadata.TableTitle][]string, partitions []string, version int) error {
 	for _, table := range tablesForRestore {
 		if !strings.Contains(table.Query, "MergeTree") {
 			continue
 		}
-		partitionsIds, isExists := partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Table}]
+		dst := b.getDstTableTitle(table)
+		partitionsIds, isExists := partitionsIdMap[dst]
 		if !isExists {
-			return fmt.Errorf("`%s`.`%s` doesn't contains %#v partitions", table.Database, table.Table, partitions)
+			return fmt.Errorf("`%s`.`%s` doesn't contains %#v partitions", dst.Database, dst.Table, partitions)
+		}
+		if len(partitionsIds) == 0 {
+			continue
 		}
 		partitionsSQL := fmt.Sprintf("DROP PARTITION %s", strings.Join(partitionsIds, ", DROP PARTITION "))
 		settings := ""
 		if version >= 19017000 {
 			settings = "SETTINGS mutations_sync=2"
 		}
-		err := b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` %s %s", table.Database, table.Table, partitionsSQL, settings))
+		err := b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` %s %s", dst.Database, dst.Table, partitionsSQL, settings))
 		if err != nil {
 			return err
 		}
@@ -886,8 +1055,17 @@ func (b *Backuper) dropExistPartitions(ctx context.Context, tablesForRestore Lis
 }
 
 // RestoreSchema - restore schemas matched by tablePattern from backupName
-func (b *Backuper) RestoreSchema(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, disks []clickhouse.Disk, tablesForRestore ListOfTables, ignoreDependencies bool, version int) error {
+func (b *Backuper) RestoreSchema(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, disks []clickhouse.Disk, tablesForRestore ListOfTables, ignoreDependencies, replicatedDDLWait bool, version int) error {
 	startRestoreSchema := time.Now()
+	var err error
+	if b.replicatedDatabases, err = b.getReplicatedDatabases(ctx); err != nil {
+		return err
+	}
+	if !b.isEmbedded {
+		if err = b.checkStoragePoliciesExist(tablesForRestore, disks); err != nil {
+			return err
+		}
+	}
 	if dropErr := b.dropExistsTables(tablesForRestore, ignoreDependencies, version); dropErr != nil {
 		return dropErr
 	}
@@ -900,6 +1078,11 @@ func (b *Backuper) RestoreSchema(ctx context.Context, backupName string, backupM
 	if restoreErr != nil {
 		return restoreErr
 	}
+	if replicatedDDLWait {
+		if err = b.waitReplicatedDatabaseDDL(ctx, tablesForRestore, version); err != nil {
+			return err
+		}
+	}
 	log.Info().Fields(map[string]interface{}{
 		"backup":    backupName,
 		"operation": "restore_schema",
@@ -908,6 +1091,67 @@ func (b *Backuper) RestoreSchema(ctx context.Context, backupName string, backupM
 	return nil
 }
 
+// checkStoragePoliciesExist - tables with storage_policy setting can't be created when policy is absent in system.storage_policies, fail before drop or create any table
+func (b *Backuper) checkStoragePoliciesExist(tablesForRestore ListOfTables, disks []clickhouse.Disk) error {
+	existsPolicies := map[string]struct{}{"default": {}}
+	for _, disk := range disks {
+		for _, policy := range disk.StoragePolicies {
+			existsPolicies[policy] = struct{}{}
+		}
+	}
+	var missingPolicies []string
+	for _, table := range tablesForRestore {
+		storagePolicy := b.extractMappedStoragePolicy(table.Query)
+		if _, exists := existsPolicies[storagePolicy]; !exists {
+			missingPolicies = append(missingPolicies, fmt.Sprintf("%s.%s storage_policy='%s'", table.Database, table.Table, storagePolicy))
+		}
+	}
+	if len(missingPolicies) > 0 {
+		return fmt.Errorf("storage policies not found in system.storage_policies, add them into clickhouse-server configuration or use clickhouse->storage_policy_mapping before restore: %s", strings.Join(missingPolicies, ", "))
+	}
+	return nil
+}
+
+// extractMappedStoragePolicy - storage policy which table will use after restore according to `storage_policy_mapping`
+func (b *Backuper) extractMappedStoragePolicy(query string) string {
+	storagePolicy := b.ch.ExtractStoragePolicy(query)
+	if targetPolicy, exists := b.cfg.ClickHouse.StoragePolicyMapping[storagePolicy]; exists {
+		return targetPolicy
+	}
+	return storagePolicy
+}
+
+var storagePolicySettingRE = regexp.MustCompile(`(storage_policy\s*=\s*)'([^']+)'`)
+
+// applyStoragePolicyMapping - replace storage_policy setting in table query, allow restore tables from hot/cold tiers into server with other disks layout
+func applyStoragePolicyMapping(query string, policyMapping map[string]string) (string, bool) {
+	isChanged := false
+	query = storagePolicySettingRE.ReplaceAllStringFunc(query, func(setting string) string {
+		matches := storagePolicySettingRE.FindStringSubmatch(setting)
+		if targetPolicy, exists := policyMapping[matches[2]]; exists && targetPolicy != matches[2] {
+			isChanged = true
+			return matches[1] + "'" + targetPolicy + "'"
+		}
+		return setting
+	})
+	return query, isChanged
+}
+
+var projectionNameRE = regexp.MustCompile("PROJECTION\\s+(?:`([^`]+)`|([^\\s(]+))\\s*\\(\\s*SELECT")
+
+// extractProjectionNames - projection names from CREATE TABLE query, used for `--rebuild-projections`
+func extractProjectionNames(query string) []string {
+	var projections []string
+	for _, matches := range projectionNameRE.FindAllStringSubmatch(query, -1) {
+		if matches[1] != "" {
+			projections = append(projections, matches[1])
+		} else {
+			projections = append(projections, matches[2])
+		}
+	}
+	return projections
+}
+
 var UUIDWithMergeTreeRE = regexp.MustCompile(`^(.+)(UUID)(\s+)'([^']+)'(.+)({uuid})(.*)`)
 
 var emptyReplicatedMergeTreeRE = regexp.MustCompile(`(?m)Replicated(MergeTree|ReplacingMergeTree|SummingMergeTree|AggregatingMergeTree|CollapsingMergeTree|VersionedCollapsingMergeTree|GraphiteMergeTree)\s*\(([^']*)\)(.*)`)
@@ -1094,17 +1338,21 @@ func (b *Backuper) restoreSchemaRegular(ctx context.Context, tablesForRestore Li
 					isDatabaseCreated[schema.Database] = struct{}{}
 				}
 			}
-			//materialized and window views should restore via ATTACH
-			b.replaceCreateToAttachForView(&schema)
+			b.prepareSchemaQueryForRestore(&schema)
 			// https://github.com/Altinity/clickhouse-backup/issues/849
 			b.checkReplicaAlreadyExistsAndChangeReplicationPath(ctx, &schema, version)
 
 			// https://github.com/Altinity/clickhouse-backup/issues/466
 			b.replaceUUIDMacroValue(&schema)
+			// restore could run on each replica, DDL inside ENGINE=Replicated database already replayed from other replica
+			if b.isTableCreatedByOtherReplica(ctx, schema.Database, schema.Table) {
+				log.Info().Msgf("`%s`.`%s` already created via Replicated database DDL log, skip", schema.Database, schema.Table)
+				continue
+			}
 			restoreErr = b.ch.CreateTable(clickhouse.Table{
 				Database: schema.Database,
 				Name:     schema.Table,
-			}, schema.Query, false, false, b.cfg.General.RestoreSchemaOnCluster, version, b.DefaultDataPath)
+			}, schema.Query, false, false, b.onClusterForDatabase(schema.Database), version, b.DefaultDataPath)
 
 			if restoreErr != nil {
 				restoreRetries++
@@ -1129,6 +1377,27 @@ func (b *Backuper) restoreSchemaRegular(ctx context.Context, tablesForRestore Li
 	return nil
 }
 
+// prepareSchemaQueryForRestore - apply query changes which don't depend on clickhouse-server state, used for restore and restore --dry-run
+func (b *Backuper) prepareSchemaQueryForRestore(schema *metadata.TableMetadata) {
+	//materialized and window views should restore via ATTACH
+	b.replaceCreateToAttachForView(schema)
+	if b.replicatedToMergeTree {
+		schema.Query = convertReplicatedToMergeTree(schema.Query)
+	}
+	if len(b.cfg.ClickHouse.ReplicationPathMapping) > 0 {
+		if changedQuery, isChanged := applyReplicationPathMapping(schema.Query, b.cfg.ClickHouse.ReplicationPathMapping, b.cfg.ClickHouse.DefaultReplicaName); isChanged {
+			log.Info().Msgf("`%s`.`%s` replication path changed according to `replication_path_mapping`", schema.Database, schema.Table)
+			schema.Query = changedQuery
+		}
+	}
+	if len(b.cfg.ClickHouse.StoragePolicyMapping) > 0 {
+		if changedQuery, isChanged := applyStoragePolicyMapping(schema.Query, b.cfg.ClickHouse.StoragePolicyMapping); isChanged {
+			log.Info().Msgf("`%s`.`%s` storage_policy changed according to `storage_policy_mapping`", schema.Database, schema.Table)
+			schema.Query = changedQuery
+		}
+	}
+}
+
 var replicatedParamsRE = regexp.MustCompile(`(Replicated[a-zA-Z]*MergeTree)\('([^']+)'(\s*,\s*)'([^']+)'\)|(Replicated[a-zA-Z]*MergeTree)\(\)`)
 var replicatedUuidRE = regexp.MustCompile(` UUID '([^']+)'`)
 
@@ -1193,6 +1462,38 @@ func (b *Backuper) checkReplicaAlreadyExistsAndChangeReplicationPath(ctx context
 	}
 }
 
+var replicatedTableEngineRE = regexp.MustCompile(`\bReplicated([a-zA-Z]*MergeTree)\((\s*'[^']*'\s*,\s*'[^']*'\s*,?)?\s*`)
+
+// convertReplicatedToMergeTree - remove Replicated prefix and keeper path with replica name arguments, other engine arguments stay as is
+func convertReplicatedToMergeTree(query string) string {
+	return replicatedTableEngineRE.ReplaceAllString(query, "${1}(")
+}
+
+// applyReplicationPathMapping - replace replication path prefix from backup with longest matched prefix from `replication_path_mapping`,
+// replica name without macros belongs to source cluster, so it replaced with `default_replica_name`
+func applyReplicationPathMapping(query string, pathMapping map[string]string, defaultReplicaName string) (string, bool) {
+	matches := replicatedParamsRE.FindStringSubmatch(query)
+	if len(matches) == 0 || matches[5] != "" {
+		return query, false
+	}
+	engine, replicaPath, delimiter, replicaName := matches[1], matches[2], matches[3], matches[4]
+	sourcePrefix := ""
+	for prefix := range pathMapping {
+		if strings.HasPrefix(replicaPath, prefix) && len(prefix) > len(sourcePrefix) {
+			sourcePrefix = prefix
+		}
+	}
+	if sourcePrefix == "" {
+		return query, false
+	}
+	newReplicaPath := pathMapping[sourcePrefix] + strings.TrimPrefix(replicaPath, sourcePrefix)
+	newReplicaName := replicaName
+	if !strings.Contains(replicaName, "{") && defaultReplicaName != "" {
+		newReplicaName = defaultReplicaName
+	}
+	return strings.Replace(query, engine+"('"+replicaPath+"'"+delimiter+"'"+replicaName+"')", engine+"('"+newReplicaPath+"', '"+newReplicaName+"')", 1), true
+}
+
 func (b *Backuper) replaceUUIDMacroValue(schema *metadata.TableMetadata) {
 	if b.cfg.General.RestoreSchemaOnCluster == "" && strings.Contains(schema.Query, "{uuid}") && strings.Contains(schema.Query, "Replicated") {
 		if !strings.Contains(schema.Query, "UUID") {
@@ -1236,7 +1537,7 @@ func (b *Backuper) dropExistsTables(tablesForDrop ListOfTables, ignoreDependenci
 					dropErr = b.ch.DropTable(clickhouse.Table{
 						Database: schema.Database,
 						Name:     schema.Table,
-					}, query, b.cfg.General.RestoreSchemaOnCluster, ignoreDependencies, version, b.DefaultDataPath)
+					}, query, b.onClusterForDatabase(schema.Database), ignoreDependencies, version, b.DefaultDataPath)
 					if dropErr == nil {
 						tablesForDrop[i].Query = query
 						break
@@ -1246,7 +1547,7 @@ func (b *Backuper) dropExistsTables(tablesForDrop ListOfTables, ignoreDependenci
 				dropErr = b.ch.DropTable(clickhouse.Table{
 					Database: schema.Database,
 					Name:     schema.Table,
-				}, schema.Query, b.cfg.General.RestoreSchemaOnCluster, ignoreDependencies, version, b.DefaultDataPath)
+				}, schema.Query, b.onClusterForDatabase(schema.Database), ignoreDependencies, version, b.DefaultDataPath)
 			}
 
 			if dropErr != nil {
@@ -1349,7 +1650,14 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 		return fmt.Errorf("%s is not created. Restore schema first or create missing tables manually", strings.Join(missingTables, ", "))
 	}
 	restoreBackupWorkingGroup, restoreCtx := errgroup.WithContext(ctx)
-	restoreBackupWorkingGroup.SetLimit(max(b.cfg.ClickHouse.MaxConnections, 1))
+	restoreConcurrency := b.cfg.ClickHouse.MaxConnections
+	if b.cfg.ClickHouse.RestoreConcurrency > 0 {
+		restoreConcurrency = b.cfg.ClickHouse.RestoreConcurrency
+	}
+	restoreBackupWorkingGroup.SetLimit(max(restoreConcurrency, 1))
+	log.Debug().Msgf("prepare restore table concurrent semaphore with concurrency=%d len(tables)=%d", max(restoreConcurrency, 1), len(tablesForRestore))
+	// parts copy and ATTACH PART run concurrently, DETACH/ATTACH TABLE for restore_as_attach is serialized
+	var ddlMutex sync.Mutex
 
 	for i := range tablesForRestore {
 		tableRestoreStartTime := time.Now()
@@ -1366,7 +1674,7 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 		}
 		// https://github.com/Altinity/clickhouse-backup/issues/937
 		if len(b.cfg.General.RestoreTableMapping) > 0 {
-			if targetTable, isMapped := b.cfg.General.RestoreTableMapping[table.Table]; isMapped {
+			if targetTable, isMapped := getRestoreTableMapping(b.cfg.General.RestoreTableMapping, table.Database, table.Table); isMapped {
 				dstTableName = targetTable
 				tablesForRestore[i].Table = targetTable
 			}
@@ -1382,7 +1690,7 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 		restoreBackupWorkingGroup.Go(func() error {
 			// https://github.com/Altinity/clickhouse-backup/issues/529
 			if b.cfg.ClickHouse.RestoreAsAttach {
-				if restoreErr := b.restoreDataRegularByAttach(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, logger); restoreErr != nil {
+				if restoreErr := b.restoreDataRegularByAttach(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, &ddlMutex, logger); restoreErr != nil {
 					return restoreErr
 				}
 			} else {
@@ -1390,12 +1698,26 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 					return restoreErr
 				}
 			}
+			if b.includeDetached {
+				if restoreErr := b.restoreDetachedParts(path.Join(b.DefaultDataPath, "backup", backupName), table, dstTable, disks); restoreErr != nil {
+					return restoreErr
+				}
+			}
 			// https://github.com/Altinity/clickhouse-backup/issues/529
 			for _, mutation := range table.Mutations {
 				if err := b.ch.ApplyMutation(restoreCtx, tablesForRestore[idx], mutation); err != nil {
 					log.Warn().Msgf("can't apply mutation %s for table `%s`.`%s`	: %v", mutation.Command, tablesForRestore[idx].Database, tablesForRestore[idx].Table, err)
 				}
 			}
+			if b.rebuildProjections {
+				for _, projection := range extractProjectionNames(table.Query) {
+					materializeProjection := metadata.MutationMetadata{Command: fmt.Sprintf("MATERIALIZE PROJECTION `%s`", projection)}
+					if err := b.ch.ApplyMutation(restoreCtx, tablesForRestore[idx], materializeProjection); err != nil {
+						return fmt.Errorf("can't rebuild projection %s for table `%s`.`%s`: %v", projection, tablesForRestore[idx].Database, tablesForRestore[idx].Table, err)
+					}
+					logger.Info().Str("projection", projection).Msg("materialize projection started")
+				}
+			}
 			log.Info().Fields(map[string]interface{}{
 				"duration":  utils.HumanizeDuration(time.Since(tableRestoreStartTime)),
 				"operation": "restoreDataRegular",
@@ -1412,7 +1734,7 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 	return nil
 }
 
-func (b *Backuper) restoreDataRegularByAttach(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, table metadata.TableMetadata, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, logger zerolog.Logger) error {
+func (b *Backuper) restoreDataRegularByAttach(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, table metadata.TableMetadata, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, ddlMutex *sync.Mutex, logger zerolog.Logger) error {
 	if err := filesystemhelper.HardlinkBackupPartsToStorage(backupName, table, disks, diskMap, dstTable.DataPaths, b.ch, false); err != nil {
 		return fmt.Errorf("can't copy data to storage '%s.%s': %v", table.Database, table.Table, err)
 	}
@@ -1426,6 +1748,8 @@ func (b *Backuper) restoreDataRegularByAttach(ctx context.Context, backupName st
 	if size > 0 {
 		logger.Info().Str("duration", utils.HumanizeDuration(time.Since(start))).Str("size", utils.FormatBytes(uint64(size))).Msg("download object_disks finish")
 	}
+	ddlMutex.Lock()
+	defer ddlMutex.Unlock()
 	if err := b.ch.AttachTable(ctx, table, dstTable); err != nil {
 		return fmt.Errorf("can't attach table '%s.%s': %v", table.Database, table.Table, err)
 	}
@@ -1501,7 +1825,7 @@ func (b *Backuper) downloadObjectDiskParts(ctx context.Context, backupName strin
 			}
 			start := time.Now()
 			downloadObjectDiskPartsWorkingGroup, downloadCtx := errgroup.WithContext(ctx)
-			downloadObjectDiskPartsWorkingGroup.SetLimit(int(b.cfg.General.ObjectDiskServerSideCopyConcurrency))
+			downloadObjectDiskPartsWorkingGroup.SetLimit(max(int(b.cfg.General.ObjectDiskServerSideCopyConcurrency), 1))
 			var isCopyFailed atomic.Bool
 			isCopyFailed.Store(false)
 			for _, part := range parts {
@@ -1575,7 +1899,7 @@ func (b *Backuper) downloadObjectDiskParts(ctx context.Context, backupName strin
 							copiedSize := int64(0)
 							var copyObjectErr error
 							if !b.cfg.General.AllowObjectDiskStreaming {
-								retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+								retry := storage.NewRetrier(&b.cfg.General)
 								copyObjectErr = retry.RunCtx(downloadCtx, func(ctx context.Context) error {
 									var retryErr error
 									copiedSize, retryErr = object_disk.CopyObject(downloadCtx, dstDiskName, storageObject.ObjectSize, srcBucket, srcKey, storageObject.ObjectRelativePath)
@@ -1602,7 +1926,7 @@ func (b *Backuper) downloadObjectDiskParts(ctx context.Context, backupName strin
 									}
 									dstStorage := dstConnection.GetRemoteStorage()
 									dstKey := path.Join(dstConnection.GetRemoteObjectDiskPath(), storageObject.ObjectRelativePath)
-									retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+									retry := storage.NewRetrier(&b.cfg.General)
 									copyObjectErr = retry.RunCtx(downloadCtx, func(ctx context.Context) error {
 										return object_disk.CopyObjectStreaming(downloadCtx, srcStorage, dstStorage, srcKey, dstKey)
 									})
@@ -1674,7 +1998,7 @@ func (b *Backuper) checkMissingTables(tablesForRestore ListOfTables, chTables []
 			}
 		}
 		if len(b.cfg.General.RestoreTableMapping) > 0 {
-			if targetTable, isMapped := b.cfg.General.RestoreTableMapping[table.Table]; isMapped {
+			if targetTable, isMapped := getRestoreTableMapping(b.cfg.General.RestoreTableMapping, table.Database, table.Table); isMapped {
 				dstTable = targetTable
 			}
 		}
@@ -1692,6 +2016,197 @@ func (b *Backuper) checkMissingTables(tablesForRestore ListOfTables, chTables []
 	return missingTables
 }
 
+// checkRestoreCompatibility - compare server version, table engines and changed settings recorded in backup metadata with destination server, only warnings, cause restore could still succeed
+func (b *Backuper) checkRestoreCompatibility(ctx context.Context, backupMetadata metadata.BackupMetadata, version int) {
+	var serverEngines map[string]struct{}
+	var err error
+	if len(backupMetadata.TableEngines) > 0 {
+		if serverEngines, err = b.ch.GetTableEngines(ctx); err != nil {
+			log.Warn().Msgf("%v", err)
+		}
+	}
+	var serverSettings map[string]bool
+	if len(backupMetadata.ClickHouseSettings) > 0 {
+		serverSettings = make(map[string]bool, len(backupMetadata.ClickHouseSettings))
+		for name := range backupMetadata.ClickHouseSettings {
+			serverSettings[name] = false
+		}
+		if serverSettings, err = b.ch.CheckSettingsExists(ctx, serverSettings); err != nil {
+			log.Warn().Msgf("can't check settings exists: %v", err)
+			serverSettings = nil
+		}
+	}
+	for _, warning := range getRestoreCompatibilityWarnings(backupMetadata, version, serverEngines, serverSettings) {
+		log.Warn().Str("backup", backupMetadata.BackupName).Msg(warning)
+	}
+}
+
+// getRestoreCompatibilityWarnings - nil serverEngines or serverSettings means the check is skipped
+func getRestoreCompatibilityWarnings(backupMetadata metadata.BackupMetadata, serverVersion int, serverEngines map[string]struct{}, serverSettings map[string]bool) []string {
+	warnings := make([]string, 0)
+	if backupVersion := backupMetadata.GetClickHouseVersionInteger(); backupVersion > 0 && serverVersion > 0 && serverVersion < backupVersion {
+		warnings = append(warnings, fmt.Sprintf("backup created on ClickHouse %s (%d), restore to older version %d, metadata and data parts could have incompatible format", backupMetadata.ClickHouseVersion, backupVersion, serverVersion))
+	}
+	if serverEngines != nil {
+		for _, engine := range backupMetadata.TableEngines {
+			if _, exists := serverEngines[engine]; !exists {
+				warnings = append(warnings, fmt.Sprintf("table engine %s is not supported by server, tables with this engine can't be restored", engine))
+			}
+		}
+	}
+	if serverSettings != nil {
+		missingSettings := make([]string, 0)
+		for name := range backupMetadata.ClickHouseSettings {
+			if !serverSettings[name] {
+				missingSettings = append(missingSettings, name)
+			}
+		}
+		if len(missingSettings) > 0 {
+			sort.Strings(missingSettings)
+			warnings = append(warnings, fmt.Sprintf("settings %s changed on source server don't exist on server, queries which rely on it could fail", strings.Join(missingSettings, ", ")))
+		}
+	}
+	return warnings
+}
+
+// checkAttachOnlyTables - all tables for `--attach-only` shall exist with compatible columns, restore database and table mapping is applied
+func (b *Backuper) checkAttachOnlyTables(ctx context.Context, tablesForRestore ListOfTables) error {
+	dstTitles := make([]metadata.TableTitle, len(tablesForRestore))
+	tableNames := make([]string, len(tablesForRestore))
+	for i, table := range tablesForRestore {
+		dstTitles[i] = b.getDstTableTitle(table)
+		tableNames[i] = fmt.Sprintf("%s.%s", dstTitles[i].Database, dstTitles[i].Table)
+	}
+	chTables, err := b.ch.GetTables(ctx, strings.Join(tableNames, ","))
+	if err != nil {
+		return err
+	}
+	dstTablesMap := b.prepareDstTablesMap(chTables)
+	var missingTables, incompatibleStructure []string
+	for i, table := range tablesForRestore {
+		dstTable, exists := dstTablesMap[dstTitles[i]]
+		if !exists {
+			missingTables = append(missingTables, fmt.Sprintf("'%s.%s'", dstTitles[i].Database, dstTitles[i].Table))
+			continue
+		}
+		for _, d := range getAttachIncompatibleColumns(dstTitles[i].Database, dstTitles[i].Table, table.Query, dstTable.CreateTableQuery) {
+			incompatibleStructure = append(incompatibleStructure, fmt.Sprintf("`%s`.`%s` column `%s` %s in backup (backup: %s, server: %s)", d.Database, d.Table, d.Column, d.Change, d.Backup, d.Server))
+		}
+		incompatibleStructure = append(incompatibleStructure, getAttachIncompatibleKeys(dstTitles[i].Database, dstTitles[i].Table, table.Query, dstTable.CreateTableQuery)...)
+	}
+	if len(missingTables) > 0 {
+		return fmt.Errorf("--attach-only: %s doesn't exist, restore schema first or create missing tables manually", strings.Join(missingTables, ", "))
+	}
+	if len(incompatibleStructure) > 0 {
+		return fmt.Errorf("--attach-only: incompatible table structure, %s", strings.Join(incompatibleStructure, "; "))
+	}
+	return nil
+}
+
+// getDstTableTitle - tablesForRestore contains source database and table names, only queries are changed by restore mapping
+func (b *Backuper) getDstTableTitle(table metadata.TableMetadata) metadata.TableTitle {
+	dst := metadata.TableTitle{Database: table.Database, Table: table.Table}
+	if targetDB, isMapped := b.cfg.General.RestoreDatabaseMapping[table.Database]; isMapped {
+		dst.Database = targetDB
+	}
+	if targetTable, isMapped := getRestoreTableMapping(b.cfg.General.RestoreTableMapping, table.Database, table.Table); isMapped {
+		dst.Table = targetTable
+	}
+	return dst
+}
+
+// getAttachIncompatibleColumns - columns added on server are allowed, attached parts will use DEFAULT expression for it, removed columns and changed types are not allowed
+func getAttachIncompatibleColumns(database, table, backupQuery, serverQuery string) []schemaDiff {
+	incompatible := make([]schemaDiff, 0)
+	for _, d := range diffColumns(database, table, extractColumnsFromCreateQuery(backupQuery), extractColumnsFromCreateQuery(serverQuery)) {
+		if d.Change == schemaDiffAdded {
+			continue
+		}
+		if d.Change == schemaDiffChanged && extractColumnType(d.Backup) == extractColumnType(d.Server) {
+			continue
+		}
+		incompatible = append(incompatible, d)
+	}
+	return incompatible
+}
+
+var attachTableEngineRE = regexp.MustCompile(`(?i)\sENGINE\s*=\s*(\w+)`)
+var attachTableClauseRE = regexp.MustCompile(`(?i)\s(PARTITION BY|PRIMARY KEY|ORDER BY|SAMPLE BY|TTL|SETTINGS|COMMENT)\s`)
+
+// attachTableKeys - table structure which shall be the same in backup and on server, otherwise ATTACH PART fails partway through restore
+var attachTableKeys = []string{"ENGINE", "PARTITION BY", "ORDER BY"}
+
+// getAttachIncompatibleKeys - engine family, partition key and sorting key shall be equal, replicated and not replicated engines of the same family are compatible
+func getAttachIncompatibleKeys(database, table, backupQuery, serverQuery string) []string {
+	backupKeys := extractAttachTableKeys(backupQuery)
+	serverKeys := extractAttachTableKeys(serverQuery)
+	incompatible := make([]string, 0)
+	for _, key := range attachTableKeys {
+		if backupKeys[key] != serverKeys[key] {
+			incompatible = append(incompatible, fmt.Sprintf("`%s`.`%s` %s changed (backup: %s, server: %s)", database, table, key, backupKeys[key], serverKeys[key]))
+		}
+	}
+	return incompatible
+}
+
+// extractAttachTableKeys - engine name without Replicated prefix and expressions of clauses after ENGINE, column list is skipped cause it can contain TTL, COMMENT and projections with ORDER BY
+func extractAttachTableKeys(query string) map[string]string {
+	keys := make(map[string]string, len(attachTableKeys))
+	engineMatch := attachTableEngineRE.FindStringSubmatchIndex(query)
+	if engineMatch == nil {
+		return keys
+	}
+	keys["ENGINE"] = strings.TrimPrefix(query[engineMatch[2]:engineMatch[3]], "Replicated")
+	tail := query[engineMatch[1]:]
+	clauses := attachTableClauseRE.FindAllStringSubmatchIndex(tail, -1)
+	for i, clause := range clauses {
+		end := len(tail)
+		if i+1 < len(clauses) {
+			end = clauses[i+1][0]
+		}
+		keys[strings.ToUpper(tail[clause[2]:clause[3]])] = strings.Join(strings.Fields(tail[clause[1]:end]), " ")
+	}
+	return keys
+}
+
+var columnTypeEndKeywords = []string{"DEFAULT ", "MATERIALIZED ", "ALIAS ", "EPHEMERAL", "CODEC(", "COMMENT ", "TTL ", "STATISTICS(", "SETTINGS "}
+
+// extractColumnType - data type from column definition without DEFAULT, CODEC, COMMENT, TTL and other modifiers, keywords inside brackets and quotes are skipped
+func extractColumnType(definition string) string {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(definition); i++ {
+		c := definition[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ':
+			if depth != 0 {
+				continue
+			}
+			rest := strings.ToUpper(definition[i+1:])
+			for _, keyword := range columnTypeEndKeywords {
+				if strings.HasPrefix(rest, keyword) {
+					return strings.TrimSpace(definition[:i])
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(definition)
+}
+
 func (b *Backuper) prepareDstTablesMap(chTables []clickhouse.Table) map[metadata.TableTitle]clickhouse.Table {
 	dstTablesMap := map[metadata.TableTitle]clickhouse.Table{}
 	for i, chTable := range chTables {
@@ -1709,7 +2224,21 @@ func (b *Backuper) changeTablePatternFromRestoreMapping(tablePattern, objType st
 	case "database":
 		mapping = b.cfg.General.RestoreDatabaseMapping
 	case "table":
-		mapping = b.cfg.General.RestoreDatabaseMapping
+		// mapped tables are created alongside source tables, so target tables are added to the pattern
+		for sourceObj, targetObj := range b.cfg.General.RestoreTableMapping {
+			targetDb := "*"
+			if sourceDb, _, isQualified := strings.Cut(sourceObj, "."); isQualified {
+				targetDb = sourceDb
+				if mappedDb, isMapped := b.cfg.General.RestoreDatabaseMapping[sourceDb]; isMapped {
+					targetDb = mappedDb
+				}
+			}
+			if tablePattern != "" {
+				tablePattern += ","
+			}
+			tablePattern += targetDb + "." + targetObj
+		}
+		return tablePattern
 	default:
 		return ""
 	}
@@ -0,0 +1,257 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage/object_disk"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// RestoreRemoteToObjectDisk - restore schema, then server-side copy data parts from remote backup directly into `objectDiskName` bucket and attach them, data doesn't pass through clickhouse-backup host
+// works only for `remote_storage: s3` backups uploaded with `compression_format: none`, tables storage policy shall contain `objectDiskName`
+// databaseMapping, tableMapping and partitions have the same meaning as for `restore`
+func (b *Backuper) RestoreRemoteToObjectDisk(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, objectDiskName string, dropExists, ignoreDependencies bool, version string, commandId int) error {
+	if b.cfg.General.RemoteStorage != "s3" {
+		return fmt.Errorf("restore directly to object disk supported only for `remote_storage: s3`, current %s", b.cfg.General.RemoteStorage)
+	}
+	if err := b.Download(backupName, tablePattern, partitions, true, false, version, commandId); err != nil && !errors.Is(err, ErrBackupIsAlreadyExists) {
+		return err
+	}
+	// Restore also fills b.cfg.General.RestoreDatabaseMapping and RestoreTableMapping, used by getDstTableTitle below
+	if err := b.Restore(backupName, tablePattern, databaseMapping, tableMapping, partitions, true, false, dropExists, ignoreDependencies, false, false, false, false, false, false, version, commandId); err != nil {
+		return err
+	}
+
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	startRestore := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return err
+	}
+	var objectDisk *clickhouse.Disk
+	for i := range disks {
+		if disks[i].Name == objectDiskName {
+			objectDisk = &disks[i]
+			break
+		}
+	}
+	if objectDisk == nil || !b.isDiskTypeObject(objectDisk.Type) {
+		return fmt.Errorf("%s is not object disk, check system.disks", objectDiskName)
+	}
+	if b.DefaultDataPath, err = b.ch.GetDefaultPath(disks); err != nil {
+		return err
+	}
+	backupMetadata, err := b.ReadBackupMetadataLocal(ctx, backupName)
+	if err != nil {
+		return err
+	}
+	if backupMetadata.DataFormat != DirectoryFormat {
+		return fmt.Errorf("%s data format is `%s`, restore directly to object disk requires backup uploaded with `compression_format: none`", backupName, backupMetadata.DataFormat)
+	}
+	if backupMetadata.RequiredBackup != "" {
+		return fmt.Errorf("%s is incremental backup, restore directly to object disk supports only full backups", backupName)
+	}
+	if err = config.ValidateObjectDiskConfig(b.cfg); err != nil {
+		return err
+	}
+	if err = object_disk.InitCredentialsAndConnections(ctx, b.ch, b.cfg, objectDiskName); err != nil {
+		return err
+	}
+	bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, backupName)
+	if err != nil {
+		return err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return fmt.Errorf("can't connect to %s: %v", bd.Kind(), err)
+	}
+	defer func() {
+		if closeErr := bd.Close(ctx); closeErr != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+		}
+	}()
+	b.dst = bd
+	// s3->path with applied macros, the same prefix as used for upload
+	s3Storage, isS3 := bd.RemoteStorage.(*storage.S3)
+	if !isS3 {
+		return fmt.Errorf("restore directly to object disk supported only for `remote_storage: s3`, current %s", bd.Kind())
+	}
+
+	metadataPath := path.Join(b.DefaultDataPath, "backup", backupName, "metadata")
+	// source database and table names, parts are filtered by --partitions
+	tablesForRestore, _, err := b.getTableListByPatternLocal(ctx, metadataPath, tablePattern, false, partitions)
+	if err != nil {
+		return err
+	}
+	totalSize := int64(0)
+	for _, table := range tablesForRestore {
+		if len(table.Parts) == 0 {
+			continue
+		}
+		tableSize, err := b.restoreTableToObjectDisk(ctx, backupName, s3Storage.Config, table, *objectDisk, disks)
+		if err != nil {
+			dst := b.getDstTableTitle(table)
+			return fmt.Errorf("can't restore `%s`.`%s` to %s: %v", dst.Database, dst.Table, objectDiskName, err)
+		}
+		totalSize += tableSize
+	}
+	log.Info().Fields(map[string]interface{}{
+		"backup":    backupName,
+		"operation": "restore_object_disk",
+		"disk":      objectDiskName,
+		"duration":  utils.HumanizeDuration(time.Since(startRestore)),
+		"size":      utils.FormatBytes(uint64(totalSize)),
+	}).Msg("done")
+	return nil
+}
+
+// restoreTableToObjectDisk - copy each file of each part into object disk and write ClickHouse object disk metadata into table `detached` folder on objectDisk, then ATTACH PART
+// table contains source names from backup, parts are attached into table with mapped names
+func (b *Backuper) restoreTableToObjectDisk(ctx context.Context, backupName string, s3Config *config.S3Config, table metadata.TableMetadata, objectDisk clickhouse.Disk, disks []clickhouse.Disk) (int64, error) {
+	dst := b.getDstTableTitle(table)
+	dstTables, err := b.ch.GetTables(ctx, fmt.Sprintf("%s.%s", dst.Database, dst.Table))
+	if err != nil {
+		return 0, err
+	}
+	if len(dstTables) == 0 {
+		return 0, fmt.Errorf("table not found after restore schema")
+	}
+	dstDataPath := ""
+	for _, dataPath := range dstTables[0].DataPaths {
+		if strings.HasPrefix(dataPath, objectDisk.Path) {
+			dstDataPath = dataPath
+			break
+		}
+	}
+	if dstDataPath == "" {
+		return 0, fmt.Errorf("storage policy doesn't contain %s disk, data_paths: %v", objectDisk.Name, dstTables[0].DataPaths)
+	}
+	size := int64(0)
+	copyGroup, copyCtx := errgroup.WithContext(ctx)
+	copyGroup.SetLimit(int(b.cfg.General.ObjectDiskServerSideCopyConcurrency))
+	attachParts := metadata.TableMetadata{
+		Database: dst.Database,
+		Table:    dst.Table,
+		Parts:    map[string][]metadata.Part{objectDisk.Name: {}},
+	}
+	for _, copyPart := range getObjectDiskCopyParts(backupName, s3Config.Path, table, dstDataPath) {
+		walkErr := b.dst.Walk(ctx, copyPart.remotePartPath+"/", true, func(ctx context.Context, f storage.RemoteFile) error {
+			fileName := strings.TrimPrefix(f.Name(), "/")
+			if fileName == "" || strings.HasSuffix(fileName, "/") {
+				return nil
+			}
+			srcKey := path.Join(copyPart.sourceKeyPrefix, fileName)
+			dstFile := path.Join(copyPart.detachedPartPath, fileName)
+			fileSize := f.Size()
+			copyGroup.Go(func() error {
+				dstKey := newObjectDiskKey()
+				copiedSize, copyErr := object_disk.CopyObject(copyCtx, objectDisk.Name, fileSize, s3Config.Bucket, srcKey, dstKey)
+				if copyErr != nil {
+					return fmt.Errorf("object_disk.CopyObject %s -> %s error: %v", srcKey, dstKey, copyErr)
+				}
+				if mkdirErr := filesystemhelper.MkdirAll(path.Dir(dstFile), b.ch, disks); mkdirErr != nil {
+					return mkdirErr
+				}
+				objMeta := &object_disk.Metadata{
+					Version:            object_disk.VersionRelativePath,
+					StorageObjectCount: 1,
+					TotalSize:          fileSize,
+					StorageObjects:     []object_disk.StorageObject{{ObjectSize: fileSize, ObjectRelativePath: dstKey}},
+				}
+				if writeErr := object_disk.WriteMetadataToFile(objMeta, dstFile); writeErr != nil {
+					return fmt.Errorf("object_disk.WriteMetadataToFile %s return error: %v", dstFile, writeErr)
+				}
+				atomic.AddInt64(&size, copiedSize)
+				return filesystemhelper.Chown(dstFile, b.ch, disks, false)
+			})
+			return nil
+		})
+		if walkErr != nil {
+			return 0, walkErr
+		}
+		attachParts.Parts[objectDisk.Name] = append(attachParts.Parts[objectDisk.Name], copyPart.part)
+	}
+	if err = copyGroup.Wait(); err != nil {
+		// don't leave partially copied parts in detached folder
+		for _, part := range attachParts.Parts[objectDisk.Name] {
+			if removeErr := os.RemoveAll(path.Join(dstDataPath, "detached", part.Name)); removeErr != nil {
+				log.Warn().Msgf("can't remove %s: %v", part.Name, removeErr)
+			}
+		}
+		return 0, err
+	}
+	if err = b.ch.AttachDataParts(attachParts, dstTables[0]); err != nil {
+		return 0, err
+	}
+	log.Info().Str("table", fmt.Sprintf("%s.%s", dst.Database, dst.Table)).Str("disk", objectDisk.Name).Str("size", utils.FormatBytes(uint64(size))).Msg("restored to object disk")
+	return size, nil
+}
+
+// objectDiskCopyPart - part of table in remote backup and its folder inside `detached` of destination table
+type objectDiskCopyPart struct {
+	part             metadata.Part
+	remotePartPath   string // relative to s3->path, used for Walk
+	sourceKeyPrefix  string // full key prefix in s3->bucket, used for CopyObject
+	detachedPartPath string
+}
+
+// getObjectDiskCopyParts - parts of table from all disks in backup, table shall contain source database and table names, cause remote backup layout uses them
+func getObjectDiskCopyParts(backupName, s3Path string, table metadata.TableMetadata, dstDataPath string) []objectDiskCopyPart {
+	dbAndTableDir := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+	copyParts := make([]objectDiskCopyPart, 0)
+	for diskName, parts := range table.Parts {
+		for _, part := range parts {
+			remotePartPath := path.Join(backupName, "shadow", dbAndTableDir, diskName, part.Name)
+			copyParts = append(copyParts, objectDiskCopyPart{
+				part:             part,
+				remotePartPath:   remotePartPath,
+				sourceKeyPrefix:  path.Join(s3Path, remotePartPath),
+				detachedPartPath: path.Join(dstDataPath, "detached", part.Name),
+			})
+		}
+	}
+	return copyParts
+}
+
+const objectDiskKeyLetters = "abcdefghijklmnopqrstuvwxyz"
+
+// newObjectDiskKey - random key with the same layout as ClickHouse generates for s3 disk objects, `xxx/yyyyyyyyyyyyyyyyyyyyyyyyyyyyy`
+func newObjectDiskKey() string {
+	key := make([]byte, 32)
+	for i := range key {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(objectDiskKeyLetters))))
+		if err != nil {
+			n = big.NewInt(int64(time.Now().UnixNano() % int64(len(objectDiskKeyLetters))))
+		}
+		key[i] = objectDiskKeyLetters[n.Int64()]
+	}
+	return string(key[:3]) + "/" + string(key[3:])
+}
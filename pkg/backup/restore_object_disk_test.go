@@ -0,0 +1,49 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetObjectDiskCopyParts(t *testing.T) {
+	metadataPath := path.Join(t.TempDir(), "backup1", "metadata")
+	assert.NoError(t, os.MkdirAll(path.Join(metadataPath, "db%2D1"), 0750))
+	tableJSON := `{"database":"db-1","table":"t1","query":"CREATE TABLE db-1.t1 (id UInt64) ENGINE=MergeTree PARTITION BY id ORDER BY id","parts":{"default":[{"name":"202401_1_1_0"},{"name":"202402_2_2_0"}],"hot":[{"name":"202401_3_3_0"}]}}`
+	assert.NoError(t, os.WriteFile(path.Join(metadataPath, "db%2D1", "t1.json"), []byte(tableJSON), 0640))
+
+	cfg := config.DefaultConfig()
+	cfg.General.RestoreDatabaseMapping = map[string]string{"db-1": "db_restored"}
+	cfg.General.RestoreTableMapping = map[string]string{"t1": "t1_restored"}
+	b := &Backuper{cfg: cfg}
+	tables, _, err := b.getTableListByPatternLocal(context.Background(), metadataPath, "*", false, []string{"202401"})
+	assert.NoError(t, err)
+	assert.Len(t, tables, 1)
+	assert.Equal(t, metadata.TableTitle{Database: "db_restored", Table: "t1_restored"}, b.getDstTableTitle(tables[0]))
+
+	copyParts := getObjectDiskCopyParts("backup1", "prefix/shard1", tables[0], "/var/lib/clickhouse/disks/s3/store/abc/abcdef/")
+	partsByName := map[string]objectDiskCopyPart{}
+	for _, copyPart := range copyParts {
+		partsByName[copyPart.part.Name] = copyPart
+	}
+	// 202402 filtered by --partitions, remote layout uses source names, detached uses destination data path
+	assert.Len(t, partsByName, 2)
+	assert.NotContains(t, partsByName, "202402_2_2_0")
+	assert.Equal(t, "backup1/shadow/db%2D1/t1/default/202401_1_1_0", partsByName["202401_1_1_0"].remotePartPath)
+	assert.Equal(t, "prefix/shard1/backup1/shadow/db%2D1/t1/default/202401_1_1_0", partsByName["202401_1_1_0"].sourceKeyPrefix)
+	assert.Equal(t, "prefix/shard1/backup1/shadow/db%2D1/t1/hot/202401_3_3_0", partsByName["202401_3_3_0"].sourceKeyPrefix)
+	assert.Equal(t, "/var/lib/clickhouse/disks/s3/store/abc/abcdef/detached/202401_3_3_0", partsByName["202401_3_3_0"].detachedPartPath)
+}
+
+func TestNewObjectDiskKey(t *testing.T) {
+	keyRE := regexp.MustCompile(`^[a-z]{3}/[a-z]{29}$`)
+	first := newObjectDiskKey()
+	assert.Regexp(t, keyRE, first)
+	assert.NotEqual(t, first, newObjectDiskKey())
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+// restoreDryRunPlan - `restore --dry-run`, print DDL statements and data parts which restore would apply, nothing is executed
+func (b *Backuper) restoreDryRunPlan(ctx context.Context, w io.Writer, backupName string, backupMetadata metadata.BackupMetadata, tablePattern string, partitions []string, restoreSchema, restoreData, dropExists bool) error {
+	if tablePattern == "" {
+		tablePattern = "*"
+	}
+	metadataPath := path.Join(b.DefaultDataPath, "backup", backupName, "metadata")
+	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		metadataPath = path.Join(b.EmbeddedBackupDataPath, backupName, "metadata")
+	}
+	tablesForRestore, _, err := b.getTablesForRestoreLocal(ctx, backupName, metadataPath, tablePattern, dropExists, partitions)
+	if err != nil {
+		return err
+	}
+	return b.writeRestorePlan(w, backupMetadata, tablePattern, tablesForRestore, restoreSchema, restoreData, dropExists)
+}
+
+// writeRestorePlan - replication path and replica existence checks require clickhouse-server state and are not applied to printed queries
+func (b *Backuper) writeRestorePlan(w io.Writer, backupMetadata metadata.BackupMetadata, tablePattern string, tablesForRestore ListOfTables, restoreSchema, restoreData, dropExists bool) error {
+	plan := &strings.Builder{}
+	_, _ = fmt.Fprintf(plan, "-- restore plan for backup %s, nothing will be executed\n", backupMetadata.BackupName)
+	if restoreSchema {
+		for _, database := range backupMetadata.Databases {
+			targetDB, databaseQuery := b.prepareDatabaseQueryForRestore(database)
+			if IsInformationSchema(targetDB) || ShallSkipDatabase(b.cfg, targetDB, tablePattern) {
+				continue
+			}
+			_, _ = fmt.Fprintf(plan, "%s;\n", trimQuery(databaseQuery))
+		}
+		for _, function := range backupMetadata.Functions {
+			_, _ = fmt.Fprintf(plan, "%s;\n", trimQuery(function.CreateQuery))
+		}
+	}
+	for _, table := range tablesForRestore {
+		_, _ = fmt.Fprintf(plan, "\n-- `%s`.`%s`\n", table.Database, table.Table)
+		if restoreSchema {
+			if dropExists {
+				_, _ = fmt.Fprintf(plan, "DROP TABLE IF EXISTS `%s`.`%s` SYNC;\n", table.Database, table.Table)
+			}
+			schema := table
+			b.prepareSchemaQueryForRestore(&schema)
+			b.replaceUUIDMacroValue(&schema)
+			_, _ = fmt.Fprintf(plan, "%s;\n", trimQuery(schema.Query))
+		}
+		if restoreData && !table.MetadataOnly {
+			b.writeRestoreDataPlan(plan, table)
+		}
+	}
+	_, err := io.WriteString(w, plan.String())
+	return err
+}
+
+func (b *Backuper) writeRestoreDataPlan(plan *strings.Builder, table metadata.TableMetadata) {
+	disks := make([]string, 0, len(table.Parts))
+	partsCount := 0
+	for disk, parts := range table.Parts {
+		disks = append(disks, disk)
+		partsCount += len(parts)
+	}
+	if partsCount == 0 {
+		_, _ = fmt.Fprintln(plan, "-- no data parts")
+		return
+	}
+	sort.Strings(disks)
+	if b.cfg.ClickHouse.RestoreAsAttach {
+		_, _ = fmt.Fprintf(plan, "-- restore_as_attach: true, %d parts will copy into table data path\n", partsCount)
+		_, _ = fmt.Fprintf(plan, "DETACH TABLE `%s`.`%s` SYNC;\n", table.Database, table.Table)
+	}
+	for _, disk := range disks {
+		parts := append([]metadata.Part{}, table.Parts[disk]...)
+		metadata.SortPartsByMinBlock(parts)
+		for _, part := range parts {
+			if strings.HasSuffix(part.Name, ".proj") {
+				continue
+			}
+			if b.cfg.ClickHouse.RestoreAsAttach {
+				_, _ = fmt.Fprintf(plan, "-- part %s, disk %s\n", part.Name, disk)
+			} else {
+				_, _ = fmt.Fprintf(plan, "ALTER TABLE `%s`.`%s` ATTACH PART '%s'; -- disk %s\n", table.Database, table.Table, part.Name, disk)
+			}
+		}
+	}
+	if b.cfg.ClickHouse.RestoreAsAttach {
+		_, _ = fmt.Fprintf(plan, "ATTACH TABLE `%s`.`%s`;\n", table.Database, table.Table)
+	}
+	for _, mutation := range table.Mutations {
+		_, _ = fmt.Fprintf(plan, "ALTER TABLE `%s`.`%s` %s;\n", table.Database, table.Table, mutation.Command)
+	}
+	if b.rebuildProjections {
+		for _, projection := range extractProjectionNames(table.Query) {
+			_, _ = fmt.Fprintf(plan, "ALTER TABLE `%s`.`%s` MATERIALIZE PROJECTION `%s`;\n", table.Database, table.Table, projection)
+		}
+	}
+}
+
+func trimQuery(query string) string {
+	return strings.TrimSuffix(strings.TrimSpace(query), ";")
+}
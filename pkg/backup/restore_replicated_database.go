@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// getReplicatedDatabases - databases with ENGINE=Replicated, DDL for tables inside such databases replicated by database itself via DDL log in zookeeper
+func (b *Backuper) getReplicatedDatabases(ctx context.Context) (common.EmptyMap, error) {
+	replicatedDatabases := make([]struct {
+		Name string `ch:"name"`
+	}, 0)
+	if err := b.ch.SelectContext(ctx, &replicatedDatabases, "SELECT name FROM system.databases WHERE engine='Replicated'"); err != nil {
+		return nil, err
+	}
+	result := make(common.EmptyMap, len(replicatedDatabases))
+	for _, db := range replicatedDatabases {
+		result[db.Name] = struct{}{}
+	}
+	return result, nil
+}
+
+// onClusterForDatabase - ON CLUSTER is not allowed and not required for tables inside ENGINE=Replicated databases, DDL will replay on other replicas automatically
+func (b *Backuper) onClusterForDatabase(database string) string {
+	if _, isReplicated := b.replicatedDatabases[database]; isReplicated {
+		return ""
+	}
+	return b.cfg.General.RestoreSchemaOnCluster
+}
+
+// isTableCreatedByOtherReplica - when restore executes on each replica, table inside ENGINE=Replicated database could be already created via DDL log of database, we shall not create it twice
+func (b *Backuper) isTableCreatedByOtherReplica(ctx context.Context, database, table string) bool {
+	if _, isReplicated := b.replicatedDatabases[database]; !isReplicated {
+		return false
+	}
+	isExists := uint64(0)
+	if err := b.ch.SelectSingleRow(ctx, &isExists, "SELECT count() FROM system.tables WHERE database=? AND name=?", database, table); err != nil {
+		log.Warn().Msgf("can't check `%s`.`%s` exists in system.tables error: %v", database, table, err)
+		return false
+	}
+	return isExists > 0
+}
+
+var replicatedDatabaseEngineRE = regexp.MustCompile(`ENGINE\s*=\s*Replicated\(\s*'([^']+)'\s*,\s*'([^']*)'\s*,\s*'([^']*)'\s*\)`)
+
+// parseReplicatedDatabaseZooKeeperPath - extract zookeeper path from `SHOW CREATE DATABASE` for ENGINE=Replicated
+func parseReplicatedDatabaseZooKeeperPath(createQuery, database string) (string, error) {
+	matches := replicatedDatabaseEngineRE.FindStringSubmatch(createQuery)
+	if len(matches) < 2 {
+		return "", fmt.Errorf("can't find ENGINE=Replicated parameters in `%s`", createQuery)
+	}
+	return strings.Replace(matches[1], "{database}", database, -1), nil
+}
+
+// waitReplicatedDatabaseDDL - wait until all replicas of restored ENGINE=Replicated databases apply DDL log, before start data restore, timeout is `clickhouse->timeout`
+func (b *Backuper) waitReplicatedDatabaseDDL(ctx context.Context, tablesForRestore ListOfTables, version int) error {
+	databases := common.EmptyMap{}
+	for _, table := range tablesForRestore {
+		if _, isReplicated := b.replicatedDatabases[table.Database]; isReplicated {
+			databases[table.Database] = struct{}{}
+		}
+	}
+	if len(databases) == 0 {
+		return nil
+	}
+	timeout, err := time.ParseDuration(b.cfg.ClickHouse.Timeout)
+	if err != nil {
+		return err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for database := range databases {
+		startWait := time.Now()
+		// https://github.com/ClickHouse/ClickHouse/pull/35944
+		if version >= 22005000 {
+			if err = b.ch.QueryContext(waitCtx, fmt.Sprintf("SYSTEM SYNC DATABASE REPLICA `%s`", database)); err != nil {
+				return fmt.Errorf("SYSTEM SYNC DATABASE REPLICA `%s` error: %v", database, err)
+			}
+		}
+		if err = b.waitReplicatedDatabaseLogPtr(waitCtx, database); err != nil {
+			return err
+		}
+		log.Info().Fields(map[string]interface{}{
+			"database":  database,
+			"operation": "replicated_ddl_wait",
+			"duration":  utils.HumanizeDuration(time.Since(startWait)),
+		}).Msg("done")
+	}
+	return nil
+}
+
+// waitReplicatedDatabaseLogPtr - each replica store applied DDL log position in `<zk_path>/replicas/<shard|replica>/log_ptr`, wait when all replicas reach `<zk_path>/max_log_ptr`
+func (b *Backuper) waitReplicatedDatabaseLogPtr(ctx context.Context, database string) error {
+	createQuery := ""
+	if err := b.ch.SelectSingleRow(ctx, &createQuery, fmt.Sprintf("SHOW CREATE DATABASE `%s`", database)); err != nil {
+		return fmt.Errorf("can't SHOW CREATE DATABASE `%s`: %v", database, err)
+	}
+	zkPath, err := parseReplicatedDatabaseZooKeeperPath(createQuery, database)
+	if err != nil {
+		return err
+	}
+	if zkPath, err = b.ch.ApplyMacros(ctx, zkPath); err != nil {
+		return err
+	}
+	maxLogPtr, err := b.getReplicatedDatabaseZooKeeperCounter(ctx, zkPath, "max_log_ptr")
+	if err != nil {
+		return err
+	}
+	replicas := make([]struct {
+		Name string `ch:"name"`
+	}, 0)
+	if err = b.ch.SelectContext(ctx, &replicas, "SELECT name FROM system.zookeeper WHERE path=?", path.Join(zkPath, "replicas")); err != nil {
+		return fmt.Errorf("can't get replicas for `%s` from system.zookeeper: %v", database, err)
+	}
+	for _, replica := range replicas {
+		for {
+			logPtr, err := b.getReplicatedDatabaseZooKeeperCounter(ctx, path.Join(zkPath, "replicas", replica.Name), "log_ptr")
+			if err != nil {
+				return err
+			}
+			if logPtr >= maxLogPtr {
+				break
+			}
+			log.Info().Msgf("wait replica %s of `%s` apply DDL log, log_ptr=%d max_log_ptr=%d", replica.Name, database, logPtr, maxLogPtr)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("replica %s of `%s` doesn't apply DDL log: %v", replica.Name, database, ctx.Err())
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Backuper) getReplicatedDatabaseZooKeeperCounter(ctx context.Context, zkPath, name string) (uint64, error) {
+	value := ""
+	if err := b.ch.SelectSingleRow(ctx, &value, "SELECT value FROM system.zookeeper WHERE path=? AND name=?", zkPath, name); err != nil {
+		return 0, fmt.Errorf("can't get %s from system.zookeeper: %v", path.Join(zkPath, name), err)
+	}
+	return strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+}
@@ -2,7 +2,11 @@ package backup
 
 import (
 	"fmt"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -83,3 +87,246 @@ func TestDetectRBACObject(t *testing.T) {
 		}
 	}
 }
+
+func TestParseReplicatedDatabaseZooKeeperPath(t *testing.T) {
+	zkPath, err := parseReplicatedDatabaseZooKeeperPath("CREATE DATABASE db1\nENGINE = Replicated('/clickhouse/databases/{database}', '{shard}', '{replica}')", "db1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/clickhouse/databases/db1", zkPath)
+
+	zkPath, err = parseReplicatedDatabaseZooKeeperPath("CREATE DATABASE db2 ENGINE=Replicated('/clickhouse/{cluster}/db2','s1','r1')", "db2")
+	assert.NoError(t, err)
+	assert.Equal(t, "/clickhouse/{cluster}/db2", zkPath)
+
+	_, err = parseReplicatedDatabaseZooKeeperPath("CREATE DATABASE db3 ENGINE = Atomic", "db3")
+	assert.Error(t, err)
+}
+
+func TestSplitTablesByExistence(t *testing.T) {
+	tablesForRestore := ListOfTables{
+		{Database: "db1", Table: "events"},
+		{Database: "db1", Table: "new_events"},
+		{Database: "db2", Table: "events"},
+	}
+	existsTables := map[metadata.TableTitle]struct{}{
+		{Database: "db1", Table: "events"}: {},
+		{Database: "db2", Table: "events"}: {},
+		{Database: "db3", Table: "other"}:  {},
+	}
+	b := &Backuper{cfg: &config.Config{}}
+	exists, notExists := b.splitTablesByExistence(tablesForRestore, existsTables)
+	assert.Equal(t, ListOfTables{{Database: "db1", Table: "events"}, {Database: "db2", Table: "events"}}, exists)
+	assert.Equal(t, ListOfTables{{Database: "db1", Table: "new_events"}}, notExists)
+
+	// existence is checked for destination names after restore mapping
+	b.cfg.General.RestoreDatabaseMapping = map[string]string{"db1": "db3"}
+	b.cfg.General.RestoreTableMapping = map[string]string{"db1.new_events": "other"}
+	exists, notExists = b.splitTablesByExistence(tablesForRestore, existsTables)
+	assert.Equal(t, ListOfTables{{Database: "db1", Table: "new_events"}, {Database: "db2", Table: "events"}}, exists)
+	assert.Equal(t, ListOfTables{{Database: "db1", Table: "events"}}, notExists)
+}
+
+func TestPrepareRestoreDatabaseMapping(t *testing.T) {
+	newBackuper := func() *Backuper {
+		return &Backuper{cfg: &config.Config{General: config.GeneralConfig{RestoreDatabaseMapping: map[string]string{}, RestoreTableMapping: map[string]string{}}}}
+	}
+	b := newBackuper()
+	assert.NoError(t, b.prepareRestoreMapping([]string{" prod : prod_verify ,", "stage:stage_verify"}, "database"))
+	assert.Equal(t, map[string]string{"prod": "prod_verify", "stage": "stage_verify"}, b.cfg.General.RestoreDatabaseMapping)
+
+	assert.Error(t, newBackuper().prepareRestoreMapping([]string{"prod:"}, "database"))
+	assert.Error(t, newBackuper().prepareRestoreMapping([]string{"prod"}, "database"))
+	assert.ErrorContains(t, newBackuper().prepareRestoreMapping([]string{"prod:verify,stage:verify"}, "database"), "prod:verify and stage:verify")
+	assert.NoError(t, newBackuper().prepareRestoreMapping([]string{"t1:t,t2:t"}, "table"))
+}
+
+func TestPrepareRestoreTableMapping(t *testing.T) {
+	b := &Backuper{cfg: &config.Config{General: config.GeneralConfig{RestoreTableMapping: map[string]string{}}}}
+	assert.NoError(t, b.prepareRestoreMapping([]string{"db1.events:db1.events_restored,users:users_restored"}, "table"))
+	assert.Equal(t, map[string]string{"db1.events": "events_restored", "users": "users_restored"}, b.cfg.General.RestoreTableMapping)
+	assert.Error(t, b.prepareRestoreMapping([]string{"db1.events:db2.events"}, "table"))
+	assert.Error(t, b.prepareRestoreMapping([]string{"events:db1.events_restored"}, "table"))
+}
+
+func TestApplyReplicationPathMapping(t *testing.T) {
+	pathMapping := map[string]string{
+		"/clickhouse/tables/":           "/clickhouse/restored/",
+		"/clickhouse/tables/prod/01/":   "/clickhouse/tables/{cluster}/{shard}/",
+		"/clickhouse/not_matched_path/": "/clickhouse/other/",
+	}
+	query := "CREATE TABLE db1.t1 (id UInt64) ENGINE = ReplicatedMergeTree('/clickhouse/tables/prod/01/db1/t1', 'node1') ORDER BY id"
+	changedQuery, isChanged := applyReplicationPathMapping(query, pathMapping, "{replica}")
+	assert.True(t, isChanged)
+	assert.Equal(t, "CREATE TABLE db1.t1 (id UInt64) ENGINE = ReplicatedMergeTree('/clickhouse/tables/{cluster}/{shard}/db1/t1', '{replica}') ORDER BY id", changedQuery)
+
+	query = "CREATE TABLE db1.t2 (id UInt64) ENGINE = ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/db1/t2', '{replica}') ORDER BY id"
+	changedQuery, isChanged = applyReplicationPathMapping(query, pathMapping, "{replica}")
+	assert.True(t, isChanged)
+	assert.Equal(t, "CREATE TABLE db1.t2 (id UInt64) ENGINE = ReplicatedReplacingMergeTree('/clickhouse/restored/{shard}/db1/t2', '{replica}') ORDER BY id", changedQuery)
+
+	for _, query = range []string{
+		"CREATE TABLE db1.t3 (id UInt64) ENGINE = ReplicatedMergeTree('/other/db1/t3', '{replica}') ORDER BY id",
+		"CREATE TABLE db1.t4 (id UInt64) ENGINE = ReplicatedMergeTree() ORDER BY id",
+		"CREATE TABLE db1.t5 (id UInt64) ENGINE = MergeTree() ORDER BY id",
+	} {
+		changedQuery, isChanged = applyReplicationPathMapping(query, pathMapping, "{replica}")
+		assert.False(t, isChanged)
+		assert.Equal(t, query, changedQuery)
+	}
+}
+
+func TestConvertReplicatedToMergeTree(t *testing.T) {
+	testCases := map[string]string{
+		"CREATE TABLE db1.t1 (id UInt64) ENGINE = ReplicatedMergeTree('/clickhouse/tables/{shard}/db1/t1', '{replica}') ORDER BY id":                           "CREATE TABLE db1.t1 (id UInt64) ENGINE = MergeTree() ORDER BY id",
+		"CREATE TABLE db1.t2 (id UInt64, ver UInt64) ENGINE = ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/db1/t2', '{replica}', ver) ORDER BY id": "CREATE TABLE db1.t2 (id UInt64, ver UInt64) ENGINE = ReplacingMergeTree(ver) ORDER BY id",
+		"CREATE TABLE db1.t3 (id UInt64) ENGINE = ReplicatedMergeTree() ORDER BY id":                                                                           "CREATE TABLE db1.t3 (id UInt64) ENGINE = MergeTree() ORDER BY id",
+		"CREATE TABLE db1.t4 (id UInt64, s Int8) ENGINE = ReplicatedCollapsingMergeTree(s) ORDER BY id":                                                        "CREATE TABLE db1.t4 (id UInt64, s Int8) ENGINE = CollapsingMergeTree(s) ORDER BY id",
+		"CREATE TABLE db1.t5 (id UInt64) ENGINE = MergeTree ORDER BY id":                                                                                       "CREATE TABLE db1.t5 (id UInt64) ENGINE = MergeTree ORDER BY id",
+	}
+	for query, expected := range testCases {
+		assert.Equal(t, expected, convertReplicatedToMergeTree(query))
+	}
+	assert.Equal(t, "CREATE DATABASE db1 ENGINE = Atomic", replicatedDatabaseEngineRE.ReplaceAllString("CREATE DATABASE db1 ENGINE = Replicated('/clickhouse/databases/{database}', '{shard}', '{replica}')", "ENGINE = Atomic"))
+}
+
+func TestCheckStoragePoliciesExist(t *testing.T) {
+	b := &Backuper{ch: &clickhouse.ClickHouse{}, cfg: &config.Config{}}
+	disks := []clickhouse.Disk{
+		{Name: "default", StoragePolicies: []string{"default", "hot_cold"}},
+		{Name: "cold", StoragePolicies: []string{"hot_cold"}},
+	}
+	tables := ListOfTables{
+		{Database: "db", Table: "t1", Query: "CREATE TABLE db.t1 (id UInt64) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Table: "t2", Query: "CREATE TABLE db.t2 (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 'hot_cold', index_granularity = 8192"},
+	}
+	assert.NoError(t, b.checkStoragePoliciesExist(tables, disks))
+	tables = append(tables, metadata.TableMetadata{Database: "db", Table: "t3", Query: "CREATE TABLE db.t3 (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 's3_tier'"})
+	err := b.checkStoragePoliciesExist(tables, disks)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "db.t3 storage_policy='s3_tier'")
+	assert.NotContains(t, err.Error(), "db.t2")
+}
+
+func TestApplyStoragePolicyMapping(t *testing.T) {
+	mapping := map[string]string{"hot_cold": "default"}
+	query, isChanged := applyStoragePolicyMapping("CREATE TABLE db.t (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 'hot_cold', index_granularity = 8192", mapping)
+	assert.True(t, isChanged)
+	assert.Equal(t, "CREATE TABLE db.t (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 'default', index_granularity = 8192", query)
+	_, isChanged = applyStoragePolicyMapping("CREATE TABLE db.t (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 's3'", mapping)
+	assert.False(t, isChanged)
+
+	b := &Backuper{ch: &clickhouse.ClickHouse{}, cfg: &config.Config{ClickHouse: config.ClickHouseConfig{StoragePolicyMapping: mapping}}}
+	disks := []clickhouse.Disk{{Name: "default", StoragePolicies: []string{"default"}}}
+	tables := ListOfTables{{Database: "db", Table: "t", Query: "CREATE TABLE db.t (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS storage_policy = 'hot_cold'"}}
+	assert.NoError(t, b.checkStoragePoliciesExist(tables, disks))
+}
+
+func TestExtractProjectionNames(t *testing.T) {
+	query := "CREATE TABLE db.t (`id` UInt64, `user` String, PROJECTION by_user (SELECT * ORDER BY user), PROJECTION `daily counts` (SELECT user, count() GROUP BY user)) ENGINE = MergeTree ORDER BY id SETTINGS deduplicate_merge_projection_mode = 'rebuild'"
+	assert.Equal(t, []string{"by_user", "daily counts"}, extractProjectionNames(query))
+	assert.Empty(t, extractProjectionNames("CREATE TABLE db.t (`projection` UInt64) ENGINE = MergeTree ORDER BY projection"))
+}
+
+func TestWriteRestorePlan(t *testing.T) {
+	b := &Backuper{cfg: &config.Config{General: config.GeneralConfig{RestoreDatabaseMapping: map[string]string{"db": "db_restored"}, RestoreTableMapping: map[string]string{}}}}
+	backupMetadata := metadata.BackupMetadata{
+		BackupName: "plan_backup",
+		Databases:  []metadata.DatabasesMeta{{Name: "db", Engine: "Atomic", Query: "CREATE DATABASE db ENGINE = Atomic"}},
+	}
+	tables := ListOfTables{
+		{
+			Database: "db_restored", Table: "t", Query: "CREATE TABLE db_restored.t (id UInt64) ENGINE = MergeTree ORDER BY id",
+			Parts: map[string][]metadata.Part{"default": {{Name: "all_2_2_0"}, {Name: "all_1_1_0"}}},
+		},
+		{Database: "db_restored", Table: "mv", Query: "CREATE MATERIALIZED VIEW db_restored.mv TO db_restored.t AS SELECT id FROM db_restored.src", MetadataOnly: true},
+	}
+	plan := &strings.Builder{}
+	assert.NoError(t, b.writeRestorePlan(plan, backupMetadata, "*", tables, true, true, true))
+	assert.Equal(t, "-- restore plan for backup plan_backup, nothing will be executed\n"+
+		"CREATE DATABASE IF NOT EXISTS `db_restored` ENGINE = Atomic;\n"+
+		"\n-- `db_restored`.`t`\n"+
+		"DROP TABLE IF EXISTS `db_restored`.`t` SYNC;\n"+
+		"CREATE TABLE db_restored.t (id UInt64) ENGINE = MergeTree ORDER BY id;\n"+
+		"ALTER TABLE `db_restored`.`t` ATTACH PART 'all_1_1_0'; -- disk default\n"+
+		"ALTER TABLE `db_restored`.`t` ATTACH PART 'all_2_2_0'; -- disk default\n"+
+		"\n-- `db_restored`.`mv`\n"+
+		"DROP TABLE IF EXISTS `db_restored`.`mv` SYNC;\n"+
+		"ATTACH MATERIALIZED VIEW db_restored.mv TO db_restored.t AS SELECT id FROM db_restored.src;\n", plan.String())
+
+	plan.Reset()
+	assert.NoError(t, b.writeRestorePlan(plan, backupMetadata, "*", tables[:1], false, true, false))
+	assert.NotContains(t, plan.String(), "CREATE")
+	assert.Contains(t, plan.String(), "ATTACH PART 'all_1_1_0'")
+}
+
+func TestGetAttachIncompatibleColumns(t *testing.T) {
+	backupQuery := "CREATE TABLE db.t UUID 'a7d9c5d0-1bb2-4d5d-9b3e-6e1c4f6d7a11' (`id` UInt64, `s` String DEFAULT 'a', `e` Enum8('DEFAULT ' = 1), `v` Int32) ENGINE = MergeTree ORDER BY id"
+	serverQuery := "CREATE TABLE db.t (`id` UInt64 CODEC(ZSTD(1)), `s` String DEFAULT 'b' COMMENT 'new', `e` Enum8('DEFAULT ' = 1), `v` Int64, `added` String) ENGINE = MergeTree ORDER BY id"
+	diffs := getAttachIncompatibleColumns("db", "t", backupQuery, serverQuery)
+	assert.Equal(t, []schemaDiff{{Database: "db", Table: "t", Column: "v", Change: schemaDiffChanged, Backup: "Int32", Server: "Int64"}}, diffs)
+
+	diffs = getAttachIncompatibleColumns("db", "t", backupQuery, "CREATE TABLE db.t (`id` UInt64, `s` String, `e` Enum8('DEFAULT ' = 1)) ENGINE = MergeTree ORDER BY id")
+	assert.Equal(t, []schemaDiff{{Database: "db", Table: "t", Column: "v", Change: schemaDiffRemoved, Backup: "Int32"}}, diffs)
+
+	assert.Equal(t, "Nullable(String)", extractColumnType("Nullable(String) DEFAULT NULL"))
+	assert.Equal(t, "Enum8('DEFAULT ' = 1)", extractColumnType("Enum8('DEFAULT ' = 1) CODEC(ZSTD(1))"))
+	assert.Equal(t, "UInt64", extractColumnType("UInt64"))
+}
+
+func TestGetAttachIncompatibleKeys(t *testing.T) {
+	backupQuery := "CREATE TABLE db.t (`id` UInt64, `d` Date TTL d + toIntervalDay(1) COMMENT 'ORDER BY d', PROJECTION p (SELECT * ORDER BY d)) ENGINE = ReplicatedMergeTree('/clickhouse/tables/{shard}/db/t', '{replica}') PARTITION BY toYYYYMM(d) ORDER BY (id, d) SETTINGS index_granularity = 8192"
+	assert.Empty(t, getAttachIncompatibleKeys("db", "t", backupQuery, "CREATE TABLE db.t (`id` UInt64, `d` Date) ENGINE = MergeTree PARTITION BY toYYYYMM(d) ORDER BY (id, d) SETTINGS index_granularity = 8192"))
+	assert.Equal(t, []string{
+		"`db`.`t` PARTITION BY changed (backup: toYYYYMM(d), server: toYYYYMMDD(d))",
+		"`db`.`t` ORDER BY changed (backup: (id, d), server: id)",
+	}, getAttachIncompatibleKeys("db", "t", backupQuery, "CREATE TABLE db.t (`id` UInt64, `d` Date) ENGINE = MergeTree PARTITION BY toYYYYMMDD(d) PRIMARY KEY id ORDER BY id"))
+	assert.Equal(t, []string{
+		"`db`.`t` ENGINE changed (backup: MergeTree, server: ReplacingMergeTree)",
+		"`db`.`t` PARTITION BY changed (backup: toYYYYMM(d), server: )",
+	}, getAttachIncompatibleKeys("db", "t", backupQuery, "CREATE TABLE db.t (`id` UInt64, `d` Date) ENGINE = ReplacingMergeTree ORDER BY (id, d)"))
+}
+
+func TestExtractDictionaryFilePath(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"CREATE DICTIONARY db.dict (`id` UInt64, `v` String) PRIMARY KEY id SOURCE(FILE(PATH '/var/lib/clickhouse/user_files/dict.csv' FORMAT 'CSV')) LIFETIME(MIN 0 MAX 0) LAYOUT(FLAT())", "/var/lib/clickhouse/user_files/dict.csv"},
+		{"CREATE DICTIONARY db.dict (`id` UInt64) PRIMARY KEY id SOURCE(FILE(PATH 'sub/../dict.tsv' FORMAT 'TabSeparated')) LIFETIME(0) LAYOUT(FLAT())", "/var/lib/clickhouse/user_files/dict.tsv"},
+		{"CREATE DICTIONARY db.dict (`id` UInt64) PRIMARY KEY id SOURCE(CLICKHOUSE(TABLE 'src' DB 'db')) LIFETIME(0) LAYOUT(FLAT())", ""},
+		{"CREATE TABLE db.t (`path` String) ENGINE = File(CSV, 'SOURCE(FILE(PATH ''x'''))", ""},
+	}
+	for _, tc := range testCases {
+		filePath, err := extractDictionaryFilePath(tc.query, "/var/lib/clickhouse/user_files")
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, filePath)
+	}
+	for _, traversalPath := range []string{"/etc/cron.d/backdoor", "../../../etc/passwd", "sub/../../user_files_other/x.csv", "/var/lib/clickhouse/user_files", "."} {
+		query := fmt.Sprintf("CREATE DICTIONARY db.dict (`id` UInt64) PRIMARY KEY id SOURCE(FILE(PATH '%s' FORMAT 'CSV')) LIFETIME(0) LAYOUT(FLAT())", traversalPath)
+		filePath, err := extractDictionaryFilePath(query, "/var/lib/clickhouse/user_files")
+		assert.Error(t, err, traversalPath)
+		assert.Equal(t, "", filePath)
+	}
+	dictionary := &metadata.TableMetadata{Query: "CREATE DICTIONARY db.dict (`id` UInt64) PRIMARY KEY id SOURCE(FILE(PATH 'dict.csv' FORMAT 'CSV')) LIFETIME(0) LAYOUT(FLAT())"}
+	table := &metadata.TableMetadata{Query: "CREATE TABLE db.t (`id` UInt64) ENGINE = MergeTree ORDER BY id"}
+	assert.False(t, hasDictionaryFiles([]*metadata.TableMetadata{table, nil}, "/var/lib/clickhouse/user_files"))
+	assert.True(t, hasDictionaryFiles([]*metadata.TableMetadata{table, dictionary}, "/var/lib/clickhouse/user_files"))
+}
+
+func TestGetRestoreCompatibilityWarnings(t *testing.T) {
+	backupMetadata := metadata.BackupMetadata{
+		ClickHouseVersion:  "v24.8.4.13-lts",
+		TableEngines:       []string{"MergeTree", "TimeSeries"},
+		ClickHouseSettings: map[string]string{"max_threads": "8", "new_setting": "1", "another_new_setting": "0"},
+	}
+	serverEngines := map[string]struct{}{"MergeTree": {}, "ReplicatedMergeTree": {}}
+	serverSettings := map[string]bool{"max_threads": true, "new_setting": false, "another_new_setting": false}
+
+	warnings := getRestoreCompatibilityWarnings(backupMetadata, 23008016, serverEngines, serverSettings)
+	assert.Equal(t, 3, len(warnings))
+	assert.Contains(t, warnings[0], "restore to older version 23008016")
+	assert.Contains(t, warnings[1], "table engine TimeSeries")
+	assert.Contains(t, warnings[2], "settings another_new_setting, new_setting")
+
+	assert.Empty(t, getRestoreCompatibilityWarnings(backupMetadata, 24008004, nil, nil))
+	assert.Empty(t, getRestoreCompatibilityWarnings(metadata.BackupMetadata{}, 23008016, serverEngines, serverSettings))
+}
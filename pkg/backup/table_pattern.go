@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
@@ -97,7 +98,10 @@ func (b *Backuper) getTableListByPatternLocal(ctx context.Context, metadataPath
 					return err
 				}
 				// .sql file will enrich Query
-				partitionsIdMap, _ := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{t}, partitions)
+				partitionsIdMap, _, err := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{t}, partitions)
+				if err != nil {
+					return err
+				}
 				filterPartsAndFilesByPartitionsFilter(t, partitionsIdMap[metadata.TableTitle{Database: t.Database, Table: t.Table}])
 				result = addTableToListIfNotExistsOrEnrichQueryAndParts(result, t)
 				return nil
@@ -106,7 +110,13 @@ func (b *Backuper) getTableListByPatternLocal(ctx context.Context, metadataPath
 			if err := json.Unmarshal(data, &t); err != nil {
 				return err
 			}
-			partitionsIdMap, partitionsNameList := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{t}, partitions)
+			if err := b.checkTableMetadataChecksum(path.Base(path.Dir(metadataPath)), &t); err != nil {
+				return err
+			}
+			partitionsIdMap, partitionsNameList, err := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, nil, []metadata.TableMetadata{t}, partitions)
+			if err != nil {
+				return err
+			}
 			filterPartsAndFilesByPartitionsFilter(t, partitionsIdMap[metadata.TableTitle{Database: t.Database, Table: t.Table}])
 			result = addTableToListIfNotExistsOrEnrichQueryAndParts(result, t)
 			for tt := range partitionsNameList {
@@ -141,7 +151,7 @@ func (b *Backuper) getTableListByPatternLocal(ctx context.Context, metadataPath
 func (b *Backuper) shouldSkipByTableName(tableFullName string) bool {
 	shallSkipped := false
 	for _, skipPattern := range b.cfg.ClickHouse.SkipTables {
-		if shallSkipped, _ = filepath.Match(skipPattern, tableFullName); shallSkipped {
+		if shallSkipped, _ = filepath.Match(strings.Trim(skipPattern, " \t\r\n"), tableFullName); shallSkipped {
 			break
 		}
 	}
@@ -257,7 +267,10 @@ func (b *Backuper) enrichTablePatternsByInnerDependencies(metadataPath string, t
 			}
 			if strings.HasPrefix(t.Query, "ATTACH MATERIALIZED") || strings.HasPrefix(t.Query, "CREATE MATERIALIZED") {
 				if strings.Contains(t.Query, " TO ") && !strings.Contains(t.Query, " TO INNER UUID") {
-					continue
+					if targetTableName, targetExists := b.getMaterializedViewTargetFromBackup(metadataPath, t.Query); targetExists && !checkTablePatternsMatch(tablePatterns, targetTableName) {
+						innerTablePatterns = append(innerTablePatterns, targetTableName)
+					}
+					return nil
 				}
 				innerTableFile := path.Join(names[:len(names)-1]...)
 				innerTableName := fmt.Sprintf("%s.", database)
@@ -272,13 +285,7 @@ func (b *Backuper) enrichTablePatternsByInnerDependencies(metadataPath string, t
 				if _, err := os.Stat(path.Join(metadataPath, innerTableFile+".json")); err != nil {
 					return err
 				}
-				innerPatternExists := false
-				for _, existsP := range tablePatterns {
-					if innerPatternExists, _ = filepath.Match(strings.Trim(existsP, " \t\r\n"), innerTableName); innerPatternExists {
-						break
-					}
-				}
-				if !innerPatternExists {
+				if !checkTablePatternsMatch(tablePatterns, innerTableName) {
 					innerTablePatterns = append(innerTablePatterns, innerTableName)
 				}
 			}
@@ -294,6 +301,33 @@ func (b *Backuper) enrichTablePatternsByInnerDependencies(metadataPath string, t
 	return tablePatterns, nil
 }
 
+func checkTablePatternsMatch(tablePatterns []string, tableName string) bool {
+	for _, p := range tablePatterns {
+		if matched, _ := filepath.Match(strings.Trim(p, " \t\r\n"), tableName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// getMaterializedViewTargetFromBackup - return `db.table` for MATERIALIZED VIEW ... TO db.table, when target table metadata present in backup and target table not skipped
+func (b *Backuper) getMaterializedViewTargetFromBackup(metadataPath string, query string) (string, bool) {
+	targetDatabase, targetTable, ok := clickhouse.ExtractMaterializedViewTarget(query)
+	if !ok {
+		return "", false
+	}
+	targetTableName := fmt.Sprintf("%s.%s", targetDatabase, targetTable)
+	targetTableFile := path.Join(metadataPath, common.TablePathEncode(targetDatabase), common.TablePathEncode(targetTable)+".json")
+	if _, err := os.Stat(targetTableFile); err != nil {
+		log.Warn().Msgf("%s not found in backup, materialized view target table shall exist before restore", targetTableName)
+		return "", false
+	}
+	if b.shouldSkipByTableName(targetTableName) {
+		return "", false
+	}
+	return targetTableName, true
+}
+
 var queryRE = regexp.MustCompile(`(?m)^(CREATE|ATTACH) (TABLE|VIEW|LIVE VIEW|MATERIALIZED VIEW|DICTIONARY|FUNCTION) (\x60?)([^\s\x60.]*)(\x60?)\.\x60?([^\s\x60.]*)\x60?( UUID '[^']+')?(?:( TO )(\x60?)([^\s\x60.]*)(\x60?)(\.)(\x60?)([^\s\x60.]*)(\x60?))?(?:(.+FROM )(\x60?)([^\s\x60.]*)(\x60?)(\.)(\x60?)([^\s\x60.]*)(\x60?))?`)
 var createOrAttachRE = regexp.MustCompile(`(?m)^(CREATE|ATTACH)`)
 var uuidRE = regexp.MustCompile(`UUID '([a-f\d\-]+)'`)
@@ -351,6 +385,8 @@ func changeTableQueryToAdjustDatabaseMapping(originTables *ListOfTables, dbMapRu
 				if strings.Contains(originPath, dbReplicatedPattern) {
 					substitution = fmt.Sprintf("${1}('%s'${3})", strings.Replace(originPath, dbReplicatedPattern, "/"+targetDB+"/", 1))
 					originTable.Query = replicatedRE.ReplaceAllString(originTable.Query, substitution)
+				} else if !strings.Contains(originPath, "{database}") && !strings.Contains(originPath, "{uuid}") {
+					log.Warn().Msgf("%s.%s replication path %s doesn't depend on database, restored %s.%s will share replicated data with source table when the source table exists on the same cluster", originTable.Database, originTable.Table, originPath, targetDB, originTable.Table)
 				}
 			}
 			// https://github.com/Altinity/clickhouse-backup/issues/547
@@ -370,10 +406,20 @@ func changeTableQueryToAdjustDatabaseMapping(originTables *ListOfTables, dbMapRu
 	return nil
 }
 
+// getRestoreTableMapping - `db.table` rule has priority over `table` rule which is applied for tables with the same name in all databases
+func getRestoreTableMapping(tableMapRule map[string]string, database, table string) (string, bool) {
+	if targetTable, isMapped := tableMapRule[database+"."+table]; isMapped {
+		return targetTable, true
+	}
+	targetTable, isMapped := tableMapRule[table]
+	return targetTable, isMapped
+}
+
+// changeTableQueryToAdjustTableMapping - shall be applied before changeTableQueryToAdjustDatabaseMapping, cause `db.table` rules contain source database
 func changeTableQueryToAdjustTableMapping(originTables *ListOfTables, tableMapRule map[string]string) error {
 	for i := 0; i < len(*originTables); i++ {
 		originTable := (*originTables)[i]
-		if targetTable, isMapped := tableMapRule[originTable.Table]; isMapped {
+		if targetTable, isMapped := getRestoreTableMapping(tableMapRule, originTable.Database, originTable.Table); isMapped {
 			// substitute table in the table create query
 			var substitution string
 
@@ -382,8 +428,11 @@ func changeTableQueryToAdjustTableMapping(originTables *ListOfTables, tableMapRu
 				if matches[0][6] != originTable.Table {
 					return fmt.Errorf("invalid SQL: %s for restore-table-mapping[%s]=%s", originTable.Query, originTable.Table, targetTable)
 				}
-				setMatchedDb := func(clauseTargetTable string) string {
-					if clauseMappedTable, isClauseMapped := tableMapRule[clauseTargetTable]; isClauseMapped {
+				setMatchedDb := func(clauseTargetDb, clauseTargetTable string) string {
+					if clauseTargetDb == "" {
+						clauseTargetDb = originTable.Database
+					}
+					if clauseMappedTable, isClauseMapped := getRestoreTableMapping(tableMapRule, clauseTargetDb, clauseTargetTable); isClauseMapped {
 						clauseTargetTable = clauseMappedTable
 						if !usualIdentifier.MatchString(clauseTargetTable) {
 							clauseTargetTable = "`" + clauseTargetTable + "`"
@@ -395,8 +444,8 @@ func changeTableQueryToAdjustTableMapping(originTables *ListOfTables, tableMapRu
 				if !usualIdentifier.MatchString(createTargetTable) {
 					createTargetTable = "`" + createTargetTable + "`"
 				}
-				toClauseTargetTable := setMatchedDb(matches[0][14])
-				fromClauseTargetTable := setMatchedDb(matches[0][22])
+				toClauseTargetTable := setMatchedDb(matches[0][10], matches[0][14])
+				fromClauseTargetTable := setMatchedDb(matches[0][18], matches[0][22])
 				// matching CREATE|ATTACH ... TO .. SELECT ... FROM ... command
 				substitution = fmt.Sprintf("${1} ${2} ${3}${4}${5}.%v${7}${8}${9}${10}${11}${12}${13}%v${15}${16}${17}${18}${19}${20}${21}%v${23}", createTargetTable, toClauseTargetTable, fromClauseTargetTable)
 			} else {
@@ -426,7 +475,8 @@ func changeTableQueryToAdjustTableMapping(originTables *ListOfTables, tableMapRu
 				matches := distributedRE.FindAllStringSubmatch(originTable.Query, -1)
 				underlyingTable := matches[0][4]
 				underlyingTableClean := strings.NewReplacer(" ", "", "'", "").Replace(underlyingTable)
-				if underlyingTargetTable, isUnderlyingMapped := tableMapRule[underlyingTableClean]; isUnderlyingMapped {
+				underlyingDBClean := strings.NewReplacer(" ", "", "'", "").Replace(matches[0][3])
+				if underlyingTargetTable, isUnderlyingMapped := getRestoreTableMapping(tableMapRule, underlyingDBClean, underlyingTableClean); isUnderlyingMapped {
 					substitution = fmt.Sprintf("${1}(${2},${3},%s${5})", strings.Replace(underlyingTable, underlyingTableClean, underlyingTargetTable, 1))
 					originTable.Query = distributedRE.ReplaceAllString(originTable.Query, substitution)
 				}
@@ -452,7 +502,7 @@ func changePartitionsToAdjustDatabaseMapping(partitionsNames map[metadata.TableT
 func changePartitionsToAdjustTableMapping(partitionsNames map[metadata.TableTitle][]string, tableMapping map[string]string) (map[metadata.TableTitle][]string, error) {
 	adjustedPartitionsNames := map[metadata.TableTitle][]string{}
 	for tableTitle, partitions := range partitionsNames {
-		if targetTable, isMapped := tableMapping[tableTitle.Table]; isMapped {
+		if targetTable, isMapped := getRestoreTableMapping(tableMapping, tableTitle.Database, tableTitle.Table); isMapped {
 			tableTitle.Table = targetTable
 		}
 		adjustedPartitionsNames[tableTitle] = partitions
@@ -589,6 +639,46 @@ func parseTablePatternForDownload(tables []metadata.TableTitle, tablePattern str
 	return result
 }
 
+// ApplyDatabaseFilter - restrict tablePattern to selected databases, each databases item could contain several names separated by comma
+func ApplyDatabaseFilter(tablePattern string, databases []string) (string, error) {
+	databaseNames := make([]string, 0)
+	for _, item := range databases {
+		for _, database := range strings.Split(item, ",") {
+			if database = strings.Trim(database, " \t\r\n"); database != "" {
+				databaseNames = common.AddStringToSliceIfNotExists(databaseNames, database)
+			}
+		}
+	}
+	if len(databaseNames) == 0 {
+		return tablePattern, nil
+	}
+	tablePatterns := []string{"*.*"}
+	if tablePattern != "" {
+		tablePatterns = strings.Split(tablePattern, ",")
+	}
+	result := make([]string, 0)
+	for _, database := range databaseNames {
+		for _, pattern := range tablePatterns {
+			pattern = strings.Trim(pattern, " \t\r\n")
+			dotIdx := strings.Index(pattern, ".")
+			// pattern without dot could match whole database, look https://github.com/Altinity/clickhouse-backup/issues/663
+			if dotIdx < 0 {
+				if matched, err := filepath.Match(pattern, database+"."); err == nil && matched {
+					result = common.AddStringToSliceIfNotExists(result, database+".*")
+				}
+				continue
+			}
+			if matched, err := filepath.Match(pattern[:dotIdx], database); err == nil && matched {
+				result = common.AddStringToSliceIfNotExists(result, database+pattern[dotIdx:])
+			}
+		}
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("--database=%s doesn't match with --tables=%s", strings.Join(databaseNames, ","), tablePattern)
+	}
+	return strings.Join(result, ","), nil
+}
+
 func IsInformationSchema(database string) bool {
 	for _, skipDatabase := range []string{"INFORMATION_SCHEMA", "information_schema", "_temporary_and_external_tables"} {
 		if database == skipDatabase {
@@ -599,6 +689,14 @@ func IsInformationSchema(database string) bool {
 }
 
 func ShallSkipDatabase(cfg *config.Config, targetDB, tablePattern string) bool {
+	// skip_tables and --exclude have priority over table pattern
+	for _, pattern := range cfg.ClickHouse.SkipTables {
+		pattern = strings.Trim(pattern, " \r\t\n")
+		// https://github.com/Altinity/clickhouse-backup/issues/663
+		if matched, err := filepath.Match(pattern, targetDB+"."); err == nil && matched {
+			return true
+		}
+	}
 	if tablePattern != "" {
 		var bypassTablePatterns []string
 		bypassTablePatterns = append(bypassTablePatterns, strings.Split(tablePattern, ",")...)
@@ -614,17 +712,78 @@ func ShallSkipDatabase(cfg *config.Config, targetDB, tablePattern string) bool {
 		}
 		return true
 	}
+	return false
+}
 
-	if len(cfg.ClickHouse.SkipTables) > 0 {
-		var skipTablesPatterns []string
-		skipTablesPatterns = append(skipTablesPatterns, cfg.ClickHouse.SkipTables...)
-		for _, pattern := range skipTablesPatterns {
-			pattern = strings.Trim(pattern, " \r\t\n")
-			// https://github.com/Altinity/clickhouse-backup/issues/663
-			if matched, err := filepath.Match(pattern, targetDB+"."); err == nil && matched {
-				return true
+// ParseExcludeTablePatterns - split `--exclude` values by comma, pattern without database like `tmp_*` matches tables in any database
+func ParseExcludeTablePatterns(excludePatterns []string) []string {
+	result := make([]string, 0)
+	for _, item := range excludePatterns {
+		for _, pattern := range strings.Split(item, ",") {
+			if pattern = strings.Trim(pattern, " \t\r\n"); pattern == "" {
+				continue
 			}
+			if !strings.Contains(pattern, ".") {
+				pattern = "*." + pattern
+			}
+			result = common.AddStringToSliceIfNotExists(result, pattern)
 		}
 	}
-	return false
+	return result
+}
+
+// CheckTablePatternMatches - each pattern separated by comma shall match at least one not skipped table, to avoid silent empty backups when pattern contains typo
+func CheckTablePatternMatches(tables []clickhouse.Table, tablePattern string) error {
+	if tablePattern == "" {
+		tablePattern = "*"
+	}
+	// the same symbols are removed in ClickHouse.prepareGetTablesSQL
+	replacer := strings.NewReplacer(" ", "", "`", "", `"`, "")
+	notMatchedPatterns := make([]string, 0)
+	for _, pattern := range strings.Split(tablePattern, ",") {
+		if pattern = replacer.Replace(strings.Trim(pattern, " \t\r\n")); pattern == "" {
+			continue
+		}
+		matched := false
+		for _, t := range tables {
+			if t.Skip {
+				continue
+			}
+			if isMatched, _ := filepath.Match(pattern, fmt.Sprintf("%s.%s", t.Database, t.Name)); isMatched {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			notMatchedPatterns = append(notMatchedPatterns, pattern)
+		}
+	}
+	if len(notMatchedPatterns) > 0 {
+		return fmt.Errorf("--strict: table pattern %s doesn't match any table, check skip_tables, skip_table_engines and skip_databases settings", strings.Join(notMatchedPatterns, ","))
+	}
+	return nil
+}
+
+// getNotMatchedTablePatterns - table patterns which don't match any table from backup metadata, used by `restore --ignore-missing`
+func getNotMatchedTablePatterns(tables ListOfTables, tablePattern string) []string {
+	notMatchedPatterns := make([]string, 0)
+	if tablePattern == "" {
+		return notMatchedPatterns
+	}
+	for _, pattern := range strings.Split(tablePattern, ",") {
+		if pattern = strings.Trim(pattern, " \t\r\n"); pattern == "" {
+			continue
+		}
+		matched := false
+		for _, t := range tables {
+			if isMatched, _ := filepath.Match(pattern, fmt.Sprintf("%s.%s", t.Database, t.Table)); isMatched {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			notMatchedPatterns = append(notMatchedPatterns, pattern)
+		}
+	}
+	return notMatchedPatterns
 }
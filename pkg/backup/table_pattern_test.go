@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDatabaseFilter(t *testing.T) {
+	testCases := []struct {
+		tablePattern string
+		databases    []string
+		expected     string
+		expectedErr  bool
+	}{
+		{tablePattern: "db1.t1", databases: nil, expected: "db1.t1"},
+		{tablePattern: "", databases: []string{"db1,db2"}, expected: "db1.*,db2.*"},
+		{tablePattern: "", databases: []string{"db1", " db2 ", "db1"}, expected: "db1.*,db2.*"},
+		{tablePattern: "*.t1,db2.t?", databases: []string{"db1,db2"}, expected: "db1.t1,db2.t1,db2.t?"},
+		{tablePattern: "db*", databases: []string{"db1"}, expected: "db1.*"},
+		{tablePattern: "db2.*", databases: []string{"db1"}, expectedErr: true},
+	}
+	for _, tc := range testCases {
+		actual, err := ApplyDatabaseFilter(tc.tablePattern, tc.databases)
+		if tc.expectedErr {
+			assert.Error(t, err, "tablePattern=%s databases=%v", tc.tablePattern, tc.databases)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, actual, "tablePattern=%s databases=%v", tc.tablePattern, tc.databases)
+	}
+}
+
+func TestCheckTablePatternMatches(t *testing.T) {
+	tables := []clickhouse.Table{
+		{Database: "db1", Name: "t1"},
+		{Database: "db1", Name: "t2"},
+		{Database: "db2", Name: "skipped", Skip: true},
+	}
+	assert.NoError(t, CheckTablePatternMatches(tables, ""))
+	assert.NoError(t, CheckTablePatternMatches(tables, "db1.*"))
+	assert.NoError(t, CheckTablePatternMatches(tables, " `db1`.t1 , db1.t?"))
+	assert.ErrorContains(t, CheckTablePatternMatches(tables, "db1.t1,db1.typo"), "db1.typo")
+	assert.ErrorContains(t, CheckTablePatternMatches(tables, "db2.*"), "db2.*")
+	assert.Error(t, CheckTablePatternMatches(nil, ""))
+}
+
+func TestParseExcludeTablePatterns(t *testing.T) {
+	assert.Equal(t, []string{}, ParseExcludeTablePatterns(nil))
+	assert.Equal(t, []string{"logs.*", "*.tmp_*"}, ParseExcludeTablePatterns([]string{"logs.*,tmp_*"}))
+	assert.Equal(t, []string{"logs.*", "db1.t?"}, ParseExcludeTablePatterns([]string{" logs.* ,", "db1.t?", "logs.*"}))
+}
+
+func TestShallSkipDatabase(t *testing.T) {
+	cfg := &config.Config{ClickHouse: config.ClickHouseConfig{SkipTables: []string{"system.*", "logs.*", "*.tmp_*"}}}
+	assert.True(t, ShallSkipDatabase(cfg, "logs", ""))
+	assert.True(t, ShallSkipDatabase(cfg, "logs", "logs.*"))
+	assert.False(t, ShallSkipDatabase(cfg, "db1", ""))
+	assert.False(t, ShallSkipDatabase(cfg, "db1", "db1.*"))
+	assert.True(t, ShallSkipDatabase(cfg, "db2", "db1.*"))
+}
+
+func TestChangeTableQueryToAdjustTableMapping(t *testing.T) {
+	tables := ListOfTables{
+		{Database: "db1", Table: "events", Query: "CREATE TABLE db1.events UUID '00000000-0000-0000-0000-000000000001' (id UInt64) ENGINE = ReplicatedMergeTree('/clickhouse/tables/{shard}/db1/events', '{replica}') ORDER BY id"},
+		{Database: "db2", Table: "events", Query: "CREATE TABLE db2.events (id UInt64) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db2", Table: "users", Query: "CREATE TABLE db2.users (id UInt64) ENGINE = MergeTree ORDER BY id"},
+	}
+	assert.NoError(t, changeTableQueryToAdjustTableMapping(&tables, map[string]string{"db1.events": "events_restored", "users": "users_restored"}))
+	assert.Equal(t, "events_restored", tables[0].Table)
+	assert.Contains(t, tables[0].Query, "CREATE TABLE db1.events_restored UUID")
+	assert.NotContains(t, tables[0].Query, "00000000-0000-0000-0000-000000000001")
+	assert.Contains(t, tables[0].Query, "'/clickhouse/tables/{shard}/db1/events_restored'")
+	assert.Equal(t, "events", tables[1].Table)
+	assert.Equal(t, "users_restored", tables[2].Table)
+	assert.Contains(t, tables[2].Query, "CREATE TABLE db2.users_restored ")
+}
+
+func TestEnrichTablePatternsByMaterializedViewTarget(t *testing.T) {
+	metadataPath := t.TempDir()
+	writeTableMetadata := func(database, table, query string) {
+		tableMetadata := metadata.TableMetadata{Database: database, Table: table, Query: query}
+		data, err := json.Marshal(tableMetadata)
+		assert.NoError(t, err)
+		assert.NoError(t, os.MkdirAll(path.Join(metadataPath, common.TablePathEncode(database)), 0750))
+		assert.NoError(t, os.WriteFile(path.Join(metadataPath, common.TablePathEncode(database), common.TablePathEncode(table)+".json"), data, 0640))
+	}
+	writeTableMetadata("db1", "src", "CREATE TABLE db1.src (`id` UInt64) ENGINE = MergeTree ORDER BY id")
+	writeTableMetadata("db2", "target", "CREATE TABLE db2.target (`id` UInt64) ENGINE = MergeTree ORDER BY id")
+	writeTableMetadata("db1", "mv_to_target", "CREATE MATERIALIZED VIEW db1.mv_to_target TO db2.target (`id` UInt64) AS SELECT id FROM db1.src")
+	writeTableMetadata("db1", "mv_to_missed", "CREATE MATERIALIZED VIEW db1.mv_to_missed TO db3.missed (`id` UInt64) AS SELECT id FROM db1.src")
+
+	b := &Backuper{cfg: &config.Config{}}
+	tablePatterns, err := b.enrichTablePatternsByInnerDependencies(metadataPath, []string{"db1.mv_to_target"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db2.target", "db1.mv_to_target"}, tablePatterns)
+
+	tablePatterns, err = b.enrichTablePatternsByInnerDependencies(metadataPath, []string{"db1.mv_to_target", "db2.*"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1.mv_to_target", "db2.*"}, tablePatterns)
+
+	tablePatterns, err = b.enrichTablePatternsByInnerDependencies(metadataPath, []string{"db1.mv_to_missed"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1.mv_to_missed"}, tablePatterns)
+
+	b.cfg.ClickHouse.SkipTables = []string{"db2.*"}
+	tablePatterns, err = b.enrichTablePatternsByInnerDependencies(metadataPath, []string{"db1.mv_to_target"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1.mv_to_target"}, tablePatterns)
+}
+
+func TestGetNotMatchedTablePatterns(t *testing.T) {
+	tables := ListOfTables{
+		{Database: "db1", Table: "events"},
+		{Database: "db2", Table: "users"},
+	}
+	assert.Empty(t, getNotMatchedTablePatterns(tables, ""))
+	assert.Empty(t, getNotMatchedTablePatterns(tables, "*"))
+	assert.Empty(t, getNotMatchedTablePatterns(tables, "db1.events, db2.*"))
+	assert.Equal(t, []string{"db1.missing", "db3.*"}, getNotMatchedTablePatterns(tables, "db1.events,db1.missing,db3.*"))
+}
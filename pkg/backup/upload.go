@@ -12,27 +12,29 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/custom"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/events"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
-	"github.com/eapache/go-resiliency/retrier"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/partition"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
 	"github.com/rs/zerolog/log"
 	"github.com/yargevad/filepathx"
 )
 
-func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, resume bool, backupVersion string, commandId int) (err error) {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -42,6 +44,17 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 
 	startUpload := time.Now()
 	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	defer func() {
+		// replica upload is part of main upload, so finish hooks and notifications are sent once
+		if b.replicaName == "" {
+			b.notify("upload", backupName, startUpload, err)
+		}
+	}()
+	// replicas choose own diff base with upload_diff_from_latest_remote
+	replicaDiffFromRemote := diffFromRemote
+	if partitions, err = partition.ApplyPartitionsTimeTemplate(partitions, time.Now()); err != nil {
+		return err
+	}
 	var disks []clickhouse.Disk
 	b.adjustResumeFlag(resume)
 	if err = b.ch.Connect(); err != nil {
@@ -52,7 +65,16 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		return err
 	}
 	if b.cfg.General.RemoteStorage == "custom" {
-		return custom.Upload(ctx, b.cfg, backupName, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly)
+		if err = custom.Upload(ctx, b.cfg, backupName, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly); err != nil {
+			return err
+		}
+		if err = b.RemoveOldBackupsCustom(ctx); err != nil {
+			return fmt.Errorf("can't remove old backups on remote storage: %v", err)
+		}
+		if err = b.RemoveOldBackupsLocal(ctx, false, nil); err != nil {
+			return fmt.Errorf("can't remove old local backups: %v", err)
+		}
+		return nil
 	}
 	if _, disks, err = b.getLocalBackup(ctx, backupName, nil); err != nil {
 		return fmt.Errorf("can't find local backup: %v", err)
@@ -85,6 +107,12 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 	}
 	var tablesForUpload ListOfTables
 	b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
+	if diffFrom == "" && diffFromRemote == "" && b.cfg.General.UploadDiffFromLatestRemote && !b.isEmbedded && !b.resume {
+		if latestBackup := getLatestRemoteBackupForDiff(remoteBackups, backupName, b.cfg.General.UploadDiffMaxChainLength, b.cfg.General.UploadDiffFullDuration, time.Now()); latestBackup != "" {
+			log.Info().Msgf("upload_diff_from_latest_remote: %s will use %s as required backup", backupName, latestBackup)
+			diffFromRemote = latestBackup
+		}
+	}
 	// will ignore partitions cause can't manipulate .backup
 	if b.isEmbedded {
 		partitions = make([]string, 0)
@@ -112,7 +140,11 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		backupMetadata.RequiredBackup = diffFromRemote
 	}
 	if b.resume {
-		b.resumableState = resumable.NewState(b.GetStateDir(), backupName, "upload", map[string]interface{}{
+		stateCommand := "upload"
+		if b.replicaName != "" {
+			stateCommand = "upload_replica_" + b.replicaName
+		}
+		b.resumableState = resumable.NewState(b.GetStateDir(), backupName, stateCommand, map[string]interface{}{
 			"diffFrom":       diffFrom,
 			"diffFromRemote": diffFromRemote,
 			"tablePattern":   tablePattern,
@@ -145,13 +177,15 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 			//skip upload data for embedded backup with empty embedded_backup_disk
 			if !schemaOnly && (!b.isEmbedded || b.cfg.ClickHouse.EmbeddedBackupDisk != "") {
 				var files map[string][]string
+				var checksums map[string]string
 				var err error
-				files, uploadedBytes, err = b.uploadTableData(uploadCtx, backupName, deleteSource, tablesForUpload[idx])
+				files, checksums, uploadedBytes, err = b.uploadTableData(uploadCtx, backupName, deleteSource, tablesForUpload[idx])
 				if err != nil {
 					return err
 				}
 				atomic.AddInt64(&compressedDataSize, uploadedBytes)
 				tablesForUpload[idx].Files = files
+				tablesForUpload[idx].Checksums = checksums
 			}
 			tableMetadataSize, err := b.uploadTableMetadata(uploadCtx, backupName, backupMetadata.RequiredBackup, tablesForUpload[idx])
 			if err != nil {
@@ -173,15 +207,37 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		return fmt.Errorf("one of upload table go-routine return error: %v", err)
 	}
 
+	// checksums of uploaded access, configs, dictionaries, detached and keeper objects
+	backupMetadata.Checksums = map[string]string{}
 	// upload rbac for backup
-	if backupMetadata.RBACSize, err = b.uploadRBACData(ctx, backupName); err != nil {
+	if backupMetadata.RBACSize, err = b.uploadRBACData(ctx, backupName, backupMetadata.Checksums); err != nil {
 		return fmt.Errorf("b.uploadRBACData return error: %v", err)
 	}
 
 	// upload configs for backup
-	if backupMetadata.ConfigSize, err = b.uploadConfigData(ctx, backupName); err != nil {
+	if backupMetadata.ConfigSize, err = b.uploadConfigData(ctx, backupName, backupMetadata.Checksums); err != nil {
 		return fmt.Errorf("b.uploadConfigData return error: %v", err)
 	}
+	// upload dictionary source files for backup, uploadBackupRelatedDir skips backups without `dictionaries` directory
+	dictionaryFilesSize, err := b.uploadDictionaryFilesData(ctx, backupName, backupMetadata.Checksums)
+	if err != nil {
+		return fmt.Errorf("b.uploadDictionaryFilesData return error: %v", err)
+	}
+	backupMetadata.ConfigSize += dictionaryFilesSize
+	// upload detached parts for backup, `create --include-detached`
+	detachedSize, err := b.uploadDetachedPartsData(ctx, backupName, backupMetadata.Checksums)
+	if err != nil {
+		return fmt.Errorf("b.uploadDetachedPartsData return error: %v", err)
+	}
+	backupMetadata.ConfigSize += detachedSize
+	// upload keeper snapshot for backup
+	if b.cfg.ClickHouse.KeeperSnapshotPath != "" {
+		keeperSnapshotSize, keeperErr := b.uploadKeeperSnapshotData(ctx, backupName, backupMetadata.Checksums)
+		if keeperErr != nil {
+			return fmt.Errorf("b.uploadKeeperSnapshotData return error: %v", keeperErr)
+		}
+		backupMetadata.ConfigSize += keeperSnapshotSize
+	}
 	//upload embedded .backup file
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" && backupMetadata.Tables != nil && len(backupMetadata.Tables) > 0 {
 		localClickHouseBackupFile := path.Join(b.EmbeddedBackupDataPath, backupName, ".backup")
@@ -210,13 +266,23 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		backupMetadata.DataFormat = DirectoryFormat
 	}
 	backupMetadata.ClickhouseBackupVersion = backupVersion
+	// tables checksums allow detect corrupted table metadata during download, even without `general->metadata_signing_key`
+	backupMetadata.TablesChecksums = nil
+	if !b.isEmbedded {
+		if err := backupMetadata.SetTablesChecksums(tablesForUpload); err != nil {
+			return err
+		}
+	}
+	if err := backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+		return err
+	}
 	newBackupMetadataBody, err := json.MarshalIndent(backupMetadata, "", "\t")
 	if err != nil {
 		return err
 	}
 	remoteBackupMetaFile := path.Join(backupName, "metadata.json")
 	if !b.resume || (b.resume && !b.resumableState.IsAlreadyProcessedBool(remoteBackupMetaFile)) {
-		retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+		retry := storage.NewRetrier(&b.cfg.General)
 		err = retry.RunCtx(ctx, func(ctx context.Context) error {
 			return b.dst.PutFile(ctx, remoteBackupMetaFile, io.NopCloser(bytes.NewReader(newBackupMetadataBody)))
 		})
@@ -233,9 +299,22 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		"duration":         utils.HumanizeDuration(time.Since(startUpload)),
 		"upload_size":      utils.FormatBytes(uint64(compressedDataSize) + uint64(metadataSize) + uint64(len(newBackupMetadataBody)) + backupMetadata.RBACSize + backupMetadata.ConfigSize),
 		"object_disk_size": utils.FormatBytes(backupMetadata.ObjectDiskSize),
+		"replica":          b.replicaName,
 		"version":          backupVersion,
 	}).Msg("done")
 
+	// upload to `general->replica_configs`, status of each replica is stored into metadata.json on main remote storage, local backup is kept when any replica failed
+	var replicasErr error
+	if len(b.cfg.General.ReplicaConfigs) > 0 {
+		backupMetadata.Replicas, replicasErr = b.uploadReplicas(backupName, diffFrom, replicaDiffFromRemote, tablePattern, partitions, schemaOnly, resume, backupVersion, commandId)
+		if err = backupMetadata.Sign(b.cfg.General.MetadataSigningKey); err != nil {
+			return err
+		}
+		if err = b.dst.PutBackupMetadata(ctx, *backupMetadata); err != nil {
+			return fmt.Errorf("can't save replicas status: %v", err)
+		}
+	}
+
 	// Remote old backup retention
 	if err = b.RemoveOldBackupsRemote(ctx); err != nil {
 		return fmt.Errorf("can't remove old backups on remote storage: %v", err)
@@ -245,6 +324,10 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		return fmt.Errorf("can't remove old local backups: %v", err)
 	}
 
+	if replicasErr != nil {
+		return replicasErr
+	}
+
 	// explicitly delete local backup after successful upload, fix https://github.com/Altinity/clickhouse-backup/issues/777
 	if b.cfg.General.BackupsToKeepLocal >= 0 && deleteSource {
 		if err = b.RemoveBackupLocal(ctx, backupName, disks); err != nil {
@@ -254,9 +337,42 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 	return nil
 }
 
+// RemoveOldBackupsCustom - apply backups_to_keep_remote for `remote_storage: custom`, upload_command doesn't know about retention settings
+func (b *Backuper) RemoveOldBackupsCustom(ctx context.Context) error {
+	if !b.remoteRetentionPolicy().IsEnabled() {
+		return nil
+	}
+	start := time.Now()
+	backupList, err := custom.List(ctx, b.cfg)
+	if err != nil {
+		return err
+	}
+	// list_command could return backups without upload_date, GetBackupsToDeleteRemote skip such backups
+	for i := range backupList {
+		if backupList[i].UploadDate.IsZero() {
+			backupList[i].UploadDate = backupList[i].CreationDate
+		}
+	}
+	for _, backupToDelete := range storage.GetBackupsToDeleteRemoteByPolicy(backupList, b.remoteRetentionPolicy(), time.Now(), b.cfg.General.RetentionTimeLocation) {
+		startDelete := time.Now()
+		if err = custom.DeleteRemote(ctx, b.cfg, backupToDelete.BackupName); err != nil {
+			log.Warn().Msgf("can't delete %s return error : %v", backupToDelete.BackupName, err)
+			continue
+		}
+		log.Info().Fields(map[string]interface{}{
+			"operation": "RemoveOldBackupsCustom",
+			"location":  "remote",
+			"backup":    backupToDelete.BackupName,
+			"duration":  utils.HumanizeDuration(time.Since(startDelete)),
+		}).Msg("done")
+	}
+	log.Info().Fields(map[string]interface{}{"operation": "RemoveOldBackupsCustom", "duration": utils.HumanizeDuration(time.Since(start))}).Msg("done")
+	return nil
+}
+
 func (b *Backuper) RemoveOldBackupsRemote(ctx context.Context) error {
 
-	if b.cfg.General.BackupsToKeepRemote < 1 {
+	if !b.remoteRetentionPolicy().IsEnabled() {
 		return nil
 	}
 	start := time.Now()
@@ -264,13 +380,18 @@ func (b *Backuper) RemoveOldBackupsRemote(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	backupsToDelete := storage.GetBackupsToDeleteRemote(backupList, b.cfg.General.BackupsToKeepRemote)
+	backupsToDelete := storage.GetBackupsToDeleteRemoteByPolicy(backupList, b.remoteRetentionPolicy(), time.Now(), b.cfg.General.RetentionTimeLocation)
 	log.Info().Fields(map[string]interface{}{
 		"operation": "RemoveOldBackupsRemote",
 		"duration":  utils.HumanizeDuration(time.Since(start)),
 	}).Msg("calculate backup list for delete remote")
 	for _, backupToDelete := range backupsToDelete {
 		startDelete := time.Now()
+		// object disk data shall be kept together with locked backup
+		if err = b.dst.CheckBackupObjectLock(ctx, backupToDelete.BackupName); err != nil {
+			log.Warn().Msgf("skip retention: %v", err)
+			continue
+		}
 		err = b.cleanEmbeddedAndObjectDiskRemoteIfSameLocalNotPresent(ctx, backupToDelete)
 		if err != nil {
 			return err
@@ -278,6 +399,8 @@ func (b *Backuper) RemoveOldBackupsRemote(ctx context.Context) error {
 
 		if err := b.dst.RemoveBackupRemote(ctx, backupToDelete, b.cfg); err != nil {
 			log.Warn().Msgf("can't deleteKey %s return error : %v", backupToDelete.BackupName, err)
+		} else {
+			events.Current.Publish(events.Deleted, backupToDelete.BackupName, "remote", nil)
 		}
 		log.Info().Fields(map[string]interface{}{
 			"operation": "RemoveOldBackupsRemote",
@@ -305,7 +428,7 @@ func (b *Backuper) uploadSingleBackupFile(ctx context.Context, localFile, remote
 			log.Warn().Msgf("can't close %v: %v", f, err)
 		}
 	}()
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
 		return b.dst.PutFile(ctx, remoteFile, f)
 	})
@@ -334,6 +457,46 @@ func (b *Backuper) prepareTableListToUpload(ctx context.Context, backupName stri
 	return tablesForUpload, nil
 }
 
+// getLatestRemoteBackupForDiff - return the latest complete non-embedded remote backup, which could be used as `--diff-from-remote`,
+// empty when full backup at the beginning of its `required_backup` chain is older than fullInterval or chain already contains maxChainLength increments
+func getLatestRemoteBackupForDiff(remoteBackups []storage.Backup, backupName string, maxChainLength int, fullInterval time.Duration, now time.Time) string {
+	var latestBackup *storage.Backup
+	backupsByName := make(map[string]*storage.Backup, len(remoteBackups))
+	for i := range remoteBackups {
+		backupsByName[remoteBackups[i].BackupName] = &remoteBackups[i]
+		if remoteBackups[i].BackupName == backupName || remoteBackups[i].Broken != "" || strings.Contains(remoteBackups[i].Tags, "embedded") {
+			continue
+		}
+		if latestBackup == nil || remoteBackups[i].UploadDate.After(latestBackup.UploadDate) {
+			latestBackup = &remoteBackups[i]
+		}
+	}
+	if latestBackup == nil {
+		return ""
+	}
+	fullBackup := latestBackup
+	chainLength := 1
+	for fullBackup.RequiredBackup != "" {
+		requiredBackup, exists := backupsByName[fullBackup.RequiredBackup]
+		// missing or cyclic required backup, new chain shall start from full backup
+		if !exists || chainLength > len(remoteBackups) {
+			log.Warn().Msgf("upload_diff_from_latest_remote: required backup %s of %s is not found or cyclic, will upload full backup", fullBackup.RequiredBackup, fullBackup.BackupName)
+			return ""
+		}
+		fullBackup = requiredBackup
+		chainLength++
+	}
+	if maxChainLength > 0 && chainLength > maxChainLength {
+		log.Info().Msgf("upload_diff_from_latest_remote: %s already contains %d increments, will upload full backup", latestBackup.BackupName, chainLength-1)
+		return ""
+	}
+	if fullInterval > 0 && now.Sub(fullBackup.UploadDate) >= fullInterval {
+		log.Info().Msgf("upload_diff_from_latest_remote: full backup %s is older than upload_diff_full_interval %s, will upload full backup", fullBackup.BackupName, fullInterval)
+		return ""
+	}
+	return latestBackup.BackupName
+}
+
 func (b *Backuper) validateUploadParams(ctx context.Context, backupName string, diffFrom string, diffFromRemote string) error {
 	if b.cfg.General.RemoteStorage == "none" {
 		return fmt.Errorf("general->remote_storage shall not be \"none\" for upload, change you config or use REMOTE_STORAGE environment variable")
@@ -380,7 +543,7 @@ func (b *Backuper) validateUploadParams(ctx context.Context, backupName string,
 	return nil
 }
 
-func (b *Backuper) uploadConfigData(ctx context.Context, backupName string) (uint64, error) {
+func (b *Backuper) uploadConfigData(ctx context.Context, backupName string, checksums map[string]string) (uint64, error) {
 	backupPath := b.DefaultDataPath
 	configBackupPath := path.Join(backupPath, "backup", backupName, "configs")
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
@@ -390,13 +553,13 @@ func (b *Backuper) uploadConfigData(ctx context.Context, backupName string) (uin
 	configFilesGlobPattern := path.Join(configBackupPath, "**/*.*")
 	if b.cfg.GetCompressionFormat() == "none" {
 		remoteConfigsDir := path.Join(backupName, "configs")
-		return b.uploadBackupRelatedDir(ctx, configBackupPath, configFilesGlobPattern, remoteConfigsDir)
+		return b.uploadBackupRelatedDir(ctx, configBackupPath, configFilesGlobPattern, remoteConfigsDir, checksums)
 	}
 	remoteConfigsArchive := path.Join(backupName, fmt.Sprintf("configs.%s", b.cfg.GetArchiveExtension()))
-	return b.uploadBackupRelatedDir(ctx, configBackupPath, configFilesGlobPattern, remoteConfigsArchive)
+	return b.uploadBackupRelatedDir(ctx, configBackupPath, configFilesGlobPattern, remoteConfigsArchive, checksums)
 }
 
-func (b *Backuper) uploadRBACData(ctx context.Context, backupName string) (uint64, error) {
+func (b *Backuper) uploadRBACData(ctx context.Context, backupName string, checksums map[string]string) (uint64, error) {
 	backupPath := b.DefaultDataPath
 	rbacBackupPath := path.Join(backupPath, "backup", backupName, "access")
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
@@ -406,18 +569,67 @@ func (b *Backuper) uploadRBACData(ctx context.Context, backupName string) (uint6
 	accessFilesGlobPattern := path.Join(rbacBackupPath, "*.*")
 	if b.cfg.GetCompressionFormat() == "none" {
 		remoteRBACDir := path.Join(backupName, "access")
-		return b.uploadBackupRelatedDir(ctx, rbacBackupPath, accessFilesGlobPattern, remoteRBACDir)
+		return b.uploadBackupRelatedDir(ctx, rbacBackupPath, accessFilesGlobPattern, remoteRBACDir, checksums)
 	}
 	remoteRBACArchive := path.Join(backupName, fmt.Sprintf("access.%s", b.cfg.GetArchiveExtension()))
-	return b.uploadBackupRelatedDir(ctx, rbacBackupPath, accessFilesGlobPattern, remoteRBACArchive)
+	return b.uploadBackupRelatedDir(ctx, rbacBackupPath, accessFilesGlobPattern, remoteRBACArchive, checksums)
+}
+
+func (b *Backuper) uploadDictionaryFilesData(ctx context.Context, backupName string, checksums map[string]string) (uint64, error) {
+	backupPath := b.DefaultDataPath
+	dictionaryFilesBackupPath := path.Join(backupPath, "backup", backupName, dictionaryFilesDir)
+	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		backupPath = b.EmbeddedBackupDataPath
+		dictionaryFilesBackupPath = path.Join(backupPath, backupName, dictionaryFilesDir)
+	}
+	dictionaryFilesGlobPattern := path.Join(dictionaryFilesBackupPath, "**/*")
+	if b.cfg.GetCompressionFormat() == "none" {
+		remoteDictionaryFilesDir := path.Join(backupName, dictionaryFilesDir)
+		return b.uploadBackupRelatedDir(ctx, dictionaryFilesBackupPath, dictionaryFilesGlobPattern, remoteDictionaryFilesDir, checksums)
+	}
+	remoteDictionaryFilesArchive := path.Join(backupName, fmt.Sprintf("%s.%s", dictionaryFilesDir, b.cfg.GetArchiveExtension()))
+	return b.uploadBackupRelatedDir(ctx, dictionaryFilesBackupPath, dictionaryFilesGlobPattern, remoteDictionaryFilesArchive, checksums)
+}
+
+func (b *Backuper) uploadDetachedPartsData(ctx context.Context, backupName string, checksums map[string]string) (uint64, error) {
+	detachedBackupPath := path.Join(b.DefaultDataPath, "backup", backupName, detachedPartsDir)
+	detachedFilesGlobPattern := path.Join(detachedBackupPath, "**/*")
+	if b.cfg.GetCompressionFormat() == "none" {
+		remoteDetachedDir := path.Join(backupName, detachedPartsDir)
+		return b.uploadBackupRelatedDir(ctx, detachedBackupPath, detachedFilesGlobPattern, remoteDetachedDir, checksums)
+	}
+	remoteDetachedArchive := path.Join(backupName, fmt.Sprintf("%s.%s", detachedPartsDir, b.cfg.GetArchiveExtension()))
+	return b.uploadBackupRelatedDir(ctx, detachedBackupPath, detachedFilesGlobPattern, remoteDetachedArchive, checksums)
 }
 
-func (b *Backuper) uploadBackupRelatedDir(ctx context.Context, localBackupRelatedDir, localFilesGlobPattern, destinationRemote string) (uint64, error) {
+func (b *Backuper) uploadKeeperSnapshotData(ctx context.Context, backupName string, checksums map[string]string) (uint64, error) {
+	backupPath := b.DefaultDataPath
+	keeperBackupPath := path.Join(backupPath, "backup", backupName, "keeper")
+	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		backupPath = b.EmbeddedBackupDataPath
+		keeperBackupPath = path.Join(backupPath, backupName, "keeper")
+	}
+	keeperFilesGlobPattern := path.Join(keeperBackupPath, "snapshot_*")
+	if b.cfg.GetCompressionFormat() == "none" {
+		remoteKeeperDir := path.Join(backupName, "keeper")
+		return b.uploadBackupRelatedDir(ctx, keeperBackupPath, keeperFilesGlobPattern, remoteKeeperDir, checksums)
+	}
+	remoteKeeperArchive := path.Join(backupName, fmt.Sprintf("keeper.%s", b.cfg.GetArchiveExtension()))
+	return b.uploadBackupRelatedDir(ctx, keeperBackupPath, keeperFilesGlobPattern, remoteKeeperArchive, checksums)
+}
+
+// uploadBackupRelatedDir - upload files from localBackupRelatedDir into destinationRemote archive or directory, SHA-256 of each uploaded object is added into checksums with path relative to backup
+func (b *Backuper) uploadBackupRelatedDir(ctx context.Context, localBackupRelatedDir, localFilesGlobPattern, destinationRemote string, checksums map[string]string) (uint64, error) {
 	if _, err := os.Stat(localBackupRelatedDir); os.IsNotExist(err) {
 		return 0, nil
 	}
+	// destinationRemote is always <backup_name>/<dir or archive>
+	checksumPrefix := path.Base(destinationRemote)
 	if b.resume {
 		if isProcessed, processedSize := b.resumableState.IsAlreadyProcessed(destinationRemote); isProcessed {
+			if checksum := b.resumableState.GetChecksumFromState(destinationRemote); checksum != "" {
+				checksums[checksumPrefix] = checksum
+			}
 			return uint64(processedSize), nil
 		}
 	}
@@ -441,49 +653,62 @@ func (b *Backuper) uploadBackupRelatedDir(ctx context.Context, localBackupRelate
 	}
 	if b.cfg.GetCompressionFormat() == "none" {
 		remoteUploadedBytes := int64(0)
-		if remoteUploadedBytes, err = b.dst.UploadPath(ctx, localBackupRelatedDir, localFiles, destinationRemote, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.UploadMaxBytesPerSecond); err != nil {
+		var uploadedChecksums map[string]string
+		if remoteUploadedBytes, uploadedChecksums, err = b.dst.UploadPath(ctx, localBackupRelatedDir, localFiles, destinationRemote, &b.cfg.General, b.cfg.General.UploadMaxBytesPerSecond); err != nil {
 			return 0, fmt.Errorf("can't RBAC or config upload %s: %v", destinationRemote, err)
 		}
+		for fileName, checksum := range uploadedChecksums {
+			checksums[path.Join(checksumPrefix, fileName)] = checksum
+		}
 		if b.resume {
 			b.resumableState.AppendToState(destinationRemote, remoteUploadedBytes)
 		}
 		return uint64(remoteUploadedBytes), nil
 	}
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
+	var checksum string
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
-		return b.dst.UploadCompressedStream(ctx, localBackupRelatedDir, localFiles, destinationRemote, b.cfg.General.UploadMaxBytesPerSecond)
+		var uploadErr error
+		checksum, uploadErr = b.dst.UploadCompressedStream(ctx, localBackupRelatedDir, localFiles, destinationRemote, b.cfg.General.UploadMaxBytesPerSecond)
+		return uploadErr
 	})
 	if err != nil {
 		return 0, fmt.Errorf("can't RBAC or config upload compressed %s: %v", destinationRemote, err)
 	}
+	checksums[checksumPrefix] = checksum
 
 	var remoteUploaded storage.RemoteFile
-	retry = retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	// NewUploadedStatRetrier retries ErrNotFound, so call storage directly without BackupDestination retries
+	retry = storage.NewUploadedStatRetrier(&b.cfg.General)
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
-		remoteUploaded, err = b.dst.StatFile(ctx, destinationRemote)
+		remoteUploaded, err = b.dst.RemoteStorage.StatFile(ctx, destinationRemote)
 		return err
 	})
 	if err != nil {
 		return 0, fmt.Errorf("can't check uploaded destinationRemote: %s, error: %v", destinationRemote, err)
 	}
 	if b.resume {
+		b.resumableState.AppendChecksumToState(destinationRemote, checksum)
 		b.resumableState.AppendToState(destinationRemote, remoteUploaded.Size())
 	}
 	return uint64(remoteUploaded.Size()), nil
 }
 
-func (b *Backuper) uploadTableData(ctx context.Context, backupName string, deleteSource bool, table metadata.TableMetadata) (map[string][]string, int64, error) {
+// uploadTableData - return uploaded archive names for each disk and SHA-256 checksum for each uploaded archive, or for each `disk/part/file` when compression_format is none
+func (b *Backuper) uploadTableData(ctx context.Context, backupName string, deleteSource bool, table metadata.TableMetadata) (map[string][]string, map[string]string, int64, error) {
 	dbAndTablePath := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
 	uploadedFiles := map[string][]string{}
+	uploadedChecksums := map[string]string{}
+	var uploadedChecksumsMutex sync.Mutex
 	capacity := 0
 	for disk := range table.Parts {
 		capacity += len(table.Parts[disk])
 	}
-	log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Parts[...])=%d", table.Database, table.Table, b.cfg.General.UploadConcurrency, capacity)
+	log.Debug().Msgf("start %s.%s with concurrency=%d len(table.Parts[...])=%d", table.Database, table.Table, b.cfg.GetUploadObjectsConcurrency(), capacity)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	dataGroup, ctx := errgroup.WithContext(ctx)
-	dataGroup.SetLimit(int(b.cfg.General.UploadConcurrency))
+	dataGroup.SetLimit(b.cfg.GetUploadObjectsConcurrency())
 	var uploadedBytes int64
 
 	splitParts := make(map[string][]metadata.SplitPartFiles)
@@ -493,7 +718,7 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 		backupPath := b.getLocalBackupDataPathForTable(backupName, disk, dbAndTablePath)
 		splitPartsList, err := b.splitPartFiles(backupPath, table.Parts[disk])
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, 0, err
 		}
 		splitParts[disk] = splitPartsList
 		splitPartsOffset[disk] = 0
@@ -517,16 +742,32 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 					if b.resume {
 						if isProcessed, processedSize := b.resumableState.IsAlreadyProcessed(remotePathFull); isProcessed {
 							atomic.AddInt64(&uploadedBytes, processedSize)
+							uploadedChecksumsMutex.Lock()
+							for _, f := range partFiles {
+								if checksum := b.resumableState.GetChecksumFromState(path.Join(remotePath, f)); checksum != "" {
+									uploadedChecksums[path.Join(disk, f)] = checksum
+								}
+							}
+							uploadedChecksumsMutex.Unlock()
 							return nil
 						}
 					}
 					log.Debug().Msgf("start upload %d files to %s", len(partFiles), remotePath)
-					if uploadPathBytes, err := b.dst.UploadPath(ctx, backupPath, partFiles, remotePath, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.UploadMaxBytesPerSecond); err != nil {
+					if uploadPathBytes, partChecksums, err := b.dst.UploadPath(ctx, backupPath, partFiles, remotePath, &b.cfg.General, b.cfg.General.UploadMaxBytesPerSecond); err != nil {
 						log.Error().Msgf("UploadPath return error: %v", err)
 						return fmt.Errorf("can't upload: %v", err)
 					} else {
 						atomic.AddInt64(&uploadedBytes, uploadPathBytes)
+						// checksums of directory format files are stored with `disk/part/file` key
+						uploadedChecksumsMutex.Lock()
+						for f, checksum := range partChecksums {
+							uploadedChecksums[path.Join(disk, f)] = checksum
+						}
+						uploadedChecksumsMutex.Unlock()
 						if b.resume {
+							for f, checksum := range partChecksums {
+								b.resumableState.AppendChecksumToState(path.Join(remotePath, f), checksum)
+							}
 							b.resumableState.AppendToState(remotePathFull, uploadPathBytes)
 						}
 					}
@@ -549,23 +790,34 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 					if b.resume {
 						if isProcessed, processedSize := b.resumableState.IsAlreadyProcessed(remoteDataFile); isProcessed {
 							atomic.AddInt64(&uploadedBytes, processedSize)
+							if checksum := b.resumableState.GetChecksumFromState(remoteDataFile); checksum != "" {
+								uploadedChecksumsMutex.Lock()
+								uploadedChecksums[fileName] = checksum
+								uploadedChecksumsMutex.Unlock()
+							}
 							return nil
 						}
 					}
 					log.Debug().Msgf("start upload %d files to %s", len(localFiles), remoteDataFile)
-					retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+					retry := storage.NewRetrier(&b.cfg.General)
+					var checksum string
 					err := retry.RunCtx(ctx, func(ctx context.Context) error {
-						return b.dst.UploadCompressedStream(ctx, backupPath, localFiles, remoteDataFile, b.cfg.General.UploadMaxBytesPerSecond)
+						var uploadErr error
+						checksum, uploadErr = b.dst.UploadCompressedStream(ctx, backupPath, localFiles, remoteDataFile, b.cfg.General.UploadMaxBytesPerSecond)
+						return uploadErr
 					})
 					if err != nil {
 						log.Error().Msgf("UploadCompressedStream return error: %v", err)
 						return fmt.Errorf("can't upload: %v", err)
 					}
+					uploadedChecksumsMutex.Lock()
+					uploadedChecksums[fileName] = checksum
+					uploadedChecksumsMutex.Unlock()
 
 					var remoteFile storage.RemoteFile
-					retry = retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+					retry = storage.NewUploadedStatRetrier(&b.cfg.General)
 					err = retry.RunCtx(ctx, func(ctx context.Context) error {
-						remoteFile, err = b.dst.StatFile(ctx, remoteDataFile)
+						remoteFile, err = b.dst.RemoteStorage.StatFile(ctx, remoteDataFile)
 						return err
 					})
 					if err != nil {
@@ -573,6 +825,7 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 					}
 					atomic.AddInt64(&uploadedBytes, remoteFile.Size())
 					if b.resume {
+						b.resumableState.AppendChecksumToState(remoteDataFile, checksum)
 						b.resumableState.AppendToState(remoteDataFile, remoteFile.Size())
 					}
 					// https://github.com/Altinity/clickhouse-backup/issues/777
@@ -590,10 +843,13 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 		}
 	}
 	if err := dataGroup.Wait(); err != nil {
-		return nil, 0, fmt.Errorf("one of uploadTableData go-routine return error: %v", err)
+		return nil, nil, 0, fmt.Errorf("one of uploadTableData go-routine return error: %v", err)
+	}
+	log.Debug().Msgf("finish %s.%s with concurrency=%d len(table.Parts[...])=%d uploadedFiles=%v, uploadedBytes=%v", table.Database, table.Table, b.cfg.GetUploadObjectsConcurrency(), capacity, uploadedFiles, uploadedBytes)
+	if len(uploadedChecksums) == 0 {
+		uploadedChecksums = nil
 	}
-	log.Debug().Msgf("finish %s.%s with concurrency=%d len(table.Parts[...])=%d uploadedFiles=%v, uploadedBytes=%v", table.Database, table.Table, b.cfg.General.UploadConcurrency, capacity, uploadedFiles, uploadedBytes)
-	return uploadedFiles, uploadedBytes, nil
+	return uploadedFiles, uploadedChecksums, uploadedBytes, nil
 }
 
 func (b *Backuper) uploadTableMetadata(ctx context.Context, backupName string, requiredBackupName string, tableMetadata metadata.TableMetadata) (int64, error) {
@@ -619,7 +875,7 @@ func (b *Backuper) uploadTableMetadataRegular(ctx context.Context, backupName st
 			return processedSize, nil
 		}
 	}
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
 		return b.dst.PutFile(ctx, remoteTableMetaFile, io.NopCloser(bytes.NewReader(content)))
 	})
@@ -664,7 +920,7 @@ func (b *Backuper) uploadTableMetadataEmbedded(ctx context.Context, backupName s
 			log.Warn().Msgf("can't close %v: %v", localReader, err)
 		}
 	}()
-	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&b.cfg.General)
 	err = retry.RunCtx(ctx, func(ctx context.Context) error {
 		return b.dst.PutFile(ctx, remoteTableMetaFile, localReader)
 	})
@@ -786,6 +1042,9 @@ func (b *Backuper) splitFilesByName(basePath string, parts []metadata.Part) ([]m
 	return result, nil
 }
 
+// tarFileOverhead - tar header and padding to 512 bytes block for each archived file, allow keep archive size under max_file_size
+const tarFileOverhead = 1024
+
 func (b *Backuper) splitFilesBySize(basePath string, parts []metadata.Part) ([]metadata.SplitPartFiles, error) {
 	var size int64
 	var files []string
@@ -804,7 +1063,12 @@ func (b *Backuper) splitFilesBySize(basePath string, parts []metadata.Part) ([]m
 			if !info.Mode().IsRegular() {
 				return nil
 			}
-			if (size+info.Size()) > maxSize && len(files) > 0 {
+			fileSize := info.Size() + tarFileOverhead
+			// one file can't be split into different archives, so limit is raised for archive which contains only this file, like CalculateMaxSize does for upload_by_part: true
+			if maxSize > 0 && fileSize > maxSize {
+				log.Warn().Msgf("%s size %d is more than max_file_size=%d, one file can't be split into different archives, archive will be bigger than max_file_size", filePath, info.Size(), maxSize)
+			}
+			if (size+fileSize) > maxSize && len(files) > 0 {
 				result = append(result, metadata.SplitPartFiles{
 					Prefix: strconv.Itoa(partSuffix),
 					Files:  files,
@@ -815,7 +1079,7 @@ func (b *Backuper) splitFilesBySize(basePath string, parts []metadata.Part) ([]m
 			}
 			relativePath := strings.TrimPrefix(filePath, basePath)
 			files = append(files, relativePath)
-			size += info.Size()
+			size += fileSize
 			return nil
 		})
 		if err != nil {
@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+func TestGetLatestRemoteBackupForDiff(t *testing.T) {
+	now := time.Now()
+	remoteBackups := []storage.Backup{
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "full"}, UploadDate: now.Add(-3 * time.Hour)},
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "increment1", RequiredBackup: "full"}, UploadDate: now.Add(-2 * time.Hour)},
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "broken"}, UploadDate: now.Add(-1 * time.Hour), Broken: "broken (can't stat metadata.json)"},
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "embedded", Tags: "embedded"}, UploadDate: now.Add(-1 * time.Hour)},
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "increment2", RequiredBackup: "increment1"}, UploadDate: now},
+	}
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 0, 24*time.Hour, now); latest != "increment2" {
+		t.Fatalf("expected increment2, got %s", latest)
+	}
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment2", 0, 24*time.Hour, now); latest != "increment1" {
+		t.Fatalf("current backup shall be skipped, expected increment1, got %s", latest)
+	}
+	if latest := getLatestRemoteBackupForDiff(nil, "increment1", 0, 24*time.Hour, now); latest != "" {
+		t.Fatalf("expected empty latest backup, got %s", latest)
+	}
+	// increment2 already has 2 increments in chain
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 2, 24*time.Hour, now); latest != "" {
+		t.Fatalf("max chain length reached, expected full backup, got %s", latest)
+	}
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 3, 24*time.Hour, now); latest != "increment2" {
+		t.Fatalf("max chain length is not reached, expected increment2, got %s", latest)
+	}
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 0, 3*time.Hour, now); latest != "" {
+		t.Fatalf("full backup is older than full_interval, expected full backup, got %s", latest)
+	}
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 0, 0, now.Add(24*365*time.Hour)); latest != "increment2" {
+		t.Fatalf("zero full_interval shall not limit chain, expected increment2, got %s", latest)
+	}
+	remoteBackups[1].RequiredBackup = "deleted"
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 0, 24*time.Hour, now); latest != "" {
+		t.Fatalf("required backup is missing, expected full backup, got %s", latest)
+	}
+	remoteBackups[1].RequiredBackup = "increment2"
+	if latest := getLatestRemoteBackupForDiff(remoteBackups, "increment3", 0, 24*time.Hour, now); latest != "" {
+		t.Fatalf("cyclic required backups, expected full backup, got %s", latest)
+	}
+}
+
+// TestUploadDiffFromLatestRemoteRetention - daily backups with upload_diff_from_latest_remote, retention shall delete old chains only when full backup is uploaded periodically
+func TestUploadDiffFromLatestRemoteRetention(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	uploadBackups := func(maxChainLength int, fullInterval time.Duration) []storage.Backup {
+		remoteBackups := make([]storage.Backup, 0)
+		for day := 0; day < 30; day++ {
+			now := start.Add(time.Duration(day) * 24 * time.Hour)
+			backupName := now.Format("2006-01-02")
+			requiredBackup := getLatestRemoteBackupForDiff(remoteBackups, backupName, maxChainLength, fullInterval, now)
+			remoteBackups = append(remoteBackups, storage.Backup{BackupMetadata: metadata.BackupMetadata{BackupName: backupName, RequiredBackup: requiredBackup}, UploadDate: now})
+		}
+		return remoteBackups
+	}
+	if toDelete := storage.GetBackupsToDeleteRemote(uploadBackups(0, 0), 7); len(toDelete) != 0 {
+		t.Fatalf("endless chain, all backups are required by the latest, got %d backups to delete", len(toDelete))
+	}
+	for _, remoteBackups := range [][]storage.Backup{uploadBackups(6, 0), uploadBackups(0, 7*24*time.Hour)} {
+		toDelete := storage.GetBackupsToDeleteRemote(remoteBackups, 7)
+		// 7 backups are kept, the oldest of them requires previous chain up to full backup
+		if len(toDelete) == 0 || len(toDelete) > 30-7 {
+			t.Fatalf("expected some old chains deleted, got %d backups to delete", len(toDelete))
+		}
+		kept := make(map[string]bool)
+		for _, backup := range remoteBackups {
+			kept[backup.BackupName] = true
+		}
+		for _, backup := range toDelete {
+			delete(kept, backup.BackupName)
+		}
+		for _, backup := range remoteBackups {
+			if kept[backup.BackupName] && backup.RequiredBackup != "" && !kept[backup.RequiredBackup] {
+				t.Fatalf("%s is kept, but required %s is deleted", backup.BackupName, backup.RequiredBackup)
+			}
+		}
+	}
+}
+
+func TestSplitFilesBySize(t *testing.T) {
+	basePath := t.TempDir()
+	for _, part := range []string{"all_1_1_0", "all_2_2_0"} {
+		if err := os.MkdirAll(path.Join(basePath, part), 0750); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"data.bin", "data.mrk3"} {
+			if err := os.WriteFile(path.Join(basePath, part, file), make([]byte, 3000), 0640); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	parts := []metadata.Part{{Name: "all_1_1_0"}, {Name: "all_2_2_0"}, {Name: "all_3_3_0", Required: true}}
+	b := &Backuper{cfg: &config.Config{General: config.GeneralConfig{MaxFileSize: 10000}}}
+	splitParts, err := b.splitFilesBySize(basePath, parts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(splitParts) != 2 || len(splitParts[0].Files) != 2 || len(splitParts[1].Files) != 2 {
+		t.Fatalf("expected two archives with two files each, got %+v", splitParts)
+	}
+	for _, splitPart := range splitParts {
+		if int64(len(splitPart.Files))*(3000+tarFileOverhead) > b.cfg.General.MaxFileSize {
+			t.Fatalf("archive %s exceeds max_file_size: %+v", splitPart.Prefix, splitPart.Files)
+		}
+	}
+
+	// file bigger than max_file_size is placed into separate archive instead of error
+	b.cfg.General.MaxFileSize = 2000
+	if splitParts, err = b.splitFilesBySize(basePath, parts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(splitParts) != 4 {
+		t.Fatalf("expected four archives with one file each, got %+v", splitParts)
+	}
+	for _, splitPart := range splitParts {
+		if len(splitPart.Files) != 1 {
+			t.Fatalf("archive %s shall contain only one file bigger than max_file_size: %+v", splitPart.Prefix, splitPart.Files)
+		}
+	}
+}
@@ -2,14 +2,41 @@ package backup
 
 import (
 	"sort"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
 )
 
 func GetBackupsToDeleteLocal(backups []LocalBackup, keep int) []LocalBackup {
-	if len(backups) > keep {
-		sort.SliceStable(backups, func(i, j int) bool {
-			return backups[i].CreationDate.After(backups[j].CreationDate)
-		})
-		return backups[keep:]
+	return GetBackupsToDeleteLocalByPolicy(backups, config.RetentionPolicy{KeepLast: keep}, time.Now(), nil)
+}
+
+// GetBackupsToDeleteLocalByPolicy - the same as GetBackupsToDeleteLocal, but also respects `backups_to_keep_days_local` and `backups_to_keep_gfs_local`
+func GetBackupsToDeleteLocalByPolicy(backups []LocalBackup, policy config.RetentionPolicy, now time.Time, location *time.Location) []LocalBackup {
+	// pinned backups and backups with `retention_ignore_tags` don't occupy retention slots, and never deleted
+	candidates := make([]LocalBackup, 0, len(backups))
+	for _, backup := range backups {
+		if !backup.Pinned && !backup.MatchAnyUserTag(policy.IgnoreTags) {
+			candidates = append(candidates, backup)
+		}
+	}
+	if !policy.IsCalendarBased() && len(candidates) <= policy.KeepLast {
+		return []LocalBackup{}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].CreationDate.After(candidates[j].CreationDate)
+	})
+	creationDates := make([]time.Time, len(candidates))
+	for i := range candidates {
+		creationDates[i] = candidates[i].CreationDate
+	}
+	isKept := storage.SelectBackupsToKeep(creationDates, policy, now, location)
+	backupsToDelete := make([]LocalBackup, 0, len(candidates))
+	for i := range candidates {
+		if !isKept[i] {
+			backupsToDelete = append(backupsToDelete, candidates[i])
+		}
 	}
-	return []LocalBackup{}
+	return backupsToDelete
 }
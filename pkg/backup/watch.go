@@ -15,6 +15,9 @@ import (
 
 var watchBackupTemplateTimeRE = regexp.MustCompile(`{time:([^}]+)}`)
 
+// watchSharedLockRetryInterval - how long watch waits when shared lock, like `general->lock_file`, is held by another instance
+const watchSharedLockRetryInterval = time.Minute
+
 func (b *Backuper) NewBackupWatchName(ctx context.Context, backupType string) (string, error) {
 	backupName, err := b.ch.ApplyMacros(ctx, b.cfg.General.WatchBackupNameTemplate)
 	if err != nil {
@@ -87,6 +90,12 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 	if err != nil {
 		return err
 	}
+	lastFullBackupName := ""
+	if b.cfg.General.WatchIncrementFromFull {
+		if lastFullBackupName, err = b.latestWatchBackupRemote(ctx, "full"); err != nil {
+			return err
+		}
+	}
 
 	createRemoteErrCount := 0
 	deleteLocalErrCount := 0
@@ -119,6 +128,20 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 			diffFromRemote := ""
 			if backupType == "increment" {
 				diffFromRemote = prevBackupName
+				// each increment depends only on last full backup, instead of chain of increments
+				if b.cfg.General.WatchIncrementFromFull && lastFullBackupName != "" {
+					diffFromRemote = lastFullBackupName
+				}
+			}
+			// shared locks are held only during create_remote and delete local, other instances can run commands between watch iterations
+			if lockErr := status.Current.AcquireSharedLocks("watch"); lockErr != nil {
+				log.Warn().Msgf("watch skip %s: %v, retry after %s", backupName, lockErr, watchSharedLockRetryInterval)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(watchSharedLockRetryInterval):
+				}
+				continue
 			}
 			if metrics != nil {
 				createRemoteErr, createRemoteErrCount = metrics.ExecuteWithMetrics("create_remote", createRemoteErrCount, func() error {
@@ -171,6 +194,7 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 				}
 
 			}
+			status.Current.ReleaseSharedLocks()
 
 			if createRemoteErrCount > b.cfg.General.BackupsToKeepRemote || deleteLocalErrCount > b.cfg.General.BackupsToKeepLocal {
 				return fmt.Errorf("too many errors create_remote: %d, delete local: %d, during watch full_interval: %s, abort watching", createRemoteErrCount, deleteLocalErrCount, b.cfg.General.FullInterval)
@@ -181,6 +205,9 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 			if createRemoteErr == nil {
 				prevBackupName = backupName
 				prevBackupType = backupType
+				if backupType == "full" {
+					lastFullBackupName = backupName
+				}
 				if prevBackupType == "full" {
 					backupType = "increment"
 				}
@@ -206,6 +233,67 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 	}
 }
 
+// CreateRemoteByType - run create_remote + delete local like one watch iteration, backupType is `full` or `increment`, increment based on latest remote backup which matched watch_backup_name_template, used by server scheduler
+func (b *Backuper) CreateRemoteByType(backupType, tablePattern, version string, commandId int) (string, error) {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	if err = b.ch.Connect(); err != nil {
+		return "", fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	diffFromRemote := ""
+	if backupType == "increment" {
+		latestBackupType := ""
+		if b.cfg.General.WatchIncrementFromFull {
+			latestBackupType = "full"
+		}
+		if diffFromRemote, err = b.latestWatchBackupRemote(ctx, latestBackupType); err != nil {
+			return "", err
+		}
+		if diffFromRemote == "" {
+			log.Warn().Msg("remote backup which matched watch_backup_name_template not found, will create full backup instead of increment")
+			backupType = "full"
+		}
+	}
+	backupName, err := b.NewBackupWatchName(ctx, backupType)
+	if err != nil {
+		return "", err
+	}
+	createRemoteErr := b.CreateToRemote(backupName, false, "", diffFromRemote, tablePattern, nil, false, false, false, false, false, false, false, version, commandId)
+	if deleteLocalErr := b.RemoveBackupLocal(ctx, backupName, nil); deleteLocalErr != nil {
+		log.Error().Str("backup", backupName).Msgf("delete local %s return error: %v", backupName, deleteLocalErr)
+	}
+	return backupName, createRemoteErr
+}
+
+// latestWatchBackupRemote - return name of latest not broken remote backup which matched watch_backup_name_template, backupType `full` skip backups with required_backup, empty means any
+func (b *Backuper) latestWatchBackupRemote(ctx context.Context, backupType string) (string, error) {
+	remoteBackups, err := b.GetRemoteBackups(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	backupTemplateName, err := b.ch.ApplyMacros(ctx, b.cfg.General.WatchBackupNameTemplate)
+	if err != nil {
+		return "", err
+	}
+	backupTemplateNameRE := regexp.MustCompile(regexp.MustCompile(`{type}|{time:([^}]+)}`).ReplaceAllString(backupTemplateName, `\S+`))
+	latestBackupName := ""
+	for _, remoteBackup := range remoteBackups {
+		if remoteBackup.Broken == "" && backupTemplateNameRE.MatchString(remoteBackup.BackupName) {
+			// backup name template could contain `increment` in other place than {type}, so check metadata instead of name
+			if backupType == "full" && remoteBackup.RequiredBackup != "" {
+				continue
+			}
+			latestBackupName = remoteBackup.BackupName
+		}
+	}
+	return latestBackupName, nil
+}
+
 // calculatePrevBackupNameAndType - https://github.com/Altinity/clickhouse-backup/pull/804
 func (b *Backuper) calculatePrevBackupNameAndType(ctx context.Context, prevBackupName string, prevBackupType string, lastBackup time.Time, lastFullBackup time.Time, backupType string) (string, string, time.Time, time.Time, string, error) {
 	remoteBackups, err := b.GetRemoteBackups(ctx, true)
@@ -222,7 +310,7 @@ func (b *Backuper) calculatePrevBackupNameAndType(ctx context.Context, prevBacku
 	for _, remoteBackup := range remoteBackups {
 		if remoteBackup.Broken == "" && backupTemplateNameRE.MatchString(remoteBackup.BackupName) {
 			prevBackupName = remoteBackup.BackupName
-			if strings.Contains(remoteBackup.BackupName, "increment") {
+			if remoteBackup.RequiredBackup != "" {
 				prevBackupType = "increment"
 				lastBackup = remoteBackup.CreationDate
 			} else {
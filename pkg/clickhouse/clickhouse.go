@@ -10,7 +10,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -211,7 +213,11 @@ func (ch *ClickHouse) GetEmbeddedBackupPath(disks []Disk) (string, error) {
 	return "", fmt.Errorf("%s not found in system.disks %v", ch.Config.EmbeddedBackupDisk, disks)
 }
 
+// GetDefaultPath - path of `default` disk, or `embedded_metadata_local_path` for remote-only mode, when BACKUP / RESTORE writes data directly to remote storage and only metadata is stored locally
 func (ch *ClickHouse) GetDefaultPath(disks []Disk) (string, error) {
+	if ch.Config.UseEmbeddedBackupRestore && ch.Config.EmbeddedBackupDisk == "" && ch.Config.EmbeddedMetadataLocalPath != "" {
+		return ch.Config.EmbeddedMetadataLocalPath, nil
+	}
 	defaultPath := "/var/lib/clickhouse"
 	for _, d := range disks {
 		if d.Name == "default" {
@@ -312,6 +318,14 @@ func (ch *ClickHouse) getDisksFromSystemDisks(ctx context.Context) ([]Disk, erro
 			diskTypeSQL, diskFreeSpaceSQL, storagePoliciesSQL, joinStoragePoliciesSQL,
 		)
 		err := ch.SelectContext(ctx, &result, query)
+		// system.disks doesn't contain free_space in old versions, disk free space is required to check general->free_space_reserve
+		if err == nil && (len(diskFields) == 0 || diskFields[0].FreeSpacePresent == 0) {
+			for i := range result {
+				if result[i].Type == "local" && result[i].Path != "" {
+					result[i].FreeSpace = du.NewDiskUsage(result[i].Path).Free()
+				}
+			}
+		}
 		return result, err
 	}
 }
@@ -394,6 +408,12 @@ func (ch *ClickHouse) GetTables(ctx context.Context, tablePattern string) ([]Tab
 				break
 			}
 		}
+		for _, engine := range ch.Config.SkipTableEnginesSchemaOnly {
+			if strings.EqualFold(t.Engine, engine) {
+				t.SkipData = true
+				break
+			}
+		}
 		if t.Skip {
 			tables[i] = t
 			continue
@@ -423,6 +443,9 @@ func (ch *ClickHouse) enrichTablesByInnerDependencies(ctx context.Context, table
 	for _, t := range tables {
 		if !t.Skip && (strings.HasPrefix(t.CreateTableQuery, "ATTACH MATERIALIZED") || strings.HasPrefix(t.CreateTableQuery, "CREATE MATERIALIZED")) {
 			if strings.Contains(t.CreateTableQuery, " TO ") && !strings.Contains(t.CreateTableQuery, " TO INNER UUID") {
+				if targetDatabase, targetTable, ok := ExtractMaterializedViewTarget(t.CreateTableQuery); ok && !ch.isTableSkippedOrPresent(tables, targetDatabase, targetTable) {
+					innerTablesMissed = append(innerTablesMissed, fmt.Sprintf("%s.%s", targetDatabase, targetTable))
+				}
 				continue
 			}
 			found := false
@@ -463,6 +486,35 @@ func (ch *ClickHouse) enrichTablesByInnerDependencies(ctx context.Context, table
 	return append(missedTables, tables...), nil
 }
 
+var materializedViewTargetRE = regexp.MustCompile("^(?:CREATE|ATTACH) MATERIALIZED VIEW .+? TO (?:`([^`]+)`|([^\\s`.]+))\\.(?:`([^`]+)`|([^\\s`.(]+))")
+
+// ExtractMaterializedViewTarget - return database and table from MATERIALIZED VIEW ... TO db.table
+func ExtractMaterializedViewTarget(query string) (string, string, bool) {
+	if strings.Contains(query, " TO INNER UUID") {
+		return "", "", false
+	}
+	matches := materializedViewTargetRE.FindStringSubmatch(query)
+	if len(matches) == 0 {
+		return "", "", false
+	}
+	return matches[1] + matches[2], matches[3] + matches[4], true
+}
+
+// isTableSkippedOrPresent - materialized view target table shall not be added twice and shall respect skip_tables
+func (ch *ClickHouse) isTableSkippedOrPresent(tables []Table, database, table string) bool {
+	for _, t := range tables {
+		if t.Database == database && t.Name == table {
+			return true
+		}
+	}
+	for _, filter := range ch.Config.SkipTables {
+		if matched, _ := filepath.Match(strings.Trim(filter, " \t\r\n"), fmt.Sprintf("%s.%s", database, table)); matched {
+			return true
+		}
+	}
+	return false
+}
+
 func (ch *ClickHouse) prepareGetTablesSQL(tablePattern string, skipDatabases, skipTableEngines []string, settings map[string]bool, isSystemTablesFieldPresent []IsSystemTablesFieldPresent) string {
 	allTablesSQL := "SELECT database, name, engine "
 	if len(isSystemTablesFieldPresent) > 0 && isSystemTablesFieldPresent[0].IsDataPathPresent > 0 {
@@ -623,6 +675,87 @@ func (ch *ClickHouse) getTableSizeFromParts(ctx context.Context, table Table) ui
 	return 0
 }
 
+// GetActivePartsCount - count active data parts in tables which will freeze during backup, only partitions from partitionsIdMap when not empty for table
+func (ch *ClickHouse) GetActivePartsCount(ctx context.Context, tables []Table, partitionsIdMap map[metadata.TableTitle]common.EmptyMap) (uint64, error) {
+	query, args := activePartsCountQuery(tables, partitionsIdMap)
+	if query == "" {
+		return 0, nil
+	}
+	var partsCount []struct {
+		Count uint64 `ch:"parts_count"`
+	}
+	if err := ch.SelectContext(ctx, &partsCount, query, args...); err != nil {
+		return 0, err
+	}
+	if len(partsCount) == 0 {
+		return 0, nil
+	}
+	return partsCount[0].Count, nil
+}
+
+// activePartsCountQuery - names and partition ids passed as query arguments, driver escapes quotes and backslashes
+func activePartsCountQuery(tables []Table, partitionsIdMap map[metadata.TableTitle]common.EmptyMap) (string, []interface{}) {
+	tablesCondition := make([]string, 0, len(tables))
+	args := make([]interface{}, 0, len(tables)*2)
+	for _, t := range tables {
+		if t.Skip {
+			continue
+		}
+		args = append(args, t.Database, t.Name)
+		partitionIds := partitionsIdMap[metadata.TableTitle{Database: t.Database, Table: t.Name}]
+		if len(partitionIds) == 0 {
+			tablesCondition = append(tablesCondition, "(database=? AND table=?)")
+			continue
+		}
+		ids := make([]string, 0, len(partitionIds))
+		for id := range partitionIds {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		args = append(args, ids)
+		tablesCondition = append(tablesCondition, "(database=? AND table=? AND has(?, partition_id))")
+	}
+	if len(tablesCondition) == 0 {
+		return "", nil
+	}
+	return "SELECT count() AS parts_count FROM system.parts WHERE active AND (" + strings.Join(tablesCondition, " OR ") + ")", args
+}
+
+// PartsStats - summary of active data parts for user tables, used for config tuning recommendations
+type PartsStats struct {
+	TablesCount  uint64 `ch:"tables_count"`
+	PartsCount   uint64 `ch:"parts_count"`
+	TotalBytes   uint64 `ch:"total_bytes"`
+	MaxPartBytes uint64 `ch:"max_part_bytes"`
+}
+
+func (ch *ClickHouse) GetPartsStats(ctx context.Context) (PartsStats, error) {
+	var stats []PartsStats
+	query := "SELECT uniqExact(database, table) AS tables_count, count() AS parts_count, sum(bytes_on_disk) AS total_bytes, max(bytes_on_disk) AS max_part_bytes " +
+		"FROM system.parts WHERE active AND lower(database) NOT IN ('system','information_schema','_temporary_and_external_tables')"
+	if err := ch.SelectContext(ctx, &stats, query); err != nil {
+		return PartsStats{}, err
+	}
+	if len(stats) == 0 {
+		return PartsStats{}, nil
+	}
+	return stats[0], nil
+}
+
+// GetOSMemoryTotal - clickhouse-backup usually runs on the same host as clickhouse-server, return 0 when metric is not available
+func (ch *ClickHouse) GetOSMemoryTotal(ctx context.Context) (uint64, error) {
+	var memory []struct {
+		Value float64 `ch:"value"`
+	}
+	if err := ch.SelectContext(ctx, &memory, "SELECT value FROM system.asynchronous_metrics WHERE metric='OSMemoryTotal'"); err != nil {
+		return 0, err
+	}
+	if len(memory) == 0 {
+		return 0, nil
+	}
+	return uint64(memory[0].Value), nil
+}
+
 func (ch *ClickHouse) fixVariousVersions(ctx context.Context, t Table, metadataPath string) Table {
 	// versions before 19.15 contain data_path in a different column
 	if t.DataPath != "" {
@@ -691,20 +824,72 @@ func (ch *ClickHouse) FreezeTableByParts(ctx context.Context, table *Table, name
 	if err := ch.SelectContext(ctx, &partitions, q); err != nil {
 		return fmt.Errorf("can't get partitions for '%s.%s': %w", table.Database, table.Name, err)
 	}
+	partitionIds := make([]string, len(partitions))
+	for i, item := range partitions {
+		partitionIds[i] = item.PartitionID
+	}
+	return ch.FreezeTablePartitions(ctx, table, name, partitionIds)
+}
+
+// GetMaxModificationTime - max(modification_time) of active parts, the latest data change which could be included in backup
+func (ch *ClickHouse) GetMaxModificationTime(ctx context.Context, table *Table) (time.Time, error) {
+	var result []struct {
+		MaxModificationTime time.Time `ch:"max_modification_time"`
+	}
+	q := "SELECT max(modification_time) AS max_modification_time FROM `system`.`parts` WHERE active AND database=? AND table=?"
+	if err := ch.SelectContext(ctx, &result, q, table.Database, table.Name); err != nil {
+		return time.Time{}, fmt.Errorf("can't get max modification_time for '%s.%s': %w", table.Database, table.Name, err)
+	}
+	if len(result) == 0 {
+		return time.Time{}, nil
+	}
+	return result[0].MaxModificationTime.UTC(), nil
+}
+
+// GetReplicasHealth - replication state for all Replicated*MergeTree tables, used to avoid backup of stale replica
+func (ch *ClickHouse) GetReplicasHealth(ctx context.Context) ([]ReplicaHealth, error) {
+	replicas := make([]ReplicaHealth, 0)
+	q := "SELECT r.database AS database, r.table AS table, r.is_readonly AS is_readonly, r.is_session_expired AS is_session_expired, r.absolute_delay AS absolute_delay, r.queue_size AS queue_size, countIf(q.last_exception!='') AS queue_errors " +
+		"FROM `system`.`replicas` r LEFT JOIN `system`.`replication_queue` q ON q.database=r.database AND q.table=r.table " +
+		"GROUP BY database, table, is_readonly, is_session_expired, absolute_delay, queue_size ORDER BY database, table"
+	if err := ch.SelectContext(ctx, &replicas, q); err != nil {
+		return nil, fmt.Errorf("can't get replicas health: %w", err)
+	}
+	return replicas, nil
+}
+
+// GetActivePartitionIds - distinct partition_id of active parts, used to FREEZE PARTITION only requested partitions
+func (ch *ClickHouse) GetActivePartitionIds(ctx context.Context, table *Table) ([]string, error) {
+	var partitions []struct {
+		PartitionID string `ch:"partition_id"`
+	}
+	q := "SELECT DISTINCT partition_id FROM `system`.`parts` WHERE active AND database=? AND table=? ORDER BY partition_id"
+	if err := ch.SelectContext(ctx, &partitions, q, table.Database, table.Name); err != nil {
+		return nil, fmt.Errorf("can't get partitions for '%s.%s': %w", table.Database, table.Name, err)
+	}
+	partitionIds := make([]string, len(partitions))
+	for i, item := range partitions {
+		partitionIds[i] = item.PartitionID
+	}
+	return partitionIds, nil
+}
+
+// FreezeTablePartitions - execute FREEZE PARTITION ID for each partition, creates hard links only for parts from these partitions
+func (ch *ClickHouse) FreezeTablePartitions(ctx context.Context, table *Table, name string, partitionIds []string) error {
 	withNameQuery := ""
 	if name != "" {
 		withNameQuery = fmt.Sprintf("WITH NAME '%s'", name)
 	}
-	for _, item := range partitions {
-		log.Debug().Msgf("  partition '%v'", item.PartitionID)
+	for _, partitionId := range partitionIds {
+		log.Debug().Msgf("  partition '%v'", partitionId)
 		query := fmt.Sprintf(
 			"ALTER TABLE `%v`.`%v` FREEZE PARTITION ID '%v' %s;",
 			table.Database,
 			table.Name,
-			item.PartitionID,
+			partitionId,
 			withNameQuery,
 		)
-		if item.PartitionID == "all" {
+		if partitionId == "all" {
 			query = fmt.Sprintf(
 				"ALTER TABLE `%v`.`%v` FREEZE PARTITION tuple() %s;",
 				table.Database,
@@ -716,16 +901,16 @@ func (ch *ClickHouse) FreezeTableByParts(ctx context.Context, table *Table, name
 			if (strings.Contains(err.Error(), "code: 60") || strings.Contains(err.Error(), "code: 81")) && ch.Config.IgnoreNotExistsErrorDuringFreeze {
 				log.Warn().Msgf("can't freeze partition: %v", err)
 			} else {
-				return fmt.Errorf("can't freeze partition '%s': %w", item.PartitionID, err)
+				return fmt.Errorf("can't freeze partition '%s': %w", partitionId, err)
 			}
 		}
 	}
 	return nil
 }
 
-// FreezeTable - freeze all partitions for table
+// FreezeTable - freeze all partitions for table, or only partitionIds when not empty
 // This way available for ClickHouse since v19.1
-func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string) error {
+func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string, partitionIds []string) error {
 	version, err := ch.GetVersion(ctx)
 	if err != nil {
 		return err
@@ -738,6 +923,9 @@ func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string
 			log.Debug().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Msg("replica synced")
 		}
 	}
+	if len(partitionIds) > 0 {
+		return ch.FreezeTablePartitions(ctx, table, name, partitionIds)
+	}
 	if version < 19001005 || ch.Config.FreezeByPart {
 		return ch.FreezeTableByParts(ctx, table, name)
 	}
@@ -1071,6 +1259,39 @@ func (ch *ClickHouse) SelectSingleRowNoCtx(dest interface{}, query string, args
 	return err
 }
 
+// SelectSingleValue - return first column of first row as string for query with unknown columns, isEmpty=true when query returns no rows
+func (ch *ClickHouse) SelectSingleValue(ctx context.Context, query string) (value string, isEmpty bool, err error) {
+	rows, err := ch.conn.Query(ctx, ch.LogQuery(query))
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	if !rows.Next() {
+		return "", true, rows.Err()
+	}
+	columnTypes := rows.ColumnTypes()
+	values := make([]interface{}, len(columnTypes))
+	for i := range columnTypes {
+		values[i] = reflect.New(columnTypes[i].ScanType()).Interface()
+	}
+	if err = rows.Scan(values...); err != nil {
+		return "", false, err
+	}
+	firstValue := reflect.ValueOf(values[0]).Elem()
+	// Nullable columns scan into pointers
+	for firstValue.Kind() == reflect.Ptr {
+		if firstValue.IsNil() {
+			return "", true, nil
+		}
+		firstValue = firstValue.Elem()
+	}
+	return fmt.Sprintf("%v", firstValue.Interface()), false, nil
+}
+
 func (ch *ClickHouse) LogQuery(query string, args ...interface{}) string {
 	level := zerolog.InfoLevel
 	if !ch.Config.LogSQLQueries {
@@ -1324,6 +1545,37 @@ func (ch *ClickHouse) GetSettingsValues(ctx context.Context, settings []interfac
 	return settingsValuesMap, nil
 }
 
+// GetChangedSettings - settings with non-default values for current user, stored in backup metadata to compare with server during restore
+func (ch *ClickHouse) GetChangedSettings(ctx context.Context) (map[string]string, error) {
+	settingsValues := make([]struct {
+		Name  string `ch:"name"`
+		Value string `ch:"value"`
+	}, 0)
+	if err := ch.SelectContext(ctx, &settingsValues, "SELECT name, value FROM `system`.`settings` WHERE changed ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("can't get changed settings: %w", err)
+	}
+	settingsValuesMap := map[string]string{}
+	for _, v := range settingsValues {
+		settingsValuesMap[v.Name] = v.Value
+	}
+	return settingsValuesMap, nil
+}
+
+// GetTableEngines - names of table engines supported by server
+func (ch *ClickHouse) GetTableEngines(ctx context.Context) (map[string]struct{}, error) {
+	engines := make([]struct {
+		Name string `ch:"name"`
+	}, 0)
+	if err := ch.SelectContext(ctx, &engines, "SELECT name FROM `system`.`table_engines`"); err != nil {
+		return nil, fmt.Errorf("can't get table engines: %w", err)
+	}
+	enginesMap := make(map[string]struct{}, len(engines))
+	for _, engine := range engines {
+		enginesMap[engine.Name] = struct{}{}
+	}
+	return enginesMap, nil
+}
+
 func (ch *ClickHouse) CheckSettingsExists(ctx context.Context, settings map[string]bool) (map[string]bool, error) {
 	isSettingsPresent := make([]struct {
 		Name      string `ch:"name"`
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -118,3 +121,58 @@ func TestExtractStoragePolicy(t *testing.T) {
 		assert.Equal(t, policy, ch.ExtractStoragePolicy(query))
 	}
 }
+
+func TestExtractMaterializedViewTarget(t *testing.T) {
+	testCases := []struct {
+		query            string
+		expectedDatabase string
+		expectedTable    string
+		expectedOk       bool
+	}{
+		{"CREATE MATERIALIZED VIEW db1.mv1 TO db2.target (`id` UInt64) AS SELECT id FROM db1.src", "db2", "target", true},
+		{"ATTACH MATERIALIZED VIEW `db1`.`mv1` UUID 'c9a3ec45-8a0c-4cb2-9d43-9ca4a0e5d123' TO `db 2`.`target.t1` (`id` UInt64) AS SELECT id FROM db1.src", "db 2", "target.t1", true},
+		{"ATTACH MATERIALIZED VIEW db1.mv1 UUID 'c9a3ec45-8a0c-4cb2-9d43-9ca4a0e5d123' TO INNER UUID '0b0e7c4e-1d61-4f8a-9c43-9ca4a0e5d124' (`id` UInt64) ENGINE = MergeTree ORDER BY id AS SELECT id FROM db1.src", "", "", false},
+		{"CREATE MATERIALIZED VIEW db1.mv1 (`id` UInt64) ENGINE = MergeTree ORDER BY id AS SELECT id FROM db1.src", "", "", false},
+		{"CREATE TABLE db1.t1 (`id` UInt64) ENGINE = MergeTree ORDER BY id", "", "", false},
+	}
+	for _, tc := range testCases {
+		database, table, ok := ExtractMaterializedViewTarget(tc.query)
+		assert.Equal(t, tc.expectedOk, ok, tc.query)
+		assert.Equal(t, tc.expectedDatabase, database, tc.query)
+		assert.Equal(t, tc.expectedTable, table, tc.query)
+	}
+}
+
+func TestGetDefaultPath(t *testing.T) {
+	ch := ClickHouse{Config: &config.ClickHouseConfig{}}
+	disks := []Disk{{Name: "default", Path: "/var/lib/clickhouse/data"}}
+	defaultPath, err := ch.GetDefaultPath(disks)
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/lib/clickhouse/data", defaultPath)
+	ch.Config.EmbeddedMetadataLocalPath = "/tmp/clickhouse-backup"
+	defaultPath, _ = ch.GetDefaultPath(disks)
+	assert.Equal(t, "/var/lib/clickhouse/data", defaultPath, "embedded_metadata_local_path shall be ignored without use_embedded_backup_restore")
+	ch.Config.UseEmbeddedBackupRestore = true
+	defaultPath, _ = ch.GetDefaultPath(disks)
+	assert.Equal(t, "/tmp/clickhouse-backup", defaultPath)
+}
+
+func TestActivePartsCountQuery(t *testing.T) {
+	tables := []Table{
+		{Database: `db'1`, Name: `t\1`},
+		{Database: "db2", Name: "t2"},
+		{Database: "db3", Name: "skipped", Skip: true},
+	}
+	partitionsIdMap := map[metadata.TableTitle]common.EmptyMap{
+		{Database: `db'1`, Table: `t\1`}: {},
+		{Database: "db2", Table: "t2"}:   {"202402": {}, "202401": {}},
+	}
+	query, args := activePartsCountQuery(tables, partitionsIdMap)
+	assert.Equal(t, "SELECT count() AS parts_count FROM system.parts WHERE active AND ((database=? AND table=?) OR (database=? AND table=? AND has(?, partition_id)))", query)
+	// raw values passed as arguments, quotes and backslashes escaped by driver during bind
+	assert.Equal(t, []interface{}{`db'1`, `t\1`, "db2", "t2", []string{"202401", "202402"}}, args)
+
+	query, args = activePartsCountQuery(tables[2:], nil)
+	assert.Empty(t, query)
+	assert.Empty(t, args)
+}
@@ -25,7 +25,11 @@ type Table struct {
 	CreateTableQuery string   `ch:"create_table_query"`
 	TotalBytes       uint64   `ch:"total_bytes"`
 	Skip             bool
-	BackupType       ShardBackupType
+	// SkipData - table engine matched with skip_table_engines_schema_only, or table is bigger than general->max_table_data_size
+	SkipData bool
+	// SkipDataReason - stored in table metadata when SkipData is set by general->max_table_data_size
+	SkipDataReason string
+	BackupType     ShardBackupType
 }
 
 // IsSystemTablesFieldPresent - ClickHouse `system.tables` varius field flags
@@ -46,6 +50,17 @@ type Disk struct {
 	IsBackup        bool
 }
 
+// ReplicaHealth - Clickhouse system.replicas struct with count of system.replication_queue entries which have last_exception
+type ReplicaHealth struct {
+	Database         string `ch:"database"`
+	Table            string `ch:"table"`
+	IsReadonly       uint8  `ch:"is_readonly"`
+	IsSessionExpired uint8  `ch:"is_session_expired"`
+	AbsoluteDelay    uint64 `ch:"absolute_delay"`
+	QueueSize        uint32 `ch:"queue_size"`
+	QueueErrors      uint64 `ch:"queue_errors"`
+}
+
 // Database - Clickhouse system.databases struct
 type Database struct {
 	Name   string `ch:"name"`
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestTablePathEncode(t *testing.T) {
@@ -50,3 +51,15 @@ func TestCompareMaps(t *testing.T) {
 	r.True(CompareMaps(oldParams, newParams))
 
 }
+
+func TestFormatAPITime(t *testing.T) {
+	r := require.New(t)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("UTC+3", 3*3600))
+	defer SetAPITimeFormat("default", nil)
+	SetAPITimeFormat("default", nil)
+	r.Equal("2024-01-02 03:04:05", FormatAPITime(ts))
+	SetAPITimeFormat("rfc3339", time.UTC)
+	r.Equal("2024-01-02T00:04:05Z", FormatAPITime(ts))
+	SetAPITimeFormat("2006-01-02", time.UTC)
+	r.Equal("2024-01-02", FormatAPITime(ts))
+}
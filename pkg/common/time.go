@@ -0,0 +1,39 @@
+package common
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	apiTimeLayout   = TimeFormat
+	apiTimeLocation *time.Location
+	apiTimeLock     sync.RWMutex
+)
+
+// SetAPITimeFormat - apply api.time_format and api.timezone, `default` keep clickhouse compatible format, nil location keep timestamps as is
+func SetAPITimeFormat(timeFormat string, location *time.Location) {
+	layout := TimeFormat
+	switch strings.ToLower(timeFormat) {
+	case "", "default":
+	case "rfc3339":
+		layout = time.RFC3339
+	default:
+		layout = timeFormat
+	}
+	apiTimeLock.Lock()
+	defer apiTimeLock.Unlock()
+	apiTimeLayout = layout
+	apiTimeLocation = location
+}
+
+// FormatAPITime - format timestamps for list, status and actions responses
+func FormatAPITime(t time.Time) string {
+	apiTimeLock.RLock()
+	defer apiTimeLock.RUnlock()
+	if apiTimeLocation != nil {
+		t = t.In(apiTimeLocation)
+	}
+	return t.Format(apiTimeLayout)
+}
@@ -1,16 +1,24 @@
 package config
 
 import (
+	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/log_helper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/schedule"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rs/zerolog/log"
@@ -24,16 +32,19 @@ const (
 
 // Config - config file format
 type Config struct {
-	General    GeneralConfig    `yaml:"general" envconfig:"_"`
-	ClickHouse ClickHouseConfig `yaml:"clickhouse" envconfig:"_"`
-	S3         S3Config         `yaml:"s3" envconfig:"_"`
-	GCS        GCSConfig        `yaml:"gcs" envconfig:"_"`
-	COS        COSConfig        `yaml:"cos" envconfig:"_"`
-	API        APIConfig        `yaml:"api" envconfig:"_"`
-	FTP        FTPConfig        `yaml:"ftp" envconfig:"_"`
-	SFTP       SFTPConfig       `yaml:"sftp" envconfig:"_"`
-	AzureBlob  AzureBlobConfig  `yaml:"azblob" envconfig:"_"`
-	Custom     CustomConfig     `yaml:"custom" envconfig:"_"`
+	General       GeneralConfig       `yaml:"general" envconfig:"_"`
+	ClickHouse    ClickHouseConfig    `yaml:"clickhouse" envconfig:"_"`
+	S3            S3Config            `yaml:"s3" envconfig:"_"`
+	GCS           GCSConfig           `yaml:"gcs" envconfig:"_"`
+	COS           COSConfig           `yaml:"cos" envconfig:"_"`
+	API           APIConfig           `yaml:"api" envconfig:"_"`
+	FTP           FTPConfig           `yaml:"ftp" envconfig:"_"`
+	SFTP          SFTPConfig          `yaml:"sftp" envconfig:"_"`
+	Local         LocalConfig         `yaml:"local" envconfig:"_"`
+	AzureBlob     AzureBlobConfig     `yaml:"azblob" envconfig:"_"`
+	Custom        CustomConfig        `yaml:"custom" envconfig:"_"`
+	Notifications NotificationsConfig `yaml:"notifications" envconfig:"_"`
+	Hooks         HooksConfig         `yaml:"hooks" envconfig:"_"`
 }
 
 // GeneralConfig - general setting section
@@ -42,10 +53,22 @@ type GeneralConfig struct {
 	MaxFileSize                         int64             `yaml:"max_file_size" envconfig:"MAX_FILE_SIZE"`
 	BackupsToKeepLocal                  int               `yaml:"backups_to_keep_local" envconfig:"BACKUPS_TO_KEEP_LOCAL"`
 	BackupsToKeepRemote                 int               `yaml:"backups_to_keep_remote" envconfig:"BACKUPS_TO_KEEP_REMOTE"`
+	BackupsToKeepDaysLocal              int               `yaml:"backups_to_keep_days_local" envconfig:"BACKUPS_TO_KEEP_DAYS_LOCAL"`
+	BackupsToKeepDaysRemote             int               `yaml:"backups_to_keep_days_remote" envconfig:"BACKUPS_TO_KEEP_DAYS_REMOTE"`
+	BackupsToKeepGFSLocal               string            `yaml:"backups_to_keep_gfs_local" envconfig:"BACKUPS_TO_KEEP_GFS_LOCAL"`
+	BackupsToKeepGFSRemote              string            `yaml:"backups_to_keep_gfs_remote" envconfig:"BACKUPS_TO_KEEP_GFS_REMOTE"`
+	RetentionIgnoreTags                 []string          `yaml:"retention_ignore_tags" envconfig:"RETENTION_IGNORE_TAGS"`
+	RetentionTimezone                   string            `yaml:"retention_timezone" envconfig:"RETENTION_TIMEZONE"`
+	LockFile                            string            `yaml:"lock_file" envconfig:"LOCK_FILE"`
 	LogLevel                            string            `yaml:"log_level" envconfig:"LOG_LEVEL"`
 	AllowEmptyBackups                   bool              `yaml:"allow_empty_backups" envconfig:"ALLOW_EMPTY_BACKUPS"`
+	MaxPartsCount                       uint64            `yaml:"max_parts_count" envconfig:"MAX_PARTS_COUNT"`
+	MaxPartsCountAction                 string            `yaml:"max_parts_count_action" envconfig:"MAX_PARTS_COUNT_ACTION"`
 	DownloadConcurrency                 uint8             `yaml:"download_concurrency" envconfig:"DOWNLOAD_CONCURRENCY"`
 	UploadConcurrency                   uint8             `yaml:"upload_concurrency" envconfig:"UPLOAD_CONCURRENCY"`
+	DownloadObjectsConcurrency          uint8             `yaml:"download_objects_concurrency" envconfig:"DOWNLOAD_OBJECTS_CONCURRENCY"`
+	UploadObjectsConcurrency            uint8             `yaml:"upload_objects_concurrency" envconfig:"UPLOAD_OBJECTS_CONCURRENCY"`
+	CompressionConcurrency              int               `yaml:"compression_concurrency" envconfig:"COMPRESSION_CONCURRENCY"`
 	UploadMaxBytesPerSecond             uint64            `yaml:"upload_max_bytes_per_second" envconfig:"UPLOAD_MAX_BYTES_PER_SECOND"`
 	DownloadMaxBytesPerSecond           uint64            `yaml:"download_max_bytes_per_second" envconfig:"DOWNLOAD_MAX_BYTES_PER_SECOND"`
 	ObjectDiskServerSideCopyConcurrency uint8             `yaml:"object_disk_server_side_copy_concurrency" envconfig:"OBJECT_DISK_SERVER_SIDE_COPY_CONCURRENCY"`
@@ -53,68 +76,132 @@ type GeneralConfig struct {
 	UseResumableState                   bool              `yaml:"use_resumable_state" envconfig:"USE_RESUMABLE_STATE"`
 	RestoreSchemaOnCluster              string            `yaml:"restore_schema_on_cluster" envconfig:"RESTORE_SCHEMA_ON_CLUSTER"`
 	UploadByPart                        bool              `yaml:"upload_by_part" envconfig:"UPLOAD_BY_PART"`
+	UploadDiffFromLatestRemote          bool              `yaml:"upload_diff_from_latest_remote" envconfig:"UPLOAD_DIFF_FROM_LATEST_REMOTE"`
 	DownloadByPart                      bool              `yaml:"download_by_part" envconfig:"DOWNLOAD_BY_PART"`
 	RestoreDatabaseMapping              map[string]string `yaml:"restore_database_mapping" envconfig:"RESTORE_DATABASE_MAPPING"`
 	RestoreTableMapping                 map[string]string `yaml:"restore_table_mapping" envconfig:"RESTORE_TABLE_MAPPING"`
 	RetriesOnFailure                    int               `yaml:"retries_on_failure" envconfig:"RETRIES_ON_FAILURE"`
 	RetriesPause                        string            `yaml:"retries_pause" envconfig:"RETRIES_PAUSE"`
+	RetriesBackoff                      string            `yaml:"retries_backoff" envconfig:"RETRIES_BACKOFF"`
+	RetriesMaxPause                     string            `yaml:"retries_max_pause" envconfig:"RETRIES_MAX_PAUSE"`
+	RetriesJitter                       float64           `yaml:"retries_jitter" envconfig:"RETRIES_JITTER"`
+	RemoteConnectTimeout                string            `yaml:"remote_connect_timeout" envconfig:"REMOTE_CONNECT_TIMEOUT"`
+	RemoteUploadTimeout                 string            `yaml:"remote_upload_timeout" envconfig:"REMOTE_UPLOAD_TIMEOUT"`
+	RemoteListTimeout                   string            `yaml:"remote_list_timeout" envconfig:"REMOTE_LIST_TIMEOUT"`
+	RemoteProxy                         string            `yaml:"remote_proxy" envconfig:"REMOTE_PROXY"`
+	ReplicaConfigs                      []string          `yaml:"replica_configs" envconfig:"REPLICA_CONFIGS"`
+	ReplicaUploadMode                   string            `yaml:"replica_upload_mode" envconfig:"REPLICA_UPLOAD_MODE"`
 	WatchInterval                       string            `yaml:"watch_interval" envconfig:"WATCH_INTERVAL"`
 	FullInterval                        string            `yaml:"full_interval" envconfig:"FULL_INTERVAL"`
 	WatchBackupNameTemplate             string            `yaml:"watch_backup_name_template" envconfig:"WATCH_BACKUP_NAME_TEMPLATE"`
+	BackupNameTemplate                  string            `yaml:"backup_name_template" envconfig:"BACKUP_NAME_TEMPLATE"`
+	WatchIncrementFromFull              bool              `yaml:"watch_increment_from_full" envconfig:"WATCH_INCREMENT_FROM_FULL"`
 	ShardedOperationMode                string            `yaml:"sharded_operation_mode" envconfig:"SHARDED_OPERATION_MODE"`
 	CPUNicePriority                     int               `yaml:"cpu_nice_priority" envconfig:"CPU_NICE_PRIORITY"`
 	IONicePriority                      string            `yaml:"io_nice_priority" envconfig:"IO_NICE_PRIORITY"`
 	RBACBackupAlways                    bool              `yaml:"rbac_backup_always" envconfig:"RBAC_BACKUP_ALWAYS"`
 	RBACConflictResolution              string            `yaml:"rbac_conflict_resolution" envconfig:"RBAC_CONFLICT_RESOLUTION"`
+	PostCreateChecks                    SQLQueries        `yaml:"post_create_checks" envconfig:"POST_CREATE_CHECKS"`
+	MetadataSigningKey                  string            `yaml:"metadata_signing_key" envconfig:"METADATA_SIGNING_KEY"`
+	FreeSpaceReserve                    string            `yaml:"free_space_reserve" envconfig:"FREE_SPACE_RESERVE"`
+	MaxTableDataSize                    string            `yaml:"max_table_data_size" envconfig:"MAX_TABLE_DATA_SIZE"`
+	UploadDiffMaxChainLength            int               `yaml:"upload_diff_max_chain_length" envconfig:"UPLOAD_DIFF_MAX_CHAIN_LENGTH"`
+	UploadDiffFullInterval              string            `yaml:"upload_diff_full_interval" envconfig:"UPLOAD_DIFF_FULL_INTERVAL"`
 	RetriesDuration                     time.Duration
+	RetriesMaxDuration                  time.Duration
+	RemoteConnectTimeoutDuration        time.Duration
+	RemoteUploadTimeoutDuration         time.Duration
+	RemoteListTimeoutDuration           time.Duration
 	WatchDuration                       time.Duration
 	FullDuration                        time.Duration
+	UploadDiffFullDuration              time.Duration
+	FreeSpaceReserveBytes               uint64
+	MaxTableDataSizeBytes               uint64
+	RetentionLocal                      RetentionPolicy `yaml:"-" ignored:"true"`
+	RetentionRemote                     RetentionPolicy `yaml:"-" ignored:"true"`
+	RetentionTimeLocation               *time.Location  `yaml:"-" ignored:"true"`
+}
+
+// SQLQueries - list of SQL queries, environment variable value is split by semicolon, cause queries could contain commas
+type SQLQueries []string
+
+// Decode - implements envconfig.Decoder
+func (q *SQLQueries) Decode(value string) error {
+	queries := SQLQueries{}
+	for _, query := range strings.Split(value, ";") {
+		if query = strings.TrimSpace(query); query != "" {
+			queries = append(queries, query)
+		}
+	}
+	*q = queries
+	return nil
 }
 
 // GCSConfig - GCS settings section
 type GCSConfig struct {
-	CredentialsFile        string            `yaml:"credentials_file" envconfig:"GCS_CREDENTIALS_FILE"`
-	CredentialsJSON        string            `yaml:"credentials_json" envconfig:"GCS_CREDENTIALS_JSON"`
-	CredentialsJSONEncoded string            `yaml:"credentials_json_encoded" envconfig:"GCS_CREDENTIALS_JSON_ENCODED"`
-	EmbeddedAccessKey      string            `yaml:"embedded_access_key" envconfig:"GCS_EMBEDDED_ACCESS_KEY"`
-	EmbeddedSecretKey      string            `yaml:"embedded_secret_key" envconfig:"GCS_EMBEDDED_SECRET_KEY"`
-	SkipCredentials        bool              `yaml:"skip_credentials" envconfig:"GCS_SKIP_CREDENTIALS"`
-	Bucket                 string            `yaml:"bucket" envconfig:"GCS_BUCKET"`
-	Path                   string            `yaml:"path" envconfig:"GCS_PATH"`
-	ObjectDiskPath         string            `yaml:"object_disk_path" envconfig:"GCS_OBJECT_DISK_PATH"`
-	CompressionLevel       int               `yaml:"compression_level" envconfig:"GCS_COMPRESSION_LEVEL"`
-	CompressionFormat      string            `yaml:"compression_format" envconfig:"GCS_COMPRESSION_FORMAT"`
-	Debug                  bool              `yaml:"debug" envconfig:"GCS_DEBUG"`
-	ForceHttp              bool              `yaml:"force_http" envconfig:"GCS_FORCE_HTTP"`
-	Endpoint               string            `yaml:"endpoint" envconfig:"GCS_ENDPOINT"`
-	StorageClass           string            `yaml:"storage_class" envconfig:"GCS_STORAGE_CLASS"`
-	ObjectLabels           map[string]string `yaml:"object_labels" envconfig:"GCS_OBJECT_LABELS"`
-	CustomStorageClassMap  map[string]string `yaml:"custom_storage_class_map" envconfig:"GCS_CUSTOM_STORAGE_CLASS_MAP"`
+	CredentialsFile        string `yaml:"credentials_file" envconfig:"GCS_CREDENTIALS_FILE"`
+	CredentialsJSON        string `yaml:"credentials_json" envconfig:"GCS_CREDENTIALS_JSON"`
+	CredentialsJSONEncoded string `yaml:"credentials_json_encoded" envconfig:"GCS_CREDENTIALS_JSON_ENCODED"`
+	EmbeddedAccessKey      string `yaml:"embedded_access_key" envconfig:"GCS_EMBEDDED_ACCESS_KEY"`
+	EmbeddedSecretKey      string `yaml:"embedded_secret_key" envconfig:"GCS_EMBEDDED_SECRET_KEY"`
+	SkipCredentials        bool   `yaml:"skip_credentials" envconfig:"GCS_SKIP_CREDENTIALS"`
+	// ImpersonateServiceAccount - email of service account, roles/iam.serviceAccountTokenCreator is required for credentials which used for impersonation
+	ImpersonateServiceAccount string            `yaml:"impersonate_service_account" envconfig:"GCS_IMPERSONATE_SERVICE_ACCOUNT"`
+	ImpersonateDelegates      []string          `yaml:"impersonate_delegates" envconfig:"GCS_IMPERSONATE_DELEGATES"`
+	Bucket                    string            `yaml:"bucket" envconfig:"GCS_BUCKET"`
+	Path                      string            `yaml:"path" envconfig:"GCS_PATH"`
+	ObjectDiskPath            string            `yaml:"object_disk_path" envconfig:"GCS_OBJECT_DISK_PATH"`
+	CompressionLevel          int               `yaml:"compression_level" envconfig:"GCS_COMPRESSION_LEVEL"`
+	CompressionFormat         string            `yaml:"compression_format" envconfig:"GCS_COMPRESSION_FORMAT"`
+	Debug                     bool              `yaml:"debug" envconfig:"GCS_DEBUG"`
+	ForceHttp                 bool              `yaml:"force_http" envconfig:"GCS_FORCE_HTTP"`
+	Endpoint                  string            `yaml:"endpoint" envconfig:"GCS_ENDPOINT"`
+	StorageClass              string            `yaml:"storage_class" envconfig:"GCS_STORAGE_CLASS"`
+	ObjectLabels              map[string]string `yaml:"object_labels" envconfig:"GCS_OBJECT_LABELS"`
+	CustomStorageClassMap     map[string]string `yaml:"custom_storage_class_map" envconfig:"GCS_CUSTOM_STORAGE_CLASS_MAP"`
 	// NOTE: ClientPoolSize should be at least 2 times bigger than
 	// 			UploadConcurrency or DownloadConcurrency in each upload and download case
 	ClientPoolSize int `yaml:"client_pool_size" envconfig:"GCS_CLIENT_POOL_SIZE"`
 	ChunkSize      int `yaml:"chunk_size" envconfig:"GCS_CHUNK_SIZE"`
+	// ChunkRetryDeadline - how long resumable upload retries one chunk on flaky network before fail
+	ChunkRetryDeadline         string `yaml:"chunk_retry_deadline" envconfig:"GCS_CHUNK_RETRY_DEADLINE"`
+	ChunkRetryDeadlineDuration time.Duration
+	EncryptionKeyName          string `yaml:"encryption_key_name" envconfig:"GCS_ENCRYPTION_KEY_NAME"`
+	Proxy                      string `yaml:"proxy" envconfig:"GCS_PROXY"`
+	ConnectTimeout             string `yaml:"connect_timeout" envconfig:"GCS_CONNECT_TIMEOUT"`
+	UploadTimeout              string `yaml:"upload_timeout" envconfig:"GCS_UPLOAD_TIMEOUT"`
+	ListTimeout                string `yaml:"list_timeout" envconfig:"GCS_LIST_TIMEOUT"`
 }
 
+// GCSStorageClasses - allowed values for GCS_STORAGE_CLASS and GCS_CUSTOM_STORAGE_CLASS_MAP, look https://cloud.google.com/storage/docs/storage-classes
+var GCSStorageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE", "MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY"}
+
 // AzureBlobConfig - Azure Blob settings section
 type AzureBlobConfig struct {
-	EndpointSchema        string `yaml:"endpoint_schema" envconfig:"AZBLOB_ENDPOINT_SCHEMA"`
-	EndpointSuffix        string `yaml:"endpoint_suffix" envconfig:"AZBLOB_ENDPOINT_SUFFIX"`
-	AccountName           string `yaml:"account_name" envconfig:"AZBLOB_ACCOUNT_NAME"`
-	AccountKey            string `yaml:"account_key" envconfig:"AZBLOB_ACCOUNT_KEY"`
-	SharedAccessSignature string `yaml:"sas" envconfig:"AZBLOB_SAS"`
-	UseManagedIdentity    bool   `yaml:"use_managed_identity" envconfig:"AZBLOB_USE_MANAGED_IDENTITY"`
-	Container             string `yaml:"container" envconfig:"AZBLOB_CONTAINER"`
-	Path                  string `yaml:"path" envconfig:"AZBLOB_PATH"`
-	ObjectDiskPath        string `yaml:"object_disk_path" envconfig:"AZBLOB_OBJECT_DISK_PATH"`
-	CompressionLevel      int    `yaml:"compression_level" envconfig:"AZBLOB_COMPRESSION_LEVEL"`
-	CompressionFormat     string `yaml:"compression_format" envconfig:"AZBLOB_COMPRESSION_FORMAT"`
-	SSEKey                string `yaml:"sse_key" envconfig:"AZBLOB_SSE_KEY"`
-	BufferSize            int    `yaml:"buffer_size" envconfig:"AZBLOB_BUFFER_SIZE"`
-	MaxBuffers            int    `yaml:"buffer_count" envconfig:"AZBLOB_MAX_BUFFERS"`
-	MaxPartsCount         int    `yaml:"max_parts_count" envconfig:"AZBLOB_MAX_PARTS_COUNT"`
-	Timeout               string `yaml:"timeout" envconfig:"AZBLOB_TIMEOUT"`
-	Debug                 bool   `yaml:"debug" envconfig:"AZBLOB_DEBUG"`
+	EndpointSchema           string `yaml:"endpoint_schema" envconfig:"AZBLOB_ENDPOINT_SCHEMA"`
+	EndpointSuffix           string `yaml:"endpoint_suffix" envconfig:"AZBLOB_ENDPOINT_SUFFIX"`
+	AccountName              string `yaml:"account_name" envconfig:"AZBLOB_ACCOUNT_NAME"`
+	AccountKey               string `yaml:"account_key" envconfig:"AZBLOB_ACCOUNT_KEY"`
+	SharedAccessSignature    string `yaml:"sas" envconfig:"AZBLOB_SAS"`
+	UseManagedIdentity       bool   `yaml:"use_managed_identity" envconfig:"AZBLOB_USE_MANAGED_IDENTITY"`
+	Container                string `yaml:"container" envconfig:"AZBLOB_CONTAINER"`
+	Path                     string `yaml:"path" envconfig:"AZBLOB_PATH"`
+	ObjectDiskPath           string `yaml:"object_disk_path" envconfig:"AZBLOB_OBJECT_DISK_PATH"`
+	CompressionLevel         int    `yaml:"compression_level" envconfig:"AZBLOB_COMPRESSION_LEVEL"`
+	CompressionFormat        string `yaml:"compression_format" envconfig:"AZBLOB_COMPRESSION_FORMAT"`
+	SSEKey                   string `yaml:"sse_key" envconfig:"AZBLOB_SSE_KEY"`
+	BufferSize               int    `yaml:"buffer_size" envconfig:"AZBLOB_BUFFER_SIZE"`
+	MaxBuffers               int    `yaml:"buffer_count" envconfig:"AZBLOB_MAX_BUFFERS"`
+	MaxPartsCount            int    `yaml:"max_parts_count" envconfig:"AZBLOB_MAX_PARTS_COUNT"`
+	Timeout                  string `yaml:"timeout" envconfig:"AZBLOB_TIMEOUT"`
+	RehydratePriority        string `yaml:"rehydrate_priority" envconfig:"AZBLOB_REHYDRATE_PRIORITY"`
+	RehydrateMaxWait         string `yaml:"rehydrate_max_wait" envconfig:"AZBLOB_REHYDRATE_MAX_WAIT"`
+	RehydrateMaxWaitDuration time.Duration
+	Debug                    bool   `yaml:"debug" envconfig:"AZBLOB_DEBUG"`
+	Proxy                    string `yaml:"proxy" envconfig:"AZBLOB_PROXY"`
+	ConnectTimeout           string `yaml:"connect_timeout" envconfig:"AZBLOB_CONNECT_TIMEOUT"`
+	UploadTimeout            string `yaml:"upload_timeout" envconfig:"AZBLOB_UPLOAD_TIMEOUT"`
+	ListTimeout              string `yaml:"list_timeout" envconfig:"AZBLOB_LIST_TIMEOUT"`
 }
 
 // S3Config - s3 settings section
@@ -126,6 +213,10 @@ type S3Config struct {
 	Region                  string            `yaml:"region" envconfig:"S3_REGION"`
 	ACL                     string            `yaml:"acl" envconfig:"S3_ACL"`
 	AssumeRoleARN           string            `yaml:"assume_role_arn" envconfig:"S3_ASSUME_ROLE_ARN"`
+	AssumeRoleSessionName   string            `yaml:"assume_role_session_name" envconfig:"S3_ASSUME_ROLE_SESSION_NAME"`
+	AssumeRoleExternalID    string            `yaml:"assume_role_external_id" envconfig:"S3_ASSUME_ROLE_EXTERNAL_ID"`
+	WebIdentityRoleARN      string            `yaml:"web_identity_role_arn" envconfig:"S3_WEB_IDENTITY_ROLE_ARN"`
+	WebIdentityTokenFile    string            `yaml:"web_identity_token_file" envconfig:"S3_WEB_IDENTITY_TOKEN_FILE"`
 	ForcePathStyle          bool              `yaml:"force_path_style" envconfig:"S3_FORCE_PATH_STYLE"`
 	Path                    string            `yaml:"path" envconfig:"S3_PATH"`
 	ObjectDiskPath          string            `yaml:"object_disk_path" envconfig:"S3_OBJECT_DISK_PATH"`
@@ -139,6 +230,9 @@ type S3Config struct {
 	SSECustomerKeyMD5       string            `yaml:"sse_customer_key_md5" envconfig:"S3_SSE_CUSTOMER_KEY_MD5"`
 	SSEKMSEncryptionContext string            `yaml:"sse_kms_encryption_context" envconfig:"S3_SSE_KMS_ENCRYPTION_CONTEXT"`
 	DisableCertVerification bool              `yaml:"disable_cert_verification" envconfig:"S3_DISABLE_CERT_VERIFICATION"`
+	TLSCa                   string            `yaml:"tls_ca" envconfig:"S3_TLS_CA"`
+	TLSCert                 string            `yaml:"tls_cert" envconfig:"S3_TLS_CERT"`
+	TLSKey                  string            `yaml:"tls_key" envconfig:"S3_TLS_KEY"`
 	UseCustomStorageClass   bool              `yaml:"use_custom_storage_class" envconfig:"S3_USE_CUSTOM_STORAGE_CLASS"`
 	StorageClass            string            `yaml:"storage_class" envconfig:"S3_STORAGE_CLASS"`
 	CustomStorageClassMap   map[string]string `yaml:"custom_storage_class_map" envconfig:"S3_CUSTOM_STORAGE_CLASS_MAP"`
@@ -147,22 +241,41 @@ type S3Config struct {
 	MaxPartsCount           int64             `yaml:"max_parts_count" envconfig:"S3_MAX_PARTS_COUNT"`
 	AllowMultipartDownload  bool              `yaml:"allow_multipart_download" envconfig:"S3_ALLOW_MULTIPART_DOWNLOAD"`
 	ObjectLabels            map[string]string `yaml:"object_labels" envconfig:"S3_OBJECT_LABELS"`
+	ObjectMetadata          map[string]string `yaml:"object_metadata" envconfig:"S3_OBJECT_METADATA"`
 	RequestPayer            string            `yaml:"request_payer" envconfig:"S3_REQUEST_PAYER"`
 	CheckSumAlgorithm       string            `yaml:"check_sum_algorithm" envconfig:"S3_CHECKSUM_ALGORITHM"`
-	Debug                   bool              `yaml:"debug" envconfig:"S3_DEBUG"`
+	RestoreTier             string            `yaml:"restore_tier" envconfig:"S3_RESTORE_TIER"`
+	RestoreDays             int32             `yaml:"restore_days" envconfig:"S3_RESTORE_DAYS"`
+	RestoreMaxWait          string            `yaml:"restore_max_wait" envconfig:"S3_RESTORE_MAX_WAIT"`
+	RestoreMaxWaitDuration  time.Duration
+	ObjectLockMode          string `yaml:"object_lock_mode" envconfig:"S3_OBJECT_LOCK_MODE"`
+	ObjectLockDays          int    `yaml:"object_lock_days" envconfig:"S3_OBJECT_LOCK_DAYS"`
+	Debug                   bool   `yaml:"debug" envconfig:"S3_DEBUG"`
+	Proxy                   string `yaml:"proxy" envconfig:"S3_PROXY"`
+	ConnectTimeout          string `yaml:"connect_timeout" envconfig:"S3_CONNECT_TIMEOUT"`
+	UploadTimeout           string `yaml:"upload_timeout" envconfig:"S3_UPLOAD_TIMEOUT"`
+	ListTimeout             string `yaml:"list_timeout" envconfig:"S3_LIST_TIMEOUT"`
 }
 
 // COSConfig - cos settings section
 type COSConfig struct {
-	RowURL            string `yaml:"url" envconfig:"COS_URL"`
-	Timeout           string `yaml:"timeout" envconfig:"COS_TIMEOUT"`
-	SecretID          string `yaml:"secret_id" envconfig:"COS_SECRET_ID"`
-	SecretKey         string `yaml:"secret_key" envconfig:"COS_SECRET_KEY"`
-	Path              string `yaml:"path" envconfig:"COS_PATH"`
-	ObjectDiskPath    string `yaml:"object_disk_path" envconfig:"COS_OBJECT_DISK_PATH"`
-	CompressionFormat string `yaml:"compression_format" envconfig:"COS_COMPRESSION_FORMAT"`
-	CompressionLevel  int    `yaml:"compression_level" envconfig:"COS_COMPRESSION_LEVEL"`
-	Debug             bool   `yaml:"debug" envconfig:"COS_DEBUG"`
+	RowURL                string `yaml:"url" envconfig:"COS_URL"`
+	Timeout               string `yaml:"timeout" envconfig:"COS_TIMEOUT"`
+	SecretID              string `yaml:"secret_id" envconfig:"COS_SECRET_ID"`
+	SecretKey             string `yaml:"secret_key" envconfig:"COS_SECRET_KEY"`
+	Path                  string `yaml:"path" envconfig:"COS_PATH"`
+	ObjectDiskPath        string `yaml:"object_disk_path" envconfig:"COS_OBJECT_DISK_PATH"`
+	CompressionFormat     string `yaml:"compression_format" envconfig:"COS_COMPRESSION_FORMAT"`
+	CompressionLevel      int    `yaml:"compression_level" envconfig:"COS_COMPRESSION_LEVEL"`
+	PartSize              int64  `yaml:"part_size" envconfig:"COS_PART_SIZE"`
+	Concurrency           int    `yaml:"concurrency" envconfig:"COS_CONCURRENCY"`
+	RetryCount            int    `yaml:"retry_count" envconfig:"COS_RETRY_COUNT"`
+	RetryInterval         string `yaml:"retry_interval" envconfig:"COS_RETRY_INTERVAL"`
+	RetryIntervalDuration time.Duration
+	Debug                 bool   `yaml:"debug" envconfig:"COS_DEBUG"`
+	ConnectTimeout        string `yaml:"connect_timeout" envconfig:"COS_CONNECT_TIMEOUT"`
+	UploadTimeout         string `yaml:"upload_timeout" envconfig:"COS_UPLOAD_TIMEOUT"`
+	ListTimeout           string `yaml:"list_timeout" envconfig:"COS_LIST_TIMEOUT"`
 }
 
 // FTPConfig - ftp settings section
@@ -179,6 +292,10 @@ type FTPConfig struct {
 	CompressionLevel  int    `yaml:"compression_level" envconfig:"FTP_COMPRESSION_LEVEL"`
 	Concurrency       uint8  `yaml:"concurrency" envconfig:"FTP_CONCURRENCY"`
 	Debug             bool   `yaml:"debug" envconfig:"FTP_DEBUG"`
+	Proxy             string `yaml:"proxy" envconfig:"FTP_PROXY"`
+	ConnectTimeout    string `yaml:"connect_timeout" envconfig:"FTP_CONNECT_TIMEOUT"`
+	UploadTimeout     string `yaml:"upload_timeout" envconfig:"FTP_UPLOAD_TIMEOUT"`
+	ListTimeout       string `yaml:"list_timeout" envconfig:"FTP_LIST_TIMEOUT"`
 }
 
 // SFTPConfig - sftp settings section
@@ -193,7 +310,21 @@ type SFTPConfig struct {
 	CompressionFormat string `yaml:"compression_format" envconfig:"SFTP_COMPRESSION_FORMAT"`
 	CompressionLevel  int    `yaml:"compression_level" envconfig:"SFTP_COMPRESSION_LEVEL"`
 	Concurrency       int    `yaml:"concurrency" envconfig:"SFTP_CONCURRENCY"`
-	Debug             bool   `yaml:"debug" envconfig:"SFTP_DEBUG"`
+	// ConnectionPoolSize - how many SSH connections could be used in parallel for upload and download
+	ConnectionPoolSize int    `yaml:"connection_pool_size" envconfig:"SFTP_CONNECTION_POOL_SIZE"`
+	Debug              bool   `yaml:"debug" envconfig:"SFTP_DEBUG"`
+	ConnectTimeout     string `yaml:"connect_timeout" envconfig:"SFTP_CONNECT_TIMEOUT"`
+	UploadTimeout      string `yaml:"upload_timeout" envconfig:"SFTP_UPLOAD_TIMEOUT"`
+	ListTimeout        string `yaml:"list_timeout" envconfig:"SFTP_LIST_TIMEOUT"`
+}
+
+// LocalConfig - local directory settings section, path is usually mounted NFS or SMB share
+type LocalConfig struct {
+	Path              string `yaml:"path" envconfig:"LOCAL_PATH"`
+	ObjectDiskPath    string `yaml:"object_disk_path" envconfig:"LOCAL_OBJECT_DISK_PATH"`
+	CompressionFormat string `yaml:"compression_format" envconfig:"LOCAL_COMPRESSION_FORMAT"`
+	CompressionLevel  int    `yaml:"compression_level" envconfig:"LOCAL_COMPRESSION_LEVEL"`
+	Debug             bool   `yaml:"debug" envconfig:"LOCAL_DEBUG"`
 }
 
 // CustomConfig - custom CLI storage settings section
@@ -206,6 +337,30 @@ type CustomConfig struct {
 	CommandTimeoutDuration time.Duration
 }
 
+// HooksConfig - shell commands executed around create, upload and restore
+type HooksConfig struct {
+	BeforeCreate       string `yaml:"before_create" envconfig:"HOOKS_BEFORE_CREATE"`
+	AfterCreate        string `yaml:"after_create" envconfig:"HOOKS_AFTER_CREATE"`
+	BeforeRestore      string `yaml:"before_restore" envconfig:"HOOKS_BEFORE_RESTORE"`
+	AfterUploadSuccess string `yaml:"after_upload_success" envconfig:"HOOKS_AFTER_UPLOAD_SUCCESS"`
+	OnFailure          string `yaml:"on_failure" envconfig:"HOOKS_ON_FAILURE"`
+	Timeout            string `yaml:"timeout" envconfig:"HOOKS_TIMEOUT"`
+	TimeoutDuration    time.Duration
+}
+
+// NotificationsConfig - slack and telegram notifications settings section
+type NotificationsConfig struct {
+	SlackWebhookURL  string `yaml:"slack_webhook_url" envconfig:"NOTIFICATIONS_SLACK_WEBHOOK_URL"`
+	TelegramBotToken string `yaml:"telegram_bot_token" envconfig:"NOTIFICATIONS_TELEGRAM_BOT_TOKEN"`
+	TelegramChatID   string `yaml:"telegram_chat_id" envconfig:"NOTIFICATIONS_TELEGRAM_CHAT_ID"`
+	TelegramAPIURL   string `yaml:"telegram_api_url" envconfig:"NOTIFICATIONS_TELEGRAM_API_URL"`
+	OnSuccess        bool   `yaml:"on_success" envconfig:"NOTIFICATIONS_ON_SUCCESS"`
+	OnFailure        bool   `yaml:"on_failure" envconfig:"NOTIFICATIONS_ON_FAILURE"`
+	Template         string `yaml:"template" envconfig:"NOTIFICATIONS_TEMPLATE"`
+	Timeout          string `yaml:"timeout" envconfig:"NOTIFICATIONS_TIMEOUT"`
+	TimeoutDuration  time.Duration
+}
+
 // ClickHouseConfig - clickhouse settings section
 type ClickHouseConfig struct {
 	Username                         string            `yaml:"username" envconfig:"CLICKHOUSE_USERNAME"`
@@ -215,11 +370,13 @@ type ClickHouseConfig struct {
 	DiskMapping                      map[string]string `yaml:"disk_mapping" envconfig:"CLICKHOUSE_DISK_MAPPING"`
 	SkipTables                       []string          `yaml:"skip_tables" envconfig:"CLICKHOUSE_SKIP_TABLES"`
 	SkipTableEngines                 []string          `yaml:"skip_table_engines" envconfig:"CLICKHOUSE_SKIP_TABLE_ENGINES"`
+	SkipTableEnginesSchemaOnly       []string          `yaml:"skip_table_engines_schema_only" envconfig:"CLICKHOUSE_SKIP_TABLE_ENGINES_SCHEMA_ONLY"`
 	Timeout                          string            `yaml:"timeout" envconfig:"CLICKHOUSE_TIMEOUT"`
 	FreezeByPart                     bool              `yaml:"freeze_by_part" envconfig:"CLICKHOUSE_FREEZE_BY_PART"`
 	FreezeByPartWhere                string            `yaml:"freeze_by_part_where" envconfig:"CLICKHOUSE_FREEZE_BY_PART_WHERE"`
 	UseEmbeddedBackupRestore         bool              `yaml:"use_embedded_backup_restore" envconfig:"CLICKHOUSE_USE_EMBEDDED_BACKUP_RESTORE"`
 	EmbeddedBackupDisk               string            `yaml:"embedded_backup_disk" envconfig:"CLICKHOUSE_EMBEDDED_BACKUP_DISK"`
+	EmbeddedMetadataLocalPath        string            `yaml:"embedded_metadata_local_path" envconfig:"CLICKHOUSE_EMBEDDED_METADATA_LOCAL_PATH"`
 	BackupMutations                  bool              `yaml:"backup_mutations" envconfig:"CLICKHOUSE_BACKUP_MUTATIONS"`
 	RestoreAsAttach                  bool              `yaml:"restore_as_attach" envconfig:"CLICKHOUSE_RESTORE_AS_ATTACH"`
 	CheckPartsColumns                bool              `yaml:"check_parts_columns" envconfig:"CLICKHOUSE_CHECK_PARTS_COLUMNS"`
@@ -229,14 +386,28 @@ type ClickHouseConfig struct {
 	LogSQLQueries                    bool              `yaml:"log_sql_queries" envconfig:"CLICKHOUSE_LOG_SQL_QUERIES"`
 	ConfigDir                        string            `yaml:"config_dir" envconfig:"CLICKHOUSE_CONFIG_DIR"`
 	RestartCommand                   string            `yaml:"restart_command" envconfig:"CLICKHOUSE_RESTART_COMMAND"`
+	BackupDictionaryFiles            bool              `yaml:"backup_dictionary_files" envconfig:"CLICKHOUSE_BACKUP_DICTIONARY_FILES"`
+	KeeperSnapshotPath               string            `yaml:"keeper_snapshot_path" envconfig:"CLICKHOUSE_KEEPER_SNAPSHOT_PATH"`
+	KeeperSnapshotAddress            string            `yaml:"keeper_snapshot_address" envconfig:"CLICKHOUSE_KEEPER_SNAPSHOT_ADDRESS"`
+	KeeperSnapshotTimeout            string            `yaml:"keeper_snapshot_timeout" envconfig:"CLICKHOUSE_KEEPER_SNAPSHOT_TIMEOUT"`
+	KeeperSnapshotTimeoutDuration    time.Duration
 	IgnoreNotExistsErrorDuringFreeze bool              `yaml:"ignore_not_exists_error_during_freeze" envconfig:"CLICKHOUSE_IGNORE_NOT_EXISTS_ERROR_DURING_FREEZE"`
+	CleanShadowAfterFreeze           bool              `yaml:"clean_shadow_after_freeze" envconfig:"CLICKHOUSE_CLEAN_SHADOW_AFTER_FREEZE"`
+	StopMergesDuringFreeze           bool              `yaml:"stop_merges_during_freeze" envconfig:"CLICKHOUSE_STOP_MERGES_DURING_FREEZE"`
 	CheckReplicasBeforeAttach        bool              `yaml:"check_replicas_before_attach" envconfig:"CLICKHOUSE_CHECK_REPLICAS_BEFORE_ATTACH"`
+	CheckReplicasBeforeBackup        bool              `yaml:"check_replicas_before_backup" envconfig:"CLICKHOUSE_CHECK_REPLICAS_BEFORE_BACKUP"`
+	CheckReplicasMaxAbsoluteDelay    uint64            `yaml:"check_replicas_max_absolute_delay" envconfig:"CLICKHOUSE_CHECK_REPLICAS_MAX_ABSOLUTE_DELAY"`
+	CheckReplicasMaxQueueErrors      uint64            `yaml:"check_replicas_max_queue_errors" envconfig:"CLICKHOUSE_CHECK_REPLICAS_MAX_QUEUE_ERRORS"`
 	DefaultReplicaPath               string            `yaml:"default_replica_path" envconfig:"CLICKHOUSE_DEFAULT_REPLICA_PATH"`
 	DefaultReplicaName               string            `yaml:"default_replica_name" envconfig:"CLICKHOUSE_DEFAULT_REPLICA_NAME"`
+	ReplicationPathMapping           map[string]string `yaml:"replication_path_mapping" envconfig:"CLICKHOUSE_REPLICATION_PATH_MAPPING"`
+	StoragePolicyMapping             map[string]string `yaml:"storage_policy_mapping" envconfig:"CLICKHOUSE_STORAGE_POLICY_MAPPING"`
 	TLSKey                           string            `yaml:"tls_key" envconfig:"CLICKHOUSE_TLS_KEY"`
 	TLSCert                          string            `yaml:"tls_cert" envconfig:"CLICKHOUSE_TLS_CERT"`
 	TLSCa                            string            `yaml:"tls_ca" envconfig:"CLICKHOUSE_TLS_CA"`
 	MaxConnections                   int               `yaml:"max_connections" envconfig:"CLICKHOUSE_MAX_CONNECTIONS"`
+	FreezeConcurrency                int               `yaml:"freeze_concurrency" envconfig:"CLICKHOUSE_FREEZE_CONCURRENCY"`
+	RestoreConcurrency               int               `yaml:"restore_concurrency" envconfig:"CLICKHOUSE_RESTORE_CONCURRENCY"`
 	Debug                            bool              `yaml:"debug" envconfig:"CLICKHOUSE_DEBUG"`
 }
 
@@ -256,6 +427,26 @@ type APIConfig struct {
 	AllowParallel                 bool   `yaml:"allow_parallel" envconfig:"API_ALLOW_PARALLEL"`
 	CompleteResumableAfterRestart bool   `yaml:"complete_resumable_after_restart" envconfig:"API_COMPLETE_RESUMABLE_AFTER_RESTART"`
 	WatchIsMainProcess            bool   `yaml:"watch_is_main_process" envconfig:"WATCH_IS_MAIN_PROCESS"`
+	BackupMetricsUpdateInterval   string `yaml:"backup_metrics_update_interval" envconfig:"API_BACKUP_METRICS_UPDATE_INTERVAL"`
+	BackupMetricsUpdateDuration   time.Duration
+	RouteRateLimits               map[string]float64 `yaml:"route_rate_limits" envconfig:"API_ROUTE_RATE_LIMITS"`
+	RouteQueueTimeout             string             `yaml:"route_queue_timeout" envconfig:"API_ROUTE_QUEUE_TIMEOUT"`
+	RouteQueueTimeoutDuration     time.Duration
+	IdempotencyKeyTTL             string `yaml:"idempotency_key_ttl" envconfig:"API_IDEMPOTENCY_KEY_TTL"`
+	IdempotencyKeyTTLDuration     time.Duration
+	SharedLock                    string   `yaml:"shared_lock" envconfig:"API_SHARED_LOCK"`
+	SharedLockPath                string   `yaml:"shared_lock_path" envconfig:"API_SHARED_LOCK_PATH"`
+	Peers                         []string `yaml:"peers" envconfig:"API_PEERS"`
+	PeersTimeout                  string   `yaml:"peers_timeout" envconfig:"API_PEERS_TIMEOUT"`
+	PeersTimeoutDuration          time.Duration
+	PushgatewayURL                string         `yaml:"pushgateway_url" envconfig:"API_PUSHGATEWAY_URL"`
+	PushgatewayJob                string         `yaml:"pushgateway_job" envconfig:"API_PUSHGATEWAY_JOB"`
+	TimeFormat                    string         `yaml:"time_format" envconfig:"API_TIME_FORMAT"`
+	Timezone                      string         `yaml:"timezone" envconfig:"API_TIMEZONE"`
+	TimeLocation                  *time.Location `yaml:"-" ignored:"true"`
+	ScheduleFull                  string         `yaml:"schedule_full" envconfig:"API_SCHEDULE_FULL"`
+	ScheduleIncremental           string         `yaml:"schedule_incremental" envconfig:"API_SCHEDULE_INCREMENTAL"`
+	ScheduleRetention             string         `yaml:"schedule_retention" envconfig:"API_SCHEDULE_RETENTION"`
 }
 
 // ArchiveExtensions - list of available compression formats and associated file extensions
@@ -283,6 +474,8 @@ func (cfg *Config) GetArchiveExtension() string {
 		return ArchiveExtensions[cfg.FTP.CompressionFormat]
 	case "sftp":
 		return ArchiveExtensions[cfg.SFTP.CompressionFormat]
+	case "local":
+		return ArchiveExtensions[cfg.Local.CompressionFormat]
 	case "azblob":
 		return ArchiveExtensions[cfg.AzureBlob.CompressionFormat]
 	default:
@@ -302,6 +495,8 @@ func (cfg *Config) GetCompressionFormat() string {
 		return cfg.FTP.CompressionFormat
 	case "sftp":
 		return cfg.SFTP.CompressionFormat
+	case "local":
+		return cfg.Local.CompressionFormat
 	case "azblob":
 		return cfg.AzureBlob.CompressionFormat
 	case "none", "custom":
@@ -311,6 +506,139 @@ func (cfg *Config) GetCompressionFormat() string {
 	}
 }
 
+func (cfg *Config) GetCompressionLevel() int {
+	switch cfg.General.RemoteStorage {
+	case "s3":
+		return cfg.S3.CompressionLevel
+	case "gcs":
+		return cfg.GCS.CompressionLevel
+	case "cos":
+		return cfg.COS.CompressionLevel
+	case "ftp":
+		return cfg.FTP.CompressionLevel
+	case "sftp":
+		return cfg.SFTP.CompressionLevel
+	case "local":
+		return cfg.Local.CompressionLevel
+	case "azblob":
+		return cfg.AzureBlob.CompressionLevel
+	default:
+		return 0
+	}
+}
+
+// GetCompressionConcurrency - encoder threads for each archive, by default split available CPU cores between upload_concurrency streams
+func (cfg *Config) GetCompressionConcurrency() int {
+	if cfg.General.CompressionConcurrency > 0 {
+		return cfg.General.CompressionConcurrency
+	}
+	concurrency := runtime.NumCPU()
+	if cfg.General.UploadConcurrency > 0 {
+		concurrency /= int(cfg.General.UploadConcurrency)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return concurrency
+}
+
+// GetUploadObjectsConcurrency - how many objects of one table upload simultaneously, by default the same as upload_concurrency
+func (cfg *Config) GetUploadObjectsConcurrency() int {
+	if cfg.General.UploadObjectsConcurrency > 0 {
+		return int(cfg.General.UploadObjectsConcurrency)
+	}
+	return int(cfg.General.UploadConcurrency)
+}
+
+// GetDownloadObjectsConcurrency - how many objects of one table download simultaneously, by default the same as download_concurrency
+func (cfg *Config) GetDownloadObjectsConcurrency() int {
+	if cfg.General.DownloadObjectsConcurrency > 0 {
+		return int(cfg.General.DownloadObjectsConcurrency)
+	}
+	return int(cfg.General.DownloadConcurrency)
+}
+
+// validateCompressionLevel - levels which accepted by each compression library, tar, sz and xz ignore compression_level
+func validateCompressionLevel(format string, level int) error {
+	minLevel, maxLevel := 0, 0
+	switch format {
+	case "gzip", "gz":
+		minLevel, maxLevel = -2, 9
+	case "bzip2", "bz2", "lz4":
+		minLevel, maxLevel = 0, 9
+	case "br", "brotli":
+		minLevel, maxLevel = 0, 11
+	case "zstd":
+		minLevel, maxLevel = 1, 22
+	default:
+		return nil
+	}
+	if level < minLevel || level > maxLevel {
+		return fmt.Errorf("compression_level: %d is invalid for compression_format: %s, allowed range is %d..%d", level, format, minLevel, maxLevel)
+	}
+	return nil
+}
+
+// GetRemoteTimeouts - connect_timeout, upload_timeout and list_timeout from remote_storage section have priority over general->remote_*_timeout, empty means general value
+func (cfg *Config) GetRemoteTimeouts() (time.Duration, time.Duration, time.Duration) {
+	connect, upload, list := cfg.General.RemoteConnectTimeoutDuration, cfg.General.RemoteUploadTimeoutDuration, cfg.General.RemoteListTimeoutDuration
+	var backendTimeouts [3]string
+	switch cfg.General.RemoteStorage {
+	case "s3":
+		backendTimeouts = [3]string{cfg.S3.ConnectTimeout, cfg.S3.UploadTimeout, cfg.S3.ListTimeout}
+	case "gcs":
+		backendTimeouts = [3]string{cfg.GCS.ConnectTimeout, cfg.GCS.UploadTimeout, cfg.GCS.ListTimeout}
+	case "cos":
+		backendTimeouts = [3]string{cfg.COS.ConnectTimeout, cfg.COS.UploadTimeout, cfg.COS.ListTimeout}
+	case "ftp":
+		backendTimeouts = [3]string{cfg.FTP.ConnectTimeout, cfg.FTP.UploadTimeout, cfg.FTP.ListTimeout}
+	case "sftp":
+		backendTimeouts = [3]string{cfg.SFTP.ConnectTimeout, cfg.SFTP.UploadTimeout, cfg.SFTP.ListTimeout}
+	case "azblob":
+		backendTimeouts = [3]string{cfg.AzureBlob.ConnectTimeout, cfg.AzureBlob.UploadTimeout, cfg.AzureBlob.ListTimeout}
+	}
+	for i, timeout := range []*time.Duration{&connect, &upload, &list} {
+		if backendTimeouts[i] == "" {
+			continue
+		}
+		// already validated in ValidateConfig
+		if duration, err := time.ParseDuration(backendTimeouts[i]); err == nil {
+			*timeout = duration
+		}
+	}
+	return connect, upload, list
+}
+
+// GetRemoteProxy - backend `proxy` has priority over general->remote_proxy, empty value means HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used by HTTP based storages
+func (cfg *Config) GetRemoteProxy(backendProxy string) string {
+	if backendProxy != "" {
+		return backendProxy
+	}
+	return cfg.General.RemoteProxy
+}
+
+// validateProxy - proxy URL could contain credentials, so URL itself is not included into error
+func validateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5" {
+		return fmt.Errorf("unsupported scheme '%s', allowed http://, https:// or socks5://", proxyURL.Scheme)
+	}
+	if proxyURL.Hostname() == "" {
+		return fmt.Errorf("empty proxy host")
+	}
+	return nil
+}
+
 var freezeByPartBeginAndRE = regexp.MustCompile(`(?im)^\s*AND\s+`)
 
 // LoadConfig - load config from file + environment variables
@@ -338,12 +666,32 @@ func LoadConfig(configLocation string) (*Config, error) {
 	if (cfg.General.RemoteStorage == "gcs" || cfg.General.RemoteStorage == "azblob" || cfg.General.RemoteStorage == "cos") && cfgWithoutDefault.General.UploadConcurrency == 0 {
 		cfg.General.UploadConcurrency = uint8(runtime.NumCPU() / 2)
 	}
+	cfg.trimRemotePaths()
+
+	// https://github.com/Altinity/clickhouse-backup/issues/855
+	if cfg.ClickHouse.FreezeByPart && cfg.ClickHouse.FreezeByPartWhere != "" && !freezeByPartBeginAndRE.MatchString(cfg.ClickHouse.FreezeByPartWhere) {
+		cfg.ClickHouse.FreezeByPartWhere = " AND " + cfg.ClickHouse.FreezeByPartWhere
+	}
+
+	log_helper.SetLogLevelFromString(cfg.General.LogLevel)
+
+	if err = ValidateConfig(cfg); err != nil {
+		return cfg, err
+	}
+	if err = cfg.SetPriority(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) trimRemotePaths() {
 	cfg.AzureBlob.Path = strings.Trim(cfg.AzureBlob.Path, "/ \t\r\n")
 	cfg.S3.Path = strings.Trim(cfg.S3.Path, "/ \t\r\n")
 	cfg.GCS.Path = strings.Trim(cfg.GCS.Path, "/ \t\r\n")
 	cfg.COS.Path = strings.Trim(cfg.COS.Path, "/ \t\r\n")
 	cfg.FTP.Path = strings.TrimRight(strings.Trim(cfg.FTP.Path, " \t\r\n"), "/")
 	cfg.SFTP.Path = strings.TrimRight(strings.Trim(cfg.SFTP.Path, " \t\r\n"), "/")
+	cfg.Local.Path = strings.TrimRight(strings.Trim(cfg.Local.Path, " \t\r\n"), "/")
 
 	cfg.AzureBlob.ObjectDiskPath = strings.Trim(cfg.AzureBlob.ObjectDiskPath, "/ \t\n")
 	cfg.S3.ObjectDiskPath = strings.Trim(cfg.S3.ObjectDiskPath, "/ \t\r\n")
@@ -351,21 +699,125 @@ func LoadConfig(configLocation string) (*Config, error) {
 	cfg.COS.ObjectDiskPath = strings.Trim(cfg.COS.ObjectDiskPath, "/ \t\r\n")
 	cfg.FTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.FTP.ObjectDiskPath, " \t\r\n"), "/")
 	cfg.SFTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.SFTP.ObjectDiskPath, " \t\r\n"), "/")
+	cfg.Local.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.Local.ObjectDiskPath, " \t\r\n"), "/")
+}
 
-	// https://github.com/Altinity/clickhouse-backup/issues/855
-	if cfg.ClickHouse.FreezeByPart && cfg.ClickHouse.FreezeByPartWhere != "" && !freezeByPartBeginAndRE.MatchString(cfg.ClickHouse.FreezeByPartWhere) {
-		cfg.ClickHouse.FreezeByPartWhere = " AND " + cfg.ClickHouse.FreezeByPartWhere
+// LoadReplicaConfig - replica config file contains only keys which differ from main config, usually one remote storage section, all other settings are inherited
+func (cfg *Config) LoadReplicaConfig(replicaConfigLocation string) (*Config, error) {
+	mainConfigYaml, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	replicaCfg := &Config{}
+	if err = yaml.Unmarshal(mainConfigYaml, replicaCfg); err != nil {
+		return nil, err
 	}
+	replicaConfigYaml, err := os.ReadFile(replicaConfigLocation)
+	if err != nil {
+		return nil, fmt.Errorf("can't open replica config file: %v", err)
+	}
+	if err = yaml.Unmarshal(replicaConfigYaml, replicaCfg); err != nil {
+		return nil, fmt.Errorf("can't parse replica config file %s: %v", replicaConfigLocation, err)
+	}
+	// replica can't have own replicas
+	replicaCfg.General.ReplicaConfigs = nil
+	replicaCfg.trimRemotePaths()
+	if err = ValidateConfig(replicaCfg); err != nil {
+		return nil, fmt.Errorf("invalid replica config %s: %v", replicaConfigLocation, err)
+	}
+	return replicaCfg, nil
+}
 
-	log_helper.SetLogLevelFromString(cfg.General.LogLevel)
+// UpdateConfigFile - set `section.key` values in YAML config file, other keys and comments are kept, file is restored when updated config is not valid
+func UpdateConfigFile(configLocation string, values map[string]string) error {
+	configYaml, err := os.ReadFile(configLocation)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't open config file: %v", err)
+	}
+	fileMode := os.FileMode(0640)
+	if stat, statErr := os.Stat(configLocation); statErr == nil {
+		fileMode = stat.Mode().Perm()
+	}
+	doc := yaml.Node{}
+	if err = yaml.Unmarshal(configYaml, &doc); err != nil {
+		return fmt.Errorf("can't parse config file: %v", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s root is not YAML mapping", configLocation)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		section, key, found := strings.Cut(name, ".")
+		if !found {
+			return fmt.Errorf("invalid config key '%s', expected `section.key`", name)
+		}
+		sectionNode := getYamlMappingValue(root, section)
+		if sectionNode == nil {
+			sectionNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, sectionNode)
+		}
+		// empty section like `s3:` is parsed as null scalar
+		if sectionNode.Kind != yaml.MappingNode {
+			sectionNode.Kind, sectionNode.Tag, sectionNode.Value, sectionNode.Content = yaml.MappingNode, "!!map", "", nil
+		}
+		if valueNode := getYamlMappingValue(sectionNode, key); valueNode != nil {
+			valueNode.Kind, valueNode.Tag, valueNode.Style, valueNode.Value, valueNode.Content = yaml.ScalarNode, "", 0, values[name], nil
+		} else {
+			sectionNode.Content = append(sectionNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &yaml.Node{Kind: yaml.ScalarNode, Value: values[name]})
+		}
+	}
+	var updatedYaml bytes.Buffer
+	encoder := yaml.NewEncoder(&updatedYaml)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("can't encode config file: %v", err)
+	}
+	if err = encoder.Close(); err != nil {
+		return fmt.Errorf("can't encode config file: %v", err)
+	}
+	if err = os.WriteFile(configLocation, updatedYaml.Bytes(), fileMode); err != nil {
+		return fmt.Errorf("can't write config file: %v", err)
+	}
+	if _, err = LoadConfig(configLocation); err != nil {
+		if restoreErr := os.WriteFile(configLocation, configYaml, fileMode); restoreErr != nil {
+			log.Error().Msgf("can't restore config file %s: %v", configLocation, restoreErr)
+		}
+		return fmt.Errorf("updated config is not valid, %s restored: %v", configLocation, err)
+	}
+	return nil
+}
 
-	if err = ValidateConfig(cfg); err != nil {
-		return cfg, err
+func getYamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
 	}
-	if err = cfg.SetPriority(); err != nil {
-		return cfg, err
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
 	}
-	return cfg, nil
+	return nil
+}
+
+// validateGCSStorageClass - empty value means bucket default storage class
+func validateGCSStorageClass(storageClass string) error {
+	if storageClass == "" {
+		return nil
+	}
+	for _, gcsStorageClass := range GCSStorageClasses {
+		if strings.EqualFold(storageClass, gcsStorageClass) {
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' is bad storage class, select one of: %#v", storageClass, GCSStorageClasses)
 }
 
 func ValidateConfig(cfg *Config) error {
@@ -378,12 +830,27 @@ func ValidateConfig(cfg *Config) error {
 			cfg.FTP.Concurrency, cfg.General.DownloadConcurrency, cfg.General.UploadConcurrency,
 		)
 	}
+	if cfg.General.RemoteStorage == "local" && cfg.Local.Path == "" {
+		return fmt.Errorf("local->path shall be not empty when general->remote_storage: local")
+	}
 	if cfg.GetCompressionFormat() == "lz4" {
 		return fmt.Errorf("clickhouse already compressed data by lz4")
 	}
 	if _, ok := ArchiveExtensions[cfg.GetCompressionFormat()]; !ok && cfg.GetCompressionFormat() != "none" {
 		return fmt.Errorf("'%s' is unsupported compression format", cfg.GetCompressionFormat())
 	}
+	if err := validateCompressionLevel(cfg.GetCompressionFormat(), cfg.GetCompressionLevel()); err != nil {
+		return err
+	}
+	if cfg.ClickHouse.FreezeConcurrency < 0 {
+		return fmt.Errorf("clickhouse->freeze_concurrency: %d shall be zero or positive", cfg.ClickHouse.FreezeConcurrency)
+	}
+	if cfg.ClickHouse.RestoreConcurrency < 0 {
+		return fmt.Errorf("clickhouse->restore_concurrency: %d shall be zero or positive", cfg.ClickHouse.RestoreConcurrency)
+	}
+	if cfg.General.CompressionConcurrency < 0 {
+		return fmt.Errorf("compression_concurrency: %d shall be zero or positive", cfg.General.CompressionConcurrency)
+	}
 	if timeout, err := time.ParseDuration(cfg.ClickHouse.Timeout); err != nil {
 		return fmt.Errorf("invalid clickhouse timeout: %v", err)
 	} else {
@@ -391,12 +858,39 @@ func ValidateConfig(cfg *Config) error {
 			return fmt.Errorf("clickhouse `timeout: %v`, not enough for `use_embedded_backup_restore: true`", cfg.ClickHouse.Timeout)
 		}
 	}
+	if keeperSnapshotTimeout, err := time.ParseDuration(cfg.ClickHouse.KeeperSnapshotTimeout); err != nil {
+		return fmt.Errorf("invalid clickhouse keeper_snapshot_timeout: %v", err)
+	} else {
+		cfg.ClickHouse.KeeperSnapshotTimeoutDuration = keeperSnapshotTimeout
+	}
+	switch cfg.General.MaxPartsCountAction {
+	case "", "error", "warn":
+	default:
+		return fmt.Errorf("invalid general max_parts_count_action: '%s', allowed values 'error' or 'warn'", cfg.General.MaxPartsCountAction)
+	}
+	if cfg.General.UploadDiffFromLatestRemote && !cfg.General.UploadByPart {
+		return fmt.Errorf("`upload_diff_from_latest_remote: %v` require `upload_by_part: true` in `general` config section", cfg.General.UploadDiffFromLatestRemote)
+	}
+	if cfg.General.UploadDiffMaxChainLength < 0 {
+		return fmt.Errorf("invalid general upload_diff_max_chain_length: %d, shall be 0 or greater", cfg.General.UploadDiffMaxChainLength)
+	}
+	if cfg.ClickHouse.EmbeddedMetadataLocalPath != "" && (!cfg.ClickHouse.UseEmbeddedBackupRestore || cfg.ClickHouse.EmbeddedBackupDisk != "") {
+		return fmt.Errorf("`embedded_metadata_local_path` requires `use_embedded_backup_restore: true` and empty `embedded_backup_disk` in `clickhouse` config section")
+	}
 	if cfg.ClickHouse.FreezeByPart && cfg.ClickHouse.UseEmbeddedBackupRestore {
 		return fmt.Errorf("`freeze_by_part: %v` is not compatible with `use_embedded_backup_restore: %v`", cfg.ClickHouse.FreezeByPart, cfg.ClickHouse.UseEmbeddedBackupRestore)
 	}
 	if _, err := time.ParseDuration(cfg.COS.Timeout); err != nil {
 		return fmt.Errorf("invalid cos timeout: %v", err)
 	}
+	if cfg.COS.PartSize < 1024*1024 || cfg.COS.PartSize > 5*1024*1024*1024 {
+		return fmt.Errorf("cos->part_size: %d shall be between 1MiB and 5GiB", cfg.COS.PartSize)
+	}
+	if duration, err := time.ParseDuration(cfg.COS.RetryInterval); err != nil {
+		return fmt.Errorf("invalid cos->retry_interval: %v", err)
+	} else {
+		cfg.COS.RetryIntervalDuration = duration
+	}
 	if _, err := time.ParseDuration(cfg.FTP.Timeout); err != nil {
 		return fmt.Errorf("invalid ftp timeout: %v", err)
 	}
@@ -422,6 +916,62 @@ func ValidateConfig(cfg *Config) error {
 		return fmt.Errorf("'%s' is bad S3_STORAGE_CLASS, select one of: %#v",
 			cfg.S3.StorageClass, allStorageClasses.Values())
 	}
+	// custom_storage_class_map values replace storage_class during upload, so shall pass the same check
+	if !cfg.S3.UseCustomStorageClass {
+		for pattern, customStorageClass := range cfg.S3.CustomStorageClassMap {
+			customStorageClassOk := false
+			for _, storageClass := range allStorageClasses.Values() {
+				if s3types.StorageClass(strings.ToUpper(customStorageClass)) == storageClass {
+					customStorageClassOk = true
+					break
+				}
+			}
+			if !customStorageClassOk {
+				return fmt.Errorf("'%s' is bad storage class for '%s' in S3_CUSTOM_STORAGE_CLASS_MAP, select one of: %#v", customStorageClass, pattern, allStorageClasses.Values())
+			}
+		}
+	}
+	if cfg.S3.RestoreTier != "" && !strings.EqualFold(cfg.S3.RestoreTier, string(s3types.TierExpedited)) && !strings.EqualFold(cfg.S3.RestoreTier, string(s3types.TierStandard)) && !strings.EqualFold(cfg.S3.RestoreTier, string(s3types.TierBulk)) {
+		return fmt.Errorf("'%s' is bad S3_RESTORE_TIER, select one of: Expedited, Standard, Bulk", cfg.S3.RestoreTier)
+	}
+	if err := validateGCSStorageClass(cfg.GCS.StorageClass); err != nil {
+		return fmt.Errorf("%v, GCS_STORAGE_CLASS", err)
+	}
+	for pattern, customStorageClass := range cfg.GCS.CustomStorageClassMap {
+		if err := validateGCSStorageClass(customStorageClass); err != nil {
+			return fmt.Errorf("%v, for '%s' in GCS_CUSTOM_STORAGE_CLASS_MAP", err, pattern)
+		}
+	}
+	if cfg.GCS.EncryptionKeyName != "" && !strings.HasPrefix(cfg.GCS.EncryptionKeyName, "projects/") {
+		return fmt.Errorf("gcs->encryption_key_name: '%s' shall have format projects/<project>/locations/<location>/keyRings/<key_ring>/cryptoKeys/<key>", cfg.GCS.EncryptionKeyName)
+	}
+	if duration, err := time.ParseDuration(cfg.GCS.ChunkRetryDeadline); err != nil {
+		return fmt.Errorf("invalid gcs->chunk_retry_deadline: %v", err)
+	} else {
+		cfg.GCS.ChunkRetryDeadlineDuration = duration
+	}
+	if cfg.S3.MaxPartsCount < 1 || cfg.S3.MaxPartsCount > 10000 {
+		return fmt.Errorf("s3->max_parts_count: %d shall be between 1 and 10000", cfg.S3.MaxPartsCount)
+	}
+	if (cfg.S3.TLSCert == "") != (cfg.S3.TLSKey == "") {
+		return fmt.Errorf("s3->tls_cert and s3->tls_key shall be defined together")
+	}
+	if cfg.S3.RestoreDays < 1 {
+		return fmt.Errorf("s3->restore_days: %d shall be positive", cfg.S3.RestoreDays)
+	}
+	if duration, err := time.ParseDuration(cfg.S3.RestoreMaxWait); err != nil {
+		return fmt.Errorf("invalid s3->restore_max_wait: %v", err)
+	} else {
+		cfg.S3.RestoreMaxWaitDuration = duration
+	}
+	if cfg.AzureBlob.RehydratePriority != "" && !strings.EqualFold(cfg.AzureBlob.RehydratePriority, "Standard") && !strings.EqualFold(cfg.AzureBlob.RehydratePriority, "High") {
+		return fmt.Errorf("'%s' is bad AZBLOB_REHYDRATE_PRIORITY, select one of: Standard, High", cfg.AzureBlob.RehydratePriority)
+	}
+	if duration, err := time.ParseDuration(cfg.AzureBlob.RehydrateMaxWait); err != nil {
+		return fmt.Errorf("invalid azblob->rehydrate_max_wait: %v", err)
+	} else {
+		cfg.AzureBlob.RehydrateMaxWaitDuration = duration
+	}
 	if cfg.S3.AllowMultipartDownload && cfg.S3.Concurrency == 1 {
 		return fmt.Errorf(
 			"`allow_multipart_download` require `concurrency` in `s3` section more than 1 (3-4 recommends) current value: %d",
@@ -449,6 +999,75 @@ func ValidateConfig(cfg *Config) error {
 	} else {
 		return fmt.Errorf("empty custom command timeout")
 	}
+	if cfg.Notifications.Timeout != "" {
+		if duration, err := time.ParseDuration(cfg.Notifications.Timeout); err != nil {
+			return fmt.Errorf("invalid notifications timeout: %v", err)
+		} else {
+			cfg.Notifications.TimeoutDuration = duration
+		}
+	}
+	if cfg.General.FreeSpaceReserve != "" {
+		if reserve, err := utils.ParseBytes(cfg.General.FreeSpaceReserve); err != nil {
+			return fmt.Errorf("invalid general->free_space_reserve: %v", err)
+		} else {
+			cfg.General.FreeSpaceReserveBytes = reserve
+		}
+	}
+	if cfg.General.MaxTableDataSize != "" {
+		if maxTableDataSize, err := utils.ParseBytes(cfg.General.MaxTableDataSize); err != nil {
+			return fmt.Errorf("invalid general->max_table_data_size: %v", err)
+		} else {
+			cfg.General.MaxTableDataSizeBytes = maxTableDataSize
+		}
+	}
+	if cfg.Hooks.Timeout != "" {
+		if duration, err := time.ParseDuration(cfg.Hooks.Timeout); err != nil {
+			return fmt.Errorf("invalid hooks timeout: %v", err)
+		} else {
+			cfg.Hooks.TimeoutDuration = duration
+		}
+	}
+	if cfg.Notifications.Template != "" {
+		if _, err := template.New("notification").Parse(cfg.Notifications.Template); err != nil {
+			return fmt.Errorf("invalid notifications template: %v", err)
+		}
+	}
+	if retentionLocal, err := ParseRetentionPolicy(cfg.General.BackupsToKeepDaysLocal, cfg.General.BackupsToKeepGFSLocal); err != nil {
+		return fmt.Errorf("invalid local retention: %v", err)
+	} else {
+		cfg.General.RetentionLocal = retentionLocal
+	}
+	if retentionRemote, err := ParseRetentionPolicy(cfg.General.BackupsToKeepDaysRemote, cfg.General.BackupsToKeepGFSRemote); err != nil {
+		return fmt.Errorf("invalid remote retention: %v", err)
+	} else {
+		cfg.General.RetentionRemote = retentionRemote
+	}
+	if retentionIgnoreTags, err := metadata.ParseUserTags(cfg.General.RetentionIgnoreTags); err != nil {
+		return fmt.Errorf("invalid retention_ignore_tags: %v", err)
+	} else {
+		cfg.General.RetentionLocal.IgnoreTags = retentionIgnoreTags
+		cfg.General.RetentionRemote.IgnoreTags = retentionIgnoreTags
+	}
+	if cfg.General.RetentionTimezone != "" {
+		if location, err := time.LoadLocation(cfg.General.RetentionTimezone); err != nil {
+			return fmt.Errorf("invalid retention_timezone: %v", err)
+		} else {
+			cfg.General.RetentionTimeLocation = location
+		}
+	}
+	if cfg.S3.ObjectLockMode != "" {
+		cfg.S3.ObjectLockMode = strings.ToUpper(cfg.S3.ObjectLockMode)
+		if cfg.S3.ObjectLockMode != string(s3types.ObjectLockModeGovernance) && cfg.S3.ObjectLockMode != string(s3types.ObjectLockModeCompliance) {
+			return fmt.Errorf("'%s' is bad S3_OBJECT_LOCK_MODE, select one of: GOVERNANCE, COMPLIANCE", cfg.S3.ObjectLockMode)
+		}
+		// retain-until date is derived from remote retention policy, when not defined explicitly
+		if cfg.S3.ObjectLockDays == 0 {
+			cfg.S3.ObjectLockDays = cfg.General.RetentionRemote.KeepDays
+		}
+		if cfg.S3.ObjectLockDays <= 0 {
+			return fmt.Errorf("s3->object_lock_mode: %s requires positive s3->object_lock_days or general->backups_to_keep_days_remote", cfg.S3.ObjectLockMode)
+		}
+	}
 	if cfg.General.RetriesPause != "" {
 		if duration, err := time.ParseDuration(cfg.General.RetriesPause); err != nil {
 			return fmt.Errorf("invalid retries pause: %v", err)
@@ -458,6 +1077,70 @@ func ValidateConfig(cfg *Config) error {
 	} else {
 		return fmt.Errorf("empty retries pause")
 	}
+	if cfg.General.RetriesBackoff != "constant" && cfg.General.RetriesBackoff != "exponential" {
+		return fmt.Errorf("invalid retries_backoff: '%s', allowed values 'constant' or 'exponential'", cfg.General.RetriesBackoff)
+	}
+	if duration, err := time.ParseDuration(cfg.General.RetriesMaxPause); err != nil {
+		return fmt.Errorf("invalid retries_max_pause: %v", err)
+	} else {
+		cfg.General.RetriesMaxDuration = duration
+	}
+	if cfg.General.RetriesJitter < 0 || cfg.General.RetriesJitter > 1 {
+		return fmt.Errorf("retries_jitter: %v shall be between 0 and 1", cfg.General.RetriesJitter)
+	}
+	if cfg.General.ReplicaUploadMode != "sequential" && cfg.General.ReplicaUploadMode != "parallel" {
+		return fmt.Errorf("replica_upload_mode: '%s' is unknown, shall be sequential or parallel", cfg.General.ReplicaUploadMode)
+	}
+	if len(cfg.General.ReplicaConfigs) > 0 && (cfg.General.RemoteStorage == "none" || cfg.General.RemoteStorage == "custom") {
+		return fmt.Errorf("replica_configs is not supported for remote_storage: %s", cfg.General.RemoteStorage)
+	}
+	for _, remoteTimeout := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"remote_connect_timeout", cfg.General.RemoteConnectTimeout, &cfg.General.RemoteConnectTimeoutDuration},
+		{"remote_upload_timeout", cfg.General.RemoteUploadTimeout, &cfg.General.RemoteUploadTimeoutDuration},
+		{"remote_list_timeout", cfg.General.RemoteListTimeout, &cfg.General.RemoteListTimeoutDuration},
+	} {
+		if duration, err := time.ParseDuration(remoteTimeout.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", remoteTimeout.name, err)
+		} else {
+			*remoteTimeout.duration = duration
+		}
+	}
+	for _, backendTimeout := range []struct {
+		name  string
+		value string
+	}{
+		{"s3->connect_timeout", cfg.S3.ConnectTimeout}, {"s3->upload_timeout", cfg.S3.UploadTimeout}, {"s3->list_timeout", cfg.S3.ListTimeout},
+		{"gcs->connect_timeout", cfg.GCS.ConnectTimeout}, {"gcs->upload_timeout", cfg.GCS.UploadTimeout}, {"gcs->list_timeout", cfg.GCS.ListTimeout},
+		{"cos->connect_timeout", cfg.COS.ConnectTimeout}, {"cos->upload_timeout", cfg.COS.UploadTimeout}, {"cos->list_timeout", cfg.COS.ListTimeout},
+		{"ftp->connect_timeout", cfg.FTP.ConnectTimeout}, {"ftp->upload_timeout", cfg.FTP.UploadTimeout}, {"ftp->list_timeout", cfg.FTP.ListTimeout},
+		{"sftp->connect_timeout", cfg.SFTP.ConnectTimeout}, {"sftp->upload_timeout", cfg.SFTP.UploadTimeout}, {"sftp->list_timeout", cfg.SFTP.ListTimeout},
+		{"azblob->connect_timeout", cfg.AzureBlob.ConnectTimeout}, {"azblob->upload_timeout", cfg.AzureBlob.UploadTimeout}, {"azblob->list_timeout", cfg.AzureBlob.ListTimeout},
+	} {
+		if backendTimeout.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(backendTimeout.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", backendTimeout.name, err)
+		}
+	}
+	for _, remoteProxy := range []struct {
+		name  string
+		value string
+	}{
+		{"general->remote_proxy", cfg.General.RemoteProxy},
+		{"s3->proxy", cfg.S3.Proxy},
+		{"gcs->proxy", cfg.GCS.Proxy},
+		{"azblob->proxy", cfg.AzureBlob.Proxy},
+		{"ftp->proxy", cfg.FTP.Proxy},
+	} {
+		if err := validateProxy(remoteProxy.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", remoteProxy.name, err)
+		}
+	}
 	if cfg.General.WatchInterval != "" {
 		if duration, err := time.ParseDuration(cfg.General.WatchInterval); err != nil {
 			return fmt.Errorf("invalid watch interval: %v", err)
@@ -465,6 +1148,61 @@ func ValidateConfig(cfg *Config) error {
 			cfg.General.WatchDuration = duration
 		}
 	}
+	if cfg.API.BackupMetricsUpdateInterval != "" {
+		if duration, err := time.ParseDuration(cfg.API.BackupMetricsUpdateInterval); err != nil {
+			return fmt.Errorf("invalid api backup metrics update interval: %v", err)
+		} else {
+			cfg.API.BackupMetricsUpdateDuration = duration
+		}
+	}
+	if cfg.API.RouteQueueTimeout != "" {
+		if duration, err := time.ParseDuration(cfg.API.RouteQueueTimeout); err != nil {
+			return fmt.Errorf("invalid api route queue timeout: %v", err)
+		} else {
+			cfg.API.RouteQueueTimeoutDuration = duration
+		}
+	}
+	if cfg.API.IdempotencyKeyTTL != "" {
+		if duration, err := time.ParseDuration(cfg.API.IdempotencyKeyTTL); err != nil {
+			return fmt.Errorf("invalid api idempotency key ttl: %v", err)
+		} else {
+			cfg.API.IdempotencyKeyTTLDuration = duration
+		}
+	}
+	if cfg.API.PeersTimeout != "" {
+		if duration, err := time.ParseDuration(cfg.API.PeersTimeout); err != nil {
+			return fmt.Errorf("invalid api peers timeout: %v", err)
+		} else {
+			cfg.API.PeersTimeoutDuration = duration
+		}
+	}
+	switch cfg.API.SharedLock {
+	case "", "none", "keeper":
+	default:
+		return fmt.Errorf("invalid api shared_lock: '%s', allowed values 'none' or 'keeper'", cfg.API.SharedLock)
+	}
+	switch strings.ToLower(cfg.API.TimeFormat) {
+	case "", "default", "rfc3339":
+	default:
+		if time.Unix(0, 0).UTC().Format(cfg.API.TimeFormat) == cfg.API.TimeFormat {
+			return fmt.Errorf("invalid api time_format: %s, allowed values `default`, `rfc3339` or Go time layout", cfg.API.TimeFormat)
+		}
+	}
+	if cfg.API.Timezone != "" {
+		if location, err := time.LoadLocation(cfg.API.Timezone); err != nil {
+			return fmt.Errorf("invalid api timezone: %v", err)
+		} else {
+			cfg.API.TimeLocation = location
+		}
+	}
+	for name, spec := range map[string]string{"schedule_full": cfg.API.ScheduleFull, "schedule_incremental": cfg.API.ScheduleIncremental, "schedule_retention": cfg.API.ScheduleRetention} {
+		if spec == "" {
+			continue
+		}
+		if _, err := schedule.Parse(spec); err != nil {
+			return fmt.Errorf("invalid api %s: %v", name, err)
+		}
+	}
 	if cfg.General.FullInterval != "" {
 		if duration, err := time.ParseDuration(cfg.General.FullInterval); err != nil {
 			return fmt.Errorf("invalid full interval for watch: %v", err)
@@ -472,6 +1210,13 @@ func ValidateConfig(cfg *Config) error {
 			cfg.General.FullDuration = duration
 		}
 	}
+	if cfg.General.UploadDiffFullInterval != "" {
+		if duration, err := time.ParseDuration(cfg.General.UploadDiffFullInterval); err != nil {
+			return fmt.Errorf("invalid general upload_diff_full_interval: %v", err)
+		} else {
+			cfg.General.UploadDiffFullDuration = duration
+		}
+	}
 	return nil
 }
 
@@ -489,6 +1234,8 @@ func ValidateObjectDiskConfig(cfg *Config) error {
 			return fmt.Errorf("data in objects disks, invalid ftp->object_disk_path config section, shall be not empty and shall not be prefix for ftp->path")
 		} else if cfg.General.RemoteStorage == "sftp" && ((cfg.SFTP.ObjectDiskPath == "" && cfg.SFTP.Path == "") || (cfg.SFTP.Path != "" && strings.HasPrefix(cfg.SFTP.Path, cfg.SFTP.ObjectDiskPath))) {
 			return fmt.Errorf("data in objects disks, invalid sftp->object_disk_path config section, shall be not empty and shall not be prefix for sftp->path")
+		} else if cfg.General.RemoteStorage == "local" && ((cfg.Local.ObjectDiskPath == "" && cfg.Local.Path == "") || (cfg.Local.Path != "" && strings.HasPrefix(cfg.Local.Path, cfg.Local.ObjectDiskPath))) {
+			return fmt.Errorf("data in objects disks, invalid local->object_disk_path config section, shall be not empty and shall not be prefix for local->path")
 		}
 	}
 	return nil
@@ -538,17 +1285,33 @@ func DefaultConfig() *Config {
 			RetriesOnFailure:                    3,
 			RetriesPause:                        "5s",
 			RetriesDuration:                     5 * time.Second,
+			RetriesBackoff:                      "constant",
+			RetriesMaxPause:                     "5m",
+			RetriesMaxDuration:                  5 * time.Minute,
+			RetriesJitter:                       0,
+			RemoteConnectTimeout:                "1m",
+			RemoteConnectTimeoutDuration:        time.Minute,
+			RemoteUploadTimeout:                 "4h",
+			RemoteUploadTimeoutDuration:         4 * time.Hour,
+			RemoteListTimeout:                   "1h",
+			RemoteListTimeoutDuration:           time.Hour,
+			ReplicaUploadMode:                   "sequential",
 			WatchInterval:                       "1h",
 			WatchDuration:                       1 * time.Hour,
 			FullInterval:                        "24h",
 			FullDuration:                        24 * time.Hour,
+			UploadDiffFullInterval:              "24h",
+			UploadDiffFullDuration:              24 * time.Hour,
 			WatchBackupNameTemplate:             "shard{shard}-{type}-{time:20060102150405}",
+			FreeSpaceReserve:                    "1GiB",
+			FreeSpaceReserveBytes:               1 << 30,
 			RestoreDatabaseMapping:              make(map[string]string),
 			RestoreTableMapping:                 make(map[string]string),
 			IONicePriority:                      "idle",
 			CPUNicePriority:                     15,
 			RBACBackupAlways:                    true,
 			RBACConflictResolution:              "recreate",
+			MaxPartsCountAction:                 "error",
 		},
 		ClickHouse: ClickHouseConfig{
 			Username: "default",
@@ -561,36 +1324,48 @@ func DefaultConfig() *Config {
 				"information_schema.*",
 				"_temporary_and_external_tables.*",
 			},
+			SkipTableEnginesSchemaOnly:       []string{"Kafka", "RabbitMQ", "MySQL", "LiveView"},
 			Timeout:                          "30m",
 			SyncReplicatedTables:             false,
 			LogSQLQueries:                    true,
 			ConfigDir:                        "/etc/clickhouse-server/",
 			RestartCommand:                   "exec:systemctl restart clickhouse-server",
+			KeeperSnapshotTimeout:            "5m",
 			IgnoreNotExistsErrorDuringFreeze: true,
+			CleanShadowAfterFreeze:           true,
 			CheckReplicasBeforeAttach:        true,
+			CheckReplicasBeforeBackup:        false,
+			CheckReplicasMaxAbsoluteDelay:    300,
+			CheckReplicasMaxQueueErrors:      0,
 			UseEmbeddedBackupRestore:         false,
 			BackupMutations:                  true,
 			RestoreAsAttach:                  false,
 			CheckPartsColumns:                true,
 			DefaultReplicaPath:               "/clickhouse/tables/{cluster}/{shard}/{database}/{table}",
 			DefaultReplicaName:               "{replica}",
+			ReplicationPathMapping:           make(map[string]string),
+			StoragePolicyMapping:             make(map[string]string),
 			MaxConnections:                   int(downloadConcurrency),
 		},
 		AzureBlob: AzureBlobConfig{
-			EndpointSchema:    "https",
-			EndpointSuffix:    "core.windows.net",
-			CompressionLevel:  1,
-			CompressionFormat: "tar",
-			BufferSize:        0,
-			MaxBuffers:        3,
-			MaxPartsCount:     256,
-			Timeout:           "4h",
+			EndpointSchema:           "https",
+			EndpointSuffix:           "core.windows.net",
+			CompressionLevel:         1,
+			CompressionFormat:        "tar",
+			BufferSize:               0,
+			MaxBuffers:               3,
+			MaxPartsCount:            256,
+			Timeout:                  "4h",
+			RehydratePriority:        "Standard",
+			RehydrateMaxWait:         "24h",
+			RehydrateMaxWaitDuration: 24 * time.Hour,
 		},
 		S3: S3Config{
 			Region:                  "us-east-1",
 			DisableSSL:              false,
 			ACL:                     "private",
 			AssumeRoleARN:           "",
+			AssumeRoleSessionName:   "clickhouse-backup",
 			CompressionLevel:        1,
 			CompressionFormat:       "tar",
 			DisableCertVerification: false,
@@ -599,26 +1374,50 @@ func DefaultConfig() *Config {
 			Concurrency:             int(downloadConcurrency + 1),
 			PartSize:                0,
 			MaxPartsCount:           4000,
+			RestoreDays:             1,
+			RestoreMaxWait:          "48h",
+			RestoreMaxWaitDuration:  48 * time.Hour,
 		},
 		GCS: GCSConfig{
-			CompressionLevel:  1,
-			CompressionFormat: "tar",
-			StorageClass:      "STANDARD",
-			ClientPoolSize:    int(max(uploadConcurrency*3, downloadConcurrency*3, objectDiskServerSideCopyConcurrency)),
+			CompressionLevel:           1,
+			CompressionFormat:          "tar",
+			StorageClass:               "STANDARD",
+			ClientPoolSize:             int(max(uploadConcurrency*3, downloadConcurrency*3, objectDiskServerSideCopyConcurrency)),
+			ChunkRetryDeadline:         "1h",
+			ChunkRetryDeadlineDuration: time.Hour,
 		},
 		COS: COSConfig{
-			RowURL:            "",
-			Timeout:           "2m",
-			SecretID:          "",
-			SecretKey:         "",
-			Path:              "",
-			CompressionFormat: "tar",
-			CompressionLevel:  1,
+			RowURL:                "",
+			Timeout:               "2m",
+			SecretID:              "",
+			SecretKey:             "",
+			Path:                  "",
+			CompressionFormat:     "tar",
+			CompressionLevel:      1,
+			PartSize:              16 * 1024 * 1024,
+			Concurrency:           int(uploadConcurrency),
+			RetryCount:            3,
+			RetryInterval:         "1s",
+			RetryIntervalDuration: time.Second,
 		},
 		API: APIConfig{
 			ListenAddr:                    "localhost:7171",
 			EnableMetrics:                 true,
 			CompleteResumableAfterRestart: true,
+			BackupMetricsUpdateInterval:   "1h",
+			BackupMetricsUpdateDuration:   time.Hour,
+			RouteRateLimits:               make(map[string]float64),
+			RouteQueueTimeout:             "10s",
+			RouteQueueTimeoutDuration:     10 * time.Second,
+			IdempotencyKeyTTL:             "24h",
+			IdempotencyKeyTTLDuration:     24 * time.Hour,
+			SharedLock:                    "none",
+			SharedLockPath:                "clickhouse-backup/{shard}/{replica}/api_lock",
+			Peers:                         []string{},
+			PeersTimeout:                  "30s",
+			PeersTimeoutDuration:          30 * time.Second,
+			PushgatewayJob:                "clickhouse-backup",
+			TimeFormat:                    "default",
 		},
 		FTP: FTPConfig{
 			Timeout:           "2m",
@@ -627,15 +1426,30 @@ func DefaultConfig() *Config {
 			CompressionLevel:  1,
 		},
 		SFTP: SFTPConfig{
-			Port:              22,
+			Port:               22,
+			CompressionFormat:  "tar",
+			CompressionLevel:   1,
+			Concurrency:        int(downloadConcurrency * 3),
+			ConnectionPoolSize: int(max(uploadConcurrency, downloadConcurrency)),
+		},
+		Local: LocalConfig{
 			CompressionFormat: "tar",
 			CompressionLevel:  1,
-			Concurrency:       int(downloadConcurrency * 3),
 		},
 		Custom: CustomConfig{
 			CommandTimeout:         "4h",
 			CommandTimeoutDuration: 4 * time.Hour,
 		},
+		Notifications: NotificationsConfig{
+			TelegramAPIURL:  "https://api.telegram.org",
+			OnFailure:       true,
+			Timeout:         "30s",
+			TimeoutDuration: 30 * time.Second,
+		},
+		Hooks: HooksConfig{
+			Timeout:         "5m",
+			TimeoutDuration: 5 * time.Minute,
+		},
 	}
 }
 
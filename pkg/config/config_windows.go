@@ -0,0 +1,12 @@
+package config
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+func (cfg *Config) SetPriority() error {
+	if cfg.General.IONicePriority != "" || cfg.General.CPUNicePriority != 0 {
+		log.Debug().Msg("cpu_nice_priority and io_nice_priority are not supported on windows, ignored")
+	}
+	return nil
+}
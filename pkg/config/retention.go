@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RetentionPolicy - backup is kept when at least one rule keeps it, zero value means rule is disabled
+type RetentionPolicy struct {
+	KeepLast int
+	KeepDays int
+	Daily    int
+	Weekly   int
+	Monthly  int
+	// IgnoreTags - backups with any of these `key=value` user tags are kept and don't occupy retention slots, the same as pinned
+	IgnoreTags map[string]string
+}
+
+// IsCalendarBased - true when `backups_to_keep_days_*` or `backups_to_keep_gfs_*` defined
+func (p RetentionPolicy) IsCalendarBased() bool {
+	return p.KeepDays > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+// IsEnabled - false means all backups shall be kept
+func (p RetentionPolicy) IsEnabled() bool {
+	return p.KeepLast > 0 || p.IsCalendarBased()
+}
+
+// WithKeepLast - `backups_to_keep_*` has special meaning for negative values, so it calculates by caller
+func (p RetentionPolicy) WithKeepLast(keepLast int) RetentionPolicy {
+	p.KeepLast = keepLast
+	return p
+}
+
+// ParseRetentionPolicy - parse `backups_to_keep_days_*` and `backups_to_keep_gfs_*` in `daily=7,weekly=4,monthly=12` format
+func ParseRetentionPolicy(keepDays int, gfs string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{KeepDays: keepDays}
+	if keepDays < 0 {
+		return policy, fmt.Errorf("keep days shall be positive or 0, current value: %d", keepDays)
+	}
+	for _, rule := range strings.Split(gfs, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		nameAndValue := strings.SplitN(rule, "=", 2)
+		if len(nameAndValue) != 2 {
+			return policy, fmt.Errorf("invalid GFS rule `%s`, expected format `daily=7,weekly=4,monthly=12`", rule)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(nameAndValue[1]))
+		if err != nil || value < 0 {
+			return policy, fmt.Errorf("invalid GFS rule `%s`, value shall be positive integer", rule)
+		}
+		switch strings.ToLower(strings.TrimSpace(nameAndValue[0])) {
+		case "daily":
+			policy.Daily = value
+		case "weekly":
+			policy.Weekly = value
+		case "monthly":
+			policy.Monthly = value
+		default:
+			return policy, fmt.Errorf("invalid GFS rule `%s`, allowed daily, weekly, monthly", rule)
+		}
+	}
+	return policy, nil
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
-	"github.com/eapache/go-resiliency/retrier"
 	"github.com/rs/zerolog/log"
 	"time"
 )
@@ -34,7 +34,7 @@ func Download(ctx context.Context, cfg *config.Config, backupName string, tableP
 		"schema":        schemaOnly,
 	}
 	args := ApplyCommandTemplate(cfg.Custom.DownloadCommand, templateData)
-	retry := retrier.New(retrier.ConstantBackoff(cfg.General.RetriesOnFailure, cfg.General.RetriesDuration), nil)
+	retry := storage.NewRetrier(&cfg.General)
 	err := retry.RunCtx(ctx, func(ctx context.Context) error {
 		return utils.ExecCmd(ctx, cfg.Custom.CommandTimeoutDuration, args[0], args[1:]...)
 	})
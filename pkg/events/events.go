@@ -0,0 +1,96 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	Created         = "created"
+	Uploaded        = "uploaded"
+	Downloaded      = "downloaded"
+	Deleted         = "deleted"
+	RestoreStarted  = "restore_started"
+	RestoreFinished = "restore_finished"
+)
+
+// historySize - how many last events are kept for subscribers which reconnect with Last-Event-ID
+const historySize = 100
+
+// subscriberBufferSize - slow subscriber loses events instead of blocking backup operations
+const subscriberBufferSize = 64
+
+// Event - backup lifecycle event, published by backup package and streamed by `GET /backup/events`
+type Event struct {
+	Id         uint64 `json:"id"`
+	Type       string `json:"type"`
+	BackupName string `json:"backup_name"`
+	Location   string `json:"location,omitempty"`
+	Time       string `json:"time"`
+	Error      string `json:"error,omitempty"`
+}
+
+var Current = NewBroker()
+
+// Broker - in-memory publish/subscribe for lifecycle events inside one clickhouse-backup process
+type Broker struct {
+	lastId      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		history:     make([]Event, 0, historySize),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish - assign id and time, never blocks
+func (b *Broker) Publish(eventType, backupName, location string, err error) Event {
+	b.Lock()
+	defer b.Unlock()
+	b.lastId++
+	event := Event{
+		Id:         b.lastId,
+		Type:       eventType,
+		BackupName: backupName,
+		Location:   location,
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if len(b.history) == historySize {
+		b.history = b.history[1:]
+	}
+	b.history = append(b.history, event)
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	return event
+}
+
+// Subscribe - return events with id greater than afterId from history and channel for new events, unsubscribe shall be called when subscriber done
+func (b *Broker) Subscribe(afterId uint64) ([]Event, <-chan Event, func()) {
+	b.Lock()
+	defer b.Unlock()
+	missed := make([]Event, 0)
+	for _, event := range b.history {
+		if event.Id > afterId {
+			missed = append(missed, event)
+		}
+	}
+	subscriber := make(chan Event, subscriberBufferSize)
+	b.subscribers[subscriber] = struct{}{}
+	unsubscribe := func() {
+		b.Lock()
+		defer b.Unlock()
+		delete(b.subscribers, subscriber)
+	}
+	return missed, subscriber, unsubscribe
+}
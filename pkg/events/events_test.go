@@ -0,0 +1,31 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	broker.Publish(Created, "backup1", "local", nil)
+
+	missed, subscriber, unsubscribe := broker.Subscribe(0)
+	if len(missed) != 1 || missed[0].Type != Created || missed[0].Id != 1 {
+		t.Fatalf("unexpected missed events %+v", missed)
+	}
+	broker.Publish(RestoreFinished, "backup1", "local", fmt.Errorf("restore error"))
+	event := <-subscriber
+	if event.Id != 2 || event.Type != RestoreFinished || event.Error != "restore error" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	unsubscribe()
+
+	for i := 0; i < historySize+subscriberBufferSize+10; i++ {
+		broker.Publish(Deleted, fmt.Sprintf("backup%d", i), "remote", nil)
+	}
+	missed, _, unsubscribe = broker.Subscribe(2)
+	defer unsubscribe()
+	if len(missed) != historySize || missed[len(missed)-1].Id != broker.lastId {
+		t.Fatalf("history shall be limited by %d, got %d events", historySize, len(missed))
+	}
+}
@@ -1,6 +1,7 @@
 package filesystemhelper
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/url"
@@ -16,6 +17,7 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	recursiveCopy "github.com/otiai10/copy"
 	"github.com/rs/zerolog/log"
 )
 
@@ -190,7 +192,13 @@ func HardlinkBackupPartsToStorage(backupName string, backupTable metadata.TableM
 				}
 				log.Debug().Msgf("Link %s -> %s", filePath, dstFilePath)
 				if err := os.Link(filePath, dstFilePath); err != nil {
-					if !os.IsExist(err) {
+					// backup disk path replaced by `clickhouse->disk_mapping` could be placed on other filesystem
+					if errors.Is(err, syscall.EXDEV) {
+						log.Debug().Msgf("can't link %s -> %s across filesystems, copy", filePath, dstFilePath)
+						if copyErr := recursiveCopy.Copy(filePath, dstFilePath); copyErr != nil {
+							return fmt.Errorf("failed to copy '%s' -> '%s': %w", filePath, dstFilePath, copyErr)
+						}
+					} else if !os.IsExist(err) {
 						return fmt.Errorf("failed to create hard link '%s' -> '%s': %w", filePath, dstFilePath, err)
 					}
 				}
@@ -251,6 +259,7 @@ func IsFileInPartition(disk, fileName string, partitionsBackupMap common.EmptyMa
 func MoveShadowToBackup(shadowPath, backupPartsPath string, partitionsBackupMap common.EmptyMap, tableDiffFromRemote metadata.TableMetadata, disk clickhouse.Disk, version int) ([]metadata.Part, int64, error) {
 	size := int64(0)
 	parts := make([]metadata.Part, 0)
+	partsSize := make(map[string]int64)
 	err := filepath.Walk(shadowPath, func(filePath string, info os.FileInfo, err error) error {
 		// fix https://github.com/Altinity/clickhouse-backup/issues/826
 		if strings.Contains(info.Name(), "frozen_metadata") {
@@ -291,12 +300,16 @@ func MoveShadowToBackup(shadowPath, backupPartsPath string, partitionsBackupMap
 			return nil
 		}
 		size += info.Size()
+		partsSize[strings.SplitN(pathParts[3], "/", 2)[0]] += info.Size()
 		if version < 21004000 {
 			return os.Rename(filePath, dstFilePath)
 		} else {
 			return os.Link(filePath, dstFilePath)
 		}
 	})
+	for i := range parts {
+		parts[i].Size = partsSize[parts[i].Name]
+	}
 	// https://github.com/ClickHouse/ClickHouse/issues/71009
 	metadata.SortPartsByMinBlock(parts)
 	return parts, size, err
@@ -0,0 +1,83 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/mattn/go-shellwords"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BeforeCreate       = "before_create"
+	AfterCreate        = "after_create"
+	BeforeRestore      = "before_restore"
+	AfterUploadSuccess = "after_upload_success"
+	OnFailure          = "on_failure"
+)
+
+// GetCommand - return configured command for hook, empty string when hook is not configured
+func GetCommand(cfg *config.HooksConfig, hook string) string {
+	switch hook {
+	case BeforeCreate:
+		return cfg.BeforeCreate
+	case AfterCreate:
+		return cfg.AfterCreate
+	case BeforeRestore:
+		return cfg.BeforeRestore
+	case AfterUploadSuccess:
+		return cfg.AfterUploadSuccess
+	case OnFailure:
+		return cfg.OnFailure
+	}
+	return ""
+}
+
+// Env - backup context passed to hook command in environment variables
+func Env(hook, command, backupName string, commandErr error) []string {
+	status := "success"
+	errorMessage := ""
+	if commandErr != nil {
+		status = "error"
+		errorMessage = commandErr.Error()
+	}
+	return []string{
+		"CLICKHOUSE_BACKUP_HOOK=" + hook,
+		"CLICKHOUSE_BACKUP_COMMAND=" + command,
+		"CLICKHOUSE_BACKUP_NAME=" + backupName,
+		"CLICKHOUSE_BACKUP_STATUS=" + status,
+		"CLICKHOUSE_BACKUP_ERROR=" + errorMessage,
+	}
+}
+
+// Run - execute hook command with backup context in environment variables, do nothing when hook is not configured
+func Run(ctx context.Context, cfg *config.HooksConfig, hook, command, backupName string, commandErr error) error {
+	hookCommand := GetCommand(cfg, hook)
+	if hookCommand == "" {
+		return nil
+	}
+	args, err := shellwords.Parse(hookCommand)
+	if err != nil {
+		return fmt.Errorf("can't parse hooks->%s command: %v", hook, err)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	if cfg.TimeoutDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.TimeoutDuration)
+		defer cancel()
+	}
+	log.Info().Str("hook", hook).Str("command", command).Str("backup", backupName).Msgf("run %s", hookCommand)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), Env(hook, command, backupName, commandErr)...)
+	out, err := cmd.CombinedOutput()
+	log.Debug().Str("hook", hook).Msg(string(out))
+	if err != nil {
+		return fmt.Errorf("hooks->%s `%s` return error: %v, output: %s", hook, hookCommand, err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv(t *testing.T) {
+	r := require.New(t)
+	r.Equal([]string{
+		"CLICKHOUSE_BACKUP_HOOK=on_failure",
+		"CLICKHOUSE_BACKUP_COMMAND=upload",
+		"CLICKHOUSE_BACKUP_NAME=backup1",
+		"CLICKHOUSE_BACKUP_STATUS=error",
+		"CLICKHOUSE_BACKUP_ERROR=timeout",
+	}, Env(OnFailure, "upload", "backup1", errors.New("timeout")))
+	r.Contains(Env(AfterCreate, "create", "backup1", nil), "CLICKHOUSE_BACKUP_STATUS=success")
+}
+
+func TestRun(t *testing.T) {
+	r := require.New(t)
+	outFile := path.Join(t.TempDir(), "hook.out")
+	cfg := &config.HooksConfig{
+		BeforeCreate:  "sh -c 'echo $CLICKHOUSE_BACKUP_HOOK $CLICKHOUSE_BACKUP_COMMAND $CLICKHOUSE_BACKUP_NAME $CLICKHOUSE_BACKUP_STATUS > " + outFile + "'",
+		BeforeRestore: "sh -c 'exit 3'",
+		OnFailure:     "sleep 5",
+	}
+	r.NoError(Run(context.Background(), cfg, BeforeCreate, "create", "backup1", nil))
+	out, err := os.ReadFile(outFile)
+	r.NoError(err)
+	r.Equal("before_create create backup1 success\n", string(out))
+
+	r.NoError(Run(context.Background(), cfg, AfterCreate, "create", "backup1", nil))
+	r.ErrorContains(Run(context.Background(), cfg, BeforeRestore, "restore", "backup1", nil), "hooks->before_restore")
+
+	cfg.TimeoutDuration = 100 * time.Millisecond
+	start := time.Now()
+	r.Error(Run(context.Background(), cfg, OnFailure, "upload", "backup1", errors.New("timeout")))
+	r.Less(time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,114 @@
+package hostlock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrLocked - lock file is held by another process on the same host
+var ErrLocked = errors.New("another clickhouse-backup instance is running")
+
+// Lock - exclusive flock on `general->lock_file`, released automatically by OS when process dies, file contains owner of lock
+type Lock struct {
+	lockFile string
+	owner    string
+	file     *os.File
+	mu       sync.Mutex
+}
+
+func New(lockFile, owner string) *Lock {
+	return &Lock{lockFile: lockFile, owner: owner}
+}
+
+// Acquire - non-blocking, return ErrLocked with owner when lock held by another process
+func (l *Lock) Acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(path.Dir(l.lockFile), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.lockFile, os.O_CREATE|os.O_RDWR, 0640)
+	if err != nil {
+		return err
+	}
+	if err = lockFile(f); err != nil {
+		lockedBy := readOwner(f)
+		_ = f.Close()
+		if isWouldBlock(err) {
+			return fmt.Errorf("%w: %s locked by %s", ErrLocked, l.lockFile, lockedBy)
+		}
+		return fmt.Errorf("can't lock %s: %v", l.lockFile, err)
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(l.owner), 0)
+	}
+	if err != nil {
+		_ = unlockFile(f)
+		_ = f.Close()
+		return fmt.Errorf("can't write owner to %s: %v", l.lockFile, err)
+	}
+	l.file = f
+	return nil
+}
+
+// Release - unlock and close lock file, file itself is kept to avoid race with another process which opened it
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	unlockErr := unlockFile(l.file)
+	closeErr := l.file.Close()
+	l.file = nil
+	if unlockErr != nil {
+		return fmt.Errorf("can't unlock %s: %v", l.lockFile, unlockErr)
+	}
+	return closeErr
+}
+
+// LockedByOther - return owner of lock when it held by another process
+func (l *Lock) LockedByOther() (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return l.owner, false, nil
+	}
+	f, err := os.OpenFile(l.lockFile, os.O_RDWR, 0640)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err = lockFile(f); err != nil {
+		if isWouldBlock(err) {
+			return readOwner(f), true, nil
+		}
+		return "", false, fmt.Errorf("can't check lock %s: %v", l.lockFile, err)
+	}
+	return "", false, unlockFile(f)
+}
+
+func readOwner(f *os.File) string {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "unknown"
+	}
+	body, err := io.ReadAll(f)
+	if err != nil || len(body) == 0 {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package hostlock
+
+import (
+	"errors"
+	"path"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	lockFile := path.Join(t.TempDir(), "backup", "clickhouse-backup.lock")
+	first := New(lockFile, "pid=1 command=create")
+	second := New(lockFile, "pid=2 command=server")
+	if _, isLocked, err := second.LockedByOther(); err != nil || isLocked {
+		t.Fatalf("lock file which not exists shall not be locked, isLocked=%v err=%v", isLocked, err)
+	}
+	if err := first.Acquire(); err != nil {
+		t.Fatalf("unexpected Acquire error: %v", err)
+	}
+	if err := second.Acquire(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if lockedBy, isLocked, err := second.LockedByOther(); err != nil || !isLocked || lockedBy != "pid=1 command=create" {
+		t.Fatalf("unexpected LockedByOther lockedBy=%s isLocked=%v err=%v", lockedBy, isLocked, err)
+	}
+	if _, isLocked, _ := first.LockedByOther(); isLocked {
+		t.Fatalf("lock shall not be locked by other for owner")
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("unexpected Release error: %v", err)
+	}
+	if err := second.Acquire(); err != nil {
+		t.Fatalf("unexpected Acquire error after Release: %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatalf("unexpected Release error: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package hostlock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+func isWouldBlock(err error) bool {
+	return errors.Is(err, syscall.EWOULDBLOCK)
+}
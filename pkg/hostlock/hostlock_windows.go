@@ -0,0 +1,25 @@
+package hostlock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockedRangeOffsetHigh - LockFileEx is mandatory byte-range lock, so lock one byte far beyond the end of file, to keep owner readable by other instances
+const lockedRangeOffsetHigh = 0x7fffffff
+
+func lockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockedRangeOffsetHigh}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+}
+
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockedRangeOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
+
+func isWouldBlock(err error) bool {
+	return errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
@@ -0,0 +1,107 @@
+package keeper
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// lockConn - part of *zk.Conn which is used by Lock
+type lockConn interface {
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Delete(path string, version int32) error
+}
+
+// Lock - ephemeral node, which exists while keeper session of owner is alive, so lock is released automatically when process dies
+type Lock struct {
+	conn     lockConn
+	nodePath string
+	owner    string
+}
+
+// NewLock - relative nodePath is prefixed by /zookeeper/root from config.xml, the same as Dump and Restore
+func (k *Keeper) NewLock(nodePath, owner string) *Lock {
+	if !strings.HasPrefix(nodePath, "/") && k.root != "" {
+		nodePath = path.Join(k.root, nodePath)
+	}
+	if !strings.HasPrefix(nodePath, "/") {
+		nodePath = "/" + nodePath
+	}
+	return &Lock{conn: k.conn, nodePath: nodePath, owner: owner}
+}
+
+// Acquire - create ephemeral node, return error when node already created by another owner
+func (l *Lock) Acquire() error {
+	if err := l.createParents(); err != nil {
+		return err
+	}
+	for {
+		_, err := l.conn.Create(l.nodePath, []byte(l.owner), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, zk.ErrNodeExists) {
+			return fmt.Errorf("can't create %s: %v", l.nodePath, err)
+		}
+		value, _, err := l.conn.Get(l.nodePath)
+		// node was released between Create and Get, try again
+		if errors.Is(err, zk.ErrNoNode) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("can't get %s: %v", l.nodePath, err)
+		}
+		if string(value) != l.owner {
+			return fmt.Errorf("%s already locked by %s", l.nodePath, string(value))
+		}
+		return nil
+	}
+}
+
+// Release - delete ephemeral node only when it created by current owner
+func (l *Lock) Release() error {
+	value, stat, err := l.conn.Get(l.nodePath)
+	if errors.Is(err, zk.ErrNoNode) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't get %s: %v", l.nodePath, err)
+	}
+	if string(value) != l.owner {
+		return nil
+	}
+	if err = l.conn.Delete(l.nodePath, stat.Version); err != nil && !errors.Is(err, zk.ErrNoNode) {
+		return fmt.Errorf("can't delete %s: %v", l.nodePath, err)
+	}
+	return nil
+}
+
+// LockedByOther - return owner of lock when it held by another owner
+func (l *Lock) LockedByOther() (string, bool, error) {
+	value, _, err := l.conn.Get(l.nodePath)
+	if errors.Is(err, zk.ErrNoNode) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("can't get %s: %v", l.nodePath, err)
+	}
+	return string(value), string(value) != l.owner, nil
+}
+
+func (l *Lock) createParents() error {
+	parentPath := ""
+	for _, nodeName := range strings.Split(strings.Trim(path.Dir(l.nodePath), "/"), "/") {
+		if nodeName == "" {
+			continue
+		}
+		parentPath += "/" + nodeName
+		if _, err := l.conn.Create(parentPath, []byte{}, 0, zk.WorldACL(zk.PermAll)); err != nil && !errors.Is(err, zk.ErrNodeExists) {
+			return fmt.Errorf("can't create %s: %v", parentPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// fakeLockConn - in-memory nodes, onGet is called before each Get to emulate concurrent changes
+type fakeLockConn struct {
+	nodes map[string]string
+	onGet func()
+}
+
+func (c *fakeLockConn) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	if _, exists := c.nodes[path]; exists {
+		return "", zk.ErrNodeExists
+	}
+	c.nodes[path] = string(data)
+	return path, nil
+}
+
+func (c *fakeLockConn) Get(path string) ([]byte, *zk.Stat, error) {
+	if c.onGet != nil {
+		c.onGet()
+	}
+	value, exists := c.nodes[path]
+	if !exists {
+		return nil, nil, zk.ErrNoNode
+	}
+	return []byte(value), &zk.Stat{}, nil
+}
+
+func (c *fakeLockConn) Delete(path string, version int32) error {
+	if _, exists := c.nodes[path]; !exists {
+		return zk.ErrNoNode
+	}
+	delete(c.nodes, path)
+	return nil
+}
+
+func TestLock(t *testing.T) {
+	conn := &fakeLockConn{nodes: map[string]string{}}
+	first := &Lock{conn: conn, nodePath: "/clickhouse/backup/lock", owner: "host1:1"}
+	second := &Lock{conn: conn, nodePath: "/clickhouse/backup/lock", owner: "host2:2"}
+	if err := first.Acquire(); err != nil {
+		t.Fatalf("unexpected Acquire error: %v", err)
+	}
+	if _, exists := conn.nodes["/clickhouse/backup"]; !exists {
+		t.Fatalf("parent nodes shall be created")
+	}
+	if err := first.Acquire(); err != nil {
+		t.Fatalf("Acquire by the same owner shall succeed, got %v", err)
+	}
+	if err := second.Acquire(); err == nil {
+		t.Fatalf("Acquire shall fail when lock held by another owner")
+	}
+	if lockedBy, isLocked, err := second.LockedByOther(); err != nil || !isLocked || lockedBy != "host1:1" {
+		t.Fatalf("unexpected LockedByOther lockedBy=%s isLocked=%v err=%v", lockedBy, isLocked, err)
+	}
+	if err := second.Release(); err != nil || conn.nodes["/clickhouse/backup/lock"] != "host1:1" {
+		t.Fatalf("Release shall not delete lock of another owner, err=%v", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("unexpected Release error: %v", err)
+	}
+	if _, isLocked, err := second.LockedByOther(); err != nil || isLocked {
+		t.Fatalf("released lock shall not be locked, isLocked=%v err=%v", isLocked, err)
+	}
+	if err := second.Acquire(); err != nil {
+		t.Fatalf("unexpected Acquire error after Release: %v", err)
+	}
+}
+
+func TestLockAcquireAfterConcurrentRelease(t *testing.T) {
+	conn := &fakeLockConn{nodes: map[string]string{"/lock": "host1:1"}}
+	conn.onGet = func() {
+		// lock released by other owner between Create and Get
+		delete(conn.nodes, "/lock")
+		conn.onGet = nil
+	}
+	lock := &Lock{conn: conn, nodePath: "/lock", owner: "host2:2"}
+	if err := lock.Acquire(); err != nil {
+		t.Fatalf("unexpected Acquire error: %v", err)
+	}
+	if conn.nodes["/lock"] != "host2:2" {
+		t.Fatalf("Acquire shall create node after concurrent Release, nodes=%v", conn.nodes)
+	}
+}
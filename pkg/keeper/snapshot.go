@@ -0,0 +1,154 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	snapshotFilePrefix = "snapshot_"
+	// snapshotTmpPrefix - keeper creates tmp_snapshot_<idx>.bin marker before write snapshot and removes it after
+	snapshotTmpPrefix = "tmp_"
+)
+
+// TriggerSnapshot - send `csnp` four-letter command to ClickHouse Keeper, return last committed log index which will be included into snapshot
+func TriggerSnapshot(ctx context.Context, address string) (uint64, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return 0, fmt.Errorf("can't connect to keeper %s: %v", address, err)
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close connection to keeper %s: %v", address, closeErr)
+		}
+	}()
+	if err = conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return 0, err
+	}
+	if _, err = conn.Write([]byte("csnp")); err != nil {
+		return 0, fmt.Errorf("can't send csnp to keeper %s: %v", address, err)
+	}
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return 0, fmt.Errorf("can't read csnp response from keeper %s: %v", address, err)
+	}
+	responseStr := strings.TrimSpace(string(response))
+	logIdx, err := strconv.ParseUint(responseStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected csnp response from keeper %s: %s, check `four_letter_word_white_list` in keeper_server config", address, responseStr)
+	}
+	log.Info().Msgf("keeper %s scheduled snapshot with last committed log index %d", address, logIdx)
+	return logIdx, nil
+}
+
+// snapshotPollInterval - how often WaitSnapshot check snapshots directory
+var snapshotPollInterval = time.Second
+
+// WaitSnapshot - wait until snapshot file with log index greater or equal than logIdx will appear in snapshotPath and will be completely written
+func WaitSnapshot(ctx context.Context, snapshotPath string, logIdx uint64, timeout time.Duration) (string, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	prevFile := ""
+	prevSize := int64(-1)
+	for {
+		snapshotFile, snapshotIdx, err := LatestSnapshot(snapshotPath)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if snapshotFile != "" && snapshotIdx >= logIdx {
+			// keeper writes snapshot file in place, it is complete when tmp_ marker is removed and size is not changed during poll interval
+			info, statErr := os.Stat(snapshotFile)
+			if statErr != nil && !os.IsNotExist(statErr) {
+				return "", statErr
+			}
+			_, markerErr := os.Stat(path.Join(snapshotPath, snapshotTmpPrefix+filepath.Base(snapshotFile)))
+			if statErr == nil && os.IsNotExist(markerErr) {
+				if snapshotFile == prevFile && info.Size() == prevSize {
+					return snapshotFile, nil
+				}
+				prevFile, prevSize = snapshotFile, info.Size()
+			}
+		}
+		select {
+		case <-waitCtx.Done():
+			return "", fmt.Errorf("completed snapshot with log index >= %d not found in %s: %v", logIdx, snapshotPath, waitCtx.Err())
+		case <-time.After(snapshotPollInterval):
+		}
+	}
+}
+
+// LatestSnapshot - return full path and log index of latest snapshot_<idx>.bin[.zstd] file in snapshotPath
+func LatestSnapshot(snapshotPath string) (string, uint64, error) {
+	entries, err := os.ReadDir(snapshotPath)
+	if err != nil {
+		return "", 0, err
+	}
+	type snapshotFile struct {
+		name string
+		idx  uint64
+	}
+	snapshots := make([]snapshotFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), snapshotFilePrefix) {
+			continue
+		}
+		idxStr := strings.TrimPrefix(entry.Name(), snapshotFilePrefix)
+		if dotIdx := strings.Index(idxStr, "."); dotIdx > 0 {
+			idxStr = idxStr[:dotIdx]
+		}
+		idx, parseErr := strconv.ParseUint(idxStr, 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshotFile{name: entry.Name(), idx: idx})
+	}
+	if len(snapshots) == 0 {
+		return "", 0, nil
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].idx > snapshots[j].idx
+	})
+	return path.Join(snapshotPath, snapshots[0].name), snapshots[0].idx, nil
+}
+
+// CopySnapshot - copy snapshot file into destinationDir, return copied size
+func CopySnapshot(snapshotFile, destinationDir string) (uint64, error) {
+	if err := os.MkdirAll(destinationDir, 0750); err != nil {
+		return 0, err
+	}
+	src, err := os.Open(snapshotFile)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := src.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", snapshotFile, closeErr)
+		}
+	}()
+	dstFile := path.Join(destinationDir, filepath.Base(snapshotFile))
+	dst, err := os.Create(dstFile)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(dst, src)
+	if err != nil {
+		_ = dst.Close()
+		return 0, fmt.Errorf("can't copy %s -> %s: %v", snapshotFile, dstFile, err)
+	}
+	if err = dst.Close(); err != nil {
+		return 0, err
+	}
+	return uint64(written), nil
+}
@@ -0,0 +1,140 @@
+package keeper
+
+import (
+	"context"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerSnapshot(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("can't listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	commands := make(chan string, 1)
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		command := make([]byte, 4)
+		if _, readErr := conn.Read(command); readErr != nil {
+			return
+		}
+		commands <- string(command)
+		_, _ = conn.Write([]byte("123\n"))
+	}()
+	logIdx, err := TriggerSnapshot(context.Background(), listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logIdx != 123 {
+		t.Fatalf("unexpected log index %d, expected 123", logIdx)
+	}
+	if command := <-commands; command != "csnp" {
+		t.Fatalf("unexpected command %s, expected csnp", command)
+	}
+}
+
+func TestLatestSnapshot(t *testing.T) {
+	snapshotPath := t.TempDir()
+	for _, name := range []string{"snapshot_100.bin.zstd", "snapshot_20.bin.zstd", "snapshot_bad.bin", "tmp_snapshot_200.bin.zstd", "changelog_1_100000.bin.zstd"} {
+		if err := os.WriteFile(path.Join(snapshotPath, name), []byte("data"), 0640); err != nil {
+			t.Fatalf("can't write %s: %v", name, err)
+		}
+	}
+	snapshotFile, snapshotIdx, err := LatestSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshotFile != path.Join(snapshotPath, "snapshot_100.bin.zstd") || snapshotIdx != 100 {
+		t.Fatalf("unexpected latest snapshot %s with index %d", snapshotFile, snapshotIdx)
+	}
+	if snapshotFile, _, err = LatestSnapshot(t.TempDir()); err != nil || snapshotFile != "" {
+		t.Fatalf("empty directory shall return empty snapshot, got %s, %v", snapshotFile, err)
+	}
+}
+
+func TestWaitSnapshot(t *testing.T) {
+	prevPollInterval := snapshotPollInterval
+	snapshotPollInterval = 20 * time.Millisecond
+	defer func() { snapshotPollInterval = prevPollInterval }()
+
+	snapshotPath := t.TempDir()
+	snapshotFile := path.Join(snapshotPath, "snapshot_100.bin.zstd")
+	markerFile := path.Join(snapshotPath, "tmp_snapshot_100.bin.zstd")
+	if err := os.WriteFile(markerFile, nil, 0640); err != nil {
+		t.Fatalf("can't write marker: %v", err)
+	}
+	if err := os.WriteFile(snapshotFile, []byte("part"), 0640); err != nil {
+		t.Fatalf("can't write snapshot: %v", err)
+	}
+	// snapshot with marker is in progress
+	if _, err := WaitSnapshot(context.Background(), snapshotPath, 100, 200*time.Millisecond); err == nil {
+		t.Fatalf("snapshot in progress shall not be returned")
+	}
+	// snapshot with log index lower than requested
+	if err := os.Remove(markerFile); err != nil {
+		t.Fatalf("can't remove marker: %v", err)
+	}
+	if _, err := WaitSnapshot(context.Background(), snapshotPath, 101, 200*time.Millisecond); err == nil || !strings.Contains(err.Error(), ">= 101") {
+		t.Fatalf("unexpected error for outdated snapshot: %v", err)
+	}
+
+	stopGrow := make(chan struct{})
+	growDone := make(chan struct{})
+	go func() {
+		defer close(growDone)
+		f, err := os.OpenFile(snapshotFile, os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+		for {
+			select {
+			case <-stopGrow:
+				return
+			case <-time.After(time.Millisecond):
+				_, _ = f.Write([]byte("more"))
+			}
+		}
+	}()
+	// growing snapshot is not returned until size is stable
+	if _, err := WaitSnapshot(context.Background(), snapshotPath, 100, 200*time.Millisecond); err == nil {
+		t.Fatalf("growing snapshot shall not be returned")
+	}
+	close(stopGrow)
+	<-growDone
+	result, err := WaitSnapshot(context.Background(), snapshotPath, 100, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != snapshotFile {
+		t.Fatalf("unexpected snapshot %s, expected %s", result, snapshotFile)
+	}
+}
+
+func TestCopySnapshot(t *testing.T) {
+	snapshotFile := path.Join(t.TempDir(), "snapshot_100.bin.zstd")
+	if err := os.WriteFile(snapshotFile, []byte("snapshot data"), 0640); err != nil {
+		t.Fatalf("can't write snapshot: %v", err)
+	}
+	destinationDir := path.Join(t.TempDir(), "backup1", "keeper")
+	size, err := CopySnapshot(snapshotFile, destinationDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size != uint64(len("snapshot data")) {
+		t.Fatalf("unexpected copied size %d", size)
+	}
+	copied, err := os.ReadFile(path.Join(destinationDir, "snapshot_100.bin.zstd"))
+	if err != nil || string(copied) != "snapshot data" {
+		t.Fatalf("unexpected copied content %q, %v", string(copied), err)
+	}
+}
@@ -1,9 +1,13 @@
 package metadata
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,6 +20,8 @@ type BackupMetadata struct {
 	CreationDate            time.Time         `json:"creation_date"`
 	Tags                    string            `json:"tags,omitempty"` // "regular,embedded"
 	ClickHouseVersion       string            `json:"clickhouse_version,omitempty"`
+	ClickHouseSettings      map[string]string `json:"clickhouse_settings,omitempty"` // changed settings on source server, compared with destination server during restore
+	TableEngines            []string          `json:"table_engines,omitempty"`       // distinct engines of backed up tables, compared with destination server during restore
 	DataSize                uint64            `json:"data_size,omitempty"`
 	ObjectDiskSize          uint64            `json:"object_disk_size,omitempty"`
 	MetadataSize            uint64            `json:"metadata_size"`
@@ -23,10 +29,32 @@ type BackupMetadata struct {
 	ConfigSize              uint64            `json:"config_size,omitempty"`
 	CompressedSize          uint64            `json:"compressed_size,omitempty"`
 	Databases               []DatabasesMeta   `json:"databases,omitempty"`
+	DatabaseSizes           map[string]uint64 `json:"database_sizes,omitempty"` // data size for each database, empty for embedded backups
 	Tables                  []TableTitle      `json:"tables"`
 	Functions               []FunctionsMeta   `json:"functions"`
 	DataFormat              string            `json:"data_format"`
 	RequiredBackup          string            `json:"required_backup,omitempty"`
+	Churn                   *ChurnMetadata    `json:"churn,omitempty"`           // data changes compared to diff base or previous local backup
+	Pinned                  bool              `json:"pinned,omitempty"`          // skipped by retention, `delete` requires `--force`
+	UserTags                map[string]string `json:"user_tags,omitempty"`       // `key=value` pairs from `create --tag`, used for `list --tag` and `general->retention_ignore_tags`
+	CustomMetadata          map[string]string `json:"custom_metadata,omitempty"` // `key=value` pairs from `create --metadata`, like application version or ticket number, returned by `list`
+	Replicas                map[string]string `json:"replicas,omitempty"`        // upload status for each `general->replica_configs` target, "success" or error message
+	Signature               string            `json:"signature,omitempty"`       // HMAC-SHA256 of metadata without signature, see `general->metadata_signing_key`
+	// TablesChecksums - TableMetadata.Checksum for each `db.table`, covered by signature
+	TablesChecksums map[string]string `json:"tables_checksums,omitempty"`
+	// Checksums - SHA-256 of uploaded access, configs, dictionaries, detached and keeper objects, path relative to backup, covered by signature
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
+// ChurnMetadata - new, changed (mutated) and removed (merged, dropped or parts of dropped tables) parts compared to `--diff-from-remote` backup or previous local backup
+type ChurnMetadata struct {
+	PreviousBackup string `json:"previous_backup"`
+	NewParts       uint64 `json:"new_parts"`
+	NewBytes       uint64 `json:"new_bytes"`
+	ChangedParts   uint64 `json:"changed_parts"`
+	ChangedBytes   uint64 `json:"changed_bytes"`
+	RemovedParts   uint64 `json:"removed_parts"`
+	RemovedBytes   uint64 `json:"removed_bytes"`
 }
 
 func (b *BackupMetadata) GetFullSize() uint64 {
@@ -43,6 +71,74 @@ func (b *BackupMetadata) GetFullSize() uint64 {
 	return size
 }
 
+// GetClickHouseVersionInteger - ClickHouseVersion like `v24.3.5.46-lts` in VERSION_INTEGER format like 24003005, 0 when version is empty or unknown
+func (b *BackupMetadata) GetClickHouseVersionInteger() int {
+	parts := strings.SplitN(strings.TrimPrefix(b.ClickHouseVersion, "v"), ".", 4)
+	if len(parts) < 3 {
+		return 0
+	}
+	version := 0
+	for _, part := range parts[:3] {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		version = version*1000 + number
+	}
+	return version
+}
+
+// ParseUserTags - parse `key=value` pairs, each value could contain comma separated pairs
+func ParseUserTags(tags []string) (map[string]string, error) {
+	userTags := make(map[string]string)
+	for _, item := range tags {
+		for _, tag := range strings.Split(item, ",") {
+			if tag = strings.TrimSpace(tag); tag == "" {
+				continue
+			}
+			keyAndValue := strings.SplitN(tag, "=", 2)
+			if len(keyAndValue) != 2 || strings.TrimSpace(keyAndValue[0]) == "" {
+				return nil, fmt.Errorf("invalid tag `%s`, expected format `key=value`", tag)
+			}
+			userTags[strings.TrimSpace(keyAndValue[0])] = strings.TrimSpace(keyAndValue[1])
+		}
+	}
+	return userTags, nil
+}
+
+// ParseCustomMetadata - parse `key=value` pairs, unlike ParseUserTags value is free-form text and could contain commas
+func ParseCustomMetadata(items []string) (map[string]string, error) {
+	customMetadata := make(map[string]string)
+	for _, item := range items {
+		keyAndValue := strings.SplitN(item, "=", 2)
+		if len(keyAndValue) != 2 || strings.TrimSpace(keyAndValue[0]) == "" {
+			return nil, fmt.Errorf("invalid metadata `%s`, expected format `key=value`", item)
+		}
+		customMetadata[strings.TrimSpace(keyAndValue[0])] = keyAndValue[1]
+	}
+	return customMetadata, nil
+}
+
+// MatchAllUserTags - true when backup contains each `key=value` pair from filter, empty filter matches any backup
+func (b *BackupMetadata) MatchAllUserTags(filter map[string]string) bool {
+	for key, value := range filter {
+		if backupValue, exists := b.UserTags[key]; !exists || backupValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAnyUserTag - true when backup contains at least one `key=value` pair from filter
+func (b *BackupMetadata) MatchAnyUserTag(filter map[string]string) bool {
+	for key, value := range filter {
+		if backupValue, exists := b.UserTags[key]; exists && backupValue == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *BackupMetadata) Save(location string) error {
 	tbBody, err := json.MarshalIndent(b, "", "\t")
 	if err != nil {
@@ -53,3 +149,74 @@ func (b *BackupMetadata) Save(location string) error {
 	}
 	return nil
 }
+
+// Sign - set HMAC-SHA256 signature with key, empty key removes signature, shall be called after each metadata change
+func (b *BackupMetadata) Sign(key string) error {
+	b.Signature = ""
+	if key == "" {
+		return nil
+	}
+	signature, err := b.calculateSignature(key)
+	if err != nil {
+		return err
+	}
+	b.Signature = signature
+	return nil
+}
+
+// VerifySignature - return error when metadata is not signed or signature doesn't match with key
+func (b *BackupMetadata) VerifySignature(key string) error {
+	if b.Signature == "" {
+		return fmt.Errorf("%s/metadata.json is not signed", b.BackupName)
+	}
+	expectedSignature, err := b.calculateSignature(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expectedSignature), []byte(b.Signature)) {
+		return fmt.Errorf("%s/metadata.json signature mismatch, metadata was changed after signing or signed with another key", b.BackupName)
+	}
+	return nil
+}
+
+// SetTablesChecksums - store checksums of tables metadata, Sign shall be called after it
+func (b *BackupMetadata) SetTablesChecksums(tables []TableMetadata) error {
+	b.TablesChecksums = make(map[string]string, len(tables))
+	for i := range tables {
+		checksum, err := tables[i].Checksum()
+		if err != nil {
+			return err
+		}
+		b.TablesChecksums[tables[i].Database+"."+tables[i].Table] = checksum
+	}
+	return nil
+}
+
+// VerifyTableMetadata - return error when table metadata is absent in TablesChecksums or doesn't match with it, signature shall be verified before
+func (b *BackupMetadata) VerifyTableMetadata(table *TableMetadata) error {
+	tableName := table.Database + "." + table.Table
+	expectedChecksum, exists := b.TablesChecksums[tableName]
+	if !exists {
+		return fmt.Errorf("%s/metadata.json doesn't contain checksum for %s table metadata", b.BackupName, tableName)
+	}
+	checksum, err := table.Checksum()
+	if err != nil {
+		return err
+	}
+	if checksum != expectedChecksum {
+		return fmt.Errorf("%s table metadata checksum mismatch in %s, metadata was changed or corrupted after upload", tableName, b.BackupName)
+	}
+	return nil
+}
+
+func (b *BackupMetadata) calculateSignature(key string) (string, error) {
+	unsigned := *b
+	unsigned.Signature = ""
+	body, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", fmt.Errorf("can't marshall backup metadata: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
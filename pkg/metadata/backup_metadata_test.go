@@ -0,0 +1,135 @@
+package metadata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBackupMetadataSignature(t *testing.T) {
+	bm := BackupMetadata{BackupName: "backup1", DataFormat: "tar", Tables: []TableTitle{{Database: "db", Table: "t"}}}
+	if err := bm.Sign(""); err != nil || bm.Signature != "" {
+		t.Fatalf("empty key shall not sign, signature=%q err=%v", bm.Signature, err)
+	}
+	if err := bm.VerifySignature("secret"); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("unsigned metadata shall fail verify, got %v", err)
+	}
+	if err := bm.Sign("secret"); err != nil || bm.Signature == "" {
+		t.Fatalf("unexpected Sign result signature=%q err=%v", bm.Signature, err)
+	}
+	if err := bm.VerifySignature("secret"); err != nil {
+		t.Fatalf("unexpected VerifySignature error: %v", err)
+	}
+	if err := bm.VerifySignature("other"); err == nil {
+		t.Fatalf("wrong key shall fail verify")
+	}
+	signature := bm.Signature
+	if err := bm.Sign("secret"); err != nil || bm.Signature != signature {
+		t.Fatalf("Sign shall be idempotent, got %q expected %q", bm.Signature, signature)
+	}
+	bm.Tables = append(bm.Tables, TableTitle{Database: "db", Table: "t2"})
+	if err := bm.VerifySignature("secret"); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatalf("tampered metadata shall fail verify, got %v", err)
+	}
+}
+
+func TestUserTags(t *testing.T) {
+	userTags, err := ParseUserTags([]string{"env=prod", " reason = pre-upgrade ,team=data", "empty="})
+	if err != nil {
+		t.Fatalf("unexpected ParseUserTags error: %v", err)
+	}
+	expected := map[string]string{"env": "prod", "reason": "pre-upgrade", "team": "data", "empty": ""}
+	if len(userTags) != len(expected) {
+		t.Fatalf("unexpected ParseUserTags result: %v", userTags)
+	}
+	for key, value := range expected {
+		if userTags[key] != value {
+			t.Fatalf("unexpected ParseUserTags result: %v", userTags)
+		}
+	}
+	for _, invalid := range []string{"env", "=prod"} {
+		if _, err = ParseUserTags([]string{invalid}); err == nil {
+			t.Fatalf("%s shall fail", invalid)
+		}
+	}
+	bm := BackupMetadata{UserTags: map[string]string{"env": "prod", "reason": "pre-upgrade"}}
+	if !bm.MatchAllUserTags(nil) || !bm.MatchAllUserTags(map[string]string{"env": "prod", "reason": "pre-upgrade"}) {
+		t.Fatalf("MatchAllUserTags shall match")
+	}
+	if bm.MatchAllUserTags(map[string]string{"env": "prod", "team": "data"}) || bm.MatchAllUserTags(map[string]string{"env": "stage"}) {
+		t.Fatalf("MatchAllUserTags shall not match")
+	}
+	if !bm.MatchAnyUserTag(map[string]string{"reason": "pre-upgrade", "team": "data"}) || bm.MatchAnyUserTag(map[string]string{"env": "stage"}) || bm.MatchAnyUserTag(nil) {
+		t.Fatalf("unexpected MatchAnyUserTag result")
+	}
+}
+
+func TestParseCustomMetadata(t *testing.T) {
+	customMetadata, err := ParseCustomMetadata([]string{"app_version=1.2.3", "ticket=OPS-1, OPS-2", "migration_id=20240101=1"})
+	if err != nil {
+		t.Fatalf("unexpected ParseCustomMetadata error: %v", err)
+	}
+	if customMetadata["app_version"] != "1.2.3" || customMetadata["ticket"] != "OPS-1, OPS-2" || customMetadata["migration_id"] != "20240101=1" {
+		t.Fatalf("unexpected ParseCustomMetadata result: %v", customMetadata)
+	}
+	if _, err = ParseCustomMetadata([]string{"app_version"}); err == nil {
+		t.Fatalf("value without `=` shall fail")
+	}
+}
+
+func TestGetClickHouseVersionInteger(t *testing.T) {
+	for version, expected := range map[string]int{
+		"v24.3.5.46-lts":    24003005,
+		"v23.8.16.40-lts":   23008016,
+		"v21.1.9.41-stable": 21001009,
+		"24.10.1.2812":      24010001,
+		"":                  0,
+		"unknown":           0,
+		"v24.x.1.1-testing": 0,
+	} {
+		bm := BackupMetadata{ClickHouseVersion: version}
+		if actual := bm.GetClickHouseVersionInteger(); actual != expected {
+			t.Fatalf("GetClickHouseVersionInteger(%q)=%d, expected %d", version, actual, expected)
+		}
+	}
+}
+
+func TestBackupMetadataTablesChecksums(t *testing.T) {
+	table := TableMetadata{
+		Database: "db",
+		Table:    "t",
+		Query:    "CREATE TABLE db.t (id UInt64) ENGINE=MergeTree() ORDER BY id",
+		Parts:    map[string][]Part{"default": {{Name: "all_1_1_0"}}},
+		Files:    map[string][]string{},
+	}
+	bm := BackupMetadata{BackupName: "backup1", Tables: []TableTitle{{Database: "db", Table: "t"}}}
+	if err := bm.SetTablesChecksums([]TableMetadata{table}); err != nil {
+		t.Fatalf("unexpected SetTablesChecksums error: %v", err)
+	}
+	if err := bm.Sign("secret"); err != nil {
+		t.Fatalf("unexpected Sign error: %v", err)
+	}
+	// uploaded json omits empty maps
+	body, err := json.Marshal(&table)
+	if err != nil {
+		t.Fatalf("unexpected Marshal error: %v", err)
+	}
+	var uploaded TableMetadata
+	if err = json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatalf("unexpected Unmarshal error: %v", err)
+	}
+	if err = bm.VerifyTableMetadata(&uploaded); err != nil {
+		t.Fatalf("unexpected VerifyTableMetadata error: %v", err)
+	}
+	uploaded.Query = "CREATE TABLE db.t (id UInt64) ENGINE=Log"
+	if err = bm.VerifyTableMetadata(&uploaded); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatalf("tampered table metadata shall fail verify, got %v", err)
+	}
+	if err = bm.VerifyTableMetadata(&TableMetadata{Database: "db", Table: "t2"}); err == nil || !strings.Contains(err.Error(), "doesn't contain checksum") {
+		t.Fatalf("table without checksum shall fail verify, got %v", err)
+	}
+	bm.TablesChecksums["db.t"] = ""
+	if err = bm.VerifySignature("secret"); err == nil {
+		t.Fatalf("changed tables checksums shall fail signature verify")
+	}
+}
@@ -10,6 +10,7 @@ type Part struct {
 	Name           string `json:"name"`
 	Required       bool   `json:"required,omitempty"`
 	RebalancedDisk string `json:"rebalanced_disk,omitempty"`
+	Size           int64  `json:"size,omitempty"`
 }
 
 // SortPartsByMinBlock need to avoid wrong restore for Replacing, Collapsing, https://github.com/ClickHouse/ClickHouse/issues/71009
@@ -1,14 +1,19 @@
 package metadata
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"github.com/rs/zerolog/log"
 	"os"
 	"path"
+	"time"
 )
 
 type TableMetadata struct {
 	Files                map[string][]string `json:"files,omitempty"`
+	Checksums            map[string]string   `json:"checksums,omitempty"` // SHA-256 of each uploaded archive from Files
 	RebalancedFiles      map[string]string   `json:"rebalanced_files,omitempty"`
 	Table                string              `json:"table"`
 	Database             string              `json:"database"`
@@ -19,10 +24,49 @@ type TableMetadata struct {
 	DependenciesTable    string              `json:"dependencies_table,omitempty"`
 	DependenciesDatabase string              `json:"dependencies_database,omitempty"`
 	Mutations            []MutationMetadata  `json:"mutations,omitempty"`
+	Snapshot             *SnapshotMetadata   `json:"snapshot,omitempty"` // table state at freeze time, allows to reason about consistency between tables
 	MetadataOnly         bool                `json:"metadata_only"`
+	SkipDataReason       string              `json:"skip_data_reason,omitempty"` // why data is absent, like `general->max_table_data_size`
 	LocalFile            string              `json:"local_file,omitempty"`
 }
 
+// SnapshotMetadata - wall-clock time just before FREEZE and max(modification_time) of active parts, mutations state is stored in TableMetadata.Mutations
+type SnapshotMetadata struct {
+	FreezeTime          time.Time `json:"freeze_time"`
+	MaxModificationTime time.Time `json:"max_modification_time"`
+	MergesStopped       bool      `json:"merges_stopped,omitempty"` // `clickhouse->stop_merges_during_freeze`
+}
+
+// Checksum - SHA-256 of fields which define what will be downloaded and restored, local metadata after partial download has own checksum
+func (tm *TableMetadata) Checksum() (string, error) {
+	// empty maps are omitted in uploaded json, so they shall be the same as nil
+	parts, files, checksums := tm.Parts, tm.Files, tm.Checksums
+	if len(parts) == 0 {
+		parts = nil
+	}
+	if len(files) == 0 {
+		files = nil
+	}
+	if len(checksums) == 0 {
+		checksums = nil
+	}
+	body, err := json.Marshal(struct {
+		Database             string              `json:"database"`
+		Table                string              `json:"table"`
+		Query                string              `json:"query"`
+		DependenciesDatabase string              `json:"dependencies_database"`
+		DependenciesTable    string              `json:"dependencies_table"`
+		Parts                map[string][]Part   `json:"parts"`
+		Files                map[string][]string `json:"files"`
+		Checksums            map[string]string   `json:"checksums"`
+	}{tm.Database, tm.Table, tm.Query, tm.DependenciesDatabase, tm.DependenciesTable, parts, files, checksums})
+	if err != nil {
+		return "", fmt.Errorf("can't marshall table metadata: %v", err)
+	}
+	checksum := sha256.Sum256(body)
+	return hex.EncodeToString(checksum[:]), nil
+}
+
 func (tm *TableMetadata) Save(location string, metadataOnly bool) (uint64, error) {
 	newTM := TableMetadata{
 		Table:                tm.Table,
@@ -31,13 +75,16 @@ func (tm *TableMetadata) Save(location string, metadataOnly bool) (uint64, error
 		DependenciesTable:    tm.DependenciesTable,
 		DependenciesDatabase: tm.DependenciesDatabase,
 		MetadataOnly:         true,
+		SkipDataReason:       tm.SkipDataReason,
 	}
 
 	if !metadataOnly {
 		newTM.Files = tm.Files
+		newTM.Checksums = tm.Checksums
 		newTM.Parts = tm.Parts
 		newTM.Size = tm.Size
 		newTM.TotalBytes = tm.TotalBytes
+		newTM.Snapshot = tm.Snapshot
 		newTM.MetadataOnly = false
 	}
 	if err := os.MkdirAll(path.Dir(location), 0750); err != nil {
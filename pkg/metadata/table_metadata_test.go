@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"path"
+	"testing"
+	"time"
+)
+
+func TestTableMetadataSaveSnapshot(t *testing.T) {
+	freezeTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tm := TableMetadata{
+		Database: "db",
+		Table:    "t",
+		Snapshot: &SnapshotMetadata{FreezeTime: freezeTime, MaxModificationTime: freezeTime.Add(-time.Minute), MergesStopped: true},
+	}
+	location := path.Join(t.TempDir(), "db", "t.json")
+	if _, err := tm.Save(location, false); err != nil {
+		t.Fatalf("unexpected Save error: %v", err)
+	}
+	loaded := TableMetadata{}
+	if _, err := loaded.Load(location); err != nil {
+		t.Fatalf("unexpected Load error: %v", err)
+	}
+	if loaded.Snapshot == nil || !loaded.Snapshot.FreezeTime.Equal(freezeTime) || !loaded.Snapshot.MergesStopped {
+		t.Fatalf("unexpected snapshot %+v", loaded.Snapshot)
+	}
+	if _, err := tm.Save(location, true); err != nil {
+		t.Fatalf("unexpected Save error: %v", err)
+	}
+	loaded = TableMetadata{}
+	if _, err := loaded.Load(location); err != nil {
+		t.Fatalf("unexpected Load error: %v", err)
+	}
+	if loaded.Snapshot != nil {
+		t.Fatalf("snapshot shall be skipped for metadata only, got %+v", loaded.Snapshot)
+	}
+}
+
+func TestTableMetadataSaveSkipDataReason(t *testing.T) {
+	tm := TableMetadata{Database: "db", Table: "t", SkipDataReason: "total_bytes=2 greater than max_table_data_size=1"}
+	location := path.Join(t.TempDir(), "db", "t.json")
+	if _, err := tm.Save(location, true); err != nil {
+		t.Fatalf("unexpected Save error: %v", err)
+	}
+	loaded := TableMetadata{}
+	if _, err := loaded.Load(location); err != nil {
+		t.Fatalf("unexpected Load error: %v", err)
+	}
+	if !loaded.MetadataOnly || loaded.SkipDataReason != tm.SkipDataReason {
+		t.Fatalf("unexpected metadata_only=%v skip_data_reason=%s", loaded.MetadataOnly, loaded.SkipDataReason)
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultTemplate - used when notifications->template is empty
+const DefaultTemplate = `clickhouse-backup {{.Command}} {{.Status}} on {{.Hostname}}, backup: {{.BackupName}}, size: {{.Size}}, duration: {{.Duration}}{{if .Error}}, error: {{.Error}}{{end}}`
+
+// Event - finished command, fields available in notifications->template
+type Event struct {
+	Command    string
+	BackupName string
+	Status     string
+	Hostname   string
+	Size       string
+	SizeBytes  uint64
+	Duration   string
+	Error      string
+}
+
+// NewEvent - prepare Event for template rendering
+func NewEvent(command, backupName string, size uint64, duration time.Duration, commandErr error) Event {
+	event := Event{
+		Command:    command,
+		BackupName: backupName,
+		Status:     "success",
+		Size:       utils.FormatBytes(size),
+		SizeBytes:  size,
+		Duration:   utils.HumanizeDuration(duration),
+	}
+	if commandErr != nil {
+		event.Status = "error"
+		event.Error = commandErr.Error()
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		event.Hostname = hostname
+	}
+	return event
+}
+
+// IsEnabled - return true when at least one notifier configured and command result matched on_success / on_failure
+func IsEnabled(cfg *config.NotificationsConfig, commandErr error) bool {
+	if cfg.SlackWebhookURL == "" && (cfg.TelegramBotToken == "" || cfg.TelegramChatID == "") {
+		return false
+	}
+	if commandErr != nil {
+		return cfg.OnFailure
+	}
+	return cfg.OnSuccess
+}
+
+// Send - render message and send it into all configured notifiers, try all notifiers even when one of them failed
+func Send(ctx context.Context, cfg *config.NotificationsConfig, event Event) error {
+	message, err := Render(cfg.Template, event)
+	if err != nil {
+		return err
+	}
+	if cfg.TimeoutDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.TimeoutDuration)
+		defer cancel()
+	}
+	var errs []string
+	if cfg.SlackWebhookURL != "" {
+		if err = postJSON(ctx, cfg.SlackWebhookURL, map[string]string{"text": message}); err != nil {
+			errs = append(errs, fmt.Sprintf("slack: %v", err))
+		}
+	}
+	if cfg.TelegramBotToken != "" && cfg.TelegramChatID != "" {
+		telegramURL := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(cfg.TelegramAPIURL, "/"), cfg.TelegramBotToken)
+		if err = postJSON(ctx, telegramURL, map[string]string{"chat_id": cfg.TelegramChatID, "text": message}); err != nil {
+			// don't expose bot token in logs
+			errs = append(errs, fmt.Sprintf("telegram: %s", strings.ReplaceAll(err.Error(), cfg.TelegramBotToken, "***")))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("can't send notification: %s", strings.Join(errs, "; "))
+	}
+	log.Debug().Str("command", event.Command).Str("backup", event.BackupName).Msg("notification sent")
+	return nil
+}
+
+// Render - execute text/template with Event, empty tmpl means DefaultTemplate
+func Render(tmpl string, event Event) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid notifications template: %v", err)
+	}
+	var message bytes.Buffer
+	if err = t.Execute(&message, event); err != nil {
+		return "", fmt.Errorf("can't execute notifications template: %v", err)
+	}
+	return message.String(), nil
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close notification response body: %v", closeErr)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	r := require.New(t)
+	event := NewEvent("upload", "backup1", 1024, 2*time.Second, errors.New("timeout"))
+	event.Hostname = "host1"
+	message, err := Render("", event)
+	r.NoError(err)
+	r.Equal("clickhouse-backup upload error on host1, backup: backup1, size: 1.00KiB, duration: 2s, error: timeout", message)
+	message, err = Render("{{.BackupName}} {{.SizeBytes}}", event)
+	r.NoError(err)
+	r.Equal("backup1 1024", message)
+	_, err = Render("{{.Unknown", event)
+	r.Error(err)
+}
+
+func TestSend(t *testing.T) {
+	r := require.New(t)
+	received := make(map[string]map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		payload := make(map[string]string)
+		r.NoError(json.NewDecoder(req.Body).Decode(&payload))
+		received[req.URL.Path] = payload
+	}))
+	defer srv.Close()
+	cfg := &config.NotificationsConfig{
+		SlackWebhookURL:  srv.URL + "/slack",
+		TelegramAPIURL:   srv.URL,
+		TelegramBotToken: "token",
+		TelegramChatID:   "42",
+		Template:         "{{.Command}} {{.Status}}",
+		TimeoutDuration:  time.Second,
+	}
+	r.NoError(Send(context.Background(), cfg, NewEvent("create", "backup1", 0, time.Second, nil)))
+	r.Equal("create success", received["/slack"]["text"])
+	r.Equal("create success", received["/bottoken/sendMessage"]["text"])
+	r.Equal("42", received["/bottoken/sendMessage"]["chat_id"])
+}
+
+func TestIsEnabled(t *testing.T) {
+	r := require.New(t)
+	cfg := &config.NotificationsConfig{OnFailure: true}
+	r.False(IsEnabled(cfg, errors.New("fail")))
+	cfg.SlackWebhookURL = "http://localhost/slack"
+	r.True(IsEnabled(cfg, errors.New("fail")))
+	r.False(IsEnabled(cfg, nil))
+}
@@ -14,6 +14,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // https://regex101.com/r/k4Zxs9/1
@@ -186,8 +187,41 @@ func dropPartitionIdTable(ch *clickhouse.ClickHouse, database string, partitionI
 
 var partitionTupleRE = regexp.MustCompile(`\)\s*,\s*\(`)
 
+var partitionTimeTemplateRE = regexp.MustCompile(`{time([+-][^:}]+)?:([^}]+)}`)
+
+// ApplyPartitionTimeTemplate - replace {time:layout} and {time-24h:layout} with formatted UTC time, allow to select yesterday partition in scheduled backups, like --partitions={time-24h:20060102}
+func ApplyPartitionTimeTemplate(partitionArg string, now time.Time) (string, error) {
+	var templateErr error
+	result := partitionTimeTemplateRE.ReplaceAllStringFunc(partitionArg, func(templateItem string) string {
+		group := partitionTimeTemplateRE.FindStringSubmatch(templateItem)
+		t := now.UTC()
+		if group[1] != "" {
+			offset, err := time.ParseDuration(group[1])
+			if err != nil {
+				templateErr = fmt.Errorf("invalid offset in partition template %s: %v", templateItem, err)
+				return templateItem
+			}
+			t = t.Add(offset)
+		}
+		return t.Format(group[2])
+	})
+	return result, templateErr
+}
+
+// ApplyPartitionsTimeTemplate - the same as ApplyPartitionTimeTemplate for each --partitions value, return new slice, cause `watch` reuses partitions for each iteration
+func ApplyPartitionsTimeTemplate(partitions []string, now time.Time) ([]string, error) {
+	result := make([]string, len(partitions))
+	for i := range partitions {
+		var err error
+		if result[i], err = ApplyPartitionTimeTemplate(partitions[i], now); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 // ConvertPartitionsToIdsMapAndNamesList - get partitions from CLI/API params and convert it for NameList and IdMap for each table
-func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.ClickHouse, tablesFromClickHouse []clickhouse.Table, tablesFromMetadata []metadata.TableMetadata, partitions []string) (map[metadata.TableTitle]common.EmptyMap, map[metadata.TableTitle][]string) {
+func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.ClickHouse, tablesFromClickHouse []clickhouse.Table, tablesFromMetadata []metadata.TableMetadata, partitions []string) (map[metadata.TableTitle]common.EmptyMap, map[metadata.TableTitle][]string, error) {
 	partitionsIdMap := map[metadata.TableTitle]common.EmptyMap{}
 	partitionsNameList := map[metadata.TableTitle][]string{}
 	if len(partitions) == 0 {
@@ -197,12 +231,16 @@ func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.C
 		for _, t := range tablesFromMetadata {
 			createIdMapAndNameListIfNotExists(t.Database, t.Table, partitionsIdMap, partitionsNameList)
 		}
-		return partitionsIdMap, partitionsNameList
+		return partitionsIdMap, partitionsNameList, nil
 	}
 
 	// to allow use --partitions val1 --partitions val2, https://github.com/Altinity/clickhouse-backup/issues/425#issuecomment-1149855063
 	for _, partitionArg := range partitions {
 		partitionArg = strings.Trim(partitionArg, " \t")
+		partitionArg, err := ApplyPartitionTimeTemplate(partitionArg, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
 		tablePattern := "*.*"
 		// when PARTITION BY is table specific, https://github.com/Altinity/clickhouse-backup/issues/916
 		if tablePatternDelimiterIndex := strings.Index(partitionArg, ":"); tablePatternDelimiterIndex != -1 {
@@ -216,7 +254,7 @@ func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.C
 				for _, t := range tablesFromClickHouse {
 					createIdMapAndNameListIfNotExists(t.Database, t.Name, partitionsIdMap, partitionsNameList)
 					if partitionId, partitionName, err := GetPartitionIdAndName(ctx, ch, t.Database, t.Name, t.CreateTableQuery, partitionTuple); err != nil {
-						log.Fatal().Msgf("partition.GetPartitionIdAndName error: %v", err)
+						return nil, nil, fmt.Errorf("partition.GetPartitionIdAndName error: %v", err)
 					} else if partitionId != "" {
 						addItemToIdMapAndNameListIfNotExists(partitionId, partitionName, t.Database, t.Name, partitionsIdMap, partitionsNameList, tablePattern)
 					}
@@ -224,7 +262,7 @@ func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.C
 				for _, t := range tablesFromMetadata {
 					createIdMapAndNameListIfNotExists(t.Database, t.Table, partitionsIdMap, partitionsNameList)
 					if partitionId, partitionName, err := GetPartitionIdAndName(ctx, ch, t.Database, t.Table, t.Query, partitionTuple); err != nil {
-						log.Fatal().Msgf("partition.GetPartitionIdAndName error: %v", err)
+						return nil, nil, fmt.Errorf("partition.GetPartitionIdAndName error: %v", err)
 					} else if partitionId != "" {
 						addItemToIdMapAndNameListIfNotExists(partitionId, partitionName, t.Database, t.Table, partitionsIdMap, partitionsNameList, tablePattern)
 					}
@@ -245,7 +283,7 @@ func ConvertPartitionsToIdsMapAndNamesList(ctx context.Context, ch *clickhouse.C
 			}
 		}
 	}
-	return partitionsIdMap, partitionsNameList
+	return partitionsIdMap, partitionsNameList, nil
 }
 
 func addItemToIdMapAndNameListIfNotExists(partitionId, partitionName, database, table string, partitionsIdMap map[metadata.TableTitle]common.EmptyMap, partitionsNameList map[metadata.TableTitle][]string, tablePattern string) {
@@ -0,0 +1,68 @@
+package partition
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestApplyPartitionTimeTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		partitionArg string
+		expected     string
+	}{
+		{"202403", "202403"},
+		{"{time:20060102}", "20240301"},
+		{"{time-24h:20060102}", "20240229"},
+		{"db.events_*:{time-24h:20060102},{time-48h:20060102}", "db.events_*:20240229,20240228"},
+		{"('{time+1h:2006-01-02}')", "('2024-03-01')"},
+	}
+	for _, tc := range testCases {
+		actual, err := ApplyPartitionTimeTemplate(tc.partitionArg, now)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tc.partitionArg, err)
+		}
+		if actual != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, actual)
+		}
+	}
+	if _, err := ApplyPartitionTimeTemplate("{time-1day:20060102}", now); err == nil {
+		t.Fatalf("invalid offset shall return error")
+	}
+}
+
+func TestApplyPartitionsTimeTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC)
+	partitions := []string{"{time-24h:20060102}", "202402"}
+	actual, err := ApplyPartitionsTimeTemplate(partitions, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual[0] != "20240229" || actual[1] != "202402" {
+		t.Fatalf("unexpected result: %v", actual)
+	}
+	// watch reuses partitions for each iteration
+	if partitions[0] != "{time-24h:20060102}" {
+		t.Fatalf("source partitions shall not be changed, got %v", partitions)
+	}
+	if _, err = ApplyPartitionsTimeTemplate([]string{"{time-1day:20060102}"}, now); err == nil {
+		t.Fatalf("invalid offset shall return error")
+	}
+}
+
+func TestConvertPartitionsToIdsMapAndNamesListTemplateError(t *testing.T) {
+	tables := []metadata.TableMetadata{{Database: "db", Table: "events"}}
+	if _, _, err := ConvertPartitionsToIdsMapAndNamesList(context.Background(), nil, nil, tables, []string{"{time-1day:20060102}"}); err == nil {
+		t.Fatalf("invalid partition template shall return error")
+	}
+	partitionsIdMap, _, err := ConvertPartitionsToIdsMapAndNamesList(context.Background(), nil, nil, tables, []string{"202402"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, exists := partitionsIdMap[metadata.TableTitle{Database: "db", Table: "events"}]["202402"]; !exists {
+		t.Fatalf("unexpected partitionsIdMap: %v", partitionsIdMap)
+	}
+}
@@ -7,12 +7,13 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/rs/zerolog/log"
 	bolt "go.etcd.io/bbolt"
-	"math/big"
 	"path"
 )
 
 var bucketName = []byte("clickhouse-backup")
 
+const checksumKeyPrefix = "sha256:"
+
 type State struct {
 	stateFile string
 	db        *bolt.DB
@@ -169,7 +170,7 @@ func (s *State) IsAlreadyProcessed(path string) (bool, int64) {
 		buf := b.Get([]byte(path))
 		if buf != nil {
 			found = true
-			size = new(big.Int).SetBytes(buf).Int64()
+			size, _ = binary.Varint(buf)
 			log.Info().Msgf("%s already processed", path)
 		}
 		return nil
@@ -181,6 +182,38 @@ func (s *State) IsAlreadyProcessed(path string) (bool, int64) {
 	return found, size
 }
 
+// AppendChecksumToState - keep SHA-256 checksum of uploaded object, so table metadata after resumed upload contains checksums for skipped objects too
+func (s *State) AppendChecksumToState(path, checksum string) {
+	if s.db == nil || checksum == "" {
+		return
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := s.getBucket(tx)
+		return b.Put([]byte(checksumKeyPrefix+path), []byte(checksum))
+	})
+	if err != nil {
+		log.Fatal().Msgf("resumable state: can't write checksum for %s to %s error: %v", path, s.stateFile, err)
+	}
+}
+
+func (s *State) GetChecksumFromState(path string) string {
+	if s.db == nil {
+		return ""
+	}
+	checksum := ""
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := s.getBucket(tx)
+		if buf := b.Get([]byte(checksumKeyPrefix + path)); buf != nil {
+			checksum = string(buf)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Msgf("resumable state: can't read checksum for %s from %s error: %v", path, s.stateFile, err)
+	}
+	return checksum
+}
+
 func (s *State) Close() {
 	if s.db == nil {
 		return
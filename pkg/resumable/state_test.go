@@ -0,0 +1,34 @@
+package resumable
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestStateResumeSizeAndChecksum(t *testing.T) {
+	stateDir := t.TempDir()
+	if err := os.MkdirAll(path.Join(stateDir, "backup", "backup1"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]interface{}{"diffFrom": ""}
+	s := NewState(stateDir, "backup1", "upload", params)
+	s.AppendChecksumToState("backup1/shadow/db/t/default_1.tar", "abc")
+	s.AppendToState("backup1/shadow/db/t/default_1.tar", 1234567890)
+	s.Close()
+
+	s = NewState(stateDir, "backup1", "upload", params)
+	defer s.Close()
+	if isProcessed, size := s.IsAlreadyProcessed("backup1/shadow/db/t/default_1.tar"); !isProcessed || size != 1234567890 {
+		t.Fatalf("unexpected IsAlreadyProcessed result %v %d", isProcessed, size)
+	}
+	if checksum := s.GetChecksumFromState("backup1/shadow/db/t/default_1.tar"); checksum != "abc" {
+		t.Fatalf("unexpected checksum %s", checksum)
+	}
+	if isProcessed := s.IsAlreadyProcessedBool("backup1/shadow/db/t/default_2.tar"); isProcessed {
+		t.Fatalf("not uploaded object shall not be processed")
+	}
+	if checksum := s.GetChecksumFromState("backup1/shadow/db/t/default_2.tar"); checksum != "" {
+		t.Fatalf("unexpected checksum %s", checksum)
+	}
+}
@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - parsed standard 5 fields cron expression `minute hour day-of-month month day-of-week`
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// day-of-month and day-of-week matched with OR when both restricted, like in vixie cron
+	domStar, dowStar bool
+}
+
+type fieldBounds struct {
+	name     string
+	min, max uint
+}
+
+var (
+	minuteBounds = fieldBounds{"minute", 0, 59}
+	hourBounds   = fieldBounds{"hour", 0, 23}
+	domBounds    = fieldBounds{"day of month", 1, 31}
+	monthBounds  = fieldBounds{"month", 1, 12}
+	dowBounds    = fieldBounds{"day of week", 0, 7}
+)
+
+var predefined = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse - parse cron expression, supports `*`, `*/step`, `a-b`, `a-b/step`, comma separated lists and @daily like shortcuts
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, exists := predefined[strings.ToLower(spec)]; exists {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule `%s`: expected 5 fields `minute hour day-of-month month day-of-week`, got %d", spec, len(fields))
+	}
+	s := &Schedule{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, item := range []struct {
+		bits   *uint64
+		bounds fieldBounds
+	}{
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		if *item.bits, err = parseField(fields[i], item.bounds); err != nil {
+			return nil, fmt.Errorf("invalid schedule `%s`: %v", spec, err)
+		}
+	}
+	// 7 is Sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, bounds fieldBounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangeStr, step := item, uint(1)
+		if slashIdx := strings.Index(item, "/"); slashIdx >= 0 {
+			rangeStr = item[:slashIdx]
+			parsedStep, err := strconv.ParseUint(item[slashIdx+1:], 10, 8)
+			if err != nil || parsedStep == 0 {
+				return 0, fmt.Errorf("invalid %s step `%s`", bounds.name, item)
+			}
+			step = uint(parsedStep)
+		}
+		start, end := bounds.min, bounds.max
+		switch {
+		case rangeStr == "*":
+		case strings.Contains(rangeStr, "-"):
+			parts := strings.SplitN(rangeStr, "-", 2)
+			var err error
+			if start, err = parseValue(parts[0], bounds); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(parts[1], bounds); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid %s range `%s`", bounds.name, rangeStr)
+			}
+		default:
+			value, err := parseValue(rangeStr, bounds)
+			if err != nil {
+				return 0, err
+			}
+			start = value
+			// `5/10` means from 5 to max with step 10
+			if strings.Contains(item, "/") {
+				end = bounds.max
+			} else {
+				end = value
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(value string, bounds fieldBounds) (uint, error) {
+	v, err := strconv.ParseUint(value, 10, 8)
+	if err != nil || uint(v) < bounds.min || uint(v) > bounds.max {
+		return 0, fmt.Errorf("invalid %s value `%s`, expected %d-%d", bounds.name, value, bounds.min, bounds.max)
+	}
+	return uint(v), nil
+}
+
+// Next - return first time after t which matched to schedule, zero time when nothing matched during next 5 years
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond())).Truncate(time.Second)
+	yearLimit := t.Year() + 5
+	for t.Year() <= yearLimit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,42 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	r := require.New(t)
+	now := time.Date(2024, 1, 31, 2, 30, 15, 0, time.UTC) // Wednesday
+	testCases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"0 2 * * *", time.Date(2024, 2, 1, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 2, 45, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 2, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 3 1-7 * 1-5", time.Date(2024, 1, 31, 3, 0, 0, 0, time.UTC)},
+		{"0 3 1 * *", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10,12 * * *", time.Date(2024, 1, 31, 10, 5, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tc := range testCases {
+		s, err := Parse(tc.spec)
+		r.NoError(err, tc.spec)
+		r.Equal(tc.expected, s.Next(now), tc.spec)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	r := require.New(t)
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(spec)
+		r.Error(err, spec)
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestCheckSyncActionArgs(t *testing.T) {
+	cliApp := cli.NewApp()
+	cliApp.Commands = []cli.Command{
+		{Name: "delete", Flags: []cli.Flag{cli.BoolFlag{Name: "force"}}},
+		{Name: "rename"},
+		{Name: "service"},
+		{Name: "server"},
+	}
+	api := &APIServer{cliApp: cliApp}
+	testCases := []struct {
+		command string
+		allowed bool
+	}{
+		{"rename old_backup new_backup", true},
+		{"rename old_backup", false},
+		{"delete local backup_name", true},
+		{"delete --force remote backup_name", true},
+		{"delete local", false},
+		{"delete unknown backup_name", false},
+		{"service install", false},
+		{"server", false},
+		{"unknown", false},
+	}
+	for _, tc := range testCases {
+		args := strings.Fields(tc.command)
+		if err := api.checkSyncActionArgs(args[0], args); (err == nil) != tc.allowed {
+			t.Fatalf("`%s` allowed=%v expected, got error: %v", tc.command, tc.allowed, err)
+		}
+	}
+}
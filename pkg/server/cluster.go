@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/rs/zerolog/log"
+)
+
+// peerStatus - one row of `GET /backup/status/cluster` response
+type peerStatus struct {
+	Peer     string            `json:"peer"`
+	Status   string            `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	Commands []json.RawMessage `json:"commands"`
+	Backups  []json.RawMessage `json:"backups"`
+}
+
+// httpClusterStatusHandler - aggregate `/backup/status` and `/backup/list/{where}` from current instance and all `api.peers`, unavailable peer doesn't fail whole response
+func (api *APIServer) httpClusterStatusHandler(w http.ResponseWriter, r *http.Request) {
+	where, exists := api.getQueryParameter(r.URL.Query(), "where")
+	if !exists {
+		where = "local"
+	}
+	if where != "local" && where != "remote" {
+		api.writeError(w, http.StatusBadRequest, "status/cluster", fmt.Errorf("where shall be 'local' or 'remote'"))
+		return
+	}
+	ctx := r.Context()
+	if api.config.API.PeersTimeoutDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.config.API.PeersTimeoutDuration)
+		defer cancel()
+	}
+	peerClient, err := api.newPeerHTTPClient()
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, "status/cluster", err)
+		return
+	}
+	result := make([]peerStatus, len(api.config.API.Peers)+1)
+	result[0] = api.getSelfStatus(ctx, where)
+	var wg sync.WaitGroup
+	for i, peer := range api.config.API.Peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			result[i+1] = api.getPeerStatus(ctx, peerClient, peer, where)
+		}(i, peer)
+	}
+	wg.Wait()
+	api.sendJSONEachRow(w, http.StatusOK, result)
+}
+
+// newPeerHTTPClient - peers are expected to use the same api TLS settings, api.ca_cert_file verifies peer certificates, and certificate_file with private_key_file are presented as client certificate when peers require it
+func (api *APIServer) newPeerHTTPClient() (*http.Client, error) {
+	if !api.config.API.Secure && api.config.API.CACertFile == "" {
+		return http.DefaultClient, nil
+	}
+	tlsConfig := &tls.Config{}
+	if api.config.API.CACertFile != "" {
+		caCert, err := os.ReadFile(api.config.API.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read api.ca_cert_file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("can't parse certificates from %s", api.config.API.CACertFile)
+		}
+	}
+	if api.config.API.CertificateFile != "" && api.config.API.PrivateKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(api.config.API.CertificateFile, api.config.API.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load api.certificate_file and api.private_key_file: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+func (api *APIServer) getSelfStatus(ctx context.Context, where string) peerStatus {
+	self := peerStatus{Peer: "self", Status: "ok", Commands: make([]json.RawMessage, 0), Backups: make([]json.RawMessage, 0)}
+	if hostname, err := os.Hostname(); err == nil {
+		self.Peer = hostname
+	}
+	for _, command := range status.Current.GetStatus(true, "", 0) {
+		if row, err := json.Marshal(command); err == nil {
+			self.Commands = append(self.Commands, row)
+		}
+	}
+	backups, err := api.getBackupList(ctx, api.config, where)
+	if err != nil {
+		self.Status = "error"
+		self.Error = err.Error()
+		return self
+	}
+	for _, item := range backups {
+		if row, err := json.Marshal(item); err == nil {
+			self.Backups = append(self.Backups, row)
+		}
+	}
+	return self
+}
+
+func (api *APIServer) getPeerStatus(ctx context.Context, peerClient *http.Client, peer, where string) peerStatus {
+	result := peerStatus{Peer: peer, Status: "ok", Commands: make([]json.RawMessage, 0), Backups: make([]json.RawMessage, 0)}
+	var err error
+	if result.Commands, err = api.getPeerJSONEachRow(ctx, peerClient, peer, "/backup/status"); err == nil {
+		result.Backups, err = api.getPeerJSONEachRow(ctx, peerClient, peer, "/backup/list/"+where)
+	}
+	if err != nil {
+		log.Warn().Msgf("can't get status from peer %s: %v", peer, err)
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// getPeerJSONEachRow - peers use the same api.username and api.password as current instance
+func (api *APIServer) getPeerJSONEachRow(ctx context.Context, peerClient *http.Client, peer, uri string) ([]json.RawMessage, error) {
+	peerURL, err := url.JoinPath(peer, uri)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if api.config.API.Username != "" || api.config.API.Password != "" {
+		req.SetBasicAuth(api.config.API.Username, api.config.API.Password)
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s response body: %v", peerURL, closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("GET %s return %s: %s", uri, resp.Status, strings.TrimSpace(string(body)))
+	}
+	rows := make([]json.RawMessage, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return nil, fmt.Errorf("GET %s return invalid JSON row: %s", uri, line)
+		}
+		rows = append(rows, json.RawMessage(line))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read GET %s response: %v", uri, err)
+	}
+	return rows, nil
+}
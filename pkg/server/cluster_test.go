@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+func TestGetPeerStatus(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/backup/status":
+			fmt.Fprintln(w, `{"command":"create backup1","status":"in progress"}`)
+		case "/backup/list/local":
+			fmt.Fprintln(w, `{"name":"backup1","location":"local"}`)
+			fmt.Fprintln(w, `{"name":"backup2","location":"local"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer peer.Close()
+
+	api := &APIServer{config: &config.Config{API: config.APIConfig{Username: "admin", Password: "secret"}}}
+	result := api.getPeerStatus(context.Background(), http.DefaultClient, peer.URL, "local")
+	if result.Status != "ok" || len(result.Commands) != 1 || len(result.Backups) != 2 {
+		t.Fatalf("unexpected peer status %+v", result)
+	}
+	result = api.getPeerStatus(context.Background(), http.DefaultClient, peer.URL, "remote")
+	if result.Status != "error" || result.Error == "" {
+		t.Fatalf("peer error shall be returned in status row, got %+v", result)
+	}
+	api.config.API.Password = "wrong"
+	if result = api.getPeerStatus(context.Background(), http.DefaultClient, peer.URL, "local"); result.Status != "error" {
+		t.Fatalf("unauthorized peer shall return error status, got %+v", result)
+	}
+}
+
+func TestGetPeerStatusTLS(t *testing.T) {
+	peer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"name":"backup1","location":"local"}`)
+	}))
+	defer peer.Close()
+	caFile := path.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peer.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	api := &APIServer{config: &config.Config{API: config.APIConfig{}}}
+	if result := api.getPeerStatus(context.Background(), http.DefaultClient, peer.URL, "local"); result.Status != "error" {
+		t.Fatalf("peer certificate shall not be trusted without api.ca_cert_file, got %+v", result)
+	}
+	api.config.API.Secure = true
+	api.config.API.CACertFile = caFile
+	peerClient, err := api.newPeerHTTPClient()
+	if err != nil {
+		t.Fatalf("newPeerHTTPClient return error: %v", err)
+	}
+	if result := api.getPeerStatus(context.Background(), peerClient, peer.URL, "local"); result.Status != "ok" || len(result.Backups) != 1 {
+		t.Fatalf("unexpected peer status %+v", result)
+	}
+}
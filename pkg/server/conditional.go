@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// bufferedResponseWriter - keep whole response in memory to calculate ETag before send it
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// conditionalResponse - add ETag to successful responses, return `304 Not Modified` when `If-None-Match` contains the same ETag and compress response body when client sends `Accept-Encoding: gzip`
+// helps to reduce traffic for dashboards which poll `/backup/list` and `/backup/tables` often
+func (api *APIServer) conditionalResponse(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		buffered := &bufferedResponseWriter{header: w.Header()}
+		next(buffered, r)
+		if buffered.statusCode == 0 {
+			buffered.statusCode = http.StatusOK
+		}
+		if buffered.statusCode != http.StatusOK {
+			w.WriteHeader(buffered.statusCode)
+			if _, err := w.Write(buffered.body.Bytes()); err != nil {
+				log.Warn().Msgf("can't write to http.ResponseWriter: %v", err)
+			}
+			return
+		}
+		body := buffered.body.Bytes()
+		hash := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		// allow revalidation with If-None-Match instead of no-store
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Del("Pragma")
+		w.Header().Add("Vary", "Accept-Encoding")
+		if isETagMatched(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if len(body) > 0 && isGzipAccepted(r.Header.Get("Accept-Encoding")) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(buffered.statusCode)
+			gz := gzip.NewWriter(w)
+			if _, err := gz.Write(body); err != nil {
+				log.Warn().Msgf("can't write gzip response: %v", err)
+			}
+			if err := gz.Close(); err != nil {
+				log.Warn().Msgf("can't close gzip response: %v", err)
+			}
+			return
+		}
+		w.WriteHeader(buffered.statusCode)
+		if _, err := w.Write(body); err != nil {
+			log.Warn().Msgf("can't write to http.ResponseWriter: %v", err)
+		}
+	}
+}
+
+// isETagMatched - If-None-Match could contain several ETags separated by comma, weak comparison, https://www.rfc-editor.org/rfc/rfc9110#field.if-none-match
+func isETagMatched(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func isGzipAccepted(acceptEncoding string) bool {
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(encoding, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		// gzip;q=0 means gzip is not acceptable
+		if len(fields) > 1 && strings.ReplaceAll(strings.TrimSpace(fields[1]), " ", "") == "q=0" {
+			return false
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionalResponse(t *testing.T) {
+	api := &APIServer{}
+	handler := api.conditionalResponse(func(w http.ResponseWriter, r *http.Request) {
+		api.sendJSONEachRow(w, http.StatusOK, []string{"backup1", "backup2"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/backup/list", nil)
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || etag == "" || resp.Body.String() != "\"backup1\"\n\"backup2\"\n" {
+		t.Fatalf("unexpected response code=%d etag=%s body=%s", resp.Code, etag, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/backup/list", nil)
+	req.Header.Set("If-None-Match", "\"other\", "+etag)
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Fatalf("expected 304 without body, got code=%d body=%s", resp.Code, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/backup/list", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip")
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	if resp.Header().Get("Content-Encoding") != "gzip" || resp.Header().Get("ETag") != etag {
+		t.Fatalf("expected gzip response with the same ETag, got headers %v", resp.Header())
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("can't read gzip body: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil || string(body) != "\"backup1\"\n\"backup2\"\n" {
+		t.Fatalf("unexpected gzip body=%s err=%v", string(body), err)
+	}
+
+	errorHandler := api.conditionalResponse(func(w http.ResponseWriter, r *http.Request) {
+		api.writeError(w, http.StatusInternalServerError, "list", io.EOF)
+	})
+	resp = httptest.NewRecorder()
+	errorHandler(resp, httptest.NewRequest(http.MethodGet, "/backup/list", nil))
+	if resp.Code != http.StatusInternalServerError || resp.Header().Get("ETag") != "" {
+		t.Fatalf("error response shall be passed without ETag, got code=%d headers=%v", resp.Code, resp.Header())
+	}
+	if isGzipAccepted("gzip;q=0") || !isGzipAccepted("br, gzip;q=0.5") {
+		t.Fatalf("unexpected isGzipAccepted result")
+	}
+}
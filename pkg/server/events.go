@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// eventsKeepAliveInterval - comment line, which prevents close idle connection by proxies and load balancers
+const eventsKeepAliveInterval = 15 * time.Second
+
+// httpEventsHandler - Server-Sent Events stream of backup lifecycle events, `Last-Event-ID` header or `last_event_id` query parameter allow to receive events missed during reconnect
+func (api *APIServer) httpEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.writeError(w, http.StatusInternalServerError, "events", fmt.Errorf("streaming is not supported"))
+		return
+	}
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId, _ = api.getQueryParameter(r.URL.Query(), "last_event_id")
+	}
+	afterId := uint64(0)
+	if lastEventId != "" {
+		var err error
+		if afterId, err = strconv.ParseUint(lastEventId, 10, 64); err != nil {
+			api.writeError(w, http.StatusBadRequest, "events", fmt.Errorf("invalid Last-Event-ID: %v", err))
+			return
+		}
+	}
+	missed, subscriber, unsubscribe := events.Current.Subscribe(afterId)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// when Last-Event-ID is not passed, only new events are sent
+	if lastEventId != "" {
+		for _, event := range missed {
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event := <-subscriber:
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Warn().Msgf("can't marshal event %+v: %v", event, err)
+		return nil
+	}
+	if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+		log.Debug().Msgf("can't write event to /backup/events subscriber: %v", err)
+		return err
+	}
+	return nil
+}
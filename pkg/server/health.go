@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	healthCheckTimeout = 30 * time.Second
+	healthCheckKey     = "clickhouse-backup-health-check"
+)
+
+type healthComponentStatus struct {
+	Component string `json:"component"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration"`
+}
+
+type healthFullStatus struct {
+	Status     string                  `json:"status"`
+	Components []healthComponentStatus `json:"components"`
+}
+
+// httpHealthFullHandler - actively check clickhouse connection and remote storage availability, return 503 when one of components failed
+func (api *APIServer) httpHealthFullHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	cfg := api.config
+	ch := &clickhouse.ClickHouse{
+		Config: &cfg.ClickHouse,
+	}
+	defer ch.Close()
+	result := healthFullStatus{
+		Status: "OK",
+		Components: []healthComponentStatus{
+			checkHealthComponent("clickhouse", func() error {
+				if err := ch.Connect(); err != nil {
+					return fmt.Errorf("can't connect to clickhouse: %v", err)
+				}
+				_, err := ch.GetVersion(ctx)
+				return err
+			}),
+			checkHealthComponent("remote_storage", func() error {
+				if cfg.General.RemoteStorage == "none" || cfg.General.RemoteStorage == "custom" {
+					return errHealthSkipped
+				}
+				// macros in remote storage path resolves via clickhouse
+				if !ch.IsOpen {
+					return fmt.Errorf("clickhouse is not available, can't resolve macros for %s path", cfg.General.RemoteStorage)
+				}
+				bd, err := storage.NewBackupDestination(ctx, cfg, ch, "")
+				if err != nil {
+					return err
+				}
+				if err = bd.Connect(ctx); err != nil {
+					return fmt.Errorf("can't connect to %s: %v", bd.Kind(), err)
+				}
+				defer func() {
+					if closeErr := bd.Close(ctx); closeErr != nil {
+						log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+					}
+				}()
+				if _, err = bd.StatFile(ctx, healthCheckKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return fmt.Errorf("%s StatFile error: %v", bd.Kind(), err)
+				}
+				return nil
+			}),
+		},
+	}
+	statusCode := http.StatusOK
+	for _, component := range result.Components {
+		if component.Status == "ERROR" {
+			result.Status = "ERROR"
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+	api.sendJSONEachRow(w, statusCode, result)
+}
+
+var errHealthSkipped = errors.New("skipped")
+
+func checkHealthComponent(component string, check func() error) healthComponentStatus {
+	startTime := time.Now()
+	err := check()
+	status := healthComponentStatus{
+		Component: component,
+		Status:    "OK",
+		Duration:  time.Since(startTime).String(),
+	}
+	if errors.Is(err, errHealthSkipped) {
+		status.Status = "SKIPPED"
+	} else if err != nil {
+		log.Warn().Msgf("/health/full %s check failed: %v", component, err)
+		status.Status = "ERROR"
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+// idempotencyEntry - original operation which was started for Idempotency-Key
+type idempotencyEntry struct {
+	operation   string
+	backupName  string
+	operationId string
+	commandId   int
+	created     time.Time
+}
+
+// idempotencyKeys - remember Idempotency-Key of mutating requests during ttl, to avoid duplicate operations when client retry request
+type idempotencyKeys struct {
+	sync.Mutex
+	entries map[string]idempotencyEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func newIdempotencyKeys(ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		entries: make(map[string]idempotencyEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// get - return original entry when key already used and not expired
+func (k *idempotencyKeys) get(key string) (idempotencyEntry, bool) {
+	k.Lock()
+	defer k.Unlock()
+	k.cleanExpired()
+	entry, exists := k.entries[key]
+	return entry, exists
+}
+
+// reserve - store entry for key, return original entry and true when key already used and not expired
+func (k *idempotencyKeys) reserve(key string, entry idempotencyEntry) (idempotencyEntry, bool) {
+	k.Lock()
+	defer k.Unlock()
+	now := k.cleanExpired()
+	if existing, exists := k.entries[key]; exists {
+		return existing, true
+	}
+	entry.created = now
+	entry.commandId = status.NotFromAPI
+	k.entries[key] = entry
+	return entry, false
+}
+
+func (k *idempotencyKeys) cleanExpired() time.Time {
+	now := k.now()
+	for key, entry := range k.entries {
+		if k.ttl > 0 && now.Sub(entry.created) > k.ttl {
+			delete(k.entries, key)
+		}
+	}
+	return now
+}
+
+// setCommandId - link reserved key with status.Current command after operation started
+func (k *idempotencyKeys) setCommandId(key string, commandId int) {
+	if k == nil || key == "" {
+		return
+	}
+	k.Lock()
+	defer k.Unlock()
+	if entry, exists := k.entries[key]; exists {
+		entry.commandId = commandId
+		k.entries[key] = entry
+	}
+}
+
+// release - forget reserved key when operation didn't start, so client can retry with the same key
+func (k *idempotencyKeys) release(key string) {
+	if k == nil || key == "" {
+		return
+	}
+	k.Lock()
+	defer k.Unlock()
+	delete(k.entries, key)
+}
+
+// getIdempotencyKey - `Idempotency-Key` header has priority over `request_id` query parameter
+func (api *APIServer) getIdempotencyKey(r *http.Request) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	key, _ := api.getQueryParameter(r.URL.Query(), "request_id")
+	return key
+}
+
+// replayIdempotentRequest - write status of original operation when Idempotency-Key already used, return true when response already written
+func (api *APIServer) replayIdempotentRequest(w http.ResponseWriter, key, operation string) bool {
+	if key == "" || api.idempotencyKeys == nil {
+		return false
+	}
+	original, exists := api.idempotencyKeys.get(key)
+	if !exists {
+		return false
+	}
+	api.writeIdempotentReplay(w, key, operation, original)
+	return true
+}
+
+// reserveIdempotencyKey - remember Idempotency-Key before operation start, concurrent retry with the same key which reserved it first is replayed, return true when response already written
+func (api *APIServer) reserveIdempotencyKey(w http.ResponseWriter, key string, entry idempotencyEntry) bool {
+	if key == "" || api.idempotencyKeys == nil {
+		return false
+	}
+	original, exists := api.idempotencyKeys.reserve(key, entry)
+	if !exists {
+		return false
+	}
+	api.writeIdempotentReplay(w, key, entry.operation, original)
+	return true
+}
+
+func (api *APIServer) writeIdempotentReplay(w http.ResponseWriter, key, operation string, original idempotencyEntry) {
+	if original.operation != operation {
+		api.writeError(w, http.StatusConflict, operation, fmt.Errorf("Idempotency-Key '%s' already used for %s operation %s", key, original.operation, original.operationId))
+		return
+	}
+	commandStatus := status.ActionRowStatus{Status: status.InProgressStatus}
+	if cmdStatus, found := status.Current.GetStatusById(original.commandId); found {
+		commandStatus = cmdStatus
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status      string `json:"status"`
+		Operation   string `json:"operation"`
+		BackupName  string `json:"backup_name"`
+		OperationId string `json:"operation_id"`
+		Command     string `json:"command,omitempty"`
+		Start       string `json:"start,omitempty"`
+		Finish      string `json:"finish,omitempty"`
+		Error       string `json:"error,omitempty"`
+		Replayed    bool   `json:"replayed"`
+	}{
+		Status:      commandStatus.Status,
+		Operation:   original.operation,
+		BackupName:  original.backupName,
+		OperationId: original.operationId,
+		Command:     commandStatus.Command,
+		Start:       commandStatus.Start,
+		Finish:      commandStatus.Finish,
+		Error:       commandStatus.Error,
+		Replayed:    true,
+	})
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	now := time.Now()
+	keys := newIdempotencyKeys(time.Hour)
+	keys.now = func() time.Time { return now }
+
+	if _, exists := keys.get("key1"); exists {
+		t.Fatalf("unknown key shall not exist")
+	}
+	if _, exists := keys.reserve("key1", idempotencyEntry{operation: "create", backupName: "backup1", operationId: "op1"}); exists {
+		t.Fatalf("first reserve shall not return existing entry")
+	}
+	original, exists := keys.reserve("key1", idempotencyEntry{operation: "create", backupName: "backup2", operationId: "op2"})
+	if !exists || original.operationId != "op1" || original.backupName != "backup1" || original.commandId != status.NotFromAPI {
+		t.Fatalf("retry shall return original entry, got %+v exists=%v", original, exists)
+	}
+	keys.setCommandId("key1", 5)
+	if original, _ = keys.get("key1"); original.commandId != 5 {
+		t.Fatalf("unexpected commandId=%d", original.commandId)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, exists = keys.get("key1"); exists {
+		t.Fatalf("key shall expire after ttl")
+	}
+	if _, exists = keys.reserve("key1", idempotencyEntry{operation: "upload", operationId: "op3"}); exists {
+		t.Fatalf("expired key shall be reserved again")
+	}
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -33,8 +35,38 @@ type APIMetrics struct {
 	NumberBackupsLocalExpected  prometheus.Gauge
 	InProgressCommands          prometheus.Gauge
 	LocalDataSize               prometheus.Gauge
+	NewestBackupAgeLocal        prometheus.Collector
+	OldestBackupAgeLocal        prometheus.Collector
+	NewestBackupAgeRemote       prometheus.Collector
+	OldestBackupAgeRemote       prometheus.Collector
+	APIRequestsQueued           *prometheus.CounterVec
+	APIRequestsDropped          *prometheus.CounterVec
+	ScheduledJobsSkipped        *prometheus.CounterVec
+	UploadedBytes               prometheus.Counter
+	DownloadedBytes             prometheus.Counter
+	UploadThroughput            prometheus.GaugeFunc
+	DownloadThroughput          prometheus.GaugeFunc
+	LastBackupChurnBytes        *prometheus.GaugeVec
+	LastBackupChurnParts        *prometheus.GaugeVec
+	LastBackupReplicaStatus     *prometheus.GaugeVec
+	BuildInfo                   *prometheus.GaugeVec
 
 	SubCommands map[string][]string
+
+	backupTimes     map[string]time.Time
+	backupTimesLock sync.RWMutex
+
+	transfers     map[string][]transferEvent
+	transfersLock sync.Mutex
+}
+
+// throughputWindow - period for calculate current upload and download throughput
+const throughputWindow = time.Minute
+
+type transferEvent struct {
+	size       int64
+	startTime  time.Time
+	finishTime time.Time
 }
 
 func NewAPIMetrics() *APIMetrics {
@@ -43,6 +75,8 @@ func NewAPIMetrics() *APIMetrics {
 			"create_remote":  {"create", "upload"},
 			"restore_remote": {"download", "restore"},
 		},
+		backupTimes: map[string]time.Time{},
+		transfers:   map[string][]transferEvent{},
 	}
 	return metrics
 }
@@ -151,6 +185,68 @@ func (m *APIMetrics) RegisterMetrics() {
 		Help:      "How many bytes in MergeTree tables",
 	})
 
+	m.NewestBackupAgeLocal = m.newBackupAgeGauge("newest_backup_age_local", "Seconds since creation of newest successful local backup, not exported when no backups", "newest_local")
+	m.OldestBackupAgeLocal = m.newBackupAgeGauge("oldest_backup_age_local", "Seconds since creation of oldest successful local backup, not exported when no backups", "oldest_local")
+	m.NewestBackupAgeRemote = m.newBackupAgeGauge("newest_backup_age_remote", "Seconds since creation of newest successful remote backup, not exported when no backups", "newest_remote")
+	m.OldestBackupAgeRemote = m.newBackupAgeGauge("oldest_backup_age_remote", "Seconds since creation of oldest successful remote backup, not exported when no backups", "oldest_remote")
+
+	m.APIRequestsQueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "api_requests_queued",
+		Help:      "Counter of API requests which waited for api.route_rate_limits token",
+	}, []string{"route"})
+
+	m.APIRequestsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "api_requests_dropped",
+		Help:      "Counter of API requests rejected with 429 by api.route_rate_limits",
+	}, []string{"route"})
+
+	m.ScheduledJobsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "scheduled_jobs_skipped",
+		Help:      "Counter of api.schedule_* runs skipped cause another command was in progress",
+	}, []string{"job"})
+
+	m.UploadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "uploaded_bytes_total",
+		Help:      "Counter of bytes uploaded to remote storage",
+	})
+
+	m.DownloadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "downloaded_bytes_total",
+		Help:      "Counter of bytes downloaded from remote storage",
+	})
+
+	m.UploadThroughput = m.newThroughputGauge("upload_throughput_bytes_per_second", "Average upload speed to remote storage during last minute", "upload")
+	m.DownloadThroughput = m.newThroughputGauge("download_throughput_bytes_per_second", "Average download speed from remote storage during last minute", "download")
+
+	m.LastBackupChurnBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "last_backup_churn_bytes",
+		Help:      "Bytes in new, changed and removed parts of last local backup compared to previous local backup",
+	}, []string{"type"})
+
+	m.LastBackupChurnParts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "last_backup_churn_parts",
+		Help:      "Number of new, changed and removed parts of last local backup compared to previous local backup",
+	}, []string{"type"})
+
+	m.LastBackupReplicaStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "last_backup_replica_status",
+		Help:      "Upload status of last remote backup for each general->replica_configs target: 0=failed, 1=success",
+	}, []string{"replica"})
+
+	m.BuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      "build_info",
+		Help:      "Always 1, labels contain version, git commit, build date and supported remote storages of running clickhouse-backup",
+	}, []string{"version", "git_commit", "build_date", "storage_backends"})
+
 	for _, command := range commandList {
 		prometheus.MustRegister(
 			m.SuccessfulCounter[command],
@@ -171,6 +267,21 @@ func (m *APIMetrics) RegisterMetrics() {
 		m.NumberBackupsLocalExpected,
 		m.InProgressCommands,
 		m.LocalDataSize,
+		m.NewestBackupAgeLocal,
+		m.OldestBackupAgeLocal,
+		m.NewestBackupAgeRemote,
+		m.OldestBackupAgeRemote,
+		m.APIRequestsQueued,
+		m.APIRequestsDropped,
+		m.ScheduledJobsSkipped,
+		m.UploadedBytes,
+		m.DownloadedBytes,
+		m.UploadThroughput,
+		m.DownloadThroughput,
+		m.LastBackupChurnBytes,
+		m.LastBackupChurnParts,
+		m.LastBackupReplicaStatus,
+		m.BuildInfo,
 	)
 
 	for _, command := range commandList {
@@ -178,6 +289,102 @@ func (m *APIMetrics) RegisterMetrics() {
 	}
 }
 
+// backupAgeCollector - age calculates during scrape, so it grows between UpdateBackupMetrics calls, nothing is exported when no backups, cause 0 looks fresher than any real backup
+type backupAgeCollector struct {
+	m    *APIMetrics
+	desc *prometheus.Desc
+	key  string
+}
+
+func (c *backupAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *backupAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.backupTimesLock.RLock()
+	t, exists := c.m.backupTimes[c.key]
+	c.m.backupTimesLock.RUnlock()
+	if !exists || t.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(t).Seconds())
+}
+
+func (m *APIMetrics) newBackupAgeGauge(name, help, key string) prometheus.Collector {
+	return &backupAgeCollector{
+		m:    m,
+		desc: prometheus.NewDesc(prometheus.BuildFQName("clickhouse_backup", "", name), help, nil, nil),
+		key:  key,
+	}
+}
+
+// SetBackupTimes store creation time for newest and oldest successful backups, location shall be local or remote, zero time means no backups
+func (m *APIMetrics) SetBackupTimes(location string, newest, oldest time.Time) {
+	m.backupTimesLock.Lock()
+	defer m.backupTimesLock.Unlock()
+	m.backupTimes["newest_"+location] = newest
+	m.backupTimes["oldest_"+location] = oldest
+}
+
+// ObserveTransfer receive bytes transferred by storage layer, long transfers are reported in parts during transfer, direction shall be upload or download
+func (m *APIMetrics) ObserveTransfer(direction string, size int64, startTime, finishTime time.Time) {
+	switch direction {
+	case "upload":
+		if m.UploadedBytes != nil {
+			m.UploadedBytes.Add(float64(size))
+		}
+	case "download":
+		if m.DownloadedBytes != nil {
+			m.DownloadedBytes.Add(float64(size))
+		}
+	default:
+		return
+	}
+	m.transfersLock.Lock()
+	defer m.transfersLock.Unlock()
+	m.transfers[direction] = append(m.pruneTransfers(direction, finishTime), transferEvent{size: size, startTime: startTime, finishTime: finishTime})
+}
+
+// Throughput return bytes per second during last throughputWindow, size of each transfer distributed uniformly between start and finish
+func (m *APIMetrics) Throughput(direction string, now time.Time) float64 {
+	m.transfersLock.Lock()
+	defer m.transfersLock.Unlock()
+	m.transfers[direction] = m.pruneTransfers(direction, now)
+	windowStart := now.Add(-throughputWindow)
+	transferred := float64(0)
+	for _, t := range m.transfers[direction] {
+		duration := t.finishTime.Sub(t.startTime)
+		if duration <= 0 || !t.startTime.Before(windowStart) {
+			transferred += float64(t.size)
+			continue
+		}
+		transferred += float64(t.size) * float64(t.finishTime.Sub(windowStart)) / float64(duration)
+	}
+	return transferred / throughputWindow.Seconds()
+}
+
+// pruneTransfers shall be called under transfersLock
+func (m *APIMetrics) pruneTransfers(direction string, now time.Time) []transferEvent {
+	windowStart := now.Add(-throughputWindow)
+	actual := m.transfers[direction][:0]
+	for _, t := range m.transfers[direction] {
+		if t.finishTime.After(windowStart) {
+			actual = append(actual, t)
+		}
+	}
+	return actual
+}
+
+func (m *APIMetrics) newThroughputGauge(name, help, direction string) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		return m.Throughput(direction, time.Now())
+	})
+}
+
 func (m *APIMetrics) Start(command string, startTime time.Time) {
 	if _, exists := m.LastStart[command]; exists {
 		m.LastStart[command].Set(float64(startTime.Unix()))
@@ -252,3 +459,43 @@ func (m *APIMetrics) ExecuteWithMetrics(command string, errCounter int, f func()
 	}
 	return err, errCounter
 }
+
+// SkipScheduledJob - count scheduled run which was not started, cause another command was in progress
+func (m *APIMetrics) SkipScheduledJob(job string) {
+	if m.ScheduledJobsSkipped != nil {
+		m.ScheduledJobsSkipped.WithLabelValues(job).Inc()
+	}
+}
+
+// SetBackupChurn set churn metrics from metadata.json of last local backup, zero when churn not calculated
+func (m *APIMetrics) SetBackupChurn(newParts, changedParts, removedParts, newBytes, changedBytes, removedBytes uint64) {
+	m.LastBackupChurnParts.WithLabelValues("new").Set(float64(newParts))
+	m.LastBackupChurnParts.WithLabelValues("changed").Set(float64(changedParts))
+	m.LastBackupChurnParts.WithLabelValues("removed").Set(float64(removedParts))
+	m.LastBackupChurnBytes.WithLabelValues("new").Set(float64(newBytes))
+	m.LastBackupChurnBytes.WithLabelValues("changed").Set(float64(changedBytes))
+	m.LastBackupChurnBytes.WithLabelValues("removed").Set(float64(removedBytes))
+}
+
+// SetBackupReplicas set replica status metrics from metadata.json of last remote backup, replicas removed from config disappear from metrics
+func (m *APIMetrics) SetBackupReplicas(replicas map[string]string, successStatus string) {
+	if m.LastBackupReplicaStatus == nil {
+		return
+	}
+	m.LastBackupReplicaStatus.Reset()
+	for replica, status := range replicas {
+		if status == successStatus {
+			m.LastBackupReplicaStatus.WithLabelValues(replica).Set(1)
+		} else {
+			m.LastBackupReplicaStatus.WithLabelValues(replica).Set(0)
+		}
+	}
+}
+
+// SetBuildInfo - labels don't change during process lifetime, so set once after RegisterMetrics
+func (m *APIMetrics) SetBuildInfo(version, gitCommit, buildDate string, storageBackends []string) {
+	if m.BuildInfo == nil {
+		return
+	}
+	m.BuildInfo.WithLabelValues(version, gitCommit, buildDate, strings.Join(storageBackends, ",")).Set(1)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestThroughput(t *testing.T) {
+	m := NewAPIMetrics()
+	now := time.Now()
+	m.ObserveTransfer("upload", 600, now.Add(-10*time.Second), now)
+	// half of transfer inside window
+	m.ObserveTransfer("upload", 1200, now.Add(-3*throughputWindow/2), now.Add(-throughputWindow/2))
+	// outside window
+	m.ObserveTransfer("upload", 1000, now.Add(-3*throughputWindow), now.Add(-2*throughputWindow))
+	m.ObserveTransfer("unknown", 1000, now.Add(-time.Second), now)
+
+	if throughput := m.Throughput("upload", now); throughput != 20 {
+		t.Fatalf("unexpected upload throughput %f, expected 20", throughput)
+	}
+	if throughput := m.Throughput("download", now); throughput != 0 {
+		t.Fatalf("unexpected download throughput %f, expected 0", throughput)
+	}
+	if len(m.transfers["upload"]) != 2 {
+		t.Fatalf("outdated transfers shall be pruned, got %d", len(m.transfers["upload"]))
+	}
+}
+
+func TestBackupAgeNotExportedWithoutBackups(t *testing.T) {
+	m := NewAPIMetrics()
+	age := m.newBackupAgeGauge("newest_backup_age_remote", "test", "newest_remote")
+	if count := testutil.CollectAndCount(age); count != 0 {
+		t.Fatalf("age shall not be exported before backup metrics update, got %d metrics", count)
+	}
+	m.SetBackupTimes("remote", time.Time{}, time.Time{})
+	if count := testutil.CollectAndCount(age); count != 0 {
+		t.Fatalf("age shall not be exported when no backups, got %d metrics", count)
+	}
+	m.SetBackupTimes("remote", time.Now().Add(-time.Hour), time.Now().Add(-2*time.Hour))
+	if value := testutil.ToFloat64(age); value < 3600 || value > 3700 {
+		t.Fatalf("unexpected newest backup age %f", value)
+	}
+}
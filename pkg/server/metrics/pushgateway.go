@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushCommandMetrics - push last_<command>_* metrics for single CLI run into Pushgateway, cron based runs don't have /metrics endpoint
+func PushCommandMetrics(pushgatewayURL, job, command string, startTime time.Time, commandErr error) error {
+	finishTime := time.Now()
+	lastStart := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      fmt.Sprintf("last_%s_start", command),
+		Help:      fmt.Sprintf("Last backup %s start timestamp", command),
+	})
+	lastFinish := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      fmt.Sprintf("last_%s_finish", command),
+		Help:      fmt.Sprintf("Last backup %s finish timestamp", command),
+	})
+	lastDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      fmt.Sprintf("last_%s_duration", command),
+		Help:      fmt.Sprintf("Backup %s duration in nanoseconds", command),
+	})
+	lastStatus := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clickhouse_backup",
+		Name:      fmt.Sprintf("last_%s_status", command),
+		Help:      fmt.Sprintf("Last backup %s status: 0=failed, 1=success, 2=unknown", command),
+	})
+	lastStart.Set(float64(startTime.Unix()))
+	lastFinish.Set(float64(finishTime.Unix()))
+	lastDuration.Set(float64(finishTime.Sub(startTime).Nanoseconds()))
+	if commandErr != nil {
+		lastStatus.Set(0)
+	} else {
+		lastStatus.Set(1)
+	}
+	pusher := push.New(pushgatewayURL, job).
+		Grouping("command", command).
+		Collector(lastStart).
+		Collector(lastFinish).
+		Collector(lastDuration).
+		Collector(lastStatus)
+	if hostname, err := os.Hostname(); err == nil {
+		pusher = pusher.Grouping("instance", hostname)
+	}
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("can't push metrics to %s: %v", pushgatewayURL, err)
+	}
+	return nil
+}
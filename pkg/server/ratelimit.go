@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// routeLimiter - token bucket per route template, requests wait in queue up to queueTimeout and dropped after
+type routeLimiter struct {
+	limiters     map[string]*rate.Limiter
+	queueTimeout time.Duration
+	onQueued     func(route string)
+	onDropped    func(route string)
+}
+
+func newRouteLimiter(rateLimits map[string]float64, queueTimeout time.Duration) *routeLimiter {
+	limiters := make(map[string]*rate.Limiter, len(rateLimits))
+	for route, requestsPerSecond := range rateLimits {
+		if requestsPerSecond <= 0 {
+			continue
+		}
+		burst := int(math.Max(1, math.Ceil(requestsPerSecond)))
+		limiters[route] = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+	return &routeLimiter{
+		limiters:     limiters,
+		queueTimeout: queueTimeout,
+		onQueued:     func(string) {},
+		onDropped:    func(string) {},
+	}
+}
+
+// allow return false when request can't get token during queueTimeout
+func (l *routeLimiter) allow(ctx context.Context, route string) bool {
+	limiter, exists := l.limiters[route]
+	if !exists {
+		return true
+	}
+	if limiter.Allow() {
+		return true
+	}
+	l.onQueued(route)
+	if l.queueTimeout <= 0 {
+		l.onDropped(route)
+		return false
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, l.queueTimeout)
+	defer cancel()
+	if err := limiter.Wait(waitCtx); err != nil {
+		l.onDropped(route)
+		return false
+	}
+	return true
+}
+
+func (api *APIServer) rateLimitMiddleware(limiter *routeLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !limiter.allow(r.Context(), template) {
+				log.Warn().Msgf("%s %s dropped by api.route_rate_limits", r.Method, r.URL.Path)
+				w.Header().Set("Retry-After", "1")
+				api.writeError(w, http.StatusTooManyRequests, template, fmt.Errorf("too many requests for %s, look api.route_rate_limits", template))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRouteLimiter(t *testing.T) {
+	ctx := context.Background()
+	queued, dropped := 0, 0
+	limiter := newRouteLimiter(map[string]float64{"/backup/list": 1, "/backup/tables": 0}, 0)
+	limiter.onQueued = func(string) { queued++ }
+	limiter.onDropped = func(string) { dropped++ }
+
+	if !limiter.allow(ctx, "/backup/list") {
+		t.Fatalf("first request shall be allowed")
+	}
+	if limiter.allow(ctx, "/backup/list") {
+		t.Fatalf("second request shall be dropped without queue timeout")
+	}
+	if queued != 1 || dropped != 1 {
+		t.Fatalf("unexpected queued=%d dropped=%d", queued, dropped)
+	}
+	for i := 0; i < 10; i++ {
+		if !limiter.allow(ctx, "/backup/tables") {
+			t.Fatalf("zero rate limit shall not limit route")
+		}
+		if !limiter.allow(ctx, "/backup/status") {
+			t.Fatalf("route without rate limit shall not be limited")
+		}
+	}
+
+	limiter = newRouteLimiter(map[string]float64{"/backup/list": 20}, time.Second)
+	for i := 0; i < 25; i++ {
+		if !limiter.allow(ctx, "/backup/list") {
+			t.Fatalf("request %d shall wait in queue instead of drop", i)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// configRecommendation - one suggested config change, key is `section.key` the same as in config file
+type configRecommendation struct {
+	Key       string `json:"key"`
+	Current   string `json:"current"`
+	Suggested string `json:"suggested"`
+	Reason    string `json:"reason"`
+}
+
+// recommendationInputs - host, data and recent operations stats, which recommendations are based on
+type recommendationInputs struct {
+	CPU              int    `json:"cpu"`
+	MemoryBytes      uint64 `json:"memory_bytes"`
+	TablesCount      uint64 `json:"tables_count"`
+	PartsCount       uint64 `json:"parts_count"`
+	TotalBytes       uint64 `json:"total_bytes"`
+	MaxPartBytes     uint64 `json:"max_part_bytes"`
+	RecentOperations int    `json:"recent_operations"`
+	RecentFailures   int    `json:"recent_failures"`
+}
+
+// httpRecommendationsHandler - GET returns recommendations, POST with `confirm=true` writes them into config file and reloads config
+func (api *APIServer) httpRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	_, confirm := api.getQueryParameter(r.URL.Query(), "confirm")
+	if r.Method == http.MethodPost && !confirm {
+		api.writeError(w, http.StatusBadRequest, "recommendations", fmt.Errorf("confirm=true is required to apply recommendations"))
+		return
+	}
+	inputs, err := api.getRecommendationInputs(r)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, "recommendations", err)
+		return
+	}
+	recommendations := getConfigRecommendations(api.config, inputs)
+	applied := false
+	if r.Method == http.MethodPost && len(recommendations) > 0 {
+		values := make(map[string]string, len(recommendations))
+		for _, recommendation := range recommendations {
+			values[recommendation.Key] = recommendation.Suggested
+		}
+		if err = config.UpdateConfigFile(api.configPath, values); err != nil {
+			api.writeError(w, http.StatusInternalServerError, "recommendations", err)
+			return
+		}
+		if _, err = api.ReloadConfig(w, "recommendations"); err != nil {
+			return
+		}
+		log.Info().Msgf("apply %d recommendations to %s", len(recommendations), api.configPath)
+		applied = true
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Inputs          recommendationInputs   `json:"inputs"`
+		Recommendations []configRecommendation `json:"recommendations"`
+		Applied         bool                   `json:"applied"`
+	}{
+		Inputs:          inputs,
+		Recommendations: recommendations,
+		Applied:         applied,
+	})
+}
+
+func (api *APIServer) getRecommendationInputs(r *http.Request) (recommendationInputs, error) {
+	inputs := recommendationInputs{CPU: runtime.NumCPU()}
+	ch := clickhouse.ClickHouse{
+		Config: &api.config.ClickHouse,
+	}
+	if err := ch.Connect(); err != nil {
+		return inputs, fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer ch.Close()
+	partsStats, err := ch.GetPartsStats(r.Context())
+	if err != nil {
+		return inputs, err
+	}
+	inputs.TablesCount, inputs.PartsCount, inputs.TotalBytes, inputs.MaxPartBytes = partsStats.TablesCount, partsStats.PartsCount, partsStats.TotalBytes, partsStats.MaxPartBytes
+	if inputs.MemoryBytes, err = ch.GetOSMemoryTotal(r.Context()); err != nil {
+		log.Warn().Msgf("can't get OSMemoryTotal: %v", err)
+	}
+	for _, command := range status.Current.GetStatus(false, "", 0) {
+		operation := strings.Fields(command.Command)
+		if len(operation) == 0 || command.Status == status.InProgressStatus {
+			continue
+		}
+		switch operation[0] {
+		case "create", "create_remote", "upload", "download", "restore", "restore_remote":
+			inputs.RecentOperations++
+			if command.Status == status.ErrorStatus {
+				inputs.RecentFailures++
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// getConfigRecommendations - transfer concurrency limited by CPU, memory for upload buffers and parts count, compression format by CPU and data size, s3 part size by the largest data part
+func getConfigRecommendations(cfg *config.Config, inputs recommendationInputs) []configRecommendation {
+	recommendations := make([]configRecommendation, 0)
+	add := func(key, current, suggested, reason string) {
+		if current != suggested {
+			recommendations = append(recommendations, configRecommendation{Key: key, Current: current, Suggested: suggested, Reason: reason})
+		}
+	}
+	if cfg.General.RemoteStorage == "none" || cfg.General.RemoteStorage == "custom" {
+		return recommendations
+	}
+
+	concurrency := max(inputs.CPU/2, 1)
+	reason := fmt.Sprintf("half of %d CPU cores", inputs.CPU)
+	// s3 SDK buffers `s3.concurrency` parts for each uploaded file
+	bufferPerStream := uint64(5 * 1024 * 1024)
+	if cfg.General.RemoteStorage == "s3" {
+		if cfg.S3.PartSize > 0 {
+			bufferPerStream = uint64(cfg.S3.PartSize)
+		}
+		bufferPerStream *= uint64(max(cfg.S3.Concurrency, 1))
+	}
+	if inputs.MemoryBytes > 0 {
+		if memoryLimited := int(inputs.MemoryBytes / 4 / bufferPerStream); memoryLimited < concurrency {
+			concurrency = max(memoryLimited, 1)
+			reason = fmt.Sprintf("upload buffers shall use less than 25%% of %s memory", utils.FormatBytes(inputs.MemoryBytes))
+		}
+	}
+	if inputs.PartsCount > 0 && uint64(concurrency) > inputs.PartsCount {
+		concurrency = int(inputs.PartsCount)
+		reason = fmt.Sprintf("only %d data parts to transfer", inputs.PartsCount)
+	}
+	uploadConcurrency, downloadConcurrency := concurrency, concurrency
+	uploadReason, downloadReason := reason, reason
+	if inputs.RecentOperations >= 2 && inputs.RecentFailures*2 > inputs.RecentOperations {
+		uploadConcurrency = max(min(uploadConcurrency, int(cfg.General.UploadConcurrency)/2), 1)
+		downloadConcurrency = max(min(downloadConcurrency, int(cfg.General.DownloadConcurrency)/2), 1)
+		uploadReason = fmt.Sprintf("%d of %d recent operations failed", inputs.RecentFailures, inputs.RecentOperations)
+		downloadReason = uploadReason
+	}
+	uploadConcurrency, downloadConcurrency = min(uploadConcurrency, 255), min(downloadConcurrency, 255)
+	add("general.upload_concurrency", strconv.Itoa(int(cfg.General.UploadConcurrency)), strconv.Itoa(uploadConcurrency), uploadReason)
+	add("general.download_concurrency", strconv.Itoa(int(cfg.General.DownloadConcurrency)), strconv.Itoa(downloadConcurrency), downloadReason)
+
+	switch cfg.General.RemoteStorage {
+	case "s3", "gcs", "cos", "ftp", "sftp", "azblob", "local":
+		compressionKey := cfg.General.RemoteStorage + ".compression_format"
+		currentCompression := cfg.GetCompressionFormat()
+		switch {
+		case inputs.CPU <= 2 && (currentCompression == "zstd" || currentCompression == "xz" || currentCompression == "brotli" || currentCompression == "br" || currentCompression == "bzip2" || currentCompression == "gzip"):
+			add(compressionKey, currentCompression, "lz4", fmt.Sprintf("%s is CPU expensive for %d CPU cores", currentCompression, inputs.CPU))
+		case inputs.CPU >= 8 && inputs.TotalBytes >= 1<<40 && (currentCompression == "tar" || currentCompression == "lz4"):
+			add(compressionKey, currentCompression, "zstd", fmt.Sprintf("zstd reduces storage and network cost for %s of data", utils.FormatBytes(inputs.TotalBytes)))
+		}
+	}
+
+	if cfg.General.RemoteStorage == "s3" && cfg.S3.PartSize > 0 && cfg.S3.MaxPartsCount > 0 && inputs.MaxPartBytes/uint64(cfg.S3.PartSize) > uint64(cfg.S3.MaxPartsCount) {
+		add("s3.part_size", strconv.FormatInt(cfg.S3.PartSize, 10), "0", fmt.Sprintf("the largest data part %s requires more than s3.max_parts_count=%d multipart parts, 0 means calculate part size automatically", utils.FormatBytes(inputs.MaxPartBytes), cfg.S3.MaxPartsCount))
+	}
+	return recommendations
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+func TestGetConfigRecommendations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.General.RemoteStorage = "none"
+	if recommendations := getConfigRecommendations(cfg, recommendationInputs{CPU: 16}); len(recommendations) != 0 {
+		t.Fatalf("remote_storage: none shall not have recommendations, got %+v", recommendations)
+	}
+
+	cfg.General.RemoteStorage = "s3"
+	cfg.General.UploadConcurrency, cfg.General.DownloadConcurrency = 8, 8
+	cfg.S3.CompressionFormat = "tar"
+	cfg.S3.Concurrency = 1
+	cfg.S3.PartSize = 5 * 1024 * 1024
+	cfg.S3.MaxPartsCount = 10
+	inputs := recommendationInputs{CPU: 16, MemoryBytes: 64 << 30, PartsCount: 1000, TotalBytes: 2 << 40, MaxPartBytes: 100 << 20}
+	recommendations := map[string]string{}
+	for _, recommendation := range getConfigRecommendations(cfg, inputs) {
+		recommendations[recommendation.Key] = recommendation.Suggested
+	}
+	expected := map[string]string{"s3.compression_format": "zstd", "s3.part_size": "0"}
+	if len(recommendations) != len(expected) || recommendations["s3.compression_format"] != "zstd" || recommendations["s3.part_size"] != "0" {
+		t.Fatalf("expected %v, got %v", expected, recommendations)
+	}
+
+	// memory limited concurrency
+	inputs = recommendationInputs{CPU: 16, MemoryBytes: 40 << 20, PartsCount: 1000}
+	for _, recommendation := range getConfigRecommendations(cfg, inputs) {
+		if recommendation.Key == "general.upload_concurrency" && recommendation.Suggested != "2" {
+			t.Fatalf("expected upload_concurrency=2, got %+v", recommendation)
+		}
+	}
+
+	// recent failures halve current concurrency
+	inputs = recommendationInputs{CPU: 16, PartsCount: 1000, RecentOperations: 4, RecentFailures: 3}
+	for _, recommendation := range getConfigRecommendations(cfg, inputs) {
+		if recommendation.Key == "general.download_concurrency" && recommendation.Suggested != "4" {
+			t.Fatalf("expected download_concurrency=4, got %+v", recommendation)
+		}
+	}
+}
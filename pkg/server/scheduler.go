@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/backup"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/schedule"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/rs/zerolog/log"
+)
+
+const schedulerTick = 30 * time.Second
+
+// scheduledJob - one of api.schedule_* cron expressions, spec re-parsed when config reloaded
+type scheduledJob struct {
+	name     string
+	spec     func(cfg *config.APIConfig) string
+	run      func(cfg *config.Config)
+	lastSpec string
+	next     time.Time
+}
+
+// RunScheduler - run create_remote full, create_remote increment and retention according api.schedule_full, api.schedule_incremental and api.schedule_retention, replace external cron which calls REST API
+func (api *APIServer) RunScheduler() {
+	createRemoteErrCount := 0
+	jobs := []*scheduledJob{
+		{
+			name: "full",
+			spec: func(cfg *config.APIConfig) string { return cfg.ScheduleFull },
+			run: func(cfg *config.Config) {
+				createRemoteErrCount = api.runScheduledCreateRemote(cfg, "full", "full", createRemoteErrCount)
+			},
+		},
+		{
+			name: "incremental",
+			spec: func(cfg *config.APIConfig) string { return cfg.ScheduleIncremental },
+			run: func(cfg *config.Config) {
+				createRemoteErrCount = api.runScheduledCreateRemote(cfg, "incremental", "increment", createRemoteErrCount)
+			},
+		},
+		{
+			name: "retention",
+			spec: func(cfg *config.APIConfig) string { return cfg.ScheduleRetention },
+			run:  api.runScheduledRetention,
+		},
+	}
+	log.Info().Msg("Starting API Server scheduler")
+	for {
+		now := time.Now()
+		if api.config.API.TimeLocation != nil {
+			now = now.In(api.config.API.TimeLocation)
+		}
+		for _, job := range jobs {
+			spec := job.spec(&api.config.API)
+			if spec == "" {
+				job.lastSpec = ""
+				continue
+			}
+			if spec != job.lastSpec {
+				s, err := schedule.Parse(spec)
+				if err != nil {
+					log.Error().Str("job", job.name).Msgf("scheduler: %v", err)
+					continue
+				}
+				job.lastSpec = spec
+				job.next = s.Next(now)
+				log.Info().Str("job", job.name).Str("schedule", spec).Msgf("scheduler: next run at %s", job.next.Format(time.RFC3339))
+				continue
+			}
+			if job.next.IsZero() || now.Before(job.next) {
+				continue
+			}
+			api.runScheduledJob(job)
+			// schedule is already validated, config could be reloaded during job, lastSpec reset will re-parse it
+			job.lastSpec = ""
+		}
+		time.Sleep(schedulerTick)
+	}
+}
+
+func (api *APIServer) runScheduledJob(job *scheduledJob) {
+	if !api.config.API.AllowParallel && status.Current.InProgress() {
+		api.skipScheduledJob(job.name, job.name, ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(nil, "schedule")
+	if err != nil {
+		return
+	}
+	log.Info().Str("job", job.name).Msg("scheduler: start")
+	job.run(cfg)
+}
+
+// skipScheduledJob - scheduled run is not queued, next run happens according to schedule, so each skipped run is logged and counted in clickhouse_backup_scheduled_jobs_skipped metric
+func (api *APIServer) skipScheduledJob(jobName, command string, err error) {
+	log.Warn().Str("job", jobName).Msgf("scheduler: skip %s, %v", command, err)
+	if api.metrics != nil {
+		api.metrics.SkipScheduledJob(jobName)
+	}
+}
+
+func (api *APIServer) runScheduledCreateRemote(cfg *config.Config, jobName, backupType string, errCounter int) int {
+	commandId, _, err := status.Current.StartWithSharedLock("create_remote --schedule=" + backupType)
+	if err != nil {
+		api.skipScheduledJob(jobName, "create_remote "+backupType, err)
+		return errCounter
+	}
+	var backupName string
+	err, errCounter = api.metrics.ExecuteWithMetrics("create_remote", errCounter, func() error {
+		var createErr error
+		backupName, createErr = backup.NewBackuper(cfg, backup.WithTransferObserver(api.metrics.ObserveTransfer)).CreateRemoteByType(backupType, "", api.clickhouseBackupVersion, commandId)
+		return createErr
+	})
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		log.Error().Str("backup", backupName).Msgf("scheduler: create_remote %s return error: %v", backupType, err)
+	}
+	if metricsErr := api.UpdateBackupMetrics(context.Background(), false); metricsErr != nil {
+		log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+	}
+	return errCounter
+}
+
+func (api *APIServer) runScheduledRetention(cfg *config.Config) {
+	locations := []string{"local"}
+	if cfg.General.RemoteStorage != "none" && cfg.General.RemoteStorage != "custom" {
+		locations = append(locations, "remote")
+	}
+	for _, location := range locations {
+		commandId, _, err := status.Current.StartWithSharedLock("clean --location=" + location)
+		if err != nil {
+			api.skipScheduledJob("retention", "clean "+location+" retention", err)
+			continue
+		}
+		backupsToDelete, err := backup.NewBackuper(cfg).CleanRetention(location, false, commandId)
+		status.Current.Stop(commandId, err)
+		if err != nil {
+			log.Error().Msgf("scheduler: clean %s retention return error: %v", location, err)
+			continue
+		}
+		log.Info().Str("location", location).Msgf("scheduler: retention deleted %d backups", len(backupsToDelete))
+	}
+}
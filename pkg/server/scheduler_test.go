@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/server/metrics"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunScheduledJobSkipped(t *testing.T) {
+	apiMetrics := metrics.NewAPIMetrics()
+	apiMetrics.ScheduledJobsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "scheduled_jobs_skipped"}, []string{"job"})
+	api := &APIServer{config: &config.Config{API: config.APIConfig{AllowParallel: false}}, metrics: apiMetrics}
+	started := false
+	job := &scheduledJob{name: "full", run: func(cfg *config.Config) { started = true }}
+
+	commandId, _ := status.Current.Start("create")
+	api.runScheduledJob(job)
+	api.runScheduledJob(job)
+	status.Current.Stop(commandId, nil)
+
+	if started {
+		t.Fatalf("scheduled job shall be skipped while another command is in progress")
+	}
+	if skipped := testutil.ToFloat64(apiMetrics.ScheduledJobsSkipped.WithLabelValues("full")); skipped != 2 {
+		t.Fatalf("unexpected skipped counter %f, expected 2", skipped)
+	}
+	if skipped := testutil.ToFloat64(apiMetrics.ScheduledJobsSkipped.WithLabelValues("retention")); skipped != 0 {
+		t.Fatalf("unexpected retention skipped counter %f, expected 0", skipped)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
@@ -32,9 +34,11 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/server/metrics"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
 	"github.com/google/uuid"
 )
@@ -50,14 +54,18 @@ type APIServer struct {
 	metrics                 *metrics.APIMetrics
 	routes                  []string
 	clickhouseBackupVersion string
+	idempotencyKeys         *idempotencyKeys
 }
 
 var (
 	ErrAPILocked = errors.New("another operation is currently running")
 )
 
-// Run - expose CLI commands as REST API
-func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBackupVersion string) error {
+// TransferObserverMetadataKey - key of cli.App.Metadata which contains storage.TransferObserver when commands executed by API server
+const TransferObserverMetadataKey = "transferObserver"
+
+// Run - expose CLI commands as REST API, serviceStop is closed by OS service manager, nil when not running as service
+func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBackupVersion string, serviceStop <-chan struct{}) error {
 	var (
 		cfg *config.Config
 		err error
@@ -81,6 +89,7 @@ func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBack
 		_ = ch.GetConn().Close()
 		break
 	}
+	common.SetAPITimeFormat(cfg.API.TimeFormat, cfg.API.TimeLocation)
 	api := APIServer{
 		cliApp:                  cliApp,
 		cliCtx:                  cliCtx,
@@ -90,13 +99,22 @@ func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBack
 		clickhouseBackupVersion: clickhouseBackupVersion,
 		metrics:                 metrics.NewAPIMetrics(),
 		stop:                    make(chan struct{}),
+		idempotencyKeys:         newIdempotencyKeys(cfg.API.IdempotencyKeyTTLDuration),
 	}
 	if cfg.API.CreateIntegrationTables {
 		if err := api.CreateIntegrationTables(); err != nil {
 			log.Error().Err(err).Send()
 		}
 	}
+	if err := api.initSharedLock(cfg); err != nil {
+		return err
+	}
+	api.initHostLock(cfg)
 	api.metrics.RegisterMetrics()
+	info := api.getBuildInfo()
+	api.metrics.SetBuildInfo(info.Version, info.GitCommit, info.BuildDate, info.StorageBackends)
+	// commands executed via cliApp.Run by /backup/actions and resumed operations shall update transfer metrics too
+	api.cliApp.Metadata[TransferObserverMetadataKey] = storage.TransferObserver(api.metrics.ObserveTransfer)
 
 	log.Info().Msgf("Starting API server %s on %s", api.cliApp.Version, api.config.API.ListenAddr)
 	sigterm := make(chan os.Signal, 1)
@@ -114,11 +132,8 @@ func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBack
 		}()
 	}
 
-	go func() {
-		if metricsErr := api.UpdateBackupMetrics(context.Background(), false); metricsErr != nil {
-			log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
-		}
-	}()
+	go api.UpdateBackupMetricsPeriodically()
+	go api.RunScheduler()
 
 	if cliCtx.Bool("watch") {
 		go api.RunWatch(cliCtx)
@@ -141,6 +156,9 @@ func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBack
 		case <-sigterm:
 			log.Info().Msg("Stopping API server")
 			return api.Stop()
+		case <-serviceStop:
+			log.Info().Msg("Stopping API server by service manager")
+			return api.Stop()
 		case <-api.stop:
 			log.Info().Msg("Stopping API server. Stopped from the inside of the application")
 			return api.Stop()
@@ -154,7 +172,7 @@ func (api *APIServer) GetMetrics() *metrics.APIMetrics {
 
 func (api *APIServer) RunWatch(cliCtx *cli.Context) {
 	log.Info().Msg("Starting API Server in watch mode")
-	b := backup.NewBackuper(api.config)
+	b := backup.NewBackuper(api.config, backup.WithTransferObserver(api.metrics.ObserveTransfer))
 	commandId, _ := status.Current.Start("watch")
 	err := b.Watch(
 		cliCtx.String("watch-interval"), cliCtx.String("full-interval"), cliCtx.String("watch-backup-name-template"),
@@ -211,6 +229,12 @@ func (api *APIServer) Restart() error {
 func (api *APIServer) registerHTTPHandlers() *http.Server {
 	r := mux.NewRouter()
 	r.Use(api.basicAuthMiddleware)
+	limiter := newRouteLimiter(api.config.API.RouteRateLimits, api.config.API.RouteQueueTimeoutDuration)
+	if api.metrics != nil && api.metrics.APIRequestsQueued != nil {
+		limiter.onQueued = func(route string) { api.metrics.APIRequestsQueued.WithLabelValues(route).Inc() }
+		limiter.onDropped = func(route string) { api.metrics.APIRequestsDropped.WithLabelValues(route).Inc() }
+	}
+	r.Use(api.rateLimitMiddleware(limiter))
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		api.writeError(w, http.StatusNotFound, r.URL.Path, fmt.Errorf("%s %s 404 Not Found", r.Method, r.URL))
 	})
@@ -221,13 +245,14 @@ func (api *APIServer) registerHTTPHandlers() *http.Server {
 	r.HandleFunc("/", api.httpRootHandler).Methods("GET", "HEAD")
 	r.HandleFunc("/", api.httpRestartHandler).Methods("POST")
 	r.HandleFunc("/restart", api.httpRestartHandler).Methods("POST", "GET")
+	r.HandleFunc("/version", api.httpVersionHandler).Methods("GET", "HEAD")
 	r.HandleFunc("/backup/version", api.httpVersionHandler).Methods("GET", "HEAD")
 	r.HandleFunc("/backup/kill", api.httpKillHandler).Methods("POST", "GET")
 	r.HandleFunc("/backup/watch", api.httpWatchHandler).Methods("POST", "GET")
-	r.HandleFunc("/backup/tables", api.httpTablesHandler).Methods("GET")
-	r.HandleFunc("/backup/tables/all", api.httpTablesHandler).Methods("GET")
-	r.HandleFunc("/backup/list", api.httpListHandler).Methods("GET", "HEAD")
-	r.HandleFunc("/backup/list/{where}", api.httpListHandler).Methods("GET")
+	r.HandleFunc("/backup/tables", api.conditionalResponse(api.httpTablesHandler)).Methods("GET")
+	r.HandleFunc("/backup/tables/all", api.conditionalResponse(api.httpTablesHandler)).Methods("GET")
+	r.HandleFunc("/backup/list", api.conditionalResponse(api.httpListHandler)).Methods("GET", "HEAD")
+	r.HandleFunc("/backup/list/{where}", api.conditionalResponse(api.httpListHandler)).Methods("GET")
 	r.HandleFunc("/backup/create", api.httpCreateHandler).Methods("POST")
 	r.HandleFunc("/backup/clean", api.httpCleanHandler).Methods("POST")
 	r.HandleFunc("/backup/clean/remote_broken", api.httpCleanRemoteBrokenHandler).Methods("POST")
@@ -235,7 +260,14 @@ func (api *APIServer) registerHTTPHandlers() *http.Server {
 	r.HandleFunc("/backup/download/{name}", api.httpDownloadHandler).Methods("POST")
 	r.HandleFunc("/backup/restore/{name}", api.httpRestoreHandler).Methods("POST")
 	r.HandleFunc("/backup/delete/{where}/{name}", api.httpDeleteHandler).Methods("POST")
+	r.HandleFunc("/backup/pin/{name}", api.httpPinHandler).Methods("POST")
+	r.HandleFunc("/backup/unpin/{name}", api.httpPinHandler).Methods("POST")
+	r.HandleFunc("/backup/rename/{name}/{new_name}", api.httpRenameHandler).Methods("POST")
+	r.HandleFunc("/backup/copy/{name}", api.httpCopyHandler).Methods("POST")
 	r.HandleFunc("/backup/status", api.httpBackupStatusHandler).Methods("GET")
+	r.HandleFunc("/backup/status/cluster", api.httpClusterStatusHandler).Methods("GET")
+	r.HandleFunc("/backup/events", api.httpEventsHandler).Methods("GET")
+	r.HandleFunc("/backup/recommendations", api.httpRecommendationsHandler).Methods("GET", "POST")
 
 	r.HandleFunc("/backup/actions", api.actionsLog).Methods("GET", "HEAD")
 	r.HandleFunc("/backup/actions", api.actions).Methods("POST")
@@ -322,7 +354,7 @@ func (api *APIServer) actions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	lines := bytes.Split(body, []byte("\n"))
-	actionsResults := make([]actionsResultsRow, 0)
+	actionsResults := make([]interface{}, 0)
 	for _, line := range lines {
 		if len(line) == 0 {
 			continue
@@ -365,32 +397,53 @@ func (api *APIServer) actions(w http.ResponseWriter, r *http.Request) {
 				api.writeError(w, http.StatusInternalServerError, row.Command, err)
 				return
 			}
-		case "create", "restore", "upload", "download", "create_remote", "restore_remote", "list":
+		case "create", "restore", "upload", "download", "create_remote", "restore_remote":
 			actionsResults, err = api.actionsAsyncCommandsHandler(command, args, row, actionsResults)
 			if err != nil {
 				api.writeError(w, http.StatusInternalServerError, row.Command, err)
 				return
 			}
 		case "delete":
+			if err = api.checkSyncActionArgs(command, args); err != nil {
+				api.writeError(w, http.StatusBadRequest, row.Command, err)
+				return
+			}
 			actionsResults, err = api.actionsDeleteHandler(row, args, actionsResults)
 			if err != nil {
 				api.writeError(w, http.StatusInternalServerError, row.Command, err)
 				return
 			}
+		// read commands return their result rows instead of status row
+		case "list", "tables":
+			actionsResults, err = api.actionsReadCommandsHandler(w, command, args, row, actionsResults)
+			if err != nil {
+				api.writeError(w, http.StatusInternalServerError, row.Command, err)
+				return
+			}
 		default:
-			api.writeError(w, http.StatusBadRequest, row.Command, fmt.Errorf("unknown command"))
-			return
+			if err = api.checkSyncActionArgs(command, args); err != nil {
+				api.writeError(w, http.StatusBadRequest, row.Command, err)
+				return
+			}
+			actionsResults, err = api.actionsSyncCommandsHandler(command, args, row, actionsResults)
+			if err != nil {
+				api.writeError(w, http.StatusInternalServerError, row.Command, err)
+				return
+			}
 		}
 	}
 	api.sendJSONEachRow(w, http.StatusOK, actionsResults)
 }
 
-func (api *APIServer) actionsDeleteHandler(row status.ActionRow, args []string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+func (api *APIServer) actionsDeleteHandler(row status.ActionRow, args []string, actionsResults []interface{}) ([]interface{}, error) {
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		return actionsResults, ErrAPILocked
 	}
-	commandId, _ := status.Current.Start(row.Command)
-	err := api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
+	commandId, _, err := status.Current.StartWithSharedLock(row.Command)
+	if err != nil {
+		return actionsResults, err
+	}
+	err = api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
 	status.Current.Stop(commandId, err)
 	if err != nil {
 		return actionsResults, err
@@ -408,12 +461,140 @@ func (api *APIServer) actionsDeleteHandler(row status.ActionRow, args []string,
 	return actionsResults, nil
 }
 
-func (api *APIServer) actionsAsyncCommandsHandler(command string, args []string, row status.ActionRow, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+// actionsReadCommandsHandler - execute `list` and `tables` synchronously and return the same rows as GET /backup/list and GET /backup/tables
+func (api *APIServer) actionsReadCommandsHandler(w http.ResponseWriter, command string, args []string, row status.ActionRow, actionsResults []interface{}) ([]interface{}, error) {
+	flags, err := api.parseActionArgs(command, args)
+	if err != nil {
+		return actionsResults, err
+	}
+	cfg, err := api.ReloadConfig(w, command)
+	if err != nil {
+		return actionsResults, err
+	}
+	commandId, ctx := status.Current.Start(row.Command)
+	switch command {
+	case "list":
+		if flags.NArg() > 1 {
+			err = fmt.Errorf("list format '%s' is not supported in /backup/actions", flags.Arg(1))
+			break
+		}
+		var backups []backupJSON
+		if backups, err = api.getBackupList(ctx, cfg, flags.Arg(0)); err == nil {
+			for _, backupRow := range backups {
+				actionsResults = append(actionsResults, backupRow)
+			}
+		}
+	case "tables":
+		var tables []clickhouse.Table
+		if tables, err = api.getTables(ctx, cfg, getActionFlag(flags, "table", "tables", "t"), getActionFlag(flags, "remote-backup"), getActionFlag(flags, "all", "a") == "true"); err == nil {
+			for _, tableRow := range tables {
+				actionsResults = append(actionsResults, tableRow)
+			}
+		}
+	}
+	status.Current.Stop(commandId, err)
+	return actionsResults, err
+}
+
+// actionsSyncCommands - CLI commands which are safe to run synchronously via cli app inside API server process, with count of required positional arguments
+// commands call cli.ShowCommandHelpAndExit when arguments are missing, it would stop whole API server, so arguments are checked before
+var actionsSyncCommands = map[string]int{
+	"delete":      2,
+	"diff-schema": 1,
+	"pin":         1,
+	"unpin":       1,
+	"rename":      2,
+}
+
+// checkSyncActionArgs - only commands from actionsSyncCommands with all required arguments are allowed
+func (api *APIServer) checkSyncActionArgs(command string, args []string) error {
+	requiredArgs, isAllowed := actionsSyncCommands[command]
+	if !isAllowed {
+		return fmt.Errorf("command `%s` is not allowed in /backup/actions", command)
+	}
+	flags, err := api.parseActionArgs(command, args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < requiredArgs {
+		return fmt.Errorf("`%s` requires %d arguments, %d passed", command, requiredArgs, flags.NArg())
+	}
+	for i := 0; i < requiredArgs; i++ {
+		if flags.Arg(i) == "" {
+			return fmt.Errorf("`%s` argument %d shall be not empty", command, i+1)
+		}
+	}
+	if command == "delete" && flags.Arg(0) != "local" && flags.Arg(0) != "remote" {
+		return fmt.Errorf("`delete` first argument shall be `local` or `remote`")
+	}
+	return nil
+}
+
+// actionsSyncCommandsHandler - execute commands from actionsSyncCommands synchronously via cli app
+func (api *APIServer) actionsSyncCommandsHandler(command string, args []string, row status.ActionRow, actionsResults []interface{}) ([]interface{}, error) {
+	if !api.config.API.AllowParallel && status.Current.InProgress() {
+		return actionsResults, ErrAPILocked
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(row.Command)
+	if err != nil {
+		return actionsResults, err
+	}
+	err = api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		return actionsResults, err
+	}
+	go func() {
+		if metricsErr := api.UpdateBackupMetrics(context.Background(), false); metricsErr != nil {
+			log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+		}
+	}()
+	actionsResults = append(actionsResults, actionsResultsRow{
+		Status:    "success",
+		Operation: row.Command,
+	})
+	return actionsResults, nil
+}
+
+// parseActionArgs - parse arguments of /backup/actions command with the same flags as CLI command
+func (api *APIServer) parseActionArgs(command string, args []string) (*flag.FlagSet, error) {
+	cliCommand := api.cliApp.Command(command)
+	if cliCommand == nil {
+		return nil, fmt.Errorf("unknown command %s", command)
+	}
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	for _, f := range cliCommand.Flags {
+		f.Apply(flags)
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, fmt.Errorf("can't parse `%s` arguments: %v", command, err)
+	}
+	return flags, nil
+}
+
+// getActionFlag - each alias of CLI flag is a separate flag in flag.FlagSet, return value of the first passed alias
+func getActionFlag(flags *flag.FlagSet, aliases ...string) string {
+	value := ""
+	flags.Visit(func(f *flag.Flag) {
+		for _, alias := range aliases {
+			if f.Name == alias {
+				value = f.Value.String()
+			}
+		}
+	})
+	return value
+}
+
+func (api *APIServer) actionsAsyncCommandsHandler(command string, args []string, row status.ActionRow, actionsResults []interface{}) ([]interface{}, error) {
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		return actionsResults, ErrAPILocked
 	}
 	// to avoid race condition between GET /backup/actions and POST /backup/actions
-	commandId, _ := status.Current.Start(row.Command)
+	commandId, _, err := status.Current.StartWithSharedLock(row.Command)
+	if err != nil {
+		return actionsResults, err
+	}
 	go func() {
 		err, _ := api.metrics.ExecuteWithMetrics(command, 0, func() error {
 			return api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
@@ -436,7 +617,7 @@ func (api *APIServer) actionsAsyncCommandsHandler(command string, args []string,
 	return actionsResults, nil
 }
 
-func (api *APIServer) actionsKillHandler(row status.ActionRow, args []string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+func (api *APIServer) actionsKillHandler(row status.ActionRow, args []string, actionsResults []interface{}) ([]interface{}, error) {
 	killCommand := ""
 	if len(args) > 1 {
 		killCommand = args[1]
@@ -454,12 +635,15 @@ func (api *APIServer) actionsKillHandler(row status.ActionRow, args []string, ac
 	return actionsResults, nil
 }
 
-func (api *APIServer) actionsCleanHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+func (api *APIServer) actionsCleanHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []interface{}) ([]interface{}, error) {
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		log.Warn().Msgf(ErrAPILocked.Error())
 		return actionsResults, ErrAPILocked
 	}
-	commandId, ctx := status.Current.Start(command)
+	commandId, ctx, err := status.Current.StartWithSharedLock(command)
+	if err != nil {
+		return actionsResults, err
+	}
 	cfg, err := api.ReloadConfig(w, "clean")
 	if err != nil {
 		status.Current.Stop(commandId, err)
@@ -486,12 +670,15 @@ func (api *APIServer) actionsCleanHandler(w http.ResponseWriter, row status.Acti
 	return actionsResults, nil
 }
 
-func (api *APIServer) actionsCleanRemoteBrokenHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+func (api *APIServer) actionsCleanRemoteBrokenHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []interface{}) ([]interface{}, error) {
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		log.Warn().Err(ErrAPILocked).Send()
 		return actionsResults, ErrAPILocked
 	}
-	commandId, _ := status.Current.Start(command)
+	commandId, _, err := status.Current.StartWithSharedLock(command)
+	if err != nil {
+		return actionsResults, err
+	}
 	cfg, err := api.ReloadConfig(w, "clean_remote_broken")
 	if err != nil {
 		status.Current.Stop(commandId, err)
@@ -518,7 +705,7 @@ func (api *APIServer) actionsCleanRemoteBrokenHandler(w http.ResponseWriter, row
 	return actionsResults, nil
 }
 
-func (api *APIServer) actionsWatchHandler(w http.ResponseWriter, row status.ActionRow, args []string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
+func (api *APIServer) actionsWatchHandler(w http.ResponseWriter, row status.ActionRow, args []string, actionsResults []interface{}) ([]interface{}, error) {
 	if (!api.config.API.AllowParallel && status.Current.InProgress()) || status.Current.CheckCommandInProgress(row.Command) {
 		log.Warn().Err(ErrAPILocked).Send()
 		return actionsResults, ErrAPILocked
@@ -594,7 +781,7 @@ func (api *APIServer) actionsWatchHandler(w http.ResponseWriter, row status.Acti
 
 	commandId, _ := status.Current.Start(fullCommand)
 	go func() {
-		b := backup.NewBackuper(cfg)
+		b := backup.NewBackuper(cfg, backup.WithTransferObserver(api.metrics.ObserveTransfer))
 		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, skipCheckPartsColumns, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
 		api.handleWatchResponse(commandId, err)
 	}()
@@ -668,11 +855,33 @@ func (api *APIServer) httpRestartHandler(w http.ResponseWriter, _ *http.Request)
 
 // httpVersionHandler
 func (api *APIServer) httpVersionHandler(w http.ResponseWriter, _ *http.Request) {
-	api.sendJSONEachRow(w, http.StatusOK, struct {
-		Version string `json:"version"`
-	}{
-		Version: api.cliApp.Version,
-	})
+	api.sendJSONEachRow(w, http.StatusOK, api.getBuildInfo())
+}
+
+type buildInfo struct {
+	Version         string   `json:"version"`
+	GitCommit       string   `json:"git_commit"`
+	BuildDate       string   `json:"build_date"`
+	GoVersion       string   `json:"go_version"`
+	StorageBackends []string `json:"storage_backends"`
+}
+
+// getBuildInfo - gitCommit and buildDate are passed from main package via cli.App.Metadata
+func (api *APIServer) getBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:         api.cliApp.Version,
+		GitCommit:       "unknown",
+		BuildDate:       "unknown",
+		GoVersion:       runtime.Version(),
+		StorageBackends: append(append([]string{}, storage.SupportedRemoteStorages...), "custom"),
+	}
+	if gitCommit, ok := api.cliApp.Metadata["gitCommit"].(string); ok {
+		info.GitCommit = gitCommit
+	}
+	if buildDate, ok := api.cliApp.Metadata["buildDate"].(string); ok {
+		info.BuildDate = buildDate
+	}
+	return info
 }
 
 // httpKillHandler - kill selected command if it InProgress
@@ -713,25 +922,34 @@ func (api *APIServer) httpTablesHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
-	b := backup.NewBackuper(cfg)
 	q := r.URL.Query()
+	remoteBackup, _ := api.getQueryParameter(q, "remote_backup")
+	tables, err := api.getTables(context.Background(), cfg, q.Get("table"), remoteBackup, r.URL.Path == "/backup/tables/all")
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, "tables", err)
+		return
+	}
+	api.sendJSONEachRow(w, http.StatusOK, tables)
+}
+
+// getTables - tables from clickhouse or from remote backup when remoteBackup is not empty, tables matched with skip_tables are excluded when !all
+func (api *APIServer) getTables(ctx context.Context, cfg *config.Config, tablePattern, remoteBackup string, all bool) ([]clickhouse.Table, error) {
+	b := backup.NewBackuper(cfg)
 	var tables []clickhouse.Table
+	var err error
 	// https://github.com/Altinity/clickhouse-backup/issues/778
-	if remoteBackup, exists := api.getQueryParameter(q, "remote_backup"); exists {
-		tables, err = b.GetTablesRemote(context.Background(), remoteBackup, q.Get("table"))
+	if remoteBackup != "" {
+		tables, err = b.GetTablesRemote(ctx, remoteBackup, tablePattern)
 	} else {
-		tables, err = b.GetTables(context.Background(), q.Get("table"))
+		tables, err = b.GetTables(ctx, tablePattern)
 	}
 	if err != nil {
-		api.writeError(w, http.StatusInternalServerError, "tables", err)
-		return
+		return nil, err
 	}
-	if r.URL.Path == "/backup/tables/all" {
-		api.sendJSONEachRow(w, http.StatusOK, tables)
-		return
+	if all {
+		return tables, nil
 	}
-	tables = api.getTablesWithSkip(tables)
-	api.sendJSONEachRow(w, http.StatusOK, tables)
+	return api.getTablesWithSkip(tables), nil
 }
 
 func (api *APIServer) getTablesWithSkip(tables []clickhouse.Table) []clickhouse.Table {
@@ -752,6 +970,25 @@ func (api *APIServer) getTablesWithSkip(tables []clickhouse.Table) []clickhouse.
 	return showTables
 }
 
+// backupJSON - row of GET /backup/list and `list` command in POST /backup/actions
+type backupJSON struct {
+	Name               string            `json:"name"`
+	Created            string            `json:"created"`
+	Size               uint64            `json:"size,omitempty"`
+	Location           string            `json:"location"`
+	RequiredBackup     string            `json:"required"`
+	Desc               string            `json:"desc"`
+	DatabaseSizes      map[string]uint64 `json:"database_sizes,omitempty"`
+	RequiredBackupName string            `json:"required_backup"`
+	Incremental        bool              `json:"incremental"`
+	DataSize           uint64            `json:"data_size"`
+	MetadataSize       uint64            `json:"metadata_size"`
+	CompressedSize     uint64            `json:"compressed_size"`
+	Pinned             bool              `json:"pinned"`
+	UserTags           map[string]string `json:"user_tags,omitempty"`
+	CustomMetadata     map[string]string `json:"custom_metadata,omitempty"`
+}
+
 // httpListHandler - display list of all backups stored locally and remotely, could run in parallel independent of allow_parallel=true
 // CREATE TABLE system.backup_list (name String, created DateTime, size Int64, location String, desc String) ENGINE=URL('http://127.0.0.1:7171/backup/list?user=user&pass=pass', JSONEachRow)
 // SELECT * FROM system.backup_list
@@ -760,16 +997,6 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 		api.sendJSONEachRow(w, http.StatusOK, "")
 		return
 	}
-
-	type backupJSON struct {
-		Name           string `json:"name"`
-		Created        string `json:"created"`
-		Size           uint64 `json:"size,omitempty"`
-		Location       string `json:"location"`
-		RequiredBackup string `json:"required"`
-		Desc           string `json:"desc"`
-	}
-	backupsJSON := make([]backupJSON, 0)
 	cfg, err := api.ReloadConfig(w, "list")
 	if err != nil {
 		return
@@ -777,22 +1004,45 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	where, wherePresent := vars["where"]
 	fullCommand := "list"
+	userTagsFilter := make(map[string]string)
+	if tags, exist := r.URL.Query()["tag"]; exist {
+		if userTagsFilter, err = metadata.ParseUserTags(tags); err != nil {
+			api.writeError(w, http.StatusBadRequest, "list", err)
+			return
+		}
+		fullCommand = fmt.Sprintf("%s --tag=\"%s\"", fullCommand, strings.Join(tags, "\" --tag=\""))
+	}
 	if wherePresent {
 		fullCommand += " " + where
 	}
 	commandId, ctx := status.Current.Start(fullCommand)
-	defer status.Current.Stop(commandId, err)
+	backupsJSON, err := api.getBackupList(ctx, cfg, where)
+	status.Current.Stop(commandId, err)
 	if err != nil {
 		api.writeError(w, http.StatusInternalServerError, "list", err)
 		return
 	}
+	// filter after getBackupList to keep backup metrics for all backups
+	if len(userTagsFilter) > 0 {
+		filtered := make([]backupJSON, 0, len(backupsJSON))
+		for _, item := range backupsJSON {
+			if (&metadata.BackupMetadata{UserTags: item.UserTags}).MatchAllUserTags(userTagsFilter) {
+				filtered = append(filtered, item)
+			}
+		}
+		backupsJSON = filtered
+	}
+	api.sendJSONEachRow(w, http.StatusOK, backupsJSON)
+}
+
+// getBackupList - local and remote backups, empty where means both locations
+func (api *APIServer) getBackupList(ctx context.Context, cfg *config.Config, where string) ([]backupJSON, error) {
+	backupsJSON := make([]backupJSON, 0)
 	b := backup.NewBackuper(cfg)
-	if where == "local" || !wherePresent {
-		var localBackups []backup.LocalBackup
-		localBackups, _, err = b.GetLocalBackups(ctx, nil)
+	if where == "local" || where == "" {
+		localBackups, _, err := b.GetLocalBackups(ctx, nil)
 		if err != nil && !os.IsNotExist(err) {
-			api.writeError(w, http.StatusInternalServerError, "list", err)
-			return
+			return nil, err
 		}
 		for _, item := range localBackups {
 			description := item.DataFormat
@@ -806,22 +1056,30 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 				description += item.Tags
 			}
 			backupsJSON = append(backupsJSON, backupJSON{
-				Name:           item.BackupName,
-				Created:        item.CreationDate.Format(common.TimeFormat),
-				Size:           item.GetFullSize(),
-				Location:       "local",
-				RequiredBackup: item.RequiredBackup,
-				Desc:           description,
+				Name:               item.BackupName,
+				Created:            common.FormatAPITime(item.CreationDate),
+				Size:               item.GetFullSize(),
+				Location:           "local",
+				RequiredBackup:     item.RequiredBackup,
+				Desc:               description,
+				DatabaseSizes:      item.DatabaseSizes,
+				RequiredBackupName: item.RequiredBackup,
+				Incremental:        item.RequiredBackup != "",
+				DataSize:           item.DataSize,
+				MetadataSize:       item.MetadataSize,
+				CompressedSize:     item.CompressedSize,
+				Pinned:             item.Pinned,
+				UserTags:           item.UserTags,
+				CustomMetadata:     item.CustomMetadata,
 			})
 		}
 		api.metrics.NumberBackupsLocal.Set(float64(len(localBackups)))
 	}
-	if cfg.General.RemoteStorage != "none" && (where == "remote" || !wherePresent) {
+	if cfg.General.RemoteStorage != "none" && (where == "remote" || where == "") {
 		brokenBackups := 0
 		remoteBackups, err := b.GetRemoteBackups(ctx, true)
 		if err != nil {
-			api.writeError(w, http.StatusInternalServerError, "list", err)
-			return
+			return nil, err
 		}
 		for i, b := range remoteBackups {
 			description := b.DataFormat
@@ -837,12 +1095,21 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			fullSize := b.GetFullSize()
 			backupsJSON = append(backupsJSON, backupJSON{
-				Name:           b.BackupName,
-				Created:        b.CreationDate.Format(common.TimeFormat),
-				Size:           fullSize,
-				Location:       "remote",
-				RequiredBackup: b.RequiredBackup,
-				Desc:           description,
+				Name:               b.BackupName,
+				Created:            common.FormatAPITime(b.CreationDate),
+				Size:               fullSize,
+				Location:           "remote",
+				RequiredBackup:     b.RequiredBackup,
+				Desc:               description,
+				DatabaseSizes:      b.DatabaseSizes,
+				RequiredBackupName: b.RequiredBackup,
+				Incremental:        b.RequiredBackup != "",
+				DataSize:           b.DataSize,
+				MetadataSize:       b.MetadataSize,
+				CompressedSize:     b.CompressedSize,
+				Pinned:             b.Pinned,
+				UserTags:           b.UserTags,
+				CustomMetadata:     b.CustomMetadata,
 			})
 			if i == len(remoteBackups)-1 {
 				api.metrics.LastBackupSizeRemote.Set(float64(fullSize))
@@ -851,11 +1118,15 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 		api.metrics.NumberBackupsRemoteBroken.Set(float64(brokenBackups))
 		api.metrics.NumberBackupsRemote.Set(float64(len(remoteBackups)))
 	}
-	api.sendJSONEachRow(w, http.StatusOK, backupsJSON)
+	return backupsJSON, nil
 }
 
 // httpCreateHandler - create a backup
 func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := api.getIdempotencyKey(r)
+	if api.replayIdempotentRequest(w, idempotencyKey, "create") {
+		return
+	}
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "create", ErrAPILocked)
@@ -868,7 +1139,7 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 	tablePattern := ""
 	diffFromRemote := ""
 	partitionsToBackup := make([]string, 0)
-	backupName := backup.NewBackupName()
+	backupName := ""
 	schemaOnly := false
 	createRBAC := false
 	rbacOnly := false
@@ -876,6 +1147,7 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 	configsOnly := false
 	checkPartsColumns := true
 	resume := false
+	strict := false
 	fullCommand := "create"
 	query := r.URL.Query()
 	operationId, _ := uuid.NewUUID()
@@ -884,6 +1156,11 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
 	}
+	if tablePattern, fullCommand, err = applyDatabaseQueryParameter(query, tablePattern, fullCommand); err != nil {
+		api.writeError(w, http.StatusBadRequest, "create", err)
+		return
+	}
+	excludePatterns, fullCommand := applyExcludeQueryParameter(query, fullCommand)
 	if baseBackup, exists := api.getQueryParameter(query, "diff-from-remote"); exists {
 		diffFromRemote = baseBackup
 	}
@@ -922,9 +1199,43 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 		fullCommand += " --resume"
 	}
 
+	if _, exist := query["strict"]; exist {
+		strict = true
+		fullCommand += " --strict"
+	}
+
+	userTags := make(map[string]string)
+	if tags, exist := query["tag"]; exist {
+		if userTags, err = metadata.ParseUserTags(tags); err != nil {
+			api.writeError(w, http.StatusBadRequest, "create", err)
+			return
+		}
+		fullCommand = fmt.Sprintf("%s --tag=\"%s\"", fullCommand, strings.Join(tags, "\" --tag=\""))
+	}
+	customMetadata := make(map[string]string)
+	if items, exist := query["metadata"]; exist {
+		if customMetadata, err = metadata.ParseCustomMetadata(items); err != nil {
+			api.writeError(w, http.StatusBadRequest, "create", err)
+			return
+		}
+		fullCommand = fmt.Sprintf("%s --metadata=\"%s\"", fullCommand, strings.Join(items, "\" --metadata=\""))
+	}
+	includeDetached := false
+	if _, exist := api.getQueryParameter(query, "include_detached"); exist {
+		includeDetached = true
+		fullCommand += " --include-detached"
+	}
+
 	if name, exist := query["name"]; exist {
 		backupName = utils.CleanBackupNameRE.ReplaceAllString(name[0], "")
 		fullCommand = fmt.Sprintf("%s %s", fullCommand, backupName)
+	} else {
+		ch := &clickhouse.ClickHouse{Config: &cfg.ClickHouse}
+		if backupName, err = backup.NewBackupNameFromTemplate(context.Background(), ch, cfg.General.BackupNameTemplate); err != nil {
+			log.Error().Err(err).Send()
+			api.writeError(w, http.StatusInternalServerError, "create", err)
+			return
+		}
 	}
 
 	callback, err := parseCallback(query)
@@ -934,10 +1245,20 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	commandId, _ := status.Current.Start(fullCommand)
+	if api.reserveIdempotencyKey(w, idempotencyKey, idempotencyEntry{operation: "create", backupName: backupName, operationId: operationId.String()}) {
+		return
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		api.idempotencyKeys.release(idempotencyKey)
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "create", err)
+		return
+	}
+	api.idempotencyKeys.setCommandId(idempotencyKey, commandId)
 	go func() {
 		err, _ := api.metrics.ExecuteWithMetrics("create", 0, func() error {
-			b := backup.NewBackuper(cfg)
+			b := backup.NewBackuper(cfg, backup.WithStrictTablePattern(strict), backup.WithExcludeTables(excludePatterns), backup.WithUserTags(userTags), backup.WithCustomMetadata(customMetadata), backup.WithIncludeDetached(includeDetached), backup.WithTransferObserver(api.metrics.ObserveTransfer))
 			return b.CreateBackup(backupName, diffFromRemote, tablePattern, partitionsToBackup, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, checkPartsColumns, resume, api.clickhouseBackupVersion, commandId)
 		})
 		if err != nil {
@@ -1006,6 +1327,10 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
 	}
+	if tablePattern, fullCommand, err = applyDatabaseQueryParameter(query, tablePattern, fullCommand); err != nil {
+		api.writeError(w, http.StatusBadRequest, "watch", err)
+		return
+	}
 	if partitions, exist := query["partitions"]; exist {
 		partitionsToBackup = append(partitionsToBackup, partitions...)
 		fullCommand = fmt.Sprintf("%s --partitions=\"%s\"", fullCommand, strings.Join(partitions, "\" --partitions=\""))
@@ -1041,7 +1366,7 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 
 	commandId, _ := status.Current.Start(fullCommand)
 	go func() {
-		b := backup.NewBackuper(cfg)
+		b := backup.NewBackuper(cfg, backup.WithTransferObserver(api.metrics.ObserveTransfer))
 		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, skipCheckPartsColumns, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
 		api.handleWatchResponse(commandId, err)
 	}()
@@ -1057,10 +1382,23 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // httpCleanHandler - clean ./shadow directory
-func (api *APIServer) httpCleanHandler(w http.ResponseWriter, _ *http.Request) {
+func (api *APIServer) httpCleanHandler(w http.ResponseWriter, r *http.Request) {
+	if location, exists := api.getQueryParameter(r.URL.Query(), "location"); exists {
+		api.httpCleanRetentionHandler(w, r, location)
+		return
+	}
+	if _, exists := api.getQueryParameter(r.URL.Query(), "broken"); exists {
+		api.httpCleanBrokenHandler(w, r)
+		return
+	}
 	var err error
 	fullCommand := "clean"
-	commandId, ctx := status.Current.Start(fullCommand)
+	commandId, ctx, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "clean", err)
+		return
+	}
 	b := backup.NewBackuper(api.config)
 	err = b.Clean(ctx)
 	defer status.Current.Stop(commandId, err)
@@ -1078,13 +1416,119 @@ func (api *APIServer) httpCleanHandler(w http.ResponseWriter, _ *http.Request) {
 	})
 }
 
+// httpCleanRetentionHandler - run retention for `location` immediately, `dry_run` returns backups which would be deleted
+func (api *APIServer) httpCleanRetentionHandler(w http.ResponseWriter, r *http.Request, location string) {
+	cfg, err := api.ReloadConfig(w, "clean")
+	if err != nil {
+		return
+	}
+	_, dryRun := api.getQueryParameter(r.URL.Query(), "dry_run")
+	fullCommand := fmt.Sprintf("clean --location=%s", location)
+	if dryRun {
+		fullCommand += " --dry-run"
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "clean", err)
+		return
+	}
+	b := backup.NewBackuper(cfg)
+	var backupsToDelete []string
+	backupsToDelete, err = b.CleanRetention(location, dryRun, commandId)
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		log.Error().Msgf("Clean retention error: %v", err)
+		api.writeError(w, http.StatusInternalServerError, "clean", err)
+		return
+	}
+	if !dryRun && len(backupsToDelete) > 0 {
+		go func() {
+			if metricsErr := api.UpdateBackupMetrics(context.Background(), location == "local"); metricsErr != nil {
+				log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+			}
+		}()
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status    string   `json:"status"`
+		Operation string   `json:"operation"`
+		Location  string   `json:"location"`
+		DryRun    bool     `json:"dry_run"`
+		Backups   []string `json:"backups"`
+	}{
+		Status:    "success",
+		Operation: "clean",
+		Location:  location,
+		DryRun:    dryRun,
+		Backups:   backupsToDelete,
+	})
+}
+
+// httpCleanBrokenHandler - remove broken local backups, `dry_run` returns backups which would be removed
+func (api *APIServer) httpCleanBrokenHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.config.API.AllowParallel && status.Current.InProgress() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "clean", ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(w, "clean")
+	if err != nil {
+		return
+	}
+	_, dryRun := api.getQueryParameter(r.URL.Query(), "dry_run")
+	fullCommand := "clean --broken"
+	if dryRun {
+		fullCommand += " --dry-run"
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "clean", err)
+		return
+	}
+	b := backup.NewBackuper(cfg)
+	var brokenBackups []string
+	brokenBackups, err = b.CleanLocalBroken(dryRun, commandId)
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		log.Error().Msgf("Clean broken error: %v", err)
+		api.writeError(w, http.StatusInternalServerError, "clean", err)
+		return
+	}
+	if !dryRun && len(brokenBackups) > 0 {
+		go func() {
+			if metricsErr := api.UpdateBackupMetrics(context.Background(), true); metricsErr != nil {
+				log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+			}
+		}()
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status    string   `json:"status"`
+		Operation string   `json:"operation"`
+		Location  string   `json:"location"`
+		DryRun    bool     `json:"dry_run"`
+		Backups   []string `json:"backups"`
+	}{
+		Status:    "success",
+		Operation: "clean",
+		Location:  "local",
+		DryRun:    dryRun,
+		Backups:   brokenBackups,
+	})
+}
+
 // httpCleanRemoteBrokenHandler - delete all remote backups with `broken` in description
 func (api *APIServer) httpCleanRemoteBrokenHandler(w http.ResponseWriter, _ *http.Request) {
 	cfg, err := api.ReloadConfig(w, "clean_remote_broken")
 	if err != nil {
 		return
 	}
-	commandId, _ := status.Current.Start("clean_remote_broken")
+	commandId, _, err := status.Current.StartWithSharedLock("clean_remote_broken")
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "clean_remote_broken", err)
+		return
+	}
 	defer status.Current.Stop(commandId, err)
 
 	b := backup.NewBackuper(cfg)
@@ -1111,6 +1555,10 @@ func (api *APIServer) httpCleanRemoteBrokenHandler(w http.ResponseWriter, _ *htt
 
 // httpUploadHandler - upload a backup to remote storage
 func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := api.getIdempotencyKey(r)
+	if api.replayIdempotentRequest(w, idempotencyKey, "upload") {
+		return
+	}
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "upload", ErrAPILocked)
@@ -1150,6 +1598,11 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
 	}
+	if tablePattern, fullCommand, err = applyDatabaseQueryParameter(query, tablePattern, fullCommand); err != nil {
+		api.writeError(w, http.StatusBadRequest, "upload", err)
+		return
+	}
+	excludePatterns, fullCommand := applyExcludeQueryParameter(query, fullCommand)
 	if partitions, exist := query["partitions"]; exist {
 		partitionsToBackup = append(partitionsToBackup, partitions...)
 		fullCommand = fmt.Sprintf("%s --partitions=\"%s\"", fullCommand, strings.Join(partitions, "\" --partitions=\""))
@@ -1176,10 +1629,20 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if api.reserveIdempotencyKey(w, idempotencyKey, idempotencyEntry{operation: "upload", backupName: name, operationId: operationId.String()}) {
+		return
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		api.idempotencyKeys.release(idempotencyKey)
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "upload", err)
+		return
+	}
+	api.idempotencyKeys.setCommandId(idempotencyKey, commandId)
 	go func() {
-		commandId, _ := status.Current.Start(fullCommand)
 		err, _ := api.metrics.ExecuteWithMetrics("upload", 0, func() error {
-			b := backup.NewBackuper(cfg)
+			b := backup.NewBackuper(cfg, backup.WithExcludeTables(excludePatterns), backup.WithTransferObserver(api.metrics.ObserveTransfer))
 			return b.Upload(name, deleteSource, diffFrom, diffFromRemote, tablePattern, partitionsToBackup, schemaOnly, resume, api.cliApp.Version, commandId)
 		})
 		if err != nil {
@@ -1218,6 +1681,10 @@ var tableMappingRE = regexp.MustCompile(`[\w+]:[\w+]`)
 
 // httpRestoreHandler - restore a backup from local storage
 func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := api.getIdempotencyKey(r)
+	if api.replayIdempotentRequest(w, idempotencyKey, "restore") {
+		return
+	}
 	if !api.config.API.AllowParallel && status.Current.InProgress() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "restore", ErrAPILocked)
@@ -1241,6 +1708,7 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 	restoreConfigs := false
 	configsOnly := false
 	resume := false
+	replicatedDDLWait := false
 	fullCommand := "restore"
 	operationId, _ := uuid.NewUUID()
 
@@ -1249,6 +1717,11 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
 	}
+	if tablePattern, fullCommand, err = applyDatabaseQueryParameter(query, tablePattern, fullCommand); err != nil {
+		api.writeError(w, http.StatusBadRequest, "restore", err)
+		return
+	}
+	excludePatterns, fullCommand := applyExcludeQueryParameter(query, fullCommand)
 	databaseMappingQueryParamName := "restore_database_mapping"
 	databaseMappingQueryParamNames := []string{
 		strings.Replace(databaseMappingQueryParamName, "_", "-", -1),
@@ -1343,6 +1816,50 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		resume = true
 		fullCommand += " --resume"
 	}
+	if _, exist := api.getQueryParameter(query, "replicated_ddl_wait"); exist {
+		replicatedDDLWait = true
+		fullCommand += " --replicated-ddl-wait"
+	}
+	restoreSchemaOnCluster := ""
+	if cluster, exist := api.getQueryParameter(query, "restore_schema_on_cluster"); exist {
+		restoreSchemaOnCluster = cluster
+		fullCommand = fmt.Sprintf("%s --restore-schema-on-cluster=\"%s\"", fullCommand, cluster)
+	}
+	replicatedToMergeTree := false
+	if _, exist := api.getQueryParameter(query, "replicated_to_merge_tree"); exist {
+		replicatedToMergeTree = true
+		fullCommand += " --replicated-to-merge-tree"
+	}
+	insecureMetadata := false
+	if _, exist := query["insecure"]; exist {
+		insecureMetadata = true
+		fullCommand += " --insecure"
+	}
+	rebuildProjections := false
+	if _, exist := api.getQueryParameter(query, "rebuild_projections"); exist {
+		rebuildProjections = true
+		fullCommand += " --rebuild-projections"
+	}
+	ignoreMissingTables := false
+	if _, exist := api.getQueryParameter(query, "ignore_missing"); exist {
+		ignoreMissingTables = true
+		fullCommand += " --ignore-missing"
+	}
+	strict := false
+	if _, exist := api.getQueryParameter(query, "strict"); exist {
+		strict = true
+		fullCommand += " --strict"
+	}
+	includeDetached := false
+	if _, exist := api.getQueryParameter(query, "include_detached"); exist {
+		includeDetached = true
+		fullCommand += " --include-detached"
+	}
+	attachOnly := false
+	if _, exist := api.getQueryParameter(query, "attach_only"); exist {
+		attachOnly = true
+		fullCommand += " --attach-only"
+	}
 
 	name := utils.CleanBackupNameRE.ReplaceAllString(vars["name"], "")
 	fullCommand += fmt.Sprintf(" %s", name)
@@ -1354,11 +1871,21 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	commandId, _ := status.Current.Start(fullCommand)
+	if api.reserveIdempotencyKey(w, idempotencyKey, idempotencyEntry{operation: "restore", backupName: name, operationId: operationId.String()}) {
+		return
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		api.idempotencyKeys.release(idempotencyKey)
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "restore", err)
+		return
+	}
+	api.idempotencyKeys.setCommandId(idempotencyKey, commandId)
 	go func() {
 		err, _ := api.metrics.ExecuteWithMetrics("restore", 0, func() error {
-			b := backup.NewBackuper(api.config)
-			return b.Restore(name, tablePattern, databaseMappingToRestore, tableMappingToRestore, partitionsToBackup, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume, api.cliApp.Version, commandId)
+			b := backup.NewBackuper(api.config, backup.WithExcludeTables(excludePatterns), backup.WithRestoreSchemaOnCluster(restoreSchemaOnCluster), backup.WithReplicatedToMergeTree(replicatedToMergeTree), backup.WithInsecureMetadata(insecureMetadata), backup.WithRebuildProjections(rebuildProjections), backup.WithIgnoreMissingTables(ignoreMissingTables), backup.WithStrictTablePattern(strict), backup.WithAttachOnly(attachOnly), backup.WithIncludeDetached(includeDetached), backup.WithTransferObserver(api.metrics.ObserveTransfer))
+			return b.Restore(name, tablePattern, databaseMappingToRestore, tableMappingToRestore, partitionsToBackup, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume, replicatedDDLWait, api.cliApp.Version, commandId)
 		})
 		go func() {
 			if metricsErr := api.UpdateBackupMetrics(context.Background(), true); metricsErr != nil {
@@ -1412,6 +1939,11 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
 	}
+	if tablePattern, fullCommand, err = applyDatabaseQueryParameter(query, tablePattern, fullCommand); err != nil {
+		api.writeError(w, http.StatusBadRequest, "download", err)
+		return
+	}
+	excludePatterns, fullCommand := applyExcludeQueryParameter(query, fullCommand)
 	if partitions, exist := query["partitions"]; exist {
 		partitionsToBackup = append(partitionsToBackup, partitions...)
 		fullCommand = fmt.Sprintf("%s --partitions=\"%s\"", fullCommand, strings.Join(partitions, "\" --partitions=\""))
@@ -1428,6 +1960,11 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 		resume = true
 		fullCommand += " --resume"
 	}
+	insecureMetadata := false
+	if _, exist := query["insecure"]; exist {
+		insecureMetadata = true
+		fullCommand += " --insecure"
+	}
 
 	fullCommand += fmt.Sprintf(" %s", name)
 
@@ -1438,10 +1975,15 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "download", err)
+		return
+	}
 	go func() {
-		commandId, _ := status.Current.Start(fullCommand)
 		err, _ := api.metrics.ExecuteWithMetrics("download", 0, func() error {
-			b := backup.NewBackuper(cfg)
+			b := backup.NewBackuper(cfg, backup.WithExcludeTables(excludePatterns), backup.WithInsecureMetadata(insecureMetadata), backup.WithTransferObserver(api.metrics.ObserveTransfer))
 			return b.Download(name, tablePattern, partitionsToBackup, schemaOnly, resume, api.cliApp.Version, commandId)
 		})
 		if err != nil {
@@ -1484,12 +2026,33 @@ func (api *APIServer) httpDeleteHandler(w http.ResponseWriter, r *http.Request)
 	}
 	vars := mux.Vars(r)
 	fullCommand := fmt.Sprintf("delete %s %s", vars["where"], vars["name"])
-	commandId, ctx := status.Current.Start(fullCommand)
-	b := backup.NewBackuper(cfg)
+	databases, deleteDatabases := r.URL.Query()["database"]
+	if deleteDatabases {
+		fullCommand = fmt.Sprintf("delete %s --database=\"%s\" %s", vars["where"], strings.Join(databases, ","), vars["name"])
+	}
+	_, force := api.getQueryParameter(r.URL.Query(), "force")
+	if force {
+		fullCommand = strings.Replace(fullCommand, "delete ", "delete --force ", 1)
+	}
+	commandId, ctx, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "delete", err)
+		return
+	}
+	b := backup.NewBackuper(cfg, backup.WithDeletePinned(force))
 	switch vars["where"] {
 	case "local":
-		err = b.RemoveBackupLocal(ctx, vars["name"], nil)
+		if deleteDatabases {
+			err = b.RemoveDatabasesFromBackupLocal(ctx, vars["name"], databases)
+		} else {
+			err = b.RemoveBackupLocal(ctx, vars["name"], nil)
+		}
 	case "remote":
+		if deleteDatabases {
+			err = fmt.Errorf("database query parameter supported only for local backups")
+			break
+		}
 		err = b.RemoveBackupRemote(ctx, vars["name"])
 	default:
 		err = fmt.Errorf("backup location must be 'local' or 'remote'")
@@ -1518,6 +2081,169 @@ func (api *APIServer) httpDeleteHandler(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// httpPinHandler - set or reset `pinned` flag for local and remote backup, optional `location` query parameter restricts storage
+func (api *APIServer) httpPinHandler(w http.ResponseWriter, r *http.Request) {
+	pinned := !strings.HasPrefix(r.URL.Path, "/backup/unpin/")
+	operation := "pin"
+	if !pinned {
+		operation = "unpin"
+	}
+	cfg, err := api.ReloadConfig(w, operation)
+	if err != nil {
+		return
+	}
+	vars := mux.Vars(r)
+	location, _ := api.getQueryParameter(r.URL.Query(), "location")
+	fullCommand := fmt.Sprintf("%s %s", operation, vars["name"])
+	if location != "" {
+		fullCommand = fmt.Sprintf("%s --location=%s %s", operation, location, vars["name"])
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, operation, err)
+		return
+	}
+	b := backup.NewBackuper(cfg)
+	err = b.Pin(location, vars["name"], pinned, commandId)
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		log.Error().Msgf("%s backup error: %v", operation, err)
+		api.writeError(w, http.StatusInternalServerError, operation, err)
+		return
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status     string `json:"status"`
+		Operation  string `json:"operation"`
+		BackupName string `json:"backup_name"`
+		Location   string `json:"location,omitempty"`
+	}{
+		Status:     "success",
+		Operation:  operation,
+		BackupName: vars["name"],
+		Location:   location,
+	})
+}
+
+// httpRenameHandler - rename local and remote backup, optional `location` query parameter restricts storage
+func (api *APIServer) httpRenameHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.config.API.AllowParallel && status.Current.InProgress() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "rename", ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(w, "rename")
+	if err != nil {
+		return
+	}
+	vars := mux.Vars(r)
+	location, _ := api.getQueryParameter(r.URL.Query(), "location")
+	fullCommand := fmt.Sprintf("rename %s %s", vars["name"], vars["new_name"])
+	if location != "" {
+		fullCommand = fmt.Sprintf("rename --location=%s %s %s", location, vars["name"], vars["new_name"])
+	}
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "rename", err)
+		return
+	}
+	b := backup.NewBackuper(cfg)
+	err = b.Rename(location, vars["name"], vars["new_name"], commandId)
+	status.Current.Stop(commandId, err)
+	if err != nil {
+		log.Error().Msgf("rename backup error: %v", err)
+		api.writeError(w, http.StatusInternalServerError, "rename", err)
+		return
+	}
+	go func() {
+		if metricsErr := api.UpdateBackupMetrics(context.Background(), location == "local"); metricsErr != nil {
+			log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+		}
+	}()
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status        string `json:"status"`
+		Operation     string `json:"operation"`
+		BackupName    string `json:"backup_name"`
+		NewBackupName string `json:"new_backup_name"`
+		Location      string `json:"location,omitempty"`
+	}{
+		Status:        "success",
+		Operation:     "rename",
+		BackupName:    vars["name"],
+		NewBackupName: vars["new_name"],
+		Location:      location,
+	})
+}
+
+// httpCopyHandler - copy remote backup between remote storages, `from` and `to` query parameters are required, executed asynchronously like upload
+func (api *APIServer) httpCopyHandler(w http.ResponseWriter, r *http.Request) {
+	if !api.config.API.AllowParallel && status.Current.InProgress() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "copy", ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(w, "copy")
+	if err != nil {
+		return
+	}
+	name := strings.ReplaceAll(mux.Vars(r)["name"], "/", "")
+	query := r.URL.Query()
+	from, _ := api.getQueryParameter(query, "from")
+	to, _ := api.getQueryParameter(query, "to")
+	if from == "" || to == "" {
+		api.writeError(w, http.StatusBadRequest, "copy", fmt.Errorf("`from` and `to` query parameters are required"))
+		return
+	}
+	for _, remoteName := range []string{from, to} {
+		if err = backup.CheckCopyRemoteName(cfg, remoteName); err != nil {
+			api.writeError(w, http.StatusBadRequest, "copy", err)
+			return
+		}
+	}
+	fullCommand := fmt.Sprintf("copy --from=%s --to=%s %s", from, to, name)
+	operationId, _ := uuid.NewUUID()
+	callback, err := parseCallback(query)
+	if err != nil {
+		log.Error().Err(err).Send()
+		api.writeError(w, http.StatusBadRequest, "copy", err)
+		return
+	}
+
+	commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+	if err != nil {
+		log.Warn().Err(err).Send()
+		api.writeError(w, http.StatusLocked, "copy", err)
+		return
+	}
+	go func() {
+		b := backup.NewBackuper(cfg)
+		err := b.Copy(name, from, to, commandId)
+		status.Current.Stop(commandId, err)
+		if err != nil {
+			log.Error().Msgf("API /backup/copy error: %v", err)
+			api.errorCallback(context.Background(), err, operationId.String(), callback)
+			return
+		}
+		api.successCallback(context.Background(), operationId.String(), callback)
+	}()
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status      string `json:"status"`
+		Operation   string `json:"operation"`
+		BackupName  string `json:"backup_name"`
+		From        string `json:"from"`
+		To          string `json:"to"`
+		OperationId string `json:"operation_id"`
+	}{
+		Status:      "acknowledged",
+		Operation:   "copy",
+		BackupName:  name,
+		From:        from,
+		To:          to,
+		OperationId: operationId.String(),
+	})
+}
+
 func (api *APIServer) httpBackupStatusHandler(w http.ResponseWriter, _ *http.Request) {
 	api.sendJSONEachRow(w, http.StatusOK, status.Current.GetStatus(true, "", 0))
 }
@@ -1539,6 +2265,19 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 	if err != nil {
 		return err
 	}
+	newestLocal, oldestLocal := time.Time{}, time.Time{}
+	for _, localBackup := range localBackups {
+		if localBackup.Broken != "" {
+			continue
+		}
+		if newestLocal.IsZero() || localBackup.CreationDate.After(newestLocal) {
+			newestLocal = localBackup.CreationDate
+		}
+		if oldestLocal.IsZero() || localBackup.CreationDate.Before(oldestLocal) {
+			oldestLocal = localBackup.CreationDate
+		}
+	}
+	api.metrics.SetBackupTimes("local", newestLocal, oldestLocal)
 	if len(localBackups) > 0 {
 		numberBackupsLocal = len(localBackups)
 		lastBackup := localBackups[numberBackupsLocal-1]
@@ -1546,9 +2285,15 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 		lastBackupCreateLocal = &lastBackup.CreationDate
 		api.metrics.LastBackupSizeLocal.Set(float64(lastSizeLocal))
 		api.metrics.NumberBackupsLocal.Set(float64(numberBackupsLocal))
+		if churn := lastBackup.Churn; churn != nil {
+			api.metrics.SetBackupChurn(churn.NewParts, churn.ChangedParts, churn.RemovedParts, churn.NewBytes, churn.ChangedBytes, churn.RemovedBytes)
+		} else {
+			api.metrics.SetBackupChurn(0, 0, 0, 0, 0, 0)
+		}
 	} else {
 		api.metrics.LastBackupSizeLocal.Set(0)
 		api.metrics.NumberBackupsLocal.Set(0)
+		api.metrics.SetBackupChurn(0, 0, 0, 0, 0, 0)
 	}
 	if localDataSize, err = b.GetLocalDataSize(ctx); err != nil {
 		return err
@@ -1577,11 +2322,19 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 	if err != nil {
 		return err
 	}
+	newestRemote, oldestRemote := time.Time{}, time.Time{}
 	if len(remoteBackups) > 0 {
 		numberBackupsRemote = len(remoteBackups)
 		for _, b := range remoteBackups {
 			if b.Broken != "" {
 				numberBackupsRemoteBroken++
+				continue
+			}
+			if newestRemote.IsZero() || b.CreationDate.After(newestRemote) {
+				newestRemote = b.CreationDate
+			}
+			if oldestRemote.IsZero() || b.CreationDate.Before(oldestRemote) {
+				oldestRemote = b.CreationDate
 			}
 		}
 		lastBackup := remoteBackups[numberBackupsRemote-1]
@@ -1591,11 +2344,14 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 		api.metrics.LastBackupSizeRemote.Set(float64(lastSizeRemote))
 		api.metrics.NumberBackupsRemote.Set(float64(numberBackupsRemote))
 		api.metrics.NumberBackupsRemoteBroken.Set(float64(numberBackupsRemoteBroken))
+		api.metrics.SetBackupReplicas(lastBackup.Replicas, backup.ReplicaUploadSuccess)
 	} else {
 		api.metrics.LastBackupSizeRemote.Set(0)
 		api.metrics.NumberBackupsRemote.Set(0)
 		api.metrics.NumberBackupsRemoteBroken.Set(0)
+		api.metrics.SetBackupReplicas(nil, backup.ReplicaUploadSuccess)
 	}
+	api.metrics.SetBackupTimes("remote", newestRemote, oldestRemote)
 
 	if lastBackupCreateLocal != nil {
 		api.metrics.LastFinish["create"].Set(float64(lastBackupCreateLocal.Unix()))
@@ -1628,6 +2384,20 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 	return nil
 }
 
+// UpdateBackupMetricsPeriodically - refresh backup inventory metrics every `api.backup_metrics_update_interval`, not only after operations
+func (api *APIServer) UpdateBackupMetricsPeriodically() {
+	for {
+		if metricsErr := api.UpdateBackupMetrics(context.Background(), false); metricsErr != nil {
+			log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+		}
+		interval := api.config.API.BackupMetricsUpdateDuration
+		if interval <= 0 {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
 func (api *APIServer) registerMetricsHandlers(r *mux.Router, enableMetrics bool, enablePprof bool) {
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		api.sendJSONEachRow(w, http.StatusOK, struct {
@@ -1636,6 +2406,7 @@ func (api *APIServer) registerMetricsHandlers(r *mux.Router, enableMetrics bool,
 			Status: "OK",
 		})
 	})
+	r.HandleFunc("/health/full", api.httpHealthFullHandler)
 	if enableMetrics {
 		r.Handle("/metrics", promhttp.Handler())
 	}
@@ -1687,6 +2458,10 @@ func (api *APIServer) CreateIntegrationTables() error {
 	}
 	if version >= 21001000 {
 		settings = "SETTINGS input_format_skip_unknown_fields=1"
+		// api.time_format and api.timezone could produce timestamps which not parsed by `basic` DateTime input format
+		if api.config.API.TimeFormat != "" && api.config.API.TimeFormat != "default" {
+			settings += ", date_time_input_format='best_effort'"
+		}
 	}
 	disks, err := ch.GetDisks(context.Background(), true)
 	if err != nil {
@@ -1721,6 +2496,7 @@ func (api *APIServer) ReloadConfig(w http.ResponseWriter, command string) (*conf
 		return nil, err
 	}
 	api.config = cfg
+	common.SetAPITimeFormat(cfg.API.TimeFormat, cfg.API.TimeLocation)
 	api.metrics.NumberBackupsRemoteExpected.Set(float64(cfg.General.BackupsToKeepRemote))
 	api.metrics.NumberBackupsLocalExpected.Set(float64(cfg.General.BackupsToKeepLocal))
 	return cfg, nil
@@ -1804,7 +2580,10 @@ func (api *APIServer) ResumeOperationsAfterRestart() error {
 					args = append(args, "--resumable=1", backupName)
 					fullCommand := strings.Join(args, " ")
 					log.Info().Str("operation", "ResumeOperationsAfterRestart").Send()
-					commandId, _ := status.Current.Start(fullCommand)
+					commandId, _, err := status.Current.StartWithSharedLock(fullCommand)
+					if err != nil {
+						return err
+					}
 					err, _ = api.metrics.ExecuteWithMetrics(command, 0, func() error {
 						return api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
 					})
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/backup"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/hostlock"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/keeper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/rs/zerolog/log"
+)
+
+// initSharedLock - connect to keeper from clickhouse-server config.xml, keeper session shall live until process exit, to keep ephemeral lock node
+func (api *APIServer) initSharedLock(cfg *config.Config) error {
+	if cfg.API.SharedLock == "" || cfg.API.SharedLock == "none" {
+		return nil
+	}
+	ctx := context.Background()
+	ch := &clickhouse.ClickHouse{
+		Config: &cfg.ClickHouse,
+	}
+	if err := ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer ch.Close()
+	lockPath, err := ch.ApplyMacros(ctx, cfg.API.SharedLockPath)
+	if err != nil {
+		return fmt.Errorf("can't apply macros to api shared_lock_path: %v", err)
+	}
+	k := &keeper.Keeper{}
+	if err = k.Connect(ctx, ch); err != nil {
+		return fmt.Errorf("can't connect to keeper for api shared_lock: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	status.Current.SetSharedLock(k.NewLock(lockPath, owner))
+	log.Info().Msgf("API uses shared lock %s in keeper as %s", lockPath, owner)
+	return nil
+}
+
+// initHostLock - `general->lock_file` is held while any API command which changes backups in progress, CLI commands from cron use the same file, see hostLockAction in main.go
+func (api *APIServer) initHostLock(cfg *config.Config) {
+	if cfg.General.LockFile == "none" {
+		return
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d server", hostname, os.Getpid())
+	status.Current.AddSharedLock(&lazyHostLock{cfg: cfg, owner: owner})
+}
+
+// lazyHostLock - default `general->lock_file` depends on default disk path, so it is resolved when clickhouse-server is available, instead of API server start
+type lazyHostLock struct {
+	cfg   *config.Config
+	owner string
+	lock  *hostlock.Lock
+	mu    sync.Mutex
+}
+
+func (l *lazyHostLock) get() (*hostlock.Lock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lock != nil {
+		return l.lock, nil
+	}
+	lockFile, err := backup.NewBackuper(l.cfg).GetLockFile(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	l.lock = hostlock.New(lockFile, l.owner)
+	log.Info().Msgf("API uses host lock %s as %s", lockFile, l.owner)
+	return l.lock, nil
+}
+
+func (l *lazyHostLock) Acquire() error {
+	lock, err := l.get()
+	if err != nil {
+		return err
+	}
+	return lock.Acquire()
+}
+
+func (l *lazyHostLock) Release() error {
+	lock, err := l.get()
+	if err != nil {
+		return err
+	}
+	return lock.Release()
+}
+
+func (l *lazyHostLock) LockedByOther() (string, bool, error) {
+	lock, err := l.get()
+	if err != nil {
+		return "", false, err
+	}
+	return lock.LockedByOther()
+}
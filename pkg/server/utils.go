@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 
+	"github.com/Altinity/clickhouse-backup/v2/pkg/backup"
 	"github.com/rs/zerolog/log"
 )
 
@@ -90,3 +93,25 @@ func (api *APIServer) successCallback(ctx context.Context, operationId string, c
 		log.Error().Err(e).Send()
 	}
 }
+
+// applyDatabaseQueryParameter - restrict tablePattern by `database` query parameter, could be passed several times or separated by comma
+func applyDatabaseQueryParameter(query url.Values, tablePattern, fullCommand string) (string, string, error) {
+	databases, exist := query["database"]
+	if !exist {
+		return tablePattern, fullCommand, nil
+	}
+	tablePattern, err := backup.ApplyDatabaseFilter(tablePattern, databases)
+	if err != nil {
+		return "", fullCommand, err
+	}
+	return tablePattern, fmt.Sprintf("%s --database=\"%s\"", fullCommand, strings.Join(databases, ",")), nil
+}
+
+// applyExcludeQueryParameter - `exclude` query parameter, the same as `--exclude` CLI option, returns patterns for backup.WithExcludeTables
+func applyExcludeQueryParameter(query url.Values, fullCommand string) ([]string, string) {
+	excludePatterns, exist := query["exclude"]
+	if !exist {
+		return nil, fullCommand
+	}
+	return excludePatterns, fmt.Sprintf("%s --exclude=\"%s\"", fullCommand, strings.Join(excludePatterns, ","))
+}
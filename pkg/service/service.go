@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	DefaultName        = "clickhouse-backup"
+	DefaultDescription = "Altinity Backup for ClickHouse API server"
+)
+
+// RunFunc - API server main loop, shall stop and return when stop channel closed
+type RunFunc func(stop <-chan struct{}) error
+
+// Service - describe how `clickhouse-backup server` shall be registered in OS service manager
+type Service struct {
+	Name        string
+	Description string
+	Executable  string
+	ConfigPath  string
+}
+
+// NewService - build service definition for current executable and config path
+func NewService(configPath string) (*Service, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("can't get current executable path: %v", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return nil, fmt.Errorf("can't resolve current executable path: %v", err)
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return nil, fmt.Errorf("can't get absolute config path: %v", err)
+	}
+	return &Service{
+		Name:        DefaultName,
+		Description: DefaultDescription,
+		Executable:  executable,
+		ConfigPath:  configPath,
+	}, nil
+}
+
+// Args - command line arguments which used to run API server as service
+func (s *Service) Args() []string {
+	return []string{"server", "--config", s.ConfigPath}
+}
+
+// Run - execute service management action, allowed values install, uninstall, start, stop
+func (s *Service) Run(action string) error {
+	switch action {
+	case "install":
+		return s.Install()
+	case "uninstall":
+		return s.Uninstall()
+	case "start":
+		return s.Start()
+	case "stop":
+		return s.Stop()
+	default:
+		return fmt.Errorf("unknown service action '%s', allowed values install, uninstall, start, stop", action)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+const systemctlTimeout = 5 * time.Minute
+
+// UnitPath - full path to generated systemd unit file
+func (s *Service) UnitPath() string {
+	return path.Join(systemdUnitDir, s.Name+".service")
+}
+
+// Unit - systemd unit content for API server
+func (s *Service) Unit() string {
+	return fmt.Sprintf(`[Unit]
+Description=%s
+Documentation=https://github.com/Altinity/clickhouse-backup
+After=network-online.target clickhouse-server.service
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s %s
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5s
+
+[Install]
+WantedBy=multi-user.target
+`, s.Description, s.Executable, strings.Join(s.Args(), " "))
+}
+
+// IsService - systemd runs API server as regular process and delivers stop as SIGTERM
+func IsService() bool {
+	return false
+}
+
+// RunService - systemd doesn't require handshake, just run API server
+func (s *Service) RunService(run RunFunc) error {
+	return run(nil)
+}
+
+func (s *Service) Install() error {
+	if err := os.WriteFile(s.UnitPath(), []byte(s.Unit()), 0644); err != nil {
+		return fmt.Errorf("can't write %s: %v", s.UnitPath(), err)
+	}
+	log.Info().Msgf("%s created", s.UnitPath())
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl("enable", s.Name)
+}
+
+func (s *Service) Uninstall() error {
+	if err := s.systemctl("disable", "--now", s.Name); err != nil {
+		log.Warn().Msgf("can't disable %s: %v", s.Name, err)
+	}
+	if err := os.Remove(s.UnitPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't remove %s: %v", s.UnitPath(), err)
+	}
+	log.Info().Msgf("%s removed", s.UnitPath())
+	return s.systemctl("daemon-reload")
+}
+
+func (s *Service) Start() error {
+	return s.systemctl("start", s.Name)
+}
+
+func (s *Service) Stop() error {
+	return s.systemctl("stop", s.Name)
+}
+
+func (s *Service) systemctl(args ...string) error {
+	out, err := utils.ExecCmdOut(context.Background(), systemctlTimeout, "systemctl", args...)
+	if err != nil {
+		return fmt.Errorf("systemctl %s return error: %v, output: %s", strings.Join(args, " "), err, out)
+	}
+	log.Info().Msgf("systemctl %s", strings.Join(args, " "))
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build !linux && !windows
+
+package service
+
+import "fmt"
+
+var errUnsupported = fmt.Errorf("service management supports only systemd and windows service manager")
+
+// IsService - service manager is not supported, API server always runs as regular process
+func IsService() bool {
+	return false
+}
+
+// RunService - just run API server, service manager is not supported
+func (s *Service) RunService(run RunFunc) error {
+	return run(nil)
+}
+
+func (s *Service) Install() error {
+	return errUnsupported
+}
+
+func (s *Service) Uninstall() error {
+	return errUnsupported
+}
+
+func (s *Service) Start() error {
+	return errUnsupported
+}
+
+func (s *Service) Stop() error {
+	return errUnsupported
+}
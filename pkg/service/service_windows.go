@@ -0,0 +1,158 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const stopTimeout = 30 * time.Second
+
+// IsService - true when current process was started by windows service manager
+func IsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Warn().Msgf("svc.IsWindowsService return error: %v", err)
+		return false
+	}
+	return isService
+}
+
+// RunService - run API server under windows service manager, without svc.Run service manager kills process with error 1053 after start timeout
+func (s *Service) RunService(run RunFunc) error {
+	h := &handler{run: run}
+	if err := svc.Run(s.Name, h); err != nil {
+		return fmt.Errorf("can't run service %s: %v", s.Name, err)
+	}
+	return h.err
+}
+
+// handler - implements svc.Handler, report status changes to service manager and translate Stop and Shutdown into closing stop channel of RunFunc
+type handler struct {
+	run RunFunc
+	err error
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(stop)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case h.err = <-done:
+			return h.exitCode()
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				close(stop)
+				h.err = <-done
+				return h.exitCode()
+			default:
+				log.Warn().Msgf("unexpected service control request %d", request.Cmd)
+			}
+		}
+	}
+}
+
+// exitCode - non-zero service specific exit code when API server failed, service manager will apply recovery actions
+func (h *handler) exitCode() (bool, uint32) {
+	if h.err != nil {
+		log.Error().Msgf("service stopped with error: %v", h.err)
+		return true, 1
+	}
+	return false, 0
+}
+
+func (s *Service) Install() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("can't connect to windows service manager: %v", err)
+	}
+	defer func() {
+		if err := m.Disconnect(); err != nil {
+			log.Warn().Msgf("can't disconnect from windows service manager: %v", err)
+		}
+	}()
+	if existing, err := m.OpenService(s.Name); err == nil {
+		_ = existing.Close()
+		return fmt.Errorf("service %s already exists", s.Name)
+	}
+	ws, err := m.CreateService(s.Name, s.Executable, mgr.Config{
+		DisplayName: s.Name,
+		Description: s.Description,
+		StartType:   mgr.StartAutomatic,
+	}, s.Args()...)
+	if err != nil {
+		return fmt.Errorf("can't create service %s: %v", s.Name, err)
+	}
+	log.Info().Msgf("service %s created", s.Name)
+	return ws.Close()
+}
+
+func (s *Service) Uninstall() error {
+	return s.withService(func(ws *mgr.Service) error {
+		if err := ws.Delete(); err != nil {
+			return fmt.Errorf("can't delete service %s: %v", s.Name, err)
+		}
+		log.Info().Msgf("service %s removed", s.Name)
+		return nil
+	})
+}
+
+func (s *Service) Start() error {
+	return s.withService(func(ws *mgr.Service) error {
+		return ws.Start()
+	})
+}
+
+func (s *Service) Stop() error {
+	return s.withService(func(ws *mgr.Service) error {
+		serviceStatus, err := ws.Control(svc.Stop)
+		if err != nil {
+			return fmt.Errorf("can't send stop to service %s: %v", s.Name, err)
+		}
+		deadline := time.Now().Add(stopTimeout)
+		for serviceStatus.State != svc.Stopped {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("service %s not stopped after %s", s.Name, stopTimeout)
+			}
+			time.Sleep(300 * time.Millisecond)
+			if serviceStatus, err = ws.Query(); err != nil {
+				return fmt.Errorf("can't query service %s status: %v", s.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Service) withService(f func(ws *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("can't connect to windows service manager: %v", err)
+	}
+	defer func() {
+		if err := m.Disconnect(); err != nil {
+			log.Warn().Msgf("can't disconnect from windows service manager: %v", err)
+		}
+	}()
+	ws, err := m.OpenService(s.Name)
+	if err != nil {
+		return fmt.Errorf("can't open service %s: %v", s.Name, err)
+	}
+	defer func() {
+		if err := ws.Close(); err != nil {
+			log.Warn().Msgf("can't close service %s handle: %v", s.Name, err)
+		}
+	}()
+	return f(ws)
+}
@@ -25,6 +25,31 @@ const NotFromAPI = int(-1)
 type AsyncStatus struct {
 	commands []ActionRow
 	sync.RWMutex
+	// shared locks have own mutex, keeper and lock_file I/O shall not block status of commands
+	sharedLocks       []SharedLock
+	sharedLockHolders int
+	sharedLocksMutex  sync.Mutex
+}
+
+// SharedLock - lock shared between several clickhouse-backup instances which use the same clickhouse-server, for example during rolling deploy
+type SharedLock interface {
+	Acquire() error
+	Release() error
+	LockedByOther() (string, bool, error)
+}
+
+// SetSharedLock - InProgress also checks shared lock, lock is acquired when first StartWithSharedLock command starts and released when all of them finished
+func (status *AsyncStatus) SetSharedLock(sharedLock SharedLock) {
+	status.sharedLocksMutex.Lock()
+	defer status.sharedLocksMutex.Unlock()
+	status.sharedLocks = []SharedLock{sharedLock}
+}
+
+// AddSharedLock - the same as SetSharedLock, but keep already defined locks, for example keeper lock and `general->lock_file`
+func (status *AsyncStatus) AddSharedLock(sharedLock SharedLock) {
+	status.sharedLocksMutex.Lock()
+	defer status.sharedLocksMutex.Unlock()
+	status.sharedLocks = append(status.sharedLocks, sharedLock)
 }
 
 type ActionRowStatus struct {
@@ -37,28 +62,103 @@ type ActionRowStatus struct {
 
 type ActionRow struct {
 	ActionRowStatus
-	Ctx    context.Context
-	Cancel context.CancelFunc
+	Ctx          context.Context
+	Cancel       context.CancelFunc
+	sharedLocked bool
 }
 
+// Start - register command which doesn't change backups, like `list`, or which acquire shared locks by itself, like `watch`
 func (status *AsyncStatus) Start(command string) (int, context.Context) {
 	status.Lock()
 	defer status.Unlock()
+	return status.start(command, false)
+}
+
+// StartWithSharedLock - register command which changes backups, return error without registering command when any shared lock is held by another instance
+func (status *AsyncStatus) StartWithSharedLock(command string) (int, context.Context, error) {
+	if err := status.AcquireSharedLocks(command); err != nil {
+		return NotFromAPI, nil, err
+	}
+	status.Lock()
+	defer status.Unlock()
+	commandId, ctx := status.start(command, true)
+	return commandId, ctx, nil
+}
+
+func (status *AsyncStatus) start(command string, sharedLocked bool) (int, context.Context) {
 	ctx, cancel := context.WithCancel(context.Background())
 	status.commands = append(status.commands, ActionRow{
 		ActionRowStatus: ActionRowStatus{
 			Command: command,
-			Start:   time.Now().Format(common.TimeFormat),
+			Start:   common.FormatAPITime(time.Now()),
 			Status:  InProgressStatus,
 		},
-		Ctx:    ctx,
-		Cancel: cancel,
+		Ctx:          ctx,
+		Cancel:       cancel,
+		sharedLocked: sharedLocked,
 	})
 	lastCommandId := len(status.commands) - 1
 	log.Debug().Msgf("api.status.Start -> status.commands[%d] == %+v", lastCommandId, status.commands[lastCommandId])
 	return lastCommandId, ctx
 }
 
+// AcquireSharedLocks - hold shared locks without registered command, for example during each `watch` iteration, ReleaseSharedLocks shall be called after
+func (status *AsyncStatus) AcquireSharedLocks(command string) error {
+	status.sharedLocksMutex.Lock()
+	defer status.sharedLocksMutex.Unlock()
+	return status.acquireSharedLocks(command)
+}
+
+// ReleaseSharedLocks - release shared locks acquired by AcquireSharedLocks, when they are not held by other commands
+func (status *AsyncStatus) ReleaseSharedLocks() {
+	status.sharedLocksMutex.Lock()
+	defer status.sharedLocksMutex.Unlock()
+	status.releaseSharedLocks()
+}
+
+// acquireSharedLocks - shall be called under status.sharedLocksMutex, locks are acquired only by first holder
+func (status *AsyncStatus) acquireSharedLocks(command string) error {
+	if status.sharedLockHolders == 0 {
+		for i, sharedLock := range status.sharedLocks {
+			if err := sharedLock.Acquire(); err != nil {
+				for _, acquiredLock := range status.sharedLocks[:i] {
+					if releaseErr := acquiredLock.Release(); releaseErr != nil {
+						log.Warn().Msgf("api.status can't release shared lock: %v", releaseErr)
+					}
+				}
+				return fmt.Errorf("can't acquire shared lock for `%s`: %w", command, err)
+			}
+		}
+	}
+	status.sharedLockHolders += 1
+	return nil
+}
+
+// releaseSharedLocks - shall be called under status.sharedLocksMutex, locks are released by last holder
+func (status *AsyncStatus) releaseSharedLocks() {
+	if status.sharedLockHolders == 0 {
+		return
+	}
+	status.sharedLockHolders -= 1
+	if status.sharedLockHolders > 0 {
+		return
+	}
+	for _, sharedLock := range status.sharedLocks {
+		if err := sharedLock.Release(); err != nil {
+			log.Warn().Msgf("api.status can't release shared lock: %v", err)
+		}
+	}
+}
+
+// finishSharedLocked - shall be called under status.Lock when command finished, return true only once for command started by StartWithSharedLock, ReleaseSharedLocks shall be called after status.Unlock
+func (status *AsyncStatus) finishSharedLocked(commandId int) bool {
+	if !status.commands[commandId].sharedLocked {
+		return false
+	}
+	status.commands[commandId].sharedLocked = false
+	return true
+}
+
 func (status *AsyncStatus) CheckCommandInProgress(command string) bool {
 	status.RLock()
 	defer status.RUnlock()
@@ -71,9 +171,33 @@ func (status *AsyncStatus) CheckCommandInProgress(command string) bool {
 }
 
 // InProgress any .Status == InProgressStatus command shall return true, https://github.com/Altinity/clickhouse-backup/issues/827
+// when shared lock is set, command which is running in another instance also returns true
 func (status *AsyncStatus) InProgress() bool {
 	status.RLock()
-	defer status.RUnlock()
+	inProgress := status.inProgress()
+	status.RUnlock()
+	if inProgress {
+		return true
+	}
+	status.sharedLocksMutex.Lock()
+	sharedLocks := status.sharedLocks
+	status.sharedLocksMutex.Unlock()
+	for _, sharedLock := range sharedLocks {
+		lockedBy, isLocked, err := sharedLock.LockedByOther()
+		// can't be sure that another instance doesn't run command, so fail closed
+		if err != nil {
+			log.Warn().Msgf("api.status.inProgress can't check shared lock, inProgress=true: %v", err)
+			return true
+		}
+		if isLocked {
+			log.Debug().Msgf("api.status.inProgress -> shared lock held by %s, inProgress=true", lockedBy)
+			return true
+		}
+	}
+	return false
+}
+
+func (status *AsyncStatus) inProgress() bool {
 	for n := range status.commands {
 		if status.commands[n].Status == InProgressStatus {
 			log.Debug().Msgf("api.status.inProgress -> status.commands[%d].Status == %s, inProgress=%v", n, status.commands[n].Status, status.commands[n].Status == InProgressStatus)
@@ -102,6 +226,12 @@ func (status *AsyncStatus) GetContextWithCancel(commandId int) (context.Context,
 }
 
 func (status *AsyncStatus) Stop(commandId int, err error) {
+	sharedLocked := false
+	defer func() {
+		if sharedLocked {
+			status.ReleaseSharedLocks()
+		}
+	}()
 	status.Lock()
 	defer status.Unlock()
 	if status.commands[commandId].Status != InProgressStatus {
@@ -114,13 +244,20 @@ func (status *AsyncStatus) Stop(commandId int, err error) {
 		status.commands[commandId].Error = err.Error()
 	}
 	status.commands[commandId].Status = s
-	status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
+	status.commands[commandId].Finish = common.FormatAPITime(time.Now())
 	status.commands[commandId].Ctx = nil
 	status.commands[commandId].Cancel = nil
 	log.Debug().Msgf("api.status.stop -> status.commands[%d] == %+v", commandId, status.commands[commandId])
+	sharedLocked = status.finishSharedLocked(commandId)
 }
 
 func (status *AsyncStatus) Cancel(command string, err error) error {
+	sharedLocked := false
+	defer func() {
+		if sharedLocked {
+			status.ReleaseSharedLocks()
+		}
+	}()
 	status.Lock()
 	defer status.Unlock()
 	if len(status.commands) == 0 {
@@ -159,12 +296,19 @@ func (status *AsyncStatus) Cancel(command string, err error) error {
 	}
 	status.commands[commandId].Error = err.Error()
 	status.commands[commandId].Status = CancelStatus
-	status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
+	status.commands[commandId].Finish = common.FormatAPITime(time.Now())
 	log.Debug().Msgf("api.status.cancel -> status.commands[%d] == %+v", commandId, status.commands[commandId])
+	sharedLocked = status.finishSharedLocked(commandId)
 	return nil
 }
 
 func (status *AsyncStatus) CancelAll(cancelMsg string) {
+	sharedLockedCount := 0
+	defer func() {
+		for i := 0; i < sharedLockedCount; i++ {
+			status.ReleaseSharedLocks()
+		}
+	}()
 	status.Lock()
 	defer status.Unlock()
 	for commandId := range status.commands {
@@ -175,9 +319,22 @@ func (status *AsyncStatus) CancelAll(cancelMsg string) {
 		}
 		status.commands[commandId].Status = CancelStatus
 		status.commands[commandId].Error = cancelMsg
-		status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
+		status.commands[commandId].Finish = common.FormatAPITime(time.Now())
 		log.Debug().Msgf("api.status.cancel -> status.commands[%d] == %+v", commandId, status.commands[commandId])
+		if status.finishSharedLocked(commandId) {
+			sharedLockedCount += 1
+		}
+	}
+}
+
+// GetStatusById - copy of command status without context and cancel
+func (status *AsyncStatus) GetStatusById(commandId int) (ActionRowStatus, bool) {
+	status.RLock()
+	defer status.RUnlock()
+	if commandId < 0 || commandId >= len(status.commands) {
+		return ActionRowStatus{}, false
 	}
+	return status.commands[commandId].ActionRowStatus, true
 }
 
 func (status *AsyncStatus) GetStatus(current bool, filter string, last int) []ActionRowStatus {
@@ -0,0 +1,160 @@
+package status
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeSharedLock struct {
+	owner     string
+	locked    string
+	err       error
+	onAcquire func()
+}
+
+func (l *fakeSharedLock) Acquire() error {
+	if l.onAcquire != nil {
+		l.onAcquire()
+	}
+	if l.err != nil {
+		return l.err
+	}
+	if l.locked != "" && l.locked != l.owner {
+		return fmt.Errorf("locked by %s", l.locked)
+	}
+	l.locked = l.owner
+	return nil
+}
+
+func (l *fakeSharedLock) Release() error {
+	if l.locked == l.owner {
+		l.locked = ""
+	}
+	return nil
+}
+
+func (l *fakeSharedLock) LockedByOther() (string, bool, error) {
+	if l.err != nil {
+		return "", false, l.err
+	}
+	return l.locked, l.locked != "" && l.locked != l.owner, nil
+}
+
+func TestSharedLock(t *testing.T) {
+	lock := &fakeSharedLock{owner: "instance1"}
+	status := &AsyncStatus{}
+	status.SetSharedLock(lock)
+
+	listCommandId, _ := status.Start("list")
+	if lock.locked != "" {
+		t.Fatalf("shared lock shall not be acquired by Start")
+	}
+	commandId, _, err := status.StartWithSharedLock("create")
+	if err != nil || lock.locked != "instance1" {
+		t.Fatalf("shared lock shall be acquired after StartWithSharedLock, err=%v", err)
+	}
+	secondCommandId, _, err := status.StartWithSharedLock("upload")
+	if err != nil {
+		t.Fatalf("unexpected StartWithSharedLock error: %v", err)
+	}
+	status.Stop(commandId, nil)
+	if lock.locked != "instance1" {
+		t.Fatalf("shared lock shall be held while any StartWithSharedLock command in progress")
+	}
+	status.Stop(secondCommandId, nil)
+	if lock.locked != "" {
+		t.Fatalf("shared lock shall be released after all StartWithSharedLock commands finished")
+	}
+	status.Stop(listCommandId, nil)
+
+	lock.locked = "instance2"
+	if !status.InProgress() {
+		t.Fatalf("InProgress shall return true when shared lock held by another instance")
+	}
+	if _, _, err = status.StartWithSharedLock("create"); err == nil {
+		t.Fatalf("StartWithSharedLock shall fail when shared lock held by another instance")
+	}
+	if len(status.commands) != 3 {
+		t.Fatalf("failed StartWithSharedLock shall not register command, len(commands)=%d", len(status.commands))
+	}
+	lock.locked = ""
+	if status.InProgress() {
+		t.Fatalf("InProgress shall return false without running commands")
+	}
+
+	commandId, _, _ = status.StartWithSharedLock("create")
+	if err = status.Cancel("create", fmt.Errorf("canceled")); err != nil || lock.locked != "" {
+		t.Fatalf("shared lock shall be released after Cancel, err=%v", err)
+	}
+	status.Stop(commandId, nil)
+}
+
+func TestAddSharedLock(t *testing.T) {
+	keeperLock := &fakeSharedLock{owner: "instance1"}
+	hostLock := &fakeSharedLock{owner: "instance1"}
+	status := &AsyncStatus{}
+	status.SetSharedLock(keeperLock)
+	status.AddSharedLock(hostLock)
+
+	commandId, _, err := status.StartWithSharedLock("create")
+	if err != nil || keeperLock.locked != "instance1" || hostLock.locked != "instance1" {
+		t.Fatalf("all shared locks shall be acquired after StartWithSharedLock, err=%v", err)
+	}
+	status.Stop(commandId, nil)
+	if keeperLock.locked != "" || hostLock.locked != "" {
+		t.Fatalf("all shared locks shall be released after all commands finished")
+	}
+	hostLock.locked = "cli"
+	if !status.InProgress() {
+		t.Fatalf("InProgress shall return true when any shared lock held by another instance")
+	}
+	if err = status.AcquireSharedLocks("watch"); err == nil {
+		t.Fatalf("AcquireSharedLocks shall fail when any shared lock held by another instance")
+	}
+	if keeperLock.locked != "" {
+		t.Fatalf("already acquired shared locks shall be released after failed AcquireSharedLocks")
+	}
+	hostLock.locked = ""
+	if err = status.AcquireSharedLocks("watch"); err != nil || hostLock.locked != "instance1" {
+		t.Fatalf("unexpected AcquireSharedLocks result, err=%v", err)
+	}
+	status.ReleaseSharedLocks()
+	if keeperLock.locked != "" || hostLock.locked != "" {
+		t.Fatalf("all shared locks shall be released after ReleaseSharedLocks")
+	}
+}
+
+func TestSharedLockErrors(t *testing.T) {
+	lock := &fakeSharedLock{owner: "instance1", err: fmt.Errorf("keeper connection lost")}
+	status := &AsyncStatus{}
+	status.SetSharedLock(lock)
+	if !status.InProgress() {
+		t.Fatalf("InProgress shall return true when shared lock can't be checked")
+	}
+	if _, _, err := status.StartWithSharedLock("create"); err == nil {
+		t.Fatalf("StartWithSharedLock shall fail when shared lock can't be acquired")
+	}
+
+	// keeper I/O shall not be executed under status lock
+	lock.err = nil
+	lock.onAcquire = func() {
+		status.GetStatus(true, "", 0)
+	}
+	done := make(chan error, 1)
+	go func() {
+		commandId, _, err := status.StartWithSharedLock("create")
+		if err == nil {
+			status.Stop(commandId, nil)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected StartWithSharedLock error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("StartWithSharedLock acquires shared lock under status lock")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"io"
+	"net/http"
 	"net/url"
 	"path"
 	"strings"
@@ -143,6 +144,11 @@ func (a *AzureBlob) Connect(ctx context.Context) error {
 				TryTimeout: timeout,
 			},
 		}
+		if a.Config.Proxy != "" {
+			if options.HTTPSender, err = newAzblobProxySender(a.Config.Proxy); err != nil {
+				return err
+			}
+		}
 		if a.Config.Debug {
 			options.Log = pipeline.LogOptions{
 				Log: a.log,
@@ -174,6 +180,24 @@ func (a *AzureBlob) Connect(ctx context.Context) error {
 	return nil
 }
 
+// newAzblobProxySender - the same as default pipeline HTTP sender, but default sender use only system proxy settings
+func newAzblobProxySender(proxy string) (pipeline.Factory, error) {
+	proxyTransport, err := newProxyHTTPTransport(proxy)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Transport: proxyTransport}
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			r, err := httpClient.Do(request.WithContext(ctx))
+			if err != nil {
+				err = pipeline.NewError(err, "HTTP request failed")
+			}
+			return pipeline.NewHTTPResponse(r), err
+		}
+	}), nil
+}
+
 func (a *AzureBlob) Close(ctx context.Context) error {
 	return nil
 }
@@ -187,11 +211,87 @@ func (a *AzureBlob) GetFileReaderAbsolute(ctx context.Context, key string) (io.R
 	blob := a.Container.NewBlockBlobURL(key)
 	r, err := blob.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, a.CPK)
 	if err != nil {
-		return nil, err
+		var se azblob.StorageError
+		if !errors.As(err, &se) || (se.ServiceCode() != azblob.ServiceCodeBlobArchived && se.ServiceCode() != azblob.ServiceCodeBlobBeingRehydrated) {
+			return nil, err
+		}
+		log.Warn().Msgf("GetFileReader %s, receive error: %s", key, se.ServiceCode())
+		if rehydrateErr := a.rehydrateBlob(ctx, blob.BlobURL, key); rehydrateErr != nil {
+			log.Warn().Msgf("rehydrateBlob %s, return error: %v", key, rehydrateErr)
+			return nil, rehydrateErr
+		}
+		if r, err = blob.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, a.CPK); err != nil {
+			return nil, err
+		}
 	}
 	return r.Body(azblob.RetryReaderOptions{}), nil
 }
 
+// rehydrateBlob - move blob from Archive to Hot tier with azblob->rehydrate_priority and wait azblob->rehydrate_max_wait, unlike S3 restore the tier is changed permanently
+func (a *AzureBlob) rehydrateBlob(ctx context.Context, blob azblob.BlobURL, key string) error {
+	if err := a.requestRehydrateBlob(ctx, blob); err != nil {
+		return err
+	}
+	return waitArchiveRestore(ctx, key, a.Config.RehydrateMaxWaitDuration, func(ctx context.Context) (bool, error) {
+		return a.isBlobRehydrated(ctx, key)
+	})
+}
+
+// RestoreArchivedObjects - request rehydration of all Archive tier blobs of backups at once and wait azblob->rehydrate_max_wait until all of them are rehydrated, so rehydration time is spent once per backup instead of once per blob
+func (a *AzureBlob) RestoreArchivedObjects(ctx context.Context, backupNames ...string) error {
+	pendingKeys := make([]string, 0)
+	for _, backupName := range backupNames {
+		prefix := path.Join(a.Config.Path, backupName)
+		if err := a.WalkAbsolute(ctx, prefix, true, func(ctx context.Context, f RemoteFile) error {
+			if f.(*azureBlobFile).accessTier == azblob.AccessTierArchive {
+				pendingKeys = append(pendingKeys, path.Join(prefix, f.Name()))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(pendingKeys) == 0 {
+		return nil
+	}
+	description := fmt.Sprintf("%d blobs of %s", len(pendingKeys), strings.Join(backupNames, ", "))
+	log.Info().Msgf("request rehydrate of %s", description)
+	for _, key := range pendingKeys {
+		if err := a.requestRehydrateBlob(ctx, a.Container.NewBlockBlobURL(key).BlobURL); err != nil {
+			return err
+		}
+	}
+	return waitArchiveRestore(ctx, description, a.Config.RehydrateMaxWaitDuration, func(ctx context.Context) (bool, error) {
+		var err error
+		pendingKeys, err = filterNotRestored(ctx, pendingKeys, a.isBlobRehydrated)
+		return len(pendingKeys) == 0, err
+	})
+}
+
+// requestRehydrateBlob - move blob from Archive to Hot tier with azblob->rehydrate_priority
+func (a *AzureBlob) requestRehydrateBlob(ctx context.Context, blob azblob.BlobURL) error {
+	priority := azblob.RehydratePriorityStandard
+	if strings.EqualFold(a.Config.RehydratePriority, string(azblob.RehydratePriorityHigh)) {
+		priority = azblob.RehydratePriorityHigh
+	}
+	if _, err := blob.SetTier(ctx, azblob.AccessTierHot, azblob.LeaseAccessConditions{}, priority); err != nil {
+		var se azblob.StorageError
+		if !errors.As(err, &se) || se.ServiceCode() != azblob.ServiceCodeBlobBeingRehydrated {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBlobRehydrated - blob is not in Archive tier and rehydration is finished, key is absolute
+func (a *AzureBlob) isBlobRehydrated(ctx context.Context, key string) (bool, error) {
+	props, err := a.Container.NewBlockBlobURL(key).GetProperties(ctx, azblob.BlobAccessConditions{}, a.CPK)
+	if err != nil {
+		return false, fmt.Errorf("rehydrateBlob: failed to get %s properties, %v", key, err)
+	}
+	return props.ArchiveStatus() == "" && props.AccessTier() != string(azblob.AccessTierArchive), nil
+}
+
 func (a *AzureBlob) GetFileReaderWithLocalPath(ctx context.Context, key, _ string) (io.ReadCloser, error) {
 	return a.GetFileReader(ctx, key)
 }
@@ -285,6 +385,7 @@ func (a *AzureBlob) WalkAbsolute(ctx context.Context, prefix string, recursive b
 					name:         strings.TrimPrefix(blob.Name, prefix),
 					size:         size,
 					lastModified: blob.Properties.LastModified,
+					accessTier:   blob.Properties.AccessTier,
 				}); err != nil {
 					return err
 				}
@@ -306,6 +407,7 @@ func (a *AzureBlob) WalkAbsolute(ctx context.Context, prefix string, recursive b
 					name:         strings.TrimPrefix(blob.Name, prefix),
 					size:         size,
 					lastModified: blob.Properties.LastModified,
+					accessTier:   blob.Properties.AccessTier,
 				}); err != nil {
 					return err
 				}
@@ -361,6 +463,8 @@ type azureBlobFile struct {
 	size         int64
 	lastModified time.Time
 	name         string
+	// accessTier - filled only by recursive WalkAbsolute
+	accessTier azblob.AccessTierType
 }
 
 func (f *azureBlobFile) Size() int64 {
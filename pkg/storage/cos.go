@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,11 +10,15 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/tencentyun/cos-go-sdk-v5"
 	"github.com/tencentyun/cos-go-sdk-v5/debug"
+	"golang.org/x/sync/errgroup"
 )
 
 type COS struct {
@@ -50,6 +55,9 @@ func (c *COS) Connect(ctx context.Context) error {
 			},
 		},
 	})
+	// 5xx and network errors are retried by SDK, request body shall be seekable for retry
+	c.client.Conf.RetryOpt.Count = c.Config.RetryCount
+	c.client.Conf.RetryOpt.Interval = c.Config.RetryIntervalDuration
 	// check bucket exists
 	_, err = c.client.Bucket.Head(ctx)
 	return err
@@ -155,8 +163,91 @@ func (c *COS) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
 	return c.PutFileAbsolute(ctx, path.Join(c.Config.Path, key), r)
 }
 
+// cosMaxPartsCount - COS multipart upload limit
+const cosMaxPartsCount = 10000
+
+// getCOSPartSize - increase part size when expectedSize doesn't fit into cosMaxPartsCount parts, 0 expectedSize means unknown size
+func getCOSPartSize(partSize, expectedSize int64) int64 {
+	if expectedSize/partSize >= cosMaxPartsCount {
+		partSize = expectedSize/cosMaxPartsCount + 1
+	}
+	return min(partSize, 5*1024*1024*1024)
+}
+
+// PutFileAbsolute - each part is buffered in memory, so COS SDK could retry it after transient errors, objects less than part_size are uploaded with one PutObject
 func (c *COS) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error {
-	_, err := c.client.Object.Put(ctx, key, r, nil)
+	partSize := c.Config.PartSize
+	if sizedReader, ok := r.(interface{ ExpectedSize() int64 }); ok {
+		partSize = getCOSPartSize(partSize, sizedReader.ExpectedSize())
+	}
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		_, err = c.client.Object.Put(ctx, key, bytes.NewReader(buf[:n]), nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	initResult, _, err := c.client.Object.InitiateMultipartUpload(ctx, key, nil)
+	if err != nil {
+		return err
+	}
+	if err = c.putMultipart(ctx, key, initResult.UploadID, r, buf, partSize); err != nil {
+		if _, abortErr := c.client.Object.AbortMultipartUpload(context.Background(), key, initResult.UploadID); abortErr != nil {
+			log.Warn().Msgf("can't abort multipart upload %s: %v", key, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *COS) putMultipart(ctx context.Context, key, uploadID string, r io.Reader, firstPart []byte, partSize int64) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.Config.Concurrency, 1))
+	parts := make([]cos.Object, 0)
+	partsMutex := sync.Mutex{}
+	uploadPart := func(partNumber int, part []byte) {
+		g.Go(func() error {
+			resp, err := c.client.Object.UploadPart(ctx, key, uploadID, partNumber, bytes.NewReader(part), nil)
+			if err != nil {
+				return fmt.Errorf("can't upload part %d of %s: %v", partNumber, key, err)
+			}
+			partsMutex.Lock()
+			parts = append(parts, cos.Object{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+			partsMutex.Unlock()
+			return nil
+		})
+	}
+	uploadPart(1, firstPart)
+	for partNumber := 2; ; partNumber++ {
+		part := make([]byte, partSize)
+		n, err := io.ReadFull(r, part)
+		if n > 0 {
+			if partNumber > cosMaxPartsCount {
+				_ = g.Wait()
+				return fmt.Errorf("%s requires more than %d parts, increase cos->part_size", key, cosMaxPartsCount)
+			}
+			uploadPart(partNumber, part[:n])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			_ = g.Wait()
+			return err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	_, _, err := c.client.Object.CompleteMultipartUpload(ctx, key, uploadID, &cos.CompleteMultipartUploadOptions{Parts: parts})
 	return err
 }
 
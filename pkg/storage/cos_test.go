@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCOSPartSize(t *testing.T) {
+	const mb = int64(1024 * 1024)
+	assert.Equal(t, 16*mb, getCOSPartSize(16*mb, 0))
+	assert.Equal(t, 16*mb, getCOSPartSize(16*mb, 1024*mb))
+	assert.Equal(t, 1024*1024*mb/cosMaxPartsCount+1, getCOSPartSize(16*mb, 1024*1024*mb))
+	assert.Equal(t, 5*1024*mb, getCOSPartSize(16*mb, 100*1024*1024*mb))
+}
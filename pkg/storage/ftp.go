@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path"
 	"strings"
@@ -45,6 +46,14 @@ func (f *FTP) Connect(ctx context.Context) error {
 		tlsConfig := tls.Config{InsecureSkipVerify: f.Config.SkipTLSVerify}
 		options = append(options, ftp.DialWithTLS(&tlsConfig))
 	}
+	if f.Config.Proxy != "" {
+		dialFunc, err := f.getProxyDialFunc(ctx, timeout)
+		if err != nil {
+			return err
+		}
+		// EPSV data connection use IP of control connection, which is proxy IP, PASV returns FTP server IP
+		options = append(options, ftp.DialWithDialFunc(dialFunc), ftp.DialWithDisabledEPSV(true))
+	}
 	f.clients = pool.NewObjectPoolWithDefaultConfig(ctx, &ftpPoolFactory{options: options, ftp: f})
 	if f.Config.Concurrency > 1 {
 		f.clients.Config.MaxTotal = int(f.Config.Concurrency) * 4
@@ -56,6 +65,30 @@ func (f *FTP) Connect(ctx context.Context) error {
 	return nil
 }
 
+// getProxyDialFunc - control and data connections via ftp->proxy, ftp.DialWithTLS is not applied when custom dial function used, so TLS is established here
+func (f *FTP) getProxyDialFunc(ctx context.Context, timeout time.Duration) (func(network, address string) (net.Conn, error), error) {
+	proxyDialFunc, err := newProxyDialFunc(ctx, f.Config.Proxy, &net.Dialer{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	if !f.Config.TLS {
+		return proxyDialFunc, nil
+	}
+	// data connections use IP from PASV response, so certificate is verified for ftp->address host
+	serverName, _, err := net.SplitHostPort(f.Config.Address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: serverName, InsecureSkipVerify: f.Config.SkipTLSVerify}
+	return func(network, address string) (net.Conn, error) {
+		conn, err := proxyDialFunc(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return tls.Client(conn, tlsConfig), nil
+	}, nil
+}
+
 func (f *FTP) Close(ctx context.Context) error {
 	f.clients.Close(ctx)
 	return nil
@@ -21,6 +21,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 	googleHTTPTransport "google.golang.org/api/transport/http"
 )
@@ -93,21 +94,40 @@ func (gcs *GCS) Connect(ctx context.Context) error {
 		clientOptions = append(clientOptions, option.WithEndpoint(endpoint))
 	}
 
+	// when credentials are not defined, Application Default Credentials are used, it includes GKE workload identity and metadata server of GCE instance
+	credentialsOptions := make([]option.ClientOption, 0)
 	if gcs.Config.CredentialsJSON != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsJSON([]byte(gcs.Config.CredentialsJSON)))
+		credentialsOptions = append(credentialsOptions, option.WithCredentialsJSON([]byte(gcs.Config.CredentialsJSON)))
 	} else if gcs.Config.CredentialsJSONEncoded != "" {
 		d, _ := base64.StdEncoding.DecodeString(gcs.Config.CredentialsJSONEncoded)
-		clientOptions = append(clientOptions, option.WithCredentialsJSON(d))
+		credentialsOptions = append(credentialsOptions, option.WithCredentialsJSON(d))
 	} else if gcs.Config.CredentialsFile != "" {
-		clientOptions = append(clientOptions, option.WithCredentialsFile(gcs.Config.CredentialsFile))
+		credentialsOptions = append(credentialsOptions, option.WithCredentialsFile(gcs.Config.CredentialsFile))
 	} else if gcs.Config.SkipCredentials {
-		clientOptions = append(clientOptions, option.WithoutAuthentication())
+		credentialsOptions = append(credentialsOptions, option.WithoutAuthentication())
 	}
+	if gcs.Config.ImpersonateServiceAccount != "" && !gcs.Config.SkipCredentials {
+		// credentials above or Application Default Credentials are used only to request short-lived token for impersonated service account
+		tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: gcs.Config.ImpersonateServiceAccount,
+			Scopes:          []string{storage.ScopeFullControl},
+			Delegates:       gcs.Config.ImpersonateDelegates,
+		}, credentialsOptions...)
+		if err != nil {
+			return fmt.Errorf("can't impersonate %s: %v", gcs.Config.ImpersonateServiceAccount, err)
+		}
+		credentialsOptions = []option.ClientOption{option.WithTokenSource(tokenSource)}
+	}
+	clientOptions = append(clientOptions, credentialsOptions...)
 
+	proxyFunc, err := newProxyFunc(gcs.Config.Proxy)
+	if err != nil {
+		return err
+	}
 	if gcs.Config.ForceHttp {
 		customTransport := &http.Transport{
 			WriteBufferSize: 128 * 1024,
-			Proxy:           http.ProxyFromEnvironment,
+			Proxy:           proxyFunc,
 			DialContext: (&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
@@ -142,6 +162,19 @@ func (gcs *GCS) Connect(ctx context.Context) error {
 
 		clientOptions = append(clientOptions, option.WithHTTPClient(gcpTransport))
 
+	} else if gcs.Config.Proxy != "" {
+		// auth transport is wrapped around proxy transport, the same way as for force_http, OAuth2 token requests still use HTTPS_PROXY environment variable
+		proxyTransport := http.DefaultTransport.(*http.Transport).Clone()
+		proxyTransport.Proxy = proxyFunc
+		if gcs.Config.Endpoint == "" {
+			clientOptions = append([]option.ClientOption{option.WithScopes(storage.ScopeFullControl)}, clientOptions...)
+		}
+		clientOptions = append(clientOptions, internaloption.WithDefaultEndpoint(endpoint))
+		transport, err := googleHTTPTransport.NewTransport(ctx, proxyTransport, clientOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to create GCP transport with proxy: %v", err)
+		}
+		clientOptions = append(clientOptions, option.WithHTTPClient(&http.Client{Transport: transport}))
 	}
 
 	if gcs.Config.Debug {
@@ -266,10 +299,16 @@ func (gcs *GCS) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser
 	}
 	pClient := pClientObj.(*clientObject).Client
 	obj := pClient.Bucket(gcs.Config.Bucket).Object(key)
-	writer := obj.NewWriter(ctx)
+	// cancel stops resumable upload without saving partial object when read from r fails
+	writerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	writer := obj.NewWriter(writerCtx)
 	writer.ChunkSize = gcs.Config.ChunkSize
-	writer.StorageClass = gcs.Config.StorageClass
-	writer.ChunkRetryDeadline = 60 * time.Minute
+	writer.StorageClass = strings.ToUpper(gcs.Config.StorageClass)
+	writer.ChunkRetryDeadline = gcs.Config.ChunkRetryDeadlineDuration
+	if gcs.Config.EncryptionKeyName != "" {
+		writer.KMSKeyName = gcs.Config.EncryptionKeyName
+	}
 	if len(gcs.Config.ObjectLabels) > 0 {
 		writer.Metadata = gcs.Config.ObjectLabels
 	}
@@ -282,6 +321,8 @@ func (gcs *GCS) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser
 	_, err = io.CopyBuffer(writer, r, buffer)
 	if err != nil {
 		log.Warn().Msgf("gcs.PutFile: can't copy buffer: %+v", err)
+		cancel()
+		_ = writer.Close()
 		return err
 	}
 	if err = writer.Close(); err != nil {
@@ -338,7 +379,15 @@ func (gcs *GCS) DeleteFileFromObjectDiskBackup(ctx context.Context, key string)
 }
 
 func (gcs *GCS) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
-	dstKey = path.Join(gcs.Config.ObjectDiskPath, dstKey)
+	return gcs.copyObject(ctx, srcSize, srcBucket, srcKey, path.Join(gcs.Config.ObjectDiskPath, dstKey))
+}
+
+// CopyBackupObject - implements BackupCopyStorage, used by `copy` command
+func (gcs *GCS) CopyBackupObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
+	return gcs.copyObject(ctx, srcSize, srcBucket, srcKey, path.Join(gcs.Config.Path, dstKey))
+}
+
+func (gcs *GCS) copyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
 	log.Debug().Msgf("GCS->CopyObject %s/%s -> %s/%s", srcBucket, srcKey, gcs.Config.Bucket, dstKey)
 	pClientObj, err := gcs.clientPool.BorrowObject(ctx)
 	if err != nil {
@@ -355,7 +404,12 @@ func (gcs *GCS) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey
 		}
 		return 0, err
 	}
-	if _, err = dst.CopierFrom(src).Run(ctx); err != nil {
+	copier := dst.CopierFrom(src)
+	copier.StorageClass = strings.ToUpper(gcs.Config.StorageClass)
+	if gcs.Config.EncryptionKeyName != "" {
+		copier.DestinationKMSKeyName = gcs.Config.EncryptionKeyName
+	}
+	if _, err = copier.Run(ctx); err != nil {
 		if pErr := gcs.clientPool.InvalidateObject(ctx, pClientObj); pErr != nil {
 			log.Warn().Msgf("gcs.CopyObject: gcs.clientPool.InvalidateObject error: %+v", pErr)
 		}
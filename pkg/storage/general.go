@@ -2,8 +2,12 @@ package storage
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -20,7 +24,6 @@ import (
 
 	"github.com/djherbis/buffer"
 	"github.com/djherbis/nio/v3"
-	"github.com/eapache/go-resiliency/retrier"
 	"github.com/mholt/archiver/v4"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
@@ -45,32 +48,227 @@ type Backup struct {
 
 type BackupDestination struct {
 	RemoteStorage
-	compressionFormat string
-	compressionLevel  int
+	compressionFormat      string
+	compressionLevel       int
+	compressionConcurrency int
+	timeouts               remoteTimeouts
+	retryConfig            *config.GeneralConfig
+	transferObserver       TransferObserver
+}
+
+// remoteTimeouts - hung connection shall fail operation instead of block it forever, 0 means no limit
+type remoteTimeouts struct {
+	connect time.Duration
+	upload  time.Duration
+	list    time.Duration
+}
+
+func newRemoteTimeouts(cfg *config.Config) remoteTimeouts {
+	connect, upload, list := cfg.GetRemoteTimeouts()
+	return remoteTimeouts{connect: connect, upload: upload, list: list}
+}
+
+// Connect - some backends keep ctx for connection pools, so ctx is cancelled only when general->remote_connect_timeout expired before Connect returns
+func (bd *BackupDestination) Connect(ctx context.Context) error {
+	if bd.timeouts.connect <= 0 {
+		return bd.RemoteStorage.Connect(ctx)
+	}
+	connectCtx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(bd.timeouts.connect, func() {
+		cancel(fmt.Errorf("%s connect timeout %s exceeded", bd.Kind(), bd.timeouts.connect))
+	})
+	err := bd.RemoteStorage.Connect(connectCtx)
+	if !timer.Stop() {
+		return fmt.Errorf("%v, error: %v", context.Cause(connectCtx), err)
+	}
+	return err
+}
+
+// PutFile - general->remote_upload_timeout limits upload of one object
+func (bd *BackupDestination) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	if bd.timeouts.upload > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bd.timeouts.upload)
+		defer cancel()
+	}
+	return bd.RemoteStorage.PutFile(ctx, key, r)
+}
+
+func (bd *BackupDestination) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error {
+	if bd.timeouts.upload > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bd.timeouts.upload)
+		defer cancel()
+	}
+	return bd.RemoteStorage.PutFileAbsolute(ctx, key, r)
+}
+
+// retry - remote calls of BackupDestination are retried according to general->retries_*, so callers don't need own retry for single remote call, BackupDestination without config is not retried
+func (bd *BackupDestination) retry(ctx context.Context, f func(ctx context.Context) error, nonRetryableErrors ...error) error {
+	if bd.retryConfig == nil {
+		return f(ctx)
+	}
+	return NewRetrier(bd.retryConfig, nonRetryableErrors...).RunCtx(ctx, f)
+}
+
+func (bd *BackupDestination) StatFile(ctx context.Context, key string) (RemoteFile, error) {
+	var remoteFile RemoteFile
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		var statErr error
+		remoteFile, statErr = bd.RemoteStorage.StatFile(ctx, key)
+		return statErr
+	})
+	return remoteFile, err
+}
+
+func (bd *BackupDestination) DeleteFile(ctx context.Context, key string) error {
+	return bd.retry(ctx, func(ctx context.Context) error {
+		return bd.RemoteStorage.DeleteFile(ctx, key)
+	})
+}
+
+func (bd *BackupDestination) DeleteFileFromObjectDiskBackup(ctx context.Context, key string) error {
+	return bd.retry(ctx, func(ctx context.Context) error {
+		return bd.RemoteStorage.DeleteFileFromObjectDiskBackup(ctx, key)
+	})
+}
+
+func (bd *BackupDestination) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
+	var copiedSize int64
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		var copyErr error
+		copiedSize, copyErr = bd.RemoteStorage.CopyObject(ctx, srcSize, srcBucket, srcKey, dstKey)
+		return copyErr
+	})
+	return copiedSize, err
+}
+
+// GetFileReader - only opening of reader is retried, read errors shall be retried by caller, look ReadFile
+func (bd *BackupDestination) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		var readerErr error
+		reader, readerErr = bd.RemoteStorage.GetFileReader(ctx, key)
+		return readerErr
+	})
+	return reader, err
+}
+
+func (bd *BackupDestination) GetFileReaderAbsolute(ctx context.Context, key string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		var readerErr error
+		reader, readerErr = bd.RemoteStorage.GetFileReaderAbsolute(ctx, key)
+		return readerErr
+	})
+	return reader, err
+}
+
+func (bd *BackupDestination) GetFileReaderWithLocalPath(ctx context.Context, key, localPath string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		var readerErr error
+		reader, readerErr = bd.RemoteStorage.GetFileReaderWithLocalPath(ctx, key, localPath)
+		return readerErr
+	})
+	return reader, err
+}
+
+// ReadFile - read whole object, open and read are retried together
+func (bd *BackupDestination) ReadFile(ctx context.Context, key string) ([]byte, error) {
+	var body []byte
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		reader, err := bd.RemoteStorage.GetFileReader(ctx, key)
+		if err != nil {
+			return err
+		}
+		body, err = io.ReadAll(reader)
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	return body, err
+}
+
+// errWalkProcess - error returned by process function of Walk is not retried
+var errWalkProcess = errors.New("walk process error")
+
+func (bd *BackupDestination) Walk(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	return bd.retryWalk(ctx, process, func(ctx context.Context, process func(context.Context, RemoteFile) error) error {
+		return bd.RemoteStorage.Walk(ctx, prefix, recursive, process)
+	})
+}
+
+func (bd *BackupDestination) WalkAbsolute(ctx context.Context, absolutePrefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	return bd.retryWalk(ctx, process, func(ctx context.Context, process func(context.Context, RemoteFile) error) error {
+		return bd.RemoteStorage.WalkAbsolute(ctx, absolutePrefix, recursive, process)
+	})
+}
+
+// retryWalk - listing is restarted after failure, already processed files are skipped, so process is called once for each file
+func (bd *BackupDestination) retryWalk(ctx context.Context, process func(context.Context, RemoteFile) error, walk func(context.Context, func(context.Context, RemoteFile) error) error) error {
+	var processed sync.Map
+	var processErr error
+	var processErrOnce sync.Once
+	err := bd.retry(ctx, func(ctx context.Context) error {
+		return walk(ctx, func(ctx context.Context, f RemoteFile) error {
+			if _, isProcessed := processed.LoadOrStore(f.Name(), struct{}{}); isProcessed {
+				return nil
+			}
+			if err := process(ctx, f); err != nil {
+				processErrOnce.Do(func() { processErr = err })
+				return errWalkProcess
+			}
+			return nil
+		})
+	}, errWalkProcess)
+	if errors.Is(err, errWalkProcess) {
+		return processErr
+	}
+	return err
 }
 
 var metadataCacheLock sync.RWMutex
 
+// RestoreArchivedBackups - restore all archived objects of backups before download, storages without archive tier do nothing
+func (bd *BackupDestination) RestoreArchivedBackups(ctx context.Context, backupNames ...string) error {
+	archiveStorage, ok := bd.RemoteStorage.(ArchiveRestoreStorage)
+	if !ok {
+		return nil
+	}
+	return archiveStorage.RestoreArchivedObjects(ctx, backupNames...)
+}
+
+// CheckBackupObjectLock - metadata.json is uploaded last, so when it is not locked, other files of backup are not locked too, errors of retention check are ignored, cause bucket could be without object lock configuration
+func (bd *BackupDestination) CheckBackupObjectLock(ctx context.Context, backupName string) error {
+	lockStorage, ok := bd.RemoteStorage.(ObjectLockStorage)
+	if !ok {
+		return nil
+	}
+	retainUntil, err := lockStorage.GetObjectLockRetainUntil(ctx, path.Join(backupName, "metadata.json"))
+	if err != nil {
+		log.Debug().Msgf("can't get object lock retention for %s: %v", backupName, err)
+		return nil
+	}
+	if retainUntil.After(time.Now()) {
+		return fmt.Errorf("'%s' can't be deleted until %s: %w", backupName, retainUntil.Format(time.RFC3339), ErrObjectLocked)
+	}
+	return nil
+}
+
 func (bd *BackupDestination) RemoveBackupRemote(ctx context.Context, backup Backup, cfg *config.Config) error {
-	retry := retrier.New(retrier.ConstantBackoff(cfg.General.RetriesOnFailure, cfg.General.RetriesDuration), nil)
-	if bd.Kind() == "SFTP" || bd.Kind() == "FTP" {
-		return retry.RunCtx(ctx, func(ctx context.Context) error {
-			return bd.DeleteFile(ctx, backup.BackupName)
-		})
+	if err := bd.CheckBackupObjectLock(ctx, backup.BackupName); err != nil {
+		return err
+	}
+	if bd.Kind() == "SFTP" || bd.Kind() == "FTP" || bd.Kind() == "local" {
+		return bd.DeleteFile(ctx, backup.BackupName)
 	}
 	return bd.Walk(ctx, backup.BackupName+"/", true, func(ctx context.Context, f RemoteFile) error {
-		if bd.Kind() == "azblob" {
-			if f.Size() > 0 || !f.LastModified().IsZero() {
-				return retry.RunCtx(ctx, func(ctx context.Context) error {
-					return bd.DeleteFile(ctx, path.Join(backup.BackupName, f.Name()))
-				})
-			} else {
-				return nil
-			}
+		if bd.Kind() == "azblob" && f.Size() == 0 && f.LastModified().IsZero() {
+			return nil
 		}
-		return retry.RunCtx(ctx, func(ctx context.Context) error {
-			return bd.DeleteFile(ctx, path.Join(backup.BackupName, f.Name()))
-		})
+		return bd.DeleteFile(ctx, path.Join(backup.BackupName, f.Name()))
 	})
 }
 
@@ -162,6 +360,12 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 	defer func() {
 		log.Info().Dur("list_duration", time.Since(backupListStart)).Send()
 	}()
+	// general->remote_list_timeout limits listing with metadata parsing
+	if bd.timeouts.list > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bd.timeouts.list)
+		defer cancel()
+	}
 	result := make([]Backup, 0)
 	metadataCacheLock.Lock()
 	defer metadataCacheLock.Unlock()
@@ -203,19 +407,7 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 			result = append(result, brokenBackup)
 			return nil
 		}
-		r, err := bd.GetFileReader(ctx, path.Join(o.Name(), "metadata.json"))
-		if err != nil {
-			brokenBackup := Backup{
-				metadata.BackupMetadata{
-					BackupName: backupName,
-				},
-				"broken (can't open metadata.json)",
-				o.LastModified(), // folder
-			}
-			result = append(result, brokenBackup)
-			return nil
-		}
-		b, err := io.ReadAll(r)
+		b, err := bd.ReadFile(ctx, path.Join(o.Name(), "metadata.json"))
 		if err != nil {
 			brokenBackup := Backup{
 				metadata.BackupMetadata{
@@ -227,9 +419,6 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 			result = append(result, brokenBackup)
 			return nil
 		}
-		if err := r.Close(); err != nil { // Never use defer in loops
-			return err
-		}
 		var m metadata.BackupMetadata
 		if err := json.Unmarshal(b, &m); err != nil {
 			brokenBackup := Backup{
@@ -266,17 +455,48 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 	return result, nil
 }
 
-func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remotePath string, localPath string, maxSpeed uint64) error {
+// PutBackupMetadata - overwrite metadata.json for already uploaded backup and drop outdated entry from list cache
+func (bd *BackupDestination) PutBackupMetadata(ctx context.Context, backupMetadata metadata.BackupMetadata) error {
+	body, err := json.MarshalIndent(&backupMetadata, "", "\t")
+	if err != nil {
+		return fmt.Errorf("can't marshal %s/metadata.json: %v", backupMetadata.BackupName, err)
+	}
+	metadataCacheLock.Lock()
+	defer metadataCacheLock.Unlock()
+	if err = bd.PutFile(ctx, path.Join(backupMetadata.BackupName, "metadata.json"), io.NopCloser(bytes.NewReader(body))); err != nil {
+		return fmt.Errorf("can't upload %s/metadata.json: %v", backupMetadata.BackupName, err)
+	}
+	listCache, err := bd.loadMetadataCache(ctx)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, isCached := listCache[backupMetadata.BackupName]; !isCached {
+		return nil
+	}
+	delete(listCache, backupMetadata.BackupName)
+	actualList := make([]Backup, 0, len(listCache))
+	for _, cachedBackup := range listCache {
+		actualList = append(actualList, cachedBackup)
+	}
+	return bd.saveMetadataCache(ctx, listCache, actualList)
+}
+
+// DownloadCompressedStream - download and extract archive, when expectedChecksum is not empty, SHA-256 of downloaded archive shall be equal to expectedChecksum, otherwise nothing is extracted into localPath
+func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remotePath string, localPath string, expectedChecksum string, maxSpeed uint64) error {
 	if err := os.MkdirAll(localPath, 0750); err != nil {
 		return err
 	}
 	// get this first as GetFileReader blocks the ftp control channel
-	remoteFileInfo, err := bd.StatFile(ctx, remotePath)
+	// the whole download is retried by caller
+	remoteFileInfo, err := bd.RemoteStorage.StatFile(ctx, remotePath)
 	if err != nil {
 		return err
 	}
 	startTime := time.Now()
-	reader, err := bd.GetFileReaderWithLocalPath(ctx, remotePath, localPath)
+	reader, err := bd.RemoteStorage.GetFileReaderWithLocalPath(ctx, remotePath, localPath)
 	if err != nil {
 		return err
 	}
@@ -293,8 +513,24 @@ func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remot
 		}
 	}()
 
+	countingReader := bd.newCountingReadCloser(reader, TransferDownload)
+	var archiveReader io.Reader = countingReader
+	extractPath := localPath
+	checksumHash := sha256.New()
+	if expectedChecksum != "" {
+		// corrupted archive shall not be extracted into localPath, so extract into staging directory while calculating checksum, extracted files need the same space as in localPath, no additional space for archive required
+		if extractPath, err = os.MkdirTemp(localPath, ".download-*.tmp"); err != nil {
+			return err
+		}
+		defer func() {
+			if err := os.RemoveAll(extractPath); err != nil {
+				log.Warn().Msgf("can't remove %s: %v", extractPath, err)
+			}
+		}()
+		archiveReader = io.TeeReader(countingReader, checksumHash)
+	}
 	buf := buffer.New(BufferSize)
-	bufReader := nio.NewReader(reader, buf)
+	bufReader := nio.NewReader(archiveReader, buf)
 	compressionFormat := bd.compressionFormat
 	if !checkArchiveExtension(path.Ext(remotePath), compressionFormat) {
 		log.Warn().Msgf("remote file backup extension %s not equal with %s", remotePath, compressionFormat)
@@ -313,7 +549,7 @@ func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remot
 		if !ok {
 			return fmt.Errorf("expected header to be *tar.Header but was %T", file.Header)
 		}
-		extractFile := filepath.Join(localPath, header.Name)
+		extractFile := filepath.Join(extractPath, header.Name)
 		extractDir := filepath.Dir(extractFile)
 		if _, err := os.Stat(extractDir); os.IsNotExist(err) {
 			_ = os.MkdirAll(extractDir, 0750)
@@ -343,16 +579,48 @@ func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remot
 	}); err != nil {
 		return err
 	}
+	if expectedChecksum != "" {
+		// archive could contain padding after last file which is not read during extract
+		if _, err = io.Copy(io.Discard, bufReader); err != nil {
+			return err
+		}
+		if actualChecksum := hex.EncodeToString(checksumHash.Sum(nil)); actualChecksum != expectedChecksum {
+			return fmt.Errorf("%s checksum mismatch, expected sha256=%s, actual sha256=%s", remotePath, expectedChecksum, actualChecksum)
+		}
+		if err = moveExtractedFiles(extractPath, localPath); err != nil {
+			return err
+		}
+	}
+	countingReader.report()
 	bd.throttleSpeed(startTime, remoteFileInfo.Size(), maxSpeed)
 	return nil
 }
 
-func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLocalPath string, files []string, remotePath string, maxSpeed uint64) error {
+// moveExtractedFiles - rename verified files from staging directory into localPath, staging directory is inside localPath, so rename doesn't copy data
+func moveExtractedFiles(extractPath, localPath string) error {
+	return filepath.Walk(extractPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(extractPath, filePath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(localPath, relativePath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, 0750)
+		}
+		return os.Rename(filePath, dstPath)
+	})
+}
+
+// UploadCompressedStream - archive files and upload as one object, return SHA-256 checksum of uploaded object
+func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLocalPath string, files []string, remotePath string, maxSpeed uint64) (string, error) {
 	var totalBytes int64
 	for _, filename := range files {
 		fInfo, err := os.Stat(path.Join(baseLocalPath, filename))
 		if err != nil {
-			return err
+			return "", err
 		}
 		if fInfo.Mode().IsRegular() {
 			totalBytes += fInfo.Size()
@@ -363,6 +631,10 @@ func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLoc
 	g, ctx := errgroup.WithContext(ctx)
 	startTime := time.Now()
 	var writerErr, readerErr error
+	// tar adds header and padding for each file, compression of already compressed data could add a little
+	countingBody := bd.newCountingReadCloser(body, TransferUpload)
+	countingBody.hash = sha256.New()
+	countingBody.expectedSize = totalBytes + totalBytes/100 + int64(len(files)+1)*1024
 	g.Go(func() error {
 		defer func() {
 			if writerErr != nil {
@@ -375,7 +647,7 @@ func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLoc
 				}
 			}
 		}()
-		z, err := getArchiveWriter(bd.compressionFormat, bd.compressionLevel)
+		z, err := getArchiveWriter(bd.compressionFormat, bd.compressionLevel, bd.compressionConcurrency)
 		if err != nil {
 			return err
 		}
@@ -417,25 +689,28 @@ func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLoc
 				}
 			}
 		}()
-		readerErr = bd.PutFile(ctx, remotePath, body)
+		readerErr = bd.PutFile(ctx, remotePath, countingBody)
 		return readerErr
 	})
-	if waitErr := g.Wait(); waitErr != nil {
-		return waitErr
+	waitErr := g.Wait()
+	countingBody.report()
+	if waitErr != nil {
+		return "", waitErr
 	}
 	bd.throttleSpeed(startTime, totalBytes, maxSpeed)
-	return nil
+	return hex.EncodeToString(countingBody.hash.Sum(nil)), nil
 }
 
-func (bd *BackupDestination) DownloadPath(ctx context.Context, remotePath string, localPath string, RetriesOnFailure int, RetriesDuration time.Duration, maxSpeed uint64) error {
+// DownloadPath - download all files from remotePath, when expectedChecksums contains file name relative to remotePath, SHA-256 of downloaded file shall be equal to it
+func (bd *BackupDestination) DownloadPath(ctx context.Context, remotePath string, localPath string, expectedChecksums map[string]string, retryConfig *config.GeneralConfig, maxSpeed uint64) error {
 	return bd.Walk(ctx, remotePath, true, func(ctx context.Context, f RemoteFile) error {
 		if bd.Kind() == "SFTP" && (f.Name() == "." || f.Name() == "..") {
 			return nil
 		}
-		retry := retrier.New(retrier.ConstantBackoff(RetriesOnFailure, RetriesDuration), nil)
+		retry := NewRetrier(retryConfig)
 		err := retry.RunCtx(ctx, func(ctx context.Context) error {
 			startTime := time.Now()
-			r, err := bd.GetFileReader(ctx, path.Join(remotePath, f.Name()))
+			r, err := bd.RemoteStorage.GetFileReader(ctx, path.Join(remotePath, f.Name()))
 			if err != nil {
 				log.Error().Err(err).Send()
 				return err
@@ -451,7 +726,14 @@ func (bd *BackupDestination) DownloadPath(ctx context.Context, remotePath string
 				log.Error().Err(err).Send()
 				return err
 			}
-			if _, err := io.Copy(dst, r); err != nil {
+			checksumReader := bd.newCountingReadCloser(r, TransferDownload)
+			expectedChecksum, checksumExists := expectedChecksums[f.Name()]
+			if checksumExists {
+				checksumReader.hash = sha256.New()
+			}
+			_, err = io.Copy(dst, checksumReader)
+			checksumReader.report()
+			if err != nil {
 				log.Error().Err(err).Send()
 				return err
 			}
@@ -463,6 +745,14 @@ func (bd *BackupDestination) DownloadPath(ctx context.Context, remotePath string
 				log.Error().Err(err).Send()
 				return err
 			}
+			if checksumExists {
+				if actualChecksum := hex.EncodeToString(checksumReader.hash.Sum(nil)); actualChecksum != expectedChecksum {
+					if err := os.Remove(dstFilePath); err != nil {
+						log.Warn().Msgf("can't remove %s: %v", dstFilePath, err)
+					}
+					return fmt.Errorf("%s checksum mismatch, expected sha256=%s, actual sha256=%s", path.Join(remotePath, f.Name()), expectedChecksum, actualChecksum)
+				}
+			}
 
 			if dstFileInfo, err := os.Stat(dstFilePath); err == nil {
 				bd.throttleSpeed(startTime, dstFileInfo.Size(), maxSpeed)
@@ -479,39 +769,52 @@ func (bd *BackupDestination) DownloadPath(ctx context.Context, remotePath string
 	})
 }
 
-func (bd *BackupDestination) UploadPath(ctx context.Context, baseLocalPath string, files []string, remotePath string, RetriesOnFailure int, RetriesDuration time.Duration, maxSpeed uint64) (int64, error) {
+// UploadPath - upload each file as separate object, return uploaded bytes and SHA-256 checksum for each file name relative to remotePath
+func (bd *BackupDestination) UploadPath(ctx context.Context, baseLocalPath string, files []string, remotePath string, retryConfig *config.GeneralConfig, maxSpeed uint64) (int64, map[string]string, error) {
 	totalBytes := int64(0)
+	checksums := make(map[string]string, len(files))
 	for _, filename := range files {
 		startTime := time.Now()
 		fInfo, err := os.Stat(filepath.Clean(path.Join(baseLocalPath, filename)))
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 		if fInfo.Mode().IsRegular() {
 			totalBytes += fInfo.Size()
 		}
 		f, err := os.Open(filepath.Clean(path.Join(baseLocalPath, filename)))
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 		closeFile := func() {
 			if err := f.Close(); err != nil {
 				log.Warn().Msgf("can't close UploadPath file descriptor %v: %v", f, err)
 			}
 		}
-		retry := retrier.New(retrier.ConstantBackoff(RetriesOnFailure, RetriesDuration), nil)
+		var checksumReader *countingReadCloser
+		retry := NewRetrier(retryConfig)
 		err = retry.RunCtx(ctx, func(ctx context.Context) error {
-			return bd.PutFile(ctx, path.Join(remotePath, filename), f)
+			// each retry shall upload and checksum the whole file again
+			if _, seekErr := f.Seek(0, io.SeekStart); seekErr != nil {
+				return seekErr
+			}
+			checksumReader = bd.newCountingReadCloser(f, TransferUpload)
+			checksumReader.hash = sha256.New()
+			checksumReader.expectedSize = fInfo.Size()
+			putErr := bd.PutFile(ctx, path.Join(remotePath, filename), checksumReader)
+			checksumReader.report()
+			return putErr
 		})
 		if err != nil {
 			closeFile()
-			return 0, err
+			return 0, nil, err
 		}
 		closeFile()
+		checksums[strings.TrimPrefix(filename, "/")] = hex.EncodeToString(checksumReader.hash.Sum(nil))
 		bd.throttleSpeed(startTime, fInfo.Size(), maxSpeed)
 	}
 
-	return totalBytes, nil
+	return totalBytes, checksums, nil
 }
 
 func (bd *BackupDestination) throttleSpeed(startTime time.Time, size int64, maxSpeed uint64) {
@@ -529,6 +832,9 @@ func (bd *BackupDestination) throttleSpeed(startTime time.Time, size int64, maxS
 	}
 }
 
+// SupportedRemoteStorages - `remote_storage` values which are implemented in NewBackupDestination, `custom` and `none` are processed separately
+var SupportedRemoteStorages = []string{"azblob", "s3", "gcs", "cos", "ftp", "sftp", "local"}
+
 func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhouse.ClickHouse, backupName string) (*BackupDestination, error) {
 	var err error
 	switch cfg.General.RemoteStorage {
@@ -553,10 +859,15 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 			bufferSize = AdjustAzblobBufferSize(bufferSize)
 		}
 		azblobStorage.Config.BufferSize = bufferSize
+		azblobStorage.Config.Proxy = cfg.GetRemoteProxy(cfg.AzureBlob.Proxy)
 		return &BackupDestination{
 			azblobStorage,
 			cfg.AzureBlob.CompressionFormat,
 			cfg.AzureBlob.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	case "s3":
 		partSize := cfg.S3.PartSize
@@ -588,10 +899,23 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 			}
 			s3Storage.Config.ObjectLabels = objectLabels
 		}
+		if len(s3Storage.Config.ObjectMetadata) > 0 && backupName != "" {
+			objectMetadata := s3Storage.Config.ObjectMetadata
+			objectMetadata, err = ch.ApplyMacrosToObjectLabels(ctx, objectMetadata, backupName)
+			if err != nil {
+				return nil, err
+			}
+			s3Storage.Config.ObjectMetadata = objectMetadata
+		}
+		s3Storage.Config.Proxy = cfg.GetRemoteProxy(cfg.S3.Proxy)
 		return &BackupDestination{
 			s3Storage,
 			cfg.S3.CompressionFormat,
 			cfg.S3.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	case "gcs":
 		googleCloudStorage := &GCS{Config: &cfg.GCS}
@@ -610,10 +934,15 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 			}
 			googleCloudStorage.Config.ObjectLabels = objectLabels
 		}
+		googleCloudStorage.Config.Proxy = cfg.GetRemoteProxy(cfg.GCS.Proxy)
 		return &BackupDestination{
 			googleCloudStorage,
 			cfg.GCS.CompressionFormat,
 			cfg.GCS.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	case "cos":
 		tencentStorage := &COS{Config: &cfg.COS}
@@ -627,6 +956,10 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 			tencentStorage,
 			cfg.COS.CompressionFormat,
 			cfg.COS.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	case "ftp":
 		if cfg.FTP.Concurrency < cfg.General.ObjectDiskServerSideCopyConcurrency/4 {
@@ -641,10 +974,15 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 		if ftpStorage.Config.ObjectDiskPath, err = ch.ApplyMacros(ctx, ftpStorage.Config.ObjectDiskPath); err != nil {
 			return nil, err
 		}
+		ftpStorage.Config.Proxy = cfg.GetRemoteProxy(cfg.FTP.Proxy)
 		return &BackupDestination{
 			ftpStorage,
 			cfg.FTP.CompressionFormat,
 			cfg.FTP.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	case "sftp":
 		sftpStorage := &SFTP{
@@ -661,6 +999,29 @@ func NewBackupDestination(ctx context.Context, cfg *config.Config, ch *clickhous
 			sftpStorage,
 			cfg.SFTP.CompressionFormat,
 			cfg.SFTP.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
+		}, nil
+	case "local":
+		localStorage := &Local{
+			Config: &cfg.Local,
+		}
+		if localStorage.Config.Path, err = ch.ApplyMacros(ctx, localStorage.Config.Path); err != nil {
+			return nil, err
+		}
+		if localStorage.Config.ObjectDiskPath, err = ch.ApplyMacros(ctx, localStorage.Config.ObjectDiskPath); err != nil {
+			return nil, err
+		}
+		return &BackupDestination{
+			localStorage,
+			cfg.Local.CompressionFormat,
+			cfg.Local.CompressionLevel,
+			cfg.GetCompressionConcurrency(),
+			newRemoteTimeouts(cfg),
+			&cfg.General,
+			nil,
 		}, nil
 	default:
 		return nil, fmt.Errorf("NewBackupDestination error: storage type '%s' is not supported", cfg.General.RemoteStorage)
@@ -0,0 +1,375 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamingRemoteStorage - accept only PutFile, other RemoteStorage methods shall not be called during UploadCompressedStream
+type streamingRemoteStorage struct {
+	RemoteStorage
+	key     string
+	body    []byte
+	tempDir string
+	// tempFiles - files in temp dir when the first byte of archive was received
+	tempFiles int
+}
+
+func (s *streamingRemoteStorage) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	s.key = key
+	firstByte := make([]byte, 1)
+	if _, err := io.ReadFull(r, firstByte); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.tempDir)
+	if err != nil {
+		return err
+	}
+	s.tempFiles = len(entries)
+	rest, err := io.ReadAll(r)
+	s.body = append(firstByte, rest...)
+	return err
+}
+
+func TestUploadCompressedStreamWithoutTempFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+	basePath := t.TempDir()
+	files := map[string][]byte{
+		"all_1_1_0/data.bin":  bytes.Repeat([]byte{1}, 4*BufferSize),
+		"all_1_1_0/data.mrk3": bytes.Repeat([]byte{2}, 1024),
+	}
+	fileNames := make([]string, 0, len(files))
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(basePath, name)), 0750))
+		assert.NoError(t, os.WriteFile(path.Join(basePath, name), content, 0640))
+		fileNames = append(fileNames, name)
+	}
+
+	remoteStorage := &streamingRemoteStorage{tempDir: tempDir}
+	bd := &BackupDestination{RemoteStorage: remoteStorage, compressionFormat: "tar"}
+	checksum, err := bd.UploadCompressedStream(context.Background(), basePath, fileNames, "backup/shadow/db/table/default_1.tar", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup/shadow/db/table/default_1.tar", remoteStorage.key)
+	assert.Equal(t, 0, remoteStorage.tempFiles)
+	expectedChecksum := sha256.Sum256(remoteStorage.body)
+	assert.Equal(t, hex.EncodeToString(expectedChecksum[:]), checksum)
+
+	tarReader := tar.NewReader(bytes.NewReader(remoteStorage.body))
+	archived := 0
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		assert.NoError(t, err)
+		assert.Equal(t, files[header.Name], content)
+		archived++
+	}
+	assert.Equal(t, len(files), archived)
+}
+
+// lockedRemoteStorage - metadata.json of each backup is locked until retainUntil, DeleteFile shall not be called for locked backup
+type lockedRemoteStorage struct {
+	RemoteStorage
+	retainUntil time.Time
+	deleted     []string
+}
+
+func (s *lockedRemoteStorage) Kind() string {
+	return "S3"
+}
+
+func (s *lockedRemoteStorage) GetObjectLockRetainUntil(ctx context.Context, key string) (time.Time, error) {
+	return s.retainUntil, nil
+}
+
+func (s *lockedRemoteStorage) Walk(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	return process(ctx, &localFile{name: "metadata.json", size: 1})
+}
+
+func (s *lockedRemoteStorage) DeleteFile(ctx context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func TestRemoveBackupRemoteWithObjectLock(t *testing.T) {
+	cfg := &config.Config{}
+	remoteStorage := &lockedRemoteStorage{retainUntil: time.Now().Add(time.Hour)}
+	bd := &BackupDestination{RemoteStorage: remoteStorage}
+	err := bd.RemoveBackupRemote(context.Background(), Backup{BackupMetadata: metadata.BackupMetadata{BackupName: "backup1"}}, cfg)
+	assert.ErrorIs(t, err, ErrObjectLocked)
+	assert.Empty(t, remoteStorage.deleted)
+
+	remoteStorage.retainUntil = time.Now().Add(-time.Hour)
+	assert.NoError(t, bd.RemoveBackupRemote(context.Background(), Backup{BackupMetadata: metadata.BackupMetadata{BackupName: "backup1"}}, cfg))
+	assert.Equal(t, []string{"backup1/metadata.json"}, remoteStorage.deleted)
+}
+
+// hungRemoteStorage - Connect and PutFile block until ctx is done, like unresponsive server
+type hungRemoteStorage struct {
+	RemoteStorage
+}
+
+func (s *hungRemoteStorage) Kind() string {
+	return "hung"
+}
+
+func (s *hungRemoteStorage) Connect(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *hungRemoteStorage) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBackupDestinationTimeouts(t *testing.T) {
+	bd := &BackupDestination{
+		RemoteStorage: &hungRemoteStorage{},
+		timeouts:      remoteTimeouts{connect: 10 * time.Millisecond, upload: 10 * time.Millisecond},
+	}
+	err := bd.Connect(context.Background())
+	assert.ErrorContains(t, err, "hung connect timeout 10ms exceeded")
+
+	err = bd.PutFile(context.Background(), "key", io.NopCloser(bytes.NewReader(nil)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewRemoteTimeouts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.General.RemoteStorage = "s3"
+	cfg.S3.UploadTimeout = "2h"
+	assert.Equal(t, remoteTimeouts{connect: time.Minute, upload: 2 * time.Hour, list: time.Hour}, newRemoteTimeouts(cfg))
+	// overrides of other storage are not applied
+	cfg.General.RemoteStorage = "gcs"
+	assert.Equal(t, remoteTimeouts{connect: time.Minute, upload: 4 * time.Hour, list: time.Hour}, newRemoteTimeouts(cfg))
+	cfg.GCS.ListTimeout = "0s"
+	assert.Equal(t, time.Duration(0), newRemoteTimeouts(cfg).list)
+}
+
+// memoryRemoteStorage - keep uploaded objects in memory, allow corrupt them before download
+type memoryRemoteStorage struct {
+	RemoteStorage
+	objects map[string][]byte
+}
+
+func (s *memoryRemoteStorage) Kind() string {
+	return "memory"
+}
+
+func (s *memoryRemoteStorage) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	body, err := io.ReadAll(r)
+	s.objects[key] = body
+	return err
+}
+
+func (s *memoryRemoteStorage) StatFile(ctx context.Context, key string) (RemoteFile, error) {
+	body, exists := s.objects[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &localFile{name: key, size: int64(len(body))}, nil
+}
+
+func (s *memoryRemoteStorage) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, exists := s.objects[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (s *memoryRemoteStorage) GetFileReaderWithLocalPath(ctx context.Context, key, localPath string) (io.ReadCloser, error) {
+	return s.GetFileReader(ctx, key)
+}
+
+func (s *memoryRemoteStorage) Walk(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	for key, body := range s.objects {
+		if name, isInside := strings.CutPrefix(key, prefix+"/"); isInside {
+			if err := process(ctx, &localFile{name: name, size: int64(len(body))}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestUploadDownloadPathChecksums(t *testing.T) {
+	basePath := t.TempDir()
+	files := map[string][]byte{
+		"all_1_1_0/data.bin":      bytes.Repeat([]byte{1}, 1024),
+		"all_1_1_0/checksums.txt": []byte("checksums"),
+	}
+	fileNames := make([]string, 0, len(files))
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(basePath, name)), 0750))
+		assert.NoError(t, os.WriteFile(path.Join(basePath, name), content, 0640))
+		fileNames = append(fileNames, name)
+	}
+	remoteStorage := &memoryRemoteStorage{objects: map[string][]byte{}}
+	bd := &BackupDestination{RemoteStorage: remoteStorage}
+	retryConfig := &config.GeneralConfig{RetriesOnFailure: 0}
+	uploadedBytes, checksums, err := bd.UploadPath(context.Background(), basePath, fileNames, "backup/shadow/db/table/default", retryConfig, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024+len("checksums")), uploadedBytes)
+	for name, content := range files {
+		expectedChecksum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(expectedChecksum[:]), checksums[name])
+	}
+
+	// DownloadPath expects names relative to part path
+	partChecksums := map[string]string{
+		"data.bin":      checksums["all_1_1_0/data.bin"],
+		"checksums.txt": checksums["all_1_1_0/checksums.txt"],
+	}
+	localPath := t.TempDir()
+	assert.NoError(t, bd.DownloadPath(context.Background(), "backup/shadow/db/table/default/all_1_1_0", localPath, partChecksums, retryConfig, 0))
+	content, err := os.ReadFile(path.Join(localPath, "data.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, files["all_1_1_0/data.bin"], content)
+
+	remoteStorage.objects["backup/shadow/db/table/default/all_1_1_0/data.bin"][0] = 2
+	localPath = t.TempDir()
+	err = bd.DownloadPath(context.Background(), "backup/shadow/db/table/default/all_1_1_0", localPath, partChecksums, retryConfig, 0)
+	assert.ErrorContains(t, err, "data.bin checksum mismatch")
+	assert.NoFileExists(t, path.Join(localPath, "data.bin"))
+}
+
+func TestDownloadCompressedStreamVerifyChecksum(t *testing.T) {
+	basePath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(basePath, "all_1_1_0"), 0750))
+	assert.NoError(t, os.WriteFile(path.Join(basePath, "all_1_1_0/data.bin"), bytes.Repeat([]byte{1}, 4*BufferSize), 0640))
+	remoteStorage := &memoryRemoteStorage{objects: map[string][]byte{}}
+	bd := &BackupDestination{RemoteStorage: remoteStorage, compressionFormat: "tar"}
+	remotePath := "backup/shadow/db/table/default_all_1_1_0.tar"
+	checksum, err := bd.UploadCompressedStream(context.Background(), basePath, []string{"all_1_1_0/data.bin"}, remotePath, 0)
+	assert.NoError(t, err)
+
+	localPath := t.TempDir()
+	assert.NoError(t, bd.DownloadCompressedStream(context.Background(), remotePath, localPath, checksum, 0))
+	assert.FileExists(t, path.Join(localPath, "all_1_1_0/data.bin"))
+	entries, err := os.ReadDir(localPath)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary directory shall be removed after extract")
+
+	// corrupted data inside archive, tar headers are valid, so extraction would succeed without checksum
+	archive := remoteStorage.objects[remotePath]
+	archive[len(archive)-2*BufferSize] ^= 0xff
+	localPath = t.TempDir()
+	err = bd.DownloadCompressedStream(context.Background(), remotePath, localPath, checksum, 0)
+	assert.ErrorContains(t, err, "checksum mismatch")
+	entries, err = os.ReadDir(localPath)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "corrupted archive shall not be extracted")
+}
+
+// flakyRemoteStorage - fail each operation `failures` times before pass it to memoryRemoteStorage
+type flakyRemoteStorage struct {
+	memoryRemoteStorage
+	failures int
+	calls    map[string]int
+}
+
+func (s *flakyRemoteStorage) fail(operation string) error {
+	s.calls[operation]++
+	if s.calls[operation] <= s.failures {
+		return fmt.Errorf("%s: connection reset by peer", operation)
+	}
+	return nil
+}
+
+func (s *flakyRemoteStorage) StatFile(ctx context.Context, key string) (RemoteFile, error) {
+	if err := s.fail("StatFile"); err != nil {
+		return nil, err
+	}
+	return s.memoryRemoteStorage.StatFile(ctx, key)
+}
+
+func (s *flakyRemoteStorage) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := s.fail("GetFileReader"); err != nil {
+		return nil, err
+	}
+	return s.memoryRemoteStorage.GetFileReader(ctx, key)
+}
+
+// Walk - fail after first processed file, to check already processed files are skipped on retry
+func (s *flakyRemoteStorage) Walk(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	processed := 0
+	return s.memoryRemoteStorage.Walk(ctx, prefix, recursive, func(ctx context.Context, f RemoteFile) error {
+		if processed > 0 {
+			if err := s.fail("Walk"); err != nil {
+				return err
+			}
+		}
+		processed++
+		return process(ctx, f)
+	})
+}
+
+func TestBackupDestinationRetries(t *testing.T) {
+	s := &flakyRemoteStorage{
+		memoryRemoteStorage: memoryRemoteStorage{objects: map[string][]byte{"backup/a": []byte("a"), "backup/b": []byte("bb"), "backup/c": []byte("ccc")}},
+		failures:            2,
+		calls:               map[string]int{},
+	}
+	bd := &BackupDestination{RemoteStorage: s, retryConfig: &config.GeneralConfig{RetriesOnFailure: 2}}
+	ctx := context.Background()
+
+	f, err := bd.StatFile(ctx, "backup/a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), f.Size())
+	assert.Equal(t, 3, s.calls["StatFile"])
+
+	body, err := bd.ReadFile(ctx, "backup/b")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bb"), body)
+
+	processed := map[string]int{}
+	assert.NoError(t, bd.Walk(ctx, "backup", true, func(ctx context.Context, f RemoteFile) error {
+		processed[f.Name()]++
+		return nil
+	}))
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, processed)
+
+	// errors of process are returned as is without retries
+	processErr := errors.New("process error")
+	walkCalls := 0
+	assert.ErrorIs(t, bd.Walk(ctx, "backup", true, func(ctx context.Context, f RemoteFile) error {
+		walkCalls++
+		return processErr
+	}), processErr)
+	assert.Equal(t, 1, walkCalls)
+
+	// ErrNotFound is not retried
+	s.calls["StatFile"] = 0
+	s.failures = 0
+	_, err = bd.StatFile(ctx, "backup/unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, s.calls["StatFile"])
+
+	// exhausted retries return last error
+	s.failures = 10
+	s.calls["StatFile"] = 0
+	_, err = bd.StatFile(ctx, "backup/a")
+	assert.Error(t, err)
+	assert.Equal(t, 3, s.calls["StatFile"])
+}
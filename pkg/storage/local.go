@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+// Local - implement RemoteStorage on top of local directory, usually mounted NFS or SMB share, files layout is the same as for object storages
+type Local struct {
+	Config *config.LocalConfig
+}
+
+func (l *Local) Debug(msg string, v ...interface{}) {
+	if l.Config.Debug {
+		log.Info().Msgf(msg, v...)
+	}
+}
+
+func (l *Local) Kind() string {
+	return "local"
+}
+
+// Connect - path shall exist, to avoid write backups into empty mount point when share is not mounted
+func (l *Local) Connect(ctx context.Context) error {
+	stat, err := os.Stat(l.Config.Path)
+	if err != nil {
+		return fmt.Errorf("local->path %s is not available: %v", l.Config.Path, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("local->path %s is not a directory", l.Config.Path)
+	}
+	return nil
+}
+
+func (l *Local) Close(ctx context.Context) error {
+	return nil
+}
+
+func (l *Local) StatFile(ctx context.Context, key string) (RemoteFile, error) {
+	filePath := path.Join(l.Config.Path, key)
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		l.Debug("[LOCAL_DEBUG] StatFile::STAT %s return error %v", filePath, err)
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &localFile{
+		size:         stat.Size(),
+		lastModified: stat.ModTime(),
+		name:         stat.Name(),
+	}, nil
+}
+
+// DeleteFile - key could be a directory, whole backup is deleted by one call in RemoveBackupRemote
+func (l *Local) DeleteFile(ctx context.Context, key string) error {
+	l.Debug("[LOCAL_DEBUG] Delete %s", key)
+	return l.deleteAbsolute(path.Join(l.Config.Path, key))
+}
+
+func (l *Local) DeleteFileFromObjectDiskBackup(ctx context.Context, key string) error {
+	l.Debug("[LOCAL_DEBUG] DeleteFileFromObjectDiskBackup %s", key)
+	return l.deleteAbsolute(path.Join(l.Config.ObjectDiskPath, key))
+}
+
+func (l *Local) deleteAbsolute(filePath string) error {
+	if _, err := os.Stat(filePath); err != nil {
+		l.Debug("[LOCAL_DEBUG] Delete::STAT %s return error %v", filePath, err)
+		return err
+	}
+	return os.RemoveAll(filePath)
+}
+
+func (l *Local) Walk(ctx context.Context, remotePath string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	prefix := path.Join(l.Config.Path, remotePath)
+	return l.WalkAbsolute(ctx, prefix, recursive, process)
+}
+
+// WalkAbsolute - recursive walk returns only files with path relative to prefix, like object storages, not exists prefix is empty
+func (l *Local) WalkAbsolute(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
+	l.Debug("[LOCAL_DEBUG] Walk %s, recursive=%v", prefix, recursive)
+	if _, err := os.Stat(prefix); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !recursive {
+		entries, err := os.ReadDir(prefix)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err = process(ctx, &localFile{
+				size:         info.Size(),
+				lastModified: info.ModTime(),
+				name:         entry.Name(),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return filepath.WalkDir(prefix, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), localTempFilePrefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relName, err := filepath.Rel(prefix, filePath)
+		if err != nil {
+			return err
+		}
+		return process(ctx, &localFile{
+			size:         info.Size(),
+			lastModified: info.ModTime(),
+			name:         relName,
+		})
+	})
+}
+
+func (l *Local) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	return l.GetFileReaderAbsolute(ctx, path.Join(l.Config.Path, key))
+}
+
+func (l *Local) GetFileReaderAbsolute(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(key)
+}
+
+func (l *Local) GetFileReaderWithLocalPath(ctx context.Context, key, _ string) (io.ReadCloser, error) {
+	return l.GetFileReader(ctx, key)
+}
+
+func (l *Local) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	return l.PutFileAbsolute(ctx, path.Join(l.Config.Path, key), r)
+}
+
+// localTempFilePrefix - PutFileAbsolute writes into temporary file and rename it, so interrupted upload doesn't leave partial file with expected name
+const localTempFilePrefix = ".clickhouse-backup-tmp-"
+
+func (l *Local) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error {
+	if err := os.MkdirAll(path.Dir(key), 0750); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(path.Dir(key), localTempFilePrefix+path.Base(key)+"-")
+	if err != nil {
+		return err
+	}
+	tmpName := tmpFile.Name()
+	if _, err = io.Copy(tmpFile, r); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = os.Chmod(tmpName, 0640); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, key)
+}
+
+func (l *Local) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
+	return 0, fmt.Errorf("CopyObject not imlemented for %s", l.Kind())
+}
+
+// Implement RemoteFile
+type localFile struct {
+	size         int64
+	lastModified time.Time
+	name         string
+}
+
+func (file *localFile) Size() int64 {
+	return file.size
+}
+
+func (file *localFile) LastModified() time.Time {
+	return file.lastModified
+}
+
+func (file *localFile) Name() string {
+	return file.name
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalRemoteStorage(t *testing.T) {
+	ctx := context.Background()
+	l := &Local{Config: &config.LocalConfig{Path: path.Join(t.TempDir(), "not_mounted")}}
+	assert.Error(t, l.Connect(ctx))
+	assert.NoError(t, os.MkdirAll(l.Config.Path, 0750))
+	assert.NoError(t, l.Connect(ctx))
+
+	for _, key := range []string{"backup1/metadata.json", "backup1/shadow/db/t/default_1.tar", "backup2/metadata.json"} {
+		assert.NoError(t, l.PutFile(ctx, key, io.NopCloser(strings.NewReader(key))))
+	}
+	f, err := l.StatFile(ctx, "backup1/metadata.json")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("backup1/metadata.json")), f.Size())
+	_, err = l.StatFile(ctx, "backup3/metadata.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	r, err := l.GetFileReader(ctx, "backup1/shadow/db/t/default_1.tar")
+	assert.NoError(t, err)
+	body, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "backup1/shadow/db/t/default_1.tar", string(body))
+
+	walk := func(prefix string, recursive bool) []string {
+		names := make([]string, 0)
+		assert.NoError(t, l.Walk(ctx, prefix, recursive, func(ctx context.Context, f RemoteFile) error {
+			names = append(names, f.Name())
+			return nil
+		}))
+		sort.Strings(names)
+		return names
+	}
+	assert.Equal(t, []string{"backup1", "backup2"}, walk("/", false))
+	assert.Equal(t, []string{"metadata.json", "shadow/db/t/default_1.tar"}, walk("backup1/", true))
+	assert.Empty(t, walk("backup3/", true))
+
+	assert.NoError(t, l.DeleteFile(ctx, "backup1"))
+	assert.Equal(t, []string{"backup2"}, walk("/", false))
+}
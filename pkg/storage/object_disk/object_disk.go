@@ -446,9 +446,10 @@ func makeObjectDiskConnection(ctx context.Context, ch *clickhouse.ClickHouse, cf
 	switch creds.Type {
 	case "s3", "gcs":
 		connection.Type = "s3"
+		// custom CA is appended to system CA pool, so object disks on the same internal PKI endpoint could be verified
 		s3cfg := config.S3Config{
 			Debug: cfg.S3.Debug, MaxPartsCount: cfg.S3.MaxPartsCount, Concurrency: 1,
-			PartSize: cfg.S3.PartSize,
+			PartSize: cfg.S3.PartSize, TLSCa: cfg.S3.TLSCa, Proxy: cfg.GetRemoteProxy(cfg.S3.Proxy),
 		}
 		s3cfg.PartSize = storage.AdjustS3PartSize(s3cfg.PartSize, 5*1024*1024)
 		s3URL, err := url.Parse(creds.EndPoint)
@@ -528,6 +529,7 @@ func makeObjectDiskConnection(ctx context.Context, ch *clickhouse.ClickHouse, cf
 			BufferSize:    cfg.AzureBlob.BufferSize,
 			MaxBuffers:    cfg.AzureBlob.MaxBuffers,
 			MaxPartsCount: cfg.AzureBlob.MaxPartsCount,
+			Proxy:         cfg.GetRemoteProxy(cfg.AzureBlob.Proxy),
 		}
 		azureCfg.BufferSize = storage.AdjustAzblobBufferSize(azureCfg.BufferSize)
 		azureURL, err := url.Parse(creds.EndPoint)
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// newProxyFunc - proxy for http.Transport, net/http supports http, https and socks5 schemes, empty proxy means HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func newProxyFunc(proxyAddress string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyAddress == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %v", err)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// newProxyHTTPTransport - clone of http.DefaultTransport which use proxy
+func newProxyHTTPTransport(proxyAddress string) (*http.Transport, error) {
+	proxyFunc, err := newProxyFunc(proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	return transport, nil
+}
+
+func init() {
+	// golang.org/x/net/proxy supports only socks5 scheme, HTTP CONNECT is registered here
+	proxy.RegisterDialerType("http", newHTTPConnectDialer)
+	proxy.RegisterDialerType("https", newHTTPConnectDialer)
+}
+
+// newProxyDialFunc - establish TCP connection to address via HTTP CONNECT or SOCKS5 proxy, for storages which don't use HTTP, like FTP
+func newProxyDialFunc(ctx context.Context, proxyAddress string, dialer *net.Dialer) (func(network, address string) (net.Conn, error), error) {
+	proxyURL, err := url.Parse(proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %v", err)
+	}
+	proxyDialer, err := proxy.FromURL(proxyURL, dialer)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported proxy %s, allowed schemes http, https, socks5: %v", proxyURL.Redacted(), err)
+	}
+	contextDialer, ok := proxyDialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("proxy dialer for %s doesn't support context", proxyURL.Scheme)
+	}
+	return func(network, address string) (net.Conn, error) {
+		conn, err := contextDialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s can't connect to %s: %v", proxyURL.Host, address, err)
+		}
+		return conn, nil
+	}, nil
+}
+
+// httpConnectDialer - proxy.ContextDialer for http and https proxy schemes
+type httpConnectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.Dialer
+}
+
+func newHTTPConnectDialer(proxyURL *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &httpConnectDialer{proxyURL: proxyURL, forward: forward}, nil
+}
+
+func (d *httpConnectDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	proxyAddress := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		defaultPorts := map[string]string{"http": "80", "https": "443"}
+		proxyAddress = net.JoinHostPort(d.proxyURL.Hostname(), defaultPorts[d.proxyURL.Scheme])
+	}
+	var conn net.Conn
+	var err error
+	if contextDialer, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = contextDialer.DialContext(ctx, network, proxyAddress)
+	} else {
+		conn, err = d.forward.Dial(network, proxyAddress)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to proxy %s: %v", proxyAddress, err)
+	}
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy %s TLS handshake error: %v", proxyAddress, err)
+		}
+		conn = tlsConn
+	}
+	// handshake shall not hang forever when proxy accept connection but doesn't answer
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	proxyConn, err := httpConnectHandshake(conn, d.proxyURL, address)
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return proxyConn, nil
+}
+
+// bufferedConn - remote server could send data right after CONNECT response, like FTP greeting, and it could be already read into bufio.Reader
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func httpConnectHandshake(conn net.Conn, proxyURL *url.URL, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected CONNECT response: %s", resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startFakeProxy - accept one connection, run proxy side of handshake, then send greeting like FTP server does right after connect
+func startFakeProxy(t *testing.T, handshake func(conn net.Conn, reader *bufio.Reader) string) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		targets <- handshake(conn, bufio.NewReader(conn))
+		_, _ = conn.Write([]byte("220 ready\r\n"))
+	}()
+	return listener.Addr().String(), targets
+}
+
+func readGreeting(t *testing.T, conn net.Conn) {
+	greeting, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "220 ready\r\n", greeting)
+}
+
+func TestProxyDialFuncHTTPConnect(t *testing.T) {
+	proxyAddress, targets := startFakeProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		req, err := http.ReadRequest(reader)
+		if err != nil || req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return ""
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		return req.Host
+	})
+	dialFunc, err := newProxyDialFunc(context.Background(), "http://user:pass@"+proxyAddress, &net.Dialer{Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+	conn, err := dialFunc("tcp", "ftp.example.com:21")
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	assert.Equal(t, "ftp.example.com:21", <-targets)
+	readGreeting(t, conn)
+}
+
+func TestProxyDialFuncSOCKS5(t *testing.T) {
+	proxyAddress, targets := startFakeProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(reader, greeting); err != nil {
+			return ""
+		}
+		methods := make([]byte, int(greeting[1]))
+		if _, err := io.ReadFull(reader, methods); err != nil || !bytes.Contains(methods, []byte{0x02}) {
+			return ""
+		}
+		_, _ = conn.Write([]byte{0x05, 0x02})
+		auth := make([]byte, 2+4+1+4)
+		if _, err := io.ReadFull(reader, auth); err != nil || string(auth[2:6]) != "user" || string(auth[7:]) != "pass" {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			return ""
+		}
+		_, _ = conn.Write([]byte{0x01, 0x00})
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(reader, header); err != nil || header[3] != 0x03 {
+			return ""
+		}
+		host := make([]byte, int(header[4])+2)
+		if _, err := io.ReadFull(reader, host); err != nil {
+			return ""
+		}
+		_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+		return net.JoinHostPort(string(host[:len(host)-2]), strconv.Itoa(int(binary.BigEndian.Uint16(host[len(host)-2:]))))
+	})
+	dialFunc, err := newProxyDialFunc(context.Background(), "socks5://user:pass@"+proxyAddress, &net.Dialer{Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+	conn, err := dialFunc("tcp", "ftp.example.com:2121")
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	assert.Equal(t, "ftp.example.com:2121", <-targets)
+	readGreeting(t, conn)
+}
+
+func TestProxyDialFuncErrors(t *testing.T) {
+	_, err := newProxyDialFunc(context.Background(), "ftp://proxy:21", &net.Dialer{})
+	assert.Error(t, err)
+
+	proxyAddress, _ := startFakeProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		_, _ = http.ReadRequest(reader)
+		_, _ = conn.Write([]byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+		return ""
+	})
+	dialFunc, err := newProxyDialFunc(context.Background(), "http://"+proxyAddress, &net.Dialer{Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+	_, err = dialFunc("tcp", "ftp.example.com:21")
+	assert.ErrorContains(t, err, "403 Forbidden")
+}
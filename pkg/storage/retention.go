@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+// SelectBackupsToKeep - dates shall be sorted descending, returns which backups shall be kept according to policy
+// daily, weekly and monthly rules keep the newest backup for each of N latest calendar periods which contain backups
+func SelectBackupsToKeep(dates []time.Time, policy config.RetentionPolicy, now time.Time, location *time.Location) []bool {
+	if location == nil {
+		location = time.UTC
+	}
+	isKept := make([]bool, len(dates))
+	for i := range dates {
+		if i < policy.KeepLast {
+			isKept[i] = true
+		}
+	}
+	if policy.KeepDays > 0 {
+		threshold := now.AddDate(0, 0, -policy.KeepDays)
+		for i, d := range dates {
+			if !d.IsZero() && !d.Before(threshold) {
+				isKept[i] = true
+			}
+		}
+	}
+	keepNewestInPeriod := func(periods int, periodKey func(t time.Time) string) {
+		if periods <= 0 {
+			return
+		}
+		keptPeriods := make(map[string]struct{}, periods)
+		for i, d := range dates {
+			if d.IsZero() {
+				continue
+			}
+			key := periodKey(d.In(location))
+			if _, exists := keptPeriods[key]; exists {
+				continue
+			}
+			if len(keptPeriods) >= periods {
+				break
+			}
+			keptPeriods[key] = struct{}{}
+			isKept[i] = true
+		}
+	}
+	keepNewestInPeriod(policy.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestInPeriod(policy.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewestInPeriod(policy.Monthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+	return isKept
+}
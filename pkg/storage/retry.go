@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/eapache/go-resiliency/retrier"
+)
+
+// NewRetrier - shared retry policy for remote storage operations, general->retries_on_failure retries, general->retries_pause before first retry,
+// for `retries_backoff: exponential` pause is doubled after each retry up to general->retries_max_pause, general->retries_jitter randomize pauses of parallel uploads and downloads
+func NewRetrier(cfg *config.GeneralConfig, nonRetryableErrors ...error) *retrier.Retrier {
+	return newRetrier(cfg, retryClassifier{nonRetryableErrors: nonRetryableErrors})
+}
+
+// NewUploadedStatRetrier - the same as NewRetrier for StatFile right after upload, ErrNotFound is retried, cause eventually consistent remote storage could not show just uploaded object
+func NewUploadedStatRetrier(cfg *config.GeneralConfig) *retrier.Retrier {
+	return newRetrier(cfg, retryClassifier{retryNotFound: true})
+}
+
+func newRetrier(cfg *config.GeneralConfig, classifier retryClassifier) *retrier.Retrier {
+	var backoff []time.Duration
+	if cfg.RetriesBackoff == "exponential" {
+		backoff = retrier.LimitedExponentialBackoff(cfg.RetriesOnFailure, cfg.RetriesDuration, max(cfg.RetriesMaxDuration, cfg.RetriesDuration))
+	} else {
+		backoff = retrier.ConstantBackoff(cfg.RetriesOnFailure, cfg.RetriesDuration)
+	}
+	r := retrier.New(backoff, classifier)
+	r.SetJitter(cfg.RetriesJitter)
+	return r
+}
+
+// retryClassifier - errors which can't be fixed by retry fail immediately, all other errors are retried
+type retryClassifier struct {
+	nonRetryableErrors []error
+	retryNotFound      bool
+}
+
+func (c retryClassifier) Classify(err error) retrier.Action {
+	if err == nil {
+		return retrier.Succeed
+	}
+	if (errors.Is(err, ErrNotFound) && !c.retryNotFound) || errors.Is(err, ErrObjectLocked) || errors.Is(err, context.Canceled) {
+		return retrier.Fail
+	}
+	for _, nonRetryableErr := range c.nonRetryableErrors {
+		if errors.Is(err, nonRetryableErr) {
+			return retrier.Fail
+		}
+	}
+	return retrier.Retry
+}
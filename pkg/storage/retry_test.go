@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRetrier(t *testing.T) {
+	cfg := &config.GeneralConfig{RetriesOnFailure: 3, RetriesDuration: time.Millisecond, RetriesBackoff: "exponential", RetriesMaxDuration: 2 * time.Millisecond, RetriesJitter: 0.1}
+	errTransient := errors.New("503 Service Unavailable")
+	errPermanent := errors.New("403 Forbidden")
+
+	attempts := 0
+	err := NewRetrier(cfg).RunCtx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return errTransient
+	})
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 4, attempts)
+
+	attempts = 0
+	err = NewRetrier(cfg).RunCtx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return fmt.Errorf("backup1/metadata.json: %w", ErrNotFound)
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, attempts)
+
+	// eventually consistent storage could not show just uploaded object
+	attempts = 0
+	err = NewUploadedStatRetrier(cfg).RunCtx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("backup1/shadow/db/t/default_1.tar: %w", ErrNotFound)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = NewRetrier(cfg, errPermanent).RunCtx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return errTransient
+		}
+		return errPermanent
+	})
+	assert.ErrorIs(t, err, errPermanent)
+	assert.Equal(t, 2, attempts)
+}
@@ -3,9 +3,11 @@ package storage
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"sort"
@@ -116,6 +118,59 @@ func (s *S3) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters)
 	return resolvedEndpoint, nil
 }
 
+func (s *S3) setAssumeRoleOptions(o *stscreds.AssumeRoleOptions) {
+	if s.Config.AssumeRoleSessionName != "" {
+		o.RoleSessionName = s.Config.AssumeRoleSessionName
+	}
+	if s.Config.AssumeRoleExternalID != "" {
+		o.ExternalID = aws.String(s.Config.AssumeRoleExternalID)
+	}
+}
+
+// getWebIdentity - s3->web_identity_role_arn and s3->web_identity_token_file have priority over AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE created by EKS pod identity webhook
+func (s *S3) getWebIdentity() (string, string) {
+	roleARN := s.Config.WebIdentityRoleARN
+	if roleARN == "" {
+		roleARN = os.Getenv("AWS_ROLE_ARN")
+	}
+	tokenFile := s.Config.WebIdentityTokenFile
+	if tokenFile == "" {
+		tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	return roleARN, tokenFile
+}
+
+// getTLSConfig - custom CA is appended to system CA pool, so one config could be used for internal PKI and AWS endpoints, return nil when default TLS settings are enough
+func (s *S3) getTLSConfig() (*tls.Config, error) {
+	if !s.Config.DisableCertVerification && s.Config.TLSCa == "" && s.Config.TLSCert == "" && s.Config.TLSKey == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.Config.DisableCertVerification}
+	if s.Config.TLSCert != "" || s.Config.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(s.Config.TLSCert, s.Config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("s3 tls.LoadX509KeyPair error: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if s.Config.TLSCa != "" {
+		caCert, err := os.ReadFile(s.Config.TLSCa)
+		if err != nil {
+			return nil, fmt.Errorf("read s3->tls_ca file %s return error: %v", s.Config.TLSCa, err)
+		}
+		caCertPool, err := x509.SystemCertPool()
+		if err != nil {
+			log.Warn().Msgf("can't load system CA pool: %v, only s3->tls_ca will be used", err)
+			caCertPool = x509.NewCertPool()
+		}
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("AppendCertsFromPEM %s return false", s.Config.TLSCa)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+	return tlsConfig, nil
+}
+
 // Connect - connect to s3
 func (s *S3) Connect(ctx context.Context) error {
 	var err error
@@ -130,21 +185,28 @@ func (s *S3) Connect(ctx context.Context) error {
 	if s.Config.Region != "" {
 		awsConfig.Region = s.Config.Region
 	}
+	// shall be applied before sts clients created, they use the same HTTPClient
+	httpTransport := http.DefaultTransport
+	tlsConfig, err := s.getTLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil || s.Config.Proxy != "" {
+		customTransport, err := newProxyHTTPTransport(s.Config.Proxy)
+		if err != nil {
+			return err
+		}
+		if tlsConfig != nil {
+			customTransport.TLSClientConfig = tlsConfig
+		}
+		httpTransport = customTransport
+		awsConfig.HTTPClient = &http.Client{Transport: httpTransport}
+	}
 	// AWS IRSA handling, look https://github.com/Altinity/clickhouse-backup/issues/798
-	awsRoleARN := os.Getenv("AWS_ROLE_ARN")
-	awsWebIdentityTokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
-	stsClient := sts.NewFromConfig(awsConfig)
-	if awsRoleARN != "" && awsWebIdentityTokenFile != "" {
-		awsConfig.Credentials = stscreds.NewWebIdentityRoleProvider(
-			stsClient, awsRoleARN, stscreds.IdentityTokenFile(awsWebIdentityTokenFile),
-		)
-	} else if s.Config.AssumeRoleARN != "" {
-		// backup role S3_ASSUME_ROLE_ARN have high priority than AWS_ROLE_ARN see https://github.com/Altinity/clickhouse-backup/issues/898
-		awsConfig.Credentials = stscreds.NewAssumeRoleProvider(stsClient, s.Config.AssumeRoleARN)
-	} else if awsRoleARN != "" {
-		awsConfig.Credentials = stscreds.NewAssumeRoleProvider(stsClient, awsRoleARN)
+	awsRoleARN, awsWebIdentityTokenFile := s.getWebIdentity()
+	if awsWebIdentityTokenFile != "" && awsRoleARN == "" {
+		return fmt.Errorf("web identity token file %s defined, but role ARN is empty, define s3->web_identity_role_arn or AWS_ROLE_ARN", awsWebIdentityTokenFile)
 	}
-
 	if s.Config.AccessKey != "" && s.Config.SecretKey != "" {
 		awsConfig.Credentials = credentials.StaticCredentialsProvider{
 			Value: aws.Credentials{
@@ -152,6 +214,22 @@ func (s *S3) Connect(ctx context.Context) error {
 				SecretAccessKey: s.Config.SecretKey,
 			},
 		}
+	} else if awsRoleARN != "" && awsWebIdentityTokenFile != "" {
+		// token file is read again on each credentials refresh, kubelet rotates it, so long uploads will not fail with expired token
+		awsConfig.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(awsConfig), awsRoleARN, stscreds.IdentityTokenFile(awsWebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+			},
+		))
+	} else if awsRoleARN != "" && s.Config.AssumeRoleARN == "" {
+		awsConfig.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsConfig), awsRoleARN))
+	}
+	// backup role S3_ASSUME_ROLE_ARN have high priority than AWS_ROLE_ARN see https://github.com/Altinity/clickhouse-backup/issues/898
+	// credentials above are used only to call sts:AssumeRole, so bucket could be placed in another AWS account
+	if s.Config.AssumeRoleARN != "" {
+		awsConfig.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(awsConfig), s.Config.AssumeRoleARN, s.setAssumeRoleOptions,
+		))
 	}
 
 	if s.Config.Debug {
@@ -159,14 +237,6 @@ func (s *S3) Connect(ctx context.Context) error {
 		awsConfig.ClientLogMode = aws.LogRetries | aws.LogRequest | aws.LogResponse
 	}
 
-	httpTransport := http.DefaultTransport
-	if s.Config.DisableCertVerification {
-		httpTransport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		awsConfig.HTTPClient = &http.Client{Transport: httpTransport}
-	}
-
 	// allow GCS over S3, remove Accept-Encoding header from sign https://stackoverflow.com/a/74382598/1204665, https://github.com/aws/aws-sdk-go-v2/issues/1816
 	if strings.Contains(s.Config.Endpoint, "storage.googleapis.com") {
 		// Assign custom client with our own transport
@@ -184,6 +254,9 @@ func (s *S3) Connect(ctx context.Context) error {
 	s.uploader.Concurrency = s.Concurrency
 	s.uploader.BufferProvider = s3manager.NewBufferedReadSeekerWriteToPool(s.BufferSize)
 	s.uploader.PartSize = s.PartSize
+	if s.Config.MaxPartsCount > 0 && s.Config.MaxPartsCount < int64(s3manager.MaxUploadParts) {
+		s.uploader.MaxUploadParts = int32(s.Config.MaxPartsCount)
+	}
 
 	s.downloader = s3manager.NewDownloader(s.client)
 	s.downloader.Concurrency = s.Concurrency
@@ -211,33 +284,32 @@ func (s *S3) GetFileReaderAbsolute(ctx context.Context, key string) (io.ReadClos
 	s.enrichGetObjectParams(params)
 	resp, err := s.client.GetObject(ctx, params)
 	if err != nil {
-		var opError *smithy.OperationError
-		if errors.As(err, &opError) {
-			var httpErr *awsV2http.ResponseError
-			if errors.As(opError.Err, &httpErr) {
-				var stateErr *s3types.InvalidObjectState
-				if errors.As(httpErr, &stateErr) {
-					if strings.Contains(string(stateErr.StorageClass), "GLACIER") {
-						log.Warn().Msgf("GetFileReader %s, storageClass %s receive error: %s", key, stateErr.StorageClass, stateErr.Error())
-						if restoreErr := s.restoreObject(ctx, key); restoreErr != nil {
-							log.Warn().Msgf("restoreObject %s, return error: %v", key, restoreErr)
-							return nil, err
-						}
-						if resp, err = s.client.GetObject(ctx, params); err != nil {
-							log.Warn().Msgf("second GetObject %s, return error: %v", key, err)
-							return nil, err
-						}
-						return resp.Body, nil
-					}
-				}
-			}
+		storageClass, isArchived := getS3ArchivedStorageClass(err)
+		if !isArchived {
+			return nil, err
+		}
+		log.Warn().Msgf("GetFileReader %s, storageClass %s receive error: %s", key, storageClass, err.Error())
+		if restoreErr := s.restoreObject(ctx, key, storageClass); restoreErr != nil {
+			log.Warn().Msgf("restoreObject %s, return error: %v", key, restoreErr)
+			return nil, restoreErr
+		}
+		if resp, err = s.client.GetObject(ctx, params); err != nil {
+			log.Warn().Msgf("second GetObject %s, return error: %v", key, err)
 			return nil, err
 		}
-		return nil, err
 	}
 	return resp.Body, nil
 }
 
+// getS3ArchivedStorageClass - GetObject return InvalidObjectState for GLACIER and DEEP_ARCHIVE objects without restored copy
+func getS3ArchivedStorageClass(err error) (s3types.StorageClass, bool) {
+	var stateErr *s3types.InvalidObjectState
+	if errors.As(err, &stateErr) && (stateErr.StorageClass == s3types.StorageClassGlacier || stateErr.StorageClass == s3types.StorageClassDeepArchive) {
+		return stateErr.StorageClass, true
+	}
+	return "", false
+}
+
 func (s *S3) enrichGetObjectParams(params *s3.GetObjectInput) {
 	if s.Config.SSECustomerAlgorithm != "" {
 		params.SSECustomerAlgorithm = aws.String(s.Config.SSECustomerAlgorithm)
@@ -261,10 +333,16 @@ func (s *S3) GetFileReaderWithLocalPath(ctx context.Context, key, localPath stri
 		if err != nil {
 			return nil, err
 		}
-		_, err = s.downloader.Download(ctx, writer, &s3.GetObjectInput{
+		params := &s3.GetObjectInput{
 			Bucket: aws.String(s.Config.Bucket),
 			Key:    aws.String(path.Join(s.Config.Path, key)),
-		})
+		}
+		_, err = s.downloader.Download(ctx, writer, params)
+		if storageClass, isArchived := getS3ArchivedStorageClass(err); isArchived {
+			if err = s.restoreObject(ctx, *params.Key, storageClass); err == nil {
+				_, err = s.downloader.Download(ctx, writer, params)
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -278,16 +356,42 @@ func (s *S3) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
 	return s.PutFileAbsolute(ctx, path.Join(s.Config.Path, key), r)
 }
 
+// getS3StorageClass - objects in GLACIER and DEEP_ARCHIVE can't be read without RestoreObject, so `*.json` metadata keeps STANDARD, to allow `list remote`, retention and `download` start without waiting restore
+func getS3StorageClass(storageClass, key string) s3types.StorageClass {
+	s3StorageClass := s3types.StorageClass(strings.ToUpper(storageClass))
+	if (s3StorageClass == s3types.StorageClassGlacier || s3StorageClass == s3types.StorageClassDeepArchive) && strings.HasSuffix(key, ".json") {
+		return s3types.StorageClassStandard
+	}
+	return s3StorageClass
+}
+
+// getS3Tagging - tags shall be URL query encoded, values could contain spaces, `&` and `=`
+func getS3Tagging(objectLabels map[string]string) *string {
+	tags := url.Values{}
+	for k, v := range objectLabels {
+		tags.Set(k, v)
+	}
+	return aws.String(tags.Encode())
+}
+
 func (s *S3) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error {
 	params := s3.PutObjectInput{
 		Bucket:       aws.String(s.Config.Bucket),
 		Key:          aws.String(key),
 		Body:         r,
-		StorageClass: s3types.StorageClass(strings.ToUpper(s.Config.StorageClass)),
+		StorageClass: getS3StorageClass(s.Config.StorageClass, key),
 	}
 	if s.Config.CheckSumAlgorithm != "" {
 		params.ChecksumAlgorithm = s3types.ChecksumAlgorithm(s.Config.CheckSumAlgorithm)
 	}
+	if s.Config.ObjectLockMode != "" {
+		params.ObjectLockMode = s3types.ObjectLockMode(s.Config.ObjectLockMode)
+		params.ObjectLockRetainUntilDate = s.getObjectLockRetainUntilDate()
+		// PutObject with object lock require checksum header
+		if params.ChecksumAlgorithm == "" {
+			params.ChecksumAlgorithm = s3types.ChecksumAlgorithmCrc32
+		}
+	}
 
 	// ACL shall be optional, fix https://github.com/Altinity/clickhouse-backup/issues/785
 	if s.Config.ACL != "" {
@@ -295,14 +399,10 @@ func (s *S3) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) e
 	}
 	// https://github.com/Altinity/clickhouse-backup/issues/588
 	if len(s.Config.ObjectLabels) > 0 {
-		tags := ""
-		for k, v := range s.Config.ObjectLabels {
-			if tags != "" {
-				tags += "&"
-			}
-			tags += k + "=" + v
-		}
-		params.Tagging = aws.String(tags)
+		params.Tagging = getS3Tagging(s.Config.ObjectLabels)
+	}
+	if len(s.Config.ObjectMetadata) > 0 {
+		params.Metadata = s.Config.ObjectMetadata
 	}
 	if s.Config.SSE != "" {
 		params.ServerSideEncryption = s3types.ServerSideEncryption(s.Config.SSE)
@@ -322,10 +422,28 @@ func (s *S3) PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) e
 	if s.Config.SSEKMSEncryptionContext != "" {
 		params.SSEKMSEncryptionContext = aws.String(s.Config.SSEKMSEncryptionContext)
 	}
-	_, err := s.uploader.Upload(ctx, &params)
+	var uploadOptions []func(*s3manager.Uploader)
+	// stream size is unknown for s3manager, so part size shall fit into max_parts_count before upload
+	if sizedReader, ok := r.(interface{ ExpectedSize() int64 }); ok {
+		if partSize := getS3PartSize(s.PartSize, sizedReader.ExpectedSize(), s.Config.MaxPartsCount); partSize != s.PartSize {
+			log.Debug().Msgf("%s expected size %d, increase part size %d -> %d", key, sizedReader.ExpectedSize(), s.PartSize, partSize)
+			uploadOptions = append(uploadOptions, func(u *s3manager.Uploader) {
+				u.PartSize = partSize
+			})
+		}
+	}
+	_, err := s.uploader.Upload(ctx, &params, uploadOptions...)
 	return err
 }
 
+// getS3PartSize - increase part size when expectedSize doesn't fit into maxPartsCount parts, 0 expectedSize means unknown size
+func getS3PartSize(partSize, expectedSize, maxPartsCount int64) int64 {
+	if expectedSize <= 0 || maxPartsCount <= 0 || partSize <= 0 || expectedSize/partSize < maxPartsCount {
+		return partSize
+	}
+	return AdjustS3PartSize(expectedSize/maxPartsCount+1, partSize)
+}
+
 func (s *S3) deleteKey(ctx context.Context, key string) error {
 	params := &s3.DeleteObjectInput{
 		Bucket: aws.String(s.Config.Bucket),
@@ -342,6 +460,9 @@ func (s *S3) deleteKey(ctx context.Context, key string) error {
 		for _, objVersion := range objVersions {
 			params.VersionId = &objVersion
 			if _, err := s.client.DeleteObject(ctx, params); err != nil {
+				if retainUntil, isLocked := s.isObjectVersionLocked(ctx, key, objVersion, err); isLocked {
+					return errors.Wrapf(ErrObjectLocked, "deleteKey, bucket: %s key: %s version: %s retain until %s", s.Config.Bucket, key, objVersion, retainUntil.Format(time.RFC3339))
+				}
 				return errors.Wrapf(err, "deleteKey, deleting object bucket: %s key: %s version: %v", s.Config.Bucket, key, params.VersionId)
 			}
 		}
@@ -355,6 +476,48 @@ func (s *S3) deleteKey(ctx context.Context, key string) error {
 	return nil
 }
 
+// getObjectLockRetainUntilDate - each object is locked for s3->object_lock_days since upload
+func (s *S3) getObjectLockRetainUntilDate() *time.Time {
+	return aws.Time(time.Now().UTC().AddDate(0, 0, s.Config.ObjectLockDays))
+}
+
+// isObjectVersionLocked - DeleteObject for locked version return AccessDenied, so retention is checked to report lock instead of permissions issue
+func (s *S3) isObjectVersionLocked(ctx context.Context, key, versionId string, deleteErr error) (time.Time, bool) {
+	var apiErr smithy.APIError
+	if !errors.As(deleteErr, &apiErr) || apiErr.ErrorCode() != "AccessDenied" {
+		return time.Time{}, false
+	}
+	retainUntil, err := s.getObjectRetainUntil(ctx, key, &versionId)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return retainUntil, retainUntil.After(time.Now())
+}
+
+// GetObjectLockRetainUntil - implements ObjectLockStorage, zero time means object is not locked
+func (s *S3) GetObjectLockRetainUntil(ctx context.Context, key string) (time.Time, error) {
+	return s.getObjectRetainUntil(ctx, path.Join(s.Config.Path, key), nil)
+}
+
+func (s *S3) getObjectRetainUntil(ctx context.Context, key string, versionId *string) (time.Time, error) {
+	params := &s3.GetObjectRetentionInput{
+		Bucket:    aws.String(s.Config.Bucket),
+		Key:       aws.String(key),
+		VersionId: versionId,
+	}
+	if s.Config.RequestPayer != "" {
+		params.RequestPayer = s3types.RequestPayer(s.Config.RequestPayer)
+	}
+	res, err := s.client.GetObjectRetention(ctx, params)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if res.Retention == nil || res.Retention.RetainUntilDate == nil {
+		return time.Time{}, nil
+	}
+	return *res.Retention.RetainUntilDate, nil
+}
+
 func (s *S3) DeleteFile(ctx context.Context, key string) error {
 	key = path.Join(s.Config.Path, key)
 	return s.deleteKey(ctx, key)
@@ -486,7 +649,15 @@ func (s *S3) remotePager(ctx context.Context, s3Path string, recursive bool, pro
 }
 
 func (s *S3) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
-	dstKey = path.Join(s.Config.ObjectDiskPath, dstKey)
+	return s.copyObject(ctx, srcSize, srcBucket, srcKey, path.Join(s.Config.ObjectDiskPath, dstKey))
+}
+
+// CopyBackupObject - implements BackupCopyStorage, used by `copy` command
+func (s *S3) CopyBackupObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
+	return s.copyObject(ctx, srcSize, srcBucket, srcKey, path.Join(s.Config.Path, dstKey))
+}
+
+func (s *S3) copyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error) {
 	log.Debug().Msgf("S3->CopyObject %s/%s -> %s/%s", srcBucket, srcKey, s.Config.Bucket, dstKey)
 	// just copy object without multipart
 	if srcSize < 5*1024*1024*1024 || strings.Contains(s.Config.Endpoint, "storage.googleapis.com") {
@@ -614,19 +785,19 @@ func (s *S3) enrichCreateMultipartUploadParams(params *s3.CreateMultipartUploadI
 	if s.Config.CheckSumAlgorithm != "" {
 		params.ChecksumAlgorithm = s3types.ChecksumAlgorithm(s.Config.CheckSumAlgorithm)
 	}
+	if s.Config.ObjectLockMode != "" {
+		params.ObjectLockMode = s3types.ObjectLockMode(s.Config.ObjectLockMode)
+		params.ObjectLockRetainUntilDate = s.getObjectLockRetainUntilDate()
+	}
 	if s.Config.RequestPayer != "" {
 		params.RequestPayer = s3types.RequestPayer(s.Config.RequestPayer)
 	}
 	// https://github.com/Altinity/clickhouse-backup/issues/588
 	if len(s.Config.ObjectLabels) > 0 {
-		tags := ""
-		for k, v := range s.Config.ObjectLabels {
-			if tags != "" {
-				tags += "&"
-			}
-			tags += k + "=" + v
-		}
-		params.Tagging = aws.String(tags)
+		params.Tagging = getS3Tagging(s.Config.ObjectLabels)
+	}
+	if len(s.Config.ObjectMetadata) > 0 {
+		params.Metadata = s.Config.ObjectMetadata
 	}
 	if s.Config.SSE != "" {
 		params.ServerSideEncryption = s3types.ServerSideEncryption(s.Config.SSE)
@@ -652,16 +823,19 @@ func (s *S3) enrichCopyObjectParams(params *s3.CopyObjectInput) {
 	if s.Config.CheckSumAlgorithm != "" {
 		params.ChecksumAlgorithm = s3types.ChecksumAlgorithm(s.Config.CheckSumAlgorithm)
 	}
+	if s.Config.ObjectLockMode != "" {
+		params.ObjectLockMode = s3types.ObjectLockMode(s.Config.ObjectLockMode)
+		params.ObjectLockRetainUntilDate = s.getObjectLockRetainUntilDate()
+	}
 	// https://github.com/Altinity/clickhouse-backup/issues/588
+	// source object tags and metadata are copied by default, configured values shall replace them
 	if len(s.Config.ObjectLabels) > 0 {
-		tags := ""
-		for k, v := range s.Config.ObjectLabels {
-			if tags != "" {
-				tags += "&"
-			}
-			tags += k + "=" + v
-		}
-		params.Tagging = aws.String(tags)
+		params.Tagging = getS3Tagging(s.Config.ObjectLabels)
+		params.TaggingDirective = s3types.TaggingDirectiveReplace
+	}
+	if len(s.Config.ObjectMetadata) > 0 {
+		params.Metadata = s.Config.ObjectMetadata
+		params.MetadataDirective = s3types.MetadataDirectiveReplace
 	}
 	if s.Config.SSE != "" {
 		params.ServerSideEncryption = s3types.ServerSideEncryption(s.Config.SSE)
@@ -686,44 +860,103 @@ func (s *S3) enrichCopyObjectParams(params *s3.CopyObjectInput) {
 	}
 }
 
-func (s *S3) restoreObject(ctx context.Context, key string) error {
+// getS3RestoreTier - Expedited is not supported for DEEP_ARCHIVE, so empty s3->restore_tier means Expedited for GLACIER and Standard for DEEP_ARCHIVE
+func getS3RestoreTier(restoreTier string, storageClass s3types.StorageClass) s3types.Tier {
+	for _, tier := range []s3types.Tier{s3types.TierExpedited, s3types.TierStandard, s3types.TierBulk} {
+		if strings.EqualFold(restoreTier, string(tier)) {
+			if tier == s3types.TierExpedited && storageClass == s3types.StorageClassDeepArchive {
+				log.Warn().Msgf("s3->restore_tier: %s is not supported for %s, %s will use", restoreTier, storageClass, s3types.TierStandard)
+				return s3types.TierStandard
+			}
+			return tier
+		}
+	}
+	if storageClass == s3types.StorageClassDeepArchive {
+		return s3types.TierStandard
+	}
+	return s3types.TierExpedited
+}
+
+// restoreObject - request temporary copy of archived object for s3->restore_days and wait s3->restore_max_wait until copy is available, key is absolute
+func (s *S3) restoreObject(ctx context.Context, key string, storageClass s3types.StorageClass) error {
+	if err := s.requestRestoreObject(ctx, key, storageClass); err != nil {
+		return err
+	}
+	return waitArchiveRestore(ctx, key, s.Config.RestoreMaxWaitDuration, func(ctx context.Context) (bool, error) {
+		return s.isObjectRestored(ctx, key)
+	})
+}
+
+// RestoreArchivedObjects - request restore of all GLACIER and DEEP_ARCHIVE objects of backups at once and wait s3->restore_max_wait until all copies are available, so restore time is spent once per backup instead of once per object
+func (s *S3) RestoreArchivedObjects(ctx context.Context, backupNames ...string) error {
+	archivedObjects := map[string]s3types.StorageClass{}
+	for _, backupName := range backupNames {
+		prefix := path.Join(s.Config.Path, backupName)
+		if err := s.WalkAbsolute(ctx, prefix, true, func(ctx context.Context, f RemoteFile) error {
+			if storageClass := s3types.StorageClass(f.(*s3File).StorageClass()); storageClass == s3types.StorageClassGlacier || storageClass == s3types.StorageClassDeepArchive {
+				archivedObjects[path.Join(prefix, f.Name())] = storageClass
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(archivedObjects) == 0 {
+		return nil
+	}
+	description := fmt.Sprintf("%d objects of %s", len(archivedObjects), strings.Join(backupNames, ", "))
+	log.Info().Msgf("request restore of %s", description)
+	pendingKeys := make([]string, 0, len(archivedObjects))
+	for key, storageClass := range archivedObjects {
+		if err := s.requestRestoreObject(ctx, key, storageClass); err != nil {
+			return err
+		}
+		pendingKeys = append(pendingKeys, key)
+	}
+	return waitArchiveRestore(ctx, description, s.Config.RestoreMaxWaitDuration, func(ctx context.Context) (bool, error) {
+		var err error
+		pendingKeys, err = filterNotRestored(ctx, pendingKeys, s.isObjectRestored)
+		return len(pendingKeys) == 0, err
+	})
+}
+
+// requestRestoreObject - request temporary copy of archived object for s3->restore_days, key is absolute
+func (s *S3) requestRestoreObject(ctx context.Context, key string, storageClass s3types.StorageClass) error {
 	restoreRequest := &s3.RestoreObjectInput{
 		Bucket: aws.String(s.Config.Bucket),
-		Key:    aws.String(path.Join(s.Config.Path, key)),
+		Key:    aws.String(key),
 		RestoreRequest: &s3types.RestoreRequest{
-			Days: aws.Int32(1),
+			Days: aws.Int32(s.Config.RestoreDays),
 			GlacierJobParameters: &s3types.GlacierJobParameters{
-				Tier: s3types.Tier("Expedited"),
+				Tier: getS3RestoreTier(s.Config.RestoreTier, storageClass),
 			},
 		},
 	}
 	if s.Config.RequestPayer != "" {
 		restoreRequest.RequestPayer = s3types.RequestPayer(s.Config.RequestPayer)
 	}
-	_, err := s.client.RestoreObject(ctx, restoreRequest)
-	if err != nil {
-		return err
-	}
-	i := 0
-	for {
-		restoreHeadParams := &s3.HeadObjectInput{
-			Bucket: aws.String(s.Config.Bucket),
-			Key:    aws.String(path.Join(s.Config.Path, key)),
-		}
-		s.enrichHeadParams(restoreHeadParams)
-		res, err := s.client.HeadObject(ctx, restoreHeadParams)
-		if err != nil {
-			return fmt.Errorf("restoreObject: failed to head %s object metadata, %v", path.Join(s.Config.Path, key), err)
+	if _, err := s.client.RestoreObject(ctx, restoreRequest); err != nil {
+		// parallel download of the same backup or previous attempt could already request restore
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "RestoreAlreadyInProgress" {
+			return err
 		}
+	}
+	return nil
+}
 
-		if res.Restore != nil && *res.Restore == "ongoing-request=\"true\"" {
-			i += 1
-			log.Warn().Msgf("%s still not restored, will wait %d seconds", key, i*5)
-			time.Sleep(time.Duration(i*5) * time.Second)
-		} else {
-			return nil
-		}
+// isObjectRestored - temporary copy of archived object is available, key is absolute
+func (s *S3) isObjectRestored(ctx context.Context, key string) (bool, error) {
+	restoreHeadParams := &s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(key),
+	}
+	s.enrichHeadParams(restoreHeadParams)
+	res, err := s.client.HeadObject(ctx, restoreHeadParams)
+	if err != nil {
+		return false, fmt.Errorf("restoreObject: failed to head %s object metadata, %v", key, err)
 	}
+	return res.Restore != nil && strings.Contains(*res.Restore, "ongoing-request=\"false\""), nil
 }
 
 func (s *S3) enrichHeadParams(headParams *s3.HeadObjectInput) {
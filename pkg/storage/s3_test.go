@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetS3StorageClass(t *testing.T) {
+	assert.Equal(t, s3types.StorageClassDeepArchive, getS3StorageClass("deep_archive", "backup/shadow/db/t/default_1.tar"))
+	assert.Equal(t, s3types.StorageClassStandard, getS3StorageClass("DEEP_ARCHIVE", "backup/metadata.json"))
+	assert.Equal(t, s3types.StorageClassStandard, getS3StorageClass("GLACIER", "backup/metadata/db/t.json"))
+	assert.Equal(t, s3types.StorageClassGlacierIr, getS3StorageClass("GLACIER_IR", "backup/metadata.json"))
+	assert.Equal(t, s3types.StorageClassStandardIa, getS3StorageClass("STANDARD_IA", "backup/metadata.json"))
+}
+
+func TestGetS3RestoreTier(t *testing.T) {
+	assert.Equal(t, s3types.TierExpedited, getS3RestoreTier("", s3types.StorageClassGlacier))
+	assert.Equal(t, s3types.TierStandard, getS3RestoreTier("", s3types.StorageClassDeepArchive))
+	assert.Equal(t, s3types.TierBulk, getS3RestoreTier("bulk", s3types.StorageClassGlacier))
+	assert.Equal(t, s3types.TierStandard, getS3RestoreTier("Expedited", s3types.StorageClassDeepArchive))
+}
+
+func TestSetAssumeRoleOptions(t *testing.T) {
+	s := &S3{Config: &config.S3Config{AssumeRoleSessionName: "backup", AssumeRoleExternalID: "external-id"}}
+	o := &stscreds.AssumeRoleOptions{}
+	s.setAssumeRoleOptions(o)
+	assert.Equal(t, "backup", o.RoleSessionName)
+	assert.Equal(t, "external-id", *o.ExternalID)
+
+	s = &S3{Config: &config.S3Config{}}
+	o = &stscreds.AssumeRoleOptions{}
+	s.setAssumeRoleOptions(o)
+	assert.Empty(t, o.RoleSessionName)
+	assert.Nil(t, o.ExternalID)
+}
+
+func TestGetWebIdentity(t *testing.T) {
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/env")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/secrets/eks.amazonaws.com/serviceaccount/token")
+	s := &S3{Config: &config.S3Config{}}
+	roleARN, tokenFile := s.getWebIdentity()
+	assert.Equal(t, "arn:aws:iam::123456789012:role/env", roleARN)
+	assert.Equal(t, "/var/run/secrets/eks.amazonaws.com/serviceaccount/token", tokenFile)
+
+	s = &S3{Config: &config.S3Config{WebIdentityRoleARN: "arn:aws:iam::123456789012:role/config", WebIdentityTokenFile: "/tmp/token"}}
+	roleARN, tokenFile = s.getWebIdentity()
+	assert.Equal(t, "arn:aws:iam::123456789012:role/config", roleARN)
+	assert.Equal(t, "/tmp/token", tokenFile)
+}
+
+func TestGetTLSConfig(t *testing.T) {
+	s := &S3{Config: &config.S3Config{}}
+	tlsConfig, err := s.getTLSConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	s = &S3{Config: &config.S3Config{DisableCertVerification: true}}
+	tlsConfig, err = s.getTLSConfig()
+	assert.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	s = &S3{Config: &config.S3Config{TLSCa: path.Join(t.TempDir(), "not-exists.pem")}}
+	_, err = s.getTLSConfig()
+	assert.Error(t, err)
+
+	badCa := path.Join(t.TempDir(), "bad-ca.pem")
+	assert.NoError(t, os.WriteFile(badCa, []byte("not a certificate"), 0644))
+	s = &S3{Config: &config.S3Config{TLSCa: badCa}}
+	_, err = s.getTLSConfig()
+	assert.Error(t, err)
+}
+
+func TestGetS3PartSize(t *testing.T) {
+	const mb = int64(1024 * 1024)
+	assert.Equal(t, 5*mb, getS3PartSize(5*mb, 0, 4000))
+	assert.Equal(t, 5*mb, getS3PartSize(5*mb, 1024*mb, 4000))
+	// 6TB archive with 4000 parts requires ~1.5GB parts
+	assert.Equal(t, 6*1024*1024*mb/4000+1, getS3PartSize(5*mb, 6*1024*1024*mb, 4000))
+	assert.Equal(t, 5*1024*mb, getS3PartSize(5*mb, 60*1024*1024*mb, 4000))
+}
+
+func TestGetS3Tagging(t *testing.T) {
+	assert.Equal(t, "backup-name=backup+1&retention-class=a%26b%3Dc", *getS3Tagging(map[string]string{"retention-class": "a&b=c", "backup-name": "backup 1"}))
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"io"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -20,9 +23,18 @@ import (
 
 // SFTP Implement RemoteStorage
 type SFTP struct {
+	connections    []*sftpConnection
+	nextConnection atomic.Uint64
+	sshConfig      *ssh.ClientConfig
+	clientOptions  []libSFTP.ClientOption
+	Config         *config.SFTPConfig
+}
+
+// sftpConnection - one SSH connection with SFTP session, all requests inside one SSH connection share one TCP stream, so parallel uploads require separate connections
+type sftpConnection struct {
+	mutex      sync.Mutex
 	sshClient  *ssh.Client
 	sftpClient *libSFTP.Client
-	Config     *config.SFTPConfig
 }
 
 func (sftp *SFTP) Debug(msg string, v ...interface{}) {
@@ -59,50 +71,123 @@ func (sftp *SFTP) Connect(ctx context.Context) error {
 		authMethods = append(authMethods, ssh.Password(sftp.Config.Password))
 	}
 
-	sftpConfig := &ssh.ClientConfig{
+	sftp.sshConfig = &ssh.ClientConfig{
 		User:            sftp.Config.Username,
 		Auth:            authMethods,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
-	addr := fmt.Sprintf("%s:%d", sftp.Config.Address, sftp.Config.Port)
-	sftp.Debug("[SFTP_DEBUG] try connect to tcp://%s", addr)
-	sshConnection, err := ssh.Dial("tcp", addr, sftpConfig)
-	if err != nil {
-		return err
-	}
-	clientOptions := make([]libSFTP.ClientOption, 0)
+	sftp.clientOptions = make([]libSFTP.ClientOption, 0)
 	if sftp.Config.Concurrency > 0 {
-		clientOptions = append(
-			clientOptions,
+		sftp.clientOptions = append(
+			sftp.clientOptions,
 			libSFTP.UseConcurrentReads(true),
 			libSFTP.UseConcurrentWrites(true),
 			libSFTP.MaxConcurrentRequestsPerFile(sftp.Config.Concurrency),
 		)
 	}
-	sftpConnection, err := libSFTP.NewClient(sshConnection, clientOptions...)
+	poolSize := max(sftp.Config.ConnectionPoolSize, 1)
+	sftp.connections = make([]*sftpConnection, poolSize)
+	for i := range sftp.connections {
+		sftp.connections[i] = &sftpConnection{}
+	}
+	// first connection is established immediately to fail fast on wrong address or credentials, other connections are established on demand
+	_, err := sftp.connections[0].connect(ctx, sftp)
+	return err
+}
+
+// connect - return SFTP client of connection, client is read under the lock to avoid race with Close, new SSH connection is dialed when it is absent or broken
+// TCP dial and SSH handshake are interrupted when ctx is done, to avoid hung on unresponsive server
+func (c *sftpConnection) connect(ctx context.Context, sftp *SFTP) (*libSFTP.Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sftpClient != nil {
+		return c.sftpClient, nil
+	}
+	addr := fmt.Sprintf("%s:%d", sftp.Config.Address, sftp.Config.Port)
+	sftp.Debug("[SFTP_DEBUG] try connect to tcp://%s", addr)
+	dialer := net.Dialer{}
+	tcpConnection, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	stopHandshake := context.AfterFunc(ctx, func() {
+		_ = tcpConnection.Close()
+	})
+	sshConn, sshChannels, sshRequests, err := ssh.NewClientConn(tcpConnection, addr, sftp.sshConfig)
+	if !stopHandshake() {
+		if err == nil {
+			_ = sshConn.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake with %s interrupted: %v", addr, context.Cause(ctx))
+	}
+	if err != nil {
+		_ = tcpConnection.Close()
+		return nil, err
+	}
+	sshConnection := ssh.NewClient(sshConn, sshChannels, sshRequests)
+	sftpConnection, err := libSFTP.NewClient(sshConnection, sftp.clientOptions...)
+	if err != nil {
+		if closeErr := sshConnection.Close(); closeErr != nil {
+			log.Warn().Msgf("sshClient.Close() error: %v", closeErr)
+		}
+		return nil, err
+	}
+	c.sftpClient = sftpConnection
+	c.sshClient = sshConnection
+	go c.resetOnShutdown(sftpConnection)
+	return sftpConnection, nil
+}
 
-	sftp.sftpClient = sftpConnection
-	sftp.sshClient = sshConnection
-	return nil
+// resetOnShutdown - SFTP session is finished when SSH transport fails or server closes connection, forget dead client, so next getClient dial new connection instead of return it
+func (c *sftpConnection) resetOnShutdown(client *libSFTP.Client) {
+	err := client.Wait()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// client already closed by Close
+	if c.sftpClient != client {
+		return
+	}
+	log.Warn().Msgf("sftp connection closed: %v, will reconnect on next request", err)
+	// transport is already closed, close only release SSH client resources
+	_ = c.sshClient.Close()
+	c.sftpClient = nil
+	c.sshClient = nil
+}
+
+// getClient - round-robin over connections, libSFTP.Client is safe for concurrent use, so connection is not locked during request
+func (sftp *SFTP) getClient(ctx context.Context) (*libSFTP.Client, error) {
+	c := sftp.connections[(sftp.nextConnection.Add(1)-1)%uint64(len(sftp.connections))]
+	return c.connect(ctx, sftp)
 }
 
 func (sftp *SFTP) Close(ctx context.Context) error {
-	if err := sftp.sftpClient.Close(); err != nil {
-		return fmt.Errorf("sftpClient.Close() error: , %v", err)
-	}
-	if err := sftp.sshClient.Close(); err != nil {
-		return fmt.Errorf("sshClient.Close() error: , %v", err)
+	for _, c := range sftp.connections {
+		c.mutex.Lock()
+		if c.sftpClient != nil {
+			if err := c.sftpClient.Close(); err != nil {
+				c.mutex.Unlock()
+				return fmt.Errorf("sftpClient.Close() error: , %v", err)
+			}
+			if err := c.sshClient.Close(); err != nil {
+				c.mutex.Unlock()
+				return fmt.Errorf("sshClient.Close() error: , %v", err)
+			}
+			c.sftpClient = nil
+			c.sshClient = nil
+		}
+		c.mutex.Unlock()
 	}
 	return nil
 }
 
 func (sftp *SFTP) StatFile(ctx context.Context, key string) (RemoteFile, error) {
 	filePath := path.Join(sftp.Config.Path, key)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	stat, err := sftp.sftpClient.Stat(filePath)
+	stat, err := client.Stat(filePath)
 	if err != nil {
 		sftp.Debug("[SFTP_DEBUG] StatFile::STAT %s return error %v", filePath, err)
 		if strings.Contains(err.Error(), "not exist") {
@@ -121,8 +206,12 @@ func (sftp *SFTP) StatFile(ctx context.Context, key string) (RemoteFile, error)
 func (sftp *SFTP) DeleteFile(ctx context.Context, key string) error {
 	sftp.Debug("[SFTP_DEBUG] Delete %s", key)
 	filePath := path.Join(sftp.Config.Path, key)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return err
+	}
 
-	fileStat, err := sftp.sftpClient.Stat(filePath)
+	fileStat, err := client.Stat(filePath)
 	if err != nil {
 		sftp.Debug("[SFTP_DEBUG] Delete::STAT %s return error %v", filePath, err)
 		return err
@@ -130,19 +219,23 @@ func (sftp *SFTP) DeleteFile(ctx context.Context, key string) error {
 	if fileStat.IsDir() {
 		return sftp.DeleteDirectory(ctx, filePath)
 	} else {
-		return sftp.sftpClient.Remove(filePath)
+		return client.Remove(filePath)
 	}
 }
 
 func (sftp *SFTP) DeleteDirectory(ctx context.Context, dirPath string) error {
 	sftp.Debug("[SFTP_DEBUG] DeleteDirectory %s", dirPath)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return err
+	}
 	defer func() {
-		if err := sftp.sftpClient.RemoveDirectory(dirPath); err != nil {
+		if err := client.RemoveDirectory(dirPath); err != nil {
 			log.Warn().Msgf("RemoveDirectory err=%v", err)
 		}
 	}()
 
-	files, err := sftp.sftpClient.ReadDir(dirPath)
+	files, err := client.ReadDir(dirPath)
 	if err != nil {
 		sftp.Debug("[SFTP_DEBUG] DeleteDirectory::ReadDir %s return error %v", dirPath, err)
 		return err
@@ -154,7 +247,7 @@ func (sftp *SFTP) DeleteDirectory(ctx context.Context, dirPath string) error {
 				log.Warn().Msgf("sftp.DeleteDirectory(%s) err=%v", filePath, err)
 			}
 		} else {
-			if err := sftp.sftpClient.Remove(filePath); err != nil {
+			if err := client.Remove(filePath); err != nil {
 				log.Warn().Msgf("sftp.Remove(%s) err=%v", filePath, err)
 			}
 		}
@@ -170,10 +263,17 @@ func (sftp *SFTP) Walk(ctx context.Context, remotePath string, recursive bool, p
 
 func (sftp *SFTP) WalkAbsolute(ctx context.Context, prefix string, recursive bool, process func(context.Context, RemoteFile) error) error {
 	sftp.Debug("[SFTP_DEBUG] Walk %s, recursive=%v", prefix, recursive)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return err
+	}
 
 	if recursive {
-		walker := sftp.sftpClient.Walk(prefix)
+		walker := client.Walk(prefix)
 		for walker.Step() {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if err := walker.Err(); err != nil {
 				return err
 			}
@@ -192,7 +292,7 @@ func (sftp *SFTP) WalkAbsolute(ctx context.Context, prefix string, recursive boo
 			}
 		}
 	} else {
-		entries, err := sftp.sftpClient.ReadDir(prefix)
+		entries, err := client.ReadDir(prefix)
 		if err != nil {
 			sftp.Debug("[SFTP_DEBUG] Walk::NonRecursive::ReadDir %s return error %v", prefix, err)
 			return err
@@ -216,7 +316,11 @@ func (sftp *SFTP) GetFileReader(ctx context.Context, key string) (io.ReadCloser,
 }
 
 func (sftp *SFTP) GetFileReaderAbsolute(ctx context.Context, key string) (io.ReadCloser, error) {
-	return sftp.sftpClient.OpenFile(key, syscall.O_RDWR)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.OpenFile(key, syscall.O_RDWR)
 }
 
 func (sftp *SFTP) GetFileReaderWithLocalPath(ctx context.Context, key, _ string) (io.ReadCloser, error) {
@@ -228,19 +332,32 @@ func (sftp *SFTP) PutFile(ctx context.Context, key string, localFile io.ReadClos
 }
 
 func (sftp *SFTP) PutFileAbsolute(ctx context.Context, key string, localFile io.ReadCloser) error {
-	if err := sftp.sftpClient.MkdirAll(path.Dir(key)); err != nil {
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.MkdirAll(path.Dir(key)); err != nil {
 		log.Warn().Msgf("sftp.sftpClient.MkdirAll(%s) err=%v", path.Dir(key), err)
 	}
-	remoteFile, err := sftp.sftpClient.Create(key)
+	remoteFile, err := client.Create(key)
 	if err != nil {
 		return err
 	}
+	// closing remote file interrupts hung ReadFrom when ctx is done, for example after general->remote_upload_timeout
+	stopInterrupt := context.AfterFunc(ctx, func() {
+		_ = remoteFile.Close()
+	})
 	defer func() {
-		if err := remoteFile.Close(); err != nil {
-			log.Warn().Msgf("can't close %s err=%v", key, err)
+		if stopInterrupt() {
+			if err := remoteFile.Close(); err != nil {
+				log.Warn().Msgf("can't close %s err=%v", key, err)
+			}
 		}
 	}()
 	if _, err = remoteFile.ReadFrom(localFile); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("upload %s interrupted: %v, error: %v", key, context.Cause(ctx), err)
+		}
 		return err
 	}
 	return nil
@@ -253,8 +370,12 @@ func (sftp *SFTP) CopyObject(ctx context.Context, srcSize int64, srcBucket, srcK
 func (sftp *SFTP) DeleteFileFromObjectDiskBackup(ctx context.Context, key string) error {
 	sftp.Debug("[SFTP_DEBUG] DeleteFileFromObjectDiskBackup %s", key)
 	filePath := path.Join(sftp.Config.ObjectDiskPath, key)
+	client, err := sftp.getClient(ctx)
+	if err != nil {
+		return err
+	}
 
-	fileStat, err := sftp.sftpClient.Stat(filePath)
+	fileStat, err := client.Stat(filePath)
 	if err != nil {
 		sftp.Debug("[SFTP_DEBUG] DeleteFileFromObjectDiskBackup::STAT %s return error %v", filePath, err)
 		return err
@@ -262,7 +383,7 @@ func (sftp *SFTP) DeleteFileFromObjectDiskBackup(ctx context.Context, key string
 	if fileStat.IsDir() {
 		return sftp.DeleteDirectory(ctx, filePath)
 	} else {
-		return sftp.sftpClient.Remove(filePath)
+		return client.Remove(filePath)
 	}
 }
 
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	libSFTP "github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSFTPServer - in-process SSH server with sftp subsystem, allow break all established connections
+type fakeSFTPServer struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	connections []net.Conn
+}
+
+func startFakeSFTPServer(t *testing.T) *fakeSFTPServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	assert.NoError(t, err)
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &fakeSFTPServer{listener: listener}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.breakConnections()
+	})
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.connections = append(s.connections, conn)
+			s.mutex.Unlock()
+			go s.serve(conn, serverConfig)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSFTPServer) serve(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, acceptErr := newChannel.Accept()
+		if acceptErr != nil {
+			return
+		}
+		go func() {
+			for req := range channelRequests {
+				_ = req.Reply(req.Type == "subsystem", nil)
+				if req.Type == "subsystem" {
+					if server, serverErr := libSFTP.NewServer(channel); serverErr == nil {
+						_ = server.Serve()
+					}
+					_ = channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *fakeSFTPServer) breakConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, conn := range s.connections {
+		_ = conn.Close()
+	}
+	s.connections = nil
+}
+
+func TestSFTPReconnectAfterBrokenConnection(t *testing.T) {
+	server := startFakeSFTPServer(t)
+	remotePath := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(remotePath, "metadata.json"), []byte("{}"), 0644))
+	sftp := &SFTP{Config: &config.SFTPConfig{
+		Address:            "127.0.0.1",
+		Port:               uint(server.listener.Addr().(*net.TCPAddr).Port),
+		Username:           "test",
+		Password:           "test",
+		Path:               remotePath,
+		ConnectionPoolSize: 1,
+	}}
+	ctx := context.Background()
+	assert.NoError(t, sftp.Connect(ctx))
+	brokenClient, err := sftp.getClient(ctx)
+	assert.NoError(t, err)
+	_, err = sftp.StatFile(ctx, "metadata.json")
+	assert.NoError(t, err)
+
+	server.breakConnections()
+	// dead client is forgotten in background after SSH transport is closed
+	assert.Eventually(t, func() bool {
+		client, clientErr := sftp.getClient(ctx)
+		return clientErr == nil && client != brokenClient
+	}, 5*time.Second, 10*time.Millisecond)
+	f, err := sftp.StatFile(ctx, "metadata.json")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), f.Size())
+	assert.NoError(t, sftp.Close(ctx))
+}
@@ -10,6 +10,8 @@ import (
 var (
 	// ErrNotFound is returned when file/object cannot be found
 	ErrNotFound = errors.New("key not found")
+	// ErrObjectLocked is returned when object can't be deleted, cause object lock retention is not expired
+	ErrObjectLocked = errors.New("object is locked by object lock retention")
 )
 
 // RemoteFile - interface describe file on remote storage
@@ -36,3 +38,18 @@ type RemoteStorage interface {
 	PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error
 	CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error)
 }
+
+// BackupCopyStorage - RemoteStorage which supports server-side copy of backup files from another bucket of the same storage type, dstKey is relative to `path`
+type BackupCopyStorage interface {
+	CopyBackupObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error)
+}
+
+// ArchiveRestoreStorage - RemoteStorage which keeps objects in archive tier, like S3 GLACIER, DEEP_ARCHIVE or Azure Archive, objects shall be restored before download
+type ArchiveRestoreStorage interface {
+	RestoreArchivedObjects(ctx context.Context, backupNames ...string) error
+}
+
+// ObjectLockStorage - RemoteStorage which supports WORM object lock retention, like S3 Object Lock
+type ObjectLockStorage interface {
+	GetObjectLockRetainUntil(ctx context.Context, key string) (time.Time, error)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"hash"
+	"io"
+	"time"
+)
+
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+// transferReportInterval - how often long transfer report already passed bytes to TransferObserver, so throughput is visible before transfer finished
+const transferReportInterval = time.Second
+
+// TransferObserver receive bytes which was sent or received from remote storage between startTime and finishTime, direction is TransferUpload or TransferDownload
+type TransferObserver func(direction string, size int64, startTime, finishTime time.Time)
+
+// SetTransferObserver allow to track network traffic generated by upload and download of this BackupDestination, nil disable tracking
+func (bd *BackupDestination) SetTransferObserver(observer TransferObserver) {
+	bd.transferObserver = observer
+}
+
+// newCountingReadCloser - count bytes passed through r and report them to transferObserver of BackupDestination
+func (bd *BackupDestination) newCountingReadCloser(r io.ReadCloser, direction string) *countingReadCloser {
+	return &countingReadCloser{ReadCloser: r, observer: bd.transferObserver, direction: direction, reportedAt: time.Now()}
+}
+
+// countingReadCloser count bytes which really passed to PutFile or read from remote storage, compressed stream size is unknown before upload, optional hash calculates checksum of the same bytes
+type countingReadCloser struct {
+	io.ReadCloser
+	size         int64
+	hash         hash.Hash
+	expectedSize int64
+	observer     TransferObserver
+	direction    string
+	reported     int64
+	reportedAt   time.Time
+}
+
+// ExpectedSize - upper bound of stream size, allow remote storage choose multipart part size before upload, 0 means unknown
+func (c *countingReadCloser) ExpectedSize() int64 {
+	return c.expectedSize
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.size += int64(n)
+	if c.hash != nil && n > 0 {
+		c.hash.Write(p[:n])
+	}
+	if err != nil || time.Since(c.reportedAt) >= transferReportInterval {
+		c.report()
+	}
+	return n, err
+}
+
+// report - pass bytes read after previous report to observer, shall be called when transfer finished, Read calls it each transferReportInterval
+func (c *countingReadCloser) report() {
+	now := time.Now()
+	if c.observer != nil && c.size > c.reported {
+		c.observer(c.direction, c.size-c.reported, c.reportedAt, now)
+	}
+	c.reported = c.size
+	c.reportedAt = now
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingReadCloserChecksum(t *testing.T) {
+	data := strings.Repeat("clickhouse-backup", 10000)
+	expected := sha256.Sum256([]byte(data))
+	body := &countingReadCloser{ReadCloser: io.NopCloser(strings.NewReader(data)), hash: sha256.New()}
+	_, err := io.Copy(io.Discard, body)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), body.size)
+	assert.Equal(t, hex.EncodeToString(expected[:]), hex.EncodeToString(body.hash.Sum(nil)))
+
+	body = &countingReadCloser{ReadCloser: io.NopCloser(strings.NewReader(data))}
+	_, err = io.Copy(io.Discard, body)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), body.size)
+}
+
+func TestCountingReadCloserObserver(t *testing.T) {
+	data := strings.Repeat("clickhouse-backup", 1000)
+	var observed int64
+	reports := 0
+	bd := &BackupDestination{}
+	bd.SetTransferObserver(func(direction string, size int64, startTime, finishTime time.Time) {
+		assert.Equal(t, TransferDownload, direction)
+		assert.False(t, finishTime.Before(startTime))
+		observed += size
+		reports++
+	})
+	body := bd.newCountingReadCloser(io.NopCloser(strings.NewReader(data)), TransferDownload)
+	buf := make([]byte, 100)
+	_, err := body.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, reports)
+	// long transfer shall be reported before finish
+	body.reportedAt = time.Now().Add(-transferReportInterval)
+	_, err = body.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reports)
+	assert.Equal(t, int64(200), observed)
+
+	_, err = io.Copy(io.Discard, body)
+	assert.NoError(t, err)
+	body.report()
+	assert.Equal(t, 2, reports)
+	assert.Equal(t, int64(len(data)), observed)
+}
@@ -1,66 +1,87 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/klauspost/compress/zstd"
 	"github.com/mholt/archiver/v4"
-	"github.com/rs/zerolog/log"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 func GetBackupsToDeleteRemote(backups []Backup, keep int) []Backup {
-	if len(backups) > keep {
-		// sort backup ascending
-		sort.SliceStable(backups, func(i, j int) bool {
-			return backups[i].UploadDate.After(backups[j].UploadDate)
-		})
-		// KeepRemoteBackups should respect incremental backups sequences and don't deleteKey required backups
-		// fix https://github.com/Altinity/clickhouse-backup/issues/111
-		// fix https://github.com/Altinity/clickhouse-backup/issues/385
-		// fix https://github.com/Altinity/clickhouse-backup/issues/525
-		deletedBackups := make([]Backup, len(backups)-keep)
-		copied := copy(deletedBackups, backups[keep:])
-		if copied != len(backups)-keep {
-			log.Warn().Msgf("copied wrong items from backup list expected=%d, actual=%d", len(backups)-keep, copied)
+	return GetBackupsToDeleteRemoteByPolicy(backups, config.RetentionPolicy{KeepLast: keep}, time.Now(), nil)
+}
+
+// GetBackupsToDeleteRemoteByPolicy - the same as GetBackupsToDeleteRemote, but also respects `backups_to_keep_days_remote` and `backups_to_keep_gfs_remote`
+func GetBackupsToDeleteRemoteByPolicy(backups []Backup, policy config.RetentionPolicy, now time.Time, location *time.Location) []Backup {
+	// sort backup descending
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].UploadDate.After(backups[j].UploadDate)
+	})
+	// pinned backups and backups with `retention_ignore_tags` don't occupy retention slots, and never deleted
+	keepBackups := make([]Backup, 0, len(backups))
+	candidates := make([]Backup, 0, len(backups))
+	for _, b := range backups {
+		if b.Pinned || b.MatchAnyUserTag(policy.IgnoreTags) {
+			keepBackups = append(keepBackups, b)
+		} else {
+			candidates = append(candidates, b)
 		}
-		keepBackups := make([]Backup, keep)
-		copied = copy(keepBackups, backups[:keep])
-		if copied != keep {
-			log.Warn().Msgf("copied wrong items from backup list expected=%d, actual=%d", keep, copied)
+	}
+	if !policy.IsCalendarBased() && len(candidates) <= policy.KeepLast {
+		return []Backup{}
+	}
+	uploadDates := make([]time.Time, len(candidates))
+	for i := range candidates {
+		uploadDates[i] = candidates[i].UploadDate
+	}
+	isKept := SelectBackupsToKeep(uploadDates, policy, now, location)
+	// KeepRemoteBackups should respect incremental backups sequences and don't deleteKey required backups
+	// fix https://github.com/Altinity/clickhouse-backup/issues/111
+	// fix https://github.com/Altinity/clickhouse-backup/issues/385
+	// fix https://github.com/Altinity/clickhouse-backup/issues/525
+	deletedBackups := make([]Backup, 0, len(candidates))
+	for i := range candidates {
+		if isKept[i] {
+			keepBackups = append(keepBackups, candidates[i])
+		} else {
+			deletedBackups = append(deletedBackups, candidates[i])
 		}
-		var findRequiredBackup func(b Backup)
-		findRequiredBackup = func(b Backup) {
-			if b.RequiredBackup != "" {
-				for i, deletedBackup := range deletedBackups {
-					if b.RequiredBackup == deletedBackup.BackupName {
-						deletedBackups = append(deletedBackups[:i], deletedBackups[i+1:]...)
-						findRequiredBackup(deletedBackup)
-						break
-					}
+	}
+	var findRequiredBackup func(b Backup)
+	findRequiredBackup = func(b Backup) {
+		if b.RequiredBackup != "" {
+			for i, deletedBackup := range deletedBackups {
+				if b.RequiredBackup == deletedBackup.BackupName {
+					deletedBackups = append(deletedBackups[:i], deletedBackups[i+1:]...)
+					findRequiredBackup(deletedBackup)
+					break
 				}
 			}
 		}
-		for _, b := range keepBackups {
-			findRequiredBackup(b)
-		}
-		// remove from old backup list backup with UploadDate `0001-01-01 00:00:00`, to avoid race condition for multiple shards copy
-		// fix https://github.com/Altinity/clickhouse-backup/issues/409
-		i := 0
-		for _, b := range deletedBackups {
-			if b.UploadDate != time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC) {
-				deletedBackups[i] = b
-				i++
-			}
+	}
+	for _, b := range keepBackups {
+		findRequiredBackup(b)
+	}
+	// remove from old backup list backup with UploadDate `0001-01-01 00:00:00`, to avoid race condition for multiple shards copy
+	// fix https://github.com/Altinity/clickhouse-backup/issues/409
+	i := 0
+	for _, b := range deletedBackups {
+		if b.UploadDate != time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC) {
+			deletedBackups[i] = b
+			i++
 		}
-		deletedBackups = deletedBackups[:i]
-		return deletedBackups
 	}
-	return []Backup{}
+	deletedBackups = deletedBackups[:i]
+	return deletedBackups
 }
 
-func getArchiveWriter(format string, level int) (*archiver.CompressedArchive, error) {
+func getArchiveWriter(format string, level, concurrency int) (*archiver.CompressedArchive, error) {
 	switch format {
 	case "tar":
 		return &archiver.CompressedArchive{Archival: archiver.Tar{}}, nil
@@ -77,7 +98,7 @@ func getArchiveWriter(format string, level int) (*archiver.CompressedArchive, er
 	case "br", "brotli":
 		return &archiver.CompressedArchive{Compression: archiver.Brotli{Quality: level}, Archival: archiver.Tar{}}, nil
 	case "zstd":
-		return &archiver.CompressedArchive{Compression: archiver.Zstd{EncoderOptions: []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level))}}, Archival: archiver.Tar{}}, nil
+		return &archiver.CompressedArchive{Compression: archiver.Zstd{EncoderOptions: []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(max(concurrency, 1))}}, Archival: archiver.Tar{}}, nil
 	}
 	return nil, fmt.Errorf("wrong compression_format: %s, supported: 'tar', 'lz4', 'bzip2', 'bz2', 'gzip', 'gz', 'sz', 'xz', 'br', 'brotli', 'zstd'", format)
 }
@@ -128,3 +149,51 @@ func checkArchiveExtension(ext, format string) bool {
 	}
 	return false
 }
+
+// archiveRestorePollInterval - first pause between checks of archived object restore, each next pause is longer, but not longer than archiveRestoreMaxPollInterval
+var archiveRestorePollInterval = 5 * time.Second
+
+const archiveRestoreMaxPollInterval = 5 * time.Minute
+
+// filterNotRestored - return keys which are still not restored from archive tier
+func filterNotRestored(ctx context.Context, keys []string, isRestored func(ctx context.Context, key string) (bool, error)) ([]string, error) {
+	notRestored := make([]string, 0, len(keys))
+	for _, key := range keys {
+		restored, err := isRestored(ctx, key)
+		if err != nil {
+			return keys, err
+		}
+		if !restored {
+			notRestored = append(notRestored, key)
+		}
+	}
+	return notRestored, nil
+}
+
+// waitArchiveRestore - poll isRestored until object from archive tier (S3 GLACIER, DEEP_ARCHIVE, Azure Archive) become readable, maxWait=0 means wait without limit
+func waitArchiveRestore(ctx context.Context, key string, maxWait time.Duration, isRestored func(ctx context.Context) (bool, error)) error {
+	start := time.Now()
+	for i := 1; ; i++ {
+		restored, err := isRestored(ctx)
+		if err != nil {
+			return err
+		}
+		if restored {
+			log.Info().Str("key", key).Str("duration", time.Since(start).String()).Msg("restored from archive")
+			return nil
+		}
+		if maxWait > 0 && time.Since(start) >= maxWait {
+			return fmt.Errorf("%s still not restored from archive after %s", key, maxWait)
+		}
+		pause := min(time.Duration(i)*archiveRestorePollInterval, archiveRestoreMaxPollInterval)
+		if maxWait > 0 {
+			pause = max(min(pause, maxWait-time.Since(start)), 0)
+		}
+		log.Warn().Msgf("%s still not restored from archive, will wait %s", key, pause)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"log"
 	"testing"
 	"time"
 
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,6 +66,43 @@ func TestGetBackupsToDeleteWithRequiredBackup(t *testing.T) {
 
 }
 
+func TestGetBackupsToDeleteWithPinnedBackup(t *testing.T) {
+	testData := []Backup{
+		{metadata.BackupMetadata{BackupName: "3"}, "", timeParse("2019-03-28T19-50-13")},
+		{metadata.BackupMetadata{BackupName: "1", Pinned: true}, "", timeParse("2019-03-28T19-50-11")},
+		{metadata.BackupMetadata{BackupName: "5", Pinned: true}, "", timeParse("2019-03-28T19-50-15")},
+		{metadata.BackupMetadata{BackupName: "2"}, "", timeParse("2019-03-28T19-50-12")},
+		{metadata.BackupMetadata{BackupName: "4", RequiredBackup: "3"}, "", timeParse("2019-03-28T19-50-14")},
+	}
+	// pinned backups don't occupy retention slots
+	expectedData := []Backup{
+		{metadata.BackupMetadata{BackupName: "2"}, "", timeParse("2019-03-28T19-50-12")},
+	}
+	assert.Equal(t, expectedData, GetBackupsToDeleteRemote(testData, 2))
+	// required backup for pinned backup is kept too
+	for i := range testData {
+		if testData[i].BackupName == "5" {
+			testData[i].RequiredBackup = "2"
+		}
+	}
+	assert.Equal(t, []Backup{}, GetBackupsToDeleteRemote(testData, 2))
+}
+
+func TestGetBackupsToDeleteWithRetentionIgnoreTags(t *testing.T) {
+	testData := []Backup{
+		{metadata.BackupMetadata{BackupName: "3"}, "", timeParse("2019-03-28T19-50-13")},
+		{metadata.BackupMetadata{BackupName: "1", UserTags: map[string]string{"reason": "pre-upgrade"}}, "", timeParse("2019-03-28T19-50-11")},
+		{metadata.BackupMetadata{BackupName: "2", UserTags: map[string]string{"reason": "daily"}}, "", timeParse("2019-03-28T19-50-12")},
+		{metadata.BackupMetadata{BackupName: "4"}, "", timeParse("2019-03-28T19-50-14")},
+	}
+	// backups with ignored tags don't occupy retention slots
+	expectedData := []Backup{
+		{metadata.BackupMetadata{BackupName: "2", UserTags: map[string]string{"reason": "daily"}}, "", timeParse("2019-03-28T19-50-12")},
+	}
+	policy := config.RetentionPolicy{KeepLast: 2, IgnoreTags: map[string]string{"reason": "pre-upgrade"}}
+	assert.Equal(t, expectedData, GetBackupsToDeleteRemoteByPolicy(testData, policy, time.Now(), nil))
+}
+
 func TestGetBackupsToDeleteWithInvalidUploadDate(t *testing.T) {
 	// fix https://github.com/Altinity/clickhouse-backup/issues/409
 	testData := []Backup{
@@ -125,3 +166,102 @@ func TestGetBackupsToDeleteWithRecursiveRequiredBackups(t *testing.T) {
 	}
 	assert.Equal(t, expectedData, GetBackupsToDeleteRemote(testData, 6))
 }
+
+func TestGetBackupsToDeleteByPolicy(t *testing.T) {
+	now := timeParse("2024-03-31T12-00-00")
+	testData := make([]Backup, 0, 120)
+	for i := 0; i < 120; i++ {
+		uploadDate := now.AddDate(0, 0, -i)
+		testData = append(testData, Backup{BackupMetadata: metadata.BackupMetadata{BackupName: uploadDate.Format("2006-01-02")}, UploadDate: uploadDate})
+	}
+	keptBackups := func(deleted []Backup) []string {
+		isDeleted := map[string]struct{}{}
+		for _, b := range deleted {
+			isDeleted[b.BackupName] = struct{}{}
+		}
+		kept := make([]string, 0)
+		for _, b := range testData {
+			if _, exists := isDeleted[b.BackupName]; !exists {
+				kept = append(kept, b.BackupName)
+			}
+		}
+		return kept
+	}
+	// 7 daily, newest backup for 4 ISO weeks and for 3 months, some of them are the same
+	deleted := GetBackupsToDeleteRemoteByPolicy(testData, config.RetentionPolicy{Daily: 7, Weekly: 4, Monthly: 3}, now, nil)
+	assert.Equal(t, []string{
+		"2024-03-31", "2024-03-30", "2024-03-29", "2024-03-28", "2024-03-27", "2024-03-26", "2024-03-25",
+		"2024-03-24", "2024-03-17", "2024-03-10", "2024-02-29", "2024-01-31",
+	}, keptBackups(deleted))
+
+	deleted = GetBackupsToDeleteRemoteByPolicy(testData, config.RetentionPolicy{KeepDays: 10}, now, nil)
+	assert.Equal(t, 11, len(keptBackups(deleted)))
+
+	// keep last and calendar rules are combined
+	deleted = GetBackupsToDeleteRemoteByPolicy(testData, config.RetentionPolicy{KeepLast: 2, Monthly: 2}, now, nil)
+	assert.Equal(t, []string{"2024-03-31", "2024-03-30", "2024-02-29"}, keptBackups(deleted))
+}
+
+func TestGetArchiveWriterZstdConcurrency(t *testing.T) {
+	data := bytes.Repeat([]byte("clickhouse-backup zstd parallel compression "), 1<<16)
+	for _, concurrency := range []int{0, 1, 4} {
+		z, err := getArchiveWriter("zstd", 3, concurrency)
+		assert.NoError(t, err)
+		compressed := &bytes.Buffer{}
+		w, err := z.Compression.OpenWriter(compressed)
+		assert.NoError(t, err)
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		assert.Less(t, compressed.Len(), len(data))
+
+		reader, err := getArchiveReader("zstd")
+		assert.NoError(t, err)
+		r, err := reader.Compression.OpenReader(compressed)
+		assert.NoError(t, err)
+		decompressed, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+		assert.Equal(t, data, decompressed)
+	}
+}
+
+func TestWaitArchiveRestore(t *testing.T) {
+	archiveRestorePollInterval = time.Millisecond
+	defer func() { archiveRestorePollInterval = 5 * time.Second }()
+	checks := 0
+	err := waitArchiveRestore(context.Background(), "backup/shadow/default_1.tar", time.Minute, func(ctx context.Context) (bool, error) {
+		checks++
+		return checks == 3, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, checks)
+
+	err = waitArchiveRestore(context.Background(), "backup/shadow/default_1.tar", 10*time.Millisecond, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	assert.ErrorContains(t, err, "still not restored from archive after 10ms")
+}
+
+func TestFilterNotRestored(t *testing.T) {
+	// all keys are polled together, each check restores one more object
+	restored := map[string]bool{}
+	isRestored := func(ctx context.Context, key string) (bool, error) {
+		return restored[key], nil
+	}
+	keys := []string{"backup/shadow/default_1.tar", "backup/shadow/default_2.tar", "backup/metadata/db/table.json"}
+	pending, err := filterNotRestored(context.Background(), keys, isRestored)
+	assert.NoError(t, err)
+	assert.Equal(t, keys, pending)
+
+	restored["backup/shadow/default_2.tar"] = true
+	pending, err = filterNotRestored(context.Background(), pending, isRestored)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backup/shadow/default_1.tar", "backup/metadata/db/table.json"}, pending)
+
+	pending, err = filterNotRestored(context.Background(), pending, func(ctx context.Context, key string) (bool, error) {
+		return false, io.ErrUnexpectedEOF
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Len(t, pending, 2, "pending keys shall be kept when check failed")
+}
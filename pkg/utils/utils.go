@@ -6,6 +6,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -39,6 +40,33 @@ func FormatBytes(i uint64) string {
 	}
 }
 
+// ParseBytes - Convert human-readable size like 10GB, 512MiB or 1048576 to bytes, units are powers of 1024 the same as in FormatBytes
+func ParseBytes(s string) (uint64, error) {
+	units := []struct {
+		suffix     string
+		multiplier uint64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := uint64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return uint64(size * float64(multiplier)), nil
+}
+
 func HumanizeDuration(d time.Duration) string {
 	if d < day {
 		return d.Round(time.Millisecond).String()
@@ -1127,6 +1127,16 @@ func testAPIBackupActions(r *require.Assertions, env *TestEnvironment) {
 	runClickHouseClientInsertSystemBackupActions(r, env, []string{"restore --rm actions_backup2"}, true)
 	runClickHouseClientInsertSystemBackupActions(r, env, []string{"delete local actions_backup2", "delete remote actions_backup2"}, false)
 
+	// read commands return result rows as JSONEachRow
+	runClickHouseClientInsertSystemBackupActions(r, env, []string{"create actions_backup3"}, true)
+	out, err := env.DockerExecOut("clickhouse-backup", "bash", "-ce", `curl -sfL -XPOST -d '{"command":"list local"}' 'http://localhost:7171/backup/actions'`)
+	r.NoError(err, "%s\nunexpected POST /backup/actions list error: %v", out, err)
+	r.Contains(out, `"name":"actions_backup3"`)
+	out, err = env.DockerExecOut("clickhouse-backup", "bash", "-ce", `curl -sfL -XPOST -d '{"command":"tables --tables=long_schema.*"}' 'http://localhost:7171/backup/actions'`)
+	r.NoError(err, "%s\nunexpected POST /backup/actions tables error: %v", out, err)
+	r.Contains(out, `"Database":"long_schema"`)
+	runClickHouseClientInsertSystemBackupActions(r, env, []string{"delete local actions_backup3"}, false)
+
 	inProgressActions := make([]struct {
 		Command string `ch:"command"`
 		Status  string `ch:"status"`
@@ -1138,7 +1148,7 @@ func testAPIBackupActions(r *require.Assertions, env *TestEnvironment) {
 	r.NoError(env.ch.SelectSingleRowNoCtx(&actionsBackups, "SELECT count() FROM system.backup_list WHERE name LIKE 'backup_action%'"))
 	r.Equal(uint64(0), actionsBackups)
 
-	out, err := env.DockerExecOut("clickhouse-backup", "curl", "http://localhost:7171/metrics")
+	out, err = env.DockerExecOut("clickhouse-backup", "curl", "http://localhost:7171/metrics")
 	r.NoError(err, "%s\nunexpected error: %v", out, err)
 	r.Contains(out, "clickhouse_backup_last_create_remote_status 1")
 	r.Contains(out, "clickhouse_backup_last_create_status 1")
@@ -1147,6 +1157,15 @@ func testAPIBackupActions(r *require.Assertions, env *TestEnvironment) {
 	r.Contains(out, "clickhouse_backup_last_download_status 1")
 	r.Contains(out, "clickhouse_backup_last_restore_status 1")
 	r.Regexp(regexp.MustCompile(`clickhouse_backup_local_data_size\s+\d+`), out)
+	r.Regexp(regexp.MustCompile(`clickhouse_backup_newest_backup_age_remote\s+[\d.e+]+`), out)
+	r.Regexp(regexp.MustCompile(`clickhouse_backup_oldest_backup_age_local\s+[\d.e+]+`), out)
+	r.Regexp(regexp.MustCompile(`clickhouse_backup_uploaded_bytes_total\s+[1-9][\d.e+]*`), out)
+	r.Regexp(regexp.MustCompile(`clickhouse_backup_downloaded_bytes_total\s+[1-9][\d.e+]*`), out)
+
+	out, err = env.DockerExecOut("clickhouse-backup", "curl", "-sfL", "http://localhost:7171/health/full")
+	r.NoError(err, "%s\nunexpected /health/full error: %v", out, err)
+	r.Contains(out, `"status":"OK"`)
+	r.Contains(out, `{"component":"remote_storage","status":"OK"`)
 }
 
 func testAPIWatchAndKill(r *require.Assertions, env *TestEnvironment) {
@@ -2424,6 +2443,44 @@ func TestRestoreMapping(t *testing.T) {
 	env.Cleanup(t, r)
 }
 
+func TestReplicatedDatabaseRestore(t *testing.T) {
+	if compareVersion(os.Getenv("CLICKHOUSE_VERSION"), "23.3") == -1 {
+		t.Skipf("Test skipped, ENGINE=Replicated database is experimental for %s version", os.Getenv("CLICKHOUSE_VERSION"))
+	}
+	env, r := NewTestEnvironment(t)
+	env.connectWithWait(r, 500*time.Millisecond, 1*time.Second, 1*time.Minute)
+	dbName := "test_replicated_db"
+	backupName := "test_replicated_db_backup"
+	r.NoError(env.dropDatabase(dbName))
+	env.queryWithNoError(r, "CREATE DATABASE "+dbName+" ENGINE=Replicated('/clickhouse/databases/{database}','{shard}','{replica}')")
+	env.queryWithNoError(r, "CREATE TABLE "+dbName+".t1 (id UInt64) ENGINE=ReplicatedMergeTree ORDER BY id")
+	env.queryWithNoError(r, "CREATE TABLE "+dbName+".t2 (id UInt64) ENGINE=MergeTree ORDER BY id")
+	env.queryWithNoError(r, "INSERT INTO "+dbName+".t1 SELECT number FROM numbers(100)")
+	env.queryWithNoError(r, "INSERT INTO "+dbName+".t2 SELECT number FROM numbers(10)")
+
+	// config-s3.yml contains restore_schema_on_cluster, tables inside Replicated database shall restore without ON CLUSTER
+	env.DockerExecNoError(r, "clickhouse-backup", "clickhouse-backup", "-c", "/etc/clickhouse-backup/config-s3.yml", "create", "--tables="+dbName+".*", backupName)
+	r.NoError(env.dropDatabase(dbName))
+	out, err := env.DockerExecOut("clickhouse-backup", "clickhouse-backup", "-c", "/etc/clickhouse-backup/config-s3.yml", "restore", "--rm", "--replicated-ddl-wait", "--tables="+dbName+".*", backupName)
+	r.NoError(err, "%s\nunexpected restore error: %v", out, err)
+	r.Contains(out, "replicated_ddl_wait")
+
+	for table, expectedCount := range map[string]uint64{"t1": 100, "t2": 10} {
+		var count uint64
+		r.NoError(env.ch.SelectSingleRowNoCtx(&count, "SELECT count() FROM "+dbName+"."+table))
+		r.Equal(expectedCount, count, "unexpected count() for %s.%s", dbName, table)
+	}
+
+	// second restore schema, tables already exists via Replicated database DDL log, shall not fail
+	out, err = env.DockerExecOut("clickhouse-backup", "clickhouse-backup", "-c", "/etc/clickhouse-backup/config-s3.yml", "restore", "--schema", "--tables="+dbName+".*", backupName)
+	r.NoError(err, "%s\nunexpected restore error: %v", out, err)
+	r.Contains(out, "already created via Replicated database DDL log")
+
+	env.DockerExecNoError(r, "clickhouse-backup", "clickhouse-backup", "-c", "/etc/clickhouse-backup/config-s3.yml", "delete", "local", backupName)
+	r.NoError(env.dropDatabase(dbName))
+	env.Cleanup(t, r)
+}
+
 func TestMySQLMaterialized(t *testing.T) {
 	t.Skipf("Wait when fix DROP TABLE not supported by MaterializedMySQL, just attach will not help, https://github.com/ClickHouse/ClickHouse/issues/57543")
 	if compareVersion(os.Getenv("CLICKHOUSE_VERSION"), "22.12") == -1 {